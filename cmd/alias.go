@@ -0,0 +1,217 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"pb/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	forceAliasFlag = "force"
+
+	// aliasNamePattern matches the names an alias can have
+	aliasNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+	// reservedCommandNames are commands cobra adds on its own, which are not
+	// among the root commands before the command line is parsed
+	reservedCommandNames = []string{"help", "completion", "__complete", "__completeNoDesc"}
+)
+
+var AddAliasCmd = &cobra.Command{
+	Use:   "add alias-name command",
+	Short: "Add an alias for a pb command line",
+	Example: `  pb alias add recent-errors 'query run "select * from errors" --from 1h'
+  pb recent-errors -o json`,
+	Long: `Add an alias for a pb command line.
+
+The command is written as you would type it after pb, in one quoted
+argument. Running pb alias-name runs that command line, with any further
+arguments appended to it. An alias cannot have the name of a pb command, and
+it must start with a pb command, so aliases never run other aliases.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		startTime := time.Now()
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		name, command := args[0], strings.TrimSpace(args[1])
+		if err := validateAlias(cmd.Root(), name, command); err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
+		force, _ := cmd.Flags().GetBool(forceAliasFlag)
+		err := config.UpdateConfig(func(conf *config.Config) error {
+			if existing, ok := conf.Aliases[name]; ok && !force {
+				return fmt.Errorf("alias %s already runs %q, pass --%s to replace it", name, existing, forceAliasFlag)
+			}
+			if conf.Aliases == nil {
+				conf.Aliases = make(map[string]string)
+			}
+			conf.Aliases[name] = command
+			return nil
+		})
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		fmt.Printf("Added alias %s, run it with pb %s\n", StyleBold.Render(name), name)
+		return nil
+	},
+}
+
+var ListAliasCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List aliases",
+	Example: "  pb alias list",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		startTime := time.Now()
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		fileConfig, err := config.ReadConfigFromFile()
+		if err != nil {
+			cmd.Annotations["error"] = fmt.Sprintf("error reading config: %s", err)
+			return err
+		}
+		if len(fileConfig.Aliases) == 0 {
+			fmt.Println("No aliases, add one with pb alias add")
+			return nil
+		}
+
+		names := make([]string, 0, len(fileConfig.Aliases))
+		for name := range fileConfig.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s = %s\n", StyleBold.Render(name), fileConfig.Aliases[name])
+		}
+		return nil
+	},
+}
+
+var RemoveAliasCmd = &cobra.Command{
+	Use:     "remove alias-name",
+	Aliases: []string{"rm"},
+	Short:   "Delete an alias",
+	Example: "  pb alias remove prod-errors",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		startTime := time.Now()
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		name := args[0]
+		err := config.UpdateConfig(func(conf *config.Config) error {
+			if _, ok := conf.Aliases[name]; !ok {
+				return fmt.Errorf("no alias named %s", name)
+			}
+			delete(conf.Aliases, name)
+			return nil
+		})
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		fmt.Printf("Deleted alias %s\n", name)
+		return nil
+	},
+}
+
+func init() {
+	AddAliasCmd.Flags().Bool(forceAliasFlag, false, "Replace an existing alias of the same name")
+}
+
+// isBuiltinCommand reports whether name runs a pb command, by its name or
+// one of its aliases
+func isBuiltinCommand(root *cobra.Command, name string) bool {
+	for _, reserved := range reservedCommandNames {
+		if name == reserved {
+			return true
+		}
+	}
+	for _, command := range root.Commands() {
+		if command.Name() == name || command.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAlias checks an alias before it is saved. The name must not shadow
+// a pb command, and the command line must start with one
+func validateAlias(root *cobra.Command, name, command string) error {
+	if !aliasNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid alias name %q, start with a letter and use only letters, digits, - and _", name)
+	}
+	if isBuiltinCommand(root, name) {
+		return fmt.Errorf("alias %s would shadow the pb command of the same name, choose another name", name)
+	}
+
+	args, err := splitShellArgs(command)
+	if err != nil {
+		return fmt.Errorf("invalid command for alias %s: %w", name, err)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("the command for alias %s is empty", name)
+	}
+	if !isBuiltinCommand(root, args[0]) {
+		return fmt.Errorf("the command for alias %s must start with a pb command such as query or stream, not %q", name, args[0])
+	}
+	return nil
+}
+
+// ExpandAlias replaces a user defined alias at the start of args with the
+// command line it stands for, keeping the remaining args after it. pb
+// commands always win over aliases, even when the config file was edited by
+// hand, and the expansion is never expanded again
+func ExpandAlias(root *cobra.Command, args []string, aliases map[string]string) ([]string, error) {
+	if len(args) == 0 || isBuiltinCommand(root, args[0]) {
+		return args, nil
+	}
+	command, ok := aliases[args[0]]
+	if !ok {
+		return args, nil
+	}
+
+	expanded, err := splitShellArgs(command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command for alias %s: %w", args[0], err)
+	}
+	return append(expanded, args[1:]...), nil
+}
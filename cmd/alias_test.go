@@ -0,0 +1,101 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func aliasTestRoot() *cobra.Command {
+	root := &cobra.Command{Use: "pb"}
+	root.AddCommand(
+		&cobra.Command{Use: "query", Run: func(*cobra.Command, []string) {}},
+		&cobra.Command{Use: "stream", Aliases: []string{"logstream"}, Run: func(*cobra.Command, []string) {}},
+	)
+	return root
+}
+
+func TestExpandAliasAppendsArgs(t *testing.T) {
+	aliases := map[string]string{"prod-errors": `query run "select * from errors" --from 1h`}
+
+	args, err := ExpandAlias(aliasTestRoot(), []string{"prod-errors", "--to", "now", "-o", "json"}, aliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"query", "run", "select * from errors", "--from", "1h", "--to", "now", "-o", "json"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
+
+func TestExpandAliasLeavesOtherArgs(t *testing.T) {
+	root := aliasTestRoot()
+	aliases := map[string]string{
+		"errors": "query run errors",
+		// a hand edited alias that shadows a command must never run
+		"query": "stream list",
+		// expansions are not expanded again
+		"twice": "errors --from 1h",
+	}
+
+	for _, args := range [][]string{nil, {"query", "run"}, {"logstream", "list"}, {"unknown"}} {
+		got, err := ExpandAlias(root, args, aliases)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, args) {
+			t.Errorf("expected %q to be left alone, got %q", args, got)
+		}
+	}
+
+	got, _ := ExpandAlias(root, []string{"twice"}, aliases)
+	if expected := []string{"errors", "--from", "1h"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected a single expansion %q, got %q", expected, got)
+	}
+}
+
+func TestValidateAliasPreventsShadowing(t *testing.T) {
+	root := aliasTestRoot()
+	for _, name := range []string{"query", "logstream", "help", "completion"} {
+		err := validateAlias(root, name, "query run errors")
+		if err == nil || !strings.Contains(err.Error(), "shadow") {
+			t.Errorf("expected alias %s to be rejected as shadowing, got %v", name, err)
+		}
+	}
+}
+
+func TestValidateAliasChecksCommand(t *testing.T) {
+	root := aliasTestRoot()
+	if err := validateAlias(root, "prod-errors", `query run "select * from errors"`); err != nil {
+		t.Errorf("expected a valid alias, got %v", err)
+	}
+
+	for name, command := range map[string]string{
+		"-errors":  "query run errors",
+		"my alias": "query run errors",
+		"empty":    "  ",
+		"other":    "prod-errors --from 1h",
+		"unclosed": `query run "select`,
+	} {
+		if err := validateAlias(root, name, command); err == nil {
+			t.Errorf("expected alias %q = %q to be rejected", name, command)
+		}
+	}
+}
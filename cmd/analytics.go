@@ -0,0 +1,135 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"pb/pkg/analytics"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+)
+
+var analyticsPurgeFlag = "purge"
+
+// AnalyticsStatus is the state of usage analytics for this install
+type AnalyticsStatus struct {
+	Enabled       bool                   `json:"enabled"`
+	DisabledBy    string                 `json:"disabled_by,omitempty"`
+	ULID          string                 `json:"ulid,omitempty"`
+	ConfigPath    string                 `json:"config_path"`
+	EventsURL     string                 `json:"events_url"`
+	CollectedData []analytics.EventField `json:"collected_data"`
+}
+
+// AnalyticsStatusCmd shows whether analytics is enabled and what it sends
+var AnalyticsStatusCmd = &cobra.Command{
+	Use:     "status",
+	Example: "  pb analytics status\n  pb analytics status --purge",
+	Short:   "Show what usage analytics pb collects",
+	Long:    "\nShow whether usage analytics is enabled, the ID that identifies this install, where it is stored and which fields are sent after each command.\nUse --purge to delete the stored ID and turn analytics off.",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		if purge, _ := cmd.Flags().GetBool(analyticsPurgeFlag); purge {
+			if err := analytics.Purge(); err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+			fmt.Println("Deleted the install ID and turned analytics off")
+		}
+
+		status, err := fetchAnalyticsStatus()
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		if output == "json" {
+			jsonData, err := json.MarshalIndent(status, "", "  ")
+			if err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+			fmt.Println(string(jsonData))
+			return nil
+		}
+
+		enabled := "yes"
+		if !status.Enabled {
+			enabled = "no, " + status.DisabledBy
+		}
+		ulid := status.ULID
+		if ulid == "" {
+			ulid = "none"
+		}
+
+		fmt.Printf("%-12s %s\n", "Enabled:", enabled)
+		fmt.Printf("%-12s %s\n", "Install ID:", ulid)
+		fmt.Printf("%-12s %s\n", "Stored in:", status.ConfigPath)
+		fmt.Printf("%-12s %s\n", "Sent to:", status.EventsURL)
+		fmt.Println()
+		fmt.Println(StyleBold.Render("Fields sent after each command:"))
+		for _, field := range status.CollectedData {
+			fmt.Printf("  %-20s %s\n", field.Name, field.Description)
+		}
+		if status.Enabled {
+			fmt.Printf("\nTo turn analytics off, set %s=disable or run pb analytics status --%s\n", analytics.DisableEnv, analyticsPurgeFlag)
+		}
+		return nil
+	},
+}
+
+func init() {
+	AnalyticsStatusCmd.Flags().Bool(analyticsPurgeFlag, false, "Delete the stored install ID and turn analytics off")
+	AnalyticsStatusCmd.Flags().StringP("output", "o", "", "Output format (text|json)")
+}
+
+func fetchAnalyticsStatus() (AnalyticsStatus, error) {
+	configPath, err := analytics.ConfigPath()
+	if err != nil {
+		return AnalyticsStatus{}, err
+	}
+
+	status := AnalyticsStatus{
+		Enabled:       analytics.Enabled(),
+		ConfigPath:    configPath,
+		EventsURL:     analytics.EventsURL,
+		CollectedData: analytics.EventFields,
+	}
+	if ulid, err := analytics.ReadUULD(); err == nil {
+		status.ULID = ulid
+	}
+	if !status.Enabled {
+		status.DisabledBy = "turned off in " + configPath
+		if os.Getenv(analytics.DisableEnv) == "disable" {
+			status.DisabledBy = analytics.DisableEnv + "=disable is set"
+		} else if internalHTTP.Offline {
+			status.DisabledBy = "offline mode is on"
+		}
+	}
+	return status, nil
+}
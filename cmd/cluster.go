@@ -17,54 +17,166 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"pb/pkg/common"
 	"pb/pkg/helm"
 	"pb/pkg/installer"
+	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 var verbose bool
 
+var (
+	waitFlag         = "wait"
+	waitTimeoutFlag  = "wait-timeout"
+	forceInstallFlag = "force"
+
+	defaultWaitTimeout = 10 * time.Minute
+	waitPollInterval   = 5 * time.Second
+)
+
 var InstallOssCmd = &cobra.Command{
 	Use:     "install",
 	Short:   "Deploy Parseable",
-	Example: "pb cluster install",
-	Run: func(cmd *cobra.Command, _ []string) {
-		// Add verbose flag
-		cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
-		installer.Installer(verbose)
+	Example: "pb cluster install\npb cluster install --wait --wait-timeout=15m\npb cluster install --force",
+	Long: `Deploy Parseable with Helm.
+
+Installing is safe to repeat. When a release of the same name already exists
+in the namespace, pb upgrades it if the chart version or settings differ and
+leaves it alone if they match, then reports whether it installed, upgraded or
+left the release unchanged. The secret with the credentials and object store
+settings is updated in place. Pass --force to remove the existing release and
+install it again.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cmd.SilenceUsage = true
+		wait, _ := cmd.Flags().GetBool(waitFlag)
+		waitTimeout, _ := cmd.Flags().GetDuration(waitTimeoutFlag)
+
+		force, _ := cmd.Flags().GetBool(forceInstallFlag)
+		entry, err := installer.Installer(verbose, force)
+		if err != nil {
+			return err
+		}
+		if !wait {
+			return nil
+		}
+		return waitForInstallation(entry, waitTimeout)
 	},
 }
 
+func init() {
+	InstallOssCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+	InstallOssCmd.Flags().Bool(waitFlag, false, "Wait until all replicas of the installation are ready")
+	InstallOssCmd.Flags().Duration(waitTimeoutFlag, defaultWaitTimeout, "How long --wait waits before failing")
+	InstallOssCmd.Flags().Bool(forceInstallFlag, false, "Remove and install again an existing release of the same name instead of upgrading it")
+}
+
+// waitForInstallation polls the deployments and statefulsets of an
+// installation until all replicas are ready or the timeout elapses
+func waitForInstallation(entry common.InstallerEntry, timeout time.Duration) error {
+	config, err := common.LoadKubeConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		ready, desired, found, err := countReadyReplicas(ctx, clientset, entry)
+		cancel()
+
+		elapsed := time.Since(start).Round(time.Second)
+		switch {
+		case err != nil:
+			fmt.Printf(common.Yellow+"Waiting for %s (%s): %v"+common.Reset+"\n", entry.Name, elapsed, err)
+		case !found:
+			fmt.Printf(common.Yellow+"Waiting for %s (%s): no workloads found yet"+common.Reset+"\n", entry.Name, elapsed)
+		case desired > 0 && ready == desired:
+			fmt.Printf(common.Green+"%s is ready, %d/%d replicas (%s)"+common.Reset+"\n", entry.Name, ready, desired, elapsed)
+			return nil
+		default:
+			fmt.Printf(common.Yellow+"Waiting for %s (%s): %d/%d replicas ready"+common.Reset+"\n", entry.Name, elapsed, ready, desired)
+		}
+
+		if time.Now().Add(waitPollInterval).After(deadline) {
+			return fmt.Errorf("%s was not ready after %s", entry.Name, timeout)
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
 // ListOssCmd lists the Parseable OSS servers
 var ListOssCmd = &cobra.Command{
 	Use:     "list",
 	Short:   "List available Parseable servers",
-	Example: "pb list",
-	Run: func(_ *cobra.Command, _ []string) {
+	Example: "pb list\npb cluster list -o wide",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cmd.SilenceUsage = true
+		output, _ := cmd.Flags().GetString("output")
+		if output != "" && output != "text" && output != "wide" && output != "json" {
+			return fmt.Errorf("unsupported output format %q, use text, wide or json", output)
+		}
+
 		_, err := common.PromptK8sContext()
 		if err != nil {
-			log.Fatalf("Failed to prompt for kubernetes context: %v", err)
+			return fmt.Errorf("failed to prompt for kubernetes context: %w", err)
 		}
 
 		// Read the installer data from the ConfigMap
 		entries, err := common.ReadInstallerConfigMap()
 		if err != nil {
-			log.Fatalf("Failed to list servers: %v", err)
+			return fmt.Errorf("failed to list servers: %w", err)
+		}
+
+		if output == "wide" || output == "json" {
+			details := make([]installationDetail, len(entries))
+			for idx, entry := range entries {
+				details[idx] = fetchInstallationDetail(entry)
+			}
+
+			if output == "json" {
+				jsonOutput, err := json.MarshalIndent(details, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to marshal JSON output: %w", err)
+				}
+				fmt.Println(string(jsonOutput))
+				return nil
+			}
+
+			if len(details) == 0 {
+				fmt.Println("No clusters found.")
+				return nil
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Name", "Namespace", "Version", "Status", "Ready", "Endpoint"})
+			for _, detail := range details {
+				table.Append([]string{detail.Name, detail.Namespace, detail.Version, detail.Status, detail.Ready, detail.Endpoint})
+			}
+			table.Render()
+			return nil
 		}
 
 		// Check if there are no entries
 		if len(entries) == 0 {
 			fmt.Println("No clusters found.")
-			return
+			return nil
 		}
 
 		// Display the entries in a table format
@@ -76,47 +188,158 @@ var ListOssCmd = &cobra.Command{
 		}
 
 		table.Render()
+		return nil
 	},
 }
 
+func init() {
+	ListOssCmd.Flags().StringP("output", "o", "", "Output format (text|wide|json)")
+}
+
+// installationDetail is an installer entry enriched with live status from the cluster
+type installationDetail struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   string `json:"version"`
+	Status    string `json:"status"`
+	Ready     string `json:"ready"`
+	Endpoint  string `json:"endpoint"`
+}
+
+// fetchInstallationDetail queries the cluster for ready replica counts and the
+// service endpoint of an installation. Fields that cannot be determined are
+// reported as "unknown"
+func fetchInstallationDetail(entry common.InstallerEntry) installationDetail {
+	detail := installationDetail{
+		Name:      entry.Name,
+		Namespace: entry.Namespace,
+		Version:   entry.Version,
+		Status:    entry.Status,
+		Ready:     "unknown",
+		Endpoint:  "unknown",
+	}
+
+	config, err := common.LoadKubeConfig()
+	if err != nil {
+		return detail
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return detail
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	selector := metav1.ListOptions{LabelSelector: "app.kubernetes.io/instance=" + entry.Name}
+
+	if ready, desired, found, err := countReadyReplicas(ctx, clientset, entry); err == nil && found {
+		detail.Ready = fmt.Sprintf("%d/%d", ready, desired)
+	}
+
+	if services, err := clientset.CoreV1().Services(entry.Namespace).List(ctx, selector); err == nil {
+		detail.Endpoint = serviceEndpoint(services.Items)
+	}
+
+	return detail
+}
+
+// countReadyReplicas sums the ready and desired replicas of the deployments
+// and statefulsets of an installation. found is false when it has none
+func countReadyReplicas(ctx context.Context, clientset kubernetes.Interface, entry common.InstallerEntry) (ready, desired int32, found bool, err error) {
+	selector := metav1.ListOptions{LabelSelector: "app.kubernetes.io/instance=" + entry.Name}
+
+	deployments, err := clientset.AppsV1().Deployments(entry.Namespace).List(ctx, selector)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	for _, deployment := range deployments.Items {
+		found = true
+		ready += deployment.Status.ReadyReplicas
+		if deployment.Spec.Replicas != nil {
+			desired += *deployment.Spec.Replicas
+		}
+	}
+
+	statefulSets, err := clientset.AppsV1().StatefulSets(entry.Namespace).List(ctx, selector)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	for _, statefulSet := range statefulSets.Items {
+		found = true
+		ready += statefulSet.Status.ReadyReplicas
+		if statefulSet.Spec.Replicas != nil {
+			desired += *statefulSet.Spec.Replicas
+		}
+	}
+	return ready, desired, found, nil
+}
+
+// serviceEndpoint picks the most useful address for reaching an installation,
+// preferring an external load balancer over the in-cluster service name
+func serviceEndpoint(services []corev1.Service) string {
+	for _, service := range services {
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			host := ingress.Hostname
+			if host == "" {
+				host = ingress.IP
+			}
+			if host != "" && len(service.Spec.Ports) > 0 {
+				return fmt.Sprintf("http://%s:%d", host, service.Spec.Ports[0].Port)
+			}
+		}
+	}
+
+	// prefer the querier service as that is where the API and UI are served
+	for _, service := range services {
+		if strings.Contains(service.Name, "querier") {
+			return fmt.Sprintf("%s.%s.svc.cluster.local", service.Name, service.Namespace)
+		}
+	}
+	if len(services) > 0 {
+		return fmt.Sprintf("%s.%s.svc.cluster.local", services[0].Name, services[0].Namespace)
+	}
+	return "unknown"
+}
+
 // ShowValuesCmd lists the Parseable OSS servers
 var ShowValuesCmd = &cobra.Command{
 	Use:     "show values",
 	Short:   "Show values available in Parseable servers",
 	Example: "pb show values",
-	Run: func(_ *cobra.Command, _ []string) {
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cmd.SilenceUsage = true
 		_, err := common.PromptK8sContext()
 		if err != nil {
-			log.Fatalf("Failed to prompt for Kubernetes context: %v", err)
+			return fmt.Errorf("failed to prompt for Kubernetes context: %w", err)
 		}
 
 		// Read the installer data from the ConfigMap
 		entries, err := common.ReadInstallerConfigMap()
 		if err != nil {
-			log.Fatalf("Failed to list OSS servers: %v", err)
+			return fmt.Errorf("failed to list OSS servers: %w", err)
 		}
 
 		// Check if there are no entries
 		if len(entries) == 0 {
 			fmt.Println("No OSS servers found.")
-			return
+			return nil
 		}
 
 		// Prompt user to select a cluster
 		selectedCluster, err := common.PromptClusterSelection(entries)
 		if err != nil {
-			log.Fatalf("Failed to select a cluster: %v", err)
+			return fmt.Errorf("failed to select a cluster: %w", err)
 		}
 
 		values, err := helm.GetReleaseValues(selectedCluster.Name, selectedCluster.Namespace)
 		if err != nil {
-			log.Fatalf("Failed to get values for release: %v", err)
+			return fmt.Errorf("failed to get values for release: %w", err)
 		}
 
 		// Marshal values to YAML for nice formatting
 		yamlOutput, err := yaml.Marshal(values)
 		if err != nil {
-			log.Fatalf("Failed to marshal values to YAML: %v", err)
+			return fmt.Errorf("failed to marshal values to YAML: %w", err)
 		}
 
 		// Print the YAML output
@@ -125,6 +348,7 @@ var ShowValuesCmd = &cobra.Command{
 		// Print instructions for fetching secret values
 		fmt.Printf("\nTo get secret values of the Parseable cluster, run the following command:\n")
 		fmt.Printf("kubectl get secret -n %s parseable-env-secret -o jsonpath='{.data}' | jq -r 'to_entries[] | \"\\(.key): \\(.value | @base64d)\"'\n", selectedCluster.Namespace)
+		return nil
 	},
 }
 
@@ -132,29 +356,63 @@ var ShowValuesCmd = &cobra.Command{
 var UninstallOssCmd = &cobra.Command{
 	Use:     "uninstall",
 	Short:   "Uninstall Parseable servers",
-	Example: "pb uninstall",
-	Run: func(_ *cobra.Command, _ []string) {
+	Example: "pb uninstall\npb cluster uninstall --purge\npb cluster uninstall --name parseable --purge --yes",
+	Long: `Uninstall a Parseable server installed with pb cluster install.
+
+Data on object storage is never deleted. Persistent volumes, secrets and
+config maps created for the installation are left in place unless --purge is
+given. --purge also deletes them, together with the namespace when nothing
+else uses it. Everything that will be purged is listed first, and you are
+asked to type the installation name to confirm. Use --yes with --name to
+skip the prompts in scripts.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cmd.SilenceUsage = true
+		purge, _ := cmd.Flags().GetBool(purgeFlag)
+		name, _ := cmd.Flags().GetString(uninstallNameFlag)
+
 		_, err := common.PromptK8sContext()
 		if err != nil {
-			log.Fatalf("Failed to prompt for Kubernetes context: %v", err)
+			return fmt.Errorf("failed to prompt for Kubernetes context: %w", err)
 		}
 
 		// Read the installer data from the ConfigMap
 		entries, err := common.ReadInstallerConfigMap()
 		if err != nil {
-			log.Fatalf("Failed to fetch OSS servers: %v", err)
+			return fmt.Errorf("failed to fetch OSS servers: %w", err)
 		}
 
 		// Check if there are no entries
 		if len(entries) == 0 {
 			fmt.Println(common.Yellow + "\nNo Parseable OSS servers found to uninstall.")
-			return
+			return nil
 		}
 
 		// Prompt user to select a cluster
-		selectedCluster, err := common.PromptClusterSelection(entries)
+		var selectedCluster common.InstallerEntry
+		if name != "" {
+			selectedCluster, err = findInstallerEntry(entries, name)
+		} else {
+			selectedCluster, err = common.PromptClusterSelection(entries)
+		}
 		if err != nil {
-			log.Fatalf("Failed to select a cluster: %v", err)
+			return fmt.Errorf("failed to select a cluster: %w", err)
+		}
+
+		var plan purgePlan
+		var clientset kubernetes.Interface
+		if purge {
+			config, err := common.LoadKubeConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubeconfig: %w", err)
+			}
+			clientset, err = kubernetes.NewForConfig(config)
+			if err != nil {
+				return fmt.Errorf("failed to create Kubernetes client: %w", err)
+			}
+			plan, err = buildPurgePlan(context.Background(), clientset, selectedCluster, entries)
+			if err != nil {
+				return fmt.Errorf("failed to find resources to purge: %w", err)
+			}
 		}
 
 		// Display a warning banner
@@ -165,19 +423,36 @@ var UninstallOssCmd = &cobra.Command{
 
 		// Confirm uninstallation
 		fmt.Printf("\nYou have selected to uninstall the cluster '%s' in namespace '%s'.\n", selectedCluster.Name, selectedCluster.Namespace)
-		if !common.PromptConfirmation(fmt.Sprintf("Do you want to proceed with uninstalling '%s'?", selectedCluster.Name)) {
+		if purge {
+			fmt.Println()
+			printPurgePlan(os.Stdout, plan)
+			fmt.Println()
+		}
+		switch {
+		case assumeYes(cmd):
+			// confirmed on the command line
+		case purge:
+			confirmed, err := confirmDestructive(cmd, fmt.Sprintf("Purging %s and its data", selectedCluster.Name), selectedCluster.Name)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Println(common.Yellow + "Uninstall operation canceled.")
+				return nil
+			}
+		case !common.PromptConfirmation(fmt.Sprintf("Do you want to proceed with uninstalling '%s'?", selectedCluster.Name)):
 			fmt.Println(common.Yellow + "Uninstall operation canceled.")
-			return
+			return nil
 		}
 
 		//Perform uninstallation
 		if err := uninstallCluster(selectedCluster); err != nil {
-			log.Fatalf("Failed to uninstall cluster: %v", err)
+			return fmt.Errorf("failed to uninstall cluster: %w", err)
 		}
 
 		// Remove entry from ConfigMap
 		if err := common.RemoveInstallerEntry(selectedCluster.Name); err != nil {
-			log.Fatalf("Failed to remove entry from ConfigMap: %v", err)
+			return fmt.Errorf("failed to remove entry from ConfigMap: %w", err)
 		}
 
 		// Delete secret
@@ -187,7 +462,15 @@ var UninstallOssCmd = &cobra.Command{
 			fmt.Println(common.Green + "Secret 'parseable-env-secret' deleted successfully." + common.Reset)
 		}
 
+		if purge {
+			if err := executePurgePlan(context.Background(), clientset, plan); err != nil {
+				return fmt.Errorf("uninstalled, but %w", err)
+			}
+			fmt.Printf(common.Green+"Purged %d leftover resources."+common.Reset+"\n", len(plan.resources()))
+		}
+
 		fmt.Println(common.Green + "Uninstallation completed successfully." + common.Reset)
+		return nil
 	},
 }
 
@@ -0,0 +1,235 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"pb/pkg/common"
+
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	purgeFlag         = "purge"
+	uninstallNameFlag = "name"
+
+	// protectedNamespaces are never deleted by --purge. pb-system holds the
+	// installer ConfigMap shared by all installations
+	protectedNamespaces = map[string]bool{
+		"default":         true,
+		"kube-system":     true,
+		"kube-public":     true,
+		"kube-node-lease": true,
+		"pb-system":       true,
+	}
+
+	// envSecretName is the secret the installer creates next to the release
+	envSecretName = "parseable-env-secret"
+)
+
+func init() {
+	UninstallOssCmd.Flags().Bool(purgeFlag, false, "Also delete the persistent volumes, secrets, config maps and namespace of the installation")
+	UninstallOssCmd.Flags().String(uninstallNameFlag, "", "Name of the installation to uninstall, instead of choosing it from a list")
+}
+
+// purgePlan lists everything pb cluster uninstall --purge deletes on top of
+// the helm release
+type purgePlan struct {
+	Namespace       string
+	PVCs            []string
+	PVs             []string
+	Secrets         []string
+	ConfigMaps      []string
+	DeleteNamespace bool
+}
+
+// buildPurgePlan finds the volumes, secrets and config maps an installation
+// leaves behind. The namespace is only included when no other installation
+// uses it and nothing else runs in it, so a shared namespace survives
+func buildPurgePlan(ctx context.Context, clientset kubernetes.Interface, entry common.InstallerEntry, entries []common.InstallerEntry) (purgePlan, error) {
+	plan := purgePlan{Namespace: entry.Namespace}
+	instance := metav1.ListOptions{LabelSelector: "app.kubernetes.io/instance=" + entry.Name}
+
+	deleteNamespace, err := namespaceOnlyHolds(ctx, clientset, entry, entries)
+	if err != nil {
+		return plan, err
+	}
+	plan.DeleteNamespace = deleteNamespace
+
+	// deleting the namespace takes every claim in it along
+	claimSelector := instance
+	if plan.DeleteNamespace {
+		claimSelector = metav1.ListOptions{}
+	}
+	claims, err := clientset.CoreV1().PersistentVolumeClaims(entry.Namespace).List(ctx, claimSelector)
+	if err != nil {
+		return plan, fmt.Errorf("failed to list persistent volume claims: %w", err)
+	}
+	for _, claim := range claims.Items {
+		plan.PVCs = append(plan.PVCs, claim.Name)
+		if claim.Spec.VolumeName != "" {
+			plan.PVs = append(plan.PVs, claim.Spec.VolumeName)
+		}
+	}
+
+	secrets, err := clientset.CoreV1().Secrets(entry.Namespace).List(ctx, instance)
+	if err != nil {
+		return plan, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for _, secret := range secrets.Items {
+		plan.Secrets = append(plan.Secrets, secret.Name)
+	}
+	if _, err := clientset.CoreV1().Secrets(entry.Namespace).Get(ctx, envSecretName, metav1.GetOptions{}); err == nil {
+		plan.Secrets = appendUnique(plan.Secrets, envSecretName)
+	}
+
+	configMaps, err := clientset.CoreV1().ConfigMaps(entry.Namespace).List(ctx, instance)
+	if err != nil {
+		return plan, fmt.Errorf("failed to list config maps: %w", err)
+	}
+	for _, configMap := range configMaps.Items {
+		plan.ConfigMaps = append(plan.ConfigMaps, configMap.Name)
+	}
+
+	for _, names := range [][]string{plan.PVCs, plan.PVs, plan.Secrets, plan.ConfigMaps} {
+		sort.Strings(names)
+	}
+	return plan, nil
+}
+
+// namespaceOnlyHolds reports whether the namespace of entry can be deleted:
+// it is not a system namespace, no other installation lives in it, and every
+// workload in it belongs to entry
+func namespaceOnlyHolds(ctx context.Context, clientset kubernetes.Interface, entry common.InstallerEntry, entries []common.InstallerEntry) (bool, error) {
+	if protectedNamespaces[entry.Namespace] {
+		return false, nil
+	}
+	for _, other := range entries {
+		if other.Name != entry.Name && other.Namespace == entry.Namespace {
+			return false, nil
+		}
+	}
+
+	pods, err := clientset.CoreV1().Pods(entry.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Labels["app.kubernetes.io/instance"] != entry.Name {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, existing := range names {
+		if existing == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// resources lists the plan as kind/name lines in the order they are deleted
+func (p purgePlan) resources() []string {
+	var lines []string
+	for _, name := range p.Secrets {
+		lines = append(lines, fmt.Sprintf("secret %s/%s", p.Namespace, name))
+	}
+	for _, name := range p.ConfigMaps {
+		lines = append(lines, fmt.Sprintf("configmap %s/%s", p.Namespace, name))
+	}
+	for _, name := range p.PVCs {
+		lines = append(lines, fmt.Sprintf("persistentvolumeclaim %s/%s", p.Namespace, name))
+	}
+	for _, name := range p.PVs {
+		lines = append(lines, fmt.Sprintf("persistentvolume %s", name))
+	}
+	if p.DeleteNamespace {
+		lines = append(lines, fmt.Sprintf("namespace %s", p.Namespace))
+	}
+	return lines
+}
+
+// printPurgePlan shows what --purge deletes before anything is touched
+func printPurgePlan(w io.Writer, plan purgePlan) {
+	resources := plan.resources()
+	if len(resources) == 0 {
+		fmt.Fprintln(w, "No leftover volumes, secrets, config maps or namespaces found to purge.")
+		return
+	}
+	fmt.Fprintln(w, common.Red+"--purge permanently deletes the following, including all data on the volumes:"+common.Reset)
+	for _, resource := range resources {
+		fmt.Fprintf(w, "  %s\n", resource)
+	}
+	if !plan.DeleteNamespace && !protectedNamespaces[plan.Namespace] {
+		fmt.Fprintf(w, "Namespace %s is kept because other workloads or installations use it.\n", plan.Namespace)
+	}
+}
+
+// executePurgePlan deletes the resources of the plan after the release is
+// uninstalled. Resources that are already gone are skipped, and every other
+// failure is reported at the end so one stuck resource does not stop the rest
+func executePurgePlan(ctx context.Context, clientset kubernetes.Interface, plan purgePlan) error {
+	var failures []string
+	record := func(kind, name string, err error) {
+		if err != nil && !apiErrors.IsNotFound(err) {
+			failures = append(failures, fmt.Sprintf("%s %s: %v", kind, name, err))
+		}
+	}
+
+	core := clientset.CoreV1()
+	for _, name := range plan.Secrets {
+		record("secret", name, core.Secrets(plan.Namespace).Delete(ctx, name, metav1.DeleteOptions{}))
+	}
+	for _, name := range plan.ConfigMaps {
+		record("configmap", name, core.ConfigMaps(plan.Namespace).Delete(ctx, name, metav1.DeleteOptions{}))
+	}
+	for _, name := range plan.PVCs {
+		record("persistentvolumeclaim", name, core.PersistentVolumeClaims(plan.Namespace).Delete(ctx, name, metav1.DeleteOptions{}))
+	}
+	// volumes with a Retain policy outlive their claims, so delete them too
+	for _, name := range plan.PVs {
+		record("persistentvolume", name, core.PersistentVolumes().Delete(ctx, name, metav1.DeleteOptions{}))
+	}
+	if plan.DeleteNamespace {
+		record("namespace", plan.Namespace, core.Namespaces().Delete(ctx, plan.Namespace, metav1.DeleteOptions{}))
+	}
+
+	if len(failures) > 0 {
+		return errors.New("failed to purge " + strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// findInstallerEntry returns the installation named name
+func findInstallerEntry(entries []common.InstallerEntry, name string) (common.InstallerEntry, error) {
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry, nil
+		}
+	}
+	return common.InstallerEntry{}, fmt.Errorf("no Parseable installation named %q", name)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"pb/pkg/common"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func purgeFixtures(extra ...runtime.Object) *fake.Clientset {
+	labels := map[string]string{"app.kubernetes.io/instance": "parseable"}
+	objects := []runtime.Object{
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "parseable-ingestor-0", Namespace: "pb", Labels: labels}},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-parseable-ingestor-0", Namespace: "pb", Labels: labels},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "pvc-1234"},
+		},
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pvc-1234"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: envSecretName, Namespace: "pb"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parseable-config", Namespace: "pb", Labels: labels}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: "pb"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pb"}},
+	}
+	return fake.NewSimpleClientset(append(objects, extra...)...)
+}
+
+func TestPurgePlanListsLeftovers(t *testing.T) {
+	clientset := purgeFixtures()
+	entry := common.InstallerEntry{Name: "parseable", Namespace: "pb"}
+
+	plan, err := buildPurgePlan(context.Background(), clientset, entry, []common.InstallerEntry{entry})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"secret pb/parseable-env-secret",
+		"configmap pb/parseable-config",
+		"persistentvolumeclaim pb/data-parseable-ingestor-0",
+		"persistentvolume pvc-1234",
+		"namespace pb",
+	}
+	if !reflect.DeepEqual(plan.resources(), expected) {
+		t.Errorf("expected %v, got %v", expected, plan.resources())
+	}
+
+	if err := executePurgePlan(context.Background(), clientset, plan); err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumes().Get(context.Background(), "pvc-1234", metav1.GetOptions{}); err == nil {
+		t.Error("expected the persistent volume to be deleted")
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), "pb", metav1.GetOptions{}); err == nil {
+		t.Error("expected the namespace to be deleted")
+	}
+
+	// purging again finds everything gone and does not fail
+	if err := executePurgePlan(context.Background(), clientset, plan); err != nil {
+		t.Errorf("expected a second purge to skip deleted resources, got %v", err)
+	}
+}
+
+func TestPurgePlanKeepsSharedNamespace(t *testing.T) {
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "postgres-0", Namespace: "pb"}}
+	clientset := purgeFixtures(other)
+	entry := common.InstallerEntry{Name: "parseable", Namespace: "pb"}
+
+	plan, err := buildPurgePlan(context.Background(), clientset, entry, []common.InstallerEntry{entry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.DeleteNamespace {
+		t.Error("expected a namespace with other workloads to be kept")
+	}
+
+	second := common.InstallerEntry{Name: "parseable-2", Namespace: "pb"}
+	plan, err = buildPurgePlan(context.Background(), purgeFixtures(), entry, []common.InstallerEntry{entry, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.DeleteNamespace {
+		t.Error("expected a namespace shared with another installation to be kept")
+	}
+	if len(plan.PVCs) != 1 {
+		t.Errorf("expected only the claims of the installation, got %v", plan.PVCs)
+	}
+}
+
+func TestPurgePlanNeverDeletesSystemNamespace(t *testing.T) {
+	entry := common.InstallerEntry{Name: "parseable", Namespace: "default"}
+	plan, err := buildPurgePlan(context.Background(), fake.NewSimpleClientset(), entry, []common.InstallerEntry{entry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.DeleteNamespace {
+		t.Error("expected the default namespace to be kept")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"pb/pkg/common"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCountReadyReplicas(t *testing.T) {
+	replicas := func(n int32) *int32 { return &n }
+	labels := map[string]string{"app.kubernetes.io/instance": "parseable"}
+
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "parseable-querier", Namespace: "pb", Labels: labels},
+			Spec:       appsv1.DeploymentSpec{Replicas: replicas(1)},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "parseable-ingestor", Namespace: "pb", Labels: labels},
+			Spec:       appsv1.StatefulSetSpec{Replicas: replicas(3)},
+			Status:     appsv1.StatefulSetStatus{ReadyReplicas: 2},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "pb"},
+			Spec:       appsv1.DeploymentSpec{Replicas: replicas(5)},
+		},
+	)
+
+	ready, desired, found, err := countReadyReplicas(context.Background(), clientset, common.InstallerEntry{Name: "parseable", Namespace: "pb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || ready != 3 || desired != 4 {
+		t.Errorf("expected 3/4 ready, got %d/%d (found %v)", ready, desired, found)
+	}
+
+	_, _, found, _ = countReadyReplicas(context.Background(), clientset, common.InstallerEntry{Name: "missing", Namespace: "pb"})
+	if found {
+		t.Error("expected no workloads for an unknown installation")
+	}
+}
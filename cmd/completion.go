@@ -0,0 +1,139 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	// completionCacheTTL is how long names fetched for shell completion are
+	// reused before the server is asked again
+	completionCacheTTL = 30 * time.Second
+
+	// completionTimeout keeps a slow server from blocking the shell
+	completionTimeout = 3 * time.Second
+)
+
+func init() {
+	SetUserRoleCmd.ValidArgsFunction = completeUserThenRoles
+	RemoveUserCmd.ValidArgsFunction = completeFirstArg(userNames)
+	ResetUserPasswordCmd.ValidArgsFunction = completeFirstArg(userNames)
+	RemoveRoleCmd.ValidArgsFunction = completeFirstArg(roleNames)
+	RenameRoleCmd.ValidArgsFunction = completeFirstArg(roleNames)
+	AddUserCmd.RegisterFlagCompletionFunc(roleFlag, func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeList(roleNames(), toComplete)
+	})
+}
+
+// completeFirstArg completes the first argument of a command with names
+func completeFirstArg(names func() []string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return names(), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeUserThenRoles completes pb user set-role user-name roles
+func completeUserThenRoles(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return userNames(), cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		return completeList(roleNames(), toComplete)
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeList completes the last entry of a comma separated list, leaving
+// out names already in the list
+func completeList(names []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	if idx := strings.LastIndex(toComplete, ","); idx >= 0 {
+		prefix = toComplete[:idx+1]
+	}
+	chosen := strings.Split(prefix, ",")
+
+	var completions []string
+	for _, name := range names {
+		if !slices.Contains(chosen, name) {
+			completions = append(completions, prefix+name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+func userNames() []string {
+	return completionNames("users", func(client *internalHTTP.HTTPClient) ([]string, error) {
+		users, err := fetchUsers(client)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(users))
+		for idx, user := range users {
+			names[idx] = user.ID
+		}
+		return names, nil
+	})
+}
+
+func roleNames() []string {
+	return completionNames("roles", func(client *internalHTTP.HTTPClient) ([]string, error) {
+		var names []string
+		err := fetchRoles(client, &names)
+		return names, err
+	})
+}
+
+// completionNames returns the names of kind on the default profile's server.
+// Names fetched within completionCacheTTL are reused. When the server cannot
+// be asked, older cached names are used, and failures return no names so
+// that completion never prints errors into the shell
+func completionNames(kind string, fetch func(*internalHTTP.HTTPClient) ([]string, error)) []string {
+	if err := PreRun(); err != nil {
+		return nil
+	}
+
+	cache := config.ReadCompletionCache()
+	entry, cached := cache.Get(DefaultProfile.URL, kind)
+	if cached && time.Since(entry.FetchedAt) < completionCacheTTL {
+		return entry.Names
+	}
+	if internalHTTP.CheckOnline() != nil {
+		return entry.Names
+	}
+
+	// a retry notice would end up in the shell, and its wait would block it
+	internalHTTP.MaxRetries = 0
+	client := internalHTTP.DefaultClient(&DefaultProfile)
+	client.Client.Timeout = completionTimeout
+	names, err := fetch(&client)
+	if err != nil {
+		return entry.Names
+	}
+	cache.Set(DefaultProfile.URL, kind, names, time.Now())
+	config.WriteCompletionCache(cache)
+	return names
+}
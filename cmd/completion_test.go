@@ -0,0 +1,77 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCompletionNamesAreCached(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	defer func(retries int) { internalHTTP.MaxRetries = retries }(internalHTTP.MaxRetries)
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`["admin","reader"]`))
+	}))
+	err := config.WriteConfigToFile(&config.Config{
+		Profiles:       map[string]config.Profile{"local": {URL: server.URL}},
+		DefaultProfile: "local",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if names := roleNames(); strings.Join(names, ",") != "admin,reader" {
+			t.Fatalf("expected role names, got %v", names)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected the second completion to use the cache, got %d requests", n)
+	}
+
+	// once the cache is stale and the server is gone, the old names are kept
+	server.Close()
+	defer func(ttl time.Duration) { completionCacheTTL = ttl }(completionCacheTTL)
+	completionCacheTTL = 0
+	if names := roleNames(); strings.Join(names, ",") != "admin,reader" {
+		t.Errorf("expected cached names when the server is unreachable, got %v", names)
+	}
+}
+
+func TestCompleteListSkipsChosenNames(t *testing.T) {
+	completions, directive := completeList([]string{"admin", "reader", "writer"}, "admin,re")
+	if strings.Join(completions, " ") != "admin,reader admin,writer" {
+		t.Errorf("unexpected completions %v", completions)
+	}
+	if directive&cobra.ShellCompDirectiveNoSpace == 0 {
+		t.Error("expected no space after a list entry, so more roles can follow")
+	}
+}
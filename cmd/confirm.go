@@ -0,0 +1,75 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	// AssumeYesFlag is the global flag that answers every confirmation
+	// prompt, assumeYesAlias is accepted as another name for it
+	AssumeYesFlag  = "yes"
+	assumeYesAlias = "assume-yes"
+
+	// AssumeYes is set by the global --yes flag
+	AssumeYes bool
+
+	// stdinIsTerminal reports whether pb can ask the user on stdin
+	stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+)
+
+// NormalizeAssumeYes maps --assume-yes to the global --yes
+func NormalizeAssumeYes(name string) string {
+	if name == assumeYesAlias {
+		return AssumeYesFlag
+	}
+	return name
+}
+
+// assumeYes reports whether confirmation prompts of cmd are answered by the
+// global --yes
+func assumeYes(cmd *cobra.Command) bool {
+	yes, _ := cmd.Flags().GetBool(AssumeYesFlag)
+	return AssumeYes || yes
+}
+
+// confirmDestructive asks the user to type token before an action that cannot
+// be undone. --yes skips the prompt. Without a terminal on stdin the action is
+// refused with an error, so scripts fail instead of waiting for input
+func confirmDestructive(cmd *cobra.Command, action, token string) (bool, error) {
+	if assumeYes(cmd) {
+		return true, nil
+	}
+	if !stdinIsTerminal() {
+		return false, fmt.Errorf("%s cannot be undone and stdin is not a terminal, pass --%s to confirm", action, AssumeYesFlag)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s cannot be undone. Type %s to confirm: ", action, token)
+	response, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return strings.TrimSpace(response) == token, nil
+}
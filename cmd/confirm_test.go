@@ -0,0 +1,144 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+func withTerminal(t *testing.T, terminal bool) {
+	previous := stdinIsTerminal
+	stdinIsTerminal = func() bool { return terminal }
+	t.Cleanup(func() { stdinIsTerminal = previous })
+}
+
+func withAssumeYes(t *testing.T) {
+	AssumeYes = true
+	t.Cleanup(func() { AssumeYes = false })
+}
+
+func promptCommand(input string) (*cobra.Command, *strings.Builder) {
+	var out strings.Builder
+	cmd := &cobra.Command{}
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&out)
+	return cmd, &out
+}
+
+func TestConfirmDestructiveOnTerminal(t *testing.T) {
+	withTerminal(t, true)
+
+	cmd, out := promptCommand("backend\n")
+	confirmed, err := confirmDestructive(cmd, "Deleting stream backend", "backend")
+	if err != nil || !confirmed {
+		t.Errorf("expected typing the name to confirm, got %v, %v", confirmed, err)
+	}
+	if !strings.Contains(out.String(), "Type backend to confirm") {
+		t.Errorf("expected a typed confirmation prompt, got %q", out.String())
+	}
+
+	cmd, _ = promptCommand("y\n")
+	if confirmed, err := confirmDestructive(cmd, "Deleting stream backend", "backend"); err != nil || confirmed {
+		t.Errorf("expected any other answer to abort, got %v, %v", confirmed, err)
+	}
+}
+
+func TestConfirmDestructiveRefusesWithoutTerminal(t *testing.T) {
+	withTerminal(t, false)
+
+	cmd, out := promptCommand("backend\n")
+	confirmed, err := confirmDestructive(cmd, "Deleting stream backend", "backend")
+	if err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("expected an error asking for --yes, got %v", err)
+	}
+	if confirmed || out.Len() > 0 {
+		t.Errorf("expected no prompt without a terminal, got %q", out.String())
+	}
+}
+
+func TestConfirmDestructiveAssumeYes(t *testing.T) {
+	withTerminal(t, false)
+	withAssumeYes(t)
+
+	cmd, out := promptCommand("")
+	if confirmed, err := confirmDestructive(cmd, "Deleting stream backend", "backend"); err != nil || !confirmed {
+		t.Errorf("expected --yes to confirm, got %v, %v", confirmed, err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("expected no prompt with --yes, got %q", out.String())
+	}
+}
+
+func TestAssumeYesAlias(t *testing.T) {
+	if NormalizeAssumeYes("assume-yes") != AssumeYesFlag || NormalizeAssumeYes("yes") != AssumeYesFlag {
+		t.Error("expected --assume-yes to be another name for --yes")
+	}
+	if NormalizeAssumeYes("force") != "force" {
+		t.Error("expected other flags to keep their names")
+	}
+}
+
+func TestRemoveRoleNeedsConfirmation(t *testing.T) {
+	deleted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted++
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	defer func(profile config.Profile) { DefaultProfile = profile }(DefaultProfile)
+	DefaultProfile = config.Profile{URL: server.URL}
+
+	RemoveRoleCmd.SilenceUsage = true
+	RemoveRoleCmd.SetArgs([]string{"ingestor"})
+	defer RemoveRoleCmd.SetArgs(nil)
+
+	withTerminal(t, false)
+	if err := RemoveRoleCmd.Execute(); err == nil {
+		t.Error("expected remove to fail without a terminal or --yes")
+	}
+	if deleted != 0 {
+		t.Fatal("expected nothing to be deleted without confirmation")
+	}
+
+	withAssumeYes(t)
+	if err := RemoveRoleCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected the role to be deleted with --yes, got %d requests", deleted)
+	}
+}
+
+func TestCommandsUseGlobalYes(t *testing.T) {
+	// a local --yes would shadow the global flag and its -y shorthand
+	for _, cmd := range []*cobra.Command{UninstallOssCmd, AddStreamCmd, RenameRoleCmd} {
+		if cmd.LocalNonPersistentFlags().Lookup(AssumeYesFlag) != nil {
+			t.Errorf("expected %s to use the global --%s", cmd.Name(), AssumeYesFlag)
+		}
+	}
+}
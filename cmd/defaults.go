@@ -0,0 +1,245 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// defaultableFlags lists the flags that can be given a default. They only
+// choose how pb talks to the server and how it shows results. Flags that
+// answer a prompt or override a check, such as --yes and --force, and flags
+// that carry credentials are left out, so that an environment variable or
+// a shared config file can never skip a confirmation or leak a secret into
+// help output
+var defaultableFlags = map[string]bool{
+	// every command
+	"max-retries":        true,
+	"slow-threshold":     true,
+	"offline":            true,
+	"no-http-cache":      true,
+	"trace":              true,
+	"min-tls-version":    true,
+	"strict-permissions": true,
+	outputFlag:           true,
+	noColorFlag:          true,
+
+	// pb query run
+	limitFlag:            true,
+	timezoneFlag:         true,
+	warnOverFlag:         true,
+	maxColWidthFlag:      true,
+	wrapFlag:             true,
+	prettyFlag:           true,
+	flattenSeparatorFlag: true,
+	cacheTTLFlag:         true,
+	noGuardFlag:          true,
+	verboseFlag:          true,
+	statsFlag:            true,
+
+	// pb tail
+	maxReconnectsFlag: true,
+}
+
+// FlagEnvName returns the environment variable that sets a flag, e.g.
+// PB_MAX_RETRIES for --max-retries
+func FlagEnvName(flag string) string {
+	return "PB_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// ApplyFlagDefaults seeds the flags of root and all its subcommands before
+// the command line is parsed, so flags given on the command line always win.
+// Values come from, in order of precedence:
+//
+//   - the PB_<FLAG> environment variable
+//   - a command section of the default profile's defaults
+//   - the top level of the default profile's defaults
+//   - a command section of the config defaults, e.g. [Defaults."query run"]
+//   - the top level of the config defaults, which applies to every command
+//     with a flag of that name
+//
+// Only the flags in defaultableFlags are seeded, the others keep their
+// built-in default. Config keys that match no flag, or a flag that cannot be
+// given a default, are reported on stderr so typos do not go unnoticed
+func ApplyFlagDefaults(root *cobra.Command, defaults, profileDefaults map[string]interface{}, getenv func(string) string) error {
+	layers := []*defaultsLayer{
+		newDefaultsLayer("profile default", profileDefaults),
+		newDefaultsLayer("config default", defaults),
+	}
+
+	refused := map[string]bool{}
+	var apply func(cmd *cobra.Command) error
+	apply = func(cmd *cobra.Command) error {
+		path := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), root.Name()), " ")
+		for _, layer := range layers {
+			if _, ok := layer.scoped[path]; ok {
+				layer.used[path] = true
+			}
+		}
+
+		var err error
+		visit := func(flag *pflag.Flag) {
+			if err != nil {
+				return
+			}
+			if !defaultableFlags[flag.Name] {
+				for _, layer := range layers {
+					if _, found := layer.lookup(path, flag.Name); found {
+						refused[flag.Name] = true
+					}
+				}
+				return
+			}
+			value := getenv(FlagEnvName(flag.Name))
+			ok := value != ""
+			// every layer is looked up, so a key shadowed by a higher layer
+			// is not reported as matching no flag
+			for _, layer := range layers {
+				if layerValue, found := layer.lookup(path, flag.Name); found && !ok {
+					value, ok = layerValue, true
+				}
+			}
+			if !ok {
+				return
+			}
+			if err = setFlagDefault(flag, value); err != nil {
+				err = fmt.Errorf("invalid default for --%s: %w", flag.Name, err)
+			}
+		}
+		cmd.PersistentFlags().VisitAll(visit)
+		cmd.Flags().VisitAll(visit)
+		if err != nil {
+			return err
+		}
+
+		for _, sub := range cmd.Commands() {
+			if err := apply(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := apply(root); err != nil {
+		return err
+	}
+
+	for _, name := range sortedKeys(refused) {
+		fmt.Fprintf(os.Stderr, "Warning: --%s cannot be given a default, the default in the config file is ignored\n", name)
+	}
+	for _, layer := range layers {
+		for _, name := range layer.unused() {
+			fmt.Fprintf(os.Stderr, "Warning: the %s %q does not match any flag and is ignored\n", layer.name, name)
+		}
+	}
+	return nil
+}
+
+// defaultsLayer holds one source of flag defaults, split into the top level
+// keys and the command sections
+type defaultsLayer struct {
+	name   string
+	global map[string]string
+	scoped map[string]map[string]string
+	// used records the keys that matched a flag, section keys as
+	// "command.flag"
+	used map[string]bool
+}
+
+func newDefaultsLayer(name string, defaults map[string]interface{}) *defaultsLayer {
+	layer := &defaultsLayer{name: name, global: map[string]string{}, scoped: map[string]map[string]string{}, used: map[string]bool{}}
+	for key, value := range defaults {
+		if section, ok := value.(map[string]interface{}); ok {
+			layer.scoped[key] = map[string]string{}
+			for name, value := range section {
+				layer.scoped[key][name] = defaultValue(value)
+			}
+			continue
+		}
+		layer.global[key] = defaultValue(value)
+	}
+	return layer
+}
+
+// lookup returns the default of a flag of the command at path, preferring
+// the command section over the top level
+func (l *defaultsLayer) lookup(path, flag string) (string, bool) {
+	// a top level key shadowed by a section still matches the flag
+	global, globalOK := l.global[flag]
+	if globalOK {
+		l.used[flag] = true
+	}
+	if value, ok := l.scoped[path][flag]; ok {
+		l.used[path+"."+flag] = true
+		return value, true
+	}
+	return global, globalOK
+}
+
+// unused returns the keys that matched no flag, sorted
+func (l *defaultsLayer) unused() []string {
+	var unknown []string
+	for name := range l.global {
+		if !l.used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	for path, section := range l.scoped {
+		for name := range section {
+			if !l.used[path+"."+name] {
+				unknown = append(unknown, fmt.Sprintf("%s.%s", path, name))
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// setFlagDefault sets the value of flag without marking it as changed, and
+// shows the new value as the default in help output
+func setFlagDefault(flag *pflag.Flag, value string) error {
+	if err := flag.Value.Set(value); err != nil {
+		return err
+	}
+	flag.DefValue = flag.Value.String()
+	return nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// defaultValue converts a TOML value to the string form flags parse
+func defaultValue(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, len(list))
+		for idx, item := range list {
+			items[idx] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newDefaultsTestCommand builds pb → query → run with an --output flag on
+// run and persistent --max-retries and --yes flags on the root
+func newDefaultsTestCommand() (root, run *cobra.Command) {
+	root = &cobra.Command{Use: "pb"}
+	root.PersistentFlags().Int("max-retries", 3, "")
+	root.PersistentFlags().BoolP("yes", "y", false, "")
+	group := &cobra.Command{Use: "query"}
+	run = &cobra.Command{Use: "run", Run: func(*cobra.Command, []string) {}}
+	run.Flags().StringP("output", "o", "", "")
+	run.Flags().Bool("no-color", false, "")
+	run.Flags().Bool("force", false, "")
+	run.Flags().String("password", "", "")
+	group.AddCommand(run)
+	root.AddCommand(group)
+	return root, run
+}
+
+func noEnv(string) string { return "" }
+
+func executeWithDefaults(t *testing.T, defaults map[string]interface{}, getenv func(string) string, args ...string) *cobra.Command {
+	root, run := newDefaultsTestCommand()
+	if err := ApplyFlagDefaults(root, defaults, nil, getenv); err != nil {
+		t.Fatal(err)
+	}
+	root.SetArgs(args)
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+func TestConfigDefaultOutputUsedWhenFlagOmitted(t *testing.T) {
+	run := executeWithDefaults(t, map[string]interface{}{"output": "json", "no-color": true}, noEnv, "query", "run")
+
+	if output, _ := run.Flags().GetString("output"); output != "json" {
+		t.Errorf("expected output from config default, got %q", output)
+	}
+	if noColor, _ := run.Flags().GetBool("no-color"); !noColor {
+		t.Error("expected boolean config default to be applied")
+	}
+	if run.Flags().Changed("output") {
+		t.Error("a config default must not mark the flag as set on the command line")
+	}
+}
+
+func TestCommandLineOverridesConfigDefault(t *testing.T) {
+	run := executeWithDefaults(t, map[string]interface{}{"output": "json"}, noEnv, "query", "run", "-o", "csv")
+
+	if output, _ := run.Flags().GetString("output"); output != "csv" {
+		t.Errorf("expected command line output to win, got %q", output)
+	}
+}
+
+func TestDefaultsNeverConfirmOrCarrySecrets(t *testing.T) {
+	defaults := map[string]interface{}{
+		"yes":       true,
+		"query run": map[string]interface{}{"force": true, "password": "secret"},
+	}
+	env := func(name string) string {
+		switch name {
+		case "PB_YES", "PB_FORCE":
+			return "true"
+		case "PB_PASSWORD":
+			return "secret"
+		}
+		return ""
+	}
+
+	run := executeWithDefaults(t, defaults, env, "query", "run")
+	if yes, _ := run.Flags().GetBool("yes"); yes {
+		t.Error("expected --yes to keep its built-in default")
+	}
+	if force, _ := run.Flags().GetBool("force"); force {
+		t.Error("expected --force to keep its built-in default")
+	}
+	if password, _ := run.Flags().GetString("password"); password != "" {
+		t.Errorf("expected --password to keep its built-in default, got %q", password)
+	}
+	if run.Flags().Lookup("password").DefValue != "" {
+		t.Error("expected the secret not to show up as a default in help output")
+	}
+}
+
+func TestDefaultsPrecedence(t *testing.T) {
+	defaults := map[string]interface{}{
+		"output":      "json",
+		"max-retries": int64(5),
+		"query run":   map[string]interface{}{"output": "table"},
+	}
+
+	run := executeWithDefaults(t, defaults, noEnv, "query", "run")
+	if output, _ := run.Flags().GetString("output"); output != "table" {
+		t.Errorf("expected command section to override the top level default, got %q", output)
+	}
+	if retries, _ := run.Flags().GetInt("max-retries"); retries != 5 {
+		t.Errorf("expected persistent flag default from config, got %d", retries)
+	}
+
+	env := func(name string) string {
+		if name == "PB_OUTPUT" {
+			return "ndjson"
+		}
+		return ""
+	}
+	run = executeWithDefaults(t, defaults, env, "query", "run")
+	if output, _ := run.Flags().GetString("output"); output != "ndjson" {
+		t.Errorf("expected environment to override config defaults, got %q", output)
+	}
+
+	run = executeWithDefaults(t, defaults, env, "query", "run", "--output=csv")
+	if output, _ := run.Flags().GetString("output"); output != "csv" {
+		t.Errorf("expected command line to override the environment, got %q", output)
+	}
+}
+
+func TestInvalidDefaultIsReported(t *testing.T) {
+	root, _ := newDefaultsTestCommand()
+	if err := ApplyFlagDefaults(root, map[string]interface{}{"max-retries": "many"}, nil, noEnv); err == nil {
+		t.Error("expected a default that does not parse to be rejected")
+	}
+}
+
+func TestProfileDefaultsPrecedence(t *testing.T) {
+	defaults := map[string]interface{}{
+		"output":      "json",
+		"max-retries": int64(5),
+		"query run":   map[string]interface{}{"output": "table"},
+	}
+	profile := map[string]interface{}{
+		"query run": map[string]interface{}{"output": "csv"},
+	}
+
+	root, run := newDefaultsTestCommand()
+	if err := ApplyFlagDefaults(root, defaults, profile, noEnv); err != nil {
+		t.Fatal(err)
+	}
+	root.SetArgs([]string{"query", "run"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := run.Flags().GetString("output"); output != "csv" {
+		t.Errorf("expected the profile default to override config defaults, got %q", output)
+	}
+	if retries, _ := run.Flags().GetInt("max-retries"); retries != 5 {
+		t.Errorf("expected config defaults the profile does not set to still apply, got %d", retries)
+	}
+
+	root, run = newDefaultsTestCommand()
+	if err := ApplyFlagDefaults(root, defaults, profile, noEnv); err != nil {
+		t.Fatal(err)
+	}
+	root.SetArgs([]string{"query", "run", "-o", "ndjson"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := run.Flags().GetString("output"); output != "ndjson" {
+		t.Errorf("expected the command line to override the profile default, got %q", output)
+	}
+
+	// without profile defaults the config defaults apply as before
+	run = executeWithDefaults(t, defaults, noEnv, "query", "run")
+	if output, _ := run.Flags().GetString("output"); output != "table" {
+		t.Errorf("expected the config default without profile defaults, got %q", output)
+	}
+}
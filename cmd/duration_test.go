@@ -0,0 +1,38 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDaysOrDuration(t *testing.T) {
+	if d, err := parseDaysOrDuration(inactiveForFlag, "90d"); err != nil || d != 90*24*time.Hour {
+		t.Errorf("expected 90 days, got %v %v", d, err)
+	}
+	if d, err := parseDaysOrDuration(inactiveForFlag, "36h"); err != nil || d != 36*time.Hour {
+		t.Errorf("expected 36h, got %v %v", d, err)
+	}
+	for _, value := range []string{"0d", "-5d", "soon", "1.5d", "-1h"} {
+		if _, err := parseDaysOrDuration(inactiveForFlag, value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		} else if !strings.Contains(err.Error(), "--inactive-for") {
+			t.Errorf("expected the error to name the flag, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	allProfilesFlag  = "all-profiles"
+	profileGroupFlag = "group"
+
+	// fanOutConcurrency bounds the number of profiles queried at once
+	fanOutConcurrency = 4
+)
+
+// addFanOutFlags registers the flags that run a read-only command against
+// several profiles instead of the default one
+func addFanOutFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(allProfilesFlag, false, "Run against every configured profile")
+	cmd.Flags().String(profileGroupFlag, "", "Run against every profile in this group")
+	cmd.MarkFlagsMutuallyExclusive(allProfilesFlag, profileGroupFlag)
+}
+
+// fanOutProfiles returns the profile names selected by --all-profiles or
+// --group. It returns nil when neither is set, so the command runs against
+// the default profile as usual
+func fanOutProfiles(cmd *cobra.Command) ([]string, *config.Config, error) {
+	all, _ := cmd.Flags().GetBool(allProfilesFlag)
+	group, _ := cmd.Flags().GetString(profileGroupFlag)
+	if !all && group == "" {
+		return nil, nil, nil
+	}
+	if group != "" {
+		if err := config.ValidateGroupName(group); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	conf, err := config.ReadConfigFromFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	names := conf.ProfilesInGroup(group)
+	if len(names) == 0 {
+		if group != "" {
+			return nil, nil, fmt.Errorf("no profiles in group %s, tag profiles with pb profile add --%s", group, profileGroupFlag)
+		}
+		return nil, nil, errors.New("no profiles configured, add one using pb profile add")
+	}
+	return names, conf, nil
+}
+
+// profileResult is the outcome of a fan-out command for one profile
+type profileResult struct {
+	Profile string      `json:"profile"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// runAcrossProfiles calls fn for each named profile concurrently and returns
+// the results in the order of names. A failing profile, including one whose
+// ${VAR} references cannot be expanded, is recorded in its result and does
+// not stop the others
+func runAcrossProfiles(names []string, conf *config.Config, fn func(client *internalHTTP.HTTPClient) (interface{}, error)) []profileResult {
+	results := make([]profileResult, len(names))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, fanOutConcurrency)
+	for idx, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[idx].Profile = name
+			profile, err := conf.Profile(name)
+			if err != nil {
+				results[idx].Error = err.Error()
+				return
+			}
+			if err := internalHTTP.ValidateTLSConfig(&profile); err != nil {
+				results[idx].Error = err.Error()
+				return
+			}
+			client := internalHTTP.DefaultClient(&profile)
+			result, err := fn(&client)
+			if err != nil {
+				results[idx].Error = err.Error()
+				return
+			}
+			results[idx].Result = result
+		}(idx, name)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestRunAcrossProfilesKeepsOrderAndErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`[{"name":"app"}]`))
+	}))
+	defer server.Close()
+
+	conf := &config.Config{Profiles: map[string]config.Profile{
+		"prod-eu": {URL: server.URL, Group: "prod"},
+		"prod-us": {URL: "http://127.0.0.1:1", Group: "prod"},
+	}}
+	names := []string{"prod-eu", "prod-us"}
+
+	results := runAcrossProfiles(names, conf, func(client *internalHTTP.HTTPClient) (interface{}, error) {
+		if client.Profile.URL != server.URL {
+			return nil, errors.New("unreachable")
+		}
+		return fetchStreams(client)
+	})
+
+	if len(results) != 2 || results[0].Profile != "prod-eu" || results[1].Profile != "prod-us" {
+		t.Fatalf("expected results in profile order, got %+v", results)
+	}
+	if results[0].Error != "" || len(results[0].Result.([]StreamListItem)) != 1 {
+		t.Errorf("expected streams for prod-eu, got %+v", results[0])
+	}
+	if results[1].Error == "" {
+		t.Error("expected the failing profile to report its error")
+	}
+	if err := printStreamListResults([]profileResult{results[1]}, "json"); err == nil {
+		t.Error("expected a failed profile to fail the command")
+	}
+}
@@ -20,13 +20,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"os"
+	"time"
 
 	"pb/pkg/common"
 	internalHTTP "pb/pkg/http"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 const (
@@ -36,62 +39,90 @@ const (
 var GenerateSchemaCmd = &cobra.Command{
 	Use:     "generate",
 	Short:   "Generate Schema for JSON",
-	Example: "pb schema generate --file=test.json",
+	Example: "pb schema generate --file=test.json\npb schema generate --merge --file='samples/*.json'\npb schema generate --file=data.json --output=schemas/app.yaml --format=yaml\npb schema generate --file=large.json --sample=random --sample-size=5000",
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		// Get the file path from the `--file` flag
-		filePath, err := cmd.Flags().GetString("file")
+		// Get the file paths from the `--file` flag
+		filePatterns, err := cmd.Flags().GetStringArray("file")
 		if err != nil {
 			return fmt.Errorf(common.Red+"failed to read file flag: %w"+common.Reset, err)
 		}
 
-		if filePath == "" {
+		if len(filePatterns) == 0 {
 			return fmt.Errorf(common.Red + "file flag is required" + common.Reset)
 		}
 
-		// Read the file content
-		fileContent, err := os.ReadFile(filePath)
+		filePaths, err := resolveSchemaFiles(filePatterns)
 		if err != nil {
-			return fmt.Errorf(common.Red+"failed to read file %s: %w"+common.Reset, filePath, err)
+			return fmt.Errorf(common.Red+"%w"+common.Reset, err)
 		}
 
-		// Initialize HTTP client
-		client := internalHTTP.DefaultClient(&DefaultProfile)
+		merge, _ := cmd.Flags().GetBool(mergeSchemaFlag)
+		if len(filePaths) > 1 && !merge {
+			return fmt.Errorf(common.Red+"%d files given, pass --%s to merge their schemas"+common.Reset, len(filePaths), mergeSchemaFlag)
+		}
 
-		// Create the HTTP request
-		req, err := client.NewRequest(http.MethodPost, generateStaticSchemaPath, bytes.NewBuffer(fileContent))
-		if err != nil {
-			return fmt.Errorf(common.Red+"failed to create new request: %w"+common.Reset, err)
+		outputPath, _ := cmd.Flags().GetString(schemaOutputFlag)
+		format, _ := cmd.Flags().GetString(schemaFormatFlag)
+		if err := validateSchemaFormat(format); err != nil {
+			return fmt.Errorf(common.Red+"%w"+common.Reset, err)
 		}
 
-		// Set Content-Type header
-		req.Header.Set("Content-Type", "application/json")
+		sample, _ := cmd.Flags().GetString(schemaSampleFlag)
+		sampleSize, _ := cmd.Flags().GetInt(schemaSampleSizeFlag)
+		if err := validateSchemaSample(sample, sampleSize); err != nil {
+			return fmt.Errorf(common.Red+"%w"+common.Reset, err)
+		}
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 
-		// Execute the request
-		resp, err := client.Client.Do(req)
-		if err != nil {
-			return fmt.Errorf(common.Red+"request execution failed: %w"+common.Reset, err)
+		// Initialize HTTP client
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+
+		schemas := make([][]byte, len(filePaths))
+		for idx, filePath := range filePaths {
+			// Read the file content, or the sampled records
+			fileContent, err := readSchemaSample(filePath, sample, sampleSize, rng)
+			if err != nil {
+				return fmt.Errorf(common.Red+"%w"+common.Reset, err)
+			}
+
+			schemas[idx], err = detectSchema(&client, fileContent)
+			if err != nil {
+				return err
+			}
 		}
-		defer resp.Body.Close()
 
-		// Check for non-200 status codes
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			fmt.Printf(common.Red+"Error response: %s\n"+common.Reset, string(body))
-			return fmt.Errorf(common.Red+"non-200 status code received: %s"+common.Reset, resp.Status)
+		respBody := schemas[0]
+		if len(schemas) > 1 {
+			var warnings []string
+			respBody, warnings, err = mergeSchemas(schemas, filePaths)
+			if err != nil {
+				return fmt.Errorf(common.Red+"%w"+common.Reset, err)
+			}
+			for _, warning := range warnings {
+				fmt.Fprintln(os.Stderr, common.Yellow+"Warning: "+warning+common.Reset)
+			}
 		}
 
-		// Parse and print the response
-		respBody, err := io.ReadAll(resp.Body)
+		encoded, err := encodeSchema(respBody, format)
 		if err != nil {
-			return fmt.Errorf(common.Red+"failed to read response body: %w"+common.Reset, err)
+			return fmt.Errorf(common.Red+"%w"+common.Reset, err)
 		}
 
-		var prettyJSON bytes.Buffer
-		if err := json.Indent(&prettyJSON, respBody, "", "  "); err != nil {
-			return fmt.Errorf(common.Red+"failed to format response as JSON: %w"+common.Reset, err)
+		if outputPath != "" {
+			if err := writeSchemaFile(outputPath, encoded); err != nil {
+				return fmt.Errorf(common.Red+"%w"+common.Reset, err)
+			}
+			fmt.Fprintf(os.Stderr, "Schema written to %s\n", outputPath)
+			return nil
 		}
 
-		fmt.Println(common.Green + prettyJSON.String() + common.Reset)
+		// only color the schema for a terminal, so it can be piped into
+		// pb stream add --schema-file -
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			fmt.Print(common.Green + string(encoded) + common.Reset)
+		} else {
+			fmt.Print(string(encoded))
+		}
 		return nil
 	},
 }
@@ -99,7 +130,7 @@ var GenerateSchemaCmd = &cobra.Command{
 var CreateSchemaCmd = &cobra.Command{
 	Use:     "create",
 	Short:   "Create Schema for a Parseable stream",
-	Example: "pb schema create --stream=my_stream --file=schema.json",
+	Example: "pb schema create --stream=my_stream --file=schema.json\npb schema create --stream=my_stream --from-data=sample.json --dry-run\npb schema generate --file=data.json | pb schema create --stream=my_stream --file=-",
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		// Get the stream name from the `--stream` flag
 		streamName, err := cmd.Flags().GetString("stream")
@@ -117,19 +148,55 @@ var CreateSchemaCmd = &cobra.Command{
 			return fmt.Errorf(common.Red+"failed to read config flag: %w"+common.Reset, err)
 		}
 
-		if filePath == "" {
-			return fmt.Errorf(common.Red + "file path flag is required" + common.Reset)
+		// Get the sample data path from the `--from-data` flag
+		dataPath, err := cmd.Flags().GetString("from-data")
+		if err != nil {
+			return fmt.Errorf(common.Red+"failed to read from-data flag: %w"+common.Reset, err)
 		}
 
-		// Read the JSON schema file
-		schemaContent, err := os.ReadFile(filePath)
-		if err != nil {
-			return fmt.Errorf(common.Red+"failed to read schema file %s: %w"+common.Reset, filePath, err)
+		if filePath == "" && dataPath == "" {
+			return fmt.Errorf(common.Red + "either file or from-data flag is required" + common.Reset)
+		}
+
+		if filePath != "" && dataPath != "" {
+			return fmt.Errorf(common.Red + "file and from-data flags cannot be used together" + common.Reset)
 		}
 
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
 		// Initialize HTTP client
 		client := internalHTTP.DefaultClient(&DefaultProfile)
 
+		var schemaContent []byte
+		if dataPath != "" {
+			// Infer the schema from sample data, same as `pb schema generate`
+			dataContent, err := os.ReadFile(dataPath)
+			if err != nil {
+				return fmt.Errorf(common.Red+"failed to read data file %s: %w"+common.Reset, dataPath, err)
+			}
+
+			schemaContent, err = detectSchema(&client, dataContent)
+			if err != nil {
+				return err
+			}
+		} else {
+			// Read the JSON schema file, or stdin for -
+			schemaContent, err = readSchemaFile(filePath, cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf(common.Red+"%w"+common.Reset, err)
+			}
+		}
+
+		if dryRun {
+			var prettyJSON bytes.Buffer
+			if err := json.Indent(&prettyJSON, schemaContent, "", "  "); err != nil {
+				return fmt.Errorf(common.Red+"failed to format schema as JSON: %w"+common.Reset, err)
+			}
+			fmt.Printf("Schema that would be applied to stream %s:\n", streamName)
+			fmt.Println(common.Green + prettyJSON.String() + common.Reset)
+			return nil
+		}
+
 		// Construct the API path
 		apiPath := fmt.Sprintf("/logstream/%s", streamName)
 
@@ -170,7 +237,46 @@ var CreateSchemaCmd = &cobra.Command{
 
 func init() {
 	// Add the `--file` flag to the command
-	GenerateSchemaCmd.Flags().StringP("file", "f", "", "Path to the JSON file to generate schema")
+	GenerateSchemaCmd.Flags().StringArrayP("file", "f", nil, "Path or glob of the JSON file(s) to generate schema, repeat for several files")
+	GenerateSchemaCmd.Flags().Bool(mergeSchemaFlag, false, "Merge the schemas of several files into one")
+	GenerateSchemaCmd.Flags().String(schemaOutputFlag, "", "Write the schema to this file instead of stdout, creating missing directories")
+	GenerateSchemaCmd.Flags().String(schemaFormatFlag, defaultSchemaFormat, "Schema format (json|yaml)")
+	GenerateSchemaCmd.Flags().String(schemaSampleFlag, defaultSchemaSample, "Records to infer the schema from (head|random|all). head takes the first --sample-size records, random a uniform sample across the file")
+	GenerateSchemaCmd.Flags().Int(schemaSampleSizeFlag, defaultSchemaSampleSize, "Number of records to sample with --sample head or random")
 	CreateSchemaCmd.Flags().StringP("stream", "s", "", "Name of the stream to associate with the schema")
-	CreateSchemaCmd.Flags().StringP("file", "f", "", "Path to the JSON file to create schema")
+	CreateSchemaCmd.Flags().StringP("file", "f", "", "Path to the JSON file to create schema, - reads it from stdin")
+	CreateSchemaCmd.Flags().String("from-data", "", "Path to a JSON data file to infer the schema from")
+	CreateSchemaCmd.Flags().Bool("dry-run", false, "Print the schema that would be applied without creating the stream")
+}
+
+// detectSchema sends sample data to the server and returns the inferred schema
+func detectSchema(client *internalHTTP.HTTPClient, data []byte) ([]byte, error) {
+	// Create the HTTP request
+	req, err := client.NewRequest(http.MethodPost, generateStaticSchemaPath, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf(common.Red+"failed to create new request: %w"+common.Reset, err)
+	}
+
+	// Set Content-Type header
+	req.Header.Set("Content-Type", "application/json")
+
+	// Execute the request
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf(common.Red+"request execution failed: %w"+common.Reset, err)
+	}
+	defer resp.Body.Close()
+
+	// Check for non-200 status codes
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Printf(common.Red+"Error response: %s\n"+common.Reset, string(body))
+		return nil, fmt.Errorf(common.Red+"non-200 status code received: %s"+common.Reset, resp.Status)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf(common.Red+"failed to read response body: %w"+common.Reset, err)
+	}
+	return respBody, nil
 }
@@ -0,0 +1,148 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var mergeSchemaFlag = "merge"
+
+// resolveSchemaFiles expands glob patterns in the --file values. A value
+// without glob characters is kept as is so a missing file is reported when
+// it is read
+func resolveSchemaFiles(values []string) ([]string, error) {
+	var files []string
+	for _, value := range values {
+		if !strings.ContainsAny(value, "*?[") {
+			files = append(files, value)
+			continue
+		}
+		matches, err := filepath.Glob(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", value, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", value)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// mergedField tracks one field across the merged schemas
+type mergedField struct {
+	field    map[string]json.RawMessage
+	typeName string
+	seen     int
+}
+
+// mergeSchemas combines the schemas detected for several files into one.
+// Fields keep the order in which they first appear. A field missing from
+// some files is marked nullable, and conflicting types are widened where no
+// value is lost (integer and float become float). Other conflicts keep the
+// first type seen and are returned as warnings
+func mergeSchemas(schemas [][]byte, files []string) ([]byte, []string, error) {
+	var merged map[string]json.RawMessage
+	var order []string
+	fields := make(map[string]*mergedField)
+	var warnings []string
+
+	for idx, data := range schemas {
+		var schema map[string]json.RawMessage
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse schema detected for %s: %w", files[idx], err)
+		}
+		var schemaFields []map[string]json.RawMessage
+		if err := json.Unmarshal(schema["fields"], &schemaFields); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse fields detected for %s: %w", files[idx], err)
+		}
+		if merged == nil {
+			merged = schema
+		}
+
+		for _, field := range schemaFields {
+			var name string
+			json.Unmarshal(field["name"], &name)
+			typeName := dataTypeName(field["data_type"])
+
+			existing, ok := fields[name]
+			if !ok {
+				fields[name] = &mergedField{field: field, typeName: typeName, seen: 1}
+				order = append(order, name)
+				continue
+			}
+			existing.seen++
+			if strings.EqualFold(existing.typeName, typeName) {
+				continue
+			}
+			if widened, ok := widenType(existing.typeName, typeName); ok {
+				if widened == typeName {
+					existing.field["data_type"] = field["data_type"]
+					existing.typeName = typeName
+				}
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("field %s is %s in %s but %s in an earlier file, keeping %s", name, typeName, files[idx], existing.typeName, existing.typeName))
+		}
+	}
+
+	result := make([]map[string]json.RawMessage, 0, len(order))
+	for _, name := range order {
+		field := fields[name]
+		if field.seen < len(schemas) {
+			field.field["nullable"] = json.RawMessage("true")
+		}
+		result = append(result, field.field)
+	}
+
+	encodedFields, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+	if merged == nil {
+		merged = map[string]json.RawMessage{}
+	}
+	merged["fields"] = encodedFields
+
+	out, err := json.Marshal(merged)
+	return out, warnings, err
+}
+
+// widenType returns the type that holds values of both a and b without loss,
+// which is the float type when one is an integer and the other a float
+func widenType(a, b string) (string, bool) {
+	switch {
+	case isIntegerType(a) && isFloatType(b):
+		return b, true
+	case isFloatType(a) && isIntegerType(b):
+		return a, true
+	}
+	return "", false
+}
+
+func isIntegerType(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "int") || strings.HasPrefix(name, "uint")
+}
+
+func isFloatType(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "float") || name == "double"
+}
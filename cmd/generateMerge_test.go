@@ -0,0 +1,111 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	appSchema    = `{"fields":[{"name":"host","data_type":"Utf8","nullable":false},{"name":"latency","data_type":"Int64","nullable":false}],"metadata":{}}`
+	apiSchema    = `{"fields":[{"name":"host","data_type":"Utf8","nullable":false},{"name":"latency","data_type":"Float64","nullable":false},{"name":"route","data_type":"Utf8","nullable":false}],"metadata":{}}`
+	workerSchema = `{"fields":[{"name":"host","data_type":"Int64","nullable":false},{"name":"latency","data_type":"Int64","nullable":false}],"metadata":{}}`
+)
+
+func TestMergeSchemasPartialOverlap(t *testing.T) {
+	merged, warnings, err := mergeSchemas(
+		[][]byte{[]byte(appSchema), []byte(apiSchema), []byte(workerSchema)},
+		[]string{"app.json", "api.json", "worker.json"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := parseStreamSchema(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 {
+		t.Fatalf("expected the union of fields, got %v", fields)
+	}
+	if fields["latency"].Type != "Float64" {
+		t.Errorf("expected latency to widen to Float64, got %s", fields["latency"].Type)
+	}
+	if !fields["route"].Nullable {
+		t.Error("expected route, missing from two files, to be nullable")
+	}
+	if fields["host"].Nullable || fields["latency"].Nullable {
+		t.Error("expected fields present in every file to keep their nullability")
+	}
+
+	if fields["host"].Type != "Utf8" {
+		t.Errorf("expected host to keep the first type seen, got %s", fields["host"].Type)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "host") || !strings.Contains(warnings[0], "worker.json") {
+		t.Errorf("expected one warning about host in worker.json, got %v", warnings)
+	}
+
+	var schema struct {
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	json.Unmarshal(merged, &schema)
+	if schema.Fields[0].Name != "host" || schema.Fields[2].Name != "route" {
+		t.Errorf("expected fields in order of first appearance, got %+v", schema.Fields)
+	}
+}
+
+func TestResolveSchemaFilesExpandsGlobs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.json", "b.json", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := resolveSchemaFiles([]string{filepath.Join(dir, "*.json"), "extra.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 || filepath.Base(files[0]) != "a.json" || files[2] != "extra.json" {
+		t.Errorf("unexpected files %v", files)
+	}
+
+	if _, err := resolveSchemaFiles([]string{filepath.Join(dir, "*.csv")}); err == nil {
+		t.Error("expected a pattern without matches to be rejected")
+	}
+}
+
+func TestSchemaFileFlagKeepsCommas(t *testing.T) {
+	flag := GenerateSchemaCmd.Flags().Lookup("file")
+	defer func() {
+		flag.Value.(interface{ Replace([]string) error }).Replace(nil)
+		flag.Changed = false
+	}()
+
+	if err := GenerateSchemaCmd.Flags().Parse([]string{"--file=logs,2024.json", "-f", "app.json"}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := GenerateSchemaCmd.Flags().GetStringArray("file")
+	if strings.Join(files, "|") != "logs,2024.json|app.json" {
+		t.Errorf("expected a path with a comma to stay whole, got %q", files)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	schemaOutputFlag    = "output"
+	schemaFormatFlag    = "format"
+	defaultSchemaFormat = "json"
+)
+
+// validateSchemaFormat checks the --format value of pb schema generate
+func validateSchemaFormat(format string) error {
+	if format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported schema format %q, use json or yaml", format)
+	}
+	return nil
+}
+
+// encodeSchema serializes a schema detected by the server as indented JSON or
+// as YAML. Fields keep the order the server returned them in
+func encodeSchema(schema []byte, format string) ([]byte, error) {
+	if format == "yaml" {
+		// JSON is valid YAML, decoding into a node keeps the key order
+		var node yaml.Node
+		if err := yaml.Unmarshal(schema, &node); err != nil {
+			return nil, fmt.Errorf("failed to parse schema: %w", err)
+		}
+		blockStyle(&node)
+		var out bytes.Buffer
+		encoder := yaml.NewEncoder(&out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return nil, fmt.Errorf("failed to format schema as YAML: %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, schema, "", "  "); err != nil {
+		return nil, fmt.Errorf("failed to format response as JSON: %w", err)
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// blockStyle drops the flow style and quoting carried over from JSON so the
+// node is written as regular block YAML. Strings that would read as another
+// type are still quoted by the encoder
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// writeSchemaFile writes a generated schema to path, creating missing parent
+// directories. The file is replaced in one step so an existing schema is never
+// left half written
+func writeSchemaFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	file, err := createAtomic(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.discard()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.commit()
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+var detectedSchema = []byte(`{"fields":[{"name":"status","data_type":"Int64","nullable":true,"dict_id":0},{"name":"flag","data_type":"Utf8","nullable":false,"metadata":{"example":"true"}}],"metadata":{}}`)
+
+func TestSchemaFileParsesBack(t *testing.T) {
+	var want map[string]interface{}
+	if err := json.Unmarshal(detectedSchema, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		encoded, err := encodeSchema(detectedSchema, format)
+		if err != nil {
+			t.Fatalf("%s: encode failed: %v", format, err)
+		}
+		path := filepath.Join(t.TempDir(), "schemas", "nested", "schema."+format)
+		if err := writeSchemaFile(path, encoded); err != nil {
+			t.Fatalf("%s: write failed: %v", format, err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parsed interface{}
+		if format == "yaml" {
+			err = yaml.Unmarshal(data, &parsed)
+		} else {
+			err = json.Unmarshal(data, &parsed)
+		}
+		if err != nil {
+			t.Fatalf("%s: written schema does not parse: %v\n%s", format, err, data)
+		}
+
+		// normalize YAML integers to JSON numbers before comparing
+		normalized, _ := json.Marshal(parsed)
+		var got map[string]interface{}
+		json.Unmarshal(normalized, &got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: schema changed on the way to disk\nwant %v\ngot  %v", format, want, got)
+		}
+	}
+}
+
+func TestYAMLSchemaKeepsFieldOrder(t *testing.T) {
+	encoded, err := encodeSchema(detectedSchema, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(encoded)
+	if strings.Index(out, "name: status") > strings.Index(out, "name: flag") {
+		t.Errorf("expected fields in server order, got:\n%s", out)
+	}
+	if !strings.Contains(out, "  - name: status") {
+		t.Errorf("expected block style YAML, got:\n%s", out)
+	}
+}
+
+func TestSchemaFormatValidation(t *testing.T) {
+	if err := validateSchemaFormat("toml"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"unicode"
+)
+
+var (
+	schemaSampleFlag        = "sample"
+	schemaSampleSizeFlag    = "sample-size"
+	defaultSchemaSample     = "all"
+	defaultSchemaSampleSize = 1000
+)
+
+// validateSchemaSample checks the --sample and --sample-size values of pb
+// schema generate
+func validateSchemaSample(strategy string, size int) error {
+	switch strategy {
+	case "all":
+		return nil
+	case "head", "random":
+		if size < 1 {
+			return fmt.Errorf("--%s must be at least 1", schemaSampleSizeFlag)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported sample strategy %q, use head, random or all", strategy)
+}
+
+// readSchemaSample returns the records of path to infer a schema from. all
+// sends the file unchanged, head and random send a JSON array of at most size
+// records
+func readSchemaSample(path, strategy string, size int, rng *rand.Rand) ([]byte, error) {
+	if strategy == "all" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		return data, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	records, err := sampleRecords(file, strategy, size, rng)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read records from %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s contains no records", path)
+	}
+	return json.Marshal(records)
+}
+
+// sampleRecords reads the records of a JSON array, a single JSON object or
+// newline delimited JSON from r in one pass. head keeps the first size
+// records and stops reading, random keeps a uniform sample of size records
+// across the whole input using reservoir sampling, so at most size records
+// are held in memory. Sampled records keep their order in the input
+func sampleRecords(r io.Reader, strategy string, size int, rng *rand.Rand) ([]json.RawMessage, error) {
+	type sampled struct {
+		index  int
+		record json.RawMessage
+	}
+	var reservoir []sampled
+
+	seen := 0
+	err := forEachRecord(r, func(record json.RawMessage) bool {
+		switch {
+		case len(reservoir) < size:
+			reservoir = append(reservoir, sampled{seen, record})
+		case strategy == "head":
+			return false
+		default:
+			// replace a kept record with probability size/(seen+1)
+			if slot := rng.Intn(seen + 1); slot < size {
+				reservoir[slot] = sampled{seen, record}
+			}
+		}
+		seen++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(reservoir, func(i, j int) bool { return reservoir[i].index < reservoir[j].index })
+	records := make([]json.RawMessage, len(reservoir))
+	for idx, kept := range reservoir {
+		records[idx] = kept.record
+	}
+	return records, nil
+}
+
+// forEachRecord decodes records from r one at a time and passes each to fn
+// until fn returns false. The elements of a top level array are records,
+// otherwise every top level value is one
+func forEachRecord(r io.Reader, fn func(json.RawMessage) bool) error {
+	reader := bufio.NewReader(r)
+	array := false
+	for {
+		b, err := reader.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !unicode.IsSpace(rune(b)) {
+			array = b == '['
+			reader.UnreadByte()
+			break
+		}
+	}
+
+	decoder := json.NewDecoder(reader)
+	if array {
+		// read the elements one by one instead of the whole array
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+	}
+	for !array || decoder.More() {
+		var record json.RawMessage
+		if err := decoder.Decode(&record); err != nil {
+			if !array && errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("unterminated JSON array: %w", err)
+	}
+	return nil
+}
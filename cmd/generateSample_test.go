@@ -0,0 +1,142 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// driftFixture writes 1000 records whose shape changes halfway through: the
+// later records have a string status and an extra region field
+func driftFixture(t *testing.T) (string, []byte) {
+	var records []string
+	for id := 0; id < 1000; id++ {
+		if id < 500 {
+			records = append(records, fmt.Sprintf(`{"id":%d,"status":200}`, id))
+		} else {
+			records = append(records, fmt.Sprintf(`{"id":%d,"status":"ok","region":"eu"}`, id))
+		}
+	}
+	data := []byte("[\n" + strings.Join(records, ",\n") + "\n]\n")
+	path := filepath.Join(t.TempDir(), "drift.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func sampledIDs(t *testing.T, data []byte) []int {
+	var records []struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("sample is not a JSON array: %v", err)
+	}
+	ids := make([]int, len(records))
+	for idx, record := range records {
+		ids[idx] = record.ID
+	}
+	return ids
+}
+
+func TestSchemaSampleHead(t *testing.T) {
+	path, _ := driftFixture(t)
+	data, err := readSchemaSample(path, "head", 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(sampledIDs(t, data)); got != "[0 1 2 3 4 5 6 7 8 9]" {
+		t.Errorf("expected the first 10 records, got %s", got)
+	}
+	if strings.Contains(string(data), "region") {
+		t.Error("expected head to miss the drifted records")
+	}
+}
+
+func TestSchemaSampleRandomCoversFile(t *testing.T) {
+	path, _ := driftFixture(t)
+	data, err := readSchemaSample(path, "random", 50, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := sampledIDs(t, data)
+	if len(ids) != 50 {
+		t.Fatalf("expected 50 records, got %d", len(ids))
+	}
+	before, after := 0, 0
+	for idx, id := range ids {
+		if idx > 0 && id <= ids[idx-1] {
+			t.Fatalf("expected distinct records in file order, got %v", ids)
+		}
+		if id < 500 {
+			before++
+		} else {
+			after++
+		}
+	}
+	// with a uniform sample, both shapes are well represented
+	if before < 10 || after < 10 {
+		t.Errorf("expected records from both halves of the file, got %d and %d", before, after)
+	}
+}
+
+func TestSchemaSampleAllSendsFile(t *testing.T) {
+	path, fixture := driftFixture(t)
+	data, err := readSchemaSample(path, "all", 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(fixture) {
+		t.Error("expected all to send the file unchanged")
+	}
+}
+
+func TestSampleRecordsStopsAfterHead(t *testing.T) {
+	// the input is broken after the third record, head never reads that far
+	records, err := sampleRecords(strings.NewReader(`[{"id":0},{"id":1},{"id":2},{"id":`), "head", 2, nil)
+	if err != nil || len(records) != 2 {
+		t.Errorf("expected 2 records without reading the rest, got %d, %v", len(records), err)
+	}
+}
+
+func TestSampleRecordsNDJSON(t *testing.T) {
+	input := "{\"id\":0}\n{\"id\":1}\n{\"id\":2}\n"
+	records, err := sampleRecords(strings.NewReader(input), "random", 5, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || string(records[2]) != `{"id":2}` {
+		t.Errorf("expected all 3 records when fewer than the sample size, got %s", records)
+	}
+}
+
+func TestValidateSchemaSample(t *testing.T) {
+	if err := validateSchemaSample("first", 10); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+	if err := validateSchemaSample("random", 0); err == nil {
+		t.Error("expected a zero sample size to be rejected")
+	}
+	if err := validateSchemaSample("all", 0); err != nil {
+		t.Errorf("expected all to ignore the sample size, got %v", err)
+	}
+}
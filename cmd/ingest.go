@@ -0,0 +1,391 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ingestFileFlag      = "file"
+	ingestBatchSizeFlag = "batch-size"
+	validateFlag        = "validate"
+	skipInvalidFlag     = "skip-invalid"
+	deadLetterFileFlag  = "dead-letter-file"
+
+	defaultIngestBatchSize = 500
+
+	// maxReportedInvalid limits how many invalid records are listed on stderr
+	maxReportedInvalid = 10
+)
+
+// invalidRecord is a line of input that cannot be ingested
+type invalidRecord struct {
+	line   int
+	raw    string
+	reason error
+}
+
+var IngestCmd = &cobra.Command{
+	Use:     "ingest stream-name",
+	Example: "  pb ingest backend --file=events.jsonl\n  pb ingest backend --file=events.jsonl --validate --skip-invalid --dead-letter-file=rejected.jsonl\n  pb ingest backend --file=events.jsonl --compress=gzip",
+	Short:   "Send JSON lines events to a log stream",
+	Long: `Send events to a log stream. Events are read as JSON lines, one JSON
+object per line, from --file or stdin, and sent in batches.
+
+With --validate, each event is checked against the schema of the stream
+before anything is sent. Fields missing from the schema are allowed, as
+they extend the schema of the stream. If any event is invalid, nothing is
+sent, unless --skip-invalid is set to send only the valid events.
+
+With --compress gzip, each batch is sent gzip compressed with
+Content-Encoding: gzip, which saves bandwidth on slow links. pb only
+compresses when the server advertises gzip support in an Accept-Encoding
+header, and sends plain batches otherwise. --force-compress compresses
+anyway. A server that does not decompress request bodies may then reject
+the events or store them unreadable; if it answers 415 Unsupported Media
+Type, pb resends the batch uncompressed.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: PreRunDefaultProfile,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		name := args[0]
+		filePath, _ := cmd.Flags().GetString(ingestFileFlag)
+		batchSize, _ := cmd.Flags().GetInt(ingestBatchSizeFlag)
+		validate, _ := cmd.Flags().GetBool(validateFlag)
+		skipInvalid, _ := cmd.Flags().GetBool(skipInvalidFlag)
+		deadLetterFile, _ := cmd.Flags().GetString(deadLetterFileFlag)
+		compress, _ := cmd.Flags().GetString(compressFlag)
+		forceCompress, _ := cmd.Flags().GetBool(forceCompressFlag)
+
+		if deadLetterFile != "" && !skipInvalid {
+			err := fmt.Errorf("--%s requires --%s", deadLetterFileFlag, skipInvalidFlag)
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateCompression(compress, forceCompress); err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		if batchSize <= 0 {
+			err := fmt.Errorf("--%s must be positive", ingestBatchSizeFlag)
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
+		var input io.Reader = os.Stdin
+		if filePath != "" && filePath != "-" {
+			file, err := os.Open(filePath)
+			if err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
+			defer file.Close()
+			input = file
+		}
+
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+
+		var schema map[string]schemaField
+		if validate {
+			var err error
+			if schema, err = fetchStreamSchema(&client, name); err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return fmt.Errorf("failed to fetch schema of stream %s: %w", name, err)
+			}
+		}
+
+		report := &invalidReport{deadLetterPath: deadLetterFile}
+		if !skipInvalid {
+			// every record is checked before the first batch is sent, so
+			// that an invalid record means nothing is sent
+			replay, cleanup, err := checkIngestInput(input, schema, report)
+			defer cleanup()
+			if err == nil && report.count > 0 {
+				report.finish()
+				fmt.Fprintf(os.Stderr, "%d invalid records\n", report.count)
+				err = fmt.Errorf("found %d invalid records, nothing was sent. Use --%s to send the valid records only", report.count, skipInvalidFlag)
+			}
+			if err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
+			input = replay
+		}
+
+		sender := &ingestSender{client: &client, name: name, size: batchSize, compress: compress == "gzip", forceCompress: forceCompress}
+		valid := 0
+		err := scanIngestRecords(input, schema, func(record json.RawMessage) error {
+			valid++
+			return sender.add(record)
+		}, report.add)
+		if err == nil {
+			err = sender.flush()
+		}
+		if finishErr := report.finish(); err == nil {
+			err = finishErr
+		}
+
+		if validate || report.count > 0 {
+			fmt.Fprintf(os.Stderr, "%d valid, %d invalid records\n", valid, report.count)
+		}
+		if report.deadLetters != nil {
+			fmt.Fprintf(os.Stderr, "Wrote %d rejected records to %s\n", report.count, deadLetterFile)
+		}
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
+		fmt.Printf("Ingested %d records into stream %s\n", sender.sent, name)
+		return nil
+	},
+}
+
+func init() {
+	IngestCmd.Flags().StringP(ingestFileFlag, "f", "", "JSON lines file to read events from, stdin if not set")
+	IngestCmd.Flags().Int(ingestBatchSizeFlag, defaultIngestBatchSize, "Number of events sent per request")
+	IngestCmd.Flags().Bool(validateFlag, false, "Check events against the stream schema before sending")
+	IngestCmd.Flags().Bool(skipInvalidFlag, false, "Send the valid events and skip the invalid ones instead of sending nothing")
+	IngestCmd.Flags().String(deadLetterFileFlag, "", "Write skipped events to this file as JSON lines (with --skip-invalid)")
+	IngestCmd.Flags().String(compressFlag, "none", "Compress each batch before sending it (gzip|none), if the server advertises support")
+	IngestCmd.Flags().Bool(forceCompressFlag, false, "Compress with --compress even when the server does not advertise support. The server may reject or garble the events")
+}
+
+// scanIngestRecords reads JSON lines from r one at a time and passes each
+// to valid, or to invalid when it is not a JSON object or does not match
+// schema when schema is not nil
+func scanIngestRecords(r io.Reader, schema map[string]schemaField, valid func(json.RawMessage) error, invalid func(invalidRecord) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			if err := invalid(invalidRecord{line, raw, errors.New("not a JSON object")}); err != nil {
+				return err
+			}
+			continue
+		}
+		if schema != nil {
+			if reason := validateRecord(record, schema); reason != nil {
+				if err := invalid(invalidRecord{line, raw, reason}); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := valid(json.RawMessage(raw)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// checkIngestInput reads all of input and reports its invalid records, then
+// returns a reader that replays input from where it started. Input that
+// cannot seek, such as a pipe on stdin, is copied to a temporary file while
+// it is read, so that it is never held in memory
+func checkIngestInput(input io.Reader, schema map[string]schemaField, report *invalidReport) (io.Reader, func(), error) {
+	cleanup := func() {}
+
+	replay, canSeek := input.(io.ReadSeeker)
+	var start int64
+	if canSeek {
+		var err error
+		if start, err = replay.Seek(0, io.SeekCurrent); err != nil {
+			canSeek = false
+		}
+	}
+	source := input
+	if !canSeek {
+		spool, err := os.CreateTemp("", "pb-ingest-*.jsonl")
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("failed to create temporary file for the input: %w", err)
+		}
+		cleanup = func() {
+			spool.Close()
+			os.Remove(spool.Name())
+		}
+		replay, start = spool, 0
+		source = io.TeeReader(input, spool)
+	}
+
+	noop := func(json.RawMessage) error { return nil }
+	if err := scanIngestRecords(source, schema, noop, report.add); err != nil {
+		return nil, cleanup, err
+	}
+	if _, err := replay.Seek(start, io.SeekStart); err != nil {
+		return nil, cleanup, fmt.Errorf("failed to read the input again: %w", err)
+	}
+	return replay, cleanup, nil
+}
+
+// invalidReport lists the first invalid records on stderr and writes all of
+// them to the dead letter file, when one is set
+type invalidReport struct {
+	count          int
+	deadLetterPath string
+	deadLetters    *os.File
+}
+
+func (r *invalidReport) add(record invalidRecord) error {
+	if r.count < maxReportedInvalid {
+		fmt.Fprintf(os.Stderr, "  line %d: %s\n", record.line, record.reason)
+	}
+	r.count++
+
+	if r.deadLetterPath == "" {
+		return nil
+	}
+	if r.deadLetters == nil {
+		file, err := os.Create(r.deadLetterPath)
+		if err != nil {
+			return fmt.Errorf("failed to write dead letter file: %w", err)
+		}
+		r.deadLetters = file
+	}
+	if _, err := fmt.Fprintln(r.deadLetters, record.raw); err != nil {
+		return fmt.Errorf("failed to write dead letter file: %w", err)
+	}
+	return nil
+}
+
+// finish notes the invalid records that were not listed and closes the dead
+// letter file
+func (r *invalidReport) finish() error {
+	if r.count > maxReportedInvalid {
+		fmt.Fprintf(os.Stderr, "  ... and %d more\n", r.count-maxReportedInvalid)
+	}
+	if r.deadLetters != nil {
+		if err := r.deadLetters.Close(); err != nil {
+			return fmt.Errorf("failed to write dead letter file: %w", err)
+		}
+	}
+	return nil
+}
+
+// ingestSender sends records to a stream in batches of size records. Whether
+// batches are compressed is decided when the first batch is sent
+type ingestSender struct {
+	client        *internalHTTP.HTTPClient
+	name          string
+	size          int
+	compress      bool
+	forceCompress bool
+
+	decided bool
+	gzip    bool
+	batch   []json.RawMessage
+	sent    int
+}
+
+func (s *ingestSender) add(record json.RawMessage) error {
+	s.batch = append(s.batch, record)
+	if len(s.batch) < s.size {
+		return nil
+	}
+	return s.flush()
+}
+
+// flush sends the records that are not sent yet
+func (s *ingestSender) flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	if !s.decided {
+		s.decided = true
+		s.gzip = s.compress && (s.forceCompress || serverAcceptsGzip(s.client))
+		if s.compress && !s.gzip {
+			fmt.Fprintf(os.Stderr, "Warning: the server does not advertise gzip request bodies, sending uncompressed. Use --%s to compress anyway.\n", forceCompressFlag)
+		}
+	}
+
+	err := ingestBatch(s.client, s.name, s.batch, s.gzip)
+	if errors.Is(err, errCompressionRejected) {
+		fmt.Fprintln(os.Stderr, "Warning: the server rejected a gzip batch, sending uncompressed.")
+		s.gzip = false
+		err = ingestBatch(s.client, s.name, s.batch, false)
+	}
+	if err != nil {
+		return fmt.Errorf("ingested %d records, then the next batch failed: %w", s.sent, err)
+	}
+	s.sent += len(s.batch)
+	s.batch = s.batch[:0]
+	return nil
+}
+
+func ingestBatch(client *internalHTTP.HTTPClient, name string, records []json.RawMessage, compress bool) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(body)
+	if compress {
+		if buf, err = gzipBody(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := client.NewRequest(http.MethodPost, "logstream/"+name, buf)
+	if err != nil {
+		return err
+	}
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if compress && resp.StatusCode == http.StatusUnsupportedMediaType {
+		return errCompressionRejected
+	}
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Request Failed\nStatus Code: %s\nResponse: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
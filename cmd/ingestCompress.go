@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	internalHTTP "pb/pkg/http"
+)
+
+var (
+	compressFlag      = "compress"
+	forceCompressFlag = "force-compress"
+
+	// errCompressionRejected is returned when the server answers a gzip
+	// batch with 415 Unsupported Media Type
+	errCompressionRejected = errors.New("server does not accept gzip request bodies")
+)
+
+// validateCompression checks the --compress value
+func validateCompression(compress string, force bool) error {
+	switch compress {
+	case "", "none":
+		if force {
+			return fmt.Errorf("--%s requires --%s gzip", forceCompressFlag, compressFlag)
+		}
+	case "gzip":
+	default:
+		return fmt.Errorf("unsupported compression %q, use gzip or none", compress)
+	}
+	return nil
+}
+
+// serverAcceptsGzip reports whether the server advertises gzip request
+// bodies with an Accept-Encoding response header, as described in RFC 7694
+func serverAcceptsGzip(client *internalHTTP.HTTPClient) bool {
+	req, err := client.NewRequest(http.MethodGet, "about", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	for _, value := range resp.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			coding, _, _ = strings.Cut(coding, ";")
+			if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gzipBody compresses one complete batch. Each batch is its own gzip
+// stream, so the server sees a whole JSON array per request
+func gzipBody(body []byte) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
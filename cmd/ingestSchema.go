@@ -0,0 +1,166 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+)
+
+// schemaField is a field of a stream schema, with the arrow data type
+// reduced to its name, e.g. Utf8, Int64 or Timestamp
+type schemaField struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// fetchStreamSchema returns the fields of the stream schema by name
+func fetchStreamSchema(client *internalHTTP.HTTPClient, name string) (map[string]schemaField, error) {
+	req, err := client.NewRequest(http.MethodGet, fmt.Sprintf("logstream/%s/schema", name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Request Failed\nStatus Code: %s\nResponse: %s", resp.Status, strings.TrimSpace(string(bytes)))
+	}
+	return parseStreamSchema(bytes)
+}
+
+func parseStreamSchema(data []byte) (map[string]schemaField, error) {
+	var schema struct {
+		Fields []struct {
+			Name     string          `json:"name"`
+			DataType json.RawMessage `json:"data_type"`
+			Nullable bool            `json:"nullable"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	fields := make(map[string]schemaField, len(schema.Fields))
+	for _, field := range schema.Fields {
+		fields[field.Name] = schemaField{Name: field.Name, Type: dataTypeName(field.DataType), Nullable: field.Nullable}
+	}
+	return fields, nil
+}
+
+// dataTypeName reduces an arrow data type as serialized by the server, either
+// a plain name such as "Utf8" or an object such as {"Timestamp": [...]}, to
+// its name
+func dataTypeName(dataType json.RawMessage) string {
+	var name string
+	if err := json.Unmarshal(dataType, &name); err == nil {
+		return name
+	}
+	var complex map[string]json.RawMessage
+	if err := json.Unmarshal(dataType, &complex); err == nil {
+		for key := range complex {
+			return key
+		}
+	}
+	return ""
+}
+
+// validateRecord checks that every value in record fits the type of its
+// schema field and that no non nullable field is missing. Fields that are not
+// in the schema are accepted
+func validateRecord(record map[string]interface{}, schema map[string]schemaField) error {
+	var problems []string
+	for key, value := range record {
+		field, ok := schema[key]
+		if !ok {
+			continue
+		}
+		if err := checkFieldValue(field, value); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for name, field := range schema {
+		if _, ok := record[name]; !ok && !field.Nullable && !strings.HasPrefix(name, "p_") {
+			problems = append(problems, fmt.Sprintf("missing required field %s", name))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func checkFieldValue(field schemaField, value interface{}) error {
+	if value == nil {
+		if field.Nullable {
+			return nil
+		}
+		return fmt.Errorf("field %s cannot be null", field.Name)
+	}
+
+	var ok bool
+	switch {
+	case field.Type == "Utf8" || field.Type == "LargeUtf8":
+		_, ok = value.(string)
+	case field.Type == "Boolean":
+		_, ok = value.(bool)
+	case strings.HasPrefix(field.Type, "Int") || strings.HasPrefix(field.Type, "UInt"):
+		var n float64
+		n, ok = value.(float64)
+		ok = ok && n == math.Trunc(n) && (n >= 0 || strings.HasPrefix(field.Type, "Int"))
+	case strings.HasPrefix(field.Type, "Float"):
+		_, ok = value.(float64)
+	case field.Type == "Timestamp" || strings.HasPrefix(field.Type, "Date"):
+		var s string
+		if s, ok = value.(string); ok {
+			if _, ok = parseEventTime(s); !ok && strings.HasPrefix(field.Type, "Date") {
+				_, err := time.Parse(time.DateOnly, s)
+				ok = err == nil
+			}
+		}
+	case field.Type == "List" || field.Type == "LargeList":
+		_, ok = value.([]interface{})
+	default:
+		// types pb does not know about are left to the server
+		ok = true
+	}
+
+	if !ok {
+		encoded, _ := json.Marshal(value)
+		return fmt.Errorf("field %s expects %s, got %s", field.Name, field.Type, encoded)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+const testSchema = `{"fields":[
+	{"name":"host","data_type":"Utf8","nullable":true},
+	{"name":"status","data_type":"Int64","nullable":true},
+	{"name":"latency","data_type":"Float64","nullable":true},
+	{"name":"ok","data_type":"Boolean","nullable":true},
+	{"name":"at","data_type":{"Timestamp":["Millisecond",null]},"nullable":false},
+	{"name":"p_timestamp","data_type":{"Timestamp":["Millisecond",null]},"nullable":false}
+]}`
+
+// collectIngestRecords returns the valid and invalid records of r
+func collectIngestRecords(r io.Reader, schema map[string]schemaField) (records []json.RawMessage, invalid []invalidRecord, err error) {
+	err = scanIngestRecords(r, schema, func(record json.RawMessage) error {
+		records = append(records, record)
+		return nil
+	}, func(record invalidRecord) error {
+		invalid = append(invalid, record)
+		return nil
+	})
+	return records, invalid, err
+}
+
+func TestReadIngestRecordsValidates(t *testing.T) {
+	schema, err := parseStreamSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		`{"host":"a","status":200,"latency":1.5,"ok":true,"at":"2024-05-01T10:00:00Z"}`,
+		`{"host":"b","status":"200","at":"2024-05-01T10:00:00Z"}`,
+		`{"host":"c","status":2.5,"at":"2024-05-01T10:00:00Z"}`,
+		`{"host":"d","at":"yesterday"}`,
+		`{"host":"e"}`,
+		`not json`,
+		``,
+		`{"host":"f","extra":"field","at":"2024-05-01T10:00:00Z"}`,
+	}, "\n")
+
+	records, invalid, err := collectIngestRecords(strings.NewReader(input), schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Errorf("expected 2 valid records, got %d", len(records))
+	}
+
+	wantLines := []int{2, 3, 4, 5, 6}
+	if len(invalid) != len(wantLines) {
+		t.Fatalf("expected %d invalid records, got %d: %v", len(wantLines), len(invalid), invalid)
+	}
+	for idx, record := range invalid {
+		if record.line != wantLines[idx] {
+			t.Errorf("expected invalid record on line %d, got line %d", wantLines[idx], record.line)
+		}
+	}
+	if !strings.Contains(invalid[3].reason.Error(), "missing required field at") {
+		t.Errorf("expected missing field error, got %v", invalid[3].reason)
+	}
+}
+
+func TestReadIngestRecordsWithoutSchema(t *testing.T) {
+	input := "{\"status\":\"200\"}\n[1,2]\n"
+
+	records, invalid, err := collectIngestRecords(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(invalid) != 1 {
+		t.Errorf("expected only the non object line to be invalid, got %d valid and %d invalid", len(records), len(invalid))
+	}
+}
+
+// gzipIngestServer accepts gzip batches when acceptGzip is set, and answers
+// them with 415 otherwise
+type gzipIngestServer struct {
+	acceptGzip bool
+	encodings  []string
+	records    int
+}
+
+func (s *gzipIngestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v1/about" {
+		if s.acceptGzip {
+			w.Header().Set("Accept-Encoding", "gzip")
+		}
+		w.Write([]byte(`{}`))
+		return
+	}
+
+	encoding := r.Header.Get("Content-Encoding")
+	s.encodings = append(s.encodings, encoding)
+	var body io.Reader = r.Body
+	if encoding == "gzip" {
+		if !s.acceptGzip {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = reader
+	}
+
+	var batch []map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.records += len(batch)
+}
+
+func TestIngestBatchSendsGzip(t *testing.T) {
+	state := &gzipIngestServer{acceptGzip: true}
+	server := httptest.NewServer(state)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	if !serverAcceptsGzip(&client) {
+		t.Fatal("expected the advertised gzip support to be detected")
+	}
+
+	records, _, _ := collectIngestRecords(strings.NewReader("{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n"), nil)
+	for _, batch := range [][]json.RawMessage{records[:2], records[2:]} {
+		if err := ingestBatch(&client, "app", batch, true); err != nil {
+			t.Fatalf("ingest failed: %v", err)
+		}
+	}
+
+	if state.records != 3 {
+		t.Errorf("expected the server to decode 3 records from the gzip batches, got %d", state.records)
+	}
+	if strings.Join(state.encodings, ",") != "gzip,gzip" {
+		t.Errorf("expected both batches to be gzip encoded, got %v", state.encodings)
+	}
+}
+
+func TestIngestBatchReportsRejectedGzip(t *testing.T) {
+	state := &gzipIngestServer{}
+	server := httptest.NewServer(state)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	if serverAcceptsGzip(&client) {
+		t.Error("expected a server without Accept-Encoding not to be used for gzip")
+	}
+
+	records := []json.RawMessage{json.RawMessage(`{"a":1}`)}
+	if err := ingestBatch(&client, "app", records, true); err != errCompressionRejected {
+		t.Errorf("expected a 415 to be reported as rejected compression, got %v", err)
+	}
+	if err := ingestBatch(&client, "app", records, false); err != nil || state.records != 1 {
+		t.Errorf("expected the uncompressed batch to be accepted, got %v", err)
+	}
+}
+
+func TestCheckIngestInputReplaysPipes(t *testing.T) {
+	input := "{\"a\":1}\nnot json\n{\"a\":2}\n"
+	for name, reader := range map[string]io.Reader{
+		"file": strings.NewReader(input),
+		// a reader that cannot seek, like a pipe on stdin
+		"pipe": struct{ io.Reader }{strings.NewReader(input)},
+	} {
+		report := &invalidReport{}
+		replay, cleanup, err := checkIngestInput(reader, nil, report)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if report.count != 1 {
+			t.Errorf("%s: expected 1 invalid record, got %d", name, report.count)
+		}
+		data, _ := io.ReadAll(replay)
+		cleanup()
+		if string(data) != input {
+			t.Errorf("%s: expected the input to be replayed, got %q", name, data)
+		}
+	}
+}
+
+func TestIngestSenderSendsBatches(t *testing.T) {
+	state := &gzipIngestServer{}
+	server := httptest.NewServer(state)
+	defer server.Close()
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	sender := &ingestSender{client: &client, name: "app", size: 2}
+	err := scanIngestRecords(strings.NewReader("{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n"), nil, sender.add, func(invalidRecord) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.records != 2 || len(state.encodings) != 1 {
+		t.Errorf("expected one full batch to be sent while reading, got %d records in %d batches", state.records, len(state.encodings))
+	}
+	if err := sender.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if state.records != 3 || sender.sent != 3 {
+		t.Errorf("expected the last batch to be sent on flush, got %d records", state.records)
+	}
+}
+
+func TestInvalidReportWritesDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rejected.jsonl")
+	report := &invalidReport{deadLetterPath: path}
+	for idx, raw := range []string{"not json", `{"at":"yesterday"}`} {
+		if err := report.add(invalidRecord{line: idx + 1, raw: raw}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := report.finish(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "not json\n{\"at\":\"yesterday\"}\n" {
+		t.Errorf("expected both records in the dead letter file, got %q", data)
+	}
+}
+
+func TestDateFieldsAcceptDates(t *testing.T) {
+	field := schemaField{Name: "day", Type: "Date32"}
+	if err := checkFieldValue(field, "2024-05-01"); err != nil {
+		t.Errorf("expected a date to match a Date field, got %v", err)
+	}
+	field.Type = "Timestamp"
+	if err := checkFieldValue(field, "2024-05-01"); err == nil {
+		t.Error("expected a date without a time not to match a Timestamp field")
+	}
+}
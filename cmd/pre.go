@@ -20,12 +20,16 @@ import (
 	"errors"
 	"os"
 	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
 
 	"github.com/spf13/cobra"
 )
 
 var DefaultProfile config.Profile
 
+// DefaultProfileName is the name of DefaultProfile in the config file
+var DefaultProfileName string
+
 // PreRunDefaultProfile if a profile exists.
 // This is required by mostly all commands except profile
 func PreRunDefaultProfile(_ *cobra.Command, _ []string) error {
@@ -33,6 +37,10 @@ func PreRunDefaultProfile(_ *cobra.Command, _ []string) error {
 }
 
 func PreRun() error {
+	if shellSession {
+		return nil
+	}
+
 	conf, err := config.ReadConfigFromFile()
 	if os.IsNotExist(err) {
 		return errors.New("no config found to run this command. add a profile using pb profile command")
@@ -40,10 +48,10 @@ func PreRun() error {
 		return err
 	}
 
-	if conf.Profiles == nil || conf.DefaultProfile == "" {
-		return errors.New("no profile is configured to run this command. please create one using profile command")
+	DefaultProfile, err = conf.Default()
+	if err != nil {
+		return err
 	}
-
-	DefaultProfile = conf.Profiles[conf.DefaultProfile]
-	return nil
+	DefaultProfileName = conf.DefaultProfile
+	return internalHTTP.ValidateTLSConfig(&DefaultProfile)
 }
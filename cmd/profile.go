@@ -21,8 +21,10 @@ import (
 	"fmt"
 	"net/url"
 	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
 	"pb/pkg/model/credential"
 	"pb/pkg/model/defaultprofile"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -31,7 +33,7 @@ import (
 
 // ProfileListItem is a struct to hold the profile list items
 type ProfileListItem struct {
-	title, url, user string
+	title, url, user, group string
 }
 
 func (item *ProfileListItem) Render(highlight bool) string {
@@ -42,6 +44,9 @@ func (item *ProfileListItem) Render(highlight bool) string {
 			SelectedStyleAlt.Render(fmt.Sprintf("url: %s", item.url)),
 			SelectedStyleAlt.Render(fmt.Sprintf("user: %s", item.user)),
 		)
+		if item.group != "" {
+			render += "\n" + SelectedStyleAlt.Render(fmt.Sprintf("group: %s", item.group))
+		}
 		return SelectedItemOuter.Render(render)
 	}
 	render := fmt.Sprintf(
@@ -50,22 +55,68 @@ func (item *ProfileListItem) Render(highlight bool) string {
 		StandardStyleAlt.Render(fmt.Sprintf("url: %s", item.url)),
 		StandardStyleAlt.Render(fmt.Sprintf("user: %s", item.user)),
 	)
+	if item.group != "" {
+		render += "\n" + StandardStyleAlt.Render(fmt.Sprintf("group: %s", item.group))
+	}
 	return ItemOuter.Render(render)
 }
 
-// Add an output flag to specify the output format.
-var outputFormat string
+// Each profile command binds its own --output, so a default set for one of
+// them does not change the output of the others
+var (
+	addProfileOutput     string
+	removeProfileOutput  string
+	defaultProfileOutput string
+	listProfileOutput    string
+)
+
+var (
+	setDefaultFlag   = "set-default"
+	noDefaultFlag    = "no-default"
+	urlsFlag         = "urls"
+	lbPolicyFlag     = "lb-policy"
+	unsetDefaultFlag = "unset"
+)
+
+// applyEndpointFlags copies the further endpoints and the load balancing
+// policy of profile add onto profile
+func applyEndpointFlags(cmd *cobra.Command, profile *config.Profile) error {
+	urls, _ := cmd.Flags().GetStringSlice(urlsFlag)
+	for _, endpoint := range urls {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			profile.URLs = append(profile.URLs, endpoint)
+		}
+	}
+	if cmd.Flags().Changed(lbPolicyFlag) {
+		if len(profile.URLs) == 0 {
+			return fmt.Errorf("--%s requires --%s", lbPolicyFlag, urlsFlag)
+		}
+		profile.LBPolicy, _ = cmd.Flags().GetString(lbPolicyFlag)
+	}
+	return profile.ValidateEndpoints()
+}
 
 // Initialize flags
 func init() {
-	AddProfileCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (text|json)")
-	RemoveProfileCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (text|json)")
-	DefaultProfileCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (text|json)")
-	ListProfileCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (text|json)")
+	AddProfileCmd.Flags().StringVarP(&addProfileOutput, "output", "o", "", "Output format (text|json)")
+	AddProfileCmd.Flags().Bool(setDefaultFlag, false, "Make the new profile the default profile")
+	AddProfileCmd.Flags().Bool(noDefaultFlag, false, "Never change the default profile, even if none is set")
+	AddProfileCmd.MarkFlagsMutuallyExclusive(setDefaultFlag, noDefaultFlag)
+	AddProfileCmd.Flags().BoolP(interactiveFlag, "i", false, "Prompt for each setting step by step")
+	AddProfileCmd.Flags().String(profileGroupFlag, "", "Tag the profile with a group such as prod or staging")
+	AddProfileCmd.Flags().Bool(validateProfileFlag, false, "Check that the server can be reached and accepts the credentials before saving the profile, on by default with --interactive")
+	AddProfileCmd.Flags().Bool(forceProfileFlag, false, "Save the profile even when the --validate check fails")
+	AddProfileCmd.Flags().StringSlice(urlsFlag, nil, "Further endpoints of the same deployment, separated by commas, tried when url cannot be reached")
+	AddProfileCmd.Flags().String(lbPolicyFlag, config.LBPolicyFailover, fmt.Sprintf("Order the endpoints are tried in: %s or %s", config.LBPolicyFailover, config.LBPolicyRoundRobin))
+	RemoveProfileCmd.Flags().StringVarP(&removeProfileOutput, "output", "o", "", "Output format (text|json)")
+	DefaultProfileCmd.Flags().StringVarP(&defaultProfileOutput, "output", "o", "", "Output format (text|json)")
+	DefaultProfileCmd.Flags().Bool(unsetDefaultFlag, false, "Clear the default profile so commands fail until one is chosen")
+	ListProfileCmd.Flags().StringVarP(&listProfileOutput, "output", "o", "", "Output format (text|json)")
+	ListProfileCmd.Flags().String(profileGroupFlag, "", "Only list profiles in this group")
 }
 
-func outputResult(v interface{}) error {
-	if outputFormat == "json" {
+func outputResult(format string, v interface{}) error {
+	if format == "json" {
 		jsonData, err := json.MarshalIndent(v, "", "  ")
 		if err != nil {
 			return err
@@ -77,12 +128,77 @@ func outputResult(v interface{}) error {
 	return nil
 }
 
+// addProfile adds or replaces the named profile in conf and decides whether it
+// becomes the default:
+//   - setDefault: the profile always becomes the default
+//   - noDefault: the default is never changed, so a config without a default
+//     stays without one until pb profile default is run
+//   - neither: the profile becomes the default only if no default is set yet
+func addProfile(conf *config.Config, name string, profile config.Profile, setDefault, noDefault bool) {
+	if conf.Profiles == nil {
+		conf.Profiles = make(map[string]config.Profile)
+	}
+	conf.Profiles[name] = profile
+
+	switch {
+	case setDefault:
+		conf.DefaultProfile = name
+	case noDefault:
+		// keep whatever default is already configured
+	case conf.DefaultProfile == "":
+		conf.DefaultProfile = name
+	}
+}
+
 var AddProfileCmd = &cobra.Command{
 	Use:     "add profile-name url <username?> <password?>",
-	Example: "  pb profile add local_parseable http://0.0.0.0:8000 admin admin",
+	Example: "  pb profile add local_parseable http://0.0.0.0:8000 admin admin\n  pb profile add staging https://staging.example.com admin admin --no-default\n  pb profile add prod https://node1.example.com admin admin --urls https://node2.example.com,https://node3.example.com\n  pb profile add --interactive\n  pb profile add prod https://parseable.example.com admin admin --validate\n  pb profile add automation https://parseable.example.com --auth-mode oauth-client-credentials --token-url https://idp.example.com/oauth2/token --client-id pb-ci --client-secret $SECRET --scope parseable",
 	Short:   "Add a new profile",
-	Long:    "Add a new profile to the config file",
+	Long: `Add a new profile to the config file.
+
+The new profile becomes the default only if no default profile is set yet,
+for example when it is the first profile added. Use --set-default to always
+make it the default, or --no-default to leave the default unchanged.
+
+Pass --min-tls-version to store a minimum TLS version with the profile, and
+--group to tag it with an environment such as prod, so that commands like
+pb stream list --group prod run against every profile in the group.
+
+For a deployment with several nodes, pass the other endpoints with --urls.
+With the default --lb-policy failover, requests go to url and move on to the
+next endpoint only when one cannot be reached. With round-robin, requests
+are spread across all endpoints in turn.
+
+Pass --validate to check that the server can be reached and accepts the
+credentials before the profile is saved. A profile that fails the check is
+not saved, unless you add --force. The error says whether the server could
+not be reached or rejected the credentials.
+
+Use --interactive to be prompted for each setting instead. The wizard runs
+the same check at the end and asks before saving a profile that fails it,
+pass --validate=false to skip the check.
+
+For automation against a server behind an OIDC provider, use --auth-mode
+oauth-client-credentials with --token-url, --client-id and --client-secret
+instead of a username and password. pb fetches a bearer token from the token
+URL and refreshes it when it expires. Add --cache-token to reuse tokens
+across runs, they are stored next to the config file and readable only by
+you.
+
+For gateways that require signed requests, use --auth-mode hmac with
+--hmac-key-id and --hmac-secret. pb signs every request on top of the
+username and password.
+
+Pass --default-format, --default-timezone or --default-limit to give pb
+query run different defaults while this is the default profile. Change them
+later with pb profile set.`,
 	Args: func(cmd *cobra.Command, args []string) error {
+		if interactive, _ := cmd.Flags().GetBool(interactiveFlag); interactive {
+			return cobra.NoArgs(cmd, args)
+		}
+		if usesOAuthFlags(cmd) {
+			return cobra.ExactArgs(2)(cmd, args)
+		}
 		if err := cobra.MinimumNArgs(2)(cmd, args); err != nil {
 			return err
 		}
@@ -95,49 +211,107 @@ var AddProfileCmd = &cobra.Command{
 		startTime := time.Now()
 		var commandError error
 
-		// Parsing input and handling errors
-		name := args[0]
-		url, err := url.Parse(args[1])
+		fileConfig, err := config.ReadConfigFromFile()
 		if err != nil {
-			commandError = fmt.Errorf("error parsing URL: %s", err)
-			cmd.Annotations["error"] = commandError.Error()
-			return commandError
+			fileConfig = &config.Config{}
 		}
 
-		var username, password string
-		if len(args) < 4 {
-			_m, err := tea.NewProgram(credential.New()).Run()
-			if err != nil {
-				commandError = fmt.Errorf("error reading credentials: %s", err)
+		var name string
+		var profile config.Profile
+		if interactive, _ := cmd.Flags().GetBool(interactiveFlag); interactive {
+			if cmd.Flags().Changed(authModeFlag) {
+				commandError = fmt.Errorf("--%s cannot be used with --%s", authModeFlag, interactiveFlag)
 				cmd.Annotations["error"] = commandError.Error()
 				return commandError
 			}
-			m := _m.(credential.Model)
-			username, password = m.Values()
+			name, profile, err = runProfileWizard(fileConfig.Profiles)
+			if err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
 		} else {
-			username = args[2]
-			password = args[3]
+			// Parsing input and handling errors
+			name = args[0]
+			url, err := url.Parse(args[1])
+			if err != nil {
+				commandError = fmt.Errorf("error parsing URL: %s", err)
+				cmd.Annotations["error"] = commandError.Error()
+				return commandError
+			}
+
+			var username, password string
+			switch {
+			case usesOAuthFlags(cmd):
+				// the client credentials replace the username and password
+			case len(args) < 4:
+				_m, err := tea.NewProgram(credential.New()).Run()
+				if err != nil {
+					commandError = fmt.Errorf("error reading credentials: %s", err)
+					cmd.Annotations["error"] = commandError.Error()
+					return commandError
+				}
+				m := _m.(credential.Model)
+				username, password = m.Values()
+			default:
+				username = args[2]
+				password = args[3]
+			}
+			profile = config.Profile{URL: url.String(), Username: username, Password: password}
+			if err := applyAuthModeFlags(cmd, &profile); err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
 		}
 
-		profile := config.Profile{URL: url.String(), Username: username, Password: password}
-		fileConfig, err := config.ReadConfigFromFile()
-		if err != nil {
-			newConfig := config.Config{
-				Profiles:       map[string]config.Profile{name: profile},
-				DefaultProfile: name,
+		setDefault, _ := cmd.Flags().GetBool(setDefaultFlag)
+		noDefault, _ := cmd.Flags().GetBool(noDefaultFlag)
+
+		if cmd.Flags().Changed("min-tls-version") {
+			if _, err := internalHTTP.ParseTLSVersion(internalHTTP.MinTLSVersion); err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
 			}
-			err = config.WriteConfigToFile(&newConfig)
-			commandError = err
-		} else {
-			if fileConfig.Profiles == nil {
-				fileConfig.Profiles = make(map[string]config.Profile)
+			profile.MinTLSVersion = internalHTTP.MinTLSVersion
+		}
+		if group, _ := cmd.Flags().GetString(profileGroupFlag); group != "" {
+			if err := config.ValidateGroupName(group); err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
 			}
-			fileConfig.Profiles[name] = profile
-			if fileConfig.DefaultProfile == "" {
-				fileConfig.DefaultProfile = name
+			profile.Group = group
+		}
+		if err := applyEndpointFlags(cmd, &profile); err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		if _, err := applyProfileDefaultFlags(cmd.Flags(), &profile); err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		// the wizard tests the connection unless told not to
+		interactive, _ := cmd.Flags().GetBool(interactiveFlag)
+		validate := interactive
+		if cmd.Flags().Changed(validateProfileFlag) {
+			validate, _ = cmd.Flags().GetBool(validateProfileFlag)
+		}
+		if validate {
+			force, _ := cmd.Flags().GetBool(forceProfileFlag)
+			var confirm func() bool
+			if interactive {
+				confirm = confirmSaveProfile
+			}
+			if err := runProfileCheck(profile, force, confirm); err != nil {
+				cmd.SilenceUsage = true
+				cmd.Annotations["error"] = err.Error()
+				return err
 			}
-			commandError = config.WriteConfigToFile(fileConfig)
 		}
+		// re-read the config under the lock so profiles added by pb
+		// commands running at the same time are kept
+		commandError = config.UpdateConfig(func(conf *config.Config) error {
+			addProfile(conf, name, profile, setDefault, noDefault)
+			return nil
+		})
 
 		cmd.Annotations["executionTime"] = time.Since(startTime).String()
 		if commandError != nil {
@@ -145,8 +319,8 @@ var AddProfileCmd = &cobra.Command{
 			return commandError
 		}
 
-		if outputFormat == "json" {
-			return outputResult(profile)
+		if addProfileOutput == "json" {
+			return outputResult(addProfileOutput, profile)
 		}
 		fmt.Printf("Profile %s added successfully\n", name)
 		return nil
@@ -180,20 +354,21 @@ var RemoveProfileCmd = &cobra.Command{
 			return nil
 		}
 
-		delete(fileConfig.Profiles, name)
-		if len(fileConfig.Profiles) == 0 {
-			fileConfig.DefaultProfile = ""
-		}
-
-		commandError := config.WriteConfigToFile(fileConfig)
+		commandError := config.UpdateConfig(func(conf *config.Config) error {
+			delete(conf.Profiles, name)
+			if len(conf.Profiles) == 0 {
+				conf.DefaultProfile = ""
+			}
+			return nil
+		})
 		cmd.Annotations["executionTime"] = time.Since(startTime).String()
 		if commandError != nil {
 			cmd.Annotations["error"] = commandError.Error()
 			return commandError
 		}
 
-		if outputFormat == "json" {
-			return outputResult(fmt.Sprintf("Deleted profile %s", name))
+		if removeProfileOutput == "json" {
+			return outputResult(removeProfileOutput, fmt.Sprintf("Deleted profile %s", name))
 		}
 		fmt.Printf("Deleted profile %s\n", name)
 		return nil
@@ -204,7 +379,12 @@ var DefaultProfileCmd = &cobra.Command{
 	Use:     "default profile-name",
 	Args:    cobra.MaximumNArgs(1),
 	Short:   "Set default profile to use with all commands",
-	Example: "  pb profile default local_parseable",
+	Example: "  pb profile default local_parseable\n  pb profile default --unset",
+	Long: `Set the default profile that commands run against.
+
+Use --unset to clear the default. Commands that talk to a server then fail
+with an error asking you to choose a profile, instead of picking one, so a
+command cannot reach the wrong server by accident.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if cmd.Annotations == nil {
 			cmd.Annotations = make(map[string]string)
@@ -217,6 +397,28 @@ var DefaultProfileCmd = &cobra.Command{
 			return err
 		}
 
+		if unset, _ := cmd.Flags().GetBool(unsetDefaultFlag); unset {
+			if len(args) > 0 {
+				commandError := fmt.Sprintf("--%s takes no profile name", unsetDefaultFlag)
+				cmd.Annotations["error"] = commandError
+				return errors.New(commandError)
+			}
+			commandError := config.UpdateConfig(func(conf *config.Config) error {
+				conf.DefaultProfile = ""
+				return nil
+			})
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+			if commandError != nil {
+				cmd.Annotations["error"] = commandError.Error()
+				return commandError
+			}
+			if defaultProfileOutput == "json" {
+				return outputResult(defaultProfileOutput, "default profile unset")
+			}
+			fmt.Println("Default profile unset. Run pb profile default profile-name to set one again")
+			return nil
+		}
+
 		var name string
 		if len(args) > 0 {
 			name = args[0]
@@ -241,16 +443,21 @@ var DefaultProfileCmd = &cobra.Command{
 			return errors.New(commandError)
 		}
 
-		fileConfig.DefaultProfile = name
-		commandError := config.WriteConfigToFile(fileConfig)
+		commandError := config.UpdateConfig(func(conf *config.Config) error {
+			if _, exists := conf.Profiles[name]; !exists {
+				return fmt.Errorf("profile %s does not exist", name)
+			}
+			conf.DefaultProfile = name
+			return nil
+		})
 		cmd.Annotations["executionTime"] = time.Since(startTime).String()
 		if commandError != nil {
 			cmd.Annotations["error"] = commandError.Error()
 			return commandError
 		}
 
-		if outputFormat == "json" {
-			return outputResult(fmt.Sprintf("%s is now set as default profile", name))
+		if defaultProfileOutput == "json" {
+			return outputResult(defaultProfileOutput, fmt.Sprintf("%s is now set as default profile", name))
 		}
 		fmt.Printf("%s is now set as default profile\n", name)
 		return nil
@@ -260,7 +467,7 @@ var DefaultProfileCmd = &cobra.Command{
 var ListProfileCmd = &cobra.Command{
 	Use:     "list profiles",
 	Short:   "List all added profiles",
-	Example: "  pb profile list",
+	Example: "  pb profile list\n  pb profile list --group prod",
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		if cmd.Annotations == nil {
 			cmd.Annotations = make(map[string]string)
@@ -273,8 +480,20 @@ var ListProfileCmd = &cobra.Command{
 			return err
 		}
 
-		if outputFormat == "json" {
-			commandError := outputResult(fileConfig.Profiles)
+		group, _ := cmd.Flags().GetString(profileGroupFlag)
+		if group != "" {
+			if err := config.ValidateGroupName(group); err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
+		}
+		profiles := make(map[string]config.Profile)
+		for _, name := range fileConfig.ProfilesInGroup(group) {
+			profiles[name] = fileConfig.Profiles[name]
+		}
+
+		if listProfileOutput == "json" {
+			commandError := outputResult(listProfileOutput, profiles)
 			cmd.Annotations["executionTime"] = time.Since(startTime).String()
 			if commandError != nil {
 				cmd.Annotations["error"] = commandError.Error()
@@ -283,8 +502,8 @@ var ListProfileCmd = &cobra.Command{
 			return nil
 		}
 
-		for key, value := range fileConfig.Profiles {
-			item := ProfileListItem{key, value.URL, value.Username}
+		for key, value := range profiles {
+			item := ProfileListItem{key, value.URL, value.Username, value.Group}
 			fmt.Println(item.Render(fileConfig.DefaultProfile == key))
 			fmt.Println() // Add a blank line after each profile
 		}
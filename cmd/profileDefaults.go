@@ -0,0 +1,175 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"pb/pkg/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	defaultFormatFlag   = "default-format"
+	defaultTimezoneFlag = "default-timezone"
+	defaultLimitFlag    = "default-limit"
+
+	// profileQuerySection is the command the query defaults of a profile
+	// apply to, see ApplyFlagDefaults
+	profileQuerySection = "query run"
+)
+
+// profileDefaultFlags maps the profile flags to the pb query run flags they
+// set a default for
+var profileDefaultFlags = []struct{ flag, target string }{
+	{defaultFormatFlag, outputFlag},
+	{defaultTimezoneFlag, timezoneFlag},
+	{defaultLimitFlag, limitFlag},
+}
+
+func addProfileDefaultFlags(flags *pflag.FlagSet) {
+	flags.String(defaultFormatFlag, "", "Default output format of pb query run while this is the default profile (text|json|ndjson|csv|table). Empty removes it")
+	flags.String(defaultTimezoneFlag, "", "Default --timezone of pb query run while this is the default profile. Empty removes it")
+	flags.Int(defaultLimitFlag, 0, "Default --limit of pb query run while this is the default profile. 0 removes it")
+}
+
+// applyProfileDefaultFlags stores the query defaults given on the command
+// line in profile, and reports whether any were given. An empty value or a
+// limit of 0 removes the default
+func applyProfileDefaultFlags(flags *pflag.FlagSet, profile *config.Profile) (bool, error) {
+	section := map[string]interface{}{}
+	if existing, ok := profile.Defaults[profileQuerySection].(map[string]interface{}); ok {
+		for key, value := range existing {
+			section[key] = value
+		}
+	}
+
+	changed := false
+	for _, mapping := range profileDefaultFlags {
+		if !flags.Changed(mapping.flag) {
+			continue
+		}
+		changed = true
+		value := flags.Lookup(mapping.flag).Value.String()
+		if err := validateProfileDefault(mapping.flag, value); err != nil {
+			return false, err
+		}
+		if value == "" || (mapping.flag == defaultLimitFlag && value == "0") {
+			delete(section, mapping.target)
+			continue
+		}
+		if mapping.flag == defaultLimitFlag {
+			limit, _ := strconv.ParseInt(value, 10, 64)
+			section[mapping.target] = limit
+		} else {
+			section[mapping.target] = value
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if profile.Defaults == nil {
+		profile.Defaults = map[string]interface{}{}
+	}
+	profile.Defaults[profileQuerySection] = section
+	if len(section) == 0 {
+		delete(profile.Defaults, profileQuerySection)
+	}
+	if len(profile.Defaults) == 0 {
+		profile.Defaults = nil
+	}
+	return true, nil
+}
+
+func validateProfileDefault(flag, value string) error {
+	if value == "" {
+		return nil
+	}
+	switch flag {
+	case defaultFormatFlag:
+		switch value {
+		case "text", "json", "ndjson", "csv", "table":
+			return nil
+		case "xlsx":
+			return fmt.Errorf("--%s cannot be xlsx, as xlsx output needs --%s on every query", defaultFormatFlag, outputFileFlag)
+		}
+		return fmt.Errorf("unsupported --%s %q. Supported formats are text, json, ndjson, csv and table", defaultFormatFlag, value)
+	case defaultTimezoneFlag:
+		_, err := parseTimezone(value)
+		return err
+	case defaultLimitFlag:
+		if limit, err := strconv.Atoi(value); err != nil || limit < 0 {
+			return fmt.Errorf("--%s must be 0 or more, got %s", defaultLimitFlag, value)
+		}
+	}
+	return nil
+}
+
+var SetProfileCmd = &cobra.Command{
+	Use:     "set profile-name",
+	Example: "  pb profile set reporting --default-format csv --default-timezone Europe/Berlin\n  pb profile set reporting --default-format \"\"",
+	Short:   "Change the query defaults of a profile",
+	Long: `Change the defaults pb query run uses while a profile is the default
+profile, so switching profiles also switches to the defaults that suit it.
+
+Flags on the command line always override these defaults, and these
+defaults override the [Defaults] section of the config file. Pass an empty
+value, or 0 for --default-limit, to remove a default.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		startTime := time.Now()
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		name := args[0]
+		err := config.UpdateConfig(func(conf *config.Config) error {
+			profile, exists := conf.Profiles[name]
+			if !exists {
+				return fmt.Errorf("profile %s does not exist", name)
+			}
+			changed, err := applyProfileDefaultFlags(cmd.Flags(), &profile)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				return fmt.Errorf("nothing to change, pass --%s, --%s or --%s", defaultFormatFlag, defaultTimezoneFlag, defaultLimitFlag)
+			}
+			conf.Profiles[name] = profile
+			return nil
+		})
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
+		fmt.Printf("Profile %s updated\n", name)
+		return nil
+	},
+}
+
+func init() {
+	addProfileDefaultFlags(AddProfileCmd.Flags())
+	addProfileDefaultFlags(SetProfileCmd.Flags())
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"testing"
+
+	"pb/pkg/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func parseProfileDefaultFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addProfileDefaultFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestApplyProfileDefaultFlags(t *testing.T) {
+	var profile config.Profile
+	flags := parseProfileDefaultFlags(t, "--default-format=csv", "--default-timezone=UTC", "--default-limit=500")
+	if changed, err := applyProfileDefaultFlags(flags, &profile); err != nil || !changed {
+		t.Fatalf("expected defaults to be stored, got %v", err)
+	}
+
+	section, _ := profile.Defaults[profileQuerySection].(map[string]interface{})
+	if section["output"] != "csv" || section["timezone"] != "UTC" || section["limit"] != int64(500) {
+		t.Errorf("expected query defaults keyed by flag name, got %v", profile.Defaults)
+	}
+
+	// an empty value removes one default and keeps the others
+	flags = parseProfileDefaultFlags(t, "--default-format=", "--default-limit=0")
+	if _, err := applyProfileDefaultFlags(flags, &profile); err != nil {
+		t.Fatal(err)
+	}
+	section, _ = profile.Defaults[profileQuerySection].(map[string]interface{})
+	if len(section) != 1 || section["timezone"] != "UTC" {
+		t.Errorf("expected only the timezone default to remain, got %v", profile.Defaults)
+	}
+
+	flags = parseProfileDefaultFlags(t, "--default-timezone=")
+	if _, err := applyProfileDefaultFlags(flags, &profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.Defaults != nil {
+		t.Errorf("expected no defaults to be left, got %v", profile.Defaults)
+	}
+}
+
+func TestApplyProfileDefaultFlagsValidates(t *testing.T) {
+	for _, arg := range []string{"--default-format=xml", "--default-format=xlsx", "--default-timezone=Mars/Base", "--default-limit=-1"} {
+		var profile config.Profile
+		if _, err := applyProfileDefaultFlags(parseProfileDefaultFlags(t, arg), &profile); err == nil {
+			t.Errorf("expected %s to be rejected", arg)
+		}
+	}
+
+	var profile config.Profile
+	if changed, _ := applyProfileDefaultFlags(parseProfileDefaultFlags(t), &profile); changed {
+		t.Error("expected no change without flags")
+	}
+}
+
+func TestProfileDefaultsStayWithinAllowlist(t *testing.T) {
+	for _, mapping := range profileDefaultFlags {
+		if !defaultableFlags[mapping.target] {
+			t.Errorf("--%s sets --%s, which cannot be given a default", mapping.flag, mapping.target)
+		}
+	}
+
+	// the output default of pb query run does not reach the --output of
+	// another command, and a hand-written yes is never applied
+	root, run := newDefaultsTestCommand()
+	var versionOutput string
+	version := &cobra.Command{Use: "version", Run: func(*cobra.Command, []string) {}}
+	version.Flags().StringVarP(&versionOutput, "output", "o", "text", "")
+	root.AddCommand(version)
+
+	profile := map[string]interface{}{
+		"yes":               true,
+		profileQuerySection: map[string]interface{}{"output": "csv"},
+	}
+	if err := ApplyFlagDefaults(root, nil, profile, noEnv); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := run.Flags().GetString("output"); output != "csv" {
+		t.Errorf("expected the profile default for pb query run, got %q", output)
+	}
+	if versionOutput != "text" {
+		t.Errorf("expected pb version to keep its own output, got %q", versionOutput)
+	}
+	if yes, _ := run.Flags().GetBool("yes"); yes {
+		t.Error("expected a profile default for --yes to be ignored")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"pb/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var EnvProfileCmd = &cobra.Command{
+	Use:     "env profile-name",
+	Example: "  eval \"$(pb profile env prod)\"",
+	Short:   "Print export lines for a profile's URL and credentials",
+	Long: `Print shell export lines for the URL and credentials of a profile, so
+other tools can use them:
+
+  eval "$(pb profile env prod)"
+
+PB_URL is always exported, PB_USERNAME, PB_PASSWORD and PB_TOKEN only when
+the profile has them. Values are printed as they are, so a warning is
+written to stderr. pb itself does not read these variables.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		startTime := time.Now()
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		name := args[0]
+		fileConfig, err := config.ReadConfigFromFile()
+		if err != nil {
+			cmd.Annotations["error"] = fmt.Sprintf("error reading config: %s", err)
+			return err
+		}
+
+		profile, err := fileConfig.Profile(name)
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: printing the credentials of profile %s in plain text\n", name)
+		return writeProfileEnv(os.Stdout, profile)
+	},
+}
+
+// writeProfileEnv writes one export line per profile setting, quoted for
+// POSIX shells
+func writeProfileEnv(w io.Writer, profile config.Profile) error {
+	values := []struct{ name, value string }{
+		{"PB_URL", profile.URL},
+		{"PB_USERNAME", profile.Username},
+		{"PB_PASSWORD", profile.Password},
+		{"PB_TOKEN", profile.Token},
+	}
+	for _, v := range values {
+		if v.value == "" && v.name != "PB_URL" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "export %s=%s\n", v.name, shellQuote(v.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote wraps value in single quotes, so the shell takes it literally
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
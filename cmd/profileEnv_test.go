@@ -0,0 +1,70 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+func TestWriteProfileEnv(t *testing.T) {
+	var out strings.Builder
+	profile := config.Profile{URL: "https://logs.example.com", Username: "admin", Password: "it's secret"}
+	if err := writeProfileEnv(&out, profile); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "export PB_URL='https://logs.example.com'\n" +
+		"export PB_USERNAME='admin'\n" +
+		"export PB_PASSWORD='it'\\''s secret'\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteProfileEnvWithToken(t *testing.T) {
+	var out strings.Builder
+	if err := writeProfileEnv(&out, config.Profile{URL: "http://localhost:8000", Token: "abc$123"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "export PB_URL='http://localhost:8000'\nexport PB_TOKEN='abc$123'\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestProfileEnvDoesNotSeedFlags(t *testing.T) {
+	root, run := newDefaultsTestCommand()
+	getenv := func(name string) string {
+		if name == "PB_PASSWORD" {
+			return "admin"
+		}
+		return ""
+	}
+	if err := ApplyFlagDefaults(root, nil, nil, getenv); err != nil {
+		t.Fatal(err)
+	}
+	root.SetArgs([]string{"query", "run"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if password, _ := run.Flags().GetString("password"); password != "" {
+		t.Errorf("expected PB_PASSWORD not to set --password, got %q", password)
+	}
+}
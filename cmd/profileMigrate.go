@@ -0,0 +1,192 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+)
+
+var clearPasswordFlag = "clear-password"
+
+var MigrateProfileCmd = &cobra.Command{
+	Use:     "migrate profile-name",
+	Example: "  pb profile migrate local_parseable\n  pb profile migrate local_parseable --clear-password",
+	Short:   "Switch a profile from username and password to an API token",
+	Long: `Switch a profile from username and password to an API token.
+
+pb asks the server for an API token using the profile credentials and checks
+that the token works before storing it on the profile. The profile is left
+unchanged if the server does not issue a token or the token is rejected.
+Use --clear-password to remove the stored password once the token is saved.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		startTime := time.Now()
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		name := args[0]
+		clearPassword, _ := cmd.Flags().GetBool(clearPasswordFlag)
+
+		fileConfig, err := config.ReadConfigFromFile()
+		if err != nil {
+			cmd.Annotations["error"] = fmt.Sprintf("error reading config: %s", err)
+			return err
+		}
+
+		profile, err := fileConfig.Profile(name)
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		if profile.UsesOAuth() {
+			fmt.Printf("Profile %s already uses OAuth client credentials\n", name)
+			return nil
+		}
+		if profile.Token != "" {
+			fmt.Printf("Profile %s already uses an API token\n", name)
+			return nil
+		}
+
+		migrated, err := migrateProfile(profile, clearPassword)
+		if err != nil {
+			commandError := fmt.Errorf("profile %s was not changed: %w", name, err)
+			cmd.Annotations["error"] = commandError.Error()
+			return commandError
+		}
+
+		// only the token and password change, the other settings are
+		// written back as stored so environment references are kept
+		err = config.UpdateConfig(func(conf *config.Config) error {
+			stored, exists := conf.Profiles[name]
+			if !exists {
+				return fmt.Errorf("profile %s does not exist", name)
+			}
+			stored.Token = migrated.Token
+			if clearPassword {
+				stored.Password = ""
+			}
+			conf.Profiles[name] = stored
+			return nil
+		})
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
+		if clearPassword {
+			fmt.Printf("Profile %s now uses an API token, the stored password was removed\n", name)
+		} else {
+			fmt.Printf("Profile %s now uses an API token\n", name)
+		}
+		return nil
+	},
+}
+
+func init() {
+	MigrateProfileCmd.Flags().Bool(clearPasswordFlag, false, "Remove the stored password after the token is verified")
+}
+
+// migrateProfile mints an API token with the basic auth credentials of
+// profile and verifies it. It returns a copy of the profile with the token
+// set, or an error and no changes if either step fails
+func migrateProfile(profile config.Profile, clearPassword bool) (config.Profile, error) {
+	client := internalHTTP.DefaultClient(&profile)
+	token, err := mintToken(&client, profile.Username)
+	if err != nil {
+		return profile, err
+	}
+
+	migrated := profile
+	migrated.Token = token
+	tokenClient := internalHTTP.DefaultClient(&migrated)
+	if err := verifyToken(&tokenClient); err != nil {
+		return profile, err
+	}
+
+	if clearPassword {
+		migrated.Password = ""
+	}
+	return migrated, nil
+}
+
+// mintToken asks the server to issue an API token for the user
+func mintToken(client *internalHTTP.HTTPClient, username string) (string, error) {
+	req, err := client.NewRequest(http.MethodPost, fmt.Sprintf("user/%s/token", username), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	bytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return "", errors.New("server does not support API tokens")
+	default:
+		return "", fmt.Errorf("token creation failed\nStatus Code: %s\nResponse: %s", resp.Status, strings.TrimSpace(string(bytes)))
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(bytes, &body); err != nil || body.Token == "" {
+		return "", errors.New("server did not return a token")
+	}
+	return body.Token, nil
+}
+
+// verifyToken checks that the server accepts the client's token
+func verifyToken(client *internalHTTP.HTTPClient) error {
+	req, err := client.NewRequest(http.MethodGet, "about", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server rejected the new token: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+// tokenServer mocks the token endpoint. It issues token when tokenStatus is
+// 200 and accepts it on the about endpoint when tokenValid is true
+func tokenServer(t *testing.T, tokenStatus int, tokenValid bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/user/admin/token":
+			if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(tokenStatus)
+			if tokenStatus == http.StatusOK {
+				w.Write([]byte(`{"token":"abc123"}`))
+			}
+		case "/api/v1/about":
+			if !tokenValid || r.Header.Get("Authorization") != "Bearer abc123" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMigrateProfileStoresVerifiedToken(t *testing.T) {
+	server := tokenServer(t, http.StatusOK, true)
+	profile := config.Profile{URL: server.URL, Username: "admin", Password: "secret"}
+
+	migrated, err := migrateProfile(profile, true)
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if migrated.Token != "abc123" {
+		t.Errorf("expected token to be stored, got %q", migrated.Token)
+	}
+	if migrated.Password != "" {
+		t.Error("expected password to be cleared")
+	}
+}
+
+func TestMigrateProfileKeepsPasswordByDefault(t *testing.T) {
+	server := tokenServer(t, http.StatusOK, true)
+	profile := config.Profile{URL: server.URL, Username: "admin", Password: "secret"}
+
+	migrated, err := migrateProfile(profile, false)
+	if err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+	if migrated.Password != "secret" {
+		t.Error("expected password to be kept without --clear-password")
+	}
+}
+
+func TestMigrateProfileAbortsWithoutChanges(t *testing.T) {
+	cases := []struct {
+		name        string
+		tokenStatus int
+		tokenValid  bool
+	}{
+		{"token endpoint missing", http.StatusNotFound, true},
+		{"token creation fails", http.StatusInternalServerError, true},
+		{"token rejected", http.StatusOK, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := tokenServer(t, c.tokenStatus, c.tokenValid)
+			profile := config.Profile{URL: server.URL, Username: "admin", Password: "secret"}
+
+			migrated, err := migrateProfile(profile, true)
+			if err == nil {
+				t.Fatal("expected migration to fail")
+			}
+			if migrated.Token != "" || migrated.Password != profile.Password {
+				t.Errorf("expected profile to be unchanged, got %+v", migrated)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"pb/pkg/analytics"
+	"pb/pkg/common"
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/manifoldco/promptui"
+)
+
+var (
+	validateProfileFlag = "validate"
+	forceProfileFlag    = "force"
+)
+
+// checkProfile connects to the server of profile and checks that it accepts
+// the credentials. Connection failures and rejected credentials are reported
+// separately, so that a typo in the URL is not mistaken for a bad password
+func checkProfile(profile config.Profile) (analytics.About, error) {
+	client := internalHTTP.DefaultClient(&profile)
+	req, err := client.NewRequest(http.MethodGet, "about", nil)
+	if err != nil {
+		return analytics.About{}, err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		var notParseable *internalHTTP.NotParseableError
+		switch {
+		case errors.As(err, &notParseable):
+			return analytics.About{}, notParseable
+		case errors.As(err, &netErr):
+			return analytics.About{}, fmt.Errorf("cannot connect to %s: %w", profile.URL, err)
+		}
+		return analytics.About{}, fmt.Errorf("cannot authenticate with %s: %w", profile.URL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return analytics.About{}, fmt.Errorf("connected to %s, but the server rejected the %s (%s)", profile.URL, profileCredentials(profile), resp.Status)
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return analytics.About{}, fmt.Errorf("connected to %s, but the server answered %s: %s", profile.URL, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var about analytics.About
+	if err := json.NewDecoder(resp.Body).Decode(&about); err != nil {
+		return analytics.About{}, fmt.Errorf("connected to %s, but could not read the server version: %w", profile.URL, err)
+	}
+	return about, nil
+}
+
+// profileCredentials names the credentials a profile authenticates with
+func profileCredentials(profile config.Profile) string {
+	switch {
+	case profile.UsesOAuth():
+		return "OAuth client credentials"
+	case profile.Token != "":
+		return "API token"
+	}
+	return "username or password"
+}
+
+// runProfileCheck tests profile before it is saved. When the test fails the
+// profile is only saved with force, or when confirm, if set, returns true
+func runProfileCheck(profile config.Profile, force bool, confirm func() bool) error {
+	about, err := checkProfile(profile)
+	if err == nil {
+		fmt.Fprintf(os.Stderr, common.Green+"Connected to Parseable %s"+common.Reset+"\n", about.Version)
+		return nil
+	}
+	if force {
+		fmt.Fprintf(os.Stderr, "Warning: %s. Saving the profile anyway because of --%s\n", err, forceProfileFlag)
+		return nil
+	}
+	if confirm != nil {
+		fmt.Fprintf(os.Stderr, common.Red+"Connection test failed: %s"+common.Reset+"\n", err)
+		if confirm() {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w. The profile was not saved, pass --%s to save it anyway", err, forceProfileFlag)
+}
+
+// confirmSaveProfile asks whether to save a profile that failed its test
+func confirmSaveProfile() bool {
+	_, err := (&promptui.Prompt{Label: "Save the profile anyway", IsConfirm: true}).Run()
+	return err == nil
+}
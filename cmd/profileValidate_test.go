@@ -0,0 +1,102 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+func aboutServer(username, password string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/about" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"v1.6.0"}`))
+	}))
+}
+
+func TestCheckProfileSucceeds(t *testing.T) {
+	server := aboutServer("admin", "admin")
+	defer server.Close()
+
+	about, err := checkProfile(config.Profile{URL: server.URL, Username: "admin", Password: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if about.Version != "v1.6.0" {
+		t.Errorf("expected the server version, got %q", about.Version)
+	}
+}
+
+func TestCheckProfileReportsRejectedCredentials(t *testing.T) {
+	server := aboutServer("admin", "admin")
+	defer server.Close()
+
+	_, err := checkProfile(config.Profile{URL: server.URL, Username: "admin", Password: "typo"})
+	if err == nil {
+		t.Fatal("expected a wrong password to fail")
+	}
+	if !strings.Contains(err.Error(), "rejected the username or password") || strings.Contains(err.Error(), "cannot connect") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+
+	_, err = checkProfile(config.Profile{URL: server.URL, Username: "admin", Token: "stale"})
+	if err == nil || !strings.Contains(err.Error(), "rejected the API token") {
+		t.Errorf("expected the token to be named, got %v", err)
+	}
+}
+
+func TestCheckProfileReportsUnreachableServer(t *testing.T) {
+	server := aboutServer("admin", "admin")
+	url := server.URL
+	server.Close()
+
+	_, err := checkProfile(config.Profile{URL: url, Username: "admin", Password: "admin"})
+	if err == nil {
+		t.Fatal("expected an unreachable server to fail")
+	}
+	if !strings.Contains(err.Error(), "cannot connect to "+url) {
+		t.Errorf("expected a connection error, got %v", err)
+	}
+}
+
+func TestRunProfileCheckForce(t *testing.T) {
+	server := aboutServer("admin", "admin")
+	defer server.Close()
+	profile := config.Profile{URL: server.URL, Username: "admin", Password: "typo"}
+
+	err := runProfileCheck(profile, false, nil)
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("expected the profile to be refused with a hint at --force, got %v", err)
+	}
+	if err := runProfileCheck(profile, true, nil); err != nil {
+		t.Errorf("expected --force to save the profile, got %v", err)
+	}
+	if err := runProfileCheck(profile, false, func() bool { return true }); err != nil {
+		t.Errorf("expected a confirmed profile to be saved, got %v", err)
+	}
+}
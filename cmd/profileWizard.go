@@ -0,0 +1,120 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/manifoldco/promptui"
+	"golang.org/x/term"
+)
+
+var interactiveFlag = "interactive"
+
+const (
+	authPassword = "Username and password"
+	authToken    = "API token"
+)
+
+// runProfileWizard prompts for the settings of a new profile, step by step.
+// The connection test that follows is run by profile add, see checkProfile
+func runProfileWizard(existing map[string]config.Profile) (string, config.Profile, error) {
+	var profile config.Profile
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return "", profile, fmt.Errorf("--%s needs a terminal, use pb profile add profile-name url <username> <password> instead", interactiveFlag)
+	}
+
+	name, err := (&promptui.Prompt{
+		Label: "Profile name",
+		Validate: func(input string) error {
+			if input == "" || strings.ContainsAny(input, " \t") {
+				return errors.New("name must be non-empty without spaces")
+			}
+			if _, ok := existing[input]; ok {
+				return fmt.Errorf("profile %s already exists", input)
+			}
+			return nil
+		},
+	}).Run()
+	if err != nil {
+		return "", profile, err
+	}
+
+	profile.URL, err = (&promptui.Prompt{
+		Label:   "Server URL",
+		Default: "http://localhost:8000",
+		Validate: func(input string) error {
+			u, err := url.Parse(input)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.New("enter a URL like https://parseable.example.com")
+			}
+			return nil
+		},
+	}).Run()
+	if err != nil {
+		return "", profile, err
+	}
+
+	_, method, err := (&promptui.Select{
+		Label: "Authentication",
+		Items: []string{authPassword, authToken},
+	}).Run()
+	if err != nil {
+		return "", profile, err
+	}
+
+	notEmpty := func(input string) error {
+		if input == "" {
+			return errors.New("value is required")
+		}
+		return nil
+	}
+	profile.Username, err = (&promptui.Prompt{Label: "Username", Validate: notEmpty}).Run()
+	if err != nil {
+		return "", profile, err
+	}
+	if method == authToken {
+		profile.Token, err = (&promptui.Prompt{Label: "API token", Mask: '*', Validate: notEmpty}).Run()
+	} else {
+		profile.Password, err = (&promptui.Prompt{Label: "Password", Mask: '*', Validate: notEmpty}).Run()
+	}
+	if err != nil {
+		return "", profile, err
+	}
+
+	if strings.HasPrefix(profile.URL, "https://") {
+		_, version, err := (&promptui.Select{
+			Label: "Minimum TLS version",
+			Items: []string{internalHTTP.DefaultMinTLSVersion + " (default)", "1.3", "1.1", "1.0"},
+		}).Run()
+		if err != nil {
+			return "", profile, err
+		}
+		if !strings.HasSuffix(version, "(default)") {
+			profile.MinTLSVersion = version
+		}
+	}
+
+	return name, profile, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+func populatedConfig() *config.Config {
+	return &config.Config{
+		Profiles:       map[string]config.Profile{"local": {URL: "http://localhost:8000"}},
+		DefaultProfile: "local",
+	}
+}
+
+func TestAddProfileDefaultAssignment(t *testing.T) {
+	cases := []struct {
+		name        string
+		conf        *config.Config
+		setDefault  bool
+		noDefault   bool
+		wantDefault string
+	}{
+		{"empty config", &config.Config{}, false, false, "staging"},
+		{"empty config with --set-default", &config.Config{}, true, false, "staging"},
+		{"empty config with --no-default", &config.Config{}, false, true, ""},
+		{"populated config", populatedConfig(), false, false, "local"},
+		{"populated config with --set-default", populatedConfig(), true, false, "staging"},
+		{"populated config with --no-default", populatedConfig(), false, true, "local"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addProfile(c.conf, "staging", config.Profile{URL: "https://staging.example.com"}, c.setDefault, c.noDefault)
+
+			if _, ok := c.conf.Profiles["staging"]; !ok {
+				t.Error("expected profile to be added")
+			}
+			if c.conf.DefaultProfile != c.wantDefault {
+				t.Errorf("expected default %q, got %q", c.wantDefault, c.conf.DefaultProfile)
+			}
+		})
+	}
+}
+
+func TestAddProfileFlagsAreExclusive(t *testing.T) {
+	// keep the real config untouched should the command run
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	AddProfileCmd.SetArgs([]string{"staging", "https://staging.example.com", "admin", "admin", "--set-default", "--no-default"})
+	defer AddProfileCmd.SetArgs(nil)
+
+	if err := AddProfileCmd.Execute(); err == nil {
+		t.Error("expected --set-default and --no-default together to be rejected")
+	}
+}
+
+func TestUnsetDefaultProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := config.WriteConfigToFile(populatedConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	DefaultProfileCmd.SetArgs([]string{"--unset"})
+	defer DefaultProfileCmd.SetArgs(nil)
+	defer DefaultProfileCmd.Flags().Set(unsetDefaultFlag, "false")
+	if err := DefaultProfileCmd.Execute(); err != nil {
+		t.Fatalf("unset failed: %v", err)
+	}
+
+	conf, err := config.ReadConfigFromFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DefaultProfile != "" {
+		t.Errorf("expected no default profile, got %q", conf.DefaultProfile)
+	}
+	if _, ok := conf.Profiles["local"]; !ok {
+		t.Error("expected the profile itself to be kept")
+	}
+
+	err = PreRun()
+	if err == nil || !strings.Contains(err.Error(), "no default profile is set") || !strings.Contains(err.Error(), "local") {
+		t.Errorf("expected commands to ask for a profile, got %v", err)
+	}
+}
+
+func TestDefaultProfileMustExist(t *testing.T) {
+	conf := populatedConfig()
+	conf.DefaultProfile = "removed"
+	if _, err := conf.Default(); err == nil {
+		t.Error("expected a default naming a missing profile to be rejected")
+	}
+
+	conf.DefaultProfile = "local"
+	if profile, err := conf.Default(); err != nil || profile.URL != "http://localhost:8000" {
+		t.Errorf("expected the default profile, got %v, %v", profile, err)
+	}
+}
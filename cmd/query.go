@@ -17,18 +17,19 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
-	// "pb/pkg/model"
-
-	//! This dependency is required by the interactive flag Do not remove
-	// tea "github.com/charmbracelet/bubbletea"
 	internalHTTP "pb/pkg/http"
+	"pb/pkg/s3"
 
 	"github.com/spf13/cobra"
 )
@@ -43,13 +44,71 @@ var (
 	defaultEnd   = "now"
 
 	outputFlag = "output"
+
+	serverTimeoutFlag = "server-timeout"
+	outputFileFlag    = "output-file"
+	appendFlag        = "append"
 )
 
+// queryOptions holds the flags that control how a query is run and how its
+// results are written
+type queryOptions struct {
+	query        string
+	startTime    string
+	endTime      string
+	outputFormat string
+	outputFile   string
+	appendOutput bool
+	showStats    bool
+	pretty       bool
+	noColor      bool
+	limit        int
+	// offset skips rows before the page of limit rows, when paging is set
+	offset int
+	paging bool
+	// value prints the single value of a one row, one column result
+	value bool
+	// dedup drops rows with a key already written, see --deduplicate
+	dedup dedupOptions
+	// chunk splits the time range into windows of this size, queried one
+	// after the other, see --chunk, fetching up to concurrency of them at a
+	// time
+	chunk       time.Duration
+	concurrency int
+	// resume continues an interrupted chunked export, see --resume
+	resume bool
+	// preflight estimates the rows before downloading them, see --warn-over
+	preflight     preflightOptions
+	noGuard       bool
+	showTypes     bool
+	typesFile     string
+	checksum      bool
+	checksumAlgo  string
+	cellLayout    cellLayout
+	raw           bool
+	showHeaders   bool
+	outputURL     string
+	gzip          bool
+	objectStorage s3.Config
+	webhook       webhookOptions
+	flatten       flattenOptions
+	noAtomic      bool
+	cacheTTL      time.Duration
+	noCache       bool
+	verbose       bool
+	// jsonArray is set by --json-array, json output then stays a single
+	// array however large the result
+	jsonArray bool
+	// location is the --timezone timestamp columns are shown in, nil to
+	// leave them in UTC as sent by the server
+	location *time.Location
+}
+
 var query = &cobra.Command{
 	Use:     "run [query] [flags]",
-	Example: "  pb query run \"select * from frontend\" --from=10m --to=now",
+	Example: "  pb query run \"select * from frontend\" --from=10m --to=now\n  pb query run --file=report.sql --stop-on-error\n  pb query run \"select * from frontend\" --from=1d -i\n  pb query run --stream=frontend --group-by=status --agg=count --agg=avg:latency --bucket=1h --from=1d --verbose\n  pb query run \"select * from backend where user_id in (select id from flagged)\" --with flagged=flagged.ndjson\n  pb query run \"select host, count(*) from backend group by host\" --explain-plan-tree",
 	Short:   "Run SQL query on a log stream",
-	Long:    "\nRun SQL query on a log stream. Default output format is text. Use --output flag to set output format to json.",
+	Long:    "\nRun SQL query on a log stream. Default output format is text. Use --output flag to set output format to json, ndjson, csv, table or xlsx.\n\nxlsx writes an Excel workbook to --output-file, with numbers, booleans and timestamps as native Excel cells, a bold header row that stays in view, and nested fields as JSON strings.\n\nJSON output comes in two modes. --json-array writes a single JSON array that other tools can read in one go, but pb holds the whole result in memory to write it. --ndjson writes one record per line as it is read, so memory use stays flat for any result size. With -o json and --output-file, results of more than 100000 rows are written as ndjson with a warning, unless --json-array is passed.\n\nSeparate statements with semicolons to run several in order, each result block is labelled with its statement.",
 	Args:    cobra.MaximumNArgs(1),
 	PreRunE: PreRunDefaultProfile,
 	RunE: func(command *cobra.Command, args []string) error {
@@ -63,13 +122,70 @@ var query = &cobra.Command{
 			command.Annotations["executionTime"] = duration.String()
 		}()
 
-		if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		queryFile, _ := command.Flags().GetString(queryFileFlag)
+		var queryText string
+		switch {
+		case queryFile != "" && len(args) > 0:
+			err := fmt.Errorf("pass the query either as an argument or with --%s, not both", queryFileFlag)
+			command.Annotations["error"] = err.Error()
+			return err
+		case queryFile != "":
+			data, err := os.ReadFile(queryFile)
+			if err != nil {
+				command.Annotations["error"] = err.Error()
+				return fmt.Errorf("failed to read query file: %w", err)
+			}
+			queryText = string(data)
+		case len(args) > 0:
+			queryText = args[0]
+		}
+		if validate, _ := command.Flags().GetBool(validateSQLFlag); validate && strings.TrimSpace(queryText) != "" {
+			if err := checkSQLSyntax(queryText, os.Stderr); err != nil {
+				command.Annotations["error"] = err.Error()
+				return err
+			}
+		}
+
+		agg, err := parseAggregation(command.Flags())
+		if err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if agg != nil {
+			if strings.TrimSpace(queryText) != "" {
+				err := fmt.Errorf("pass either a query or --%s, --%s and --%s, not both", groupByFlag, aggFlag, bucketFlag)
+				command.Annotations["error"] = err.Error()
+				return err
+			}
+			// the schema is only used to catch mistakes early, the server
+			// reports them too when it cannot be fetched
+			client := internalHTTP.DefaultClient(&DefaultProfile)
+			schema, _ := fetchStreamSchema(&client, agg.stream)
+			if err := agg.validate(schema); err != nil {
+				command.Annotations["error"] = err.Error()
+				return err
+			}
+			queryText = agg.sql()
+		}
+
+		tables, err := readLocalTables(command.Flags())
+		if err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if queryText, err = applyLocalTables(queryText, tables); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+
+		statements := splitStatements(queryText)
+		if len(statements) == 0 {
 			fmt.Println("Please enter your query")
 			fmt.Printf("Example:\n  pb query run \"select * from frontend\" --from=10m --to=now\n")
 			return nil
 		}
 
-		query := args[0]
+		opts := queryOptions{query: statements[0]}
 		start, err := command.Flags().GetString(startFlag)
 		if err != nil {
 			command.Annotations["error"] = err.Error()
@@ -78,6 +194,7 @@ var query = &cobra.Command{
 		if start == "" {
 			start = defaultStart
 		}
+		opts.startTime = start
 
 		end, err := command.Flags().GetString(endFlag)
 		if err != nil {
@@ -87,15 +204,186 @@ var query = &cobra.Command{
 		if end == "" {
 			end = defaultEnd
 		}
+		opts.endTime = end
 
-		outputFormat, err := command.Flags().GetString("output")
+		opts.outputFormat, err = command.Flags().GetString("output")
 		if err != nil {
 			command.Annotations["error"] = err.Error()
 			return fmt.Errorf("failed to get 'output' flag: %w", err)
 		}
+		opts.outputFormat, opts.jsonArray, err = resolveJSONMode(command.Flags(), opts.outputFormat)
+		if err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+
+		serverTimeout, err := command.Flags().GetDuration(serverTimeoutFlag)
+		if err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateServerTimeout(serverTimeout); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+
+		opts.outputFile, _ = command.Flags().GetString(outputFileFlag)
+		opts.appendOutput, _ = command.Flags().GetBool(appendFlag)
+		opts.noAtomic, _ = command.Flags().GetBool(noAtomicFlag)
+		opts.cacheTTL, _ = command.Flags().GetDuration(cacheTTLFlag)
+		opts.noCache, _ = command.Flags().GetBool(noCacheFlag)
+		opts.verbose, _ = command.Flags().GetBool(verboseFlag)
+		if timezone, _ := command.Flags().GetString(timezoneFlag); timezone != "" {
+			if opts.location, err = parseTimezone(timezone); err != nil {
+				command.Annotations["error"] = err.Error()
+				return err
+			}
+		}
+		opts.showStats, _ = command.Flags().GetBool(statsFlag)
+		opts.pretty, _ = command.Flags().GetBool(prettyFlag)
+		opts.noColor, _ = command.Flags().GetBool(noColorFlag)
+		opts.limit, _ = command.Flags().GetInt(limitFlag)
+		opts.offset, _ = command.Flags().GetInt(offsetFlag)
+		opts.paging = command.Flags().Changed(offsetFlag)
+		opts.value, _ = command.Flags().GetBool(valueFlag)
+		opts.dedup.fields, _ = command.Flags().GetStringSlice(deduplicateFlag)
+		opts.dedup.window, _ = command.Flags().GetInt(dedupWindowFlag)
+		opts.chunk, _ = command.Flags().GetDuration(chunkFlag)
+		opts.concurrency, _ = command.Flags().GetInt(concurrencyFlag)
+		opts.resume, _ = command.Flags().GetBool(resumeFlag)
+		opts.preflight.warnOver, _ = command.Flags().GetInt64(warnOverFlag)
+		opts.preflight.force, _ = command.Flags().GetBool(forceQueryFlag)
+		opts.preflight.skip, _ = command.Flags().GetBool(noPreflightFlag)
+		opts.noGuard, _ = command.Flags().GetBool(noGuardFlag)
+		opts.showTypes, _ = command.Flags().GetBool(showTypesFlag)
+		opts.typesFile, _ = command.Flags().GetString(typesFileFlag)
+		opts.checksum, _ = command.Flags().GetBool(checksumFlag)
+		opts.checksumAlgo, _ = command.Flags().GetString(checksumAlgoFlag)
+		opts.cellLayout.maxWidth, _ = command.Flags().GetInt(maxColWidthFlag)
+		opts.cellLayout.wrap, _ = command.Flags().GetBool(wrapFlag)
+		opts.raw, _ = command.Flags().GetBool(rawFlag)
+		opts.showHeaders, _ = command.Flags().GetBool(showHeadersFlag)
+		opts.outputURL, _ = command.Flags().GetString(outputURLFlag)
+		opts.gzip, _ = command.Flags().GetBool(gzipFlag)
+		if opts.outputURL != "" {
+			opts.objectStorage = objectStorageConfig(command.Flags())
+		}
+		outputHeaders, _ := command.Flags().GetStringArray(outputHeaderFlag)
+		if opts.webhook.headers, err = parseOutputHeaders(outputHeaders); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		opts.webhook.batchSize, _ = command.Flags().GetInt(batchSizeFlag)
+		opts.flatten.enabled, _ = command.Flags().GetBool(flattenFlag)
+		opts.flatten.separator, _ = command.Flags().GetString(flattenSeparatorFlag)
+		opts.flatten.arrays, _ = command.Flags().GetBool(flattenArraysFlag)
+		if !opts.flatten.enabled && (command.Flags().Changed(flattenSeparatorFlag) || command.Flags().Changed(flattenArraysFlag)) {
+			err := fmt.Errorf("--%s and --%s require --%s", flattenSeparatorFlag, flattenArraysFlag, flattenFlag)
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateOutputFileOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validatePrettyOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateTableOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateRawOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateOutputURLOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateFlattenOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		interactive, _ := command.Flags().GetBool(interactiveFlag)
+		if err := validatePagingOptions(opts, len(statements), interactive); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateValueOptions(opts, len(statements)); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateDedupOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validatePreflightOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateChunkOptions(opts, len(statements), interactive); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+		if err := validateResumeOptions(opts); err != nil {
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+
+		if explain, _ := command.Flags().GetBool(explainPlanTreeFlag); explain {
+			err := validateExplainOptions(command.Flags(), len(statements))
+			if err == nil {
+				client := internalHTTP.DefaultClient(&DefaultProfile)
+				err = explainQuery(&client, opts.query, opts, os.Stdout)
+			}
+			if err != nil {
+				command.Annotations["error"] = err.Error()
+			}
+			return err
+		}
+
+		if interactive {
+			err := validateInteractiveOptions(opts, len(statements))
+			if err == nil && opts.verbose {
+				fmt.Fprintf(os.Stderr, "SQL: %s\n", opts.query)
+			}
+			if err == nil {
+				err = runInteractive(opts)
+			}
+			if err != nil {
+				command.Annotations["error"] = err.Error()
+			}
+			return err
+		}
 
 		client := internalHTTP.DefaultClient(&DefaultProfile)
-		err = fetchData(&client, query, start, end, outputFormat)
+
+		if opts.chunk > 0 {
+			err = fetchChunked(&client, opts)
+			if err != nil {
+				command.Annotations["error"] = err.Error()
+			}
+			return err
+		}
+
+		if len(statements) == 1 {
+			err = fetchData(&client, opts)
+			if err != nil {
+				command.Annotations["error"] = err.Error()
+			}
+			return err
+		}
+
+		if !writesToStdout(opts) {
+			err := fmt.Errorf("--%s and --%s cannot be used with multiple statements", outputFileFlag, outputURLFlag)
+			command.Annotations["error"] = err.Error()
+			return err
+		}
+
+		stopOnError, _ := command.Flags().GetBool(stopOnErrorFlag)
+		err = runStatements(&client, opts, statements, stopOnError)
 		if err != nil {
 			command.Annotations["error"] = err.Error()
 		}
@@ -103,79 +391,377 @@ var query = &cobra.Command{
 	},
 }
 
+// runStatements runs each statement in order, printing a label line before
+// its results. Failed statements are reported on stderr; with stopOnError
+// the remaining statements are skipped
+func runStatements(client *internalHTTP.HTTPClient, opts queryOptions, statements []string, stopOnError bool) error {
+	failed := 0
+	for idx, statement := range statements {
+		fmt.Printf("-- [%d/%d] %s\n", idx+1, len(statements), strings.Join(strings.Fields(statement), " "))
+
+		opts.query = statement
+		if err := fetchData(client, opts); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "statement %d failed: %s\n", idx+1, err)
+			if stopOnError {
+				return fmt.Errorf("stopped after statement %d of %d failed", idx+1, len(statements))
+			}
+		}
+		fmt.Println()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d statements failed", failed, len(statements))
+	}
+	return nil
+}
+
 func init() {
 	query.Flags().StringP(startFlag, startFlagShort, defaultStart, "Start time for query.")
 	query.Flags().StringP(endFlag, endFlagShort, defaultEnd, "End time for query.")
-	query.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (text|json)")
+	query.Flags().StringP("output", "o", "", "Output format (text|json|ndjson|csv|table|xlsx). xlsx requires --output-file")
+	query.Flags().Bool(jsonArrayFlag, false, "Write results as a single JSON array. The whole result is held in memory, even when it is large")
+	query.Flags().Bool(ndjsonFlag, false, "Write results as ndjson, one JSON record per line, as they are read. Memory use stays flat for any result size")
+	query.Flags().Duration(serverTimeoutFlag, 0, "Ask the server to cancel the query if it runs longer than this. Refused, as Parseable has no per-query execution limit")
+	query.Flags().String(outputFileFlag, "", "Write results to this file instead of stdout")
+	query.Flags().String(outputURLFlag, "", "Stream results to object storage, e.g. s3://bucket/exports/results.csv, or POST them to an http(s) URL instead of stdout")
+	query.Flags().StringArray(outputHeaderFlag, nil, "Header to send with each POST to an http(s) --output-url, as \"Name: value\". Repeat for several headers")
+	query.Flags().Int(batchSizeFlag, 0, "Rows per POST to an http(s) --output-url, 0 sends all rows in one request")
+	query.Flags().Bool(gzipFlag, false, "Compress results with gzip before uploading them to --output-url")
+	query.Flags().String(s3EndpointFlag, "", "Object storage endpoint for --output-url, e.g. http://localhost:9000 for MinIO (default AWS S3, or $AWS_ENDPOINT_URL)")
+	query.Flags().String(s3RegionFlag, "", "Object storage region for --output-url (default $AWS_REGION or us-east-1)")
+	query.Flags().String(s3AccessKeyFlag, "", "Access key for --output-url (default $AWS_ACCESS_KEY_ID)")
+	query.Flags().String(s3SecretKeyFlag, "", "Secret key for --output-url (default $AWS_SECRET_ACCESS_KEY)")
+	query.Flags().Bool(noAtomicFlag, false, "Write --output-file in place instead of replacing it once the results are complete, e.g. for a named pipe")
+	query.Flags().Bool(appendFlag, false, "Append results to --output-file instead of overwriting it (json, ndjson and csv only)")
+	query.Flags().Bool(checksumFlag, false, "Write a checksum of --output-file to a sidecar file next to it, e.g. results.csv.sha256")
+	query.Flags().String(checksumAlgoFlag, defaultChecksumAlgo, "Checksum algorithm for --checksum (sha256|sha512)")
+	query.Flags().Bool(statsFlag, false, "Print the rows and bytes returned and the round trip time to stderr after the results")
+	query.Flags().String(queryFileFlag, "", "Read the query from this file")
+	query.Flags().Bool(validateSQLFlag, false, "Check the query for syntax mistakes such as unclosed quotes or a comma before FROM before sending it. Syntax pb does not know is left to the server with a warning")
+	query.Flags().Bool(stopOnErrorFlag, false, "Stop at the first failed statement when running multiple statements")
+	query.Flags().Int(limitFlag, 0, "Add LIMIT to queries that have none")
+	query.Flags().Bool(valueFlag, false, "Print only the value of a result of one row and one column, for shell variables. Exits with 3 when the value is null")
+	query.Flags().StringSlice(deduplicateFlag, nil, "Drop rows whose value of this field, or these fields separated by commas, was already printed. Best-effort, done by pb on the results")
+	query.Flags().Int(dedupWindowFlag, 0, "Only remember this many recent distinct keys for --deduplicate, to cap memory on large results. 0 remembers all")
+	query.Flags().Duration(chunkFlag, 0, "Split the time range into windows of this size, e.g. 24h, and query them one after the other, for servers that limit the time range of a query")
+	query.Flags().Int(concurrencyFlag, 1, "Query up to this many --chunk windows at the same time. Rows are still written in time order")
+	query.Flags().Bool(resumeFlag, false, "Record finished --chunk windows next to --output-file, and continue an interrupted export from the first unfinished window (ndjson and csv only)")
+	query.Flags().Int64(warnOverFlag, 0, "Estimate the rows from the query plan and ask before downloading more than this many, or fail without a terminal. 0 disables the check")
+	query.Flags().Bool(forceQueryFlag, false, "Download results larger than --warn-over, or without an estimate, without asking")
+	query.Flags().Bool(noPreflightFlag, false, "Skip the row estimate of --warn-over, e.g. when it is set as a default")
+	query.Flags().Int(offsetFlag, 0, "Skip this many rows and return the next --limit rows, to page through results. The query must not have LIMIT or OFFSET")
+	query.Flags().Bool(noGuardFlag, false, "Do not warn about SELECT queries without LIMIT, aggregation or time condition")
+	query.Flags().Bool(showTypesFlag, false, "Show column types in the table header (table output only)")
+	query.Flags().Int(maxColWidthFlag, 0, "Truncate table cells to this many characters (table output only)")
+	query.Flags().Bool(wrapFlag, false, "Wrap table cells at --max-col-width instead of truncating them")
+	query.Flags().String(typesFileFlag, "", "Write column types as JSON to this file (json, ndjson and csv output only)")
+	query.Flags().Bool(rawFlag, false, "Print the response body exactly as the server sent it, including for failed requests")
+	query.Flags().Bool(showHeadersFlag, false, "Print the response status and headers to stderr")
+	query.Flags().Bool(prettyFlag, false, "Indent JSON results for reading (text and json output only)")
+	query.Flags().Bool(noColorFlag, false, "Disable syntax highlighting of --pretty output and of expensive plan nodes")
+	query.Flags().Bool(explainPlanTreeFlag, false, "Print the query plan as a tree instead of running the query, marking full scans and shuffles")
+	query.Flags().Bool(flattenFlag, false, "Flatten nested objects and arrays into dotted fields such as user.id and tags.0 (json, ndjson, csv, table and xlsx output only)")
+	query.Flags().String(flattenSeparatorFlag, defaultFlattenSeparator, "Separator between the keys of a flattened field")
+	query.Flags().Bool(flattenArraysFlag, true, "Expand arrays by index when flattening, set to false to keep arrays as JSON strings")
+	query.Flags().BoolP(interactiveFlag, "i", false, "Browse the results in an interactive table that loads rows page by page as you scroll")
+	query.Flags().Duration(cacheTTLFlag, 0, "Reuse the results of an identical query and time range run within this long, e.g. 10m. 0 disables the cache")
+	query.Flags().StringArray(withFlag, nil, "Filter against the values of a local NDJSON file, name=file.ndjson, used in the query as IN (SELECT column FROM name)")
+	query.Flags().String(aggStreamFlag, "", "Stream to query with --group-by, --agg and --bucket")
+	query.Flags().StringSlice(groupByFlag, nil, "Group rows by these fields instead of writing SQL, e.g. status,host")
+	query.Flags().StringArray(aggFlag, nil, "Aggregate to compute per group: count, count:field, sum:field, avg:field, min:field or max:field. Can be repeated, defaults to count")
+	query.Flags().Duration(bucketFlag, 0, "Group rows into time windows of this size, e.g. 5m or 1h")
+	query.Flags().Bool(verboseFlag, false, "Print the SQL sent to the server to stderr")
+	query.Flags().String(timezoneFlag, "", "Show timestamp columns in this time zone, an IANA name such as America/New_York, or local. Display only, --from and --to are not affected")
+	query.Flags().Bool(noCacheFlag, false, "Send the query to the server even when --cache-ttl is set, without reading or storing cached results")
 }
 
 var QueryCmd = query
 
-func fetchData(client *internalHTTP.HTTPClient, query string, startTime, endTime, outputFormat string) error {
-	queryTemplate := `{
-		"query": "%s",
-		"startTime": "%s",
-		"endTime": "%s"
-	}`
-	finalQuery := fmt.Sprintf(queryTemplate, query, startTime, endTime)
+func fetchData(client *internalHTTP.HTTPClient, opts queryOptions) (err error) {
+	if opts.paging {
+		opts.query = applyPage(opts.query, opts.limit, opts.offset)
+	} else {
+		opts.query = applyLimit(opts.query, opts.limit)
+	}
+	if opts.verbose {
+		fmt.Fprintf(os.Stderr, "SQL: %s\n", opts.query)
+	}
+	if !opts.noGuard && isUnboundedSelect(opts.query) {
+		fmt.Fprintf(os.Stderr, "Warning: this query has no LIMIT, aggregation or %s condition and may return every event between %s and %s. Add --%s to cap the rows, or --%s to silence this warning.\n", defaultTimeColumn, opts.startTime, opts.endTime, limitFlag, noGuardFlag)
+	}
 
-	req, err := client.NewRequest("POST", "query", bytes.NewBuffer([]byte(finalQuery)))
-	if err != nil {
-		return fmt.Errorf("failed to create new request: %w", err)
+	// the rows of a page are counted as they are written, so that the page
+	// window reports what the server returned
+	var page *queryStatsReader
+	countPage := func(r io.Reader) io.Reader {
+		if !opts.paging {
+			return r
+		}
+		page = &queryStatsReader{r: r}
+		return page
 	}
+	defer func() {
+		if err == nil && page != nil {
+			printPageWindow(os.Stderr, opts, page.rows)
+		}
+	}()
 
-	resp, err := client.Client.Do(req)
+	var cacheKey string
+	if usesQueryCache(opts) {
+		cacheKey = queryCacheKey(client.Profile, opts.query, opts.startTime, opts.endTime)
+		if body, ok := readCachedResult(cacheKey, opts.cacheTTL); ok {
+			return writeResults(client, countPage(bytes.NewReader(body)), opts)
+		}
+	}
+
+	if err := checkResultSize(client, opts, opts.startTime, opts.endTime, confirmLargeResult()); err != nil {
+		return err
+	}
+
+	requestStart := time.Now()
+	resp, err := sendQuery(context.Background(), client, opts, opts.startTime, opts.endTime)
 	if err != nil {
-		return fmt.Errorf("request execution failed: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
+	if opts.showHeaders {
+		writeResponseHeaders(os.Stderr, resp)
+	}
+
+	var body io.Reader = resp.Body
+	if opts.showStats {
+		stats := &queryStatsReader{r: resp.Body}
+		body = stats
+		defer func() {
+			if err == nil {
+				printQueryStats(os.Stderr, stats, time.Since(requestStart))
+			}
+		}()
+	}
+	body = countPage(body)
+
+	if opts.raw {
+		if resp.StatusCode != 200 {
+			io.Copy(rawErrorOutput(opts), resp.Body)
+			return fmt.Errorf("non-200 status code received: %s", resp.Status)
+		}
+		return writeText(body, opts)
+	}
+
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
 		fmt.Println(string(body))
-		return fmt.Errorf("non-200 status code received: %s", resp.Status)
+		return queryStatusError(resp, nil)
+	}
+
+	if cacheKey == "" {
+		return writeResults(client, body, opts)
+	}
+	cached, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if err := writeCachedResult(cacheKey, cached); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache query results: %s\n", err)
 	}
+	return writeResults(client, bytes.NewReader(cached), opts)
+}
 
-	if outputFormat == "json" {
-		var jsonResponse []map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&jsonResponse); err != nil {
-			return fmt.Errorf("error decoding JSON response: %w", err)
+// writeResults renders a successful query response body in the requested
+// format to stdout or the output destination
+func writeResults(client *internalHTTP.HTTPClient, body io.Reader, opts queryOptions) error {
+	if opts.value {
+		return writeScalar(os.Stdout, body, opts.location)
+	}
+	// pretty and text output write the response as the server sends it, so
+	// timestamps are converted in the response itself. Other output converts
+	// them row by row in its writer
+	passThrough := opts.pretty || ((opts.outputFormat == "" || opts.outputFormat == "text") && len(opts.dedup.fields) == 0)
+	if passThrough && opts.location != nil {
+		converted := convertResultTimezone(body, opts.location)
+		defer converted.Close()
+		body = converted
+	}
+	if opts.pretty {
+		return writePretty(body, opts)
+	}
+
+	if opts.outputFormat == "" || opts.outputFormat == "text" {
+		if len(opts.dedup.fields) == 0 {
+			return writeText(body, opts)
 		}
-		encodedResponse, _ := json.MarshalIndent(jsonResponse, "", "  ")
-		fmt.Println(string(encodedResponse))
-	} else {
-		io.Copy(os.Stdout, resp.Body)
+		// rows are decoded to drop duplicates, and written back as the
+		// JSON array the server sends
+		opts.outputFormat, opts.jsonArray = "json", true
+	}
+
+	if opts.appendOutput && opts.outputFormat != "ndjson" {
+		return appendResults(client, body, opts)
+	}
+
+	if isWebhookURL(opts.outputURL) {
+		writer := newWebhookResultWriter(opts.outputURL, opts.outputFormat, opts.webhook, os.Stderr)
+		return copyRecords(body, decorateResultWriter(writer, opts))
+	}
+
+	var out io.Writer = os.Stdout
+	var file outputSink
+	if opts.appendOutput {
+		appendFile, err := os.OpenFile(opts.outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer appendFile.Close()
+		out = appendFile
+	} else if !writesToStdout(opts) {
+		var err error
+		if file, err = openOutput(opts); err != nil {
+			return err
+		}
+		out = file
+	}
+
+	base, err := newResultWriter(out, opts.outputFormat, opts.showTypes, opts.cellLayout)
+	if err != nil {
+		if file != nil {
+			file.abort()
+		}
+		return err
+	}
+	// json written to a new file switches to ndjson when the result is
+	// large, unless --json-array asks for a single array
+	if opts.outputFormat == "json" && !opts.jsonArray && file != nil {
+		base = newAutoJSONResultWriter(out)
+	}
+
+	writer := base
+	var columns *columnsResultWriter
+	if opts.typesFile != "" {
+		columns = &columnsResultWriter{ResultWriter: writer}
+		writer = columns
+	}
+
+	err = copyRecords(body, decorateResultWriter(writer, opts))
+	if err == nil && columns != nil {
+		err = writeTypesFile(opts.typesFile, columns.columns(), columns.types)
+	}
+	if err != nil {
+		discardResults(base)
+		if file != nil {
+			file.abort()
+		}
+		return err
+	}
+	if file != nil {
+		return file.Close()
 	}
 	return nil
 }
 
-// Returns start and end time for query in RFC3339 format
-// func parseTime(start, end string) (time.Time, time.Time, error) {
-// 	if start == defaultStart && end == defaultEnd {
-// 		return time.Now().Add(-1 * time.Minute), time.Now(), nil
-// 	}
+// appendResults adds the results to an existing json or csv file. These
+// files are read back to stay valid, see appendRecordsToFile
+func appendResults(client *internalHTTP.HTTPClient, body io.Reader, opts queryOptions) error {
+	collector := &collectResultWriter{}
+	if err := copyRecords(body, decorateResultWriter(collector, opts)); err != nil {
+		return err
+	}
+	records := collector.rows
+	if opts.typesFile != "" {
+		if err := writeTypesFile(opts.typesFile, recordColumns(records), recordTypes(records)); err != nil {
+			return err
+		}
+	}
+	return appendRecordsToFile(opts.outputFile, records, opts.outputFormat)
+}
 
-// 	startTime, err := time.Parse(time.RFC3339, start)
-// 	if err != nil {
-// 		// try parsing as duration
-// 		duration, err := time.ParseDuration(start)
-// 		if err != nil {
-// 			return time.Time{}, time.Time{}, err
-// 		}
-// 		startTime = time.Now().Add(-1 * duration)
-// 	}
+// writeText copies the response body as is to stdout or the output
+// destination
+func writeText(body io.Reader, opts queryOptions) error {
+	if writesToStdout(opts) {
+		_, err := io.Copy(os.Stdout, body)
+		return err
+	}
+	file, err := openOutput(opts)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.abort()
+		return err
+	}
+	return file.Close()
+}
 
-// 	endTime, err := time.Parse(time.RFC3339, end)
-// 	if err != nil {
-// 		if end == "now" {
-// 			endTime = time.Now()
-// 		} else {
-// 			return time.Time{}, time.Time{}, err
-// 		}
-// 	}
+// queryRecords runs query over the given time range and returns the decoded
+// result rows
+func queryRecords(client *internalHTTP.HTTPClient, query, startTime, endTime string) ([]map[string]interface{}, error) {
+	resp, err := sendQuery(context.Background(), client, queryOptions{query: query}, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-// 	return startTime, endTime, nil
-// }
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, queryStatusError(resp, body)
+	}
+
+	var records []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("error decoding JSON response: %w", err)
+	}
+	return records, nil
+}
+
+// validateServerTimeout refuses --server-timeout. Parseable has no way to
+// cancel a query after a time limit, so the flag would only pretend to stop
+// an expensive query
+func validateServerTimeout(timeout time.Duration) error {
+	if timeout != 0 {
+		return fmt.Errorf("--%s is not supported, Parseable has no per-query execution limit and would run the query to the end. Narrow the time range or add a limit instead", serverTimeoutFlag)
+	}
+	return nil
+}
+
+// sendQuery posts the query for the time range and returns the response,
+// whatever its status. Cancelling ctx aborts the request
+func sendQuery(ctx context.Context, client *internalHTTP.HTTPClient, opts queryOptions, start, end string) (*http.Response, error) {
+	finalQuery, err := json.Marshal(map[string]string{
+		"query":     opts.query,
+		"startTime": start,
+		"endTime":   end,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := client.NewRequest(http.MethodPost, "query", bytes.NewBuffer(finalQuery))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new request: %w", err)
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, fmt.Errorf("client timed out after %s waiting for the server to respond", client.Client.Timeout)
+		}
+		return nil, fmt.Errorf("request execution failed: %w", err)
+	}
+	return resp, nil
+}
+
+// queryStatusError describes a query response with a status other than 200,
+// followed by the response body when it is given
+func queryStatusError(resp *http.Response, body []byte) error {
+	if body = bytes.TrimSpace(body); len(body) > 0 {
+		return fmt.Errorf("non-200 status code received: %s\n%s", resp.Status, body)
+	}
+	return fmt.Errorf("non-200 status code received: %s", resp.Status)
+}
 
 // // create a request body for saving filter without time_filter
 // func createFilter(query string, filterName string) (err error) {
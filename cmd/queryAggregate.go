@@ -0,0 +1,198 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	aggStreamFlag = "stream"
+	groupByFlag   = "group-by"
+	aggFlag       = "agg"
+	bucketFlag    = "bucket"
+	verboseFlag   = "verbose"
+
+	// aggFunctions maps each --agg function to whether it needs a field and
+	// whether the field must be numeric
+	aggFunctions = map[string]struct{ needsField, numeric bool }{
+		"count": {false, false},
+		"sum":   {true, true},
+		"avg":   {true, true},
+		"min":   {true, false},
+		"max":   {true, false},
+	}
+
+	// bucketColumn is the name of the time window column added by --bucket
+	bucketColumn = "bucket"
+)
+
+// aggregation is a query built from --group-by, --agg and --bucket instead of
+// SQL
+type aggregation struct {
+	stream  string
+	groupBy []string
+	aggs    []aggregate
+	bucket  time.Duration
+}
+
+// aggregate is one --agg value, e.g. avg:latency
+type aggregate struct {
+	function string
+	field    string
+}
+
+// alias names the result column of an aggregate, e.g. avg_latency
+func (a aggregate) alias() string {
+	if a.field == "" {
+		return a.function
+	}
+	return a.function + "_" + a.field
+}
+
+// parseAggregation reads the aggregation flags. It returns nil when none of
+// them is set, so the query is taken as SQL
+func parseAggregation(flags *pflag.FlagSet) (*aggregation, error) {
+	if !flags.Changed(groupByFlag) && !flags.Changed(aggFlag) && !flags.Changed(bucketFlag) {
+		if flags.Changed(aggStreamFlag) {
+			return nil, fmt.Errorf("--%s only applies with --%s, --%s or --%s", aggStreamFlag, groupByFlag, aggFlag, bucketFlag)
+		}
+		return nil, nil
+	}
+
+	agg := &aggregation{}
+	agg.stream, _ = flags.GetString(aggStreamFlag)
+	if agg.stream == "" {
+		return nil, fmt.Errorf("--%s, --%s and --%s need the stream to query, pass it with --%s", groupByFlag, aggFlag, bucketFlag, aggStreamFlag)
+	}
+	agg.groupBy, _ = flags.GetStringSlice(groupByFlag)
+	for _, field := range agg.groupBy {
+		if strings.TrimSpace(field) == "" {
+			return nil, fmt.Errorf("--%s has an empty field name", groupByFlag)
+		}
+	}
+
+	values, _ := flags.GetStringArray(aggFlag)
+	if len(values) == 0 {
+		values = []string{"count"}
+	}
+	for _, value := range values {
+		parsed, err := parseAggregate(value)
+		if err != nil {
+			return nil, err
+		}
+		agg.aggs = append(agg.aggs, parsed)
+	}
+
+	agg.bucket, _ = flags.GetDuration(bucketFlag)
+	if flags.Changed(bucketFlag) && (agg.bucket < time.Second || agg.bucket%time.Second != 0) {
+		return nil, fmt.Errorf("--%s must be a whole number of seconds, e.g. 5m or 1h, got %s", bucketFlag, agg.bucket)
+	}
+	return agg, nil
+}
+
+// parseAggregate parses an --agg value of the form function or
+// function:field
+func parseAggregate(value string) (aggregate, error) {
+	function, field, _ := strings.Cut(strings.TrimSpace(value), ":")
+	function = strings.ToLower(function)
+	spec, ok := aggFunctions[function]
+	if !ok {
+		return aggregate{}, fmt.Errorf("unsupported --%s %q, use count, count:field, sum:field, avg:field, min:field or max:field", aggFlag, value)
+	}
+	if spec.needsField && field == "" {
+		return aggregate{}, fmt.Errorf("--%s %s needs a field, e.g. %s:latency", aggFlag, function, function)
+	}
+	return aggregate{function: function, field: field}, nil
+}
+
+// validate checks the fields against the stream schema. A nil schema, when
+// it could not be fetched, skips the check and leaves it to the server
+func (agg *aggregation) validate(schema map[string]schemaField) error {
+	if schema == nil {
+		return nil
+	}
+	for _, field := range agg.groupBy {
+		if _, ok := schema[field]; !ok {
+			return fmt.Errorf("stream %s has no field %s to group by", agg.stream, field)
+		}
+	}
+	for _, a := range agg.aggs {
+		if a.field == "" {
+			continue
+		}
+		field, ok := schema[a.field]
+		if !ok {
+			return fmt.Errorf("stream %s has no field %s for --%s %s:%s", agg.stream, a.field, aggFlag, a.function, a.field)
+		}
+		if aggFunctions[a.function].numeric && !isNumericType(field.Type) {
+			return fmt.Errorf("%s needs a numeric field, %s is %s", a.function, a.field, columnTypeName(field.Type))
+		}
+	}
+	return nil
+}
+
+// isNumericType reports whether an arrow type name holds numbers
+func isNumericType(arrowType string) bool {
+	name := strings.ToLower(arrowType)
+	for _, prefix := range []string{"int", "uint", "float", "decimal"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sql builds the statement for the aggregation. Rows are ordered by time
+// window when bucketed, and by the first aggregate otherwise, largest first
+func (agg *aggregation) sql() string {
+	var columns, groups []string
+	if agg.bucket > 0 {
+		columns = append(columns, fmt.Sprintf("date_bin(interval '%d seconds', %s, timestamp '1970-01-01T00:00:00') as %s", int64(agg.bucket.Seconds()), defaultTimeColumn, bucketColumn))
+		groups = append(groups, bucketColumn)
+	}
+	for _, field := range agg.groupBy {
+		columns = append(columns, quoteIdentifier(field))
+		groups = append(groups, quoteIdentifier(field))
+	}
+	for _, a := range agg.aggs {
+		arg := "*"
+		if a.field != "" {
+			arg = quoteIdentifier(a.field)
+		}
+		columns = append(columns, fmt.Sprintf("%s(%s) as %s", a.function, arg, quoteIdentifier(a.alias())))
+	}
+
+	query := fmt.Sprintf("select %s from %s", strings.Join(columns, ", "), quoteIdentifier(agg.stream))
+	if len(groups) > 0 {
+		query += " group by " + strings.Join(groups, ", ")
+	}
+	if agg.bucket > 0 {
+		query += " order by " + bucketColumn
+	} else {
+		query += " order by " + quoteIdentifier(agg.aggs[0].alias()) + " desc"
+	}
+	return query
+}
+
+// quoteIdentifier quotes a field or stream name for SQL
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func parseAggregationArgs(t *testing.T, args ...string) (*aggregation, error) {
+	flags := pflag.NewFlagSet("query", pflag.ContinueOnError)
+	flags.String(aggStreamFlag, "", "")
+	flags.StringSlice(groupByFlag, nil, "")
+	flags.StringArray(aggFlag, nil, "")
+	flags.Duration(bucketFlag, 0, "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return parseAggregation(flags)
+}
+
+func TestAggregationSQL(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{
+			[]string{"--stream=app", "--group-by=status"},
+			`select "status", count(*) as "count" from "app" group by "status" order by "count" desc`,
+		},
+		{
+			[]string{"--stream=app", "--group-by=status,host", "--agg=avg:latency", "--agg=count"},
+			`select "status", "host", avg("latency") as "avg_latency", count(*) as "count" from "app" group by "status", "host" order by "avg_latency" desc`,
+		},
+		{
+			[]string{"--stream=app", "--bucket=1h", "--agg=sum:bytes"},
+			`select date_bin(interval '3600 seconds', p_timestamp, timestamp '1970-01-01T00:00:00') as bucket, sum("bytes") as "sum_bytes" from "app" group by bucket order by bucket`,
+		},
+	}
+
+	for _, c := range cases {
+		agg, err := parseAggregationArgs(t, c.args...)
+		if err != nil {
+			t.Fatalf("%v: %v", c.args, err)
+		}
+		if got := agg.sql(); got != c.want {
+			t.Errorf("%v:\nwant %s\ngot  %s", c.args, c.want, got)
+		}
+	}
+}
+
+func TestAggregationFlagErrors(t *testing.T) {
+	cases := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"--group-by=status"}, "--stream"},
+		{[]string{"--stream=app", "--agg=median:latency"}, "unsupported --agg"},
+		{[]string{"--stream=app", "--agg=avg"}, "needs a field"},
+		{[]string{"--stream=app", "--bucket=1500ms"}, "whole number of seconds"},
+		{[]string{"--stream=app"}, "only applies"},
+	}
+	for _, c := range cases {
+		_, err := parseAggregationArgs(t, c.args...)
+		if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%v: expected error containing %q, got %v", c.args, c.wantErr, err)
+		}
+	}
+
+	if agg, err := parseAggregationArgs(t); agg != nil || err != nil {
+		t.Errorf("expected no aggregation without flags, got %v, %v", agg, err)
+	}
+}
+
+func TestAggregationValidatesFieldTypes(t *testing.T) {
+	schema := map[string]schemaField{
+		"status":  {Name: "status", Type: "Utf8"},
+		"latency": {Name: "latency", Type: "Float64"},
+	}
+
+	agg, _ := parseAggregationArgs(t, "--stream=app", "--group-by=status", "--agg=avg:latency", "--agg=max:status")
+	if err := agg.validate(schema); err != nil {
+		t.Errorf("expected a valid aggregation, got %v", err)
+	}
+
+	agg, _ = parseAggregationArgs(t, "--stream=app", "--agg=avg:status")
+	if err := agg.validate(schema); err == nil || !strings.Contains(err.Error(), "avg needs a numeric field, status is string") {
+		t.Errorf("expected avg on a string field to be rejected, got %v", err)
+	}
+
+	agg, _ = parseAggregationArgs(t, "--stream=app", "--group-by=region")
+	if err := agg.validate(schema); err == nil || !strings.Contains(err.Error(), "no field region") {
+		t.Errorf("expected an unknown field to be rejected, got %v", err)
+	}
+
+	if err := agg.validate(nil); err != nil {
+		t.Errorf("expected validation to be skipped without a schema, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var noAtomicFlag = "no-atomic"
+
+// atomicFile collects results in a temporary file next to the target and only
+// replaces the target once the results are complete, so a failed or
+// interrupted query never leaves a partial file for downstream jobs
+type atomicFile struct {
+	*os.File
+	target string
+}
+
+// createAtomic creates the temporary file for target in the same directory,
+// so the final rename stays on one filesystem
+func createAtomic(target string) (*atomicFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: file, target: target}, nil
+}
+
+// commit closes the temporary file and renames it over the target. An
+// existing target keeps its permissions
+func (f *atomicFile) commit() error {
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(f.target); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(f.File.Name(), mode); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	if err := os.Rename(f.File.Name(), f.target); err != nil {
+		os.Remove(f.File.Name())
+		return fmt.Errorf("failed to replace %s: %w", f.target, err)
+	}
+	return nil
+}
+
+// discard removes the temporary file and leaves the target untouched
+func (f *atomicFile) discard() {
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
+
+// canReplaceAtomically reports whether path can be replaced by a rename.
+// Named pipes and devices such as /dev/stdout must be written in place
+func canReplaceAtomically(path string) bool {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// failingReader returns some data and then fails, like a response body cut
+// off by a dropped connection
+type failingReader struct{ sent bool }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.sent {
+		return 0, errors.New("connection reset")
+	}
+	r.sent = true
+	return copy(p, `[{"host":"a"},{"ho`), nil
+}
+
+func TestFailedWriteLeavesTargetUntouched(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.json")
+	if err := os.WriteFile(path, []byte("previous results\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := queryOptions{outputFile: path, outputFormat: "json"}
+	if err := writeText(&failingReader{}, opts); err == nil {
+		t.Fatal("expected the interrupted write to fail")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "previous results\n" {
+		t.Errorf("expected the target to be untouched, got %q, %v", data, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected the temporary file to be removed, found %d files", len(entries))
+	}
+}
+
+func TestCompletedWriteReplacesTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	if err := os.WriteFile(path, []byte("old\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := createOutputFile(queryOptions{outputFile: path, outputFormat: "csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRecords(file, firstBatch, "csv", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is visible at the target until the results are complete
+	if data, _ := os.ReadFile(path); string(data) != "old\n" {
+		t.Errorf("expected the target to keep its content while writing, got %q", data)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "host,status\na,200\n" {
+		t.Errorf("unexpected results %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o640 {
+		t.Errorf("expected the file mode to be kept, got %04o", info.Mode().Perm())
+	}
+}
+
+func TestNoAtomicWritesInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	file, err := createOutputFile(queryOptions{outputFile: path, outputFormat: "csv", noAtomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if file.file.Name() != path {
+		t.Errorf("expected --no-atomic to write to %s, got %s", path, file.file.Name())
+	}
+}
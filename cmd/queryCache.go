@@ -0,0 +1,186 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pb/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheTTLFlag = "cache-ttl"
+	noCacheFlag  = "no-cache"
+
+	// queryCacheFileMode keeps cached results, which may hold sensitive
+	// events, private to the user
+	queryCacheFileMode os.FileMode = 0o600
+)
+
+// usesQueryCache reports whether the results of a query are read from and
+// stored in the cache. Raw responses, headers and statistics describe the
+// server response itself, so they always go to the server
+func usesQueryCache(opts queryOptions) bool {
+	return opts.cacheTTL > 0 && !opts.noCache && !opts.raw && !opts.showHeaders && !opts.showStats
+}
+
+// queryCacheKey identifies a query result by the server, the user, the query
+// and the time range exactly as given, so relative ranges such as 10m hit
+// the cache until the entry expires
+func queryCacheKey(profile *config.Profile, query, startTime, endTime string) string {
+	hash := sha256.New()
+	for _, part := range []string{profile.URL, profile.Username, query, startTime, endTime} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func queryCachePath(key string) (string, error) {
+	dir, err := config.QueryCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, key+".json"), nil
+}
+
+// readCachedResult returns the cached response body for key when it was
+// stored less than ttl ago. Expired entries are removed
+func readCachedResult(key string, ttl time.Duration) ([]byte, bool) {
+	path, err := queryCachePath(key)
+	if err != nil {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if time.Since(info.ModTime()) > ttl {
+		os.Remove(path)
+		return nil, false
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// writeCachedResult stores a response body for key. The entry is written to
+// a temporary file first so a concurrent run never reads a partial result
+func writeCachedResult(key string, body []byte) error {
+	path, err := queryCachePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(body); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Chmod(file.Name(), queryCacheFileMode); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+// clearQueryCache removes every cached result and returns how many were
+// removed
+func clearQueryCache() (int, error) {
+	dir, err := config.QueryCacheDir()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return removed, err
+		}
+		if strings.HasSuffix(entry.Name(), ".json") {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// QueryCacheCmd is the parent command for the query result cache
+var QueryCacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage cached query results",
+	Long:  "\nManage query results cached by pb query run --cache-ttl.",
+}
+
+// ClearQueryCacheCmd removes all cached query results
+var ClearQueryCacheCmd = &cobra.Command{
+	Use:     "clear",
+	Example: "  pb query cache clear",
+	Short:   "Remove all cached query results",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		removed, err := clearQueryCache()
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return fmt.Errorf("failed to clear the query cache: %w", err)
+		}
+		fmt.Printf("Removed %d cached query results\n", removed)
+		return nil
+	},
+}
+
+func init() {
+	QueryCacheCmd.AddCommand(ClearQueryCacheCmd)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func queryCacheServer(t *testing.T, calls *int32) *httptest.Server {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Write([]byte(`[{"host":"a"}]`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func cachedQueryOptions(t *testing.T) queryOptions {
+	return queryOptions{
+		query:        "select host from app limit 1",
+		startTime:    "10m",
+		endTime:      "now",
+		outputFormat: "json",
+		outputFile:   filepath.Join(t.TempDir(), "results.json"),
+		cacheTTL:     time.Minute,
+	}
+}
+
+func TestIdenticalQueryWithinTTLIsServedFromCache(t *testing.T) {
+	var calls int32
+	server := queryCacheServer(t, &calls)
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	opts := cachedQueryOptions(t)
+
+	for run := 0; run < 2; run++ {
+		if err := fetchData(&client, opts); err != nil {
+			t.Fatalf("run %d failed: %v", run, err)
+		}
+		data, _ := os.ReadFile(opts.outputFile)
+		if string(data) != "[\n  {\n    \"host\": \"a\"\n  }\n]\n" {
+			t.Errorf("run %d wrote unexpected results %q", run, data)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected the second query to make no HTTP call, got %d calls", n)
+	}
+}
+
+func TestQueryCacheMisses(t *testing.T) {
+	var calls int32
+	server := queryCacheServer(t, &calls)
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	opts := cachedQueryOptions(t)
+
+	if err := fetchData(&client, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := opts
+	changed.endTime = "1m"
+	if err := fetchData(&client, changed); err != nil {
+		t.Fatal(err)
+	}
+
+	bypass := opts
+	bypass.noCache = true
+	if err := fetchData(&client, bypass); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected a different time range and --no-cache to reach the server, got %d calls", n)
+	}
+}
+
+func TestExpiredCacheEntryIsRefetched(t *testing.T) {
+	var calls int32
+	server := queryCacheServer(t, &calls)
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	opts := cachedQueryOptions(t)
+
+	if err := fetchData(&client, opts); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := queryCachePath(queryCacheKey(client.Profile, opts.query, opts.startTime, opts.endTime))
+	old := time.Now().Add(-2 * opts.cacheTTL)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchData(&client, opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected an expired entry to be refetched, got %d calls", n)
+	}
+
+	removed, err := clearQueryCache()
+	if err != nil || removed != 1 {
+		t.Errorf("expected one entry to be cleared, got %d, %v", removed, err)
+	}
+}
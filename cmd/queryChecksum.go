@@ -0,0 +1,125 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	checksumFlag     = "checksum"
+	checksumAlgoFlag = "checksum-algo"
+
+	defaultChecksumAlgo = "sha256"
+)
+
+// newChecksumHash returns the hash for a --checksum-algo value
+func newChecksumHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q, use sha256 or sha512", algo)
+}
+
+// outputFile is a results file that hashes everything written to it when a
+// checksum was requested, so the sidecar needs no second pass over the data.
+// Unless --no-atomic is set, results go to a temporary file that replaces
+// the target on Close
+type outputFile struct {
+	file   *os.File
+	atomic *atomicFile
+	path   string
+	w      io.Writer
+	hash   hash.Hash
+	algo   string
+}
+
+// createOutputFile creates the --output-file for writing
+func createOutputFile(opts queryOptions) (*outputFile, error) {
+	out := &outputFile{path: opts.outputFile}
+	var err error
+	if !opts.noAtomic && canReplaceAtomically(opts.outputFile) {
+		out.atomic, err = createAtomic(opts.outputFile)
+		if out.atomic != nil {
+			out.file = out.atomic.File
+		}
+	} else {
+		out.file, err = os.Create(opts.outputFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	out.w = out.file
+	if opts.checksum {
+		out.algo = opts.checksumAlgo
+		out.hash, err = newChecksumHash(opts.checksumAlgo)
+		if err != nil {
+			out.abort()
+			return nil, err
+		}
+		out.w = io.MultiWriter(out.file, out.hash)
+	}
+	return out, nil
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	return f.w.Write(p)
+}
+
+// Close closes the results file and then writes the checksum sidecar, named
+// after the file with the algorithm as extension, in the format read by
+// sha256sum -c and sha512sum -c
+func (f *outputFile) Close() error {
+	if f.atomic != nil {
+		if err := f.atomic.commit(); err != nil {
+			return err
+		}
+	} else if err := f.file.Close(); err != nil {
+		return err
+	}
+	if f.hash == nil {
+		return nil
+	}
+
+	sum := hex.EncodeToString(f.hash.Sum(nil))
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(f.path))
+	if err := os.WriteFile(f.path+"."+f.algo, []byte(line), 0o644); err != nil {
+		return fmt.Errorf("failed to write checksum file: %w", err)
+	}
+	return nil
+}
+
+// abort closes the results file without writing a checksum, so a failed
+// export is never vouched for by a sidecar. An atomic write is discarded and
+// leaves the target as it was
+func (f *outputFile) abort() {
+	if f.atomic != nil {
+		f.atomic.discard()
+		return
+	}
+	f.file.Close()
+}
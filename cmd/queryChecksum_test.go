@@ -0,0 +1,77 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksumSidecarMatchesOutput(t *testing.T) {
+	for _, algo := range []string{"sha256", "sha512"} {
+		path := filepath.Join(t.TempDir(), "results.csv")
+		opts := queryOptions{outputFile: path, outputFormat: "csv", checksum: true, checksumAlgo: algo}
+
+		file, err := createOutputFile(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeRecords(file, append(firstBatch, secondBatch...), "csv", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected string
+		if algo == "sha256" {
+			sum := sha256.Sum256(data)
+			expected = hex.EncodeToString(sum[:])
+		} else {
+			sum := sha512.Sum512(data)
+			expected = hex.EncodeToString(sum[:])
+		}
+
+		sidecar, err := os.ReadFile(path + "." + algo)
+		if err != nil {
+			t.Fatalf("expected a %s sidecar: %v", algo, err)
+		}
+		if string(sidecar) != expected+"  results.csv\n" {
+			t.Errorf("%s sidecar %q does not match output hash %s", algo, sidecar, expected)
+		}
+	}
+}
+
+func TestChecksumRejectsAppend(t *testing.T) {
+	err := validateOutputFileOptions(queryOptions{outputFile: "out.csv", outputFormat: "csv", appendOutput: true, checksum: true, checksumAlgo: "sha256"})
+	if err == nil || !strings.Contains(err.Error(), "--checksum") {
+		t.Errorf("expected --checksum with --append to be rejected, got %v", err)
+	}
+
+	err = validateOutputFileOptions(queryOptions{outputFile: "out.csv", outputFormat: "csv", checksum: true, checksumAlgo: "md5"})
+	if err == nil {
+		t.Error("expected an unsupported algorithm to be rejected")
+	}
+}
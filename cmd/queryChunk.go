@@ -0,0 +1,328 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+)
+
+var (
+	chunkFlag       = "chunk"
+	concurrencyFlag = "concurrency"
+)
+
+// errChunkLimit stops reading chunks once --limit rows have been written
+var errChunkLimit = errors.New("limit reached")
+
+// queryWindow is the time range of one chunk
+type queryWindow struct {
+	start, end time.Time
+}
+
+// validateChunkOptions checks that --chunk is combined only with options
+// that work on a result made of several responses
+func validateChunkOptions(opts queryOptions, statements int, interactive bool) error {
+	switch {
+	case opts.chunk == 0:
+		if opts.concurrency > 1 {
+			return fmt.Errorf("--%s requires --%s", concurrencyFlag, chunkFlag)
+		}
+		return nil
+	case opts.chunk < time.Second:
+		return fmt.Errorf("--%s must be at least 1s, got %s", chunkFlag, opts.chunk)
+	case statements > 1:
+		return fmt.Errorf("--%s runs a single statement, %d were given", chunkFlag, statements)
+	case opts.concurrency < 1:
+		return fmt.Errorf("--%s must be at least 1, got %d", concurrencyFlag, opts.concurrency)
+	case interactive || opts.paging || opts.raw || opts.value || opts.showHeaders || opts.showStats:
+		return fmt.Errorf("--%s cannot be combined with --%s, --%s, --%s, --%s, --%s or --%s", chunkFlag, interactiveFlag, offsetFlag, rawFlag, valueFlag, showHeadersFlag, statsFlag)
+	}
+	return nil
+}
+
+// chunkWindows splits [start, end) into consecutive windows of size, the last
+// one ending at end. Neighbouring windows share their boundary, so no time is
+// left out between them
+func chunkWindows(start, end time.Time, size time.Duration) []queryWindow {
+	var windows []queryWindow
+	for from := start; from.Before(end); from = from.Add(size) {
+		to := from.Add(size)
+		if to.After(end) {
+			to = end
+		}
+		windows = append(windows, queryWindow{start: from, end: to})
+	}
+	return windows
+}
+
+// fetchChunked runs the query once per --chunk window, oldest first, and
+// writes the rows of all windows as one result
+func fetchChunked(client *internalHTTP.HTTPClient, opts queryOptions) error {
+	start, end, err := parseTime(opts.startTime, opts.endTime)
+	if err != nil {
+		return err
+	}
+	windows := chunkWindows(start.UTC().Truncate(time.Second), end.UTC().Truncate(time.Second), opts.chunk)
+	if len(windows) == 0 {
+		return fmt.Errorf("--%s needs a start time before the end time, got %s to %s", chunkFlag, opts.startTime, opts.endTime)
+	}
+
+	opts.query = applyLimit(opts.query, opts.limit)
+	if opts.verbose {
+		fmt.Fprintf(os.Stderr, "SQL: %s\n", opts.query)
+	}
+	last := windows[len(windows)-1]
+	if err := checkResultSize(client, opts, windows[0].start.Format(time.RFC3339), last.end.Format(time.RFC3339), confirmLargeResult()); err != nil {
+		return err
+	}
+
+	if opts.resume {
+		return resumeExport(client, opts, windows[0].start, last.end, os.Stderr)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(streamChunks(client, opts, windows, writer, os.Stderr))
+	}()
+	defer reader.Close()
+	return writeResults(client, reader, opts)
+}
+
+// streamChunks queries the windows and writes the rows to w as a single JSON
+// array in window order, reporting progress to progress. Up to
+// opts.concurrency windows are fetched at a time. A row on the boundary
+// between two windows that the server returns for both is written once
+func streamChunks(client *internalHTTP.HTTPClient, opts queryOptions, windows []queryWindow, w io.Writer, progress io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	// cancelling aborts the windows still being fetched when the result
+	// ends early, on an error or at --limit
+	ctx, cancel := context.WithCancel(context.Background())
+	fetches := fetchChunks(ctx, client, opts, windows)
+	read := 0
+	defer func() {
+		cancel()
+		fetches.discard(read)
+	}()
+
+	state := chunkState{limit: opts.limit}
+	for idx, window := range windows {
+		if state.full() {
+			break
+		}
+		chunk := <-fetches.chunks[idx]
+		read++
+		if chunk.err != nil {
+			return chunkError(idx, len(windows), window, chunk.err)
+		}
+
+		rows, skipped, err := state.writeChunk(chunk.body, window, func(encoded []byte) error {
+			if state.written > 0 {
+				encoded = append([]byte(","), encoded...)
+			}
+			_, err := w.Write(encoded)
+			return err
+		})
+		chunk.body.Close()
+		chunk.release()
+		if err != nil {
+			return err
+		}
+		printChunkProgress(progress, idx, len(windows), window, rows, skipped)
+	}
+
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// chunkState carries what the rows of a window depend on from the windows
+// written before it
+type chunkState struct {
+	// limit is the --limit across all windows, 0 for none
+	limit   int
+	written int
+	// boundary holds the rows of the previous window on its end time, so
+	// that the next window does not write them again
+	boundary map[string]struct{}
+}
+
+// full reports whether --limit rows have been written
+func (c *chunkState) full() bool {
+	return c.limit > 0 && c.written >= c.limit
+}
+
+// writeChunk decodes the rows of one window from body and passes each row
+// to write as JSON, skipping rows the previous window already wrote and
+// stopping at the limit. It returns the number of rows written and skipped
+func (c *chunkState) writeChunk(body io.Reader, window queryWindow, write func([]byte) error) (rows, skipped int, err error) {
+	atEnd := make(map[string]struct{})
+	err = decodeRecords(body, func(record map[string]interface{}) error {
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if ts, ok := recordTimestamp(record); ok {
+			if _, seen := c.boundary[string(encoded)]; seen && ts.Equal(window.start) {
+				skipped++
+				return nil
+			}
+			if ts.Equal(window.end) {
+				atEnd[string(encoded)] = struct{}{}
+			}
+		}
+		if c.full() {
+			return errChunkLimit
+		}
+		if err := write(encoded); err != nil {
+			return err
+		}
+		c.written++
+		rows++
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChunkLimit) {
+		return rows, skipped, err
+	}
+	c.boundary = atEnd
+	return rows, skipped, nil
+}
+
+// chunkError names the window a failed query was for
+func chunkError(idx, total int, window queryWindow, err error) error {
+	return fmt.Errorf("chunk %d of %d, %s to %s: %w", idx+1, total, window.start.Format(time.RFC3339), window.end.Format(time.RFC3339), err)
+}
+
+// printChunkProgress reports a written window
+func printChunkProgress(progress io.Writer, idx, total int, window queryWindow, rows, skipped int) {
+	fmt.Fprintf(progress, "Chunk %d/%d, %s to %s: %d rows", idx+1, total, window.start.Format(time.RFC3339), window.end.Format(time.RFC3339), rows)
+	if skipped > 0 {
+		fmt.Fprintf(progress, ", %d already written by the previous chunk", skipped)
+	}
+	fmt.Fprintln(progress)
+}
+
+// fetchedChunk is the response to the query of one window. release frees
+// its slot so that the next window can be fetched
+type fetchedChunk struct {
+	body    io.ReadCloser
+	err     error
+	release func()
+}
+
+// chunkFetches holds one channel per window that its response is sent on
+type chunkFetches struct {
+	chunks []chan fetchedChunk
+	// running counts the goroutine starting fetches and the fetches
+	running sync.WaitGroup
+}
+
+// discard closes the responses of the windows from index from onward, which
+// are never read. ctx must be cancelled first, so that the fetches still
+// running end
+func (f *chunkFetches) discard(from int) {
+	f.running.Wait()
+	for _, chunk := range f.chunks[from:] {
+		select {
+		case fetched := <-chunk:
+			if fetched.body != nil {
+				fetched.body.Close()
+			}
+		default:
+		}
+	}
+}
+
+// fetchChunks starts fetching the windows in order, at most opts.concurrency
+// at a time. A window is only fetched once an earlier one has been released,
+// so at most opts.concurrency responses are held at a time. With more than
+// one worker, responses are read into memory at once, so the client timeout
+// does not run while they wait for their turn. Cancelling ctx stops fetching
+func fetchChunks(ctx context.Context, client *internalHTTP.HTTPClient, opts queryOptions, windows []queryWindow) *chunkFetches {
+	concurrency := opts.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	fetches := &chunkFetches{chunks: make([]chan fetchedChunk, len(windows))}
+	for idx := range fetches.chunks {
+		fetches.chunks[idx] = make(chan fetchedChunk, 1)
+	}
+
+	slots := make(chan struct{}, concurrency)
+	release := func() { <-slots }
+	fetches.running.Add(1)
+	go func() {
+		defer fetches.running.Done()
+		for idx, window := range windows {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			fetches.running.Add(1)
+			go func(idx int, window queryWindow) {
+				defer fetches.running.Done()
+				body, err := fetchChunk(ctx, client, opts, window)
+				if err == nil && concurrency > 1 {
+					var data []byte
+					data, err = io.ReadAll(body)
+					body.Close()
+					body = io.NopCloser(bytes.NewReader(data))
+				}
+				if err != nil {
+					body = nil
+				}
+				fetches.chunks[idx] <- fetchedChunk{body: body, err: err, release: release}
+			}(idx, window)
+		}
+	}()
+	return fetches
+}
+
+// fetchChunk sends the query for one window and returns the response body
+func fetchChunk(ctx context.Context, client *internalHTTP.HTTPClient, opts queryOptions, window queryWindow) (io.ReadCloser, error) {
+	resp, err := sendQuery(ctx, client, opts, window.start.Format(time.RFC3339), window.end.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, queryStatusError(resp, body)
+	}
+	return resp.Body, nil
+}
+
+// recordTimestamp returns the p_timestamp of a result row
+func recordTimestamp(record map[string]interface{}) (time.Time, bool) {
+	value, ok := record[defaultTimeColumn].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	return parseEventTime(value)
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+// windowCappedServer answers queries with the events between startTime and
+// endTime, both inclusive, and rejects time ranges longer than a day
+func windowCappedServer(t *testing.T, events []string, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		start, err1 := time.Parse(time.RFC3339, body["startTime"])
+		end, err2 := time.Parse(time.RFC3339, body["endTime"])
+		if err1 != nil || err2 != nil {
+			t.Errorf("unexpected time range %q to %q", body["startTime"], body["endTime"])
+		}
+		if end.Sub(start) > 24*time.Hour {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("time range exceeds 1 day"))
+			return
+		}
+		*requests++
+
+		records := []map[string]interface{}{}
+		for _, event := range events {
+			ts, _ := time.Parse(time.RFC3339, event)
+			if !ts.Before(start) && !ts.After(end) {
+				records = append(records, map[string]interface{}{"p_timestamp": event})
+			}
+		}
+		json.NewEncoder(w).Encode(records)
+	}))
+}
+
+func TestStreamChunksWorksAroundWindowCap(t *testing.T) {
+	events := []string{
+		"2024-03-01T06:00:00Z",
+		// exactly on the boundary between the first and second day
+		"2024-03-02T00:00:00Z",
+		"2024-03-02T18:00:00Z",
+		"2024-03-03T12:00:00Z",
+	}
+	var requests int
+	server := windowCappedServer(t, events, &requests)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	windows := chunkWindows(start, start.Add(72*time.Hour), 24*time.Hour)
+	if len(windows) != 3 {
+		t.Fatalf("expected 3 windows, got %d", len(windows))
+	}
+
+	var out, progress strings.Builder
+	if err := streamChunks(&client, queryOptions{query: "select * from app"}, windows, &out, &progress); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Errorf("expected one request per window, got %d", requests)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &records); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, out.String())
+	}
+	var got []string
+	for _, record := range records {
+		got = append(got, record["p_timestamp"].(string))
+	}
+	if strings.Join(got, ",") != strings.Join(events, ",") {
+		t.Errorf("expected every event once and in order, got %v", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(progress.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "Chunk 1/3, 2024-03-01T00:00:00Z to 2024-03-02T00:00:00Z: 2 rows") {
+		t.Errorf("unexpected progress:\n%s", progress.String())
+	}
+	if !strings.Contains(lines[1], "1 already written by the previous chunk") {
+		t.Errorf("expected the boundary row to be reported, got %q", lines[1])
+	}
+}
+
+func TestStreamChunksStopsAtLimit(t *testing.T) {
+	var requests int
+	server := windowCappedServer(t, []string{"2024-03-01T06:00:00Z", "2024-03-01T07:00:00Z", "2024-03-02T06:00:00Z"}, &requests)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	windows := chunkWindows(start, start.Add(48*time.Hour), 24*time.Hour)
+
+	var out strings.Builder
+	if err := streamChunks(&client, queryOptions{query: "select * from app", limit: 2}, windows, &out, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]interface{}
+	json.Unmarshal([]byte(out.String()), &records)
+	if len(records) != 2 || requests != 1 {
+		t.Errorf("expected 2 rows from a single request, got %d rows from %d requests", len(records), requests)
+	}
+}
+
+func TestFetchChunkedRelativeDays(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	events := []string{
+		now.Add(-50 * time.Hour).Format(time.RFC3339),
+		now.Add(-26 * time.Hour).Format(time.RFC3339),
+		now.Add(-2 * time.Hour).Format(time.RFC3339),
+	}
+	var requests int
+	server := windowCappedServer(t, events, &requests)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	output := filepath.Join(t.TempDir(), "export.ndjson")
+	opts := queryOptions{query: "select * from app", startTime: "3d", endTime: "now", chunk: 24 * time.Hour, outputFormat: "ndjson", outputFile: output}
+	if err := fetchChunked(&client, opts); err != nil {
+		t.Fatalf("expected --from=3d to be split into windows, got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("expected one request per day, got %d", requests)
+	}
+	data, _ := os.ReadFile(output)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 {
+		t.Errorf("expected every event once, got:\n%s", data)
+	}
+}
+
+func TestChunkWindowsEndAtEnd(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	windows := chunkWindows(start, start.Add(90*time.Minute), time.Hour)
+	if len(windows) != 2 || !windows[1].start.Equal(start.Add(time.Hour)) || !windows[1].end.Equal(start.Add(90*time.Minute)) {
+		t.Errorf("unexpected windows %+v", windows)
+	}
+	if windows := chunkWindows(start, start, time.Hour); len(windows) != 0 {
+		t.Errorf("expected no windows for an empty range, got %+v", windows)
+	}
+}
+
+// slowWindowServer answers each query with one row holding the start of its
+// window, after a delay that is longest for the earliest window so that
+// responses arrive out of order. It records the most requests in flight
+func slowWindowServer(first time.Time, delay time.Duration, inFlight, peak *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(inFlight, 1)
+		defer atomic.AddInt32(inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(peak)
+			if current <= seen || atomic.CompareAndSwapInt32(peak, seen, current) {
+				break
+			}
+		}
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		start, _ := time.Parse(time.RFC3339, body["startTime"])
+		remaining := 8 - int(start.Sub(first)/time.Hour)
+		time.Sleep(time.Duration(remaining) * delay)
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"window": body["startTime"]}})
+	}))
+}
+
+func TestStreamChunksConcurrentKeepsOrder(t *testing.T) {
+	var inFlight, peak int32
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	server := slowWindowServer(start, 10*time.Millisecond, &inFlight, &peak)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	windows := chunkWindows(start, start.Add(8*time.Hour), time.Hour)
+
+	var out strings.Builder
+	opts := queryOptions{query: "select * from app", concurrency: 4}
+	if err := streamChunks(&client, opts, windows, &out, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(out.String()), &records); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, out.String())
+	}
+	if len(records) != len(windows) {
+		t.Fatalf("expected a row per window, got %d", len(records))
+	}
+	for idx, record := range records {
+		if want := windows[idx].start.Format(time.RFC3339); record["window"] != want {
+			t.Errorf("row %d: expected window %s, got %v", idx, want, record["window"])
+		}
+	}
+	if peak < 2 || peak > 4 {
+		t.Errorf("expected between 2 and 4 requests in flight, got %d", peak)
+	}
+}
+
+func BenchmarkStreamChunksConcurrency(b *testing.B) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	windows := chunkWindows(start, start.Add(8*time.Hour), time.Hour)
+	for _, concurrency := range []int{1, 4} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			var inFlight, peak int32
+			server := slowWindowServer(start, 2*time.Millisecond, &inFlight, &peak)
+			defer server.Close()
+			client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+			opts := queryOptions{query: "select * from app", concurrency: concurrency}
+			for i := 0; i < b.N; i++ {
+				if err := streamChunks(&client, opts, windows, io.Discard, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrencyRequiresChunk(t *testing.T) {
+	if err := validateChunkOptions(queryOptions{concurrency: 4}, 1, false); err == nil || !strings.Contains(err.Error(), "--chunk") {
+		t.Errorf("expected --concurrency without --chunk to be rejected, got %v", err)
+	}
+	if err := validateChunkOptions(queryOptions{chunk: time.Hour, concurrency: 0}, 1, false); err == nil {
+		t.Error("expected --concurrency 0 to be rejected")
+	}
+	if err := validateChunkOptions(queryOptions{chunk: time.Hour, concurrency: 4}, 1, false); err != nil {
+		t.Errorf("expected --chunk with --concurrency to pass, got %v", err)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestDiscardClosesUnreadChunks(t *testing.T) {
+	read, unread := &closeRecorder{Reader: strings.NewReader("[]")}, &closeRecorder{Reader: strings.NewReader("[]")}
+	fetches := &chunkFetches{chunks: make([]chan fetchedChunk, 3)}
+	for idx := range fetches.chunks {
+		fetches.chunks[idx] = make(chan fetchedChunk, 1)
+	}
+	fetches.chunks[0] <- fetchedChunk{body: read}
+	fetches.chunks[1] <- fetchedChunk{body: unread}
+
+	// the first window was read, the third was never fetched
+	<-fetches.chunks[0]
+	fetches.discard(1)
+	if !unread.closed {
+		t.Error("expected the body of an unread window to be closed")
+	}
+	if read.closed {
+		t.Error("expected the body of a read window to be left to its reader")
+	}
+}
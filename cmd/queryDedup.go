@@ -0,0 +1,130 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	deduplicateFlag = "deduplicate"
+	dedupWindowFlag = "dedup-window"
+)
+
+// dedupOptions controls the client side removal of duplicate rows
+type dedupOptions struct {
+	// fields make up the key rows are compared on, none turns it off
+	fields []string
+	// window is the number of most recent distinct keys remembered, 0
+	// remembers every key
+	window int
+}
+
+// validateDedupOptions checks --deduplicate and --dedup-window
+func validateDedupOptions(opts queryOptions) error {
+	if len(opts.dedup.fields) == 0 {
+		if opts.dedup.window != 0 {
+			return fmt.Errorf("--%s requires --%s", dedupWindowFlag, deduplicateFlag)
+		}
+		return nil
+	}
+	switch {
+	case opts.dedup.window < 0:
+		return fmt.Errorf("--%s must be 0 or more, got %d", dedupWindowFlag, opts.dedup.window)
+	case opts.raw || opts.pretty || opts.value:
+		return fmt.Errorf("--%s cannot be used with --%s, --%s or --%s", deduplicateFlag, rawFlag, prettyFlag, valueFlag)
+	}
+	for _, field := range opts.dedup.fields {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("--%s has an empty field name", deduplicateFlag)
+		}
+	}
+	return nil
+}
+
+// dedupResultWriter drops rows whose key was already written and reports
+// the number of dropped rows when flushed. Rows without every key field are
+// always written. With a window, only the most recent distinct keys are
+// remembered, so a duplicate of an older key is written again
+type dedupResultWriter struct {
+	ResultWriter
+	fields []string
+	window int
+	// out receives the count of dropped rows
+	out io.Writer
+
+	seen map[string]struct{}
+	// recent holds the remembered keys in the order they were first seen,
+	// as a ring of window entries
+	recent  []string
+	next    int
+	removed int
+}
+
+func newDedupResultWriter(writer ResultWriter, opts dedupOptions, out io.Writer) *dedupResultWriter {
+	d := &dedupResultWriter{ResultWriter: writer, fields: opts.fields, window: opts.window, out: out, seen: make(map[string]struct{})}
+	if opts.window > 0 {
+		d.recent = make([]string, opts.window)
+	}
+	return d
+}
+
+func (d *dedupResultWriter) Write(row map[string]interface{}) error {
+	key, ok := d.key(row)
+	if !ok {
+		return d.ResultWriter.Write(row)
+	}
+	if _, dup := d.seen[key]; dup {
+		d.removed++
+		return nil
+	}
+
+	if d.recent != nil {
+		if oldest := d.recent[d.next]; oldest != "" {
+			delete(d.seen, oldest)
+		}
+		d.recent[d.next] = key
+		d.next = (d.next + 1) % len(d.recent)
+	}
+	d.seen[key] = struct{}{}
+	return d.ResultWriter.Write(row)
+}
+
+func (d *dedupResultWriter) Flush() error {
+	if err := d.ResultWriter.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(d.out, "Removed %d duplicate row(s) on %s\n", d.removed, strings.Join(d.fields, ","))
+	return err
+}
+
+// key returns the values of the key fields of row as a single string, and
+// false when row lacks one of them
+func (d *dedupResultWriter) key(row map[string]interface{}) (string, bool) {
+	values := make([]interface{}, len(d.fields))
+	for idx, field := range d.fields {
+		value, ok := row[field]
+		if !ok {
+			return "", false
+		}
+		values[idx] = value
+	}
+	encoded, _ := json.Marshal(values)
+	return string(encoded), true
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func dedupRows(t *testing.T, body string, opts dedupOptions) (string, string) {
+	var out, summary bytes.Buffer
+	writer := newDedupResultWriter(&ndjsonResultWriter{encoder: json.NewEncoder(&out)}, opts, &summary)
+	if err := copyRecords(strings.NewReader(body), writer); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), summary.String()
+}
+
+func TestDedupDropsDuplicatesWithinWindow(t *testing.T) {
+	body := `[{"id":1,"n":"a"},{"id":2,"n":"b"},{"id":1,"n":"c"},{"id":2,"n":"d"},{"id":3,"n":"e"}]`
+	out, summary := dedupRows(t, body, dedupOptions{fields: []string{"id"}})
+
+	want := "{\"id\":1,\"n\":\"a\"}\n{\"id\":2,\"n\":\"b\"}\n{\"id\":3,\"n\":\"e\"}\n"
+	if out != want {
+		t.Errorf("expected only first occurrences, got:\n%s", out)
+	}
+	if summary != "Removed 2 duplicate row(s) on id\n" {
+		t.Errorf("unexpected summary %q", summary)
+	}
+}
+
+func TestDedupWindowForgetsOldKeys(t *testing.T) {
+	// with a window of 2, key 1 is forgotten once keys 2 and 3 are seen
+	body := `[{"id":1},{"id":2},{"id":2},{"id":3},{"id":1},{"id":3}]`
+	out, summary := dedupRows(t, body, dedupOptions{fields: []string{"id"}, window: 2})
+
+	want := "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n{\"id\":1}\n"
+	if out != want {
+		t.Errorf("expected the duplicate beyond the window to be kept, got:\n%s", out)
+	}
+	if summary != "Removed 2 duplicate row(s) on id\n" {
+		t.Errorf("unexpected summary %q", summary)
+	}
+}
+
+func TestDedupCompositeKey(t *testing.T) {
+	body := `[{"host":"a","req":1},{"host":"b","req":1},{"host":"a","req":1},{"host":"a"}]`
+	out, _ := dedupRows(t, body, dedupOptions{fields: []string{"host", "req"}})
+
+	// rows without every key field are always kept
+	want := "{\"host\":\"a\",\"req\":1}\n{\"host\":\"b\",\"req\":1}\n{\"host\":\"a\"}\n"
+	if out != want {
+		t.Errorf("expected rows to be compared on both fields, got:\n%s", out)
+	}
+}
+
+func TestValidateDedupOptions(t *testing.T) {
+	cases := []struct {
+		opts  queryOptions
+		fails bool
+	}{
+		{opts: queryOptions{dedup: dedupOptions{fields: []string{"id"}, window: 100}}},
+		{opts: queryOptions{dedup: dedupOptions{window: 100}}, fails: true},
+		{opts: queryOptions{dedup: dedupOptions{fields: []string{"id"}, window: -1}}, fails: true},
+		{opts: queryOptions{dedup: dedupOptions{fields: []string{"id"}}, pretty: true}, fails: true},
+		{opts: queryOptions{dedup: dedupOptions{fields: []string{""}}}, fails: true},
+	}
+	for _, c := range cases {
+		err := validateDedupOptions(c.opts)
+		if c.fails != (err != nil) {
+			t.Errorf("%+v: expected failure %v, got %v", c.opts.dedup, c.fails, err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pb/pkg/common"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+var (
+	explainPlanTreeFlag = "explain-plan-tree"
+
+	// largeRowEstimate is the estimated row count from which a plan node is
+	// marked as expensive
+	largeRowEstimate int64 = 1_000_000
+
+	rowEstimatePattern = regexp.MustCompile(`Rows=(?:Exact|Inexact)\((\d+)\)`)
+)
+
+// planNode is one operator of a query plan with its inputs
+type planNode struct {
+	text     string
+	children []*planNode
+}
+
+// explainQuery asks the server for the plan of query and writes it as a
+// tree to w. Plans in a shape pb does not know are written as raw JSON
+func explainQuery(client *internalHTTP.HTTPClient, query string, opts queryOptions, w io.Writer) error {
+	statement := strings.TrimSpace(query)
+	if !strings.HasPrefix(strings.ToLower(statement), "explain ") {
+		statement = "EXPLAIN " + statement
+	}
+	if opts.verbose {
+		fmt.Fprintf(os.Stderr, "SQL: %s\n", statement)
+	}
+
+	records, err := queryRecords(client, statement, opts.startTime, opts.endTime)
+	if err != nil {
+		return err
+	}
+	color := !opts.noColor && os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd()))
+	return writePlanTree(w, records, color)
+}
+
+// writePlanTree renders the plans of an EXPLAIN result, one tree per plan
+// type such as logical_plan and physical_plan
+func writePlanTree(w io.Writer, records []map[string]interface{}, color bool) error {
+	plans, ok := planTexts(records)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Warning: the server returned a plan in an unrecognized shape, showing it unchanged")
+		encoded, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(encoded))
+		return err
+	}
+
+	for idx, plan := range plans {
+		if idx > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s:\n", plan[0])
+		for _, root := range parsePlan(plan[1]) {
+			writePlanNode(w, root, "", "", color)
+		}
+	}
+	return nil
+}
+
+// planTexts returns the plan type and text of every record, or false when
+// the records are not the plan_type and plan pairs of an EXPLAIN result
+func planTexts(records []map[string]interface{}) ([][2]string, bool) {
+	if len(records) == 0 {
+		return nil, false
+	}
+	plans := make([][2]string, 0, len(records))
+	for _, record := range records {
+		planType, ok := record["plan_type"].(string)
+		if !ok {
+			return nil, false
+		}
+		plan, ok := record["plan"].(string)
+		if !ok {
+			return nil, false
+		}
+		plans = append(plans, [2]string{planType, plan})
+	}
+	return plans, true
+}
+
+// parsePlan builds the operator tree from an indented text plan, where every
+// level of input is indented by two more spaces than its parent
+func parsePlan(plan string) []*planNode {
+	var roots []*planNode
+	var stack []*planNode
+	var depths []int
+	for _, line := range strings.Split(plan, "\n") {
+		text := strings.TrimSpace(line)
+		if text == "" {
+			continue
+		}
+		depth := len(line) - len(strings.TrimLeft(line, " "))
+		node := &planNode{text: text}
+
+		for len(depths) > 0 && depths[len(depths)-1] >= depth {
+			stack = stack[:len(stack)-1]
+			depths = depths[:len(depths)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+		}
+		stack = append(stack, node)
+		depths = append(depths, depth)
+	}
+	return roots
+}
+
+func writePlanNode(w io.Writer, node *planNode, prefix, childPrefix string, color bool) {
+	text := node.text
+	if reason := planNodeCost(text); reason != "" {
+		text += "  ← " + reason
+		if color {
+			text = common.Red + text + common.Reset
+		}
+	}
+	fmt.Fprintf(w, "%s%s\n", prefix, text)
+
+	for idx, child := range node.children {
+		if idx == len(node.children)-1 {
+			writePlanNode(w, child, childPrefix+"└─ ", childPrefix+"   ", color)
+		} else {
+			writePlanNode(w, child, childPrefix+"├─ ", childPrefix+"│  ", color)
+		}
+	}
+}
+
+// planNodeCost returns why an operator is likely expensive, or an empty
+// string. Scans without a filter read the whole time range, hash
+// repartitions shuffle every row between partitions, and when the plan
+// carries statistics any operator estimated at largeRowEstimate rows or more
+// is marked
+func planNodeCost(text string) string {
+	var reasons []string
+	name, details, _ := strings.Cut(text, ":")
+	switch strings.TrimSpace(name) {
+	case "TableScan":
+		if !strings.Contains(details, "filters=") {
+			reasons = append(reasons, "full scan")
+		}
+	case "ParquetExec", "DataSourceExec":
+		if !strings.Contains(details, "predicate=") {
+			reasons = append(reasons, "full scan")
+		}
+	case "RepartitionExec":
+		if strings.Contains(details, "Hash(") {
+			reasons = append(reasons, "shuffle")
+		}
+	}
+
+	if match := rowEstimatePattern.FindStringSubmatch(text); match != nil {
+		if rows, err := strconv.ParseInt(match[1], 10, 64); err == nil && rows >= largeRowEstimate {
+			reasons = append(reasons, fmt.Sprintf("~%d rows", rows))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// validateExplainOptions rejects flags that only apply to query results
+func validateExplainOptions(flags *pflag.FlagSet, statements int) error {
+	if statements > 1 {
+		return fmt.Errorf("--%s explains a single statement", explainPlanTreeFlag)
+	}
+	for _, name := range []string{interactiveFlag, outputFileFlag, outputURLFlag, rawFlag, statsFlag} {
+		if flags.Changed(name) {
+			return fmt.Errorf("--%s cannot be used with --%s", name, explainPlanTreeFlag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWritePlanTree(t *testing.T) {
+	records := []map[string]interface{}{
+		{"plan_type": "logical_plan", "plan": "Projection: host, count(*)\n  Aggregate: groupBy=[[host]], aggr=[[count(*)]]\n    TableScan: backend projection=[host]"},
+		{"plan_type": "physical_plan", "plan": "AggregateExec: mode=FinalPartitioned\n  RepartitionExec: partitioning=Hash([host@0], 8)\n    ParquetExec: file_groups={1 group}, predicate=status@1 = 500\n  CoalesceBatchesExec: target_batch_size=8192, statistics=[Rows=Inexact(5000000)]"},
+	}
+
+	var out strings.Builder
+	if err := writePlanTree(&out, records, false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `logical_plan:
+Projection: host, count(*)
+└─ Aggregate: groupBy=[[host]], aggr=[[count(*)]]
+   └─ TableScan: backend projection=[host]  ← full scan
+
+physical_plan:
+AggregateExec: mode=FinalPartitioned
+├─ RepartitionExec: partitioning=Hash([host@0], 8)  ← shuffle
+│  └─ ParquetExec: file_groups={1 group}, predicate=status@1 = 500
+└─ CoalesceBatchesExec: target_batch_size=8192, statistics=[Rows=Inexact(5000000)]  ← ~5000000 rows
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWritePlanTreeFallsBackToRaw(t *testing.T) {
+	var out strings.Builder
+	if err := writePlanTree(&out, []map[string]interface{}{{"plan": map[string]interface{}{"node": "scan"}}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"node": "scan"`) {
+		t.Errorf("expected the raw plan, got:\n%s", out.String())
+	}
+}
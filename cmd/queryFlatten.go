@@ -0,0 +1,107 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+var (
+	flattenFlag          = "flatten"
+	flattenSeparatorFlag = "flatten-separator"
+	flattenArraysFlag    = "flatten-arrays"
+
+	defaultFlattenSeparator = "."
+)
+
+// flattenOptions controls how nested values in query results are turned into
+// top level fields before formatting
+type flattenOptions struct {
+	enabled   bool
+	separator string
+	// arrays expands arrays by index, otherwise they are kept as JSON strings
+	arrays bool
+}
+
+// validateFlattenOptions checks the flatten flags, which only apply to output
+// formats that decode the records
+func validateFlattenOptions(opts queryOptions) error {
+	if !opts.flatten.enabled {
+		return nil
+	}
+	switch opts.outputFormat {
+	case "json", "ndjson", "csv", "table", "xlsx":
+	default:
+		return fmt.Errorf("--%s only applies to json, ndjson, csv, table or xlsx output", flattenFlag)
+	}
+	if opts.pretty {
+		return fmt.Errorf("--%s cannot be used with --%s", flattenFlag, prettyFlag)
+	}
+	if opts.flatten.separator == "" {
+		return fmt.Errorf("--%s must not be empty", flattenSeparatorFlag)
+	}
+	return nil
+}
+
+// flattenRecords returns records with nested objects, and arrays unless
+// they are kept, replaced by one field per leaf value. Keys join the path
+// to the leaf with the separator, e.g. user.id or tags.0
+func flattenRecords(records []map[string]interface{}, opts flattenOptions) []map[string]interface{} {
+	flattened := make([]map[string]interface{}, len(records))
+	for idx, record := range records {
+		out := make(map[string]interface{}, len(record))
+		for key, value := range record {
+			flattenValue(out, key, value, opts)
+		}
+		flattened[idx] = out
+	}
+	return flattened
+}
+
+func flattenValue(out map[string]interface{}, key string, value interface{}, opts flattenOptions) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// an empty object has no leaves, keep it so the field does not vanish
+		if len(v) == 0 {
+			out[key] = v
+			return
+		}
+		for child, childValue := range v {
+			flattenValue(out, key+opts.separator+child, childValue, opts)
+		}
+	case []interface{}:
+		if !opts.arrays {
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				out[key] = v
+				return
+			}
+			out[key] = string(encoded)
+			return
+		}
+		if len(v) == 0 {
+			out[key] = v
+			return
+		}
+		for idx, item := range v {
+			flattenValue(out, key+opts.separator+strconv.Itoa(idx), item, opts)
+		}
+	default:
+		out[key] = v
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func nestedRecords(t *testing.T) []map[string]interface{} {
+	var records []map[string]interface{}
+	body := `[{"host":"a","user":{"id":7,"geo":{"country":"DE"}},"tags":["x","y"],"empty":{}}]`
+	if err := json.Unmarshal([]byte(body), &records); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestFlattenNestedObjectsAndArrays(t *testing.T) {
+	flattened := flattenRecords(nestedRecords(t), flattenOptions{enabled: true, separator: ".", arrays: true})
+
+	expected := map[string]interface{}{
+		"host":             "a",
+		"user.id":          float64(7),
+		"user.geo.country": "DE",
+		"tags.0":           "x",
+		"tags.1":           "y",
+		"empty":            map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(flattened[0], expected) {
+		t.Errorf("expected %v, got %v", expected, flattened[0])
+	}
+}
+
+func TestFlattenKeepsArraysAsJSON(t *testing.T) {
+	flattened := flattenRecords(nestedRecords(t), flattenOptions{enabled: true, separator: "_", arrays: false})
+
+	if flattened[0]["tags"] != `["x","y"]` {
+		t.Errorf("expected tags as a JSON string, got %#v", flattened[0]["tags"])
+	}
+	if flattened[0]["user_geo_country"] != "DE" {
+		t.Errorf("expected the custom separator in keys, got %v", flattened[0])
+	}
+}
+
+func TestFlattenedCSVHasColumnPerLeaf(t *testing.T) {
+	flattened := flattenRecords(nestedRecords(t), flattenOptions{enabled: true, separator: ".", arrays: true})
+
+	var out strings.Builder
+	if err := writeRecords(&out, flattened, "csv", nil); err != nil {
+		t.Fatal(err)
+	}
+	header := strings.Split(out.String(), "\n")[0]
+	if header != "empty,host,tags.0,tags.1,user.geo.country,user.id" {
+		t.Errorf("unexpected header %q", header)
+	}
+}
+
+func TestFlattenRequiresStructuredOutput(t *testing.T) {
+	flatten := flattenOptions{enabled: true, separator: "."}
+	if err := validateFlattenOptions(queryOptions{outputFormat: "text", flatten: flatten}); err == nil {
+		t.Error("expected --flatten with text output to be rejected")
+	}
+	if err := validateFlattenOptions(queryOptions{outputFormat: "csv", flatten: flattenOptions{enabled: true}}); err == nil {
+		t.Error("expected an empty separator to be rejected")
+	}
+	if err := validateFlattenOptions(queryOptions{outputFormat: "table", flatten: flatten}); err != nil {
+		t.Errorf("expected --flatten with table output to pass, got %v", err)
+	}
+}
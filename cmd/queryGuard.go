@@ -0,0 +1,152 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"pb/pkg/model"
+)
+
+var (
+	limitFlag   = "limit"
+	offsetFlag  = "offset"
+	noGuardFlag = "no-guard"
+
+	// sqlAggregates are functions that reduce a result to a few rows
+	sqlAggregates = map[string]struct{}{
+		"count": {}, "sum": {}, "avg": {}, "min": {}, "max": {},
+		"approx_distinct": {}, "approx_percentile_cont": {}, "median": {},
+	}
+)
+
+// isUnboundedSelect reports whether a statement is a plain SELECT that may
+// return every row in the time range: it has no LIMIT, no aggregation or
+// GROUP BY, and no condition on p_timestamp
+func isUnboundedSelect(sql string) bool {
+	words := model.SQLWords(sql)
+	if len(words) == 0 || (words[0] != "select" && words[0] != "with") {
+		return false
+	}
+	for _, word := range words {
+		if _, ok := sqlAggregates[word]; ok {
+			return false
+		}
+		switch word {
+		case "limit", "group", defaultTimeColumn:
+			return false
+		}
+	}
+	return true
+}
+
+// trimStatement removes the trailing semicolons, comments and whitespace of a
+// statement so that clauses can be appended to it. A clause appended after a
+// trailing -- comment would otherwise be commented out
+func trimStatement(sql string) string {
+	end := 0
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			stop := i + 1
+			for stop < len(sql) && sql[stop] != c {
+				stop++
+			}
+			i = min(stop, len(sql)-1)
+			end = i + 1
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			newline := strings.IndexByte(sql[i:], '\n')
+			if newline < 0 {
+				return sql[:end]
+			}
+			i += newline
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			stop := strings.Index(sql[i+2:], "*/")
+			if stop < 0 {
+				return sql[:end]
+			}
+			i += stop + 3
+		case c == ';' || c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			end = i + 1
+		}
+	}
+	return sql[:end]
+}
+
+// applyLimit appends a LIMIT clause to a statement that has none
+func applyLimit(sql string, limit int) string {
+	if limit <= 0 {
+		return sql
+	}
+	for _, word := range model.SQLWords(sql) {
+		if word == "limit" {
+			return sql
+		}
+	}
+	return fmt.Sprintf("%s LIMIT %d", trimStatement(sql), limit)
+}
+
+// validatePagingOptions checks --offset, which pages through results with
+// --limit rows per page. Queries that already page themselves are rejected
+// rather than overridden
+func validatePagingOptions(opts queryOptions, statements int, interactive bool) error {
+	if !opts.paging {
+		return nil
+	}
+	switch {
+	case opts.offset < 0:
+		return fmt.Errorf("--%s must not be negative", offsetFlag)
+	case opts.limit <= 0:
+		return fmt.Errorf("--%s requires --%s, the number of rows per page", offsetFlag, limitFlag)
+	case statements > 1:
+		return fmt.Errorf("--%s cannot be used with multiple statements", offsetFlag)
+	case interactive:
+		return fmt.Errorf("--%s cannot be used with --%s, which pages through results itself", offsetFlag, interactiveFlag)
+	}
+	for _, word := range model.SQLWords(opts.query) {
+		if word == "limit" || word == "offset" {
+			return fmt.Errorf("the query already has LIMIT or OFFSET. Remove them from the SQL to page with --%s and --%s", limitFlag, offsetFlag)
+		}
+	}
+	if !model.HasOrderBy(opts.query) {
+		return fmt.Errorf("--%s needs a query with ORDER BY, e.g. on p_timestamp, as the server may return rows in a different order for each page", offsetFlag)
+	}
+	return nil
+}
+
+// applyPage appends the LIMIT and OFFSET of a page to a statement
+func applyPage(sql string, limit, offset int) string {
+	return fmt.Sprintf("%s LIMIT %d OFFSET %d", trimStatement(sql), limit, offset)
+}
+
+// printPageWindow reports the rows a page returned and how to get the next
+// one. A page with fewer rows than --limit is the last one
+func printPageWindow(w io.Writer, opts queryOptions, rows int64) {
+	first, last := int64(opts.offset)+1, int64(opts.offset)+rows
+	switch {
+	case rows == 0:
+		fmt.Fprintf(w, "No rows after row %d, the result has ended.\n", opts.offset)
+	case rows < int64(opts.limit):
+		fmt.Fprintf(w, "Rows %d to %d of the result, the last page.\n", first, last)
+	default:
+		fmt.Fprintf(w, "Rows %d to %d of the result. Next page: --%s %d --%s %d\n",
+			first, last, limitFlag, opts.limit, offsetFlag, opts.offset+opts.limit)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestIsUnboundedSelect(t *testing.T) {
+	unbounded := []string{
+		"select * from backend",
+		"SELECT host, status FROM backend WHERE status = 500",
+		"select * from backend where msg = 'limit 10'",
+		"select * from backend -- limit 10",
+		`select "count" from backend`,
+		"with recent as (select * from backend) select * from recent",
+	}
+	for _, sql := range unbounded {
+		if !isUnboundedSelect(sql) {
+			t.Errorf("expected %q to trigger the guard", sql)
+		}
+	}
+
+	bounded := []string{
+		"select * from backend limit 100",
+		"SELECT * FROM backend LIMIT 10",
+		"select count(*) from backend",
+		"select host, max(latency) from backend group by host",
+		"select status from backend group by status",
+		"select * from backend where p_timestamp > now() - interval '5 minutes'",
+		"show tables",
+		"",
+	}
+	for _, sql := range bounded {
+		if isUnboundedSelect(sql) {
+			t.Errorf("expected %q not to trigger the guard", sql)
+		}
+	}
+}
+
+func TestApplyLimit(t *testing.T) {
+	cases := map[string]string{
+		"select * from backend":                  "select * from backend LIMIT 50",
+		"select * from backend;":                 "select * from backend LIMIT 50",
+		"select * from backend limit 10":         "select * from backend limit 10",
+		"select * from backend where a='limit'":  "select * from backend where a='limit' LIMIT 50",
+		"select * from backend -- recent errors": "select * from backend LIMIT 50",
+		"select * from backend; /* note */\n":    "select * from backend LIMIT 50",
+		"select '--' as a from backend":          "select '--' as a from backend LIMIT 50",
+	}
+	for sql, want := range cases {
+		if got := applyLimit(sql, 50); got != want {
+			t.Errorf("applyLimit(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+func TestPagingSendsLimitAndOffset(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body["query"])
+		w.Write([]byte(`[{"a":1},{"a":2}]`))
+	}))
+	defer server.Close()
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	opts := queryOptions{
+		query:        "select * from backend order by p_timestamp; -- newest first",
+		startTime:    "10m",
+		endTime:      "now",
+		outputFormat: "json",
+		outputFile:   filepath.Join(t.TempDir(), "page.json"),
+		limit:        100,
+		offset:       200,
+		paging:       true,
+	}
+	if err := validatePagingOptions(opts, 1, false); err != nil {
+		t.Fatalf("expected paging options to be valid, got %v", err)
+	}
+	if err := fetchData(&client, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "select * from backend order by p_timestamp LIMIT 100 OFFSET 200"
+	if len(sent) != 1 || sent[0] != want {
+		t.Errorf("expected %q to reach the server, got %v", want, sent)
+	}
+}
+
+func TestPrintPageWindow(t *testing.T) {
+	opts := queryOptions{limit: 100, offset: 200, paging: true}
+	cases := []struct {
+		rows int64
+		want string
+	}{
+		{100, "Rows 201 to 300 of the result. Next page: --limit 100 --offset 300\n"},
+		{2, "Rows 201 to 202 of the result, the last page.\n"},
+		{0, "No rows after row 200, the result has ended.\n"},
+	}
+	for _, c := range cases {
+		var window strings.Builder
+		printPageWindow(&window, opts, c.rows)
+		if window.String() != c.want {
+			t.Errorf("printPageWindow(%d rows) = %q, want %q", c.rows, window.String(), c.want)
+		}
+	}
+}
+
+func TestPagingRejectsAmbiguousQueries(t *testing.T) {
+	paged := func(sql string, limit int) queryOptions {
+		return queryOptions{query: sql, limit: limit, offset: 100, paging: true}
+	}
+	cases := map[string]queryOptions{
+		"query has LIMIT":   paged("select * from backend order by p_timestamp limit 10", 50),
+		"query has OFFSET":  paged("select * from backend order by p_timestamp offset 10", 50),
+		"no --limit":        paged("select * from backend order by p_timestamp", 0),
+		"negative --offset": {query: "select * from backend order by p_timestamp", limit: 50, offset: -1, paging: true},
+		"no ORDER BY":       paged("select * from backend", 50),
+	}
+	for name, opts := range cases {
+		if err := validatePagingOptions(opts, 1, false); err == nil {
+			t.Errorf("%s: expected paging to be rejected", name)
+		}
+	}
+
+	// a literal that reads like a clause does not count
+	if err := validatePagingOptions(paged("select * from backend where msg = 'limit' order by p_timestamp", 50), 1, false); err != nil {
+		t.Errorf("expected a string literal to be ignored, got %v", err)
+	}
+}
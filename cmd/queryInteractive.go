@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"pb/pkg/model"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// validateInteractiveOptions rejects flags that only apply to printed
+// results, as the interactive view pages through results on screen
+func validateInteractiveOptions(opts queryOptions, statements int) error {
+	if statements > 1 {
+		return fmt.Errorf("--%s runs a single statement, %d were given", interactiveFlag, statements)
+	}
+	if opts.outputFormat != "" || !writesToStdout(opts) || opts.raw || opts.pretty || opts.location != nil || opts.value || len(opts.dedup.fields) > 0 {
+		return fmt.Errorf("--%s cannot be combined with --output, --%s, --%s, --%s, --%s, --%s, --%s or --%s", interactiveFlag, outputFileFlag, outputURLFlag, rawFlag, prettyFlag, timezoneFlag, valueFlag, deduplicateFlag)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("--%s needs a terminal", interactiveFlag)
+	}
+	return nil
+}
+
+// runInteractive opens the interactive table view for the query. Rows are
+// loaded from the server a page at a time as the user scrolls
+func runInteractive(opts queryOptions) error {
+	start, end, err := parseTime(opts.startTime, opts.endTime)
+	if err != nil {
+		return err
+	}
+	_, err = tea.NewProgram(model.NewQueryModel(DefaultProfile, opts.query, start, end), tea.WithAltScreen()).Run()
+	return err
+}
+
+// parseTime returns the start and end time of the query, given as RFC3339
+// times, durations or whole days before now such as 10m or 7d, or now
+func parseTime(start, end string) (time.Time, time.Time, error) {
+	if start == defaultStart && end == defaultEnd {
+		return time.Now().Add(-1 * time.Minute), time.Now(), nil
+	}
+
+	startTime, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		// try parsing as duration
+		duration, err := parseDaysOrDuration(startFlag, start)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		startTime = time.Now().Add(-1 * duration)
+	}
+
+	endTime, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		if end == "now" {
+			endTime = time.Now()
+		} else {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	return startTime, endTime, nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeAcceptsDays(t *testing.T) {
+	start, end, err := parseTime("1d", "now")
+	if err != nil {
+		t.Fatalf("expected --from=1d to be accepted, got %v", err)
+	}
+	if span := end.Sub(start); span < 24*time.Hour || span > 24*time.Hour+time.Second {
+		t.Errorf("expected a one day range, got %v", span)
+	}
+
+	if _, _, err := parseTime("2h", "now"); err != nil {
+		t.Errorf("expected durations to still be accepted, got %v", err)
+	}
+	if _, _, err := parseTime("1.5d", "now"); err == nil {
+		t.Error("expected a fractional day to be rejected")
+	}
+}
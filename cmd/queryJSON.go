@@ -0,0 +1,80 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	jsonArrayFlag = "json-array"
+	ndjsonFlag    = "ndjson"
+
+	// autoNDJSONRows is the number of rows above which json output to a file
+	// switches to ndjson, unless --json-array asks for a single array
+	autoNDJSONRows = 100000
+)
+
+// resolveJSONMode applies --json-array and --ndjson to the output format.
+// It reports whether a single JSON array was asked for explicitly
+func resolveJSONMode(flags *pflag.FlagSet, format string) (string, bool, error) {
+	jsonArray, _ := flags.GetBool(jsonArrayFlag)
+	ndjson, _ := flags.GetBool(ndjsonFlag)
+
+	switch {
+	case jsonArray && ndjson:
+		return "", false, fmt.Errorf("--%s and --%s cannot be used together", jsonArrayFlag, ndjsonFlag)
+	case jsonArray:
+		if format != "" && format != "json" {
+			return "", false, fmt.Errorf("--%s cannot be used with %s output", jsonArrayFlag, format)
+		}
+		return "json", true, nil
+	case ndjson:
+		if format != "" && format != "ndjson" {
+			return "", false, fmt.Errorf("--%s cannot be used with %s output", ndjsonFlag, format)
+		}
+		return "ndjson", false, nil
+	}
+	return format, false, nil
+}
+
+// decodeRecords calls fn for each record of a JSON array without holding the
+// whole array in memory
+func decodeRecords(body io.Reader, fn func(map[string]interface{}) error) error {
+	decoder := json.NewDecoder(body)
+	if token, err := decoder.Token(); err != nil {
+		return fmt.Errorf("error decoding JSON response: %w", err)
+	} else if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("error decoding JSON response: expected an array of records")
+	}
+	for decoder.More() {
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			return fmt.Errorf("error decoding JSON response: %w", err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("error decoding JSON response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const jsonModeBody = `[{"host":"a","status":200},{"host":"b","status":500},{"host":"c","status":404}]`
+
+func writeJSONMode(t *testing.T, opts queryOptions) string {
+	opts.outputFile = filepath.Join(t.TempDir(), "results")
+	if err := writeResults(nil, strings.NewReader(jsonModeBody), opts); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	data, err := os.ReadFile(opts.outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func parseNDJSON(t *testing.T, out string) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line is not a JSON record: %q", line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestJSONModesWriteSameRecords(t *testing.T) {
+	var array []map[string]interface{}
+	out := writeJSONMode(t, queryOptions{outputFormat: "json", jsonArray: true})
+	if err := json.Unmarshal([]byte(out), &array); err != nil {
+		t.Fatalf("--json-array output is not a JSON array: %v\n%s", err, out)
+	}
+
+	lines := parseNDJSON(t, writeJSONMode(t, queryOptions{outputFormat: "ndjson"}))
+
+	if len(array) != 3 || len(lines) != 3 {
+		t.Fatalf("expected 3 records in each mode, got %d and %d", len(array), len(lines))
+	}
+	for idx := range array {
+		if array[idx]["host"] != lines[idx]["host"] {
+			t.Errorf("record %d differs between modes: %v and %v", idx, array[idx], lines[idx])
+		}
+	}
+}
+
+func TestJSONOutputSwitchesToNDJSONWhenLarge(t *testing.T) {
+	defer func(rows int) { autoNDJSONRows = rows }(autoNDJSONRows)
+
+	autoNDJSONRows = 5
+	var array []map[string]interface{}
+	out := writeJSONMode(t, queryOptions{outputFormat: "json"})
+	if err := json.Unmarshal([]byte(out), &array); err != nil || len(array) != 3 {
+		t.Fatalf("expected a small result to stay a JSON array, got %v\n%s", err, out)
+	}
+
+	autoNDJSONRows = 2
+	if records := parseNDJSON(t, writeJSONMode(t, queryOptions{outputFormat: "json"})); len(records) != 3 {
+		t.Errorf("expected a large result to be written as 3 ndjson lines, got %d", len(records))
+	}
+
+	out = writeJSONMode(t, queryOptions{outputFormat: "json", jsonArray: true})
+	if err := json.Unmarshal([]byte(out), &array); err != nil || len(array) != 3 {
+		t.Errorf("expected --json-array to keep a single array, got %v\n%s", err, out)
+	}
+}
+
+func TestResolveJSONMode(t *testing.T) {
+	cases := []struct {
+		args      []string
+		format    string
+		expected  string
+		jsonArray bool
+		fails     bool
+	}{
+		{args: []string{"--json-array"}, expected: "json", jsonArray: true},
+		{args: []string{"--ndjson"}, format: "ndjson", expected: "ndjson"},
+		{args: nil, format: "json", expected: "json"},
+		{args: []string{"--json-array", "--ndjson"}, fails: true},
+		{args: []string{"--ndjson"}, format: "csv", fails: true},
+	}
+	for _, c := range cases {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.Bool(jsonArrayFlag, false, "")
+		flags.Bool(ndjsonFlag, false, "")
+		flags.Parse(c.args)
+
+		format, jsonArray, err := resolveJSONMode(flags, c.format)
+		if c.fails {
+			if err == nil {
+				t.Errorf("%v with %q: expected an error", c.args, c.format)
+			}
+			continue
+		}
+		if err != nil || format != c.expected || jsonArray != c.jsonArray {
+			t.Errorf("%v with %q: got %q, %v, %v", c.args, c.format, format, jsonArray, err)
+		}
+	}
+}
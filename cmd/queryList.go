@@ -47,12 +47,13 @@ var SavedQueryList = &cobra.Command{
 				fmt.Println("Error reading Default Profile")
 			}
 			var userProfile config.Profile
-			if profile, ok := userConfig.Profiles[userConfig.DefaultProfile]; ok {
+			if profile, err := userConfig.Default(); err == nil {
 				userProfile = profile
 			}
 
 			client := &http.Client{
-				Timeout: time.Second * 60,
+				Timeout:   time.Second * 60,
+				Transport: internalHTTP.Transport(&userProfile),
 			}
 			userSavedQueries := fetchFilters(client, &userProfile)
 			// Collect all filter titles in a slice and join with commas
@@ -228,7 +229,12 @@ func fetchFilters(client *http.Client, profile *config.Profile) []Item {
 		return nil
 	}
 
-	req.SetBasicAuth(profile.Username, profile.Password)
+	auth, err := internalHTTP.AuthHeader(profile)
+	if err != nil {
+		fmt.Println("Error creating request:", err)
+		return nil
+	}
+	req.Header.Set("Authorization", auth)
 	req.Header.Add("Content-Type", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
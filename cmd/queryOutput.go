@@ -0,0 +1,214 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// validateOutputFileOptions checks that the output file flags make sense
+// together before the query is sent
+func validateOutputFileOptions(opts queryOptions) error {
+	switch opts.outputFormat {
+	case "", "text", "json", "ndjson", "csv", "table", "xlsx":
+	default:
+		return fmt.Errorf("unsupported output format %q. Supported formats are text, json, ndjson, csv, table and xlsx", opts.outputFormat)
+	}
+
+	if opts.outputFormat == "xlsx" {
+		if writesToStdout(opts) {
+			return fmt.Errorf("xlsx output is written to a file, pass --%s", outputFileFlag)
+		}
+		if opts.appendOutput {
+			return fmt.Errorf("--%s is not supported with xlsx output", appendFlag)
+		}
+	}
+
+	if opts.showTypes && opts.outputFormat != "table" {
+		return fmt.Errorf("--%s only applies to table output, use --%s with json, ndjson or csv output", showTypesFlag, typesFileFlag)
+	}
+	if opts.typesFile != "" && opts.outputFormat != "json" && opts.outputFormat != "ndjson" && opts.outputFormat != "csv" {
+		return fmt.Errorf("--%s only applies to json, ndjson or csv output", typesFileFlag)
+	}
+
+	if opts.checksum {
+		if opts.outputFile == "" {
+			return fmt.Errorf("--%s requires --%s", checksumFlag, outputFileFlag)
+		}
+		if opts.appendOutput {
+			return fmt.Errorf("--%s cannot be used with --%s, the checksum would only cover the appended records", checksumFlag, appendFlag)
+		}
+		if _, err := newChecksumHash(opts.checksumAlgo); err != nil {
+			return err
+		}
+	}
+
+	if opts.noAtomic && opts.outputFile == "" {
+		return fmt.Errorf("--%s requires --%s", noAtomicFlag, outputFileFlag)
+	}
+
+	if opts.appendOutput {
+		if opts.outputFile == "" {
+			return fmt.Errorf("--%s requires --%s", appendFlag, outputFileFlag)
+		}
+		if opts.outputFormat == "" || opts.outputFormat == "text" || opts.outputFormat == "table" {
+			return fmt.Errorf("--%s is only supported with json, ndjson or csv output", appendFlag)
+		}
+	}
+	return nil
+}
+
+// writeRecords renders records to w in the given format. For csv, columns
+// fixes the column order; when nil the header is derived from the records and
+// written first
+func writeRecords(w io.Writer, records []map[string]interface{}, format string, columns []string) error {
+	var writer ResultWriter
+	if format == "csv" {
+		writer = newCSVResultWriter(w, columns)
+	} else {
+		var err error
+		if writer, err = newResultWriter(w, format, false, cellLayout{}); err != nil {
+			return err
+		}
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// appendRecordsToFile adds records to an existing results file while keeping
+// it valid for its format:
+//   - ndjson: records are appended as new lines
+//   - csv: the header is written only when the file is new or empty, and
+//     appended rows follow the column order of the existing header. Records
+//     with fields the header has no column for are refused, not cut down
+//   - json: the existing array is read back and the new records are merged
+//     into it before the file is rewritten
+func appendRecordsToFile(path string, records []map[string]interface{}, format string) error {
+	switch format {
+	case "json":
+		existing, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read output file: %w", err)
+		}
+		var merged []map[string]interface{}
+		if len(existing) > 0 {
+			if err := json.Unmarshal(existing, &merged); err != nil {
+				return fmt.Errorf("cannot append to %s, it does not contain a JSON array: %w", path, err)
+			}
+		}
+		merged = append(merged, records...)
+		// the merged array replaces the file, write it atomically so a
+		// failure cannot lose the records appended by earlier runs
+		file, err := createAtomic(path)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		if err := writeRecords(file, merged, format, nil); err != nil {
+			file.discard()
+			return err
+		}
+		return file.commit()
+	case "csv":
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer file.Close()
+		// only the header is read, so appending to a large file stays cheap
+		var columns []string
+		if info, err := file.Stat(); err == nil && info.Size() > 0 {
+			columns, err = csv.NewReader(file).Read()
+			if err != nil {
+				return fmt.Errorf("cannot append to %s, failed to read CSV header: %w", path, err)
+			}
+			if missing := missingColumns(columns, records); len(missing) > 0 {
+				return fmt.Errorf("cannot append to %s, its CSV header has no column for %s. Write the results to a new file to keep these fields", path, strings.Join(missing, ", "))
+			}
+		}
+		return writeRecords(file, records, format, columns)
+	default:
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer file.Close()
+		return writeRecords(file, records, format, nil)
+	}
+}
+
+// missingColumns returns the sorted fields of records that are not among
+// columns
+func missingColumns(columns []string, records []map[string]interface{}) []string {
+	known := make(map[string]struct{}, len(columns))
+	for _, column := range columns {
+		known[column] = struct{}{}
+	}
+	var missing []string
+	for _, column := range recordColumns(records) {
+		if _, ok := known[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	return missing
+}
+
+// recordColumns returns the sorted union of keys across all records
+func recordColumns(records []map[string]interface{}) []string {
+	seen := make(map[string]struct{})
+	var columns []string
+	for _, record := range records {
+		for key := range record {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// csvValue formats a single JSON value as a CSV cell. Nested values are
+// written as JSON
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	default:
+		return fmt.Sprint(v)
+	}
+}
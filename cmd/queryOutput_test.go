@@ -0,0 +1,165 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	firstBatch = []map[string]interface{}{
+		{"host": "a", "status": float64(200)},
+	}
+	secondBatch = []map[string]interface{}{
+		{"host": "b", "status": float64(500)},
+	}
+)
+
+func appendBatches(t *testing.T, format string) string {
+	path := filepath.Join(t.TempDir(), "results."+format)
+	for _, batch := range [][]map[string]interface{}{firstBatch, secondBatch} {
+		if err := appendRecordsToFile(path, batch, format); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return string(data)
+}
+
+func TestAppendNDJSON(t *testing.T) {
+	out := appendBatches(t, "ndjson")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d:\n%s", len(lines), out)
+	}
+	for _, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Errorf("line is not valid JSON: %q", line)
+		}
+	}
+}
+
+func TestAppendCSVWritesHeaderOnce(t *testing.T) {
+	out := appendBatches(t, "csv")
+	expected := "host,status\na,200\nb,500\n"
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestAppendCSVFollowsExistingHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	if err := os.WriteFile(path, []byte("status,host\n200,a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendRecordsToFile(path, secondBatch, "csv"); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	expected := "status,host\n200,a\n500,b\n"
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(data))
+	}
+}
+
+func TestAppendCSVRefusesNewColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	if err := os.WriteFile(path, []byte("host\na\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := appendRecordsToFile(path, []map[string]interface{}{{"host": "b", "status": float64(500), "zone": "eu"}}, "csv")
+	if err == nil || !strings.Contains(err.Error(), "status, zone") {
+		t.Errorf("expected the columns missing from the header to be listed, got %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "host\na\n" {
+		t.Errorf("expected the file to be left unchanged, got:\n%s", data)
+	}
+}
+
+func TestAppendJSONMergesArrays(t *testing.T) {
+	out := appendBatches(t, "json")
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &records); err != nil {
+		t.Fatalf("output is not a single JSON array: %v\n%s", err, out)
+	}
+	if len(records) != 2 || records[0]["host"] != "a" || records[1]["host"] != "b" {
+		t.Errorf("unexpected merged records: %v", records)
+	}
+}
+
+func TestAppendRequiresStructuredFormat(t *testing.T) {
+	err := validateOutputFileOptions(queryOptions{outputFile: "out.txt", appendOutput: true})
+	if err == nil {
+		t.Error("expected --append with text output to be rejected")
+	}
+
+	err = validateOutputFileOptions(queryOptions{outputFormat: "csv", appendOutput: true})
+	if err == nil {
+		t.Error("expected --append without --output-file to be rejected")
+	}
+}
+
+func TestPrettyRejectsNDJSON(t *testing.T) {
+	err := validatePrettyOptions(queryOptions{outputFormat: "ndjson", pretty: true})
+	if err == nil || !strings.Contains(err.Error(), "--pretty") {
+		t.Errorf("expected --pretty with ndjson output to be rejected, got %v", err)
+	}
+
+	if err := validatePrettyOptions(queryOptions{outputFormat: "json", pretty: true}); err != nil {
+		t.Errorf("expected --pretty with json output to pass, got %v", err)
+	}
+}
+
+func TestWriteTableShowsKnownTypes(t *testing.T) {
+	var out strings.Builder
+	records := []map[string]interface{}{{"host": "a", "total": float64(3)}}
+	if err := writeTable(&out, records, map[string]string{"host": "string"}, cellLayout{}); err != nil {
+		t.Fatal(err)
+	}
+
+	header := strings.Split(out.String(), "\n")[1]
+	if !strings.Contains(header, "host (string)") {
+		t.Errorf("expected typed header, got %q", header)
+	}
+	if !strings.Contains(header, "total") || strings.Contains(header, "total (") {
+		t.Errorf("expected untyped column without annotation, got %q", header)
+	}
+}
+
+func TestResultColumnTypes(t *testing.T) {
+	types := recordTypes([]map[string]interface{}{
+		{"at": "2024-01-02T12:00:00.000", "host": "a", "total": float64(3), "ratio": float64(1), "ok": true, "tags": []interface{}{"x"}, "value": "1"},
+		{"at": "2024-01-02T12:01:00.000", "host": "2024-01-02T12:00:00.000", "total": nil, "ratio": 0.5, "ok": false, "value": float64(1)},
+	})
+	expected := map[string]string{
+		"at": "datetime", "host": "string", "total": "int64", "ratio": "float64",
+		"ok": "bool", "tags": "list", "value": "mixed",
+	}
+	for column, want := range expected {
+		if types[column] != want {
+			t.Errorf("column %s: expected %s, got %s", column, want, types[column])
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/dustin/go-humanize"
+	"github.com/manifoldco/promptui"
+	"golang.org/x/term"
+)
+
+var (
+	warnOverFlag    = "warn-over"
+	forceQueryFlag  = "force"
+	noPreflightFlag = "no-preflight"
+)
+
+// preflightOptions configures the result size check run before a query
+type preflightOptions struct {
+	// warnOver is the number of rows above which the download must be
+	// confirmed, 0 disables the check
+	warnOver int64
+	// force downloads large results without asking, after a warning
+	force bool
+	// skip disables the check, even when warnOver is set
+	skip bool
+}
+
+// enabled reports whether the check runs for a query with the given limit. A
+// limit at or below the threshold already bounds the result
+func (p preflightOptions) enabled(limit int) bool {
+	return p.warnOver > 0 && !p.skip && (limit <= 0 || int64(limit) > p.warnOver)
+}
+
+// validatePreflightOptions checks --warn-over, --force and --no-preflight
+func validatePreflightOptions(opts queryOptions) error {
+	switch {
+	case opts.preflight.warnOver < 0:
+		return fmt.Errorf("--%s must be 0 or more, got %d", warnOverFlag, opts.preflight.warnOver)
+	case opts.preflight.force && opts.preflight.warnOver == 0:
+		return fmt.Errorf("--%s requires --%s", forceQueryFlag, warnOverFlag)
+	}
+	return nil
+}
+
+// preflightEstimate asks the server for the plan of sql and returns the rows
+// it expects the query to return, the estimate of the topmost operator of the
+// physical plan that carries one. Plans list operators from the output down
+// to the scans, so the first estimate is the closest to the result
+func preflightEstimate(client *internalHTTP.HTTPClient, sql, startTime, endTime string) (int64, error) {
+	records, err := queryRecords(client, "EXPLAIN "+trimStatement(sql), startTime, endTime)
+	if err != nil {
+		return 0, err
+	}
+	plans, ok := planTexts(records)
+	if !ok {
+		return 0, fmt.Errorf("the server returned a plan in an unrecognized shape")
+	}
+	for _, plan := range plans {
+		if plan[0] != "physical_plan" {
+			continue
+		}
+		for _, line := range strings.Split(plan[1], "\n") {
+			if match := rowEstimatePattern.FindStringSubmatch(line); match != nil {
+				return strconv.ParseInt(match[1], 10, 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("the query plan has no row estimates")
+}
+
+// checkResultSize estimates the rows the query returns from its plan and
+// stops when there are more than --warn-over, unless confirm, which is nil
+// when there is no terminal to ask on, agrees or --force is set. When there
+// is no estimate the query is treated the same way, so that scripts fail
+// rather than download a result of unknown size. --no-preflight skips the
+// check
+func checkResultSize(client *internalHTTP.HTTPClient, opts queryOptions, startTime, endTime string, confirm func(string) bool) error {
+	if !opts.preflight.enabled(opts.limit) {
+		return nil
+	}
+
+	var message string
+	estimate, err := preflightEstimate(client, opts.query, startTime, endTime)
+	switch {
+	case err != nil:
+		message = fmt.Sprintf("Could not estimate the result size: %s", strings.TrimSpace(err.Error()))
+	case estimate <= opts.preflight.warnOver:
+		return nil
+	default:
+		message = fmt.Sprintf("The query returns about %s rows, more than --%s=%s", humanize.Comma(estimate), warnOverFlag, humanize.Comma(opts.preflight.warnOver))
+	}
+
+	switch {
+	case opts.preflight.force:
+		fmt.Fprintf(os.Stderr, "Warning: %s, downloading the result because of --%s\n", message, forceQueryFlag)
+		return nil
+	case confirm != nil:
+		if confirm(message + ". Download the result anyway") {
+			return nil
+		}
+		return fmt.Errorf("%s, the query was not run", message)
+	case err != nil:
+		return fmt.Errorf("%s. Pass --%s to run the query without the check, or --%s to download the result anyway", message, noPreflightFlag, forceQueryFlag)
+	}
+	return fmt.Errorf("%s. Add --%s or a smaller time range, or pass --%s to download them anyway", message, limitFlag, forceQueryFlag)
+}
+
+// confirmLargeResult asks on the terminal whether to download a large
+// result. It returns nil when stdin or stderr is not a terminal, so that
+// scripts fail instead of waiting for an answer
+func confirmLargeResult() func(string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return func(label string) bool {
+		_, err := (&promptui.Prompt{Label: label, IsConfirm: true, Stdout: os.Stderr}).Run()
+		return err == nil
+	}
+}
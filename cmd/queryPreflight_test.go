@@ -0,0 +1,141 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+// planServer answers EXPLAIN queries with a plan estimating count rows, or
+// a plan without statistics when count is negative, and counts them
+func planServer(t *testing.T, count int, queries *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if !strings.HasPrefix(body["query"], "EXPLAIN ") {
+			t.Errorf("unexpected pre-flight query %q", body["query"])
+		}
+		*queries++
+		plan := "CoalescePartitionsExec\n  DataSourceExec: file_groups={1 group}"
+		if count >= 0 {
+			plan = fmt.Sprintf("CoalescePartitionsExec, statistics=[Rows=Inexact(%d)]\n  DataSourceExec: file_groups={1 group}, statistics=[Rows=Inexact(%d)]", count, count*4)
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"plan_type": "logical_plan", "plan": "TableScan: app"},
+			{"plan_type": "physical_plan", "plan": plan},
+		})
+	}))
+}
+
+func preflightQuery(warnOver int64, limit int, force bool) queryOptions {
+	return queryOptions{query: "select * from app", limit: limit, preflight: preflightOptions{warnOver: warnOver, force: force}}
+}
+
+func TestPreflightAbortsLargeResult(t *testing.T) {
+	var queries int
+	server := planServer(t, 250000, &queries)
+	defer server.Close()
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	// no terminal to ask on
+	err := checkResultSize(&client, preflightQuery(100000, 0, false), "1h", "now", nil)
+	if err == nil || !strings.Contains(err.Error(), "about 250,000 rows") || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("expected the query to be stopped with a hint at --force, got %v", err)
+	}
+
+	// the user declines
+	err = checkResultSize(&client, preflightQuery(100000, 0, false), "1h", "now", func(string) bool { return false })
+	if err == nil || !strings.Contains(err.Error(), "not run") {
+		t.Errorf("expected a declined download to stop the query, got %v", err)
+	}
+}
+
+func TestPreflightWarnsAndContinues(t *testing.T) {
+	var queries int
+	server := planServer(t, 250000, &queries)
+	defer server.Close()
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	var asked string
+	err := checkResultSize(&client, preflightQuery(100000, 0, false), "1h", "now", func(label string) bool {
+		asked = label
+		return true
+	})
+	if err != nil {
+		t.Errorf("expected a confirmed download to continue, got %v", err)
+	}
+	if !strings.Contains(asked, "--warn-over=100,000") {
+		t.Errorf("expected the prompt to name the threshold, got %q", asked)
+	}
+
+	if err := checkResultSize(&client, preflightQuery(100000, 0, true), "1h", "now", nil); err != nil {
+		t.Errorf("expected --force to download without asking, got %v", err)
+	}
+	if err := checkResultSize(&client, preflightQuery(300000, 0, false), "1h", "now", nil); err != nil {
+		t.Errorf("expected a result under the threshold to pass, got %v", err)
+	}
+}
+
+func TestPreflightSkipped(t *testing.T) {
+	var queries int
+	server := planServer(t, 250000, &queries)
+	defer server.Close()
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	skipped := preflightQuery(100000, 0, false)
+	skipped.preflight.skip = true
+	for _, opts := range []queryOptions{skipped, preflightQuery(100000, 1000, false), preflightQuery(0, 0, false)} {
+		if err := checkResultSize(&client, opts, "1h", "now", nil); err != nil {
+			t.Errorf("expected no check, got %v", err)
+		}
+	}
+	if queries != 0 {
+		t.Errorf("expected no pre-flight query with --no-preflight, a small --limit or no threshold, got %d", queries)
+	}
+}
+
+func TestPreflightFailsClosedWithoutEstimate(t *testing.T) {
+	var queries int
+	server := planServer(t, -1, &queries)
+	defer server.Close()
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	err := checkResultSize(&client, preflightQuery(100000, 0, false), "1h", "now", nil)
+	if err == nil || !strings.Contains(err.Error(), "Could not estimate") || !strings.Contains(err.Error(), "--no-preflight") {
+		t.Errorf("expected a missing estimate to stop the query with a hint at --no-preflight, got %v", err)
+	}
+
+	var asked string
+	err = checkResultSize(&client, preflightQuery(100000, 0, false), "1h", "now", func(label string) bool {
+		asked = label
+		return true
+	})
+	if err != nil || !strings.Contains(asked, "Could not estimate") {
+		t.Errorf("expected a missing estimate to be confirmed on the terminal, got %v after %q", err, asked)
+	}
+
+	if err := checkResultSize(&client, preflightQuery(100000, 0, true), "1h", "now", nil); err != nil {
+		t.Errorf("expected --force to run the query without an estimate, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"pb/pkg/common"
+
+	"golang.org/x/term"
+)
+
+var (
+	prettyFlag  = "pretty"
+	noColorFlag = "no-color"
+)
+
+// validatePrettyOptions rejects --pretty with formats that are not a single
+// JSON document
+func validatePrettyOptions(opts queryOptions) error {
+	if !opts.pretty {
+		return nil
+	}
+	switch opts.outputFormat {
+	case "ndjson", "csv", "table", "xlsx":
+		return fmt.Errorf("--%s cannot be used with %s output, it only applies to text and json output", prettyFlag, opts.outputFormat)
+	}
+	if opts.appendOutput {
+		return fmt.Errorf("--%s cannot be used with --%s", prettyFlag, appendFlag)
+	}
+	return nil
+}
+
+// writePretty indents the JSON response body and writes it to stdout or the
+// output file. Stdout output is highlighted when it is a terminal, unless
+// --no-color or NO_COLOR is set. Text output that is not valid JSON is written
+// unchanged
+func writePretty(body io.Reader, opts queryOptions) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		if opts.outputFormat == "json" {
+			return fmt.Errorf("error decoding JSON response: %w", err)
+		}
+		indented.Reset()
+		indented.Write(data)
+	} else {
+		indented.WriteByte('\n')
+	}
+
+	if !writesToStdout(opts) {
+		file, err := openOutput(opts)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(indented.Bytes()); err != nil {
+			file.abort()
+			return err
+		}
+		return file.Close()
+	}
+
+	output := indented.Bytes()
+	if !opts.noColor && os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd())) {
+		output = colorizeJSON(output)
+	}
+	_, err = os.Stdout.Write(output)
+	return err
+}
+
+// colorizeJSON adds ANSI colors to indented JSON: keys in blue, strings in
+// green, numbers in cyan and true, false and null in yellow
+func colorizeJSON(data []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(data) && data[end] != '"' {
+				if data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(data))
+
+			color := common.Green
+			next := end
+			for next < len(data) && (data[next] == ' ' || data[next] == '\n') {
+				next++
+			}
+			if next < len(data) && data[next] == ':' {
+				color = common.Blue
+			}
+			out.WriteString(color)
+			out.Write(data[i:end])
+			out.WriteString(common.Reset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i
+			for end < len(data) && bytes.IndexByte([]byte("+-.0123456789eE"), data[end]) >= 0 {
+				end++
+			}
+			out.WriteString(common.Cyan)
+			out.Write(data[i:end])
+			out.WriteString(common.Reset)
+			i = end
+		case c == 't' || c == 'f' || c == 'n':
+			end := i
+			for end < len(data) && data[end] >= 'a' && data[end] <= 'z' {
+				end++
+			}
+			out.WriteString(common.Yellow)
+			out.Write(data[i:end])
+			out.WriteString(common.Reset)
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
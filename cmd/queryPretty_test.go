@@ -0,0 +1,50 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"testing"
+
+	"pb/pkg/common"
+)
+
+func TestColorizeJSON(t *testing.T) {
+	input := "{\n  \"host\": \"a:b\",\n  \"count\": -1.5e3,\n  \"ok\": true,\n  \"gone\": null\n}"
+	expected := "{\n  " +
+		common.Blue + `"host"` + common.Reset + ": " + common.Green + `"a:b"` + common.Reset + ",\n  " +
+		common.Blue + `"count"` + common.Reset + ": " + common.Cyan + "-1.5e3" + common.Reset + ",\n  " +
+		common.Blue + `"ok"` + common.Reset + ": " + common.Yellow + "true" + common.Reset + ",\n  " +
+		common.Blue + `"gone"` + common.Reset + ": " + common.Yellow + "null" + common.Reset + "\n}"
+	if got := string(colorizeJSON([]byte(input))); got != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, got)
+	}
+}
+
+func TestColorizeJSONEscapedQuotes(t *testing.T) {
+	input := `["say \"hi\": 1", "\\"]`
+	expected := "[" + common.Green + `"say \"hi\": 1"` + common.Reset + ", " + common.Green + `"\\"` + common.Reset + "]"
+	if got := string(colorizeJSON([]byte(input))); got != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, got)
+	}
+}
+
+func TestColorizeJSONUnterminatedString(t *testing.T) {
+	input := `["open`
+	expected := "[" + common.Green + `"open` + common.Reset
+	if got := string(colorizeJSON([]byte(input))); got != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, got)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	rawFlag         = "raw"
+	showHeadersFlag = "show-headers"
+)
+
+// validateRawOptions rejects flags that parse or reformat the response, as
+// --raw passes it through untouched
+func validateRawOptions(opts queryOptions) error {
+	if !opts.raw {
+		return nil
+	}
+
+	var conflicts []string
+	if opts.outputFormat != "" && opts.outputFormat != "text" {
+		conflicts = append(conflicts, "--output")
+	}
+	if opts.pretty {
+		conflicts = append(conflicts, "--"+prettyFlag)
+	}
+	if opts.appendOutput {
+		conflicts = append(conflicts, "--"+appendFlag)
+	}
+	if opts.showTypes || opts.typesFile != "" {
+		conflicts = append(conflicts, "--"+showTypesFlag+"/--"+typesFileFlag)
+	}
+	if opts.cellLayout.maxWidth > 0 || opts.cellLayout.wrap {
+		conflicts = append(conflicts, "--"+maxColWidthFlag+"/--"+wrapFlag)
+	}
+	if opts.location != nil {
+		conflicts = append(conflicts, "--"+timezoneFlag)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("--%s prints the response untouched and cannot be combined with %s", rawFlag, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// rawErrorOutput returns where --raw prints the body of a failed request.
+// It is not a result, so it goes to stderr rather than to --output-file or
+// --output-url
+func rawErrorOutput(opts queryOptions) io.Writer {
+	if writesToStdout(opts) {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// writeResponseHeaders dumps the status line and headers of resp to w, with
+// header names sorted so repeated runs are easy to compare
+func writeResponseHeaders(w io.Writer, resp *http.Response) {
+	fmt.Fprintf(w, "%s %s\n", resp.Proto, resp.Status)
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range resp.Header[name] {
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+	fmt.Fprintln(w)
+}
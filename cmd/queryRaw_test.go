@@ -0,0 +1,61 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRawRejectsFormatting(t *testing.T) {
+	if err := validateRawOptions(queryOptions{raw: true, outputFile: "out.json"}); err != nil {
+		t.Errorf("expected --raw with --output-file to pass, got %v", err)
+	}
+
+	err := validateRawOptions(queryOptions{raw: true, outputFormat: "csv", pretty: true})
+	if err == nil || !strings.Contains(err.Error(), "--output") || !strings.Contains(err.Error(), "--pretty") {
+		t.Errorf("expected --raw with --output and --pretty to be rejected, got %v", err)
+	}
+}
+
+func TestWriteResponseHeadersSorted(t *testing.T) {
+	resp := &http.Response{
+		Proto:  "HTTP/1.1",
+		Status: "200 OK",
+		Header: http.Header{"X-P-Rows-Scanned": {"10"}, "Content-Type": {"application/json"}},
+	}
+
+	var out strings.Builder
+	writeResponseHeaders(&out, resp)
+	expected := "HTTP/1.1 200 OK\nContent-Type: application/json\nX-P-Rows-Scanned: 10\n\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, out.String())
+	}
+}
+
+func TestRawErrorBodyKeepsOutOfOutputFile(t *testing.T) {
+	if rawErrorOutput(queryOptions{raw: true}) != os.Stdout {
+		t.Error("expected a failed request body on stdout without an output file")
+	}
+	if rawErrorOutput(queryOptions{raw: true, outputFile: "out.json"}) != os.Stderr {
+		t.Error("expected a failed request body on stderr with --output-file")
+	}
+	if rawErrorOutput(queryOptions{raw: true, outputURL: "s3://bucket/out.json"}) != os.Stderr {
+		t.Error("expected a failed request body on stderr with --output-url")
+	}
+}
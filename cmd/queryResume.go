@@ -0,0 +1,205 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+)
+
+var resumeFlag = "resume"
+
+// exportCheckpoint records how far a --resume export got. The windows are
+// stored as absolute times, so that a relative range such as --from=30d
+// still covers the same windows when the export is resumed later
+type exportCheckpoint struct {
+	Query  string    `json:"query"`
+	Format string    `json:"format"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Chunk  string    `json:"chunk"`
+	// Completed is the number of windows written to the output file
+	Completed int `json:"completed"`
+	Rows      int `json:"rows"`
+	// Size is the size of the output file after the last completed window.
+	// Anything after it was written by a window that did not complete
+	Size int64 `json:"size"`
+	// Boundary holds the rows of the last completed window on its end time
+	Boundary []string `json:"boundary,omitempty"`
+}
+
+// checkpointPath returns the checkpoint file of an output file
+func checkpointPath(outputFile string) string {
+	return outputFile + ".checkpoint"
+}
+
+// matches reports whether the checkpoint was written by the same export
+func (c exportCheckpoint) matches(opts queryOptions) bool {
+	return c.Query == opts.query && c.Format == opts.outputFormat && c.Chunk == opts.chunk.String()
+}
+
+// validateResumeOptions checks that --resume is used for an export it can
+// continue: chunked, to a file, in a format that can be appended to
+func validateResumeOptions(opts queryOptions) error {
+	if !opts.resume {
+		return nil
+	}
+	switch {
+	case opts.chunk == 0 || opts.outputFile == "":
+		return fmt.Errorf("--%s requires --%s and --%s", resumeFlag, chunkFlag, outputFileFlag)
+	case opts.outputFormat != "ndjson" && opts.outputFormat != "csv":
+		return fmt.Errorf("--%s only works with ndjson or csv output, which can be continued where they stopped", resumeFlag)
+	case opts.appendOutput || opts.checksum || len(opts.dedup.fields) > 0:
+		return fmt.Errorf("--%s cannot be combined with --%s, --%s or --%s", resumeFlag, appendFlag, checksumFlag, deduplicateFlag)
+	}
+	return nil
+}
+
+func readCheckpoint(path string) (exportCheckpoint, error) {
+	var checkpoint exportCheckpoint
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return checkpoint, err
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("checkpoint %s is damaged, delete it to start the export again: %w", path, err)
+	}
+	return checkpoint, nil
+}
+
+// writeCheckpoint replaces the checkpoint atomically, so that an
+// interruption never leaves a half written one behind
+func writeCheckpoint(path string, checkpoint exportCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	file, err := createAtomic(path)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.discard()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return file.commit()
+}
+
+// resumeExport runs a chunked export to --output-file, one window at a
+// time, and records each completed window in a checkpoint next to the
+// output. When a checkpoint of the same export exists, the output is cut
+// back to the last completed window and the export continues from the
+// next one. The checkpoint is removed once every window is written
+func resumeExport(client *internalHTTP.HTTPClient, opts queryOptions, start, end time.Time, progress io.Writer) error {
+	path := checkpointPath(opts.outputFile)
+	checkpoint, err := readCheckpoint(path)
+	switch {
+	case err == nil && !checkpoint.matches(opts):
+		return fmt.Errorf("checkpoint %s belongs to a different export, delete it or choose another --%s", path, outputFileFlag)
+	case err == nil:
+		if err := os.Truncate(opts.outputFile, checkpoint.Size); err != nil {
+			return fmt.Errorf("cannot resume, failed to restore %s: %w", opts.outputFile, err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		checkpoint = exportCheckpoint{Query: opts.query, Format: opts.outputFormat, Start: start, End: end, Chunk: opts.chunk.String()}
+		file, err := os.Create(opts.outputFile)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		file.Close()
+	default:
+		return err
+	}
+
+	windows := chunkWindows(checkpoint.Start, checkpoint.End, opts.chunk)
+	first := checkpoint.Completed
+	if first > 0 {
+		fmt.Fprintf(progress, "Resuming after chunk %d/%d, %d rows already written\n", first, len(windows), checkpoint.Rows)
+	}
+
+	state := chunkState{limit: opts.limit, written: checkpoint.Rows, boundary: make(map[string]struct{})}
+	for _, row := range checkpoint.Boundary {
+		state.boundary[row] = struct{}{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fetches := fetchChunks(ctx, client, opts, windows[first:])
+	read := 0
+	defer func() {
+		cancel()
+		fetches.discard(read)
+	}()
+
+	// each window is appended to the output as soon as it is complete
+	windowOpts := opts
+	windowOpts.appendOutput = true
+	for offset, window := range windows[first:] {
+		idx := first + offset
+		if state.full() {
+			break
+		}
+		chunk := <-fetches.chunks[offset]
+		read++
+		if chunk.err != nil {
+			return chunkError(idx, len(windows), window, chunk.err)
+		}
+
+		var rows bytes.Buffer
+		rows.WriteString("[")
+		written, skipped, err := state.writeChunk(chunk.body, window, func(encoded []byte) error {
+			if rows.Len() > 1 {
+				rows.WriteString(",")
+			}
+			_, err := rows.Write(encoded)
+			return err
+		})
+		chunk.body.Close()
+		chunk.release()
+		if err != nil {
+			return chunkError(idx, len(windows), window, err)
+		}
+		rows.WriteString("]")
+		if err := writeResults(client, &rows, windowOpts); err != nil {
+			return err
+		}
+
+		info, err := os.Stat(opts.outputFile)
+		if err != nil {
+			return err
+		}
+		checkpoint.Completed, checkpoint.Rows, checkpoint.Size = idx+1, state.written, info.Size()
+		checkpoint.Boundary = checkpoint.Boundary[:0]
+		for row := range state.boundary {
+			checkpoint.Boundary = append(checkpoint.Boundary, row)
+		}
+		sort.Strings(checkpoint.Boundary)
+		if err := writeCheckpoint(path, checkpoint); err != nil {
+			return err
+		}
+		printChunkProgress(progress, idx, len(windows), window, written, skipped)
+	}
+
+	return os.Remove(path)
+}
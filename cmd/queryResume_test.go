@@ -0,0 +1,140 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestResumeExportAfterInterruption(t *testing.T) {
+	events := []string{
+		"2024-03-01T06:00:00Z",
+		"2024-03-02T00:00:00Z",
+		"2024-03-02T12:00:00Z",
+		"2024-03-03T06:00:00Z",
+		"2024-03-03T18:00:00Z",
+		"2024-03-04T06:00:00Z",
+	}
+	interrupt := true
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		requested = append(requested, body["startTime"])
+		start, _ := time.Parse(time.RFC3339, body["startTime"])
+		end, _ := time.Parse(time.RFC3339, body["endTime"])
+
+		var records []map[string]interface{}
+		for _, event := range events {
+			ts, _ := time.Parse(time.RFC3339, event)
+			if !ts.Before(start) && !ts.After(end) {
+				records = append(records, map[string]interface{}{"p_timestamp": event})
+			}
+		}
+		data, _ := json.Marshal(records)
+		if interrupt && body["startTime"] == "2024-03-03T00:00:00Z" {
+			// the connection drops in the middle of the third window
+			w.Write(data[:len(data)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	output := filepath.Join(t.TempDir(), "export.ndjson")
+	opts := queryOptions{query: "select * from app", outputFormat: "ndjson", outputFile: output, chunk: 24 * time.Hour, resume: true}
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	err := resumeExport(&client, opts, start, start.Add(96*time.Hour), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "chunk 3 of 4") {
+		t.Fatalf("expected the third window to fail, got %v", err)
+	}
+	checkpoint, err := readCheckpoint(checkpointPath(output))
+	if err != nil {
+		t.Fatalf("expected a checkpoint after the interruption: %v", err)
+	}
+	if checkpoint.Completed != 2 || checkpoint.Rows != 3 {
+		t.Errorf("expected 2 windows and 3 rows recorded, got %+v", checkpoint)
+	}
+
+	// a partly written window is cut off again when resuming
+	file, _ := os.OpenFile(output, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString(`{"p_timestamp":"2024-03-03T06:00:00Z"}` + "\n" + `{"p_timest`)
+	file.Close()
+
+	interrupt = false
+	requested = nil
+	// the range is taken from the checkpoint, so a relative --from that
+	// has moved on since does not shift the windows
+	var progress strings.Builder
+	if err := resumeExport(&client, opts, start.Add(time.Hour), start.Add(97*time.Hour), &progress); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(requested, ",") != "2024-03-03T00:00:00Z,2024-03-04T00:00:00Z" {
+		t.Errorf("expected only the unfinished windows to be fetched, got %v", requested)
+	}
+	if !strings.Contains(progress.String(), "Resuming after chunk 2/4") {
+		t.Errorf("expected the resume to be reported, got:\n%s", progress.String())
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line is not valid JSON: %q", line)
+		}
+		got = append(got, record["p_timestamp"].(string))
+	}
+	if strings.Join(got, ",") != strings.Join(events, ",") {
+		t.Errorf("expected every event once and in order, got %v", got)
+	}
+	if _, err := os.Stat(checkpointPath(output)); !os.IsNotExist(err) {
+		t.Errorf("expected the checkpoint to be removed, got %v", err)
+	}
+}
+
+func TestResumeRejectsOtherExport(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "export.ndjson")
+	opts := queryOptions{query: "select * from app", outputFormat: "ndjson", outputFile: output, chunk: time.Hour, resume: true}
+	if err := writeCheckpoint(checkpointPath(output), exportCheckpoint{Query: "select * from other", Format: "ndjson", Chunk: "1h0m0s"}); err != nil {
+		t.Fatal(err)
+	}
+	err := resumeExport(nil, opts, time.Now(), time.Now(), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "different export") {
+		t.Errorf("expected a checkpoint of another query to be refused, got %v", err)
+	}
+
+	if err := validateResumeOptions(queryOptions{resume: true, chunk: time.Hour, outputFile: output, outputFormat: "json"}); err == nil {
+		t.Error("expected --resume with json output to be rejected")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+)
+
+var (
+	queryFileFlag   = "file"
+	stopOnErrorFlag = "stop-on-error"
+)
+
+// splitStatements splits SQL text on semicolons into trimmed statements,
+// dropping empty ones. Semicolons inside single quoted strings, double
+// quoted identifiers, -- line comments and /* */ block comments do not end
+// a statement. Doubled single and double quotes are treated as escaped quotes
+func splitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			// copy the quoted section, including doubled quotes
+			end := i + 1
+			for end < len(sql) {
+				if sql[end] == c {
+					if end+1 < len(sql) && sql[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			end = min(end, len(sql)-1)
+			current.WriteString(sql[i : end+1])
+			i = end
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i - 1
+			}
+			current.WriteString(sql[i : i+end+1])
+			i += end
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i - 2
+			} else {
+				end += 2
+			}
+			current.WriteString(sql[i : i+2+end])
+			i += 1 + end
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return statements
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			"single statement without semicolon",
+			"select * from backend",
+			[]string{"select * from backend"},
+		},
+		{
+			"trailing semicolon and blank statements",
+			"select 1;; \n select 2;\n",
+			[]string{"select 1", "select 2"},
+		},
+		{
+			"semicolon in string literal",
+			"select * from backend where msg = 'a;b'; select 2",
+			[]string{"select * from backend where msg = 'a;b'", "select 2"},
+		},
+		{
+			"escaped quote before semicolon",
+			"select * from backend where msg = 'it''s; fine'; select 2",
+			[]string{"select * from backend where msg = 'it''s; fine'", "select 2"},
+		},
+		{
+			"semicolon in quoted identifier",
+			`select "odd;column" from backend; select 2`,
+			[]string{`select "odd;column" from backend`, "select 2"},
+		},
+		{
+			"double quote inside string literal",
+			`select * from backend where msg = 'say "hi;"'; select 2`,
+			[]string{`select * from backend where msg = 'say "hi;"'`, "select 2"},
+		},
+		{
+			"semicolon in comments",
+			"select 1 -- first; not a split\n; /* also; not */ select 2",
+			[]string{"select 1 -- first; not a split", "/* also; not */ select 2"},
+		},
+		{
+			"unterminated string",
+			"select 'abc;",
+			[]string{"select 'abc;"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := splitStatements(c.sql)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+var statsFlag = "stats"
+
+// queryStatsReader counts the bytes and top level records of a query
+// response as it is read. Parseable does not report scan statistics for a
+// query, so these are measured on the response pb receives
+type queryStatsReader struct {
+	r     io.Reader
+	bytes int64
+	rows  int64
+
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+func (s *queryStatsReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.bytes += int64(n)
+	for _, b := range p[:n] {
+		switch {
+		case s.inString:
+			if s.escaped {
+				s.escaped = false
+			} else if b == '\\' {
+				s.escaped = true
+			} else if b == '"' {
+				s.inString = false
+			}
+		case b == '"':
+			s.inString = true
+		case b == '[' || b == '{':
+			// records are the objects directly inside the response array
+			if s.depth == 1 && b == '{' {
+				s.rows++
+			}
+			s.depth++
+		case b == ']' || b == '}':
+			s.depth--
+		}
+	}
+	return n, err
+}
+
+// printQueryStats writes the rows and bytes of the response followed by the
+// round trip time measured by pb
+func printQueryStats(w io.Writer, stats *queryStatsReader, roundTrip time.Duration) {
+	fmt.Fprintln(w, "Query statistics:")
+	fmt.Fprintf(w, "  %-16s %d\n", "rows returned:", stats.rows)
+	fmt.Fprintf(w, "  %-16s %s\n", "bytes received:", humanize.Bytes(uint64(stats.bytes)))
+	fmt.Fprintf(w, "  %-16s %s\n", "round trip:", roundTrip.Round(time.Millisecond))
+}
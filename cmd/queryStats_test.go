@@ -0,0 +1,74 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestQueryStatsCountsResponse(t *testing.T) {
+	response := `[{"msg":"a } ] {\"x\":[1]}","tags":[{"k":"v"}]},{"msg":"b","nested":{"a":{"b":1}}},{}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	req, err := client.NewRequest(http.MethodPost, "query", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	stats := &queryStatsReader{r: resp.Body}
+	if _, err := io.Copy(io.Discard, stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.rows != 3 {
+		t.Errorf("expected 3 rows, got %d", stats.rows)
+	}
+	if stats.bytes != int64(len(response)) {
+		t.Errorf("expected %d bytes, got %d", len(response), stats.bytes)
+	}
+
+	var out strings.Builder
+	printQueryStats(&out, stats, 1500*time.Millisecond)
+	for _, line := range []string{"rows returned:   3", "round trip:      1.5s"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("expected %q in:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestQueryStatsEmptyResult(t *testing.T) {
+	stats := &queryStatsReader{r: strings.NewReader("[]")}
+	io.Copy(io.Discard, stats)
+	if stats.rows != 0 {
+		t.Errorf("expected no rows, got %d", stats.rows)
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+var validateSQLFlag = "validate-sql"
+
+// sqlToken is a word, literal or symbol of a statement, with its byte offset
+// in the query text
+type sqlToken struct {
+	kind string // word, number, string, ident, op, (, ), ",", ;
+	text string
+	pos  int
+}
+
+// sqlIssue is a syntax problem found before the query is sent
+type sqlIssue struct {
+	pos     int
+	message string
+}
+
+var (
+	// sqlCheckedStarts begin statements whose syntax is checked
+	sqlCheckedStarts = map[string]bool{"select": true, "with": true}
+	// sqlUncheckedStarts begin statements the server accepts but whose
+	// syntax pb does not check beyond quotes and parentheses
+	sqlUncheckedStarts = map[string]bool{"explain": true, "show": true, "describe": true, "desc": true, "values": true, "set": true}
+
+	// sqlDanglingWords need something after them
+	sqlDanglingWords = map[string]bool{
+		"select": true, "from": true, "where": true, "and": true, "or": true, "not": true, "by": true,
+		"on": true, "join": true, "having": true, "limit": true, "offset": true, "in": true, "like": true,
+		"between": true, "as": true, "group": true, "order": true, "union": true, "distinct": true,
+		"is": true, "case": true, "when": true, "then": true, "else": true, "with": true,
+	}
+
+	// sqlClauseRank is the order clauses must appear in within one query
+	sqlClauseRank = map[string]int{"from": 1, "where": 2, "group": 3, "having": 4, "order": 5, "limit": 6, "offset": 6}
+
+	// sqlNoRepeat are keywords that are always a mistake twice in a row
+	sqlNoRepeat = map[string]bool{
+		"select": true, "from": true, "where": true, "and": true, "or": true, "by": true,
+		"group": true, "order": true, "limit": true, "having": true, "on": true, "join": true,
+	}
+)
+
+// checkSQLSyntax looks for obvious syntax mistakes in the query text, such
+// as unclosed quotes or parentheses, a comma before FROM, or clauses out of
+// order. Statements or symbols pb does not know are reported as warnings on
+// w and left to the server. Only the first error of each statement is
+// reported, as later ones often follow from it
+func checkSQLSyntax(text string, w io.Writer) error {
+	tokens, issue := tokenizeSQL(text)
+	if issue != nil {
+		return sqlSyntaxError(text, []sqlIssue{*issue})
+	}
+
+	var errs []sqlIssue
+	start := 0
+	for idx := 0; idx <= len(tokens); idx++ {
+		if idx < len(tokens) && tokens[idx].kind != ";" {
+			continue
+		}
+		statement := tokens[start:idx]
+		start = idx + 1
+		if len(statement) == 0 {
+			continue
+		}
+		issue, warning := checkSQLStatement(statement)
+		if warning != nil {
+			line, column := sqlPosition(text, warning.pos)
+			fmt.Fprintf(w, "Warning: line %d, column %d: %s\n", line, column, warning.message)
+		}
+		if issue != nil {
+			errs = append(errs, *issue)
+		}
+	}
+	if len(errs) > 0 {
+		return sqlSyntaxError(text, errs)
+	}
+	return nil
+}
+
+func checkSQLStatement(tokens []sqlToken) (issue, warning *sqlIssue) {
+	first := tokens[0]
+	switch {
+	case first.kind == "word" && sqlUncheckedStarts[first.text]:
+		return checkSQLParens(tokens), &sqlIssue{first.pos, fmt.Sprintf("%s statements are not checked by --%s", strings.ToUpper(first.text), validateSQLFlag)}
+	case first.kind == "(" || (first.kind == "word" && sqlCheckedStarts[first.text]):
+	default:
+		return &sqlIssue{first.pos, fmt.Sprintf("expected SELECT or WITH, found %s", sqlTokenName(first))}, nil
+	}
+
+	for _, token := range tokens {
+		if token.kind == "other" {
+			return checkSQLParens(tokens), &sqlIssue{token.pos, fmt.Sprintf("%q is not understood by --%s, the rest of the statement is left to the server", token.text, validateSQLFlag)}
+		}
+	}
+	if issue := checkSQLParens(tokens); issue != nil {
+		return issue, nil
+	}
+
+	// ranks holds the last clause seen at each parenthesis depth
+	ranks := []int{0}
+	for idx, token := range tokens {
+		var prev, next *sqlToken
+		if idx > 0 {
+			prev = &tokens[idx-1]
+		}
+		if idx+1 < len(tokens) {
+			next = &tokens[idx+1]
+		}
+
+		switch token.kind {
+		case "(":
+			ranks = append(ranks, 0)
+		case ")":
+			ranks = ranks[:len(ranks)-1]
+			if prev != nil && sqlNeedsOperand(*prev) {
+				return &sqlIssue{token.pos, fmt.Sprintf("unexpected ')' after %s", sqlTokenName(*prev))}, nil
+			}
+		case ",":
+			if next == nil {
+				return &sqlIssue{token.pos, "query ends after ','"}, nil
+			}
+			if next.kind == ")" || next.kind == "," || (next.kind == "word" && (sqlClauseRank[next.text] > 0 || next.text == "union" || next.text == "join")) {
+				return &sqlIssue{next.pos, fmt.Sprintf("unexpected %s after ','", sqlTokenName(*next))}, nil
+			}
+		case "word":
+			if prev != nil && prev.kind == "word" && prev.text == token.text && sqlNoRepeat[token.text] {
+				return &sqlIssue{token.pos, fmt.Sprintf("%s appears twice in a row", strings.ToUpper(token.text))}, nil
+			}
+			depth := len(ranks) - 1
+			switch token.text {
+			case "select", "union", "except", "intersect":
+				ranks[depth] = 0
+				continue
+			case "group", "order":
+				// WITHIN GROUP (ORDER BY ...) of ordered set aggregates
+				if prev != nil && prev.text == "within" {
+					continue
+				}
+				if next == nil || next.kind != "word" || next.text != "by" {
+					return &sqlIssue{token.pos, fmt.Sprintf("expected BY after %s", strings.ToUpper(token.text))}, nil
+				}
+			case "from":
+				// IS [NOT] DISTINCT FROM compares values
+				if prev != nil && prev.text == "distinct" {
+					continue
+				}
+			}
+			if rank, ok := sqlClauseRank[token.text]; ok {
+				if rank < ranks[depth] {
+					return &sqlIssue{token.pos, fmt.Sprintf("%s is out of place, clauses go in the order FROM, WHERE, GROUP BY, HAVING, ORDER BY, LIMIT", strings.ToUpper(token.text))}, nil
+				}
+				ranks[depth] = rank
+			}
+		}
+	}
+
+	if last := tokens[len(tokens)-1]; sqlNeedsOperand(last) {
+		return &sqlIssue{last.pos, fmt.Sprintf("query ends after %s", sqlTokenName(last))}, nil
+	}
+	return nil, nil
+}
+
+// checkSQLParens reports a ')' without a matching '(', or an unclosed '('
+func checkSQLParens(tokens []sqlToken) *sqlIssue {
+	var open []int
+	for _, token := range tokens {
+		switch token.kind {
+		case "(":
+			open = append(open, token.pos)
+		case ")":
+			if len(open) == 0 {
+				return &sqlIssue{token.pos, "')' has no matching '('"}
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		return &sqlIssue{open[len(open)-1], "'(' is never closed"}
+	}
+	return nil
+}
+
+// sqlNeedsOperand reports whether a token cannot end an expression
+func sqlNeedsOperand(token sqlToken) bool {
+	switch token.kind {
+	case "word":
+		return sqlDanglingWords[token.text]
+	case "op":
+		return token.text != "*"
+	case ",":
+		return true
+	}
+	return false
+}
+
+func sqlTokenName(token sqlToken) string {
+	if token.kind == "word" {
+		return strings.ToUpper(token.text)
+	}
+	return fmt.Sprintf("'%s'", token.text)
+}
+
+// tokenizeSQL splits the query text into tokens, leaving out comments.
+// Words are lowercased. It fails on an unterminated string, quoted
+// identifier or block comment
+func tokenizeSQL(text string) ([]sqlToken, *sqlIssue) {
+	var tokens []sqlToken
+	for i := 0; i < len(text); {
+		c := text[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(text) && text[i+1] == '-':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(text) && text[i+1] == '*':
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, &sqlIssue{start, "comment is never closed with */"}
+			}
+			i += end + 4
+		case c == '\'' || c == '"':
+			i++
+			for {
+				if i >= len(text) {
+					if c == '\'' {
+						return nil, &sqlIssue{start, "string is never closed with '"}
+					}
+					return nil, &sqlIssue{start, `quoted name is never closed with "`}
+				}
+				if text[i] == c {
+					if i+1 < len(text) && text[i+1] == c {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+			kind := "string"
+			if c == '"' {
+				kind = "ident"
+			}
+			tokens = append(tokens, sqlToken{kind, text[start:i], start})
+		case c == '_' || c >= 0x80 || (c|0x20 >= 'a' && c|0x20 <= 'z'):
+			for i < len(text) && (text[i] == '_' || text[i] >= 0x80 || (text[i]|0x20 >= 'a' && text[i]|0x20 <= 'z') || (text[i] >= '0' && text[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{"word", strings.ToLower(text[start:i]), start})
+		case c >= '0' && c <= '9':
+			for i < len(text) && (text[i] == '.' || (text[i] >= '0' && text[i] <= '9') || text[i]|0x20 == 'e') {
+				i++
+			}
+			tokens = append(tokens, sqlToken{"number", text[start:i], start})
+		case c == '(' || c == ')' || c == ',' || c == ';':
+			i++
+			tokens = append(tokens, sqlToken{string(c), string(c), start})
+		case strings.IndexByte("=<>!+-*/%|&:.^~", c) >= 0:
+			i++
+			for i < len(text) && strings.IndexByte("=<>!|&:", text[i]) >= 0 {
+				i++
+			}
+			tokens = append(tokens, sqlToken{"op", text[start:i], start})
+		default:
+			i++
+			tokens = append(tokens, sqlToken{"other", text[start:i], start})
+		}
+	}
+	return tokens, nil
+}
+
+// sqlPosition converts a byte offset to a 1-based line and column
+func sqlPosition(text string, pos int) (int, int) {
+	before := text[:pos]
+	line := strings.Count(before, "\n") + 1
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return line, utf8.RuneCountInString(before[lineStart:]) + 1
+}
+
+// sqlSyntaxError lists the issues with the offending line and a caret
+// under the position
+func sqlSyntaxError(text string, issues []sqlIssue) error {
+	var report strings.Builder
+	fmt.Fprintf(&report, "the query has syntax errors and was not sent, run without --%s to send it anyway:", validateSQLFlag)
+	for _, issue := range issues {
+		line, column := sqlPosition(text, issue.pos)
+		lineText := strings.Split(text, "\n")[line-1]
+		fmt.Fprintf(&report, "\n  line %d, column %d: %s\n    %s\n    %s^", line, column, issue.message, strings.TrimRight(lineText, "\r"), strings.Repeat(" ", column-1))
+	}
+	return fmt.Errorf("%s", report.String())
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSQLSyntaxAcceptsValidSQL(t *testing.T) {
+	queries := []string{
+		"select * from backend",
+		"SELECT host, count(*) AS total FROM backend WHERE status >= 500 AND host <> 'it''s' GROUP BY host HAVING count(*) > 1 ORDER BY total DESC LIMIT 10 OFFSET 5",
+		"with errors as (select host from backend where level = 'error') select host, count(*) from errors group by host",
+		"select * from backend where user_id in (select id from flagged order by id limit 5) limit 10",
+		"select approx_percentile_cont(latency, 0.9) within group (order by latency) from backend",
+		"select a from t where a is not distinct from b",
+		"select extract(day from p_timestamp), status::int from backend -- trailing ( comment",
+		"select 1; /* second ' statement */ select 2;",
+		"select row_number() over (partition by host order by p_timestamp) from backend union all select 1 from backend",
+	}
+	for _, query := range queries {
+		var warnings strings.Builder
+		if err := checkSQLSyntax(query, &warnings); err != nil {
+			t.Errorf("expected %q to pass, got %v", query, err)
+		}
+		if warnings.Len() > 0 {
+			t.Errorf("expected no warnings for %q, got %s", query, warnings.String())
+		}
+	}
+}
+
+func TestCheckSQLSyntaxRejectsBrokenSQL(t *testing.T) {
+	cases := []struct {
+		query    string
+		position string
+		message  string
+	}{
+		{"select host, from backend", "line 1, column 14", "unexpected FROM after ','"},
+		{"select * from backend where host = 'a", "line 1, column 36", "string is never closed"},
+		{"select count(* from backend", "line 1, column 13", "'(' is never closed"},
+		{"select * from backend)", "line 1, column 22", "')' has no matching '('"},
+		{"selct * from backend", "line 1, column 1", "expected SELECT or WITH, found SELCT"},
+		{"select *\nfrom backend\nwhere status = 500 and", "line 3, column 20", "query ends after AND"},
+		{"select * from backend where status = 500 group host", "line 1, column 42", "expected BY after GROUP"},
+		{"select * from backend limit 10 where status = 500", "line 1, column 32", "WHERE is out of place"},
+		{"select * from from backend", "line 1, column 15", "FROM appears twice in a row"},
+		{"select 1; select * from backend where", "line 1, column 33", "query ends after WHERE"},
+	}
+	for _, c := range cases {
+		err := checkSQLSyntax(c.query, &strings.Builder{})
+		if err == nil {
+			t.Errorf("expected %q to be rejected", c.query)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.position+": "+c.message) {
+			t.Errorf("expected %q at %s for %q, got:\n%v", c.message, c.position, c.query, err)
+		}
+	}
+}
+
+func TestCheckSQLSyntaxWarnsOnUnsupportedSyntax(t *testing.T) {
+	var warnings strings.Builder
+	if err := checkSQLSyntax("select tags['env'] from backend", &warnings); err != nil {
+		t.Errorf("expected unsupported syntax not to block the query, got %v", err)
+	}
+	if !strings.Contains(warnings.String(), "line 1, column 12") {
+		t.Errorf("expected a warning at the unsupported symbol, got %q", warnings.String())
+	}
+
+	warnings.Reset()
+	if err := checkSQLSyntax("show tables", &warnings); err != nil || !strings.Contains(warnings.String(), "SHOW statements are not checked") {
+		t.Errorf("expected a warning for an unchecked statement, got %v, %q", err, warnings.String())
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	maxColWidthFlag = "max-col-width"
+	wrapFlag        = "wrap"
+)
+
+// cellLayout controls how long values are fitted into table cells. A zero
+// maxWidth leaves cells to the default layout of the table writer
+type cellLayout struct {
+	maxWidth int
+	wrap     bool
+}
+
+// fit shortens value to the layout width, counting characters rather than
+// bytes so multibyte text is never cut inside a character
+func (layout cellLayout) fit(value string) string {
+	if layout.maxWidth <= 0 {
+		return value
+	}
+	if layout.wrap {
+		return wrapCell(value, layout.maxWidth)
+	}
+	return truncateCell(value, layout.maxWidth)
+}
+
+// truncateCell cuts value to at most width characters, ending with an
+// ellipsis when anything was removed
+func truncateCell(value string, width int) string {
+	runes := []rune(value)
+	if len(runes) <= width {
+		return value
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// wrapCell breaks value into lines of at most width characters. Existing
+// line breaks are kept
+func wrapCell(value string, width int) string {
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		runes := []rune(line)
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateTableOptions checks the cell layout flags, which only apply to
+// table output
+func validateTableOptions(opts queryOptions) error {
+	if opts.cellLayout.maxWidth < 0 {
+		return fmt.Errorf("--%s must not be negative", maxColWidthFlag)
+	}
+	if (opts.cellLayout.maxWidth > 0 || opts.cellLayout.wrap) && opts.outputFormat != "table" {
+		return fmt.Errorf("--%s and --%s only apply to table output", maxColWidthFlag, wrapFlag)
+	}
+	if opts.cellLayout.wrap && opts.cellLayout.maxWidth == 0 {
+		return fmt.Errorf("--%s requires --%s to set the wrap width", wrapFlag, maxColWidthFlag)
+	}
+	return nil
+}
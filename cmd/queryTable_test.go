@@ -0,0 +1,74 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateCell(t *testing.T) {
+	cases := []struct {
+		value    string
+		width    int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"connection refused by upstream", 10, "connectio…"},
+		{"héllo wörld", 5, "héll…"},
+		{"日本語のログメッセージ", 4, "日本語…"},
+		{"abc", 1, "…"},
+	}
+
+	for _, c := range cases {
+		if got := truncateCell(c.value, c.width); got != c.expected {
+			t.Errorf("truncateCell(%q, %d) = %q, expected %q", c.value, c.width, got, c.expected)
+		}
+	}
+}
+
+func TestWrapCell(t *testing.T) {
+	if got := wrapCell("日本語のログメッセージ", 4); got != "日本語の\nログメッ\nセージ" {
+		t.Errorf("unexpected wrap of multibyte text: %q", got)
+	}
+	if got := wrapCell("abcdef\nxy", 3); got != "abc\ndef\nxy" {
+		t.Errorf("expected existing line breaks to be kept, got %q", got)
+	}
+}
+
+func TestWriteTableTruncatesCells(t *testing.T) {
+	var out strings.Builder
+	records := []map[string]interface{}{{"message": strings.Repeat("é", 50)}}
+	if err := writeTable(&out, records, nil, cellLayout{maxWidth: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), strings.Repeat("é", 7)+"…") || strings.Contains(out.String(), strings.Repeat("é", 8)) {
+		t.Errorf("expected the cell to be truncated to 8 characters, got:\n%s", out.String())
+	}
+}
+
+func TestValidateTableOptions(t *testing.T) {
+	if err := validateTableOptions(queryOptions{outputFormat: "json", cellLayout: cellLayout{maxWidth: 20}}); err == nil {
+		t.Error("expected --max-col-width with json output to be rejected")
+	}
+	if err := validateTableOptions(queryOptions{outputFormat: "table", cellLayout: cellLayout{wrap: true}}); err == nil {
+		t.Error("expected --wrap without --max-col-width to be rejected")
+	}
+	if err := validateTableOptions(queryOptions{outputFormat: "table", cellLayout: cellLayout{maxWidth: 20, wrap: true}}); err != nil {
+		t.Errorf("expected --wrap with --max-col-width to pass, got %v", err)
+	}
+}
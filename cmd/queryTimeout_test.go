@@ -0,0 +1,36 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerTimeoutRefused(t *testing.T) {
+	if err := validateServerTimeout(0); err != nil {
+		t.Errorf("expected no --server-timeout to be accepted, got %v", err)
+	}
+	for _, timeout := range []time.Duration{time.Second, 2 * time.Minute} {
+		if err := validateServerTimeout(timeout); err == nil || !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("expected %s to be refused, got %v", timeout, err)
+		}
+	}
+	if defaultableFlags[serverTimeoutFlag] {
+		t.Errorf("expected --%s to take no default", serverTimeoutFlag)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	timezoneFlag = "timezone"
+
+	// serverTimestampLayouts are the forms timestamps come back in from the
+	// server, which sends UTC without a zone suffix
+	serverTimestampLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04:05.999999999",
+	}
+)
+
+// parseTimezone returns the location for --timezone, an IANA name such as
+// America/New_York, UTC or local
+func parseTimezone(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q, use an IANA name such as America/New_York, UTC or local", name)
+	}
+	return location, nil
+}
+
+// timestampConverter rewrites timestamps in location, one row at a time. A
+// column holds timestamps when its first value that is not null is one, so
+// computed columns such as time buckets are converted too
+type timestampConverter struct {
+	location *time.Location
+	// columns records for each column seen so far whether it holds
+	// timestamps
+	columns map[string]bool
+}
+
+func newTimestampConverter(location *time.Location) *timestampConverter {
+	return &timestampConverter{location: location, columns: make(map[string]bool)}
+}
+
+// convert returns value in the converter's location, and false when value is
+// left as it is
+func (c *timestampConverter) convert(column string, value interface{}) (string, bool) {
+	if value == nil {
+		return "", false
+	}
+	text, isString := value.(string)
+	isTimestamp, seen := c.columns[column]
+	if !seen {
+		if isString {
+			_, isTimestamp = parseServerTimestamp(text)
+		}
+		c.columns[column] = isTimestamp
+	}
+	if !isTimestamp || !isString {
+		return "", false
+	}
+	return convertTimestamp(text, c.location)
+}
+
+// convertRow rewrites the timestamp columns of row in place
+func (c *timestampConverter) convertRow(row map[string]interface{}) {
+	for column, value := range row {
+		if converted, ok := c.convert(column, value); ok {
+			row[column] = converted
+		}
+	}
+}
+
+// convertObject rewrites the timestamp columns of a JSON object, keeping its
+// columns in order. Values that are not objects are returned unchanged
+func (c *timestampConverter) convertObject(object json.RawMessage) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return object, nil
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		column, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		var decoded interface{} = value
+		var text string
+		if string(value) == "null" {
+			decoded = nil
+		} else if json.Unmarshal(value, &text) == nil {
+			decoded = text
+		}
+		if converted, ok := c.convert(column, decoded); ok {
+			value, _ = json.Marshal(converted)
+		}
+
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		key, _ := json.Marshal(column)
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// timezoneResultWriter converts the timestamp columns of each row before it
+// is written
+type timezoneResultWriter struct {
+	ResultWriter
+	converter *timestampConverter
+}
+
+func (z *timezoneResultWriter) Write(row map[string]interface{}) error {
+	z.converter.convertRow(row)
+	return z.ResultWriter.Write(row)
+}
+
+// convertResultTimezone returns body with the timestamp columns of each row
+// in location, for output that is written as the server sends it. Rows are
+// converted as they are read and keep their column order. A response that is
+// not a JSON array is passed through unchanged. The returned reader must be
+// closed
+func convertResultTimezone(body io.Reader, location *time.Location) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(rewriteTimestamps(writer, body, newTimestampConverter(location)))
+	}()
+	return reader
+}
+
+func rewriteTimestamps(w io.Writer, body io.Reader, converter *timestampConverter) error {
+	buffered := bufio.NewReader(body)
+	if firstByte(buffered) != '[' {
+		_, err := io.Copy(w, buffered)
+		return err
+	}
+
+	decoder := json.NewDecoder(buffered)
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("error decoding JSON response: %w", err)
+	}
+	out := bufio.NewWriter(w)
+	out.WriteByte('[')
+	for idx := 0; decoder.More(); idx++ {
+		var row json.RawMessage
+		if err := decoder.Decode(&row); err != nil {
+			return fmt.Errorf("error decoding JSON response: %w", err)
+		}
+		converted, err := converter.convertObject(row)
+		if err != nil {
+			return fmt.Errorf("error decoding JSON response: %w", err)
+		}
+		if idx > 0 {
+			out.WriteByte(',')
+		}
+		out.Write(converted)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("error decoding JSON response: %w", err)
+	}
+	out.WriteByte(']')
+	return out.Flush()
+}
+
+// firstByte returns the first byte of r that is not JSON whitespace, without
+// consuming it, or 0 when there is none
+func firstByte(r *bufio.Reader) byte {
+	for n := 1; ; n++ {
+		peeked, err := r.Peek(n)
+		if err != nil {
+			return 0
+		}
+		switch c := peeked[n-1]; c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return c
+		}
+	}
+}
+
+func convertTimestamp(value string, location *time.Location) (string, bool) {
+	t, ok := parseServerTimestamp(value)
+	if !ok {
+		return "", false
+	}
+	return t.In(location).Format(time.RFC3339Nano), true
+}
+
+// parseServerTimestamp parses a timestamp in one of the forms the server
+// sends, reading values without a zone as UTC
+func parseServerTimestamp(value string) (time.Time, bool) {
+	for _, layout := range serverTimestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
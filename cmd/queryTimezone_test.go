@@ -0,0 +1,118 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConvertTimestampAcrossDST(t *testing.T) {
+	newYork, err := parseTimezone("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		utc, expected string
+	}{
+		// the clocks spring forward at 2:00 on 10 March 2024
+		{"2024-03-10T06:59:59.000", "2024-03-10T01:59:59-05:00"},
+		{"2024-03-10T07:00:00.000", "2024-03-10T03:00:00-04:00"},
+		// and fall back at 2:00 on 3 November 2024, so 1:30 happens twice
+		{"2024-11-03T05:30:00.000", "2024-11-03T01:30:00-04:00"},
+		{"2024-11-03T06:30:00.000", "2024-11-03T01:30:00-05:00"},
+		// fractional seconds and an explicit zone are kept
+		{"2024-07-01T12:00:00.250Z", "2024-07-01T08:00:00.25-04:00"},
+	}
+	for _, c := range cases {
+		converted, ok := convertTimestamp(c.utc, newYork)
+		if !ok || converted != c.expected {
+			t.Errorf("%s: expected %s, got %s (%v)", c.utc, c.expected, converted, ok)
+		}
+	}
+
+	if _, ok := convertTimestamp("not a time", newYork); ok {
+		t.Error("expected a non timestamp value to be left alone")
+	}
+}
+
+func TestParseTimezone(t *testing.T) {
+	if location, err := parseTimezone("local"); err != nil || location != time.Local {
+		t.Errorf("expected local to be the local zone, got %v, %v", location, err)
+	}
+	if _, err := parseTimezone("Mars/Olympus_Mons"); err == nil {
+		t.Error("expected an unknown zone to be rejected")
+	}
+}
+
+func TestConvertResultTimezoneKeepsColumnOrder(t *testing.T) {
+	location, _ := parseTimezone("Asia/Kolkata")
+	body := strings.NewReader(`[
+		{"p_timestamp":"2024-01-01T00:00:00.000","bucket":"2024-01-01T00:00:00","label":"web","note":"soon","count":12345678901234567},
+		{"p_timestamp":"2024-01-01T00:01:00.000","bucket":null,"label":"api","note":"2024-01-01T00:00:00.000","count":1}
+	]`)
+
+	converted := convertResultTimezone(body, location)
+	defer converted.Close()
+	data, err := io.ReadAll(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"p_timestamp":"2024-01-01T05:30:00+05:30","bucket":"2024-01-01T05:30:00+05:30","label":"web","note":"soon","count":12345678901234567},` +
+		`{"p_timestamp":"2024-01-01T05:31:00+05:30","bucket":null,"label":"api","note":"2024-01-01T00:00:00.000","count":1}]`
+	if string(data) != want {
+		t.Errorf("expected timestamps converted in column order\nwant %s\ngot  %s", want, data)
+	}
+
+	// a response that is not an array is left alone
+	converted = convertResultTimezone(strings.NewReader(`{"error":"x"}`), location)
+	defer converted.Close()
+	if data, _ := io.ReadAll(converted); string(data) != `{"error":"x"}` {
+		t.Errorf("expected a non array response unchanged, got %s", data)
+	}
+}
+
+func TestTimezoneResultWriterConvertsEachRow(t *testing.T) {
+	location, _ := parseTimezone("Asia/Kolkata")
+	var out strings.Builder
+	writer := decorateResultWriter(&ndjsonResultWriter{encoder: json.NewEncoder(&out)}, queryOptions{location: location})
+
+	rows := []map[string]interface{}{
+		{"p_timestamp": "2024-01-01T00:00:00.000", "label": "2024-01-01"},
+		{"p_timestamp": "2024-01-01T00:01:00.000", "label": "2024-01-01T00:00:00.000"},
+	}
+	for idx, row := range rows {
+		if err := writer.Write(row); err != nil {
+			t.Fatal(err)
+		}
+		// rows are written as they come, nothing is held back
+		if lines := strings.Count(out.String(), "\n"); lines != idx+1 {
+			t.Fatalf("expected %d rows written, got %d", idx+1, lines)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"label":"2024-01-01","p_timestamp":"2024-01-01T05:30:00+05:30"}` + "\n" +
+		`{"label":"2024-01-01T00:00:00.000","p_timestamp":"2024-01-01T05:31:00+05:30"}` + "\n"
+	if out.String() != want {
+		t.Errorf("unexpected output\nwant %s\ngot  %s", want, out.String())
+	}
+}
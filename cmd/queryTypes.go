@@ -0,0 +1,171 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	showTypesFlag = "show-types"
+	typesFileFlag = "types-file"
+)
+
+// resultColumnTypes collects the type of each column from the values of a
+// query result. Parseable does not describe the columns of a result, and the
+// stream schema misses computed columns such as aggregates, aliases and time
+// buckets, so the types are taken from the values themselves
+type resultColumnTypes map[string]string
+
+// observe records the types of the values of row. Null values say nothing
+// about a column. Columns mixing integers and floats are float64, columns
+// mixing timestamps and other strings are string, other mixes are mixed
+func (c resultColumnTypes) observe(row map[string]interface{}) {
+	for column, value := range row {
+		valueType := valueTypeName(value)
+		if valueType == "" {
+			continue
+		}
+		current := c[column]
+		switch {
+		case current == "" || current == valueType:
+			c[column] = valueType
+		case isNumericType(current) && isNumericType(valueType):
+			c[column] = "float64"
+		case isTextType(current) && isTextType(valueType):
+			c[column] = "string"
+		default:
+			c[column] = "mixed"
+		}
+	}
+}
+
+// valueTypeName returns the type name of a decoded JSON value, or an empty
+// string for null
+func valueTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		return "bool"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "int64"
+		}
+		return "float64"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "int64"
+		}
+		return "float64"
+	case string:
+		if _, ok := parseServerTimestamp(v); ok {
+			return "datetime"
+		}
+		return "string"
+	case map[string]interface{}:
+		return "struct"
+	case []interface{}:
+		return "list"
+	}
+	return ""
+}
+
+func isTextType(name string) bool {
+	return name == "string" || name == "datetime"
+}
+
+// recordTypes returns the column types of records already in memory
+func recordTypes(records []map[string]interface{}) resultColumnTypes {
+	types := make(resultColumnTypes)
+	for _, record := range records {
+		types.observe(record)
+	}
+	return types
+}
+
+// columnTypeName turns an arrow type name into the short name shown to users
+func columnTypeName(arrowType string) string {
+	switch {
+	case arrowType == "Utf8" || arrowType == "LargeUtf8":
+		return "string"
+	case arrowType == "Boolean":
+		return "bool"
+	case arrowType == "Timestamp" || strings.HasPrefix(arrowType, "Date"):
+		return "datetime"
+	default:
+		return strings.ToLower(arrowType)
+	}
+}
+
+// writeTable renders records as a table. When types is not nil, each header
+// shows the column type next to its name if it is known. Cells are fitted
+// to the given layout
+func writeTable(w io.Writer, records []map[string]interface{}, types map[string]string, layout cellLayout) error {
+	columns := recordColumns(records)
+	if len(columns) == 0 {
+		_, err := fmt.Fprintln(w, "No results")
+		return err
+	}
+
+	header := make([]string, len(columns))
+	for idx, column := range columns {
+		header[idx] = column
+		if columnType, ok := types[column]; ok {
+			header[idx] = fmt.Sprintf("%s (%s)", column, columnType)
+		}
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader(header)
+	if layout.maxWidth > 0 {
+		table.SetAutoWrapText(false)
+	}
+	for _, record := range records {
+		row := make([]string, len(columns))
+		for idx, column := range columns {
+			row[idx] = layout.fit(csvValue(record[column]))
+		}
+		table.Append(row)
+	}
+	table.Render()
+	return nil
+}
+
+// writeTypesFile writes the known types of columns as a JSON object, next to
+// json, ndjson or csv results
+func writeTypesFile(path string, columns []string, types map[string]string) error {
+	known := make(map[string]string)
+	for _, column := range columns {
+		if columnType, ok := types[column]; ok {
+			known[column] = columnType
+		}
+	}
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write types file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"pb/pkg/s3"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	outputURLFlag   = "output-url"
+	gzipFlag        = "gzip"
+	s3EndpointFlag  = "s3-endpoint"
+	s3RegionFlag    = "s3-region"
+	s3AccessKeyFlag = "s3-access-key"
+	s3SecretKeyFlag = "s3-secret-key"
+)
+
+// outputSink is where query results go when they are not printed to
+// stdout: a local --output-file or an s3:// --output-url object. Results for
+// an http(s) --output-url are posted by webhookResultWriter instead
+type outputSink interface {
+	io.Writer
+	Close() error
+	abort()
+}
+
+// writesToStdout reports whether results are printed rather than saved
+func writesToStdout(opts queryOptions) bool {
+	return opts.outputFile == "" && opts.outputURL == ""
+}
+
+// openOutput opens the destination for query results
+func openOutput(opts queryOptions) (outputSink, error) {
+	if opts.outputURL == "" {
+		return createOutputFile(opts)
+	}
+	return newObjectOutput(opts)
+}
+
+// objectStorageConfig reads credentials from the environment, with the
+// --s3-* flags taking precedence
+func objectStorageConfig(flags *pflag.FlagSet) s3.Config {
+	cfg := s3.ConfigFromEnv()
+	for name, value := range map[string]*string{
+		s3EndpointFlag:  &cfg.Endpoint,
+		s3RegionFlag:    &cfg.Region,
+		s3AccessKeyFlag: &cfg.AccessKey,
+		s3SecretKeyFlag: &cfg.SecretKey,
+	} {
+		if flag, _ := flags.GetString(name); flag != "" {
+			*value = flag
+		}
+	}
+	return cfg
+}
+
+// validateOutputURLOptions checks --output-url and --gzip before the query is
+// sent, so a typo in the URL or missing credentials fail fast
+func validateOutputURLOptions(opts queryOptions) error {
+	if err := validateWebhookOptions(opts); err != nil {
+		return err
+	}
+	if opts.outputURL == "" {
+		if opts.gzip {
+			return fmt.Errorf("--%s requires --%s", gzipFlag, outputURLFlag)
+		}
+		return nil
+	}
+
+	if opts.outputFile != "" {
+		return fmt.Errorf("use either --%s or --%s, not both", outputFileFlag, outputURLFlag)
+	}
+	if isWebhookURL(opts.outputURL) {
+		return nil
+	}
+	if _, _, err := s3.ParseURL(opts.outputURL); err != nil {
+		return err
+	}
+	return opts.objectStorage.Validate()
+}
+
+// objectOutput streams results to object storage, compressing them first
+// when --gzip is set
+type objectOutput struct {
+	upload *s3.Upload
+	gz     *gzip.Writer
+	w      io.Writer
+}
+
+func newObjectOutput(opts queryOptions) (*objectOutput, error) {
+	bucket, key, err := s3.ParseURL(opts.outputURL)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := formatContentType(opts.outputFormat)
+	if opts.gzip {
+		contentType = "application/gzip"
+	}
+	out := &objectOutput{upload: s3.NewUpload(opts.objectStorage, bucket, key, contentType)}
+	out.w = out.upload
+	if opts.gzip {
+		out.gz = gzip.NewWriter(out.upload)
+		out.w = out.gz
+	}
+	return out, nil
+}
+
+func (o *objectOutput) Write(p []byte) (int, error) {
+	return o.w.Write(p)
+}
+
+// Close flushes the compressed stream and completes the upload
+func (o *objectOutput) Close() error {
+	if o.gz != nil {
+		if err := o.gz.Close(); err != nil {
+			o.upload.Abort()
+			return err
+		}
+	}
+	return o.upload.Close()
+}
+
+// abort discards the upload so a failed export leaves no partial object
+func (o *objectOutput) abort() {
+	o.upload.Abort()
+}
+
+// formatContentType returns the content type stored with an uploaded export
+func formatContentType(format string) string {
+	switch format {
+	case "json":
+		return "application/json"
+	case "ndjson":
+		return "application/x-ndjson"
+	case "csv":
+		return "text/csv"
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/plain; charset=utf-8"
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/s3"
+)
+
+func TestOutputURLValidation(t *testing.T) {
+	creds := s3.Config{AccessKey: "minio", SecretKey: "minio123"}
+
+	cases := []struct {
+		name string
+		opts queryOptions
+		err  string
+	}{
+		{"gzip without url", queryOptions{gzip: true}, "--gzip requires --output-url"},
+		{"both destinations", queryOptions{outputURL: "s3://logs/out.csv", outputFile: "out.csv", objectStorage: creds}, "not both"},
+		{"bad scheme", queryOptions{outputURL: "gs://logs/out.csv", objectStorage: creds}, "use s3://bucket/key"},
+		{"no credentials", queryOptions{outputURL: "s3://logs/out.csv"}, "AWS_ACCESS_KEY_ID"},
+	}
+	for _, c := range cases {
+		err := validateOutputURLOptions(c.opts)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.err, err)
+		}
+	}
+
+	if err := validateOutputURLOptions(queryOptions{outputURL: "s3://logs/out.csv", gzip: true, objectStorage: creds}); err != nil {
+		t.Errorf("expected valid options to pass, got %v", err)
+	}
+}
+
+func TestGzipUploadToObjectStorage(t *testing.T) {
+	var path, contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	opts := queryOptions{
+		outputFormat:  "csv",
+		outputURL:     "s3://logs/exports/results.csv.gz",
+		gzip:          true,
+		objectStorage: s3.Config{Endpoint: server.URL, AccessKey: "minio", SecretKey: "minio123"},
+	}
+	out, err := openOutput(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRecords(out, firstBatch, opts.outputFormat, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/logs/exports/results.csv.gz" || contentType != "application/gzip" {
+		t.Errorf("unexpected upload to %q with content type %q", path, contentType)
+	}
+	reader, err := gzip.NewReader(strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("uploaded object is not gzip: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	if string(data) != "host,status\na,200\n" {
+		t.Errorf("unexpected uploaded content %q", string(data))
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	valueFlag = "value"
+
+	// nullValueExitCode is the exit code of --value when the single value
+	// is null, so scripts can tell it from an empty string
+	nullValueExitCode = 3
+)
+
+// validateValueOptions checks that --value is not combined with flags that
+// shape the output in another way
+func validateValueOptions(opts queryOptions, statements int) error {
+	if !opts.value {
+		return nil
+	}
+	switch {
+	case statements > 1:
+		return fmt.Errorf("--%s runs a single statement, %d were given", valueFlag, statements)
+	case opts.outputFormat != "" && opts.outputFormat != "text":
+		return fmt.Errorf("--%s prints the value as it is and cannot be used with --output %s", valueFlag, opts.outputFormat)
+	case !writesToStdout(opts):
+		return fmt.Errorf("--%s prints to stdout and cannot be used with --%s or --%s", valueFlag, outputFileFlag, outputURLFlag)
+	case opts.raw || opts.pretty:
+		return fmt.Errorf("--%s cannot be used with --%s or --%s", valueFlag, rawFlag, prettyFlag)
+	}
+	return nil
+}
+
+// writeScalar prints the only value of a result of one row and one column,
+// without quotes or formatting. Strings and numbers are printed as they are,
+// so large integers keep every digit, nested values as JSON. A null value
+// prints an empty line and returns an error with nullValueExitCode. A
+// timestamp is shown in location, unless it is nil
+func writeScalar(w io.Writer, body io.Reader, location *time.Location) error {
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&records); err != nil {
+		return fmt.Errorf("error decoding JSON response: %w", err)
+	}
+	if len(records) != 1 {
+		return fmt.Errorf("--%s needs a result of exactly one row, the query returned %d rows", valueFlag, len(records))
+	}
+	if len(records[0]) != 1 {
+		columns := make([]string, 0, len(records[0]))
+		for column := range records[0] {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		return fmt.Errorf("--%s needs a result of exactly one column, the query returned %d: %s", valueFlag, len(columns), strings.Join(columns, ", "))
+	}
+	if location != nil {
+		newTimestampConverter(location).convertRow(records[0])
+	}
+
+	for column, value := range records[0] {
+		if _, err := fmt.Fprintln(w, csvValue(value)); err != nil {
+			return err
+		}
+		if value == nil {
+			return &ExitCodeError{Code: nullValueExitCode, Err: fmt.Errorf("the value of %s is null", column)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteScalar(t *testing.T) {
+	cases := map[string]string{
+		`[{"max(id)": 42}]`:               "42\n",
+		`[{"host": "web-1"}]`:             "web-1\n",
+		`[{"ratio": 0.25}]`:               "0.25\n",
+		`[{"ok": true}]`:                  "true\n",
+		`[{"tags": ["a","b"]}]`:           "[\"a\",\"b\"]\n",
+		`[{"count": 1234567890123}]`:      "1234567890123\n",
+		`[{"id": 9007199254740993}]`:      "9007199254740993\n",
+		`[{"sum": 18446744073709551615}]`: "18446744073709551615\n",
+		`[{"ratio": 1.50}]`:               "1.50\n",
+		`[{"msg": "say \"hi\"\ttab"}]`:    "say \"hi\"\ttab\n",
+	}
+	for body, want := range cases {
+		var out strings.Builder
+		if err := writeScalar(&out, strings.NewReader(body), nil); err != nil {
+			t.Errorf("%s: unexpected error %v", body, err)
+			continue
+		}
+		if out.String() != want {
+			t.Errorf("%s: expected %q, got %q", body, want, out.String())
+		}
+	}
+}
+
+func TestWriteScalarNull(t *testing.T) {
+	var out strings.Builder
+	err := writeScalar(&out, strings.NewReader(`[{"max(id)": null}]`), nil)
+	var exitErr *ExitCodeError
+	if !errors.As(err, &exitErr) || exitErr.Code != nullValueExitCode {
+		t.Fatalf("expected exit code %d for a null value, got %v", nullValueExitCode, err)
+	}
+	if out.String() != "\n" {
+		t.Errorf("expected an empty line for null, got %q", out.String())
+	}
+}
+
+func TestWriteScalarRejectsMultipleRows(t *testing.T) {
+	for body, rows := range map[string]string{`[{"id": 1}, {"id": 2}]`: "2 rows", `[]`: "0 rows"} {
+		var out strings.Builder
+		err := writeScalar(&out, strings.NewReader(body), nil)
+		if err == nil || !strings.Contains(err.Error(), rows) {
+			t.Errorf("%s: expected an error about %s, got %v", body, rows, err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s: expected nothing on stdout, got %q", body, out.String())
+		}
+	}
+}
+
+func TestWriteScalarRejectsMultipleColumns(t *testing.T) {
+	var out strings.Builder
+	err := writeScalar(&out, strings.NewReader(`[{"id": 1, "host": "a"}]`), nil)
+	if err == nil || !strings.Contains(err.Error(), "2: host, id") {
+		t.Errorf("expected an error listing the columns, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", out.String())
+	}
+}
+
+func TestValueRejectsOtherOutput(t *testing.T) {
+	if err := validateValueOptions(queryOptions{value: true, outputFormat: "json"}, 1); err == nil {
+		t.Error("expected --value with json output to be rejected")
+	}
+	if err := validateValueOptions(queryOptions{value: true, outputFile: "out.txt"}, 1); err == nil {
+		t.Error("expected --value with --output-file to be rejected")
+	}
+	if err := validateValueOptions(queryOptions{value: true}, 2); err == nil {
+		t.Error("expected --value with several statements to be rejected")
+	}
+	if err := validateValueOptions(queryOptions{value: true}, 1); err != nil {
+		t.Errorf("expected plain --value to pass, got %v", err)
+	}
+}
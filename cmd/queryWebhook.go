@@ -0,0 +1,177 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+)
+
+var (
+	outputHeaderFlag = "output-header"
+	batchSizeFlag    = "batch-size"
+
+	// webhookTimeout bounds each POST to an http(s) --output-url
+	webhookTimeout = 30 * time.Second
+)
+
+// webhookOptions holds the settings for posting results to an http(s)
+// --output-url
+type webhookOptions struct {
+	headers http.Header
+	// batchSize is the number of rows per POST, 0 sends all rows at once
+	batchSize int
+}
+
+// isWebhookURL reports whether --output-url names an HTTP endpoint rather
+// than object storage
+func isWebhookURL(raw string) bool {
+	return strings.HasPrefix(raw, "http://") || strings.HasPrefix(raw, "https://")
+}
+
+// parseOutputHeaders parses --output-header values of the form "Name: value"
+func parseOutputHeaders(values []string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, value := range values {
+		name, content, ok := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid --%s %q, use \"Name: value\"", outputHeaderFlag, value)
+		}
+		headers.Add(name, strings.TrimSpace(content))
+	}
+	return headers, nil
+}
+
+// validateWebhookOptions checks the flags that apply to posting results. The
+// webhook flags need an http(s) --output-url, and results can only be posted
+// in a format that is complete in every batch
+func validateWebhookOptions(opts queryOptions) error {
+	if !isWebhookURL(opts.outputURL) {
+		if len(opts.webhook.headers) > 0 || opts.webhook.batchSize != 0 {
+			return fmt.Errorf("--%s and --%s require an http or https --%s", outputHeaderFlag, batchSizeFlag, outputURLFlag)
+		}
+		return nil
+	}
+
+	if u, err := url.Parse(opts.outputURL); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid --%s %q", outputURLFlag, opts.outputURL)
+	}
+	switch opts.outputFormat {
+	case "json", "ndjson", "csv":
+	default:
+		return fmt.Errorf("posting to an http or https --%s requires json, ndjson or csv output", outputURLFlag)
+	}
+	if opts.webhook.batchSize < 0 {
+		return fmt.Errorf("--%s cannot be negative", batchSizeFlag)
+	}
+	if opts.gzip || opts.pretty || opts.raw || opts.typesFile != "" {
+		return fmt.Errorf("--%s, --%s, --%s and --%s cannot be used with an http or https --%s", gzipFlag, prettyFlag, rawFlag, typesFileFlag, outputURLFlag)
+	}
+	return nil
+}
+
+// webhookResultWriter posts the rows to an HTTP endpoint, batchSize rows per
+// request or all of them in one. Every request is a complete document in the
+// output format, csv batches each start with a header
+type webhookResultWriter struct {
+	client  *http.Client
+	url     string
+	format  string
+	opts    webhookOptions
+	report  io.Writer
+	rows    []map[string]interface{}
+	batches int
+	sent    int
+}
+
+func newWebhookResultWriter(target, format string, opts webhookOptions, report io.Writer) *webhookResultWriter {
+	return &webhookResultWriter{
+		client: &http.Client{Timeout: webhookTimeout},
+		url:    target,
+		format: format,
+		opts:   opts,
+		report: report,
+	}
+}
+
+func (w *webhookResultWriter) Write(row map[string]interface{}) error {
+	w.rows = append(w.rows, row)
+	if w.opts.batchSize > 0 && len(w.rows) >= w.opts.batchSize {
+		return w.post()
+	}
+	return nil
+}
+
+func (w *webhookResultWriter) Flush() error {
+	if len(w.rows) > 0 {
+		if err := w.post(); err != nil {
+			return err
+		}
+	}
+	if w.batches == 0 {
+		fmt.Fprintf(w.report, "No results, nothing was sent to %s\n", w.url)
+		return nil
+	}
+	fmt.Fprintf(w.report, "Sent %d rows to %s in %d request(s)\n", w.sent, w.url, w.batches)
+	return nil
+}
+
+// post sends the buffered rows as one request and reports the status of the
+// response. A response outside 2xx stops the export
+func (w *webhookResultWriter) post() error {
+	if err := internalHTTP.CheckOnline(); err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err := writeRecords(&body, w.rows, w.format, nil); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, &body)
+	if err != nil {
+		return err
+	}
+	for name, values := range w.opts.headers {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", formatContentType(w.format))
+	}
+
+	w.batches++
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %d to %s failed: %w", w.batches, w.url, err)
+	}
+	defer resp.Body.Close()
+
+	fmt.Fprintf(w.report, "POST %s (%d rows): %s\n", w.url, len(w.rows), resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		response, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s rejected request %d with %s: %s", w.url, w.batches, resp.Status, strings.TrimSpace(string(response)))
+	}
+	w.sent += len(w.rows)
+	w.rows = w.rows[:0]
+	return nil
+}
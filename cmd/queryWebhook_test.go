@@ -0,0 +1,137 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type receivedPost struct {
+	body, contentType, auth string
+}
+
+func webhookReceiver(t *testing.T, status int) (*httptest.Server, *[]receivedPost) {
+	var posts []receivedPost
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		posts = append(posts, receivedPost{string(body), r.Header.Get("Content-Type"), r.Header.Get("Authorization")})
+		w.WriteHeader(status)
+		w.Write([]byte("received"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &posts
+}
+
+const webhookResults = `[{"host":"a","status":200},{"host":"b","status":500},{"host":"c","status":200},{"host":"d","status":404},{"host":"e","status":200}]`
+
+func TestPostResultsInBatches(t *testing.T) {
+	server, posts := webhookReceiver(t, http.StatusAccepted)
+
+	headers, err := parseOutputHeaders([]string{"Authorization: Bearer secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := queryOptions{outputFormat: "ndjson", outputURL: server.URL + "/ingest", webhook: webhookOptions{headers: headers, batchSize: 2}}
+	if err := writeResults(nil, strings.NewReader(webhookResults), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*posts) != 3 {
+		t.Fatalf("expected 3 requests for 5 rows in batches of 2, got %d", len(*posts))
+	}
+	expected := []string{
+		"{\"host\":\"a\",\"status\":200}\n{\"host\":\"b\",\"status\":500}\n",
+		"{\"host\":\"c\",\"status\":200}\n{\"host\":\"d\",\"status\":404}\n",
+		"{\"host\":\"e\",\"status\":200}\n",
+	}
+	for idx, post := range *posts {
+		if post.body != expected[idx] {
+			t.Errorf("request %d: expected payload %q, got %q", idx+1, expected[idx], post.body)
+		}
+		if post.contentType != "application/x-ndjson" || post.auth != "Bearer secret" {
+			t.Errorf("request %d: unexpected headers %q and %q", idx+1, post.contentType, post.auth)
+		}
+	}
+}
+
+func TestPostResultsCSVBatchesHaveHeader(t *testing.T) {
+	server, posts := webhookReceiver(t, http.StatusOK)
+
+	opts := queryOptions{outputFormat: "csv", outputURL: server.URL, webhook: webhookOptions{batchSize: 3}}
+	if err := writeResults(nil, strings.NewReader(webhookResults), opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(*posts) != 2 || (*posts)[1].body != "host,status\nd,404\ne,200\n" {
+		t.Errorf("expected every batch to be a complete CSV document, got %+v", *posts)
+	}
+}
+
+func TestPostResultsInOneRequest(t *testing.T) {
+	server, posts := webhookReceiver(t, http.StatusOK)
+
+	opts := queryOptions{outputFormat: "json", outputURL: server.URL}
+	if err := writeResults(nil, strings.NewReader(webhookResults), opts); err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	if len(*posts) != 1 || json.Unmarshal([]byte((*posts)[0].body), &rows) != nil || len(rows) != 5 {
+		t.Errorf("expected all rows in a single JSON array, got %+v", *posts)
+	}
+}
+
+func TestPostResultsReportsRejection(t *testing.T) {
+	server, posts := webhookReceiver(t, http.StatusBadRequest)
+
+	opts := queryOptions{outputFormat: "ndjson", outputURL: server.URL, webhook: webhookOptions{batchSize: 2}}
+	err := writeResults(nil, strings.NewReader(webhookResults), opts)
+	if err == nil || !strings.Contains(err.Error(), "400 Bad Request") || !strings.Contains(err.Error(), "received") {
+		t.Errorf("expected the status and response of the endpoint in the error, got %v", err)
+	}
+	if len(*posts) != 1 {
+		t.Errorf("expected the export to stop after the rejected request, got %d requests", len(*posts))
+	}
+}
+
+func TestWebhookOptionsValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		opts queryOptions
+		err  string
+	}{
+		{"headers without url", queryOptions{outputFormat: "json", webhook: webhookOptions{batchSize: 10}}, "require an http or https --output-url"},
+		{"text output", queryOptions{outputURL: "https://hooks.example.com/pb"}, "requires json, ndjson or csv output"},
+		{"gzip", queryOptions{outputFormat: "json", outputURL: "https://hooks.example.com/pb", gzip: true}, "--gzip"},
+		{"negative batch", queryOptions{outputFormat: "json", outputURL: "https://hooks.example.com/pb", webhook: webhookOptions{batchSize: -1}}, "cannot be negative"},
+	}
+	for _, c := range cases {
+		err := validateOutputURLOptions(c.opts)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.err, err)
+		}
+	}
+
+	if err := validateOutputURLOptions(queryOptions{outputFormat: "csv", outputURL: "https://hooks.example.com/pb"}); err != nil {
+		t.Errorf("expected valid options to pass, got %v", err)
+	}
+	if _, err := parseOutputHeaders([]string{"Authorization Bearer"}); err == nil {
+		t.Error("expected a header without a colon to be rejected")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+var (
+	withFlag = "with"
+
+	// maxLocalValues caps the values a local table adds to the query, so a
+	// large file does not turn into a query the server refuses
+	maxLocalValues = 10000
+
+	localTableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// localSubqueryPattern matches IN (SELECT column FROM table), the only
+	// form in which a local table can be used
+	localSubqueryPattern = regexp.MustCompile(`(?i)\bin\s*\(\s*select\s+"?([A-Za-z_][A-Za-z0-9_]*)"?\s+from\s+"?([A-Za-z_][A-Za-z0-9_]*)"?\s*\)`)
+)
+
+// localTable is an NDJSON file passed with --with, one record per line
+type localTable struct {
+	path    string
+	records []map[string]interface{}
+}
+
+// readLocalTables loads the NDJSON files passed with --with name=file,
+// keyed by table name
+func readLocalTables(flags *pflag.FlagSet) (map[string]localTable, error) {
+	values, _ := flags.GetStringArray(withFlag)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	tables := make(map[string]localTable, len(values))
+	for _, value := range values {
+		name, path, ok := strings.Cut(value, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid --%s %q, use name=file.ndjson", withFlag, value)
+		}
+		if !localTableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid local table name %q, use letters, digits and _", name)
+		}
+		if _, ok := tables[name]; ok {
+			return nil, fmt.Errorf("local table %s is passed more than once", name)
+		}
+		records, err := readNDJSONFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tables[name] = localTable{path: path, records: records}
+	}
+	return tables, nil
+}
+
+func readNDJSONFile(path string) ([]map[string]interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local table: %w", err)
+	}
+	defer file.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("%s line %d is not a JSON object: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// applyLocalTables replaces every IN (SELECT column FROM table) on a local
+// table with the list of the column's values, so that the server filters the
+// stream against them. Any other use of a local table is an error
+func applyLocalTables(query string, tables map[string]localTable) (string, error) {
+	if len(tables) == 0 {
+		return query, nil
+	}
+
+	var replaceErr error
+	rewritten := localSubqueryPattern.ReplaceAllStringFunc(query, func(match string) string {
+		groups := localSubqueryPattern.FindStringSubmatch(match)
+		column, name := groups[1], groups[2]
+		table, ok := tables[name]
+		if !ok {
+			return match
+		}
+		values, err := table.values(column)
+		if err != nil && replaceErr == nil {
+			replaceErr = err
+		}
+		return "IN (" + strings.Join(values, ", ") + ")"
+	})
+	if replaceErr != nil {
+		return "", replaceErr
+	}
+
+	// look for other uses in the query as written, as the values of a local
+	// table and string literals may contain its name
+	remaining := withoutStringLiterals(localSubqueryPattern.ReplaceAllStringFunc(query, func(match string) string {
+		if _, ok := tables[localSubqueryPattern.FindStringSubmatch(match)[2]]; ok {
+			return " "
+		}
+		return match
+	}))
+	for name := range tables {
+		if regexp.MustCompile(`\b` + name + `\b`).MatchString(remaining) {
+			return "", fmt.Errorf("local table %s can only be used as IN (SELECT column FROM %s)", name, name)
+		}
+	}
+	return rewritten, nil
+}
+
+// withoutStringLiterals blanks out the single-quoted string literals of a
+// statement
+func withoutStringLiterals(sql string) string {
+	var code strings.Builder
+	for i := 0; i < len(sql); i++ {
+		if sql[i] != '\'' {
+			code.WriteByte(sql[i])
+			continue
+		}
+		end := strings.IndexByte(sql[i+1:], '\'')
+		if end < 0 {
+			break
+		}
+		code.WriteByte(' ')
+		i += end + 1
+	}
+	return code.String()
+}
+
+// values returns the distinct values of column as SQL literals, in the
+// order they appear in the file. Records without the column are skipped
+func (table localTable) values(column string) ([]string, error) {
+	seen := make(map[string]bool)
+	var values []string
+	for _, record := range table.records {
+		value, ok := record[column]
+		if !ok || value == nil {
+			continue
+		}
+		literal, err := sqlLiteral(value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s of %s: %w", column, table.path, err)
+		}
+		if seen[literal] {
+			continue
+		}
+		seen[literal] = true
+		values = append(values, literal)
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s has no values in column %s", table.path, column)
+	}
+	if len(values) > maxLocalValues {
+		return nil, fmt.Errorf("%s has %d values in column %s, at most %d are supported", table.path, len(values), column, maxLocalValues)
+	}
+	return values, nil
+}
+
+// sqlLiteral formats a JSON scalar as a SQL literal
+func sqlLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("only strings, numbers and booleans can be compared, got %v", value)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func localTablesFrom(t *testing.T, ndjson string) map[string]localTable {
+	path := filepath.Join(t.TempDir(), "flagged.ndjson")
+	if err := os.WriteFile(path, []byte(ndjson), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	flags := pflag.NewFlagSet("query", pflag.ContinueOnError)
+	flags.StringArray(withFlag, nil, "")
+	if err := flags.Parse([]string{"--with", "flagged=" + path}); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := readLocalTables(flags)
+	if err != nil {
+		t.Fatalf("failed to read local table: %v", err)
+	}
+	return tables
+}
+
+func TestLocalTableBecomesValueList(t *testing.T) {
+	tables := localTablesFrom(t, `{"id":"a1","note":"x"}
+{"id":"o'brien"}
+
+{"id":"a1"}
+{"other":1}
+`)
+	query, err := applyLocalTables(`select * from backend where user_id IN ( SELECT id FROM flagged ) limit 10`, tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `select * from backend where user_id IN ('a1', 'o''brien') limit 10`
+	if query != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, query)
+	}
+}
+
+func TestLocalTableNumbersAndNotIn(t *testing.T) {
+	tables := localTablesFrom(t, "{\"code\":500}\n{\"code\":503}\n")
+	query, err := applyLocalTables(`select * from backend where status not in (select code from flagged)`, tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(query, "status not IN (500, 503)") {
+		t.Errorf("expected numeric literals, got %s", query)
+	}
+}
+
+func TestLocalTableUnsupportedUse(t *testing.T) {
+	tables := localTablesFrom(t, `{"id":"a1"}`)
+	if _, err := applyLocalTables(`select * from backend join flagged on backend.id = flagged.id`, tables); err == nil {
+		t.Error("expected a join on a local table to be rejected")
+	}
+	if _, err := applyLocalTables(`select * from backend where id in (select missing from flagged)`, tables); err == nil {
+		t.Error("expected a column without values to be rejected")
+	}
+}
+
+func TestLocalTableNameInValuesAndLiterals(t *testing.T) {
+	tables := localTablesFrom(t, `{"id":"flagged"}`)
+	query, err := applyLocalTables(`select * from backend where id in (select id from flagged) and reason != 'flagged'`, tables)
+	if err != nil {
+		t.Fatalf("expected the table name in a value and a literal to be allowed, got %v", err)
+	}
+	if query != `select * from backend where id IN ('flagged') and reason != 'flagged'` {
+		t.Errorf("unexpected query %s", query)
+	}
+
+	if _, err := applyLocalTables(`select * from backend where id in (select id from flagged) or id in (select id from flagged where id = 'x')`, tables); err == nil {
+		t.Error("expected a subquery with a filter to be rejected")
+	}
+}
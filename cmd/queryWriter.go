@@ -0,0 +1,290 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// ResultWriter renders query results one row at a time. Write is called for
+// each row in the order of the result, Flush once after the last row to
+// complete the output. Formats that need every row before they can render,
+// such as table, keep the rows until Flush.
+//
+// A ResultWriter formats rows, the destination it writes to is opened and
+// closed by the caller, see outputSink
+type ResultWriter interface {
+	Write(row map[string]interface{}) error
+	Flush() error
+}
+
+// newResultWriter returns the writer for an output format. showTypes adds
+// the column types found in the result to the table header
+func newResultWriter(w io.Writer, format string, showTypes bool, layout cellLayout) (ResultWriter, error) {
+	switch format {
+	case "json":
+		return &jsonResultWriter{w: w}, nil
+	case "ndjson":
+		return &ndjsonResultWriter{encoder: json.NewEncoder(w)}, nil
+	case "csv":
+		return newCSVResultWriter(w, nil), nil
+	case "table":
+		return &tableResultWriter{w: w, showTypes: showTypes, layout: layout}, nil
+	case "xlsx":
+		return newXLSXResultWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported output format %q", format)
+}
+
+// decorateResultWriter adds the row transforms asked for in opts around
+// writer. Rows are flattened first, so --deduplicate can name flattened
+// fields and nested timestamps are converted by --timezone
+func decorateResultWriter(writer ResultWriter, opts queryOptions) ResultWriter {
+	if len(opts.dedup.fields) > 0 {
+		writer = newDedupResultWriter(writer, opts.dedup, os.Stderr)
+	}
+	if opts.location != nil {
+		writer = &timezoneResultWriter{ResultWriter: writer, converter: newTimestampConverter(opts.location)}
+	}
+	if opts.flatten.enabled {
+		writer = &flattenResultWriter{ResultWriter: writer, opts: opts.flatten}
+	}
+	return writer
+}
+
+// copyRecords writes every record of a JSON array response to writer and
+// flushes it
+func copyRecords(body io.Reader, writer ResultWriter) error {
+	if err := decodeRecords(body, writer.Write); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// discardResults releases what a writer holds when the output is abandoned
+// before Flush, such as the temporary file behind xlsx output
+func discardResults(writer ResultWriter) {
+	if discarder, ok := writer.(interface{ discard() }); ok {
+		discarder.discard()
+	}
+}
+
+// jsonResultWriter writes the rows as a single JSON array
+type jsonResultWriter struct {
+	w    io.Writer
+	rows []map[string]interface{}
+}
+
+func (j *jsonResultWriter) Write(row map[string]interface{}) error {
+	j.rows = append(j.rows, row)
+	return nil
+}
+
+func (j *jsonResultWriter) Flush() error {
+	rows := j.rows
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	encoded, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(j.w, string(encoded))
+	return err
+}
+
+// ndjsonResultWriter writes each row as a JSON object on its own line
+type ndjsonResultWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonResultWriter) Write(row map[string]interface{}) error {
+	return n.encoder.Encode(row)
+}
+
+func (n *ndjsonResultWriter) Flush() error {
+	return nil
+}
+
+// autoJSONResultWriter writes a single JSON array while the result is small,
+// and switches to ndjson once it passes autoNDJSONRows so memory use stays
+// flat
+type autoJSONResultWriter struct {
+	array  jsonResultWriter
+	ndjson *ndjsonResultWriter
+	w      io.Writer
+}
+
+func newAutoJSONResultWriter(w io.Writer) *autoJSONResultWriter {
+	return &autoJSONResultWriter{array: jsonResultWriter{w: w}, w: w}
+}
+
+func (a *autoJSONResultWriter) Write(row map[string]interface{}) error {
+	if a.ndjson != nil {
+		return a.ndjson.Write(row)
+	}
+	a.array.rows = append(a.array.rows, row)
+	if len(a.array.rows) <= autoNDJSONRows {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Warning: the result has more than %d rows, writing it as ndjson, one record per line, instead of a single JSON array to limit memory use. Pass --%s to keep a single array, or --%s to silence this warning.\n", autoNDJSONRows, jsonArrayFlag, ndjsonFlag)
+	a.ndjson = &ndjsonResultWriter{encoder: json.NewEncoder(a.w)}
+	for _, buffered := range a.array.rows {
+		if err := a.ndjson.Write(buffered); err != nil {
+			return err
+		}
+	}
+	a.array.rows = nil
+	return nil
+}
+
+func (a *autoJSONResultWriter) Flush() error {
+	if a.ndjson != nil {
+		return a.ndjson.Flush()
+	}
+	return a.array.Flush()
+}
+
+// csvResultWriter writes the rows as CSV. With fixed columns, rows are
+// written as they come and no header is written. Otherwise the header is the
+// sorted union of the fields of all rows, so rows are kept until Flush
+type csvResultWriter struct {
+	writer  *csv.Writer
+	columns []string
+	rows    []map[string]interface{}
+}
+
+func newCSVResultWriter(w io.Writer, columns []string) *csvResultWriter {
+	return &csvResultWriter{writer: csv.NewWriter(w), columns: columns}
+}
+
+func (c *csvResultWriter) Write(row map[string]interface{}) error {
+	if c.columns == nil {
+		c.rows = append(c.rows, row)
+		return nil
+	}
+	return c.writeRow(row)
+}
+
+func (c *csvResultWriter) Flush() error {
+	if c.columns == nil {
+		c.columns = recordColumns(c.rows)
+		if err := c.writer.Write(c.columns); err != nil {
+			return err
+		}
+		for _, row := range c.rows {
+			if err := c.writeRow(row); err != nil {
+				return err
+			}
+		}
+		c.rows = nil
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvResultWriter) writeRow(row map[string]interface{}) error {
+	cells := make([]string, len(c.columns))
+	for idx, column := range c.columns {
+		cells[idx] = csvValue(row[column])
+	}
+	return c.writer.Write(cells)
+}
+
+// tableResultWriter renders the rows as a table once all of them are known,
+// as the column widths depend on every row
+type tableResultWriter struct {
+	w         io.Writer
+	showTypes bool
+	layout    cellLayout
+	rows      []map[string]interface{}
+}
+
+func (t *tableResultWriter) Write(row map[string]interface{}) error {
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+func (t *tableResultWriter) Flush() error {
+	var types map[string]string
+	if t.showTypes {
+		types = recordTypes(t.rows)
+	}
+	return writeTable(t.w, t.rows, types, t.layout)
+}
+
+// collectResultWriter keeps the rows in memory for callers that need all of
+// them at once
+type collectResultWriter struct {
+	rows []map[string]interface{}
+}
+
+func (c *collectResultWriter) Write(row map[string]interface{}) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *collectResultWriter) Flush() error {
+	return nil
+}
+
+// flattenResultWriter flattens nested fields of each row before passing it
+// on, see --flatten
+type flattenResultWriter struct {
+	ResultWriter
+	opts flattenOptions
+}
+
+func (f *flattenResultWriter) Write(row map[string]interface{}) error {
+	return f.ResultWriter.Write(flattenRecords([]map[string]interface{}{row}, f.opts)[0])
+}
+
+// columnsResultWriter records the fields seen across all rows and their
+// types before passing each row on, for the --types-file written next to
+// the results
+type columnsResultWriter struct {
+	ResultWriter
+	seen  map[string]struct{}
+	types resultColumnTypes
+}
+
+func (c *columnsResultWriter) Write(row map[string]interface{}) error {
+	if c.seen == nil {
+		c.seen = make(map[string]struct{})
+		c.types = make(resultColumnTypes)
+	}
+	for key := range row {
+		c.seen[key] = struct{}{}
+	}
+	c.types.observe(row)
+	return c.ResultWriter.Write(row)
+}
+
+// columns returns the recorded fields in sorted order
+func (c *columnsResultWriter) columns() []string {
+	names := make([]string, 0, len(c.seen))
+	for name := range c.seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var writerCases = map[string]string{
+	"empty":  `[]`,
+	"single": `[{"host":"a","status":200}]`,
+	"nested": `[{"host":"a","user":{"id":7,"tags":["x","y"]}}]`,
+}
+
+// writeWith formats a JSON array response with the writer for format
+func writeWith(t *testing.T, format, body string) string {
+	var out bytes.Buffer
+	writer, err := newResultWriter(&out, format, false, cellLayout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := copyRecords(strings.NewReader(body), writer); err != nil {
+		t.Fatalf("%s writer failed: %v", format, err)
+	}
+	return out.String()
+}
+
+func TestJSONResultWriter(t *testing.T) {
+	expected := map[string]string{
+		"empty":  "[]\n",
+		"single": "[\n  {\n    \"host\": \"a\",\n    \"status\": 200\n  }\n]\n",
+		"nested": "[\n  {\n    \"host\": \"a\",\n    \"user\": {\n      \"id\": 7,\n      \"tags\": [\n        \"x\",\n        \"y\"\n      ]\n    }\n  }\n]\n",
+	}
+	for name, body := range writerCases {
+		if out := writeWith(t, "json", body); out != expected[name] {
+			t.Errorf("%s: expected %q, got %q", name, expected[name], out)
+		}
+	}
+}
+
+func TestNDJSONResultWriter(t *testing.T) {
+	expected := map[string]string{
+		"empty":  "",
+		"single": `{"host":"a","status":200}` + "\n",
+		"nested": `{"host":"a","user":{"id":7,"tags":["x","y"]}}` + "\n",
+	}
+	for name, body := range writerCases {
+		if out := writeWith(t, "ndjson", body); out != expected[name] {
+			t.Errorf("%s: expected %q, got %q", name, expected[name], out)
+		}
+	}
+}
+
+func TestCSVResultWriter(t *testing.T) {
+	expected := map[string]string{
+		"empty":  "\n",
+		"single": "host,status\na,200\n",
+		"nested": "host,user\na,\"{\"\"id\"\":7,\"\"tags\"\":[\"\"x\"\",\"\"y\"\"]}\"\n",
+	}
+	for name, body := range writerCases {
+		if out := writeWith(t, "csv", body); out != expected[name] {
+			t.Errorf("%s: expected %q, got %q", name, expected[name], out)
+		}
+	}
+}
+
+func TestCSVResultWriterFixedColumns(t *testing.T) {
+	var out bytes.Buffer
+	writer := newCSVResultWriter(&out, []string{"status", "host"})
+	if err := copyRecords(strings.NewReader(writerCases["single"]), writer); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "200,a\n" {
+		t.Errorf("expected a row in the given column order without a header, got %q", out.String())
+	}
+}
+
+func TestTableResultWriter(t *testing.T) {
+	if out := writeWith(t, "table", writerCases["empty"]); out != "No results\n" {
+		t.Errorf("empty: expected no results message, got %q", out)
+	}
+
+	out := writeWith(t, "table", writerCases["single"])
+	if !strings.Contains(out, "host") || !strings.Contains(out, "200") {
+		t.Errorf("single: expected header and row, got:\n%s", out)
+	}
+
+	out = writeWith(t, "table", writerCases["nested"])
+	if !strings.Contains(out, `{"id":7,"tags":["x","y"]}`) {
+		t.Errorf("nested: expected nested value as JSON, got:\n%s", out)
+	}
+}
+
+func TestXLSXResultWriter(t *testing.T) {
+	sheet, _ := readXLSXSheet(t, []byte(writeWith(t, "xlsx", writerCases["empty"])))
+	if len(sheet.Rows) != 1 || len(sheet.Rows[0].Cells) != 0 {
+		t.Errorf("empty: expected only an empty header row, got %+v", sheet.Rows)
+	}
+
+	sheet, cells := readXLSXSheet(t, []byte(writeWith(t, "xlsx", writerCases["single"])))
+	if len(sheet.Rows) != 2 || cells["A2"].Inline != "a" || cells["B2"].Value != "200" {
+		t.Errorf("single: expected a header and one row, got %+v", cells)
+	}
+
+	_, cells = readXLSXSheet(t, []byte(writeWith(t, "xlsx", writerCases["nested"])))
+	if cells["B2"].Inline != `{"id":7,"tags":["x","y"]}` {
+		t.Errorf("nested: expected nested value as JSON, got %+v", cells["B2"])
+	}
+}
+
+func TestAutoJSONResultWriterSwitchesToNDJSON(t *testing.T) {
+	defer func(rows int) { autoNDJSONRows = rows }(autoNDJSONRows)
+	autoNDJSONRows = 1
+
+	var out bytes.Buffer
+	if err := copyRecords(strings.NewReader(writerCases["empty"]), newAutoJSONResultWriter(&out)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "[]\n" {
+		t.Errorf("empty: expected an empty array, got %q", out.String())
+	}
+
+	out.Reset()
+	body := `[{"host":"a"},{"host":"b"}]`
+	if err := copyRecords(strings.NewReader(body), newAutoJSONResultWriter(&out)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "{\"host\":\"a\"}\n{\"host\":\"b\"}\n" {
+		t.Errorf("expected ndjson once past the row limit, got %q", out.String())
+	}
+}
+
+func TestFlattenAndColumnsResultWriters(t *testing.T) {
+	var out bytes.Buffer
+	columns := &columnsResultWriter{ResultWriter: newCSVResultWriter(&out, nil)}
+	writer := &flattenResultWriter{ResultWriter: columns, opts: flattenOptions{enabled: true, separator: "."}}
+	if err := copyRecords(strings.NewReader(writerCases["nested"]), writer); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(out.String(), "host,user.id,user.tags\n") {
+		t.Errorf("expected flattened columns, got %q", out.String())
+	}
+	if seen := strings.Join(columns.columns(), ","); seen != "host,user.id,user.tags" {
+		t.Errorf("expected the flattened fields to be recorded, got %s", seen)
+	}
+}
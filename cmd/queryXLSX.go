@@ -0,0 +1,332 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// xlsxMaxRows is the row limit of an Excel sheet, including the header
+	xlsxMaxRows = 1048576
+
+	// xlsxMaxColumns and xlsxMaxCellChars are the column and cell length
+	// limits of Excel. Columns past the limit are left out and longer cells
+	// are cut short, as Excel refuses to open a workbook that exceeds them
+	xlsxMaxColumns   = 16384
+	xlsxMaxCellChars = 32767
+
+	// xlsxDateStyle and xlsxHeaderStyle index the cell formats in xlsxStyles
+	xlsxDateStyle   = 1
+	xlsxHeaderStyle = 2
+)
+
+// xlsxEpoch is day zero of Excel's date serial numbers
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxSheet collects the rows of a sheet in a temporary file, so large
+// results do not have to be held in memory. Columns are added as records
+// bring new fields, and the header row is written when the sheet is done
+type xlsxSheet struct {
+	rows    *os.File
+	buf     *bufio.Writer
+	columns []string
+	index   map[string]int
+	types   resultColumnTypes
+	count   int
+
+	// dropped holds the columns past xlsxMaxColumns and truncated counts the
+	// cells cut to xlsxMaxCellChars, both reported on warnings
+	dropped   map[string]bool
+	truncated int
+	warnings  io.Writer
+}
+
+func newXLSXSheet() (*xlsxSheet, error) {
+	rows, err := os.CreateTemp("", "pb-xlsx-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	return &xlsxSheet{
+		rows:     rows,
+		buf:      bufio.NewWriter(rows),
+		index:    make(map[string]int),
+		types:    make(resultColumnTypes),
+		dropped:  make(map[string]bool),
+		warnings: os.Stderr,
+	}, nil
+}
+
+// add writes one record as a row
+func (s *xlsxSheet) add(record map[string]interface{}) error {
+	if s.count+1 >= xlsxMaxRows {
+		return fmt.Errorf("the result has more rows than an Excel sheet can hold (%d), narrow the query or use csv output", xlsxMaxRows-1)
+	}
+	s.count++
+
+	var added []string
+	for key := range record {
+		if _, ok := s.index[key]; !ok && !s.dropped[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		if len(s.columns) >= xlsxMaxColumns {
+			s.dropped[key] = true
+			continue
+		}
+		s.index[key] = len(s.columns)
+		s.columns = append(s.columns, key)
+	}
+	s.types.observe(record)
+
+	row := s.count + 1
+	fmt.Fprintf(s.buf, `<row r="%d">`, row)
+	for idx, column := range s.columns {
+		if value, ok := record[column]; ok && value != nil {
+			s.writeCell(idx, row, value, s.types[column])
+		}
+	}
+	_, err := s.buf.WriteString("</row>")
+	return err
+}
+
+// writeCell writes a value as a native Excel cell: numbers, booleans and
+// timestamp columns keep their type, nested values become JSON strings. A
+// column is written as dates while every value seen so far is a timestamp
+func (s *xlsxSheet) writeCell(column, row int, value interface{}, columnType string) {
+	ref := xlsxCellRef(column, row)
+	switch v := value.(type) {
+	case float64:
+		fmt.Fprintf(s.buf, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		cell := "0"
+		if v {
+			cell = "1"
+		}
+		fmt.Fprintf(s.buf, `<c r="%s" t="b"><v>%s</v></c>`, ref, cell)
+	case string:
+		if columnType == "datetime" {
+			if serial, ok := xlsxDate(v); ok {
+				fmt.Fprintf(s.buf, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxDateStyle, strconv.FormatFloat(serial, 'f', -1, 64))
+				return
+			}
+		}
+		s.writeText(ref, v)
+	default:
+		encoded, _ := json.Marshal(v)
+		s.writeText(ref, string(encoded))
+	}
+}
+
+// writeText writes a text cell, cut to the length Excel accepts
+func (s *xlsxSheet) writeText(ref, value string) {
+	if utf8.RuneCountInString(value) > xlsxMaxCellChars {
+		value = string([]rune(value)[:xlsxMaxCellChars])
+		s.truncated++
+	}
+	writeXLSXString(s.buf, ref, value, 0)
+}
+
+// warn reports the columns and cells that did not fit in the sheet
+func (s *xlsxSheet) warn() {
+	if len(s.dropped) > 0 {
+		fmt.Fprintf(s.warnings, "Warning: the result has %d more columns than an Excel sheet can hold (%d), they are left out of the workbook. Select fewer columns or use csv output\n", len(s.dropped), xlsxMaxColumns)
+	}
+	if s.truncated > 0 {
+		fmt.Fprintf(s.warnings, "Warning: %d cells are longer than an Excel cell can hold (%d characters) and are cut short in the workbook. Use csv or json output for the full values\n", s.truncated, xlsxMaxCellChars)
+	}
+}
+
+// writeTo writes the workbook with the collected rows and removes the
+// temporary file
+func (s *xlsxSheet) writeTo(w io.Writer) error {
+	defer os.Remove(s.rows.Name())
+	defer s.rows.Close()
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if _, err := s.rows.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, xml.Header+part.content); err != nil {
+			return err
+		}
+	}
+
+	file, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	sheet := bufio.NewWriter(file)
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// freeze the header row so it stays visible while scrolling
+	sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	sheet.WriteString(`<sheetData><row r="1">`)
+	for idx, column := range s.columns {
+		writeXLSXString(sheet, xlsxCellRef(idx, 1), column, xlsxHeaderStyle)
+	}
+	sheet.WriteString(`</row>`)
+	if _, err := io.Copy(sheet, s.rows); err != nil {
+		return err
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+	if err := sheet.Flush(); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	s.warn()
+	return nil
+}
+
+// discard removes the temporary file of a sheet that is not written
+func (s *xlsxSheet) discard() {
+	s.rows.Close()
+	os.Remove(s.rows.Name())
+}
+
+// xlsxResultWriter writes the rows as an Excel workbook with a single sheet
+type xlsxResultWriter struct {
+	w     io.Writer
+	sheet *xlsxSheet
+}
+
+func newXLSXResultWriter(w io.Writer) (*xlsxResultWriter, error) {
+	sheet, err := newXLSXSheet()
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxResultWriter{w: w, sheet: sheet}, nil
+}
+
+func (x *xlsxResultWriter) Write(row map[string]interface{}) error {
+	return x.sheet.add(row)
+}
+
+func (x *xlsxResultWriter) Flush() error {
+	return x.sheet.writeTo(x.w)
+}
+
+// discard removes the rows collected so far when the workbook is not written
+func (x *xlsxResultWriter) discard() {
+	x.sheet.discard()
+}
+
+func writeXLSXString(w *bufio.Writer, ref, value string, style int) {
+	if style != 0 {
+		fmt.Fprintf(w, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+	} else {
+		fmt.Fprintf(w, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+	}
+	xml.EscapeText(w, []byte(value))
+	w.WriteString(`</t></is></c>`)
+}
+
+// xlsxDate converts a server timestamp to an Excel date serial number
+func xlsxDate(value string) (float64, bool) {
+	t, ok := parseServerTimestamp(value)
+	if !ok {
+		return 0, false
+	}
+	return float64(t.Sub(xlsxEpoch)) / float64(24*time.Hour), true
+}
+
+// xlsxCellRef returns the A1 style reference of a zero based column and a
+// one based row
+func xlsxCellRef(column, row int) string {
+	var letters []byte
+	for column >= 0 {
+		letters = append([]byte{byte('A' + column%26)}, letters...)
+		column = column/26 - 1
+	}
+	return string(letters) + strconv.Itoa(row)
+}
+
+var (
+	xlsxContentTypes = strings.Join([]string{
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`,
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`,
+		`<Default Extension="xml" ContentType="application/xml"/>`,
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`,
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`,
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`,
+		`</Types>`,
+	}, "")
+
+	xlsxRootRels = strings.Join([]string{
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`,
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>`,
+		`</Relationships>`,
+	}, "")
+
+	xlsxWorkbook = strings.Join([]string{
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`,
+		`<sheets><sheet name="Results" sheetId="1" r:id="rId1"/></sheets>`,
+		`</workbook>`,
+	}, "")
+
+	xlsxWorkbookRels = strings.Join([]string{
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`,
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>`,
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`,
+		`</Relationships>`,
+	}, "")
+
+	// xlsxStyles defines the default cell format, a date and time format for
+	// timestamps and a bold format for the header row
+	xlsxStyles = strings.Join([]string{
+		`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`,
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>`,
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>`,
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>`,
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>`,
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`,
+		`<cellXfs count="3">`,
+		`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>`,
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`,
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>`,
+		`</cellXfs>`,
+		`</styleSheet>`,
+	}, "")
+)
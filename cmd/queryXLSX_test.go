@@ -0,0 +1,211 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type xlsxTestCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  string `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+type xlsxTestSheet struct {
+	Pane struct {
+		YSplit string `xml:"ySplit,attr"`
+		State  string `xml:"state,attr"`
+	} `xml:"sheetViews>sheetView>pane"`
+	Rows []struct {
+		Cells []xlsxTestCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSXSheet opens a workbook and returns its first sheet by cell reference
+func readXLSXSheet(t *testing.T, data []byte) (xlsxTestSheet, map[string]xlsxTestCell) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("output is not a zip archive: %v", err)
+	}
+	file, err := archive.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatalf("workbook has no sheet: %v", err)
+	}
+	content, _ := io.ReadAll(file)
+
+	var sheet xlsxTestSheet
+	if err := xml.Unmarshal(content, &sheet); err != nil {
+		t.Fatalf("sheet is not valid XML: %v", err)
+	}
+	cells := make(map[string]xlsxTestCell)
+	for _, row := range sheet.Rows {
+		for _, cell := range row.Cells {
+			cells[cell.Ref] = cell
+		}
+	}
+	return sheet, cells
+}
+
+func writeXLSXBody(w io.Writer, body string) error {
+	writer, err := newXLSXResultWriter(w)
+	if err != nil {
+		return err
+	}
+	return copyRecords(strings.NewReader(body), writer)
+}
+
+func TestWriteXLSXTypedCells(t *testing.T) {
+	body := `[
+		{"p_timestamp": "2024-01-02T12:00:00.000", "status": 200, "ok": true, "host": "a<b", "user": {"id": 7}},
+		{"p_timestamp": "2024-01-03T00:00:00.000", "status": 500, "ok": false, "host": "b", "extra": "late"}
+	]`
+
+	var out bytes.Buffer
+	if err := writeXLSXBody(&out, body); err != nil {
+		t.Fatalf("expected xlsx to be written, got %v", err)
+	}
+	sheet, cells := readXLSXSheet(t, out.Bytes())
+
+	if sheet.Pane.YSplit != "1" || sheet.Pane.State != "frozen" {
+		t.Errorf("expected the header row to be frozen, got %+v", sheet.Pane)
+	}
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %d rows", len(sheet.Rows))
+	}
+
+	// columns are sorted by name per record, new fields are added at the end
+	header := []string{"host", "ok", "p_timestamp", "status", "user", "extra"}
+	for idx, cell := range sheet.Rows[0].Cells {
+		if cell.Inline != header[idx] || cell.Style != "2" {
+			t.Errorf("expected bold header %q, got %+v", header[idx], cell)
+		}
+	}
+
+	checks := map[string]xlsxTestCell{
+		"A2": {Type: "inlineStr", Inline: "a<b"},
+		"B2": {Type: "b", Value: "1"},
+		"C2": {Style: "1", Value: "45293.5"},
+		"D2": {Value: "200"},
+		"E2": {Type: "inlineStr", Inline: `{"id":7}`},
+		"B3": {Type: "b", Value: "0"},
+		"C3": {Style: "1", Value: "45294"},
+		"F3": {Type: "inlineStr", Inline: "late"},
+	}
+	for ref, expected := range checks {
+		cell := cells[ref]
+		if cell.Type != expected.Type || cell.Style != expected.Style || cell.Value != expected.Value || cell.Inline != expected.Inline {
+			t.Errorf("cell %s: expected %+v, got %+v", ref, expected, cell)
+		}
+	}
+}
+
+func TestWriteXLSXTextColumnStaysText(t *testing.T) {
+	var out bytes.Buffer
+	body := `[{"created": "yesterday"}, {"created": "2024-01-02T12:00:00.000"}]`
+	if err := writeXLSXBody(&out, body); err != nil {
+		t.Fatal(err)
+	}
+	_, cells := readXLSXSheet(t, out.Bytes())
+	if cells["A3"].Type != "inlineStr" {
+		t.Errorf("expected a timestamp in a text column to stay text, got %+v", cells["A3"])
+	}
+}
+
+func TestWriteXLSXCutsLongCells(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := newXLSXResultWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings strings.Builder
+	writer.sheet.warnings = &warnings
+
+	long := strings.Repeat("é", xlsxMaxCellChars+10)
+	if err := writer.Write(map[string]interface{}{"message": long, "short": "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, cells := readXLSXSheet(t, out.Bytes())
+	if got := []rune(cells["A2"].Inline); len(got) != xlsxMaxCellChars {
+		t.Errorf("expected the cell to be cut to %d characters, got %d", xlsxMaxCellChars, len(got))
+	}
+	if cells["B2"].Inline != "ok" {
+		t.Errorf("expected a short cell to be kept, got %+v", cells["B2"])
+	}
+	if !strings.Contains(warnings.String(), "1 cells are longer than an Excel cell can hold") {
+		t.Errorf("expected a warning about the cut cell, got %q", warnings.String())
+	}
+}
+
+func TestWriteXLSXLeavesOutExtraColumns(t *testing.T) {
+	record := make(map[string]interface{}, xlsxMaxColumns+2)
+	for idx := 0; idx < xlsxMaxColumns+2; idx++ {
+		record[fmt.Sprintf("c%05d", idx)] = idx
+	}
+	body, _ := json.Marshal([]map[string]interface{}{record})
+
+	var out bytes.Buffer
+	writer, err := newXLSXResultWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings strings.Builder
+	writer.sheet.warnings = &warnings
+	if err := copyRecords(bytes.NewReader(body), writer); err != nil {
+		t.Fatal(err)
+	}
+
+	sheet, _ := readXLSXSheet(t, out.Bytes())
+	if got := len(sheet.Rows[0].Cells); got != xlsxMaxColumns {
+		t.Errorf("expected %d header cells, got %d", xlsxMaxColumns, got)
+	}
+	if got := len(sheet.Rows[1].Cells); got != xlsxMaxColumns {
+		t.Errorf("expected %d cells in the row, got %d", xlsxMaxColumns, got)
+	}
+	if !strings.Contains(warnings.String(), "2 more columns than an Excel sheet can hold") {
+		t.Errorf("expected a warning about the left out columns, got %q", warnings.String())
+	}
+}
+
+func TestXLSXRequiresOutputFile(t *testing.T) {
+	if err := validateOutputFileOptions(queryOptions{outputFormat: "xlsx"}); err == nil || !strings.Contains(err.Error(), "--output-file") {
+		t.Errorf("expected xlsx without --output-file to be rejected, got %v", err)
+	}
+	if err := validateOutputFileOptions(queryOptions{outputFormat: "xlsx", outputFile: "out.xlsx"}); err != nil {
+		t.Errorf("expected xlsx with --output-file to pass, got %v", err)
+	}
+}
+
+func TestXLSXCellRef(t *testing.T) {
+	for column, expected := range map[int]string{0: "A1", 25: "Z1", 26: "AA1", 701: "ZZ1", 702: "AAA1"} {
+		if ref := xlsxCellRef(column, 1); ref != expected {
+			t.Errorf("column %d: expected %s, got %s", column, expected, ref)
+		}
+	}
+}
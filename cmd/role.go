@@ -65,9 +65,18 @@ func (user *RoleData) Render() string {
 
 var AddRoleCmd = &cobra.Command{
 	Use:     "add role-name",
-	Example: "  pb role add ingestors",
+	Example: "  pb role add ingestors\n  pb role add oncall --inherit=base_reader,ingestors",
 	Short:   "Add a new role",
-	Args:    cobra.ExactArgs(1),
+	Long: `
+Add a new role.
+
+--inherit copies the privileges of one or more existing roles into the new
+role, in addition to the privilege chosen in the prompt (choose none to only
+inherit). Parseable has no role inheritance, so later changes to the
+inherited roles do not reach the new role. pb records which roles were
+inherited in a local file next to the config file and refuses inheritance
+that would form a cycle.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		startTime := time.Now()
 		cmd.Annotations = make(map[string]string)
@@ -89,6 +98,21 @@ var AddRoleCmd = &cobra.Command{
 			return nil
 		}
 
+		inheritValues, _ := cmd.Flags().GetStringSlice(roleInheritFlag)
+		parents, err := parseInheritedRoles(inheritValues)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		var privileges []RoleData
+		if len(parents) > 0 {
+			privileges, err = inheritPrivileges(&client, roles, name, parents)
+			if err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+		}
+
 		_m, err := tea.NewProgram(role.New()).Run()
 		if err != nil {
 			cmd.Annotations["errors"] = fmt.Sprintf("Error initializing program: %s", err.Error())
@@ -105,7 +129,6 @@ var AddRoleCmd = &cobra.Command{
 			return nil
 		}
 
-		var putBody io.Reader
 		if privilege != "none" {
 			roleData := RoleData{Privilege: privilege}
 			switch privilege {
@@ -114,7 +137,12 @@ var AddRoleCmd = &cobra.Command{
 			case "reader":
 				roleData.Resource = &RoleResource{Stream: stream, Tag: tag}
 			}
-			roleDataJSON, _ := json.Marshal([]RoleData{roleData})
+			privileges = mergePrivileges(privileges, []RoleData{roleData})
+		}
+
+		var putBody io.Reader
+		if len(privileges) > 0 {
+			roleDataJSON, _ := json.Marshal(privileges)
 			putBody = bytes.NewBuffer(roleDataJSON)
 		}
 
@@ -140,6 +168,13 @@ var AddRoleCmd = &cobra.Command{
 
 		if resp.StatusCode == 200 {
 			fmt.Printf("Added role %s", name)
+			if len(parents) > 0 {
+				recordRoleInheritance(DefaultProfile.URL, name, parents)
+				fmt.Printf(" inheriting from %s\n\nEffective privileges:\n", strings.Join(parents, ", "))
+				for _, privilege := range privileges {
+					fmt.Println(lipgloss.NewStyle().PaddingLeft(3).Render(privilege.Render()))
+				}
+			}
 		} else {
 			cmd.Annotations["errors"] = fmt.Sprintf("Request failed - Status: %s, Response: %s", resp.Status, body)
 			fmt.Printf("Request Failed\nStatus Code: %s\nResponse: %s\n", resp.Status, body)
@@ -152,9 +187,14 @@ var AddRoleCmd = &cobra.Command{
 var RemoveRoleCmd = &cobra.Command{
 	Use:     "remove role-name",
 	Aliases: []string{"rm"},
-	Example: "  pb role remove ingestor",
+	Example: "  pb role remove ingestor\n  pb role remove ingestor --yes",
 	Short:   "Delete a role",
-	Args:    cobra.ExactArgs(1),
+	Long: `Delete a role.
+
+pb asks you to type the role name before deleting the role. Pass --yes to
+skip the prompt. When stdin is not a terminal, pb cannot ask and stops with
+an error unless --yes is set.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		startTime := time.Now()
 		cmd.Annotations = make(map[string]string)
@@ -163,6 +203,16 @@ var RemoveRoleCmd = &cobra.Command{
 		}()
 
 		name := args[0]
+		confirmed, err := confirmDestructive(cmd, fmt.Sprintf("Deleting role %s", name), name)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted, the role was not deleted")
+			return nil
+		}
+
 		client := internalHTTP.DefaultClient(&DefaultProfile)
 		req, err := client.NewRequest("DELETE", "role/"+name, nil)
 		if err != nil {
@@ -179,6 +229,7 @@ var RemoveRoleCmd = &cobra.Command{
 
 		if resp.StatusCode == 200 {
 			fmt.Printf("Removed role %s\n", StyleBold.Render(name))
+			forgetRoleInheritance(DefaultProfile.URL, name)
 		} else {
 			bodyBytes, err := io.ReadAll(resp.Body)
 			if err != nil {
@@ -195,9 +246,15 @@ var RemoveRoleCmd = &cobra.Command{
 }
 
 var ListRoleCmd = &cobra.Command{
-	Use:     "list",
-	Short:   "List all roles",
-	Example: "  pb role list",
+	Use:   "list",
+	Short: "List all roles",
+	Long: `List all roles with their privileges.
+
+Pass --user-counts to also show the number of users holding each role, and
+--unused to only list roles no user holds, the candidates for removal with
+pb role remove. Both fetch the roles of every user, a request per user. With
+either flag, JSON output maps each role to its privileges and users count.`,
+	Example: "  pb role list\n  pb role list --user-counts\n  pb role list --unused",
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		startTime := time.Now()
 		cmd.Annotations = make(map[string]string)
@@ -218,6 +275,24 @@ var ListRoleCmd = &cobra.Command{
 			cmd.Annotations["errors"] = fmt.Sprintf("Error retrieving output flag: %s", err.Error())
 			return err
 		}
+		unused, _ := cmd.Flags().GetBool(unusedRolesFlag)
+		userCounts, _ := cmd.Flags().GetBool(roleUserCountsFlag)
+
+		// counting users takes a request per user, so it is only done when
+		// asked for
+		var counts map[string]int
+		if unused || userCounts {
+			assignments, err := fetchAllUserRoles(&client)
+			if err != nil {
+				err = fmt.Errorf("failed to fetch user role assignments: %w", err)
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+			counts = countRoleUsers(roles, assignments)
+		}
+		if unused {
+			roles = unusedRoles(roles, counts)
+		}
 
 		roleResponses := make([]struct {
 			data []RoleData
@@ -235,11 +310,23 @@ var ListRoleCmd = &cobra.Command{
 		wg.Wait()
 
 		if outputFormat == "json" {
-			allRoles := map[string][]RoleData{}
-			for idx, roleName := range roles {
-				if roleResponses[idx].err == nil {
-					allRoles[roleName] = roleResponses[idx].data
+			var allRoles interface{}
+			if counts != nil {
+				entries := map[string]roleListEntry{}
+				for idx, roleName := range roles {
+					if roleResponses[idx].err == nil {
+						entries[roleName] = roleListEntry{Privileges: roleResponses[idx].data, Users: counts[roleName]}
+					}
+				}
+				allRoles = entries
+			} else {
+				privileges := map[string][]RoleData{}
+				for idx, roleName := range roles {
+					if roleResponses[idx].err == nil {
+						privileges[roleName] = roleResponses[idx].data
+					}
 				}
+				allRoles = privileges
 			}
 			jsonOutput, err := json.MarshalIndent(allRoles, "", "  ")
 			if err != nil {
@@ -250,11 +337,20 @@ var ListRoleCmd = &cobra.Command{
 			return nil
 		}
 
+		if unused && len(roles) == 0 {
+			fmt.Println("No unused roles found, every role is held by at least one user")
+			return nil
+		}
+
 		fmt.Println()
 		for idx, roleName := range roles {
 			fetchRes := roleResponses[idx]
 			fmt.Print("• ")
-			fmt.Println(StandardStyleBold.Bold(true).Render(roleName))
+			fmt.Print(StandardStyleBold.Bold(true).Render(roleName))
+			if count, ok := counts[roleName]; ok {
+				fmt.Print(StandardStyleAlt.Render(fmt.Sprintf(" (%s)", formatUserCount(count))))
+			}
+			fmt.Println()
 			if fetchRes.err == nil {
 				for _, role := range fetchRes.data {
 					fmt.Println(lipgloss.NewStyle().PaddingLeft(3).Render(role.Render()))
@@ -264,6 +360,9 @@ var ListRoleCmd = &cobra.Command{
 				cmd.Annotations["errors"] += fmt.Sprintf("Error fetching role data for %s: %v\n", roleName, fetchRes.err)
 			}
 		}
+		if unused {
+			fmt.Printf("\n%d unused role(s), remove them with pb role remove <role-name>\n", len(roles))
+		}
 
 		return nil
 	},
@@ -333,4 +432,7 @@ func fetchSpecificRole(client *internalHTTP.HTTPClient, role string) (res []Role
 func init() {
 	// Add the --output flag with default value "text"
 	ListRoleCmd.Flags().StringP("output", "o", "text", "Output format: 'text' or 'json'")
+	ListRoleCmd.Flags().Bool(unusedRolesFlag, false, "Only list roles that no user holds")
+	ListRoleCmd.Flags().Bool(roleUserCountsFlag, false, "Show the number of users holding each role")
+	AddRoleCmd.Flags().StringSlice(roleInheritFlag, nil, "Copy the privileges of these existing roles into the new role, e.g. base_reader,ingestors")
 }
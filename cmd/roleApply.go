@@ -0,0 +1,337 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pb/pkg/common"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	roleApplyDirFlag    = "dir"
+	roleApplyDryRunFlag = "dry-run"
+)
+
+// roleDefinition is the desired state of one role, read from a file
+type roleDefinition struct {
+	Name       string     `json:"name" yaml:"name"`
+	Privileges []RoleData `json:"privileges" yaml:"privileges"`
+	path       string
+}
+
+// roleChange is what applying a definition does to a role
+type roleChange struct {
+	name    string
+	action  string // created, updated, unchanged or failed
+	added   []RoleData
+	removed []RoleData
+	err     error
+}
+
+// ApplyRoleCmd makes the roles on the server match a directory of role
+// definition files
+var ApplyRoleCmd = &cobra.Command{
+	Use:     "apply --dir directory",
+	Example: "  pb role apply --dir ./roles --dry-run\n  pb role apply --dir ./roles",
+	Short:   "Create or update roles from definition files",
+	Long: `Create or update roles so they match the definition files in a directory.
+
+Each .json, .yaml or .yml file defines one role with a name and a list of
+privileges. The name defaults to the file name without its extension:
+
+  name: ops
+  privileges:
+    - privilege: editor
+    - privilege: reader
+      resource:
+        stream: backend
+        tag: prod
+
+Missing roles are created and roles whose privileges differ are updated,
+other roles are left alone. The order of privileges does not matter. Roles
+on the server without a file are not removed. Use --dry-run to see the
+changes without applying them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		dir, _ := cmd.Flags().GetString(roleApplyDirFlag)
+		definitions, err := readRoleDefinitions(dir)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+		changes, err := planRoleApply(&client, definitions)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		dryRun, _ := cmd.Flags().GetBool(roleApplyDryRunFlag)
+		if !dryRun {
+			applyRoleChanges(&client, definitions, changes)
+		}
+		printRoleChanges(os.Stdout, changes, dryRun)
+
+		if failed := countRoleChanges(changes, "failed"); failed > 0 {
+			cmd.SilenceUsage = true
+			err := fmt.Errorf("failed to apply %d role(s)", failed)
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	ApplyRoleCmd.Flags().String(roleApplyDirFlag, "", "Directory with one role definition file per role")
+	ApplyRoleCmd.MarkFlagRequired(roleApplyDirFlag)
+	ApplyRoleCmd.Flags().Bool(roleApplyDryRunFlag, false, "Show the changes without applying them")
+}
+
+// readRoleDefinitions reads every role definition file in dir, sorted by
+// role name. Other files are ignored
+func readRoleDefinitions(dir string) ([]roleDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role directory: %w", err)
+	}
+
+	var definitions []roleDefinition
+	paths := map[string]string{}
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		definition, err := readRoleDefinition(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := paths[definition.Name]; ok {
+			return nil, fmt.Errorf("role %s is defined in both %s and %s", definition.Name, other, path)
+		}
+		paths[definition.Name] = path
+		definitions = append(definitions, definition)
+	}
+
+	if len(definitions) == 0 {
+		return nil, fmt.Errorf("no role definition files (.json, .yaml or .yml) in %s", dir)
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+	return definitions, nil
+}
+
+func readRoleDefinition(path string) (roleDefinition, error) {
+	definition := roleDefinition{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return definition, err
+	}
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &definition)
+	} else {
+		err = yaml.Unmarshal(data, &definition)
+	}
+	if err != nil {
+		return definition, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if definition.Name == "" {
+		definition.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for _, privilege := range definition.Privileges {
+		if err := validatePrivilege(privilege); err != nil {
+			return definition, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return definition, nil
+}
+
+// validatePrivilege checks that a privilege has the resource its kind needs:
+// admin and editor apply to every stream, writer and ingestor to one stream,
+// and reader to one stream, optionally narrowed to a tag
+func validatePrivilege(privilege RoleData) error {
+	resource := privilege.Resource
+	switch privilege.Privilege {
+	case "admin", "editor":
+		if resource != nil && (resource.Stream != "" || resource.Tag != "") {
+			return fmt.Errorf("privilege %s applies to every stream and takes no resource", privilege.Privilege)
+		}
+	case "writer", "ingestor":
+		if resource == nil || resource.Stream == "" || resource.Tag != "" {
+			return fmt.Errorf("privilege %s needs a resource with a stream and no tag", privilege.Privilege)
+		}
+	case "reader":
+		if resource == nil || resource.Stream == "" {
+			return fmt.Errorf("privilege reader needs a resource with a stream")
+		}
+	default:
+		return fmt.Errorf("unknown privilege %q, use admin, editor, writer, ingestor or reader", privilege.Privilege)
+	}
+	return nil
+}
+
+// planRoleApply compares each definition with the role on the server
+func planRoleApply(client *internalHTTP.HTTPClient, definitions []roleDefinition) ([]roleChange, error) {
+	var existing []string
+	if err := fetchRoles(client, &existing); err != nil {
+		return nil, err
+	}
+
+	changes := make([]roleChange, len(definitions))
+	for idx, definition := range definitions {
+		change := roleChange{name: definition.Name}
+		if !slices.Contains(existing, definition.Name) {
+			change.action = "created"
+			change.added = mergePrivileges(definition.Privileges)
+		} else if current, err := fetchSpecificRole(client, definition.Name); err != nil {
+			change.action = "failed"
+			change.err = err
+		} else {
+			change.added, change.removed = diffPrivileges(current, definition.Privileges)
+			change.action = "unchanged"
+			if len(change.added) > 0 || len(change.removed) > 0 {
+				change.action = "updated"
+			}
+		}
+		changes[idx] = change
+	}
+	return changes, nil
+}
+
+// diffPrivileges returns the privileges of desired that current lacks, and
+// those of current that desired lacks, regardless of order
+func diffPrivileges(current, desired []RoleData) (added, removed []RoleData) {
+	currentKeys := map[string]bool{}
+	for _, privilege := range current {
+		currentKeys[privilegeKey(privilege)] = true
+	}
+	desiredKeys := map[string]bool{}
+	for _, privilege := range mergePrivileges(desired) {
+		desiredKeys[privilegeKey(privilege)] = true
+		if !currentKeys[privilegeKey(privilege)] {
+			added = append(added, privilege)
+		}
+	}
+	for _, privilege := range mergePrivileges(current) {
+		if !desiredKeys[privilegeKey(privilege)] {
+			removed = append(removed, privilege)
+		}
+	}
+	return added, removed
+}
+
+// applyRoleChanges writes the created and updated roles to the server,
+// marking the ones that fail
+func applyRoleChanges(client *internalHTTP.HTTPClient, definitions []roleDefinition, changes []roleChange) {
+	for idx := range changes {
+		change := &changes[idx]
+		if change.action != "created" && change.action != "updated" {
+			continue
+		}
+		privileges := mergePrivileges(definitions[idx].Privileges)
+		if privileges == nil {
+			privileges = []RoleData{}
+		}
+		body, _ := json.Marshal(privileges)
+		if err := sendRoleRequest(client, http.MethodPut, "role/"+change.name, body); err != nil {
+			change.action = "failed"
+			change.err = err
+			continue
+		}
+		// the definition now decides the privileges, not an inheritance
+		forgetRoleInheritance(DefaultProfile.URL, change.name)
+	}
+}
+
+// printRoleChanges reports the outcome for every role and a summary line
+func printRoleChanges(w io.Writer, changes []roleChange, dryRun bool) {
+	marks := map[string]string{
+		"created":   common.Green + "+" + common.Reset,
+		"updated":   common.Yellow + "~" + common.Reset,
+		"unchanged": "=",
+		"failed":    common.Red + "✗" + common.Reset,
+	}
+	for _, change := range changes {
+		action := change.action
+		if dryRun && (action == "created" || action == "updated") {
+			action = "would be " + action
+		}
+		if change.err != nil {
+			action += ": " + change.err.Error()
+		}
+		fmt.Fprintf(w, "  %s %s %s\n", marks[change.action], change.name, action)
+		for _, privilege := range change.added {
+			fmt.Fprintf(w, "      + %s\n", formatPrivilege(privilege))
+		}
+		for _, privilege := range change.removed {
+			fmt.Fprintf(w, "      - %s\n", formatPrivilege(privilege))
+		}
+	}
+
+	summary := fmt.Sprintf("%d created, %d updated, %d unchanged, %d failed",
+		countRoleChanges(changes, "created"), countRoleChanges(changes, "updated"),
+		countRoleChanges(changes, "unchanged"), countRoleChanges(changes, "failed"))
+	if dryRun {
+		summary += " (dry run, nothing was changed)"
+	}
+	fmt.Fprintf(w, "\n%s\n", summary)
+}
+
+func countRoleChanges(changes []roleChange, action string) int {
+	count := 0
+	for _, change := range changes {
+		if change.action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// formatPrivilege describes a privilege, e.g. reader on stream app, tag prod
+func formatPrivilege(privilege RoleData) string {
+	text := privilege.Privilege
+	if privilege.Resource != nil && privilege.Resource.Stream != "" {
+		text += " on stream " + privilege.Resource.Stream
+		if privilege.Resource.Tag != "" {
+			text += ", tag " + privilege.Resource.Tag
+		}
+	}
+	return text
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func writeRoleFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRoleApplyPlansChanges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	state := &roleServer{
+		roles: map[string]json.RawMessage{
+			"ops":     json.RawMessage(`[{"privilege":"reader","resource":{"stream":"app"}},{"privilege":"editor"}]`),
+			"viewers": json.RawMessage(`[{"privilege":"reader","resource":{"stream":"app"}}]`),
+		},
+		userRoles: map[string][]string{},
+	}
+	server := httptest.NewServer(state)
+	defer server.Close()
+
+	dir := writeRoleFiles(t, map[string]string{
+		// same privileges in a different order
+		"ops.yaml":     "privileges:\n  - privilege: editor\n  - privilege: reader\n    resource:\n      stream: app\n",
+		"viewers.json": `{"privileges":[{"privilege":"reader","resource":{"stream":"app","tag":"prod"}}]}`,
+		"ingest.yml":   "privileges:\n  - privilege: ingestor\n    resource:\n      stream: app\n",
+		"notes.txt":    "ignored",
+	})
+	definitions, err := readRoleDefinitions(dir)
+	if err != nil {
+		t.Fatalf("failed to read definitions: %v", err)
+	}
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	changes, err := planRoleApply(&client, definitions)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+
+	actions := map[string]string{}
+	for _, change := range changes {
+		actions[change.name] = change.action
+	}
+	expected := map[string]string{"ingest": "created", "ops": "unchanged", "viewers": "updated"}
+	for name, action := range expected {
+		if actions[name] != action {
+			t.Errorf("expected %s to be %s, got %q", name, action, actions[name])
+		}
+	}
+
+	var out strings.Builder
+	printRoleChanges(&out, changes, true)
+	if !strings.Contains(out.String(), "+ reader on stream app, tag prod") || !strings.Contains(out.String(), "- reader on stream app") {
+		t.Errorf("expected a privilege diff for viewers, got:\n%s", out.String())
+	}
+	if len(state.roles) != 2 {
+		t.Error("expected a dry run to change nothing")
+	}
+
+	applyRoleChanges(&client, definitions, changes)
+	if _, ok := state.roles["ingest"]; !ok {
+		t.Error("expected the missing role to be created")
+	}
+
+	// applying again finds nothing to do
+	changes, err = planRoleApply(&client, definitions)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	for _, change := range changes {
+		if change.action != "unchanged" {
+			t.Errorf("expected %s to be unchanged after apply, got %s", change.name, change.action)
+		}
+	}
+}
+
+func TestRoleDefinitionValidation(t *testing.T) {
+	cases := map[string]string{
+		"writer without stream": "privileges:\n  - privilege: writer\n",
+		"editor with resource":  "privileges:\n  - privilege: editor\n    resource:\n      stream: app\n",
+		"unknown privilege":     "privileges:\n  - privilege: owner\n",
+	}
+	for name, content := range cases {
+		dir := writeRoleFiles(t, map[string]string{"role.yaml": content})
+		if _, err := readRoleDefinitions(dir); err == nil {
+			t.Errorf("%s: expected the definition to be rejected", name)
+		}
+	}
+
+	dir := writeRoleFiles(t, map[string]string{
+		"a.yaml": "name: ops\nprivileges: []\n",
+		"b.json": `{"name":"ops","privileges":[]}`,
+	})
+	if _, err := readRoleDefinitions(dir); err == nil || !strings.Contains(err.Error(), "defined in both") {
+		t.Errorf("expected duplicate role names to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"golang.org/x/exp/slices"
+)
+
+var roleInheritFlag = "inherit"
+
+// parseInheritedRoles cleans up the --inherit values, dropping repeated names
+func parseInheritedRoles(values []string) ([]string, error) {
+	var parents []string
+	for _, value := range values {
+		name := strings.TrimSpace(value)
+		if name == "" {
+			return nil, fmt.Errorf("--%s has an empty role name", roleInheritFlag)
+		}
+		if !slices.Contains(parents, name) {
+			parents = append(parents, name)
+		}
+	}
+	return parents, nil
+}
+
+// findInheritanceCycle returns the chain of roles that leads back to role
+// when it inherits from parents, given the recorded inheritance graph, or nil
+// when there is no cycle
+func findInheritanceCycle(graph map[string][]string, role string, parents []string) []string {
+	visited := map[string]bool{}
+	var walk func(name string, chain []string) []string
+	walk = func(name string, chain []string) []string {
+		chain = append(chain, name)
+		if name == role {
+			return chain
+		}
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		for _, parent := range graph[name] {
+			if cycle := walk(parent, chain); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+
+	for _, parent := range parents {
+		if cycle := walk(parent, []string{role}); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// mergePrivileges combines privilege sets in order, keeping one copy of
+// privileges that appear in several sets
+func mergePrivileges(sets ...[]RoleData) []RoleData {
+	seen := map[string]bool{}
+	var merged []RoleData
+	for _, set := range sets {
+		for _, privilege := range set {
+			key := privilegeKey(privilege)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, privilege)
+		}
+	}
+	return merged
+}
+
+// privilegeKey identifies a privilege by its name, stream and tag
+func privilegeKey(privilege RoleData) string {
+	key := privilege.Privilege
+	if privilege.Resource != nil {
+		key += "\x00" + privilege.Resource.Stream + "\x00" + privilege.Resource.Tag
+	}
+	return key
+}
+
+// inheritPrivileges checks that role can inherit from parents and returns
+// their merged privileges. existing lists the roles on the server
+func inheritPrivileges(client *internalHTTP.HTTPClient, existing []string, role string, parents []string) ([]RoleData, error) {
+	for _, parent := range parents {
+		if !slices.Contains(existing, parent) {
+			return nil, fmt.Errorf("cannot inherit from role %s, it does not exist", parent)
+		}
+	}
+
+	record, err := config.ReadRoleInheritance()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role inheritance: %w", err)
+	}
+	if cycle := findInheritanceCycle(record.Roles(client.Profile.URL), role, parents); cycle != nil {
+		return nil, fmt.Errorf("inheritance cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	sets := make([][]RoleData, len(parents))
+	for idx, parent := range parents {
+		sets[idx], err = fetchSpecificRole(client, parent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch role %s: %w", parent, err)
+		}
+	}
+	return mergePrivileges(sets...), nil
+}
+
+// recordRoleInheritance remembers the roles a new role inherited from.
+// Failures are only reported, as the role itself is already created
+func recordRoleInheritance(url, role string, parents []string) {
+	updateRoleInheritance(func(file *config.RoleInheritanceFile) bool {
+		file.Set(url, role, parents)
+		return true
+	})
+}
+
+// forgetRoleInheritance drops the record of a deleted role
+func forgetRoleInheritance(url, role string) {
+	updateRoleInheritance(func(file *config.RoleInheritanceFile) bool {
+		if _, ok := file.Roles(url)[role]; !ok {
+			return false
+		}
+		file.Delete(url, role)
+		return true
+	})
+}
+
+// renameRoleInheritance moves the record of a renamed role and points the
+// roles that inherited from it to the new name
+func renameRoleInheritance(url, from, to string) {
+	updateRoleInheritance(func(file *config.RoleInheritanceFile) bool {
+		roles := file.Roles(url)
+		changed := false
+		for _, parents := range roles {
+			if idx := slices.Index(parents, from); idx >= 0 {
+				parents[idx] = to
+				changed = true
+			}
+		}
+		if parents, ok := roles[from]; ok {
+			file.Delete(url, from)
+			file.Set(url, to, parents)
+			changed = true
+		}
+		return changed
+	})
+}
+
+func updateRoleInheritance(update func(file *config.RoleInheritanceFile) bool) {
+	file, err := config.ReadRoleInheritance()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read role inheritance: %s\n", err)
+		return
+	}
+	if !update(file) {
+		return
+	}
+	if err := config.WriteRoleInheritance(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update role inheritance: %s\n", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestMergePrivilegesDropsOverlap(t *testing.T) {
+	base := []RoleData{
+		{Privilege: "reader", Resource: &RoleResource{Stream: "app"}},
+		{Privilege: "reader", Resource: &RoleResource{Stream: "web", Tag: "prod"}},
+	}
+	extras := []RoleData{
+		{Privilege: "reader", Resource: &RoleResource{Stream: "app"}},
+		{Privilege: "reader", Resource: &RoleResource{Stream: "web"}},
+		{Privilege: "ingestor", Resource: &RoleResource{Stream: "app"}},
+	}
+
+	merged := mergePrivileges(base, extras)
+	var got []string
+	for _, privilege := range merged {
+		got = append(got, privilege.Privilege+":"+privilege.Resource.Stream+":"+privilege.Resource.Tag)
+	}
+	want := []string{"reader:app:", "reader:web:prod", "reader:web:", "ingestor:app:"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFindInheritanceCycle(t *testing.T) {
+	graph := map[string][]string{
+		"oncall": {"base", "ingest"},
+		"ingest": {"writers"},
+	}
+
+	if cycle := findInheritanceCycle(graph, "audit", []string{"oncall"}); cycle != nil {
+		t.Errorf("expected no cycle for a new role, got %v", cycle)
+	}
+
+	// writers was deleted and is being recreated from a role that inherits it
+	cycle := findInheritanceCycle(graph, "writers", []string{"base", "oncall"})
+	if strings.Join(cycle, " -> ") != "writers -> oncall -> ingest -> writers" {
+		t.Errorf("expected the cycle through oncall, got %v", cycle)
+	}
+
+	if cycle := findInheritanceCycle(graph, "self", []string{"self"}); cycle == nil {
+		t.Error("expected a role inheriting from itself to be a cycle")
+	}
+}
+
+func TestInheritPrivilegesRejectsCycle(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	state := &roleServer{
+		roles: map[string]json.RawMessage{
+			"base":   json.RawMessage(`[{"privilege":"reader","resource":{"stream":"app"}}]`),
+			"oncall": json.RawMessage(`[{"privilege":"reader","resource":{"stream":"app"}},{"privilege":"writer","resource":{"stream":"app"}}]`),
+		},
+		userRoles: map[string][]string{},
+	}
+	server := httptest.NewServer(state)
+	defer server.Close()
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	existing := []string{"base", "oncall"}
+
+	privileges, err := inheritPrivileges(&client, existing, "ops", []string{"base", "oncall"})
+	if err != nil {
+		t.Fatalf("inherit failed: %v", err)
+	}
+	if len(privileges) != 2 {
+		t.Errorf("expected the shared reader privilege once, got %+v", privileges)
+	}
+
+	recordRoleInheritance(server.URL, "oncall", []string{"writers"})
+	if _, err := inheritPrivileges(&client, existing, "writers", []string{"oncall"}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected the cycle to be rejected, got %v", err)
+	}
+
+	if _, err := inheritPrivileges(&client, existing, "ops", []string{"missing"}); err == nil {
+		t.Error("expected inheriting from a missing role to be rejected")
+	}
+}
+
+func TestRenameRoleInheritance(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	url := "http://localhost:8000"
+	recordRoleInheritance(url, "oncall", []string{"base", "ops"})
+	recordRoleInheritance(url, "ops", []string{"base"})
+	renameRoleInheritance(url, "ops", "operators")
+
+	file, err := config.ReadRoleInheritance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"oncall":    {"base", "operators"},
+		"operators": {"base"},
+	}
+	if got := file.Roles(url); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
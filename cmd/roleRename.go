@@ -0,0 +1,232 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"pb/pkg/common"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+)
+
+var renameRoleForceFlag = "force"
+
+// roleRenamePlan describes the changes needed to rename a role on a server
+// without a rename API
+type roleRenamePlan struct {
+	From, To   string
+	Privileges []RoleData
+	// Users maps each user holding the role to the full set of roles it
+	// will have after the rename
+	Users map[string][]string
+}
+
+// userNames returns the affected users in a stable order
+func (plan *roleRenamePlan) userNames() []string {
+	names := make([]string, 0, len(plan.Users))
+	for name := range plan.Users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RenameRoleCmd renames a role by creating a copy under the new name, moving
+// every user to it and deleting the old role, as Parseable has no rename API
+var RenameRoleCmd = &cobra.Command{
+	Use:     "rename old-name new-name",
+	Example: "  pb role rename ops operators --force",
+	Short:   "Rename a role",
+	Long: `Rename a role and keep its users.
+
+Parseable has no API to rename roles, so pb creates a role with the new name
+and the same privileges, moves every user from the old role to the new one,
+and then deletes the old role. As this changes user role assignments, it
+only runs with --force, and asks you to type the old role name unless --yes
+is set. Without a terminal on stdin, --yes is required.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		from, to := args[0], args[1]
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+
+		plan, err := planRoleRename(&client, from, to)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		printRoleRenamePlan(plan)
+
+		if force, _ := cmd.Flags().GetBool(renameRoleForceFlag); !force {
+			err := fmt.Errorf("renaming a role changes the roles of %d user(s), rerun with --%s to apply", len(plan.Users), renameRoleForceFlag)
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		confirmed, err := confirmDestructive(cmd, fmt.Sprintf("Renaming role %s to %s", from, to), from)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted, nothing was changed")
+			return nil
+		}
+
+		fmt.Println()
+		if err := applyRoleRename(&client, plan, os.Stdout); err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		renameRoleInheritance(DefaultProfile.URL, plan.From, plan.To)
+		return nil
+	},
+}
+
+func init() {
+	RenameRoleCmd.Flags().Bool(renameRoleForceFlag, false, "Apply the rename, which recreates the role and reassigns its users")
+}
+
+// planRoleRename checks that the rename is possible and collects the
+// privileges of the role and the users holding it
+func planRoleRename(client *internalHTTP.HTTPClient, from, to string) (*roleRenamePlan, error) {
+	if from == to {
+		return nil, fmt.Errorf("role %s already has that name", from)
+	}
+
+	var roles []string
+	if err := fetchRoles(client, &roles); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(roles, from) {
+		return nil, fmt.Errorf("role %s does not exist", from)
+	}
+	if slices.Contains(roles, to) {
+		return nil, fmt.Errorf("role %s already exists, please use a different name", to)
+	}
+
+	privileges, err := fetchSpecificRole(client, from)
+	if err != nil {
+		return nil, err
+	}
+
+	// a user whose roles are unknown could silently lose access, so any
+	// failure to fetch them stops the rename
+	assignments, err := fetchAllUserRoles(client)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &roleRenamePlan{From: from, To: to, Privileges: privileges, Users: map[string][]string{}}
+	for name, userRoles := range assignments {
+		if _, ok := userRoles[from]; !ok {
+			continue
+		}
+
+		renamed := make([]string, 0, len(userRoles))
+		for role := range userRoles {
+			if role == from {
+				role = to
+			}
+			renamed = append(renamed, role)
+		}
+		sort.Strings(renamed)
+		plan.Users[name] = renamed
+	}
+	return plan, nil
+}
+
+func printRoleRenamePlan(plan *roleRenamePlan) {
+	fmt.Printf("Renaming role %s to %s will:\n", StyleBold.Render(plan.From), StyleBold.Render(plan.To))
+	fmt.Printf("  • create role %s with %d privilege(s) copied from %s\n", plan.To, len(plan.Privileges), plan.From)
+	for _, name := range plan.userNames() {
+		fmt.Printf("  • set the roles of user %s to %s\n", name, strings.Join(plan.Users[name], ","))
+	}
+	fmt.Printf("  • delete role %s\n", plan.From)
+}
+
+// applyRoleRename carries out the plan, reporting each change to out. The
+// old role is only deleted once every user has been moved, so a failure
+// part way leaves both roles in place and no user without access
+func applyRoleRename(client *internalHTTP.HTTPClient, plan *roleRenamePlan, out io.Writer) error {
+	privileges, err := json.Marshal(plan.Privileges)
+	if err != nil {
+		return err
+	}
+	if err := sendRoleRequest(client, http.MethodPut, "role/"+plan.To, privileges); err != nil {
+		return fmt.Errorf("failed to create role %s: %w", plan.To, err)
+	}
+	fmt.Fprintf(out, "  %s created role %s\n", common.Green+"✓"+common.Reset, plan.To)
+
+	failed := 0
+	for _, name := range plan.userNames() {
+		roles, _ := json.Marshal(plan.Users[name])
+		if err := sendRoleRequest(client, http.MethodPut, "user/"+name+"/role", roles); err != nil {
+			failed++
+			fmt.Fprintf(out, "  %s user %s: %v\n", common.Red+"✗"+common.Reset, name, err)
+			continue
+		}
+		fmt.Fprintf(out, "  %s moved user %s from %s to %s\n", common.Green+"✓"+common.Reset, name, plan.From, plan.To)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to move %d user(s), role %s was kept. Fix the errors and move them with pb user set-role before removing it", failed, plan.From)
+	}
+
+	if err := sendRoleRequest(client, http.MethodDelete, "role/"+plan.From, nil); err != nil {
+		return fmt.Errorf("all users were moved but deleting role %s failed: %w", plan.From, err)
+	}
+	fmt.Fprintf(out, "  %s deleted role %s\n", common.Green+"✓"+common.Reset, plan.From)
+	return nil
+}
+
+func sendRoleRequest(client *internalHTTP.HTTPClient, method, path string, body []byte) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := client.NewRequest(method, path, reader)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
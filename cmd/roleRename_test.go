@@ -0,0 +1,175 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+// roleServer mocks the role and user endpoints over an in-memory state
+type roleServer struct {
+	mu        sync.Mutex
+	roles     map[string]json.RawMessage
+	userRoles map[string][]string
+}
+
+func (s *roleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case path == "role" && r.Method == http.MethodGet:
+		names := []string{}
+		for name := range s.roles {
+			names = append(names, name)
+		}
+		json.NewEncoder(w).Encode(names)
+	case strings.HasPrefix(path, "role/"):
+		name := strings.TrimPrefix(path, "role/")
+		switch r.Method {
+		case http.MethodGet:
+			w.Write(s.roles[name])
+		case http.MethodPut:
+			s.roles[name] = body
+		case http.MethodDelete:
+			delete(s.roles, name)
+		}
+	case path == "user":
+		users := []UserData{}
+		for name := range s.userRoles {
+			users = append(users, UserData{ID: name})
+		}
+		json.NewEncoder(w).Encode(users)
+	case strings.HasPrefix(path, "user/") && strings.HasSuffix(path, "/role"):
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "user/"), "/role")
+		if r.Method == http.MethodPut {
+			var roles []string
+			json.Unmarshal(body, &roles)
+			s.userRoles[name] = roles
+			return
+		}
+		data := UserRoleData{}
+		for _, role := range s.userRoles[name] {
+			var privileges []RoleData
+			json.Unmarshal(s.roles[role], &privileges)
+			data[role] = privileges
+		}
+		json.NewEncoder(w).Encode(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRenameRoleMovesUsers(t *testing.T) {
+	state := &roleServer{
+		roles: map[string]json.RawMessage{
+			"ops":    json.RawMessage(`[{"privilege":"editor"}]`),
+			"reader": json.RawMessage(`[{"privilege":"reader","resource":{"stream":"app"}}]`),
+		},
+		userRoles: map[string][]string{
+			"alice": {"ops", "reader"},
+			"bob":   {"reader"},
+		},
+	}
+	server := httptest.NewServer(state)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	plan, err := planRoleRename(&client, "ops", "operators")
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if len(plan.Users) != 1 || strings.Join(plan.Users["alice"], ",") != "operators,reader" {
+		t.Fatalf("expected only alice to be moved, got %v", plan.Users)
+	}
+
+	if err := applyRoleRename(&client, plan, io.Discard); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+
+	if _, ok := state.roles["ops"]; ok {
+		t.Error("expected the old role to be deleted")
+	}
+	if string(state.roles["operators"]) != `[{"privilege":"editor"}]` {
+		t.Errorf("expected privileges to be copied, got %s", state.roles["operators"])
+	}
+	if strings.Join(state.userRoles["bob"], ",") != "reader" {
+		t.Errorf("expected bob to be untouched, got %v", state.userRoles["bob"])
+	}
+}
+
+func TestRenameRoleNeedsConfirmation(t *testing.T) {
+	state := &roleServer{
+		roles:     map[string]json.RawMessage{"ops": json.RawMessage(`[{"privilege":"editor"}]`)},
+		userRoles: map[string][]string{"alice": {"ops"}},
+	}
+	server := httptest.NewServer(state)
+	defer server.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	defer func(profile config.Profile) { DefaultProfile = profile }(DefaultProfile)
+	DefaultProfile = config.Profile{URL: server.URL}
+
+	RenameRoleCmd.SilenceUsage = true
+	RenameRoleCmd.SetArgs([]string{"ops", "operators", "--force"})
+	defer func() {
+		RenameRoleCmd.SetArgs(nil)
+		RenameRoleCmd.Flags().Set(renameRoleForceFlag, "false")
+	}()
+
+	withTerminal(t, false)
+	if err := RenameRoleCmd.Execute(); err == nil || !strings.Contains(err.Error(), "stdin is not a terminal") {
+		t.Errorf("expected rename to fail without a terminal or --yes, got %v", err)
+	}
+	if _, ok := state.roles["operators"]; ok || strings.Join(state.userRoles["alice"], ",") != "ops" {
+		t.Fatal("expected nothing to change without confirmation")
+	}
+
+	withAssumeYes(t)
+	if err := RenameRoleCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(state.userRoles["alice"], ",") != "operators" {
+		t.Errorf("expected alice to be moved with --yes, got %v", state.userRoles["alice"])
+	}
+}
+
+func TestRenameRoleRejectsExistingTarget(t *testing.T) {
+	state := &roleServer{
+		roles:     map[string]json.RawMessage{"ops": json.RawMessage(`[]`), "admin": json.RawMessage(`[]`)},
+		userRoles: map[string][]string{},
+	}
+	server := httptest.NewServer(state)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	if _, err := planRoleRename(&client, "ops", "admin"); err == nil {
+		t.Error("expected renaming onto an existing role to be rejected")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"sync"
+
+	internalHTTP "pb/pkg/http"
+)
+
+var (
+	unusedRolesFlag    = "unused"
+	roleUserCountsFlag = "user-counts"
+
+	// userRolesConcurrency bounds the number of in-flight user role requests
+	userRolesConcurrency = 8
+)
+
+// roleListEntry is one role in the JSON output of pb role list with
+// --user-counts or --unused
+type roleListEntry struct {
+	Privileges []RoleData `json:"privileges"`
+	Users      int        `json:"users"`
+}
+
+// fetchAllUserRoles returns the roles of every user, keyed by user name. It
+// fails if the roles of any user cannot be fetched, as callers use the result
+// to decide which roles are unused
+func fetchAllUserRoles(client *internalHTTP.HTTPClient) (map[string]UserRoleData, error) {
+	users, err := fetchUsers(client)
+	if err != nil {
+		return nil, err
+	}
+
+	userRoles := make([]UserRoleData, len(users))
+	errs := make([]error, len(users))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, userRolesConcurrency)
+	for idx, user := range users {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			userRoles[idx], errs[idx] = fetchUserRoles(client, name)
+		}(idx, user.ID)
+	}
+	wg.Wait()
+
+	assignments := make(map[string]UserRoleData, len(users))
+	for idx, user := range users {
+		if errs[idx] != nil {
+			return nil, fmt.Errorf("failed to fetch roles of user %s: %w", user.ID, errs[idx])
+		}
+		assignments[user.ID] = userRoles[idx]
+	}
+	return assignments, nil
+}
+
+// countRoleUsers returns the number of users holding each of the roles
+func countRoleUsers(roles []string, assignments map[string]UserRoleData) map[string]int {
+	counts := make(map[string]int, len(roles))
+	for _, role := range roles {
+		counts[role] = 0
+	}
+	for _, userRoles := range assignments {
+		for role := range userRoles {
+			if _, ok := counts[role]; ok {
+				counts[role]++
+			}
+		}
+	}
+	return counts
+}
+
+// unusedRoles returns the roles no user holds, keeping their order
+func unusedRoles(roles []string, counts map[string]int) []string {
+	unused := []string{}
+	for _, role := range roles {
+		if counts[role] == 0 {
+			unused = append(unused, role)
+		}
+	}
+	return unused
+}
+
+func formatUserCount(count int) string {
+	if count == 1 {
+		return "1 user"
+	}
+	return fmt.Sprintf("%d users", count)
+}
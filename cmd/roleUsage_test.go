@@ -0,0 +1,103 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestCountRoleUsersFindsUnusedRoles(t *testing.T) {
+	state := &roleServer{
+		roles: map[string]json.RawMessage{
+			"admin":    json.RawMessage(`[{"privilege":"admin"}]`),
+			"reader":   json.RawMessage(`[{"privilege":"reader","resource":{"stream":"app"}}]`),
+			"legacy":   json.RawMessage(`[{"privilege":"editor"}]`),
+			"ingestor": json.RawMessage(`[{"privilege":"ingestor","resource":{"stream":"app"}}]`),
+		},
+		userRoles: map[string][]string{
+			"alice": {"admin", "reader"},
+			"bob":   {"reader"},
+			"carol": {},
+		},
+	}
+	server := httptest.NewServer(state)
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	assignments, err := fetchAllUserRoles(&client)
+	if err != nil {
+		t.Fatalf("failed to fetch assignments: %v", err)
+	}
+
+	roles := []string{"admin", "ingestor", "legacy", "reader"}
+	counts := countRoleUsers(roles, assignments)
+	expected := map[string]int{"admin": 1, "ingestor": 0, "legacy": 0, "reader": 2}
+	for role, count := range expected {
+		if counts[role] != count {
+			t.Errorf("expected %d user(s) for %s, got %d", count, role, counts[role])
+		}
+	}
+
+	if unused := strings.Join(unusedRoles(roles, counts), ","); unused != "ingestor,legacy" {
+		t.Errorf("expected ingestor and legacy to be unused, got %s", unused)
+	}
+}
+
+func TestRoleListFetchesUsersOnlyWhenAsked(t *testing.T) {
+	state := &roleServer{
+		roles:     map[string]json.RawMessage{"admin": json.RawMessage(`[{"privilege":"admin"}]`)},
+		userRoles: map[string][]string{"alice": {"admin"}, "bob": {}},
+	}
+	var userRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/v1/user") {
+			userRequests.Add(1)
+		}
+		state.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	defer func(profile config.Profile) { DefaultProfile = profile }(DefaultProfile)
+	DefaultProfile = config.Profile{URL: server.URL}
+	defer func() {
+		ListRoleCmd.Flags().Set(roleUserCountsFlag, "false")
+		ListRoleCmd.SetArgs(nil)
+	}()
+
+	ListRoleCmd.SetArgs([]string{})
+	if err := ListRoleCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := userRequests.Load(); got != 0 {
+		t.Errorf("expected no user requests without --%s, got %d", roleUserCountsFlag, got)
+	}
+
+	ListRoleCmd.SetArgs([]string{"--" + roleUserCountsFlag})
+	if err := ListRoleCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := userRequests.Load(); got != 3 {
+		t.Errorf("expected the user list and the roles of both users with --%s, got %d requests", roleUserCountsFlag, got)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// shellSession is set while pb shell runs so that the profile resolved when
+// the shell started is kept for every command
+var shellSession bool
+
+const shellHelp = `Enter a SQL query to run it, or any pb command without the leading "pb",
+e.g. "stream list" or "query run 'select 1' -o json".
+
+  \from <time>   start time for SQL queries, e.g. 1h (default 1m)
+  \to <time>     end time for SQL queries (default now)
+  \refresh       reload stream names for tab completion
+  \help          show this help
+  \q             exit, Ctrl-D also exits`
+
+var ShellCmd = &cobra.Command{
+	Use:     "shell",
+	Short:   "Start an interactive session for queries and commands",
+	Example: "  pb shell",
+	Long: `Start an interactive session with the default profile. Enter SQL queries
+or pb commands one per line. Line editing, history and tab completion of
+command and stream names are available. The profile is resolved once when the
+session starts.`,
+	Args:    cobra.NoArgs,
+	PreRunE: PreRunDefaultProfile,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		root := cmd.Root()
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+		completer := &shellCompleter{commands: shellCommandNames(root, cmd)}
+		completer.refresh(&client)
+
+		rl, err := readline.NewEx(&readline.Config{
+			Prompt:          fmt.Sprintf("pb (%s)> ", DefaultProfileName),
+			AutoComplete:    completer,
+			InterruptPrompt: "^C",
+			EOFPrompt:       `\q`,
+		})
+		if err != nil {
+			return err
+		}
+		defer rl.Close()
+
+		shellSession = true
+		defer func() { shellSession = false }()
+
+		persistent := persistentFlagValues(root)
+		from, to := defaultStart, defaultEnd
+
+		fmt.Println(`Connected to ` + DefaultProfile.URL + `. Type \help for help, \q to exit.`)
+		for {
+			line, err := rl.Readline()
+			if errors.Is(err, readline.ErrInterrupt) {
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+
+			if strings.HasPrefix(line, `\`) {
+				fields := strings.Fields(line)
+				switch fields[0] {
+				case `\q`, `\quit`:
+					return nil
+				case `\help`, `\h`, `\?`:
+					fmt.Println(shellHelp)
+				case `\refresh`:
+					completer.refresh(&client)
+				case `\from`, `\to`:
+					if len(fields) != 2 {
+						fmt.Printf("usage: %s <time>\n", fields[0])
+					} else if fields[0] == `\from` {
+						from = fields[1]
+					} else {
+						to = fields[1]
+					}
+				default:
+					fmt.Printf("unknown command %s, type \\help for help\n", fields[0])
+				}
+				continue
+			}
+
+			args, err := splitShellArgs(line)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if target, _, err := root.Find(args); err != nil || target == root || target == cmd {
+				// not a pb command, run the line as a SQL query
+				args = []string{"query", "run", line, "--" + startFlag, from, "--" + endFlag, to}
+			}
+
+			root.SetArgs(args)
+			root.Execute()
+			internalHTTP.ReportSlowRequests(os.Stderr)
+			resetShellFlags(root, persistent)
+		}
+	},
+}
+
+// shellCompleter completes command names at the start of a line and stream
+// names anywhere else
+type shellCompleter struct {
+	commands []string
+	streams  []string
+}
+
+func (c *shellCompleter) refresh(client *internalHTTP.HTTPClient) {
+	streams, err := fetchStreams(client)
+	if err != nil {
+		fmt.Printf("failed to load stream names for completion: %s\n", err)
+		return
+	}
+	c.streams = c.streams[:0]
+	for _, stream := range streams {
+		c.streams = append(c.streams, stream.Name)
+	}
+	sort.Strings(c.streams)
+}
+
+func (c *shellCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	before := string(line[:pos])
+	start := strings.LastIndexAny(before, " \t\"'(,") + 1
+	word := before[start:]
+
+	candidates := c.streams
+	if strings.TrimSpace(before[:start]) == "" {
+		candidates = c.commands
+	}
+
+	var matches [][]rune
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, []rune(candidate[len(word):]+" "))
+		}
+	}
+	return matches, len([]rune(word))
+}
+
+// shellCommandNames lists the top level commands reachable from the shell
+func shellCommandNames(root, shell *cobra.Command) []string {
+	names := []string{"select"}
+	for _, c := range root.Commands() {
+		if c != shell && c.IsAvailableCommand() {
+			names = append(names, c.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitShellArgs splits a line into arguments like a POSIX shell would for
+// plain words, single and double quotes and backslash escapes
+func splitShellArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// persistentFlagValues records the root persistent flags the shell was
+// started with, e.g. --trace, so they apply to every command in the session
+func persistentFlagValues(root *cobra.Command) map[string]string {
+	values := make(map[string]string)
+	root.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// resetShellFlags puts every flag back to its default after a command so
+// flags do not leak into the next line, except root persistent flags which
+// return to the values the shell was started with
+func resetShellFlags(root *cobra.Command, persistent map[string]string) {
+	var reset func(c *cobra.Command)
+	reset = func(c *cobra.Command) {
+		visit := func(f *pflag.Flag) {
+			if _, ok := persistent[f.Name]; ok && root.PersistentFlags().Lookup(f.Name) == f {
+				return
+			}
+			setFlag(f, f.DefValue)
+		}
+		c.Flags().VisitAll(visit)
+		c.PersistentFlags().VisitAll(visit)
+		for _, child := range c.Commands() {
+			reset(child)
+		}
+	}
+	reset(root)
+
+	root.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		setFlag(f, persistent[f.Name])
+	})
+}
+
+func setFlag(f *pflag.Flag, value string) {
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		var values []string
+		if value != "" {
+			values = strings.Split(value, ",")
+		}
+		slice.Replace(values)
+	} else {
+		f.Value.Set(value)
+	}
+	f.Changed = false
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitShellArgs(t *testing.T) {
+	got, err := splitShellArgs(`query run "select * from backend where msg = 'a b'" -o json --from=1h it\'s`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"query", "run", "select * from backend where msg = 'a b'", "-o", "json", "--from=1h", "it's"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if _, err := splitShellArgs(`query run "select`); err == nil {
+		t.Error("expected unterminated quote to be rejected")
+	}
+}
@@ -16,14 +16,20 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"pb/pkg/common"
+	"pb/pkg/config"
 	internalHTTP "pb/pkg/http"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -45,6 +51,15 @@ type StreamStatsData struct {
 	Time   time.Time `json:"time"`
 }
 
+// StreamInfo is the data structure for stream metadata
+type StreamInfo struct {
+	CreatedAt       string `json:"created-at"`
+	FirstEventAt    string `json:"first-event-at"`
+	TimePartition   string `json:"time_partition"`
+	CustomPartition string `json:"custom_partition"`
+	StreamType      string `json:"stream_type"`
+}
+
 type StreamListItem struct {
 	Name string
 }
@@ -55,10 +70,16 @@ func (item *StreamListItem) Render() string {
 }
 
 // StreamRetentionData is the data structure for stream retention
-type StreamRetentionData []struct {
+type StreamRetentionData []RetentionTask
+
+// RetentionTask is one retention rule of a stream. A rule applies its action
+// once data is older than Duration, or once the stream is larger than Size
+// bytes
+type RetentionTask struct {
 	Description string `json:"description"`
 	Action      string `json:"action"`
-	Duration    string `json:"duration"`
+	Duration    string `json:"duration,omitempty"`
+	Size        uint64 `json:"size,omitempty"`
 }
 
 // AlertConfig structure
@@ -108,9 +129,28 @@ type RuleConfig struct {
 // AddStreamCmd is the parent command for stream
 var AddStreamCmd = &cobra.Command{
 	Use:     "add stream-name",
-	Example: "  pb stream add backend_logs",
+	Example: "  pb stream add backend_logs\n  pb stream add backend_logs --description \"API gateway logs\" --tag env=prod --tag team=platform\n  pb stream add backend_logs --hot-tier-size=20GiB\n  pb stream add backend_logs --retention=30d --retention-size=100GB\n  pb stream add backend_logs --tag env=prod --if-not-exists --reconcile\n  pb schema generate --file data.json | pb stream add backend_logs --schema-file - --yes",
 	Short:   "Create a new stream",
-	Args:    cobra.ExactArgs(1),
+	Long: `
+Create a new stream. --description and --tag are stored by pb in a local file next to the config file, not on the server, so they are only visible on this machine.
+
+--hot-tier-size keeps up to that much of the stream's most recent data on the
+query node's local disk. Hot tiers are only available on distributed
+deployments with hot tier storage configured, pb checks the server mode and
+refuses the flag on standalone servers.
+
+--if-not-exists makes the command safe to re-run: when the stream already
+exists nothing is created, and pb reports whether the existing stream has
+the description, tags and hot tier size given. Only the settings passed are
+compared. Add --reconcile to update the ones that differ.
+
+--schema-file creates the stream with a static schema, such as the output of
+pb schema generate. A static schema cannot be changed later, so pb asks for
+confirmation unless --yes is passed. Pass --schema-file - to read the schema
+from stdin; as stdin then holds the schema, --yes is required:
+
+  pb schema generate --file data.json | pb stream add my_stream --schema-file - --yes`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Capture start time
 		startTime := time.Now()
@@ -120,13 +160,109 @@ var AddStreamCmd = &cobra.Command{
 		}()
 
 		name := args[0]
+		if validate, _ := cmd.Flags().GetBool(validateNameFlag); validate {
+			if err := validateStreamName(name); err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+		}
+
+		description, _ := cmd.Flags().GetString(streamDescriptionFlag)
+		tagValues, _ := cmd.Flags().GetStringArray(streamTagFlag)
+		tags, err := parseTags(tagValues)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		meta := config.StreamMetadata{Description: description, Tags: tags}
+
+		// read the schema before anything is sent, stdin is only read when
+		// it cannot be needed for a confirmation prompt
+		var schema []byte
+		schemaPath, _ := cmd.Flags().GetString(schemaFileFlag)
+		yes := assumeYes(cmd)
+		if schemaPath != "" {
+			if schemaPath == "-" && !yes {
+				err := fmt.Errorf("--%s - reads the schema from stdin, so pb cannot ask for confirmation. Pass --%s to create the stream", schemaFileFlag, AssumeYesFlag)
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+			if schema, err = readSchemaFile(schemaPath, cmd.InOrStdin()); err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+		}
+
 		client := internalHTTP.DefaultClient(&DefaultProfile)
-		req, err := client.NewRequest("PUT", "logstream/"+name, nil)
+
+		var hotTierSize uint64
+		if cmd.Flags().Changed(hotTierSizeFlag) {
+			value, _ := cmd.Flags().GetString(hotTierSizeFlag)
+			hotTierSize, err = parseHotTierSize(value)
+			if err == nil {
+				err = checkServerHotTier(&client)
+			}
+			if err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+		}
+
+		retention, retentionSize, err := retentionFromFlags(cmd)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		if ifNotExists, _ := cmd.Flags().GetBool(ifNotExistsFlag); ifNotExists {
+			if retention != "" || retentionSize > 0 {
+				err := fmt.Errorf("--%s and --%s cannot be used with --%s, set the retention of an existing stream with pb stream set-retention", retentionFlag, retentionSizeFlag, ifNotExistsFlag)
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+			exists, err := streamExists(&client, name)
+			if err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+			if exists {
+				want := streamSettings{tags: tags, hotTierSize: hotTierSize}
+				if cmd.Flags().Changed(streamDescriptionFlag) {
+					want.description = &description
+				}
+				reconcile, _ := cmd.Flags().GetBool(reconcileFlag)
+				if err := skipExistingStream(&client, DefaultProfile.URL, name, want, reconcile, os.Stdout); err != nil {
+					cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+					return err
+				}
+				return nil
+			}
+		} else if cmd.Flags().Changed(reconcileFlag) {
+			err := fmt.Errorf("--%s requires --%s", reconcileFlag, ifNotExistsFlag)
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		if schema != nil && !yes {
+			if err := confirmStaticSchema(name, schema); err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+		}
+
+		var body io.Reader
+		if schema != nil {
+			body = bytes.NewReader(schema)
+		}
+		req, err := client.NewRequest("PUT", "logstream/"+name, body)
 		if err != nil {
 			// Capture error
 			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
 			return err
 		}
+		if schema != nil {
+			req.Header.Set("X-P-Static-Schema-Flag", "true")
+		}
 
 		resp, err := client.Client.Do(req)
 		if err != nil {
@@ -140,6 +276,27 @@ var AddStreamCmd = &cobra.Command{
 
 		if resp.StatusCode == 200 {
 			fmt.Printf("Created stream %s\n", StyleBold.Render(name))
+			if !meta.IsEmpty() {
+				if err := saveStreamMetadata(DefaultProfile.URL, name, meta); err != nil {
+					cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+					return fmt.Errorf("stream was created but its description and tags could not be saved: %w", err)
+				}
+			}
+			if hotTierSize > 0 {
+				if err := setHotTier(&client, name, hotTierSize); err != nil {
+					cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+					return fmt.Errorf("stream was created but its hot tier could not be set: %w", err)
+				}
+				fmt.Printf("Set hot tier of %s to %s\n", StyleBold.Render(name), humanize.IBytes(hotTierSize))
+			}
+			if retention != "" || retentionSize > 0 {
+				// a new stream has no retention to restore
+				if err := applyRetention(&client, name, retention, retentionSize, nil); err != nil {
+					cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+					return fmt.Errorf("stream was created but its retention could not be set: %w", err)
+				}
+				fmt.Printf("Set retention of %s to %s\n", StyleBold.Render(name), describeRetention(retention, retentionSize))
+			}
 		} else {
 			bytes, err := io.ReadAll(resp.Body)
 			if err != nil {
@@ -155,12 +312,61 @@ var AddStreamCmd = &cobra.Command{
 	},
 }
 
+func init() {
+	AddStreamCmd.Flags().Bool(validateNameFlag, false, "Check the stream name against the naming rules before creating it, instead of leaving the check to the server")
+	AddStreamCmd.Flags().String(streamDescriptionFlag, "", "Describe what the stream is for, shown by pb stream info")
+	AddStreamCmd.Flags().StringArray(streamTagFlag, nil, "Tag the stream with key=value, can be repeated. Filter on tags with pb stream list --tag")
+	AddStreamCmd.Flags().String(hotTierSizeFlag, "", "Keep this much recent data of the stream in the hot tier, e.g. 20GiB. Distributed servers only")
+	AddStreamCmd.Flags().String(retentionFlag, "", "Delete data older than this many days, e.g. 30d")
+	AddStreamCmd.Flags().String(retentionSizeFlag, "", "Delete the oldest data once the stream is larger than this, e.g. 100GB. With --retention, whichever is reached first applies")
+	AddStreamCmd.Flags().Bool(ifNotExistsFlag, false, "Skip creating the stream when it already exists, and report whether its settings match")
+	AddStreamCmd.Flags().Bool(reconcileFlag, false, "With --if-not-exists, update the description, tags and hot tier of an existing stream to the given values")
+	AddStreamCmd.Flags().String(schemaFileFlag, "", "Create the stream with the static schema in this JSON file, - reads it from stdin")
+}
+
 // StatStreamCmd is the stat command for stream
 var StatStreamCmd = &cobra.Command{
 	Use:     "info stream-name",
-	Example: "  pb stream info backend_logs",
+	Aliases: []string{"stat"},
+	Example: "  pb stream info backend_logs\n  pb stream info backend_logs --by-partition --sort=size --top=10\n  pb stream info --all --total -o csv > capacity.csv\n  pb stream stat --all --max-size=50GB --max-events=100000000\n  pb stream info backend_logs --show-sample=5\n  pb stream info --all --compare-with=stats.json --save-snapshot=stats.json\n  pb stream stat --all --json-lines | vector --config pipeline.toml",
 	Short:   "Get statistics for a stream",
-	Args:    cobra.ExactArgs(1),
+	Long: `Get statistics for a stream, or for every stream with --all.
+
+With -o csv or -o tsv the output has a header row followed by one row per
+stream, with these columns in this order:
+
+  stream             stream name
+  event_count        number of events ingested
+  ingestion_bytes    size of the ingested events in bytes
+  storage_bytes      size of the stored events in bytes
+  compression_ratio  percentage of the ingested size saved in storage
+
+--total adds a final row named TOTAL with the sums across all rows.
+
+--max-size and --max-events turn the command into a capacity check. Every
+stream whose storage size or event count exceeds a threshold is reported on
+stderr, and the command exits with code 2. With --all every stream is checked
+and all breaches are reported. JSON output then has a breached field per stream.
+
+--show-sample N adds the stream schema and its N most recent records, to
+see what the data looks like. JSON output has them in the schema and sample
+fields. No records are fetched unless it is set.
+
+--json-lines prints the statistics of every stream with --all as one JSON
+object per line, each as soon as it arrives, for log pipelines and to see
+progress on servers with many streams. Lines are in the order the
+statistics arrive. Use -o json for a single array instead.
+
+--save-snapshot FILE saves the statistics to a file, and --compare-with FILE
+shows the change in events and sizes since that snapshot, including streams
+created or removed since. Pass both with the same file to compare with the
+last run and then replace the snapshot, e.g. from a weekly job.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if all, _ := cmd.Flags().GetBool(statAllFlag); all {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Capture start time
 		startTime := time.Now()
@@ -169,8 +375,97 @@ var StatStreamCmd = &cobra.Command{
 			cmd.Annotations["executionTime"] = time.Since(startTime).String()
 		}()
 
-		name := args[0]
 		client := internalHTTP.DefaultClient(&DefaultProfile)
+		output, _ := cmd.Flags().GetString("output")
+		total, _ := cmd.Flags().GetBool(statTotalFlag)
+		if err := validateStatOutput(output); err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		thresholds, err := parseStatThresholds(cmd)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		sampleSize, _ := cmd.Flags().GetInt(showSampleFlag)
+		if sampleSize < 0 || (sampleSize > 0 && (output == "csv" || output == "tsv")) {
+			err := fmt.Errorf("--%s takes a positive number of records and only works with text or json output", showSampleFlag)
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		jsonLines, _ := cmd.Flags().GetBool(jsonLinesFlag)
+		if jsonLines {
+			if err := validateJSONLinesOptions(cmd.Flags(), output); err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+		}
+
+		savePath, _ := cmd.Flags().GetString(saveSnapshotFlag)
+		comparePath, _ := cmd.Flags().GetString(compareWithFlag)
+		if savePath != "" || comparePath != "" {
+			err := validateSnapshotOptions(cmd.Flags(), output)
+			if err == nil {
+				var name string
+				if len(args) > 0 {
+					name = args[0]
+				}
+				err = runStatSnapshot(&client, name, savePath, comparePath, output, total, thresholds)
+			}
+			if err != nil {
+				cmd.SilenceUsage = true
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			}
+			return err
+		}
+
+		if jsonLines {
+			rows, err := writeStreamStatLines(&client, os.Stdout, thresholds)
+			if err == nil {
+				err = reportBreaches(os.Stderr, rows)
+			}
+			if err != nil {
+				cmd.SilenceUsage = true
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			}
+			return err
+		}
+
+		if all, _ := cmd.Flags().GetBool(statAllFlag); all {
+			rows, err := fetchAllStreamStats(&client)
+			if err == nil {
+				applyThresholds(rows, thresholds)
+				err = printAllStreamStats(rows, output, total)
+			}
+			if err == nil {
+				err = reportBreaches(os.Stderr, rows)
+			}
+			if err != nil {
+				cmd.SilenceUsage = true
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			}
+			return err
+		}
+
+		name := args[0]
+
+		if byPartition, _ := cmd.Flags().GetBool(byPartitionFlag); byPartition {
+			sortBy, _ := cmd.Flags().GetString(partitionSortFlag)
+			top, _ := cmd.Flags().GetInt(partitionTopFlag)
+
+			partitions, err := fetchPartitionBreakdown(&client, name)
+			if err == nil {
+				partitions, err = sortPartitions(partitions, sortBy, top)
+			}
+			if err == nil {
+				err = printPartitions(partitions, output)
+			}
+			if err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			}
+			return err
+		}
 
 		// Fetch stats data
 		stats, err := fetchStats(&client, name)
@@ -180,6 +475,21 @@ var StatStreamCmd = &cobra.Command{
 			return err
 		}
 
+		rows := []streamStatRow{newStreamStatRow(name, stats)}
+		applyThresholds(rows, thresholds)
+
+		if output == "csv" || output == "tsv" {
+			err = writeStreamStats(os.Stdout, rows, output, total)
+			if err == nil {
+				err = reportBreaches(os.Stderr, rows)
+			}
+			if err != nil {
+				cmd.SilenceUsage = true
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			}
+			return err
+		}
+
 		ingestionCount := stats.Ingestion.Count
 		ingestionSize, _ := strconv.Atoi(strings.TrimRight(stats.Ingestion.Size, " Bytes"))
 		storageSize, _ := strconv.Atoi(strings.TrimRight(stats.Storage.Size, " Bytes"))
@@ -209,11 +519,32 @@ var StatStreamCmd = &cobra.Command{
 			return err
 		}
 
+		hotTier, err := fetchStreamHotTier(&client, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to fetch hot tier: %s\n", err)
+		}
+
+		metadata, err := config.ReadStreamMetadata()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read stream metadata: %s\n", err)
+		}
+		meta := metadata.Get(DefaultProfile.URL, name)
+
+		var sample *streamSample
+		if sampleSize > 0 {
+			sample, err = fetchStreamSample(&client, name, sampleSize)
+			if err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+		}
+
 		// Check output format
-		output, _ := cmd.Flags().GetString("output")
 		if output == "json" {
 			// Prepare JSON response
 			data := map[string]interface{}{
+				"description": meta.Description,
+				"tags":        meta.Tags,
 				"info": map[string]interface{}{
 					"event_count":       ingestionCount,
 					"ingestion_size":    humanize.Bytes(uint64(ingestionSize)),
@@ -223,6 +554,17 @@ var StatStreamCmd = &cobra.Command{
 				"retention":   retention,
 				"alerts":      alertsData.Alerts,
 				"stream_type": streamType,
+				"hot_tier":    hotTier,
+			}
+			if rows[0].Breached != nil {
+				data["breached"] = *rows[0].Breached
+			}
+			if len(rows[0].Breaches) > 0 {
+				data["breaches"] = rows[0].Breaches
+			}
+			if sample != nil {
+				data["schema"] = sample.Schema
+				data["sample"] = sample.Records
 			}
 
 			jsonData, err := json.MarshalIndent(data, "", "  ")
@@ -244,13 +586,27 @@ var StatStreamCmd = &cobra.Command{
 			fmt.Printf("  %-18s %s\n", "Storage Size:", humanize.Bytes(uint64(storageSize)))
 			fmt.Printf("  %-18s %.2f%s\n", "Compression Ratio:", compressionRatio, "%")
 			fmt.Printf("  %-18s %s\n", "Stream Type:", streamType)
+			if hotTier != nil {
+				fmt.Printf("  %-18s %s (%s used)\n", "Hot Tier Size:", humanize.IBytes(uint64(hotTier.Size)), humanize.IBytes(uint64(hotTier.UsedSize)))
+			}
+			if meta.Description != "" {
+				fmt.Printf("  %-18s %s\n", "Description:", meta.Description)
+			}
+			if len(meta.Tags) > 0 {
+				fmt.Printf("  %-18s %s\n", "Tags:", formatTags(meta))
+			}
 			fmt.Println()
 
 			if isRetentionSet {
 				fmt.Println(StyleBold.Render("Retention:"))
 				for _, item := range retention {
 					fmt.Printf("  Action:    %s\n", StyleBold.Render(item.Action))
-					fmt.Printf("  Duration:  %s\n", StyleBold.Render(item.Duration))
+					if item.Duration != "" {
+						fmt.Printf("  Duration:  %s\n", StyleBold.Render(item.Duration))
+					}
+					if item.Size > 0 {
+						fmt.Printf("  Size:      %s\n", StyleBold.Render(humanize.IBytes(item.Size)))
+					}
 					fmt.Println()
 				}
 			} else {
@@ -278,22 +634,71 @@ var StatStreamCmd = &cobra.Command{
 			} else {
 				fmt.Println(StyleBold.Render("No alerts set on stream\n"))
 			}
+
+			if sample != nil {
+				if err := printStreamSample(os.Stdout, sample); err != nil {
+					cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+					return err
+				}
+			}
 		}
 
+		if err := reportBreaches(os.Stderr, rows); err != nil {
+			cmd.SilenceUsage = true
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
 		return nil
 	},
 }
 
 func init() {
-	StatStreamCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (text|json)")
+	StatStreamCmd.Flags().StringP("output", "o", "", "Output format (text|json|csv|tsv)")
+	StatStreamCmd.Flags().Bool(statAllFlag, false, "Show statistics for every stream")
+	StatStreamCmd.Flags().Bool(statTotalFlag, false, "Add a row with the totals across all streams")
+	StatStreamCmd.Flags().Bool(byPartitionFlag, false, "Show event counts and sizes per partition")
+	StatStreamCmd.Flags().String(partitionSortFlag, defaultPartitionSort, "Sort partitions by count, size or name (with --by-partition)")
+	StatStreamCmd.Flags().Int(partitionTopFlag, 0, "Only show the top N partitions (with --by-partition)")
+	StatStreamCmd.Flags().String(maxSizeFlag, "", "Exit with code 2 when the storage size of a stream exceeds this size, e.g. 50GB")
+	StatStreamCmd.Flags().Int64(maxEventsFlag, 0, "Exit with code 2 when the event count of a stream exceeds this number")
+	StatStreamCmd.Flags().String(saveSnapshotFlag, "", "Save the statistics to this file, to compare with later using --compare-with")
+	StatStreamCmd.Flags().String(compareWithFlag, "", "Show the growth in events and sizes, and new and removed streams, since a snapshot saved with --save-snapshot")
+	StatStreamCmd.Flags().Bool(jsonLinesFlag, false, "With --all, print one JSON object per stream and line as soon as its statistics arrive")
+	StatStreamCmd.MarkFlagsMutuallyExclusive(statAllFlag, byPartitionFlag)
+	StatStreamCmd.MarkFlagsMutuallyExclusive(maxSizeFlag, byPartitionFlag)
+	StatStreamCmd.MarkFlagsMutuallyExclusive(maxEventsFlag, byPartitionFlag)
+	StatStreamCmd.Flags().Int(showSampleFlag, 0, "Also show the schema and this many of the most recent records")
+	StatStreamCmd.MarkFlagsMutuallyExclusive(showSampleFlag, statAllFlag)
+	StatStreamCmd.MarkFlagsMutuallyExclusive(showSampleFlag, byPartitionFlag)
 }
 
+var (
+	removeStreamRegexFlag     = "regex"
+	removeStreamMaxDeleteFlag = "max-delete"
+
+	defaultMaxDelete = 10
+
+	// bulkDeleteConcurrency bounds the number of in-flight delete requests
+	bulkDeleteConcurrency = 4
+)
+
 var RemoveStreamCmd = &cobra.Command{
 	Use:     "remove stream-name",
 	Aliases: []string{"rm"},
-	Example: " pb stream remove backend_logs",
+	Example: " pb stream remove backend_logs\n pb stream remove backend_logs --yes\n pb stream remove --regex '^test_.*'",
 	Short:   "Delete a stream",
-	Args:    cobra.ExactArgs(1),
+	Long: `Delete a stream and all its data.
+
+pb asks you to type the stream name, or the number of matching streams with
+--regex, before deleting anything. Pass --yes to skip the prompt. When stdin
+is not a terminal, pb cannot ask and stops with an error unless --yes is set.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		pattern, _ := cmd.Flags().GetString(removeStreamRegexFlag)
+		if pattern != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Capture start time
 		startTime := time.Now()
@@ -302,8 +707,28 @@ var RemoveStreamCmd = &cobra.Command{
 			cmd.Annotations["executionTime"] = time.Since(startTime).String()
 		}()
 
-		name := args[0]
 		client := internalHTTP.DefaultClient(&DefaultProfile)
+
+		pattern, _ := cmd.Flags().GetString(removeStreamRegexFlag)
+		if pattern != "" {
+			err := removeStreamsMatching(cmd, &client, pattern)
+			if err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			}
+			return err
+		}
+
+		name := args[0]
+		confirmed, err := confirmDestructive(cmd, fmt.Sprintf("Deleting stream %s and all its data", name), name)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted, the stream was not deleted")
+			return nil
+		}
+
 		req, err := client.NewRequest("DELETE", "logstream/"+name, nil)
 		if err != nil {
 			// Capture error
@@ -323,6 +748,7 @@ var RemoveStreamCmd = &cobra.Command{
 
 		if resp.StatusCode == 200 {
 			fmt.Printf("Successfully deleted stream %s\n", StyleBold.Render(name))
+			forgetStreamMetadata(DefaultProfile.URL, []string{name})
 		} else {
 			bytes, err := io.ReadAll(resp.Body)
 			if err != nil {
@@ -338,10 +764,110 @@ var RemoveStreamCmd = &cobra.Command{
 	},
 }
 
+func init() {
+	RemoveStreamCmd.Flags().String(removeStreamRegexFlag, "", "Delete all streams whose name matches this regular expression")
+	RemoveStreamCmd.Flags().Int(removeStreamMaxDeleteFlag, defaultMaxDelete, "Refuse to delete more than this many streams matched by --regex")
+}
+
+// removeStreamsMatching deletes every stream whose name matches pattern after
+// showing the matches and asking the user to confirm.
+func removeStreamsMatching(cmd *cobra.Command, client *internalHTTP.HTTPClient, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+
+	streams, err := fetchStreams(client)
+	if err != nil {
+		return err
+	}
+
+	var matched []string
+	for _, stream := range streams {
+		if re.MatchString(stream.Name) {
+			matched = append(matched, stream.Name)
+		}
+	}
+
+	if len(matched) == 0 {
+		fmt.Printf("No streams match %s\n", StyleBold.Render(pattern))
+		return nil
+	}
+
+	maxDelete, _ := cmd.Flags().GetInt(removeStreamMaxDeleteFlag)
+	if len(matched) > maxDelete {
+		return fmt.Errorf("pattern %q matches %d streams, which is more than --%s=%d. Raise --%s to delete them all", pattern, len(matched), removeStreamMaxDeleteFlag, maxDelete, removeStreamMaxDeleteFlag)
+	}
+
+	fmt.Printf("The following %d stream(s) match %s:\n", len(matched), StyleBold.Render(pattern))
+	for _, name := range matched {
+		fmt.Printf("  • %s\n", name)
+	}
+
+	fmt.Println()
+	confirmed, err := confirmDestructive(cmd, fmt.Sprintf("Deleting %d stream(s)", len(matched)), strconv.Itoa(len(matched)))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		fmt.Println("Aborted, no streams were deleted")
+		return nil
+	}
+
+	results := make([]error, len(matched))
+	sem := make(chan struct{}, bulkDeleteConcurrency)
+	var wg sync.WaitGroup
+	for idx, name := range matched {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[idx] = deleteStream(client, name)
+		}(idx, name)
+	}
+	wg.Wait()
+
+	failed := 0
+	var deleted []string
+	fmt.Println()
+	for idx, name := range matched {
+		if results[idx] != nil {
+			failed++
+			fmt.Printf("  %s %s: %v\n", common.Red+"✗"+common.Reset, name, results[idx])
+		} else {
+			deleted = append(deleted, name)
+			fmt.Printf("  %s %s\n", common.Green+"✓"+common.Reset, name)
+		}
+	}
+	forgetStreamMetadata(client.Profile.URL, deleted)
+	fmt.Printf("\nDeleted %d of %d stream(s)\n", len(matched)-failed, len(matched))
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d stream(s)", failed)
+	}
+	return nil
+}
+
+var (
+	listStreamRegexFlag    = "regex"
+	listStreamEmptyFlag    = "empty"
+	listStreamNonEmptyFlag = "nonempty"
+)
+
+// streamListEntry is a stream in the json output of stream list. Events is
+// only set when the list was filtered on stream stats
+type streamListEntry struct {
+	Name        string            `json:"name"`
+	Events      *int              `json:"events,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
 // ListStreamCmd is the list command for streams
 var ListStreamCmd = &cobra.Command{
 	Use:     "list",
-	Example: "  pb stream list",
+	Example: "  pb stream list\n  pb stream list --regex '^test_' --empty -o json\n  pb stream list --group prod\n  pb stream list --tag env=prod",
 	Short:   "List all streams",
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		// Capture start time
@@ -351,40 +877,92 @@ var ListStreamCmd = &cobra.Command{
 			cmd.Annotations["executionTime"] = time.Since(startTime).String()
 		}()
 
-		client := internalHTTP.DefaultClient(&DefaultProfile)
-		req, err := client.NewRequest("GET", "logstream", nil)
+		pattern, _ := cmd.Flags().GetString(listStreamRegexFlag)
+		onlyEmpty, _ := cmd.Flags().GetBool(listStreamEmptyFlag)
+		onlyNonEmpty, _ := cmd.Flags().GetBool(listStreamNonEmptyFlag)
+		output, _ := cmd.Flags().GetString("output")
+		tagValues, _ := cmd.Flags().GetStringArray(streamTagFlag)
+		tagFilter, err := parseTags(tagValues)
 		if err != nil {
-			// Capture error
 			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
 			return err
 		}
 
-		resp, err := client.Client.Do(req)
+		metadata, err := config.ReadStreamMetadata()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read stream metadata: %s\n", err)
+		}
+
+		var re *regexp.Regexp
+		if pattern != "" {
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return fmt.Errorf("invalid regex %q: %w", pattern, err)
+			}
+		}
+
+		listEntries := func(client *internalHTTP.HTTPClient) ([]streamListEntry, error) {
+			streams, err := fetchStreams(client)
+			if err != nil {
+				return nil, err
+			}
+
+			entries := make([]streamListEntry, 0, len(streams))
+			for _, stream := range streams {
+				if re != nil && !re.MatchString(stream.Name) {
+					continue
+				}
+				meta := metadata.Get(client.Profile.URL, stream.Name)
+				if !meta.HasTags(tagFilter) {
+					continue
+				}
+				entries = append(entries, streamListEntry{Name: stream.Name, Description: meta.Description, Tags: meta.Tags})
+			}
+
+			if onlyEmpty || onlyNonEmpty {
+				entries = filterStreamsByEvents(client, entries, onlyEmpty)
+			}
+			return entries, nil
+		}
+
+		names, fanOut, err := fanOutProfiles(cmd)
 		if err != nil {
-			// Capture error
 			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
 			return err
 		}
-
-		var streams []StreamListItem
-		if resp.StatusCode == 200 {
-			bytes, err := io.ReadAll(resp.Body)
+		if names != nil {
+			results := runAcrossProfiles(names, fanOut, func(client *internalHTTP.HTTPClient) (interface{}, error) {
+				return listEntries(client)
+			})
+			err := printStreamListResults(results, output)
 			if err != nil {
 				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
-				return err
 			}
-			if err := json.Unmarshal(bytes, &streams); err != nil {
+			return err
+		}
+
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+		entries, err := listEntries(&client)
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		if output == "json" {
+			jsonData, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
 				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
 				return err
 			}
-
-			for _, stream := range streams {
-				fmt.Println(stream.Render())
-			}
-		} else {
-			fmt.Printf("Failed to fetch streams. Status Code: %s\n", resp.Status)
+			fmt.Println(string(jsonData))
+			return nil
 		}
 
+		for _, entry := range entries {
+			item := StreamListItem{Name: entry.Name}
+			fmt.Println(item.Render())
+		}
 		return nil
 	},
 }
@@ -392,6 +970,131 @@ var ListStreamCmd = &cobra.Command{
 func init() {
 	// Add the --output flag with default value "text"
 	ListStreamCmd.Flags().StringP("output", "o", "text", "Output format: 'text' or 'json'")
+	ListStreamCmd.Flags().String(listStreamRegexFlag, "", "Only list streams whose name matches this regular expression")
+	ListStreamCmd.Flags().Bool(listStreamEmptyFlag, false, "Only list streams with no events")
+	ListStreamCmd.Flags().Bool(listStreamNonEmptyFlag, false, "Only list streams with at least one event")
+	ListStreamCmd.Flags().StringArray(streamTagFlag, nil, "Only list streams tagged with key=value, can be repeated to require several tags")
+	ListStreamCmd.MarkFlagsMutuallyExclusive(listStreamEmptyFlag, listStreamNonEmptyFlag)
+	addFanOutFlags(ListStreamCmd)
+}
+
+// printStreamListResults prints the streams of each profile under a header,
+// or all results as one JSON array. It fails if any profile failed
+func printStreamListResults(results []profileResult, output string) error {
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	if output == "json" {
+		jsonData, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonData))
+	} else {
+		for _, result := range results {
+			fmt.Println(StyleBold.Render(result.Profile + ":"))
+			if result.Error != "" {
+				fmt.Printf("  %s %s\n", common.Red+"✗"+common.Reset, strings.TrimSpace(result.Error))
+				continue
+			}
+			for _, entry := range result.Result.([]streamListEntry) {
+				item := StreamListItem{Name: entry.Name}
+				fmt.Println(item.Render())
+			}
+			fmt.Println()
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to list streams for %d of %d profile(s)", failed, len(results))
+	}
+	return nil
+}
+
+// filterStreamsByEvents fetches stats for the given streams concurrently and
+// keeps the empty ones when empty is true, the non-empty ones otherwise.
+// Streams whose stats cannot be fetched are reported and left out
+func filterStreamsByEvents(client *internalHTTP.HTTPClient, entries []streamListEntry, empty bool) []streamListEntry {
+	counts := make([]int, len(entries))
+	errs := make([]error, len(entries))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statsConcurrency)
+	for idx, entry := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			stats, err := fetchStats(client, name)
+			counts[idx], errs[idx] = stats.Ingestion.Count, err
+		}(idx, entry.Name)
+	}
+	wg.Wait()
+
+	filtered := make([]streamListEntry, 0, len(entries))
+	for idx, entry := range entries {
+		if errs[idx] != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s, failed to fetch stats: %s\n", entry.Name, strings.TrimSpace(errs[idx].Error()))
+			continue
+		}
+		if (counts[idx] == 0) == empty {
+			count := counts[idx]
+			entry.Events = &count
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+func fetchStreams(client *internalHTTP.HTTPClient) (streams []StreamListItem, err error) {
+	req, err := client.NewRequest(http.MethodGet, "logstream", nil)
+	if err != nil {
+		return
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return
+	}
+
+	bytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		err = json.Unmarshal(bytes, &streams)
+	} else {
+		body := string(bytes)
+		body = fmt.Sprintf("Request Failed\nStatus Code: %s\nResponse: %s\n", resp.Status, body)
+		err = errors.New(body)
+	}
+	return
+}
+
+func deleteStream(client *internalHTTP.HTTPClient, name string) error {
+	req, err := client.NewRequest(http.MethodDelete, "logstream/"+name, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		bytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(bytes)))
+	}
+	return nil
 }
 
 func fetchStats(client *internalHTTP.HTTPClient, name string) (data StreamStatsData, err error) {
@@ -476,43 +1179,44 @@ func fetchAlerts(client *internalHTTP.HTTPClient, name string) (data AlertConfig
 }
 
 func fetchInfo(client *internalHTTP.HTTPClient, name string) (streamType string, err error) {
+	info, err := fetchStreamInfo(client, name)
+	if err != nil {
+		return "", err
+	}
+	return info.StreamType, nil
+}
+
+func fetchStreamInfo(client *internalHTTP.HTTPClient, name string) (info StreamInfo, err error) {
 	// Create a new HTTP GET request
 	req, err := client.NewRequest(http.MethodGet, fmt.Sprintf("logstream/%s/info", name), nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return info, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Execute the request
 	resp, err := client.Client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request execution failed: %w", err)
+		return info, fmt.Errorf("request execution failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	bytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return info, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	// Check for successful status code
 	if resp.StatusCode == http.StatusOK {
-		// Define a struct to parse the response
-		var response struct {
-			StreamType string `json:"stream_type"`
-		}
-
 		// Unmarshal JSON into the struct
-		if err := json.Unmarshal(bytes, &response); err != nil {
-			return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		if err := json.Unmarshal(bytes, &info); err != nil {
+			return info, fmt.Errorf("failed to unmarshal response: %w", err)
 		}
-
-		// Return the extracted stream_type
-		return response.StreamType, nil
+		return info, nil
 	}
 
 	// Handle non-200 responses
 	body := string(bytes)
 	errMsg := fmt.Sprintf("Request failed\nStatus Code: %d\nResponse: %s\n", resp.StatusCode, body)
-	return "", errors.New(errMsg)
+	return info, errors.New(errMsg)
 }
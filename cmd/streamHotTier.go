@@ -0,0 +1,185 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"pb/pkg/analytics"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/dustin/go-humanize"
+)
+
+var (
+	hotTierSizeFlag = "hot-tier-size"
+
+	// minHotTierSize is the smallest hot tier the server accepts for a stream
+	minHotTierSize uint64 = 10 * humanize.GiByte
+)
+
+// StreamHotTier is the hot tier configuration and usage of a stream. Sizes
+// are in bytes
+type StreamHotTier struct {
+	Size          hotTierBytes `json:"size"`
+	UsedSize      hotTierBytes `json:"used_size"`
+	AvailableSize hotTierBytes `json:"available_size"`
+	OldestEntry   string       `json:"oldest_date_time_entry,omitempty"`
+}
+
+// hotTierBytes is a size in bytes. Older servers report hot tier sizes as
+// human readable strings such as "10 GiB", newer ones as numbers, both are
+// accepted when decoding
+type hotTierBytes uint64
+
+func (b *hotTierBytes) UnmarshalJSON(data []byte) error {
+	var number uint64
+	if err := json.Unmarshal(data, &number); err == nil {
+		*b = hotTierBytes(number)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid hot tier size %s", string(data))
+	}
+	size, err := humanize.ParseBytes(text)
+	if err != nil {
+		return fmt.Errorf("invalid hot tier size %q: %w", text, err)
+	}
+	*b = hotTierBytes(size)
+	return nil
+}
+
+// parseHotTierSize parses a --hot-tier-size value such as 20GiB
+func parseHotTierSize(value string) (uint64, error) {
+	size, err := humanize.ParseBytes(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --%s %q, use a size such as 20GiB", hotTierSizeFlag, value)
+	}
+	if size < minHotTierSize {
+		return 0, fmt.Errorf("--%s must be at least %s, got %s", hotTierSizeFlag, humanize.IBytes(minHotTierSize), humanize.IBytes(size))
+	}
+	return size, nil
+}
+
+// isDistributed reports whether the server runs in distributed mode. Older
+// servers do not report a mode and are treated as standalone
+func isDistributed(about analytics.About) bool {
+	mode := strings.ToLower(strings.TrimSpace(about.Mode))
+	return mode != "" && mode != "standalone"
+}
+
+// checkHotTierSupport returns an error explaining why the server cannot hold
+// a hot tier for streams. Hot tiers are only available on distributed
+// deployments with hot tier storage configured
+func checkHotTierSupport(about analytics.About) error {
+	if !isDistributed(about) {
+		mode := about.Mode
+		if mode == "" {
+			mode = "unknown"
+		}
+		return fmt.Errorf("--%s needs a distributed Parseable deployment, the server runs in %s mode", hotTierSizeFlag, mode)
+	}
+	if strings.EqualFold(about.HotTier, "disabled") {
+		return fmt.Errorf("--%s needs hot tier storage, which is not configured on the server", hotTierSizeFlag)
+	}
+	return nil
+}
+
+// checkServerHotTier asks the server for its mode before a hot tier is
+// requested, so the flag is never silently dropped
+func checkServerHotTier(client *internalHTTP.HTTPClient) error {
+	about, err := analytics.FetchAbout(client)
+	if err != nil {
+		return fmt.Errorf("failed to detect the server mode: %w", err)
+	}
+	return checkHotTierSupport(about)
+}
+
+// setHotTier sets the hot tier size of a stream
+func setHotTier(client *internalHTTP.HTTPClient, name string, size uint64) error {
+	body, err := json.Marshal(map[string]uint64{"size": size})
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest(http.MethodPut, fmt.Sprintf("logstream/%s/hottier", name), bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Request Failed\nStatus Code: %s\nResponse: %s", resp.Status, string(respBody))
+	}
+	return nil
+}
+
+// fetchHotTier returns the hot tier of a stream, or nil when the stream has
+// none
+func fetchHotTier(client *internalHTTP.HTTPClient, name string) (*StreamHotTier, error) {
+	req, err := client.NewRequest(http.MethodGet, fmt.Sprintf("logstream/%s/hottier", name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var hotTier StreamHotTier
+		if err := json.Unmarshal(respBody, &hotTier); err != nil {
+			return nil, err
+		}
+		return &hotTier, nil
+	case http.StatusNotFound, http.StatusBadRequest:
+		// the server answers with an error when no hot tier is set
+		return nil, nil
+	}
+	return nil, fmt.Errorf("Request Failed\nStatus Code: %s\nResponse: %s", resp.Status, string(respBody))
+}
+
+// fetchStreamHotTier returns the hot tier of a stream when the server is
+// distributed, and nil on standalone servers which have no hot tier
+func fetchStreamHotTier(client *internalHTTP.HTTPClient, name string) (*StreamHotTier, error) {
+	about, err := analytics.FetchAbout(client)
+	if err != nil {
+		return nil, err
+	}
+	if !isDistributed(about) {
+		return nil, nil
+	}
+	return fetchHotTier(client, name)
+}
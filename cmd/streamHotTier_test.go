@@ -0,0 +1,118 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/analytics"
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestParseHotTierSize(t *testing.T) {
+	size, err := parseHotTierSize("20GiB")
+	if err != nil || size != 20*1024*1024*1024 {
+		t.Errorf("expected 20GiB to parse, got %d, %v", size, err)
+	}
+
+	if _, err := parseHotTierSize("1GiB"); err == nil || !strings.Contains(err.Error(), "at least 10 GiB") {
+		t.Errorf("expected a size below the minimum to be rejected, got %v", err)
+	}
+	if _, err := parseHotTierSize("lots"); err == nil {
+		t.Error("expected an invalid size to be rejected")
+	}
+}
+
+func TestHotTierSupportFollowsServerMode(t *testing.T) {
+	cases := []struct {
+		about   analytics.About
+		wantErr string
+	}{
+		{analytics.About{Mode: "Standalone"}, "Standalone mode"},
+		{analytics.About{}, "unknown mode"},
+		{analytics.About{Mode: "Distributed (Query)", HotTier: "Disabled"}, "not configured"},
+		{analytics.About{Mode: "Distributed (Query)", HotTier: "Enabled"}, ""},
+	}
+
+	for _, c := range cases {
+		err := checkHotTierSupport(c.about)
+		if c.wantErr == "" && err != nil {
+			t.Errorf("expected %+v to support hot tiers, got %v", c.about, err)
+		}
+		if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+			t.Errorf("expected error containing %q for %+v, got %v", c.wantErr, c.about, err)
+		}
+	}
+}
+
+func TestHotTierRoundTrip(t *testing.T) {
+	var stored []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/about":
+			json.NewEncoder(w).Encode(analytics.About{Mode: "Distributed (Query)", HotTier: "Enabled"})
+		case r.URL.Path == "/api/v1/logstream/app/hottier" && r.Method == http.MethodPut:
+			stored, _ = io.ReadAll(r.Body)
+		case r.URL.Path == "/api/v1/logstream/app/hottier" && stored != nil:
+			// older servers report sizes as strings
+			w.Write([]byte(`{"size":"20 GiB","used_size":"1 GiB","available_size":"19 GiB"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	if hotTier, err := fetchStreamHotTier(&client, "app"); err != nil || hotTier != nil {
+		t.Fatalf("expected no hot tier before one is set, got %+v, %v", hotTier, err)
+	}
+
+	if err := setHotTier(&client, "app", 20*1024*1024*1024); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+	if string(stored) != `{"size":21474836480}` {
+		t.Errorf("unexpected request body %s", stored)
+	}
+
+	hotTier, err := fetchStreamHotTier(&client, "app")
+	if err != nil || hotTier == nil {
+		t.Fatalf("expected a hot tier, got %+v, %v", hotTier, err)
+	}
+	if hotTier.Size != 20*1024*1024*1024 || hotTier.UsedSize != 1024*1024*1024 {
+		t.Errorf("unexpected hot tier %+v", hotTier)
+	}
+}
+
+func TestStandaloneHasNoHotTier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/about" {
+			t.Errorf("unexpected request to %s on a standalone server", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(analytics.About{Mode: "Standalone"})
+	}))
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	if hotTier, err := fetchStreamHotTier(&client, "app"); err != nil || hotTier != nil {
+		t.Errorf("expected no hot tier on a standalone server, got %+v, %v", hotTier, err)
+	}
+}
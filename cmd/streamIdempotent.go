@@ -0,0 +1,152 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/dustin/go-humanize"
+)
+
+var (
+	ifNotExistsFlag = "if-not-exists"
+	reconcileFlag   = "reconcile"
+)
+
+// streamSettings are the settings pb stream add was given, which an existing
+// stream is compared against. Settings whose flags were not given are nil or
+// zero and are not compared
+type streamSettings struct {
+	description *string
+	tags        map[string]string
+	hotTierSize uint64
+}
+
+// streamExists reports whether the server has a stream named name
+func streamExists(client *internalHTTP.HTTPClient, name string) (bool, error) {
+	streams, err := fetchStreams(client)
+	if err != nil {
+		return false, err
+	}
+	for _, stream := range streams {
+		if stream.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// streamDifferences lists each given setting that the existing stream has a
+// different value for, as "setting: current → wanted"
+func streamDifferences(client *internalHTTP.HTTPClient, url, name string, want streamSettings) ([]string, error) {
+	metadata, err := config.ReadStreamMetadata()
+	if err != nil {
+		return nil, err
+	}
+	meta := metadata.Get(url, name)
+
+	var differences []string
+	if want.description != nil && *want.description != meta.Description {
+		differences = append(differences, fmt.Sprintf("description: %q → %q", meta.Description, *want.description))
+	}
+	if want.tags != nil && !maps.Equal(want.tags, meta.Tags) {
+		differences = append(differences, fmt.Sprintf("tags: %s → %s", orNone(formatTags(meta)), formatTags(config.StreamMetadata{Tags: want.tags})))
+	}
+	if want.hotTierSize > 0 {
+		hotTier, err := fetchStreamHotTier(client, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch hot tier: %w", err)
+		}
+		current := "none"
+		if hotTier != nil {
+			current = humanize.IBytes(uint64(hotTier.Size))
+		}
+		if hotTier == nil || uint64(hotTier.Size) != want.hotTierSize {
+			differences = append(differences, fmt.Sprintf("hot tier size: %s → %s", current, humanize.IBytes(want.hotTierSize)))
+		}
+	}
+	return differences, nil
+}
+
+// reconcileStream updates the existing stream to the given settings
+func reconcileStream(client *internalHTTP.HTTPClient, url, name string, want streamSettings) error {
+	if want.description != nil || want.tags != nil {
+		metadata, err := config.ReadStreamMetadata()
+		if err != nil {
+			return err
+		}
+		meta := metadata.Get(url, name)
+		if want.description != nil {
+			meta.Description = *want.description
+		}
+		if want.tags != nil {
+			meta.Tags = want.tags
+		}
+		if err := saveStreamMetadata(url, name, meta); err != nil {
+			return fmt.Errorf("failed to save description and tags: %w", err)
+		}
+	}
+	if want.hotTierSize > 0 {
+		if err := setHotTier(client, name, want.hotTierSize); err != nil {
+			return fmt.Errorf("failed to set hot tier: %w", err)
+		}
+	}
+	return nil
+}
+
+// skipExistingStream handles pb stream add --if-not-exists for a stream that
+// already exists. It reports whether the settings match, and with reconcile
+// updates the ones that differ
+func skipExistingStream(client *internalHTTP.HTTPClient, url, name string, want streamSettings, reconcile bool, out io.Writer) error {
+	differences, err := streamDifferences(client, url, name, want)
+	if err != nil {
+		return err
+	}
+	if len(differences) == 0 {
+		fmt.Fprintf(out, "Skipped stream %s, it already exists with matching settings\n", StyleBold.Render(name))
+		return nil
+	}
+
+	if !reconcile {
+		fmt.Fprintf(out, "Skipped stream %s, it already exists with different settings:\n", StyleBold.Render(name))
+		for _, difference := range differences {
+			fmt.Fprintf(out, "  %s\n", difference)
+		}
+		fmt.Fprintf(out, "Pass --%s to update them\n", reconcileFlag)
+		return nil
+	}
+
+	if err := reconcileStream(client, url, name, want); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Updated existing stream %s:\n", StyleBold.Render(name))
+	for _, difference := range differences {
+		fmt.Fprintf(out, "  %s\n", difference)
+	}
+	return nil
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
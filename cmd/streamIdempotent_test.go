@@ -0,0 +1,123 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+// streamAddServer mocks a server that has the stream app and records the
+// streams created with PUT
+func streamAddServer(t *testing.T) (*httptest.Server, *[]string) {
+	var created []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/logstream" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode([]StreamListItem{{Name: "app"}})
+		case strings.HasPrefix(r.URL.Path, "/api/v1/logstream/") && r.Method == http.MethodPut:
+			created = append(created, strings.TrimPrefix(r.URL.Path, "/api/v1/logstream/"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &created
+}
+
+func runStreamAdd(t *testing.T, url string, args ...string) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	defer func(profile config.Profile) { DefaultProfile = profile }(DefaultProfile)
+	DefaultProfile = config.Profile{URL: url}
+	defer func() {
+		for _, name := range []string{ifNotExistsFlag, reconcileFlag} {
+			AddStreamCmd.Flags().Set(name, "false")
+			AddStreamCmd.Flags().Lookup(name).Changed = false
+		}
+		AddStreamCmd.SetArgs(nil)
+	}()
+
+	AddStreamCmd.SetArgs(args)
+	if err := AddStreamCmd.Execute(); err != nil {
+		t.Fatalf("expected stream add to succeed, got %v", err)
+	}
+}
+
+func TestAddStreamIfNotExistsCreatesNewStream(t *testing.T) {
+	server, created := streamAddServer(t)
+	runStreamAdd(t, server.URL, "web", "--if-not-exists")
+	if strings.Join(*created, ",") != "web" {
+		t.Errorf("expected the new stream to be created, got %v", *created)
+	}
+}
+
+func TestAddStreamIfNotExistsSkipsExistingStream(t *testing.T) {
+	server, created := streamAddServer(t)
+	runStreamAdd(t, server.URL, "app", "--if-not-exists")
+	if len(*created) != 0 {
+		t.Errorf("expected the existing stream to be skipped, got %v", *created)
+	}
+}
+
+func TestSkipExistingStreamComparesSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	server, _ := streamAddServer(t)
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	if err := saveStreamMetadata(server.URL, "app", config.StreamMetadata{Tags: map[string]string{"env": "prod"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := skipExistingStream(&client, server.URL, "app", streamSettings{tags: map[string]string{"env": "prod"}}, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "matching settings") {
+		t.Errorf("expected matching settings to be reported, got:\n%s", out.String())
+	}
+
+	description := "API logs"
+	want := streamSettings{description: &description, tags: map[string]string{"env": "staging"}}
+	out.Reset()
+	if err := skipExistingStream(&client, server.URL, "app", want, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"different settings", `description: "" → "API logs"`, "tags: env=prod → env=staging", "--reconcile"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+
+	if err := skipExistingStream(&client, server.URL, "app", want, true, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	metadata, _ := config.ReadStreamMetadata()
+	if meta := metadata.Get(server.URL, "app"); meta.Description != "API logs" || meta.Tags["env"] != "staging" {
+		t.Errorf("expected --reconcile to update the settings, got %+v", meta)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/pflag"
+)
+
+var jsonLinesFlag = "json-lines"
+
+// validateJSONLinesOptions checks that --json-lines is combined only with
+// flags that do not need every stat before printing
+func validateJSONLinesOptions(flags *pflag.FlagSet, output string) error {
+	if all, _ := flags.GetBool(statAllFlag); !all {
+		return fmt.Errorf("--%s requires --%s", jsonLinesFlag, statAllFlag)
+	}
+	if output != "" && output != "json" {
+		return fmt.Errorf("--%s writes JSON, it cannot be used with -o %s", jsonLinesFlag, output)
+	}
+	for _, name := range []string{statTotalFlag, saveSnapshotFlag, compareWithFlag} {
+		if flags.Changed(name) {
+			return fmt.Errorf("--%s cannot be used with --%s", name, jsonLinesFlag)
+		}
+	}
+	return nil
+}
+
+// writeStreamStatLines writes the stats of every stream to w as one JSON
+// object per line, each as soon as it is fetched, so the order follows the
+// responses rather than the stream list. Streams whose stats cannot be
+// fetched are skipped with a warning. It returns the written rows so that
+// threshold breaches can be reported afterwards
+func writeStreamStatLines(client *internalHTTP.HTTPClient, w io.Writer, thresholds statThresholds) ([]streamStatRow, error) {
+	var rows []streamStatRow
+	var writeErr error
+	encoder := json.NewEncoder(w)
+	err := forEachStreamStat(client, func(int) {}, func(_ int, row streamStatRow, err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s, failed to fetch stats: %s\n", row.Stream, strings.TrimSpace(err.Error()))
+			return
+		}
+		if writeErr != nil {
+			return
+		}
+		thresholds.mark(&row)
+		if writeErr = encoder.Encode(row); writeErr == nil {
+			rows = append(rows, row)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, writeErr
+}
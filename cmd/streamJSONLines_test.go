@@ -0,0 +1,95 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+// lineWriter hands every write to the test as it happens
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestStreamStatLinesAreWrittenAsFetched(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/logstream":
+			w.Write([]byte(`[{"name":"slow"},{"name":"fast"}]`))
+		case "/api/v1/logstream/slow/stats":
+			<-release
+			w.Write([]byte(`{"ingestion":{"count":2,"size":"400 Bytes"},"storage":{"size":"100 Bytes"}}`))
+		case "/api/v1/logstream/fast/stats":
+			w.Write([]byte(`{"ingestion":{"count":1,"size":"200 Bytes"},"storage":{"size":"100 Bytes"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	out := make(lineWriter, 2)
+	done := make(chan error, 1)
+	go func() {
+		_, err := writeStreamStatLines(&client, out, statThresholds{})
+		done <- err
+	}()
+
+	readRow := func() streamStatRow {
+		select {
+		case line := <-out:
+			var row streamStatRow
+			if err := json.Unmarshal([]byte(line), &row); err != nil {
+				t.Fatalf("line is not a JSON object: %q", line)
+			}
+			return row
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a line")
+		}
+		return streamStatRow{}
+	}
+
+	// the slow stream is still being fetched, so the fast one must already
+	// have been written on its own line
+	if row := readRow(); row.Stream != "fast" || row.EventCount != 1 {
+		t.Fatalf("expected the fast stream first, got %+v", row)
+	}
+	close(release)
+	if row := readRow(); row.Stream != "slow" || row.CompressionRatio != 75 {
+		t.Errorf("expected the slow stream second, got %+v", row)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
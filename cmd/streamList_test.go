@@ -0,0 +1,79 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestFilterStreamsByEvents(t *testing.T) {
+	counts := map[string]int{"empty": 0, "busy": 42}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/logstream/"), "/stats")
+		count, ok := counts[name]
+		if !ok {
+			http.Error(w, "stream not found", http.StatusNotFound)
+			return
+		}
+		var stats StreamStatsData
+		stats.Ingestion.Count = count
+		json.NewEncoder(w).Encode(stats)
+	}))
+	defer server.Close()
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	entries := []streamListEntry{{Name: "empty"}, {Name: "busy"}, {Name: "missing"}}
+
+	empty := filterStreamsByEvents(&client, entries, true)
+	if len(empty) != 1 || empty[0].Name != "empty" || *empty[0].Events != 0 {
+		t.Errorf("expected only the empty stream, got %+v", empty)
+	}
+
+	nonEmpty := filterStreamsByEvents(&client, entries, false)
+	if len(nonEmpty) != 1 || nonEmpty[0].Name != "busy" || *nonEmpty[0].Events != 42 {
+		t.Errorf("expected only the busy stream, got %+v", nonEmpty)
+	}
+}
+
+func TestListStreamsRejectsInvalidFilters(t *testing.T) {
+	defer func() {
+		for _, name := range []string{listStreamRegexFlag, listStreamEmptyFlag, listStreamNonEmptyFlag} {
+			flag := ListStreamCmd.Flags().Lookup(name)
+			flag.Value.Set(flag.DefValue)
+			flag.Changed = false
+		}
+		ListStreamCmd.SetArgs(nil)
+	}()
+	ListStreamCmd.SilenceUsage = true
+	ListStreamCmd.SilenceErrors = true
+
+	ListStreamCmd.SetArgs([]string{"--regex", "test_("})
+	if err := ListStreamCmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid regex") {
+		t.Errorf("expected an invalid regex error, got %v", err)
+	}
+
+	ListStreamCmd.SetArgs([]string{"--regex", "", "--empty", "--nonempty"})
+	if err := ListStreamCmd.Execute(); err == nil {
+		t.Error("expected --empty and --nonempty to be rejected together")
+	}
+}
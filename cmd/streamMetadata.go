@@ -0,0 +1,94 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"pb/pkg/config"
+)
+
+var (
+	streamDescriptionFlag = "description"
+	streamTagFlag         = "tag"
+)
+
+// parseTags turns repeated --tag key=value values into a map. Keys must be
+// unique and non-empty, values may be empty
+func parseTags(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(values))
+	for _, value := range values {
+		key, tagValue, ok := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q, use --%s key=value", value, streamTagFlag)
+		}
+		if _, exists := tags[key]; exists {
+			return nil, fmt.Errorf("tag %q is given more than once", key)
+		}
+		tags[key] = strings.TrimSpace(tagValue)
+	}
+	return tags, nil
+}
+
+// saveStreamMetadata records the description and tags of a stream created on
+// the server at url
+func saveStreamMetadata(url, stream string, meta config.StreamMetadata) error {
+	file, err := config.ReadStreamMetadata()
+	if err != nil {
+		return err
+	}
+	file.Set(url, stream, meta)
+	return config.WriteStreamMetadata(file)
+}
+
+// forgetStreamMetadata drops the metadata of deleted streams. Failures are
+// only reported, as the streams themselves are already gone
+func forgetStreamMetadata(url string, streams []string) {
+	file, err := config.ReadStreamMetadata()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read stream metadata: %s\n", err)
+		return
+	}
+
+	changed := false
+	for _, stream := range streams {
+		if !file.Get(url, stream).IsEmpty() {
+			file.Delete(url, stream)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := config.WriteStreamMetadata(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update stream metadata: %s\n", err)
+	}
+}
+
+// formatTags renders tags as sorted key=value pairs
+func formatTags(meta config.StreamMetadata) string {
+	pairs := make([]string, 0, len(meta.Tags))
+	for _, key := range meta.TagKeys() {
+		pairs = append(pairs, key+"="+meta.Tags[key])
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import "testing"
+
+func TestParseTags(t *testing.T) {
+	tags, err := parseTags([]string{"env=prod", "team = platform", "note="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags["env"] != "prod" || tags["team"] != "platform" || tags["note"] != "" || len(tags) != 3 {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	for _, values := range [][]string{{"env"}, {"=prod"}, {"env=prod", "env=dev"}} {
+		if _, err := parseTags(values); err == nil {
+			t.Errorf("expected %v to be rejected", values)
+		}
+	}
+}
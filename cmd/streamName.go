@@ -0,0 +1,57 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const maxStreamNameLength = 255
+
+var (
+	validateNameFlag = "validate-name"
+
+	streamNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+	// reservedStreamNames are used internally by Parseable or would need
+	// quoting in every SQL query against the stream
+	reservedStreamNames = map[string]struct{}{
+		"pmeta":  {},
+		"select": {},
+		"from":   {},
+		"where":  {},
+		"group":  {},
+		"order":  {},
+		"by":     {},
+		"limit":  {},
+		"join":   {},
+		"union":  {},
+		"table":  {},
+	}
+)
+
+// validateStreamName checks a stream name against the naming rules enforced
+// by Parseable so that bad names fail before any request is sent
+func validateStreamName(name string) error {
+	if len(name) == 0 || len(name) > maxStreamNameLength || !streamNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid stream name %q: stream names must be lowercase alphanumeric with underscores, 1-%d chars", name, maxStreamNameLength)
+	}
+	if _, reserved := reservedStreamNames[name]; reserved {
+		return fmt.Errorf("invalid stream name %q: %s is a reserved word", name, name)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateStreamNameAccepts(t *testing.T) {
+	for _, name := range []string{
+		"backend",
+		"backend_logs",
+		"app2",
+		"_internal",
+		"1st_stream",
+		strings.Repeat("a", maxStreamNameLength),
+	} {
+		if err := validateStreamName(name); err != nil {
+			t.Errorf("expected %q to be accepted, got %v", name, err)
+		}
+	}
+}
+
+func TestValidateStreamNameRejects(t *testing.T) {
+	for _, name := range []string{
+		"",
+		"Backend",
+		"backend-logs",
+		"backend logs",
+		"backend.logs",
+		"bäckend",
+		strings.Repeat("a", maxStreamNameLength+1),
+		"pmeta",
+		"select",
+	} {
+		if err := validateStreamName(name); err == nil {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestValidateStreamNameMessage(t *testing.T) {
+	err := validateStreamName("Bad-Name")
+	if err == nil || !strings.Contains(err.Error(), "lowercase alphanumeric with underscores, 1-255 chars") {
+		t.Errorf("expected naming rule in error, got %v", err)
+	}
+}
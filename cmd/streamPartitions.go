@@ -0,0 +1,228 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/dustin/go-humanize"
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	byPartitionFlag      = "by-partition"
+	partitionSortFlag    = "sort"
+	partitionTopFlag     = "top"
+	defaultPartitionSort = "count"
+
+	// defaultTimeColumn is used to bucket streams without a time partition
+	defaultTimeColumn = "p_timestamp"
+
+	// statsConcurrency bounds the number of in-flight stats requests
+	statsConcurrency = 4
+)
+
+// StreamPartitionData holds the event count and size of one partition
+type StreamPartitionData struct {
+	Partition string `json:"partition"`
+	Count     int64  `json:"count"`
+	// Size is -1 when the server cannot report a size for the partition
+	Size int64 `json:"size"`
+}
+
+// fetchPartitionBreakdown returns per partition counts for a stream. Streams
+// with a custom partition are grouped by the partition columns, all others are
+// grouped by day on the time partition column with sizes from the daily stats
+func fetchPartitionBreakdown(client *internalHTTP.HTTPClient, name string) ([]StreamPartitionData, error) {
+	info, err := fetchStreamInfo(client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	start := info.FirstEventAt
+	if start == "" {
+		start = info.CreatedAt
+	}
+	if start == "" {
+		return nil, nil
+	}
+	end := time.Now().UTC().Format(time.RFC3339)
+
+	if info.CustomPartition != "" {
+		columns := strings.Split(info.CustomPartition, ",")
+		for idx, column := range columns {
+			columns[idx] = strings.TrimSpace(column)
+		}
+		query := fmt.Sprintf("select %s, count(*) as count from %s group by %s", strings.Join(columns, ", "), name, strings.Join(columns, ", "))
+		records, err := queryRecords(client, query, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		partitions := make([]StreamPartitionData, 0, len(records))
+		for _, record := range records {
+			values := make([]string, len(columns))
+			for idx, column := range columns {
+				values[idx] = fmt.Sprintf("%s=%s", column, csvValue(record[column]))
+			}
+			partitions = append(partitions, StreamPartitionData{
+				Partition: strings.Join(values, ","),
+				Count:     recordCount(record["count"]),
+				Size:      -1,
+			})
+		}
+		return partitions, nil
+	}
+
+	timeColumn := info.TimePartition
+	if timeColumn == "" {
+		timeColumn = defaultTimeColumn
+	}
+	query := fmt.Sprintf("select date_trunc('day', %s) as partition, count(*) as count from %s group by partition", timeColumn, name)
+	records, err := queryRecords(client, query, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make([]StreamPartitionData, len(records))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statsConcurrency)
+	for idx, record := range records {
+		day := csvValue(record["partition"])
+		if len(day) > len("2006-01-02") {
+			day = day[:len("2006-01-02")]
+		}
+		partitions[idx] = StreamPartitionData{Partition: day, Count: recordCount(record["count"]), Size: -1}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, day string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if stats, err := fetchStatsForDate(client, name, day); err == nil {
+				size, _ := strconv.ParseInt(strings.TrimRight(stats.Ingestion.Size, " Bytes"), 10, 64)
+				partitions[idx].Size = size
+			}
+		}(idx, day)
+	}
+	wg.Wait()
+
+	return partitions, nil
+}
+
+// sortPartitions orders partitions by count, size or name, largest first for
+// count and size, and keeps at most top entries when top is positive
+func sortPartitions(partitions []StreamPartitionData, by string, top int) ([]StreamPartitionData, error) {
+	switch by {
+	case "count":
+		sort.SliceStable(partitions, func(i, j int) bool { return partitions[i].Count > partitions[j].Count })
+	case "size":
+		sort.SliceStable(partitions, func(i, j int) bool { return partitions[i].Size > partitions[j].Size })
+	case "name":
+		sort.SliceStable(partitions, func(i, j int) bool { return partitions[i].Partition < partitions[j].Partition })
+	default:
+		return nil, fmt.Errorf("unsupported sort %q, use count, size or name", by)
+	}
+
+	if top > 0 && len(partitions) > top {
+		partitions = partitions[:top]
+	}
+	return partitions, nil
+}
+
+func printPartitions(partitions []StreamPartitionData, output string) error {
+	if output == "json" {
+		if partitions == nil {
+			partitions = []StreamPartitionData{}
+		}
+		jsonData, err := json.MarshalIndent(partitions, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+
+	if len(partitions) == 0 {
+		fmt.Println("No partitions found for stream")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Partition", "Events", "Size"})
+	for _, partition := range partitions {
+		size := "unknown"
+		if partition.Size >= 0 {
+			size = humanize.Bytes(uint64(partition.Size))
+		}
+		table.Append([]string{partition.Partition, strconv.FormatInt(partition.Count, 10), size})
+	}
+	table.Render()
+	return nil
+}
+
+func fetchStatsForDate(client *internalHTTP.HTTPClient, name, date string) (data StreamStatsData, err error) {
+	req, err := client.NewRequest(http.MethodGet, fmt.Sprintf("logstream/%s/stats", name), nil)
+	if err != nil {
+		return
+	}
+	req.URL.RawQuery = url.Values{"date": []string{date}}.Encode()
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return
+	}
+
+	bytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		err = json.Unmarshal(bytes, &data)
+	} else {
+		body := string(bytes)
+		body = fmt.Sprintf("Request Failed\nStatus Code: %s\nResponse: %s\n", resp.Status, body)
+		err = errors.New(body)
+	}
+	return
+}
+
+// recordCount converts a count column from a query result to an integer
+func recordCount(value interface{}) int64 {
+	switch v := value.(type) {
+	case float64:
+		return int64(v)
+	case string:
+		count, _ := strconv.ParseInt(v, 10, 64)
+		return count
+	}
+	return 0
+}
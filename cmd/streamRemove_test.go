@@ -0,0 +1,129 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+// streamRemoveServer lists streams and records the names of deleted ones
+func streamRemoveServer(t *testing.T, streams ...string) (*internalHTTP.HTTPClient, func() []string) {
+	var mu sync.Mutex
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/logstream":
+			items := make([]StreamListItem, len(streams))
+			for idx, name := range streams {
+				items[idx] = StreamListItem{Name: name}
+			}
+			json.NewEncoder(w).Encode(items)
+		case r.Method == http.MethodDelete:
+			mu.Lock()
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/api/v1/logstream/"))
+			mu.Unlock()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	return &client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(deleted)
+		return deleted
+	}
+}
+
+func resetRemoveStreamFlags(t *testing.T) {
+	t.Cleanup(func() {
+		flag := RemoveStreamCmd.Flags().Lookup(removeStreamMaxDeleteFlag)
+		flag.Value.Set(flag.DefValue)
+		flag.Changed = false
+	})
+}
+
+func TestRemoveStreamsMatchingDeletesMatches(t *testing.T) {
+	client, deleted := streamRemoveServer(t, "test-a", "test-b", "prod-a")
+	withAssumeYes(t)
+
+	if err := removeStreamsMatching(RemoveStreamCmd, client, "^test-"); err != nil {
+		t.Fatal(err)
+	}
+	if got := deleted(); len(got) != 2 || got[0] != "test-a" || got[1] != "test-b" {
+		t.Errorf("expected only the test streams to be deleted, got %v", got)
+	}
+}
+
+func TestRemoveStreamsMatchingNoMatches(t *testing.T) {
+	client, deleted := streamRemoveServer(t, "prod-a")
+	withAssumeYes(t)
+
+	if err := removeStreamsMatching(RemoveStreamCmd, client, "^test-"); err != nil {
+		t.Fatal(err)
+	}
+	if got := deleted(); len(got) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", got)
+	}
+}
+
+func TestRemoveStreamsMatchingRespectsMaxDelete(t *testing.T) {
+	client, deleted := streamRemoveServer(t, "test-a", "test-b", "test-c")
+	withAssumeYes(t)
+	resetRemoveStreamFlags(t)
+	RemoveStreamCmd.Flags().Set(removeStreamMaxDeleteFlag, "2")
+
+	err := removeStreamsMatching(RemoveStreamCmd, client, "^test-")
+	if err == nil || !strings.Contains(err.Error(), "--max-delete") {
+		t.Errorf("expected the match count to exceed --max-delete, got %v", err)
+	}
+	if got := deleted(); len(got) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", got)
+	}
+}
+
+func TestRemoveStreamsMatchingNeedsConfirmation(t *testing.T) {
+	client, deleted := streamRemoveServer(t, "test-a")
+	withTerminal(t, false)
+
+	if err := removeStreamsMatching(RemoveStreamCmd, client, "^test-"); err == nil {
+		t.Error("expected removal to fail without a terminal or --yes")
+	}
+	if got := deleted(); len(got) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", got)
+	}
+}
+
+func TestRemoveStreamsMatchingInvalidRegex(t *testing.T) {
+	client, _ := streamRemoveServer(t)
+	if err := removeStreamsMatching(RemoveStreamCmd, client, "test-("); err == nil || !strings.Contains(err.Error(), "invalid regex") {
+		t.Errorf("expected an invalid regex error, got %v", err)
+	}
+}
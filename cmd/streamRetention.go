@@ -0,0 +1,225 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	retentionFlag     = "retention"
+	retentionSizeFlag = "retention-size"
+
+	// retentionDurationPattern matches the retention periods the server
+	// accepts, a number of days such as 30d
+	retentionDurationPattern = regexp.MustCompile(`^[1-9][0-9]*d$`)
+)
+
+// parseRetentionDuration checks a --retention value such as 30d
+func parseRetentionDuration(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !retentionDurationPattern.MatchString(value) {
+		return "", fmt.Errorf("invalid --%s %q, use a number of days such as 30d", retentionFlag, value)
+	}
+	return value, nil
+}
+
+// parseRetentionSize parses a --retention-size value such as 100GB or 1TiB.
+// A unit is required, so that a bare number is not taken as bytes by mistake
+func parseRetentionSize(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || !strings.ContainsAny(strings.ToLower(value[len(value)-1:]), "bkmgtpe") {
+		return 0, fmt.Errorf("invalid --%s %q, use a size with a unit such as 100GB, 2TB or 500GiB", retentionSizeFlag, value)
+	}
+	size, err := humanize.ParseBytes(value)
+	if err != nil || size == 0 {
+		return 0, fmt.Errorf("invalid --%s %q, use a size with a unit such as 100GB, 2TB or 500GiB", retentionSizeFlag, value)
+	}
+	return size, nil
+}
+
+// retentionFromFlags reads --retention and --retention-size. Both are empty
+// when neither flag is set
+func retentionFromFlags(cmd *cobra.Command) (duration string, size uint64, err error) {
+	if cmd.Flags().Changed(retentionFlag) {
+		value, _ := cmd.Flags().GetString(retentionFlag)
+		if duration, err = parseRetentionDuration(value); err != nil {
+			return "", 0, err
+		}
+	}
+	if cmd.Flags().Changed(retentionSizeFlag) {
+		value, _ := cmd.Flags().GetString(retentionSizeFlag)
+		if size, err = parseRetentionSize(value); err != nil {
+			return "", 0, err
+		}
+	}
+	return duration, size, nil
+}
+
+// retentionTasks builds the retention rules for a stream. With both a
+// duration and a size there is one rule for each, and data is deleted by
+// whichever applies first
+func retentionTasks(duration string, size uint64) StreamRetentionData {
+	var tasks StreamRetentionData
+	if duration != "" {
+		tasks = append(tasks, RetentionTask{Description: "delete data older than " + duration, Action: "delete", Duration: duration})
+	}
+	if size > 0 {
+		tasks = append(tasks, RetentionTask{Description: "delete the oldest data above " + formatRetentionSize(size), Action: "delete", Size: size})
+	}
+	return tasks
+}
+
+// applyRetention replaces the retention rules of a stream with rules for
+// duration and size. A server that does not apply size based retention
+// drops the size rule, so the rules are read back after they are sent. When
+// the size rule is missing, previous is restored and an error is returned
+func applyRetention(client *internalHTTP.HTTPClient, name, duration string, size uint64, previous StreamRetentionData) error {
+	if err := setRetention(client, name, retentionTasks(duration, size)); err != nil {
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+
+	applied, err := fetchRetention(client, name)
+	if err != nil {
+		return fmt.Errorf("failed to check that the server applied --%s: %w", retentionSizeFlag, err)
+	}
+	for _, task := range applied {
+		if task.Size == size {
+			return nil
+		}
+	}
+
+	unsupported := fmt.Errorf("the server does not support --%s, it did not keep the size rule. Use --%s for time based retention", retentionSizeFlag, retentionFlag)
+	if previous == nil {
+		previous = StreamRetentionData{}
+	}
+	if err := setRetention(client, name, previous); err != nil {
+		return fmt.Errorf("%w. Restoring the previous retention failed: %v", unsupported, err)
+	}
+	return unsupported
+}
+
+// setRetention replaces the retention rules of a stream
+func setRetention(client *internalHTTP.HTTPClient, name string, tasks StreamRetentionData) error {
+	body, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest(http.MethodPut, fmt.Sprintf("logstream/%s/retention", name), bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Request Failed\nStatus Code: %s\nResponse: %s", resp.Status, string(respBody))
+	}
+	return nil
+}
+
+// formatRetentionSize shows size in binary units when it was most likely
+// given in them, such as 500GiB, and in decimal units otherwise, such as 100GB
+func formatRetentionSize(size uint64) string {
+	if size%1024 == 0 {
+		return humanize.IBytes(size)
+	}
+	return humanize.Bytes(size)
+}
+
+// describeRetention summarizes retention rules for messages
+func describeRetention(duration string, size uint64) string {
+	switch {
+	case duration != "" && size > 0:
+		return fmt.Sprintf("%s or %s, whichever is reached first", duration, formatRetentionSize(size))
+	case size > 0:
+		return formatRetentionSize(size)
+	}
+	return duration
+}
+
+// SetRetentionStreamCmd sets the retention of an existing stream
+var SetRetentionStreamCmd = &cobra.Command{
+	Use:     "set-retention stream-name",
+	Example: "  pb stream set-retention backend_logs --retention=30d\n  pb stream set-retention backend_logs --retention=30d --retention-size=100GB",
+	Short:   "Set how long or how much data a stream keeps",
+	Long: `Set the retention of a stream, replacing its current retention rules.
+
+--retention deletes data older than a number of days, such as 30d.
+--retention-size deletes the oldest data once the stream is larger than a
+size such as 100GB or 1TiB. pb reads the retention back and fails, keeping
+the previous retention, when the server does not support size based
+retention. With both, data is deleted by whichever limit is reached first.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		name := args[0]
+		duration, size, err := retentionFromFlags(cmd)
+		if err == nil && duration == "" && size == 0 {
+			err = fmt.Errorf("pass --%s, --%s or both", retentionFlag, retentionSizeFlag)
+		}
+		if err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+		var previous StreamRetentionData
+		if size > 0 {
+			if previous, err = fetchRetention(&client, name); err != nil {
+				err = fmt.Errorf("failed to read the current retention: %w", err)
+				cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+				return err
+			}
+		}
+		if err := applyRetention(&client, name, duration, size, previous); err != nil {
+			cmd.Annotations["errors"] = fmt.Sprintf("Error: %s", err.Error())
+			return err
+		}
+		fmt.Printf("Set retention of %s to %s\n", StyleBold.Render(name), describeRetention(duration, size))
+		return nil
+	},
+}
+
+func init() {
+	SetRetentionStreamCmd.Flags().String(retentionFlag, "", "Delete data older than this many days, e.g. 30d")
+	SetRetentionStreamCmd.Flags().String(retentionSizeFlag, "", "Delete the oldest data once the stream is larger than this, e.g. 100GB")
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+func TestParseRetentionSize(t *testing.T) {
+	cases := map[string]uint64{
+		"100GB":  100 * 1000 * 1000 * 1000,
+		"2TB":    2 * 1000 * 1000 * 1000 * 1000,
+		"500GiB": 500 * 1024 * 1024 * 1024,
+		"1 TiB":  1024 * 1024 * 1024 * 1024,
+	}
+	for value, want := range cases {
+		if size, err := parseRetentionSize(value); err != nil || size != want {
+			t.Errorf("%s: expected %d, got %d, %v", value, want, size, err)
+		}
+	}
+	for _, value := range []string{"", "100", "lots", "0GB", "-5GB"} {
+		if _, err := parseRetentionSize(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+
+	if _, err := parseRetentionDuration("30d"); err != nil {
+		t.Errorf("expected 30d to be valid, got %v", err)
+	}
+	for _, value := range []string{"30", "0d", "2w", "24h"} {
+		if _, err := parseRetentionDuration(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+// retentionServer records the retention rules sent to the app stream and
+// returns them on GET. Unless keepsSize is set, it drops the size of each
+// rule, as a server without size based retention does
+func retentionServer(keepsSize bool, stored *[]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/logstream/app/retention" && r.Method == http.MethodGet:
+			var tasks StreamRetentionData
+			json.Unmarshal(*stored, &tasks)
+			if !keepsSize {
+				for idx := range tasks {
+					tasks[idx].Size = 0
+				}
+			}
+			json.NewEncoder(w).Encode(tasks)
+		case r.URL.Path == "/api/v1/logstream/app/retention" && r.Method == http.MethodPut:
+			*stored, _ = io.ReadAll(r.Body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func runSetRetention(t *testing.T, url string, args ...string) error {
+	t.Helper()
+	previous := DefaultProfile
+	DefaultProfile = config.Profile{URL: url}
+	t.Cleanup(func() { DefaultProfile = previous })
+	for _, name := range []string{retentionFlag, retentionSizeFlag} {
+		SetRetentionStreamCmd.Flags().Set(name, "")
+		SetRetentionStreamCmd.Flags().Lookup(name).Changed = false
+	}
+	SetRetentionStreamCmd.SetArgs(args)
+	SetRetentionStreamCmd.SetOut(io.Discard)
+	SetRetentionStreamCmd.SetErr(io.Discard)
+	return SetRetentionStreamCmd.Execute()
+}
+
+func TestSetRetentionSendsTimeAndSize(t *testing.T) {
+	var stored []byte
+	server := retentionServer(true, &stored)
+	defer server.Close()
+
+	if err := runSetRetention(t, server.URL, "app", "--retention=30d", "--retention-size=100GB"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []map[string]interface{}
+	if err := json.Unmarshal(stored, &tasks); err != nil {
+		t.Fatalf("request body is not a list of rules: %s", stored)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected a time and a size rule, got %s", stored)
+	}
+	if tasks[0]["action"] != "delete" || tasks[0]["duration"] != "30d" || tasks[0]["size"] != nil {
+		t.Errorf("unexpected time rule %v", tasks[0])
+	}
+	if tasks[1]["action"] != "delete" || tasks[1]["size"] != float64(100000000000) || tasks[1]["duration"] != nil {
+		t.Errorf("unexpected size rule %v", tasks[1])
+	}
+}
+
+func TestSizeRetentionRestoredWhenDropped(t *testing.T) {
+	previous := `[{"description":"delete data older than 90d","action":"delete","duration":"90d"}]`
+	stored := []byte(previous)
+	server := retentionServer(false, &stored)
+	defer server.Close()
+
+	err := runSetRetention(t, server.URL, "app", "--retention=30d", "--retention-size=100GB")
+	if err == nil || !strings.Contains(err.Error(), "does not support --retention-size") {
+		t.Errorf("expected a dropped size rule to fail, got %v", err)
+	}
+	if string(stored) != previous {
+		t.Errorf("expected the previous retention to be restored, got %s", stored)
+	}
+
+	// time based retention is not read back
+	if err := runSetRetention(t, server.URL, "app", "--retention=7d"); err != nil {
+		t.Fatal(err)
+	}
+	if string(stored) != `[{"description":"delete data older than 7d","action":"delete","duration":"7d"}]` {
+		t.Errorf("unexpected request body %s", stored)
+	}
+}
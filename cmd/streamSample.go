@@ -0,0 +1,107 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+)
+
+var showSampleFlag = "show-sample"
+
+// streamSample is the schema of a stream with its most recent records
+type streamSample struct {
+	Schema  []streamSampleField      `json:"schema"`
+	Records []map[string]interface{} `json:"records"`
+}
+
+// streamSampleField is one field of the schema shown with a sample
+type streamSampleField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// fetchStreamSample returns the schema of the stream and its n most recent
+// records, newest first
+func fetchStreamSample(client *internalHTTP.HTTPClient, name string, n int) (*streamSample, error) {
+	schema, err := fetchStreamSchema(client, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema: %w", err)
+	}
+	sample := &streamSample{Schema: make([]streamSampleField, 0, len(schema)), Records: []map[string]interface{}{}}
+	for _, field := range schema {
+		sample.Schema = append(sample.Schema, streamSampleField{Name: field.Name, Type: field.Type, Nullable: field.Nullable})
+	}
+	sort.Slice(sample.Schema, func(i, j int) bool { return sample.Schema[i].Name < sample.Schema[j].Name })
+
+	info, err := fetchStreamInfo(client, name)
+	if err != nil {
+		return nil, err
+	}
+	start := info.FirstEventAt
+	if start == "" {
+		start = info.CreatedAt
+	}
+	if start == "" {
+		// the stream has no events yet
+		return sample, nil
+	}
+	timeColumn := info.TimePartition
+	if timeColumn == "" {
+		timeColumn = defaultTimeColumn
+	}
+
+	query := fmt.Sprintf("select * from %s order by %s desc limit %d", quoteIdentifier(name), quoteIdentifier(timeColumn), n)
+	records, err := queryRecords(client, query, start, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sample records: %w", err)
+	}
+	if records != nil {
+		sample.Records = records
+	}
+	return sample, nil
+}
+
+// printStreamSample writes the schema as a field list and each record as a
+// line of JSON
+func printStreamSample(w io.Writer, sample *streamSample) error {
+	fmt.Fprintln(w, StyleBold.Render("Schema:"))
+	for _, field := range sample.Schema {
+		fmt.Fprintf(w, "  %-24s %s\n", field.Name, field.Type)
+	}
+	fmt.Fprintln(w)
+
+	if len(sample.Records) == 0 {
+		fmt.Fprintln(w, StyleBold.Render("No records in stream\n"))
+		return nil
+	}
+	fmt.Fprintln(w, StyleBold.Render(fmt.Sprintf("Sample (%d most recent):", len(sample.Records))))
+	for _, record := range sample.Records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
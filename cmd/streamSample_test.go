@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func TestFetchStreamSampleQueriesMostRecent(t *testing.T) {
+	var query map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/logstream/app/schema":
+			w.Write([]byte(appSchema))
+		case "/api/v1/logstream/app/info":
+			w.Write([]byte(`{"created-at":"2024-01-01T00:00:00Z","first-event-at":"2024-01-02T00:00:00Z"}`))
+		case "/api/v1/query":
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &query)
+			w.Write([]byte(`[{"host":"b","latency":12},{"host":"a","latency":7}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	sample, err := fetchStreamSample(&client, "app", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query["query"] != `select * from "app" order by "p_timestamp" desc limit 2` {
+		t.Errorf("unexpected sample query %q", query["query"])
+	}
+	if query["startTime"] != "2024-01-02T00:00:00Z" {
+		t.Errorf("expected the sample to start at the first event, got %q", query["startTime"])
+	}
+	if len(sample.Schema) != 2 || sample.Schema[0].Name != "host" || sample.Schema[1].Type != "Int64" {
+		t.Errorf("unexpected schema %+v", sample.Schema)
+	}
+	if len(sample.Records) != 2 || sample.Records[0]["host"] != "b" {
+		t.Errorf("unexpected records %v", sample.Records)
+	}
+
+	var out strings.Builder
+	if err := printStreamSample(&out, sample); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `{"host":"b","latency":12}`) {
+		t.Errorf("expected records as JSON lines, got:\n%s", out.String())
+	}
+}
+
+func TestFetchStreamSampleOfEmptyStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/logstream/app/schema":
+			w.Write([]byte(`{"fields":[],"metadata":{}}`))
+		case "/api/v1/logstream/app/info":
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	sample, err := fetchStreamSample(&client, "app", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.Records == nil || len(sample.Records) != 0 {
+		t.Errorf("expected an empty sample, got %v", sample.Records)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/manifoldco/promptui"
+	"golang.org/x/term"
+)
+
+var schemaFileFlag = "schema-file"
+
+// readSchemaFile reads a JSON schema from path, or from stdin when path is
+// -, so the output of pb schema generate can be piped in
+func readSchemaFile(path string, stdin io.Reader) ([]byte, error) {
+	var (
+		schema []byte
+		err    error
+	)
+	source := path
+	if path == "-" {
+		source = "stdin"
+		schema, err = io.ReadAll(stdin)
+	} else {
+		schema, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema from %s: %w", source, err)
+	}
+	if !json.Valid(schema) {
+		return nil, fmt.Errorf("schema from %s is not valid JSON. pb schema generate writes JSON unless --format yaml is passed", source)
+	}
+	return schema, nil
+}
+
+// schemaFieldCount returns the number of fields of a schema in the format of
+// pb schema generate, or -1 when the schema has no fields list
+func schemaFieldCount(schema []byte) int {
+	var parsed struct {
+		Fields []json.RawMessage `json:"fields"`
+	}
+	if err := json.Unmarshal(schema, &parsed); err != nil || parsed.Fields == nil {
+		return -1
+	}
+	return len(parsed.Fields)
+}
+
+// confirmStaticSchema asks before a stream is created with a static schema,
+// which cannot be changed afterwards. Without a terminal to ask on, --yes is
+// required
+func confirmStaticSchema(name string, schema []byte) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("a static schema cannot be changed once the stream is created, pass --%s to confirm when not running in a terminal", AssumeYesFlag)
+	}
+	fields := "the given schema"
+	if count := schemaFieldCount(schema); count >= 0 {
+		fields = fmt.Sprintf("a static schema of %d fields", count)
+	}
+	label := fmt.Sprintf("Create stream %s with %s? The schema cannot be changed later", name, fields)
+	if _, err := (&promptui.Prompt{Label: label, IsConfirm: true}).Run(); err != nil {
+		return fmt.Errorf("stream %s was not created", name)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+// schemaStreamServer records the body and static schema header of the
+// stream created with PUT
+func schemaStreamServer(t *testing.T) (*httptest.Server, *[]byte, *string) {
+	var body []byte
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/api/v1/logstream/web" {
+			body, _ = io.ReadAll(r.Body)
+			header = r.Header.Get("X-P-Static-Schema-Flag")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	return server, &body, &header
+}
+
+func runStreamAddWithStdin(t *testing.T, url string, stdin io.Reader, args ...string) error {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	defer func(profile config.Profile) { DefaultProfile = profile }(DefaultProfile)
+	DefaultProfile = config.Profile{URL: url}
+	defer func() {
+		for name, value := range map[string]string{schemaFileFlag: ""} {
+			AddStreamCmd.Flags().Set(name, value)
+			AddStreamCmd.Flags().Lookup(name).Changed = false
+		}
+		AddStreamCmd.SetArgs(nil)
+		AddStreamCmd.SetIn(nil)
+	}()
+
+	AddStreamCmd.SetArgs(args)
+	AddStreamCmd.SetIn(stdin)
+	AddStreamCmd.SilenceUsage = true
+	return AddStreamCmd.Execute()
+}
+
+func TestAddStreamReadsSchemaFromStdin(t *testing.T) {
+	server, body, header := schemaStreamServer(t)
+
+	// the schema as pb schema generate writes it to a pipe
+	generated, err := encodeSchema([]byte(`{"fields":[{"name":"host","data_type":"Utf8"},{"name":"status","data_type":"Int64"}]}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withAssumeYes(t)
+	if err := runStreamAddWithStdin(t, server.URL, bytes.NewReader(generated), "web", "--schema-file", "-"); err != nil {
+		t.Fatalf("expected stream add to succeed, got %v", err)
+	}
+	if !bytes.Equal(*body, generated) {
+		t.Errorf("expected the piped schema to be sent, got %s", *body)
+	}
+	if *header != "true" {
+		t.Errorf("expected the static schema header, got %q", *header)
+	}
+}
+
+func TestAddStreamSchemaFromStdinRequiresYes(t *testing.T) {
+	server, body, _ := schemaStreamServer(t)
+
+	err := runStreamAddWithStdin(t, server.URL, strings.NewReader(`{"fields":[]}`), "web", "--schema-file", "-")
+	if err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Fatalf("expected --yes to be required, got %v", err)
+	}
+	if *body != nil {
+		t.Error("expected no stream to be created")
+	}
+}
+
+func TestReadSchemaFileRejectsInvalidJSON(t *testing.T) {
+	_, err := readSchemaFile("-", strings.NewReader("fields:\n  - name: host\n"))
+	if err == nil || !strings.Contains(err.Error(), "stdin is not valid JSON") {
+		t.Errorf("expected a YAML schema to be rejected, got %v", err)
+	}
+}
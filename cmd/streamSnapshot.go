@@ -0,0 +1,296 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/dustin/go-humanize"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/pflag"
+)
+
+var (
+	saveSnapshotFlag = "save-snapshot"
+	compareWithFlag  = "compare-with"
+)
+
+// statSnapshot is the stream statistics saved with --save-snapshot
+type statSnapshot struct {
+	TakenAt time.Time       `json:"taken_at"`
+	Server  string          `json:"server"`
+	Streams []streamStatRow `json:"streams"`
+}
+
+// streamStatDelta is the growth of one stream since a snapshot. Status is
+// new for a stream created since, removed for one deleted since, and
+// existing otherwise
+type streamStatDelta struct {
+	Stream              string `json:"stream"`
+	Status              string `json:"status"`
+	EventCountBefore    int64  `json:"event_count_before"`
+	EventCount          int64  `json:"event_count"`
+	EventCountDelta     int64  `json:"event_count_delta"`
+	IngestionBytesDelta int64  `json:"ingestion_bytes_delta"`
+	StorageBytesBefore  int64  `json:"storage_bytes_before"`
+	StorageBytes        int64  `json:"storage_bytes"`
+	StorageBytesDelta   int64  `json:"storage_bytes_delta"`
+}
+
+// statComparison is the JSON output of --compare-with
+type statComparison struct {
+	Since   time.Time         `json:"since"`
+	Streams []streamStatDelta `json:"streams"`
+	Total   *streamStatDelta  `json:"total,omitempty"`
+}
+
+// validateSnapshotOptions rejects flags that do not apply to snapshots
+func validateSnapshotOptions(flags *pflag.FlagSet, output string) error {
+	for _, name := range []string{byPartitionFlag, showSampleFlag} {
+		if flags.Changed(name) {
+			return fmt.Errorf("--%s cannot be used with --%s or --%s", name, saveSnapshotFlag, compareWithFlag)
+		}
+	}
+	if !flags.Changed(compareWithFlag) {
+		return nil
+	}
+	if output == "csv" || output == "tsv" {
+		return fmt.Errorf("--%s only works with text or json output", compareWithFlag)
+	}
+	for _, name := range []string{maxSizeFlag, maxEventsFlag} {
+		if flags.Changed(name) {
+			return fmt.Errorf("--%s cannot be used with --%s", name, compareWithFlag)
+		}
+	}
+	return nil
+}
+
+// fetchStatRows returns the stats of every stream, or of the named one
+func fetchStatRows(client *internalHTTP.HTTPClient, all bool, name string) ([]streamStatRow, error) {
+	if all {
+		return fetchAllStreamStats(client)
+	}
+	stats, err := fetchStats(client, name)
+	if err != nil {
+		return nil, err
+	}
+	return []streamStatRow{newStreamStatRow(name, stats)}, nil
+}
+
+func readStatSnapshot(path string) (statSnapshot, error) {
+	var snapshot statSnapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("%s is not a stats snapshot: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+func writeStatSnapshot(path string, snapshot statSnapshot) error {
+	for idx := range snapshot.Streams {
+		snapshot.Streams[idx].Breached = nil
+		snapshot.Streams[idx].Breaches = nil
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// compareStatSnapshot computes the growth of each stream since before. Only
+// is set when a single stream is compared, so the other streams of the
+// snapshot are not reported as removed. Removed streams come last, sorted
+// by name
+func compareStatSnapshot(before []streamStatRow, now []streamStatRow, only string) []streamStatDelta {
+	previous := make(map[string]streamStatRow, len(before))
+	for _, row := range before {
+		previous[row.Stream] = row
+	}
+
+	deltas := make([]streamStatDelta, 0, len(now))
+	for _, row := range now {
+		old, existed := previous[row.Stream]
+		delete(previous, row.Stream)
+		delta := streamStatDelta{
+			Stream:              row.Stream,
+			Status:              "existing",
+			EventCountBefore:    old.EventCount,
+			EventCount:          row.EventCount,
+			EventCountDelta:     row.EventCount - old.EventCount,
+			IngestionBytesDelta: row.IngestionBytes - old.IngestionBytes,
+			StorageBytesBefore:  old.StorageBytes,
+			StorageBytes:        row.StorageBytes,
+			StorageBytesDelta:   row.StorageBytes - old.StorageBytes,
+		}
+		if !existed {
+			delta.Status = "new"
+		}
+		deltas = append(deltas, delta)
+	}
+
+	var removed []string
+	for name := range previous {
+		if only == "" || name == only {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		old := previous[name]
+		deltas = append(deltas, streamStatDelta{
+			Stream:              name,
+			Status:              "removed",
+			EventCountBefore:    old.EventCount,
+			EventCountDelta:     -old.EventCount,
+			IngestionBytesDelta: -old.IngestionBytes,
+			StorageBytesBefore:  old.StorageBytes,
+			StorageBytesDelta:   -old.StorageBytes,
+		})
+	}
+	return deltas
+}
+
+// totalStatDelta sums deltas into a totals row
+func totalStatDelta(deltas []streamStatDelta) streamStatDelta {
+	total := streamStatDelta{Stream: totalStreamName}
+	for _, delta := range deltas {
+		total.EventCountBefore += delta.EventCountBefore
+		total.EventCount += delta.EventCount
+		total.EventCountDelta += delta.EventCountDelta
+		total.IngestionBytesDelta += delta.IngestionBytesDelta
+		total.StorageBytesBefore += delta.StorageBytesBefore
+		total.StorageBytes += delta.StorageBytes
+		total.StorageBytesDelta += delta.StorageBytesDelta
+	}
+	return total
+}
+
+// printStatComparison renders the deltas as a table or as JSON
+func printStatComparison(w io.Writer, since time.Time, deltas []streamStatDelta, output string, total bool) error {
+	if output == "json" {
+		comparison := statComparison{Since: since, Streams: deltas}
+		if total {
+			totals := totalStatDelta(deltas)
+			comparison.Total = &totals
+		}
+		jsonData, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(jsonData))
+		return nil
+	}
+
+	fmt.Fprintf(w, "Changes since the snapshot of %s (%s)\n", since.Local().Format("2006-01-02 15:04 MST"), humanize.Time(since))
+	if len(deltas) == 0 {
+		fmt.Fprintln(w, "No streams found")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Stream", "Status", "Events", "Events Change", "Storage Size", "Storage Change", "Ingestion Change"})
+	if total {
+		deltas = append(deltas, totalStatDelta(deltas))
+	}
+	for _, delta := range deltas {
+		status := delta.Status
+		if delta.Stream == totalStreamName {
+			status = ""
+		}
+		table.Append([]string{
+			delta.Stream,
+			status,
+			strconv.FormatInt(delta.EventCount, 10),
+			signedCount(delta.EventCountDelta),
+			humanize.Bytes(uint64(delta.StorageBytes)),
+			signedBytes(delta.StorageBytesDelta),
+			signedBytes(delta.IngestionBytesDelta),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+func signedCount(value int64) string {
+	if value > 0 {
+		return "+" + humanize.Comma(value)
+	}
+	return humanize.Comma(value)
+}
+
+func signedBytes(value int64) string {
+	switch {
+	case value > 0:
+		return "+" + humanize.Bytes(uint64(value))
+	case value < 0:
+		return "-" + humanize.Bytes(uint64(-value))
+	}
+	return "0 B"
+}
+
+// runStatSnapshot compares the stats of every stream, or of the named one,
+// with a snapshot, saves them as a snapshot, or both. The snapshot to compare
+// with is read before the new one is saved, so both can be the same file
+func runStatSnapshot(client *internalHTTP.HTTPClient, name, savePath, comparePath, output string, total bool, thresholds statThresholds) error {
+	var before *statSnapshot
+	if comparePath != "" {
+		snapshot, err := readStatSnapshot(comparePath)
+		if err != nil {
+			return err
+		}
+		before = &snapshot
+		if snapshot.Server != "" && snapshot.Server != DefaultProfile.URL {
+			fmt.Fprintf(os.Stderr, "Warning: the snapshot was taken on %s, not on %s\n", snapshot.Server, DefaultProfile.URL)
+		}
+	}
+
+	rows, err := fetchStatRows(client, name == "", name)
+	if err != nil {
+		return err
+	}
+
+	if savePath != "" {
+		snapshot := statSnapshot{TakenAt: time.Now().UTC(), Server: DefaultProfile.URL, Streams: rows}
+		if err := writeStatSnapshot(savePath, snapshot); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Saved stats of %d stream(s) to %s\n", len(rows), savePath)
+	}
+
+	if before != nil {
+		return printStatComparison(os.Stdout, before.TakenAt, compareStatSnapshot(before.Streams, rows, name), output, total)
+	}
+	applyThresholds(rows, thresholds)
+	if err := printAllStreamStats(rows, output, total); err != nil {
+		return err
+	}
+	return reportBreaches(os.Stderr, rows)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	lastWeekSnapshot = `{
+  "taken_at": "2024-05-01T00:00:00Z",
+  "server": "http://localhost:8000",
+  "streams": [
+    {"stream": "backend", "event_count": 1000, "ingestion_bytes": 4000, "storage_bytes": 1000},
+    {"stream": "legacy", "event_count": 50, "ingestion_bytes": 200, "storage_bytes": 100}
+  ]
+}`
+	thisWeekSnapshot = `{
+  "taken_at": "2024-05-08T00:00:00Z",
+  "server": "http://localhost:8000",
+  "streams": [
+    {"stream": "backend", "event_count": 1500, "ingestion_bytes": 6000, "storage_bytes": 1400},
+    {"stream": "frontend", "event_count": 300, "ingestion_bytes": 900, "storage_bytes": 300}
+  ]
+}`
+)
+
+func readFixtureSnapshot(t *testing.T, content string) statSnapshot {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := readStatSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func TestCompareStatSnapshots(t *testing.T) {
+	before := readFixtureSnapshot(t, lastWeekSnapshot)
+	now := readFixtureSnapshot(t, thisWeekSnapshot)
+
+	deltas := compareStatSnapshot(before.Streams, now.Streams, "")
+	if len(deltas) != 3 {
+		t.Fatalf("expected 3 streams, got %+v", deltas)
+	}
+
+	backend, frontend, legacy := deltas[0], deltas[1], deltas[2]
+	if backend.Status != "existing" || backend.EventCountDelta != 500 || backend.StorageBytesDelta != 400 || backend.IngestionBytesDelta != 2000 {
+		t.Errorf("unexpected growth of backend: %+v", backend)
+	}
+	if frontend.Status != "new" || frontend.EventCountDelta != 300 || frontend.EventCountBefore != 0 {
+		t.Errorf("expected frontend to be new with all its events as growth, got %+v", frontend)
+	}
+	if legacy.Status != "removed" || legacy.EventCountDelta != -50 || legacy.StorageBytesDelta != -100 {
+		t.Errorf("expected legacy to be removed with negative growth, got %+v", legacy)
+	}
+
+	total := totalStatDelta(deltas)
+	if total.EventCountDelta != 750 || total.StorageBytesDelta != 600 {
+		t.Errorf("unexpected total growth: %+v", total)
+	}
+}
+
+func TestCompareSingleStreamIgnoresOtherStreams(t *testing.T) {
+	before := readFixtureSnapshot(t, lastWeekSnapshot)
+	now := readFixtureSnapshot(t, thisWeekSnapshot)
+
+	deltas := compareStatSnapshot(before.Streams, now.Streams[:1], "backend")
+	if len(deltas) != 1 || deltas[0].Stream != "backend" {
+		t.Errorf("expected only backend to be compared, got %+v", deltas)
+	}
+}
+
+func TestPrintStatComparison(t *testing.T) {
+	before := readFixtureSnapshot(t, lastWeekSnapshot)
+	now := readFixtureSnapshot(t, thisWeekSnapshot)
+	deltas := compareStatSnapshot(before.Streams, now.Streams, "")
+
+	var out strings.Builder
+	if err := printStatComparison(&out, before.TakenAt, deltas, "json", true); err != nil {
+		t.Fatal(err)
+	}
+	var comparison statComparison
+	if err := json.Unmarshal([]byte(out.String()), &comparison); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out.String())
+	}
+	if len(comparison.Streams) != 3 || comparison.Total == nil || comparison.Total.EventCountDelta != 750 {
+		t.Errorf("unexpected JSON comparison: %s", out.String())
+	}
+
+	out.Reset()
+	if err := printStatComparison(&out, before.TakenAt, deltas, "", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"+500", "+400 B", "new", "removed", "-50"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in table output, got:\n%s", expected, out.String())
+		}
+	}
+}
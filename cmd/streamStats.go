@@ -0,0 +1,240 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/dustin/go-humanize"
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	statAllFlag   = "all"
+	statTotalFlag = "total"
+
+	// totalStreamName labels the totals row. Stream names are lowercase, so it
+	// cannot clash with a real stream
+	totalStreamName = "TOTAL"
+
+	// streamStatColumns is the column order of csv and tsv output. Scripts and
+	// spreadsheets depend on it, so only ever add columns at the end
+	streamStatColumns = []string{"stream", "event_count", "ingestion_bytes", "storage_bytes", "compression_ratio"}
+)
+
+// streamStatRow is one stream in csv, tsv and --all output. Sizes are in
+// bytes and the compression ratio is the percentage saved by storage
+type streamStatRow struct {
+	Stream           string  `json:"stream"`
+	EventCount       int64   `json:"event_count"`
+	IngestionBytes   int64   `json:"ingestion_bytes"`
+	StorageBytes     int64   `json:"storage_bytes"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	// Breached tells whether the stream exceeds a --max-size or --max-events
+	// threshold, and is left out when neither is given. Breaches describes
+	// each one
+	Breached *bool    `json:"breached,omitempty"`
+	Breaches []string `json:"breaches,omitempty"`
+}
+
+func newStreamStatRow(name string, stats StreamStatsData) streamStatRow {
+	row := streamStatRow{
+		Stream:         name,
+		EventCount:     int64(stats.Ingestion.Count),
+		IngestionBytes: parseStatBytes(stats.Ingestion.Size),
+		StorageBytes:   parseStatBytes(stats.Storage.Size),
+	}
+	row.CompressionRatio = compressionRatio(row.IngestionBytes, row.StorageBytes)
+	return row
+}
+
+// parseStatBytes reads a size such as "1024 Bytes" from the stats API
+func parseStatBytes(size string) int64 {
+	value, _ := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(size), "Bytes")), 10, 64)
+	return value
+}
+
+// compressionRatio returns the percentage of the ingested size saved in
+// storage, rounded to two decimals, or 0 when nothing was ingested
+func compressionRatio(ingestion, storage int64) float64 {
+	if ingestion <= 0 {
+		return 0
+	}
+	ratio := 100 - float64(storage)/float64(ingestion)*100
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(ratio, 'f', 2, 64), 64)
+	return rounded
+}
+
+// totalStreamStats sums rows into a totals row. The compression ratio is
+// recomputed from the summed sizes rather than averaged
+func totalStreamStats(rows []streamStatRow) streamStatRow {
+	total := streamStatRow{Stream: totalStreamName}
+	for _, row := range rows {
+		total.EventCount += row.EventCount
+		total.IngestionBytes += row.IngestionBytes
+		total.StorageBytes += row.StorageBytes
+	}
+	total.CompressionRatio = compressionRatio(total.IngestionBytes, total.StorageBytes)
+	return total
+}
+
+// fetchAllStreamStats returns stats for every stream in the order the server
+// lists them. Streams whose stats cannot be fetched are skipped
+// with a warning so one broken stream does not hide the rest
+func fetchAllStreamStats(client *internalHTTP.HTTPClient) ([]streamStatRow, error) {
+	var rows []streamStatRow
+	var errs []error
+	err := forEachStreamStat(client, func(count int) {
+		rows, errs = make([]streamStatRow, count), make([]error, count)
+	}, func(idx int, row streamStatRow, err error) {
+		rows[idx], errs[idx] = row, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := make([]streamStatRow, 0, len(rows))
+	for idx, row := range rows {
+		if errs[idx] != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s, failed to fetch stats: %s\n", row.Stream, strings.TrimSpace(errs[idx].Error()))
+			continue
+		}
+		fetched = append(fetched, row)
+	}
+	return fetched, nil
+}
+
+// forEachStreamStat fetches the stats of every stream concurrently. start is
+// called once with the number of streams, then fetched is called for each
+// stream as soon as its stats arrive, with its index in the server's list.
+// Calls to fetched never overlap
+func forEachStreamStat(client *internalHTTP.HTTPClient, start func(count int), fetched func(idx int, row streamStatRow, err error)) error {
+	streams, err := fetchStreams(client)
+	if err != nil {
+		return err
+	}
+	start(len(streams))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statsConcurrency)
+	for idx, stream := range streams {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			stats, err := fetchStats(client, name)
+			mu.Lock()
+			defer mu.Unlock()
+			fetched(idx, newStreamStatRow(name, stats), err)
+		}(idx, stream.Name)
+	}
+	wg.Wait()
+	return nil
+}
+
+// writeStreamStats renders rows as csv or tsv with a header row. Numbers are
+// written bare so spreadsheets read them as numbers, not text
+func writeStreamStats(w io.Writer, rows []streamStatRow, format string, total bool) error {
+	writer := csv.NewWriter(w)
+	if format == "tsv" {
+		writer.Comma = '\t'
+	}
+
+	if total {
+		rows = append(rows, totalStreamStats(rows))
+	}
+
+	if err := writer.Write(streamStatColumns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			row.Stream,
+			strconv.FormatInt(row.EventCount, 10),
+			strconv.FormatInt(row.IngestionBytes, 10),
+			strconv.FormatInt(row.StorageBytes, 10),
+			strconv.FormatFloat(row.CompressionRatio, 'f', 2, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// printAllStreamStats renders the --all output in any supported format
+func printAllStreamStats(rows []streamStatRow, output string, total bool) error {
+	switch output {
+	case "csv", "tsv":
+		return writeStreamStats(os.Stdout, rows, output, total)
+	case "json":
+		if rows == nil {
+			rows = []streamStatRow{}
+		}
+		if total {
+			rows = append(rows, totalStreamStats(rows))
+		}
+		jsonData, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No streams found")
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Stream", "Events", "Ingestion Size", "Storage Size", "Compression Ratio"})
+	if total {
+		rows = append(rows, totalStreamStats(rows))
+	}
+	for _, row := range rows {
+		table.Append([]string{
+			row.Stream,
+			strconv.FormatInt(row.EventCount, 10),
+			humanize.Bytes(uint64(row.IngestionBytes)),
+			humanize.Bytes(uint64(row.StorageBytes)),
+			fmt.Sprintf("%.2f%%", row.CompressionRatio),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// validateStatOutput checks the output format of stream info
+func validateStatOutput(output string) error {
+	switch output {
+	case "", "text", "json", "csv", "tsv":
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q. Supported formats are text, json, csv and tsv", output)
+}
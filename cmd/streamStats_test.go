@@ -0,0 +1,65 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+var exportRows = []streamStatRow{
+	{Stream: "backend", EventCount: 1200, IngestionBytes: 4000, StorageBytes: 1000, CompressionRatio: 75},
+	{Stream: "frontend", EventCount: 300, IngestionBytes: 1000, StorageBytes: 1000, CompressionRatio: 0},
+}
+
+func TestWriteStreamStatsCSVWithTotal(t *testing.T) {
+	var out strings.Builder
+	if err := writeStreamStats(&out, exportRows, "csv", true); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stream,event_count,ingestion_bytes,storage_bytes,compression_ratio\n" +
+		"backend,1200,4000,1000,75.00\n" +
+		"frontend,300,1000,1000,0.00\n" +
+		"TOTAL,1500,5000,2000,60.00\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteStreamStatsTSV(t *testing.T) {
+	var out strings.Builder
+	if err := writeStreamStats(&out, exportRows[:1], "tsv", false); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row, got:\n%s", out.String())
+	}
+	if lines[1] != "backend\t1200\t4000\t1000\t75.00" {
+		t.Errorf("unexpected tsv row %q", lines[1])
+	}
+}
+
+func TestParseStatBytes(t *testing.T) {
+	if size := parseStatBytes("2048 Bytes"); size != 2048 {
+		t.Errorf("expected 2048, got %d", size)
+	}
+	if ratio := compressionRatio(0, 0); ratio != 0 {
+		t.Errorf("expected no ratio without ingestion, got %v", ratio)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	maxSizeFlag   = "max-size"
+	maxEventsFlag = "max-events"
+
+	// thresholdExitCode is the exit code of stream info when a stream
+	// exceeds a threshold, so scripts can tell breaches from failures
+	thresholdExitCode = 2
+)
+
+// ExitCodeError is returned by commands that need an exit code other than 1
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
+// statThresholds are the capacity limits checked by stream info, zero
+// disables a limit
+type statThresholds struct {
+	maxSize   int64
+	maxEvents int64
+}
+
+// parseStatThresholds reads the threshold flags. Sizes accept units such as
+// 500MB or 10GiB, a bare number is in bytes
+func parseStatThresholds(cmd *cobra.Command) (statThresholds, error) {
+	var thresholds statThresholds
+	if size, _ := cmd.Flags().GetString(maxSizeFlag); size != "" {
+		bytes, err := humanize.ParseBytes(size)
+		if err != nil || bytes == 0 {
+			return thresholds, fmt.Errorf("invalid --%s %q, use a size such as 500MB or 10GiB", maxSizeFlag, size)
+		}
+		thresholds.maxSize = int64(bytes)
+	}
+	thresholds.maxEvents, _ = cmd.Flags().GetInt64(maxEventsFlag)
+	if thresholds.maxEvents < 0 {
+		return thresholds, fmt.Errorf("--%s must not be negative", maxEventsFlag)
+	}
+	return thresholds, nil
+}
+
+func (t statThresholds) enabled() bool {
+	return t.maxSize > 0 || t.maxEvents > 0
+}
+
+// check returns a description of each threshold the stream exceeds. The size
+// threshold applies to the storage size, which is what the stream costs
+func (t statThresholds) check(row streamStatRow) []string {
+	var breaches []string
+	if t.maxSize > 0 && row.StorageBytes > t.maxSize {
+		breaches = append(breaches, fmt.Sprintf("storage size %s exceeds --%s %s", humanize.Bytes(uint64(row.StorageBytes)), maxSizeFlag, humanize.Bytes(uint64(t.maxSize))))
+	}
+	if t.maxEvents > 0 && row.EventCount > t.maxEvents {
+		breaches = append(breaches, fmt.Sprintf("event count %d exceeds --%s %d", row.EventCount, maxEventsFlag, t.maxEvents))
+	}
+	return breaches
+}
+
+// mark records on the row whether it exceeds a threshold. Rows are left
+// unmarked when no threshold is set
+func (t statThresholds) mark(row *streamStatRow) {
+	if !t.enabled() {
+		return
+	}
+	row.Breaches = t.check(*row)
+	breached := len(row.Breaches) > 0
+	row.Breached = &breached
+}
+
+// applyThresholds marks the rows that exceed a threshold
+func applyThresholds(rows []streamStatRow, thresholds statThresholds) {
+	for idx := range rows {
+		thresholds.mark(&rows[idx])
+	}
+}
+
+// reportBreaches prints every breached threshold and returns the error that
+// makes the command exit with thresholdExitCode, or nil when all streams are
+// within their thresholds
+func reportBreaches(w io.Writer, rows []streamStatRow) error {
+	breached := 0
+	for _, row := range rows {
+		for _, breach := range row.Breaches {
+			fmt.Fprintf(w, "%s: %s\n", row.Stream, breach)
+		}
+		if len(row.Breaches) > 0 {
+			breached++
+		}
+	}
+	if breached == 0 {
+		return nil
+	}
+	return &ExitCodeError{Code: thresholdExitCode, Err: fmt.Errorf("%d of %d streams exceeded a threshold", breached, len(rows))}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func thresholdRows() []streamStatRow {
+	return append([]streamStatRow(nil), exportRows...)
+}
+
+func TestThresholdsUnderLimit(t *testing.T) {
+	rows := thresholdRows()
+	applyThresholds(rows, statThresholds{maxSize: 1000, maxEvents: 1200})
+
+	for _, row := range rows {
+		if row.Breached == nil || *row.Breached {
+			t.Errorf("expected %s at its thresholds to pass, got %v", row.Stream, row.Breaches)
+		}
+	}
+	var out strings.Builder
+	if err := reportBreaches(&out, rows); err != nil || out.Len() != 0 {
+		t.Errorf("expected no breaches, got %v: %s", err, out.String())
+	}
+}
+
+func TestThresholdsOverLimit(t *testing.T) {
+	rows := thresholdRows()
+	applyThresholds(rows, statThresholds{maxSize: 999, maxEvents: 500})
+
+	if len(rows[0].Breaches) != 2 || len(rows[1].Breaches) != 1 {
+		t.Fatalf("expected size and event breaches for backend and a size breach for frontend, got %v and %v", rows[0].Breaches, rows[1].Breaches)
+	}
+
+	var out strings.Builder
+	err := reportBreaches(&out, rows)
+	var exitErr *ExitCodeError
+	if !errors.As(err, &exitErr) || exitErr.Code != thresholdExitCode {
+		t.Fatalf("expected exit code %d, got %v", thresholdExitCode, err)
+	}
+	if !strings.Contains(err.Error(), "2 of 2 streams") {
+		t.Errorf("unexpected error %q", err)
+	}
+
+	report := out.String()
+	for _, line := range []string{
+		"backend: storage size 1.0 kB exceeds --max-size 999 B",
+		"backend: event count 1200 exceeds --max-events 500",
+		"frontend: storage size 1.0 kB exceeds --max-size 999 B",
+	} {
+		if !strings.Contains(report, line) {
+			t.Errorf("expected %q in report, got:\n%s", line, report)
+		}
+	}
+}
+
+func TestThresholdsInJSON(t *testing.T) {
+	rows := thresholdRows()
+	applyThresholds(rows, statThresholds{maxEvents: 500})
+
+	encoded, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded[0]["breached"] != true || decoded[1]["breached"] != false {
+		t.Errorf("expected a breached field per stream, got %s", encoded)
+	}
+	if _, ok := decoded[1]["breaches"]; ok {
+		t.Errorf("expected no breaches for a stream within its thresholds, got %s", encoded)
+	}
+
+	// without thresholds there is nothing to report
+	rows = thresholdRows()
+	applyThresholds(rows, statThresholds{})
+	if encoded, _ = json.Marshal(rows); strings.Contains(string(encoded), "breached") {
+		t.Errorf("expected no breached field without thresholds, got %s", encoded)
+	}
+}
+
+func TestParseStatThresholds(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String(maxSizeFlag, "", "")
+	cmd.Flags().Int64(maxEventsFlag, 0, "")
+
+	if thresholds, err := parseStatThresholds(cmd); err != nil || thresholds.enabled() {
+		t.Errorf("expected thresholds to be off by default, got %+v, %v", thresholds, err)
+	}
+
+	cmd.Flags().Set(maxSizeFlag, "2GiB")
+	thresholds, err := parseStatThresholds(cmd)
+	if err != nil || thresholds.maxSize != 2<<30 {
+		t.Errorf("expected 2GiB in bytes, got %d, %v", thresholds.maxSize, err)
+	}
+
+	cmd.Flags().Set(maxSizeFlag, "lots")
+	if _, err := parseStatThresholds(cmd); err == nil {
+		t.Error("expected an invalid size to be rejected")
+	}
+}
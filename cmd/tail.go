@@ -19,35 +19,93 @@ package cmd
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
 	"pb/pkg/analytics"
 	"pb/pkg/config"
 	internalHTTP "pb/pkg/http"
+	"strings"
+	"time"
 
 	"github.com/apache/arrow/go/v13/arrow/array"
 	"github.com/apache/arrow/go/v13/arrow/flight"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	sinceFlag         = "since"
+	maxReconnectsFlag = "max-reconnects"
+	tailCountFlag     = "count"
+
+	// tailBaseBackoff and tailMaxBackoff bound the wait between reconnects
+	tailBaseBackoff = time.Second
+	tailMaxBackoff  = 30 * time.Second
 )
 
 var TailCmd = &cobra.Command{
 	Use:     "tail stream-name",
-	Example: " pb tail backend_logs",
+	Example: " pb tail backend_logs\n pb tail backend_logs --since=15m\n pb tail backend_logs --max-reconnects=5\n pb tail backend_logs --count=10\n pb tail backend_logs --running-agg=count --running-agg=sum:bytes\n pb tail backend_logs --output-file=capture.ndjson --rotate=1h --keep=24",
 	Short:   "Stream live events from a log stream",
+	Long:    "\nStream live events from a log stream. When the connection drops, pb reconnects with exponential backoff and fetches the events missed while disconnected, so the output has no gaps or duplicates.\n\nWith --running-agg, pb keeps running totals over the events printed so far, count or sum:field, shown in a status line on stderr. The final totals are printed when the tail stops, including on Ctrl-C.\n\nWith --output-file, events are written to a file instead of stdout. Add --rotate to start a new file, named after the start of the period, every period, and --keep to remove all but the newest rotated files.",
 	Args:    cobra.ExactArgs(1),
 	PreRunE: PreRunDefaultProfile,
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		profile := DefaultProfile
-		return tail(profile, name)
+		since, _ := cmd.Flags().GetDuration(sinceFlag)
+		maxReconnects, _ := cmd.Flags().GetInt(maxReconnectsFlag)
+		count, _ := cmd.Flags().GetInt(tailCountFlag)
+		if count < 0 {
+			return fmt.Errorf("--%s must be 0 or more, got %d", tailCountFlag, count)
+		}
+		var totals *runningTotals
+		if aggs, _ := cmd.Flags().GetStringArray(runningAggFlag); len(aggs) > 0 {
+			var err error
+			if totals, err = parseRunningAggs(aggs); err != nil {
+				return err
+			}
+			if term.IsTerminal(int(os.Stderr.Fd())) {
+				totals.status = os.Stderr
+			}
+		}
+
+		outputFile, _ := cmd.Flags().GetString(tailOutputFileFlag)
+		rotate, _ := cmd.Flags().GetDuration(tailRotateFlag)
+		keep, _ := cmd.Flags().GetInt(tailKeepFlag)
+		if err := validateTailOutputOptions(outputFile, rotate, keep); err != nil {
+			return err
+		}
+		var out io.Writer = os.Stdout
+		if outputFile != "" {
+			file := newRotatingFile(outputFile, rotate, keep)
+			defer file.Close()
+			out = file
+		}
+		return tail(profile, name, since, maxReconnects, count, totals, out)
 	},
 }
 
-func tail(profile config.Profile, stream string) error {
+func init() {
+	TailCmd.Flags().Duration(sinceFlag, 0, "Print events from this long ago, e.g. 15m, before following live events")
+	TailCmd.Flags().Int(maxReconnectsFlag, -1, "Give up after this many reconnect attempts, -1 for unlimited and 0 to never reconnect")
+	TailCmd.Flags().Int(tailCountFlag, 0, "Exit after printing this many events, 0 to follow until interrupted")
+	TailCmd.Flags().StringArray(runningAggFlag, nil, "Keep a running total over the printed events, count or sum:field, shown live on stderr. Can be repeated")
+	TailCmd.Flags().String(tailOutputFileFlag, "", "Write events to this file instead of stdout, one JSON object per line")
+	TailCmd.Flags().Duration(tailRotateFlag, 0, "Start a new timestamped output file every period, e.g. 1h. Requires --output-file")
+	TailCmd.Flags().Int(tailKeepFlag, 0, "Keep only this many rotated files, removing the oldest. 0 keeps all")
+}
+
+func tail(profile config.Profile, stream string, since time.Duration, maxReconnects, count int, totals *runningTotals, out io.Writer) error {
 	payload, _ := json.Marshal(struct {
 		Stream string `json:"stream"`
 	}{
@@ -62,37 +120,329 @@ func tail(profile config.Profile, stream string) error {
 	}
 	url := profile.GrpcAddr(fmt.Sprint(about.GRPCPort))
 
-	client, err := flight.NewClientWithMiddleware(url, nil, nil, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return err
+	connect := func() (func() ([]string, error), func(), error) {
+		// the gRPC connection does not go through the HTTP transport, which
+		// is what refuses requests in offline mode
+		if err := internalHTTP.CheckOnline(); err != nil {
+			return nil, nil, err
+		}
+		client, err := flight.NewClientWithMiddleware(url, nil, nil, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		auth, err := internalHTTP.AuthHeader(&profile)
+		if err != nil {
+			client.Close()
+			return nil, nil, err
+		}
+		resp, err := client.DoGet(metadata.NewOutgoingContext(context.Background(), metadata.New(map[string]string{"Authorization": auth})), &flight.Ticket{
+			Ticket: payload,
+		})
+		if err != nil {
+			client.Close()
+			return nil, nil, err
+		}
+
+		records, err := flight.NewRecordReader(resp)
+		if err != nil {
+			client.Close()
+			return nil, nil, err
+		}
+
+		next := func() ([]string, error) {
+			record, err := records.Read()
+			if err != nil {
+				return nil, err
+			}
+			var buf bytes.Buffer
+			array.RecordToJSON(record, &buf)
+			return strings.Split(strings.TrimSpace(buf.String()), "\n"), nil
+		}
+		closeFn := func() {
+			records.Release()
+			client.Close()
+		}
+		return next, closeFn, nil
 	}
 
-	authHeader := basicAuth(profile.Username, profile.Password)
-	resp, err := client.DoGet(metadata.NewOutgoingContext(context.Background(), metadata.New(map[string]string{"Authorization": "Basic " + authHeader})), &flight.Ticket{
-		Ticket: payload,
-	})
-	if err != nil {
-		return err
+	backfill := func(from time.Time) ([]map[string]interface{}, error) {
+		query := fmt.Sprintf("select * from %s order by p_timestamp", stream)
+		return queryRecords(&httpClient, query, from.UTC().Format(time.RFC3339Nano), time.Now().UTC().Format(time.RFC3339Nano))
+	}
+
+	follower := &tailFollower{
+		connect:       connect,
+		backfill:      backfill,
+		out:           out,
+		notices:       os.Stderr,
+		maxReconnects: maxReconnects,
+		count:         count,
+		totals:        totals,
+		sleep:         time.Sleep,
+	}
+	if totals == nil {
+		return follower.run(since)
+	}
+
+	// print the totals on Ctrl-C instead of dying with the process
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+
+	result := make(chan error, 1)
+	go func() { result <- follower.run(since) }()
+	select {
+	case err = <-result:
+	case <-interrupted:
+		err = nil
 	}
+	totals.final(os.Stderr)
+	return err
+}
 
-	records, err := flight.NewRecordReader(resp)
+// tailFollower prints a live feed and keeps it going across dropped
+// connections. The live feed has no resume token, so after reconnecting the
+// events missed while disconnected are fetched with a query starting at the
+// timestamp of the last printed event
+type tailFollower struct {
+	// connect subscribes to the live feed and returns a function reading the
+	// next batch of events as JSON lines, and one closing the subscription
+	connect func() (next func() ([]string, error), closeFn func(), err error)
+	// backfill returns the events from the given time until now, oldest first
+	backfill func(from time.Time) ([]map[string]interface{}, error)
+
+	out, notices  io.Writer
+	maxReconnects int
+	// count stops the tail once that many events are printed, 0 follows
+	// until the feed gives up
+	count   int
+	printed int
+	// totals is updated with every printed event when --running-agg is set
+	totals *runningTotals
+	sleep  func(time.Duration)
+
+	// lastTime is the timestamp of the newest printed event, and boundary
+	// holds the fingerprints of the printed events with that timestamp, which
+	// the backfill after a reconnect returns again
+	lastTime time.Time
+	boundary map[string]bool
+}
+
+func (f *tailFollower) run(since time.Duration) error {
+	next, closeFn, err := f.connect()
 	if err != nil {
 		return err
 	}
-	defer records.Release()
 
+	// the live feed is already subscribed, so events ingested while the
+	// history is fetched are buffered there and deduplicated below
+	var deduper *tailDeduper
+	if since > 0 {
+		history, err := f.backfill(time.Now().Add(-since))
+		if err != nil {
+			closeFn()
+			return fmt.Errorf("failed to fetch events since %s: %w", since, err)
+		}
+		for _, record := range history {
+			if f.done() {
+				break
+			}
+			f.printRecord(record)
+		}
+		if f.done() {
+			closeFn()
+			return nil
+		}
+		deduper = newTailDeduper(history)
+	}
+
+	reconnects := 0
+	backoff := tailBaseBackoff
+	for {
+		received, err := f.follow(next, deduper)
+		closeFn()
+		if f.done() {
+			return nil
+		}
+		if received {
+			backoff = tailBaseBackoff
+		}
+
+		for {
+			if permanentTailError(err) {
+				return err
+			}
+			if f.maxReconnects >= 0 && reconnects >= f.maxReconnects {
+				return err
+			}
+			reconnects++
+			fmt.Fprintf(f.notices, "tail: connection lost (%v), reconnecting in %s\n", err, backoff)
+			f.sleep(backoff)
+			backoff = min(backoff*2, tailMaxBackoff)
+
+			if next, closeFn, err = f.connect(); err == nil {
+				break
+			}
+		}
+
+		deduper = nil
+		if f.lastTime.IsZero() {
+			continue
+		}
+		missed, err := f.backfill(f.lastTime)
+		if err != nil {
+			fmt.Fprintf(f.notices, "tail: could not fetch events missed while disconnected: %v\n", err)
+			continue
+		}
+		for _, record := range missed {
+			if !f.done() && !f.boundary[eventFingerprint(record)] {
+				f.printRecord(record)
+			}
+		}
+		if f.done() {
+			closeFn()
+			return nil
+		}
+		deduper = newTailDeduper(missed)
+	}
+}
+
+// permanentTailError reports whether the feed failed in a way that
+// reconnecting does not fix, such as rejected credentials, missing
+// permissions, a stream that does not exist or offline mode
+func permanentTailError(err error) bool {
+	if errors.Is(err, internalHTTP.ErrOffline) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied, codes.NotFound, codes.InvalidArgument:
+		return true
+	}
+	return false
+}
+
+// follow prints live events until the feed fails or count events are
+// printed, and reports whether any batch was received
+func (f *tailFollower) follow(next func() ([]string, error), deduper *tailDeduper) (bool, error) {
+	received := false
 	for {
-		record, err := records.Read()
+		lines, err := next()
 		if err != nil {
-			return err
+			return received, err
+		}
+		received = true
+		for _, line := range lines {
+			if line == "" || (deduper != nil && deduper.seen(line)) {
+				continue
+			}
+			var record map[string]interface{}
+			json.Unmarshal([]byte(line), &record)
+			f.print(line, record)
+			if f.done() {
+				return received, nil
+			}
 		}
-		var buf bytes.Buffer
-		array.RecordToJSON(record, &buf)
-		fmt.Println(buf.String())
 	}
 }
 
-func basicAuth(username, password string) string {
-	auth := username + ":" + password
-	return base64.StdEncoding.EncodeToString([]byte(auth))
+// done reports whether the requested number of events has been printed
+func (f *tailFollower) done() bool {
+	return f.count > 0 && f.printed >= f.count
+}
+
+func (f *tailFollower) printRecord(record map[string]interface{}) {
+	line, _ := json.Marshal(record)
+	f.print(string(line), record)
+}
+
+// print writes an event and moves the resume position to its timestamp
+func (f *tailFollower) print(line string, record map[string]interface{}) {
+	if f.totals != nil {
+		f.totals.clear()
+	}
+	fmt.Fprintln(f.out, line)
+	f.printed++
+	if f.totals != nil {
+		f.totals.add(record)
+	}
+
+	value, _ := record[defaultTimeColumn].(string)
+	ts, ok := parseEventTime(value)
+	if !ok {
+		return
+	}
+	switch {
+	case ts.After(f.lastTime):
+		f.lastTime = ts
+		f.boundary = map[string]bool{eventFingerprint(record): true}
+	case ts.Equal(f.lastTime):
+		f.boundary[eventFingerprint(record)] = true
+	}
+}
+
+// tailDeduper remembers the events printed from history so that the same
+// events arriving on the live feed at the handoff are not printed twice
+type tailDeduper struct {
+	fingerprints map[string]int
+}
+
+func newTailDeduper(history []map[string]interface{}) *tailDeduper {
+	d := &tailDeduper{fingerprints: make(map[string]int, len(history))}
+	for _, record := range history {
+		d.fingerprints[eventFingerprint(record)]++
+	}
+	return d
+}
+
+// seen reports whether a live event, as a JSON line, was already printed
+// from history. Each history event suppresses at most one live event
+func (d *tailDeduper) seen(line string) bool {
+	if len(d.fingerprints) == 0 {
+		return false
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		return false
+	}
+	key := eventFingerprint(record)
+	if d.fingerprints[key] == 0 {
+		return false
+	}
+	d.fingerprints[key]--
+	if d.fingerprints[key] == 0 {
+		delete(d.fingerprints, key)
+	}
+	return true
+}
+
+// eventFingerprint identifies an event independently of key order and of
+// the timestamp layout, which differs between query results and the live feed
+func eventFingerprint(record map[string]interface{}) string {
+	normalized := make(map[string]interface{}, len(record))
+	for key, value := range record {
+		if str, ok := value.(string); ok {
+			if ts, ok := parseEventTime(str); ok {
+				value = ts.Format(time.RFC3339Nano)
+			}
+		}
+		normalized[key] = value
+	}
+	fingerprint, _ := json.Marshal(normalized)
+	return string(fingerprint)
+}
+
+var eventTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func parseEventTime(value string) (time.Time, bool) {
+	for _, layout := range eventTimeLayouts {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts.UTC(), true
+		}
+	}
+	return time.Time{}, false
 }
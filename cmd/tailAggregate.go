@@ -0,0 +1,122 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var runningAggFlag = "running-agg"
+
+// runningTotals keeps the --running-agg values over every event printed by
+// pb tail
+type runningTotals struct {
+	aggs []aggregate
+	// status is where the live status line is redrawn, nil to only report
+	// the final totals
+	status io.Writer
+
+	mu    sync.Mutex
+	count int64
+	sums  []float64
+}
+
+// parseRunningAggs parses --running-agg values, count or sum:field
+func parseRunningAggs(values []string) (*runningTotals, error) {
+	totals := &runningTotals{}
+	for _, value := range values {
+		function, field, _ := strings.Cut(strings.TrimSpace(value), ":")
+		switch strings.ToLower(function) {
+		case "count":
+			if field != "" {
+				return nil, fmt.Errorf("--%s count takes no field, got %q", runningAggFlag, value)
+			}
+		case "sum":
+			if field == "" {
+				return nil, fmt.Errorf("--%s sum needs a field, e.g. sum:bytes", runningAggFlag)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported --%s %q, use count or sum:field", runningAggFlag, value)
+		}
+		totals.aggs = append(totals.aggs, aggregate{function: strings.ToLower(function), field: field})
+	}
+	totals.sums = make([]float64, len(totals.aggs))
+	return totals, nil
+}
+
+// add counts an event and redraws the status line
+func (r *runningTotals) add(record map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.count++
+	for idx, agg := range r.aggs {
+		if agg.function == "sum" {
+			// events without a numeric value for the field add nothing
+			r.sums[idx] += numericValue(record[agg.field])
+		}
+	}
+	if r.status != nil {
+		fmt.Fprintf(r.status, "\r\033[K%s", r.line())
+	}
+}
+
+// clear removes the status line so an event can be printed in its place
+func (r *runningTotals) clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status != nil && r.count > 0 {
+		fmt.Fprint(r.status, "\r\033[K")
+	}
+}
+
+// final writes the totals on a line of their own
+func (r *runningTotals) final(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status != nil && r.count > 0 {
+		fmt.Fprint(r.status, "\r\033[K")
+	}
+	fmt.Fprintf(w, "Total: %s\n", r.line())
+}
+
+func (r *runningTotals) line() string {
+	parts := make([]string, len(r.aggs))
+	for idx, agg := range r.aggs {
+		if agg.function == "count" {
+			parts[idx] = "count=" + strconv.FormatInt(r.count, 10)
+		} else {
+			parts[idx] = fmt.Sprintf("sum(%s)=%s", agg.field, strconv.FormatFloat(r.sums[idx], 'f', -1, 64))
+		}
+	}
+	return strings.Join(parts, "  ")
+}
+
+// numericValue returns a JSON number, or a string holding one, as a float
+func numericValue(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		number, _ := strconv.ParseFloat(v, 64)
+		return number
+	}
+	return 0
+}
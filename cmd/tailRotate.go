@@ -0,0 +1,170 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	tailOutputFileFlag = "output-file"
+	tailRotateFlag     = "rotate"
+	tailKeepFlag       = "keep"
+
+	// rotatedFileLayout is the timestamp in the names of rotated files. It
+	// sorts in time order
+	rotatedFileLayout = "20060102T150405Z"
+)
+
+// validateTailOutputOptions checks the output file flags of pb tail
+func validateTailOutputOptions(path string, rotate time.Duration, keep int) error {
+	if rotate < 0 {
+		return fmt.Errorf("--%s must be a positive duration, got %s", tailRotateFlag, rotate)
+	}
+	if keep < 0 {
+		return fmt.Errorf("--%s must be 0 or more, got %d", tailKeepFlag, keep)
+	}
+	if rotate > 0 && path == "" {
+		return fmt.Errorf("--%s requires --%s", tailRotateFlag, tailOutputFileFlag)
+	}
+	if keep > 0 && rotate == 0 {
+		return fmt.Errorf("--%s requires --%s", tailKeepFlag, tailRotateFlag)
+	}
+	return nil
+}
+
+// rotatingFile writes tailed events to a file. With a rotation period, a new
+// file named after the start of the period is started when the period ends.
+// Every event is a single write, so rotation happens between events and none
+// are split or dropped at the boundary
+type rotatingFile struct {
+	path  string
+	every time.Duration
+	// keep is the number of rotated files left in place, 0 keeps all
+	keep  int
+	now   func() time.Time
+	prune func(path string, keep int) error
+	// warnings receives failures to remove old files, which do not stop
+	// the capture
+	warnings io.Writer
+
+	file *os.File
+	// periodEnd is when the current file is rotated
+	periodEnd time.Time
+}
+
+func newRotatingFile(path string, every time.Duration, keep int) *rotatingFile {
+	return &rotatingFile{path: path, every: every, keep: keep, now: time.Now, prune: pruneRotatedFiles, warnings: os.Stderr}
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.file == nil || (r.every > 0 && !r.now().Before(r.periodEnd)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	return r.file.Write(p)
+}
+
+// rotate closes the current file and opens the file for the current period.
+// Existing files are appended to, so a restarted capture continues its file
+func (r *rotatingFile) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return fmt.Errorf("failed to close output file: %w", err)
+		}
+		r.file = nil
+	}
+
+	path := r.path
+	if r.every > 0 {
+		start := r.now().UTC().Truncate(r.every)
+		r.periodEnd = start.Add(r.every)
+		path = rotatedFilePath(r.path, start)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	r.file = file
+
+	// the event is still written when old files cannot be removed
+	if r.keep > 0 {
+		if err := r.prune(r.path, r.keep); err != nil {
+			fmt.Fprintf(r.warnings, "Warning: %s, older files are kept\n", err)
+		}
+	}
+	return nil
+}
+
+func (r *rotatingFile) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// rotatedFilePath returns the name of the file for the period starting at
+// start, with the timestamp before the extension: capture.ndjson becomes
+// capture-20240501T100000Z.ndjson
+func rotatedFilePath(path string, start time.Time) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), start.UTC().Format(rotatedFileLayout), ext)
+}
+
+// pruneRotatedFiles removes all but the newest keep rotated files of path.
+// Only files named by rotatedFilePath are considered
+func pruneRotatedFiles(path string, keep int) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list rotated files: %w", err)
+	}
+	var rotated []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if _, err := time.Parse(rotatedFileLayout, stamp); err == nil {
+			rotated = append(rotated, name)
+		}
+	}
+	if len(rotated) <= keep {
+		return nil
+	}
+
+	sort.Strings(rotated)
+	for _, name := range rotated[:len(rotated)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to remove rotated file: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock tests move forward by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func rotatedFiles(t *testing.T, dir string) map[string]string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		data, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func TestRotatingFileStartsNewFileEachPeriod(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 10, 59, 58, 0, time.UTC)}
+	file := newRotatingFile(filepath.Join(dir, "capture.ndjson"), time.Hour, 0)
+	file.now = clock.Now
+
+	for idx := 1; idx <= 6; idx++ {
+		if _, err := fmt.Fprintln(file, fmt.Sprintf(`{"n":%d}`, idx)); err != nil {
+			t.Fatal(err)
+		}
+		// events 3 and 5 are the first after an hour boundary
+		if idx == 2 || idx == 4 {
+			clock.advance(time.Hour)
+		}
+	}
+	file.Close()
+
+	want := map[string]string{
+		"capture-20240501T100000Z.ndjson": "{\"n\":1}\n{\"n\":2}\n",
+		"capture-20240501T110000Z.ndjson": "{\"n\":3}\n{\"n\":4}\n",
+		"capture-20240501T120000Z.ndjson": "{\"n\":5}\n{\"n\":6}\n",
+	}
+	got := rotatedFiles(t, dir)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d files, got %v", len(want), got)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s: expected %q, got %q", name, content, got[name])
+		}
+	}
+}
+
+func TestRotatingFileKeepsNewestFiles(t *testing.T) {
+	dir := t.TempDir()
+	// a file that is not a rotated file of the capture is left alone
+	os.WriteFile(filepath.Join(dir, "capture-notes.ndjson"), []byte("keep me"), 0o644)
+
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	file := newRotatingFile(filepath.Join(dir, "capture.ndjson"), time.Hour, 2)
+	file.now = clock.Now
+	for idx := 0; idx < 4; idx++ {
+		fmt.Fprintln(file, `{}`)
+		clock.advance(time.Hour)
+	}
+	file.Close()
+
+	var names []string
+	for name := range rotatedFiles(t, dir) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := "capture-20240501T120000Z.ndjson,capture-20240501T130000Z.ndjson,capture-notes.ndjson"
+	if strings.Join(names, ",") != want {
+		t.Errorf("expected %s, got %s", want, strings.Join(names, ","))
+	}
+}
+
+func TestRotatingFileWritesWhenPruningFails(t *testing.T) {
+	dir := t.TempDir()
+	var warnings strings.Builder
+	file := newRotatingFile(filepath.Join(dir, "capture.ndjson"), time.Hour, 1)
+	file.now = (&fakeClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}).Now
+	file.prune = func(string, int) error { return errors.New("failed to remove rotated file: permission denied") }
+	file.warnings = &warnings
+
+	if _, err := fmt.Fprintln(file, `{"id":1}`); err != nil {
+		t.Fatalf("expected the event to be written, got %v", err)
+	}
+	file.Close()
+
+	if got := rotatedFiles(t, dir)["capture-20240501T100000Z.ndjson"]; got != "{\"id\":1}\n" {
+		t.Errorf("expected the event in the new file, got %q", got)
+	}
+	if !strings.Contains(warnings.String(), "permission denied") {
+		t.Errorf("expected a warning about the failed removal, got %q", warnings.String())
+	}
+}
+
+func TestRotatingFileWithoutPeriodAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.ndjson")
+	os.WriteFile(path, []byte("{\"n\":0}\n"), 0o644)
+
+	file := newRotatingFile(path, 0, 0)
+	fmt.Fprintln(file, `{"n":1}`)
+	file.Close()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "{\"n\":0}\n{\"n\":1}\n" {
+		t.Errorf("expected events to be appended to the file, got %q", data)
+	}
+}
+
+func TestValidateTailOutputOptions(t *testing.T) {
+	if err := validateTailOutputOptions("", time.Hour, 0); err == nil {
+		t.Error("expected --rotate without --output-file to be rejected")
+	}
+	if err := validateTailOutputOptions("capture.ndjson", 0, 3); err == nil {
+		t.Error("expected --keep without --rotate to be rejected")
+	}
+	if err := validateTailOutputOptions("capture.ndjson", time.Hour, 3); err != nil {
+		t.Errorf("expected valid options to pass, got %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTailHandoffHasNoDuplicatesOrGaps(t *testing.T) {
+	// history as returned by the query API
+	history := []map[string]interface{}{
+		{"p_timestamp": "2024-05-01T10:00:01.000", "msg": "a", "status": float64(200)},
+		{"p_timestamp": "2024-05-01T10:00:02.000", "msg": "b", "status": float64(200)},
+		{"p_timestamp": "2024-05-01T10:00:03.000", "msg": "c", "status": float64(500)},
+	}
+
+	// the live feed repeats the events ingested while history was fetched,
+	// with its own key order and timestamp layout, then continues
+	live := []string{
+		`{"msg":"b","p_timestamp":"2024-05-01 10:00:02","status":200}`,
+		`{"status":500,"msg":"c","p_timestamp":"2024-05-01 10:00:03"}`,
+		`{"msg":"d","p_timestamp":"2024-05-01 10:00:04","status":200}`,
+		`{"msg":"e","p_timestamp":"2024-05-01 10:00:05","status":200}`,
+	}
+
+	var printed []string
+	for _, record := range history {
+		printed = append(printed, record["msg"].(string))
+	}
+	deduper := newTailDeduper(history)
+	for _, line := range live {
+		if !deduper.seen(line) {
+			var record map[string]interface{}
+			json.Unmarshal([]byte(line), &record)
+			printed = append(printed, record["msg"].(string))
+		}
+	}
+
+	want := []string{"a", "b", "c", "d", "e"}
+	if !reflect.DeepEqual(printed, want) {
+		t.Errorf("expected %v, got %v", want, printed)
+	}
+}
+
+func TestTailDeduperSuppressesEachEventOnce(t *testing.T) {
+	history := []map[string]interface{}{
+		{"p_timestamp": "2024-05-01T10:00:01.000", "msg": "same"},
+	}
+	deduper := newTailDeduper(history)
+
+	line := `{"msg":"same","p_timestamp":"2024-05-01 10:00:01"}`
+	if !deduper.seen(line) {
+		t.Error("expected the boundary event to be recognised")
+	}
+	if deduper.seen(line) {
+		t.Error("expected a later identical event to be printed")
+	}
+}
+
+func TestTailResumesAfterDisconnect(t *testing.T) {
+	// every event the server holds, as the query API returns them
+	stored := []map[string]interface{}{
+		{"p_timestamp": "2024-05-01T10:00:01.000", "msg": "a"},
+		{"p_timestamp": "2024-05-01T10:00:02.000", "msg": "b"},
+		{"p_timestamp": "2024-05-01T10:00:02.000", "msg": "c"},
+		{"p_timestamp": "2024-05-01T10:00:03.000", "msg": "d"},
+		{"p_timestamp": "2024-05-01T10:00:04.000", "msg": "e"},
+		{"p_timestamp": "2024-05-01T10:00:05.000", "msg": "f"},
+	}
+
+	// the first connection drops after c, the first reconnect fails, and the
+	// second replays e, which was ingested while the gap was being filled
+	connections := []struct {
+		batches [][]string
+		err     error
+	}{
+		{batches: [][]string{
+			{`{"msg":"a","p_timestamp":"2024-05-01 10:00:01"}`},
+			{`{"msg":"b","p_timestamp":"2024-05-01 10:00:02"}`, `{"msg":"c","p_timestamp":"2024-05-01 10:00:02"}`},
+		}},
+		{err: errors.New("connection refused")},
+		{batches: [][]string{
+			{`{"msg":"e","p_timestamp":"2024-05-01 10:00:04"}`},
+			{`{"msg":"f","p_timestamp":"2024-05-01 10:00:05"}`},
+		}},
+	}
+
+	attempt := 0
+	connect := func() (func() ([]string, error), func(), error) {
+		conn := connections[attempt]
+		attempt++
+		if conn.err != nil {
+			return nil, nil, conn.err
+		}
+		batch := 0
+		next := func() ([]string, error) {
+			if batch == len(conn.batches) {
+				return nil, errors.New("stream closed by server")
+			}
+			batch++
+			return conn.batches[batch-1], nil
+		}
+		return next, func() {}, nil
+	}
+
+	var resumedFrom time.Time
+	backfill := func(from time.Time) ([]map[string]interface{}, error) {
+		resumedFrom = from
+		var events []map[string]interface{}
+		for _, record := range stored[:5] {
+			ts, _ := parseEventTime(record["p_timestamp"].(string))
+			if !ts.Before(from) {
+				events = append(events, record)
+			}
+		}
+		return events, nil
+	}
+
+	var out, notices strings.Builder
+	var delays []time.Duration
+	follower := &tailFollower{
+		connect:       connect,
+		backfill:      backfill,
+		out:           &out,
+		notices:       &notices,
+		maxReconnects: 2,
+		sleep:         func(d time.Duration) { delays = append(delays, d) },
+	}
+
+	if err := follower.run(0); err == nil || !strings.Contains(err.Error(), "stream closed") {
+		t.Fatalf("expected the tail to stop once reconnects are exhausted, got %v", err)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid output line %q", line)
+		}
+		got = append(got, record["msg"].(string))
+	}
+	if expected := []string{"a", "b", "c", "d", "e", "f"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected every event exactly once in order %v, got %v", expected, got)
+	}
+
+	if want := time.Date(2024, 5, 1, 10, 0, 2, 0, time.UTC); !resumedFrom.Equal(want) {
+		t.Errorf("expected to resume from the last printed event at %s, got %s", want, resumedFrom)
+	}
+	if !reflect.DeepEqual(delays, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Errorf("expected exponential backoff, got %v", delays)
+	}
+	if strings.Count(notices.String(), "reconnecting in") != 2 {
+		t.Errorf("expected a notice per reconnect, got %q", notices.String())
+	}
+}
+
+func TestTailStopsAfterCount(t *testing.T) {
+	closed := 0
+	connect := func() (func() ([]string, error), func(), error) {
+		event := 0
+		next := func() ([]string, error) {
+			// an endless feed delivering three events per batch
+			batch := make([]string, 3)
+			for idx := range batch {
+				event++
+				batch[idx] = fmt.Sprintf(`{"msg":"%d","p_timestamp":"2024-05-01 10:00:%02d"}`, event, event%60)
+			}
+			return batch, nil
+		}
+		return next, func() { closed++ }, nil
+	}
+
+	var out strings.Builder
+	follower := &tailFollower{
+		connect:       connect,
+		out:           &out,
+		notices:       io.Discard,
+		maxReconnects: -1,
+		count:         5,
+		sleep:         func(time.Duration) { t.Fatal("expected no reconnect") },
+	}
+
+	if err := follower.run(0); err != nil {
+		t.Fatalf("expected a clean exit after the count is reached, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected exactly 5 events, got %d:\n%s", len(lines), out.String())
+	}
+	if !strings.Contains(lines[4], `"msg":"5"`) {
+		t.Errorf("expected the first five events in order, got %q last", lines[4])
+	}
+	if closed != 1 {
+		t.Errorf("expected the feed to be closed once, got %d", closed)
+	}
+}
+
+func TestTailCountIncludesHistory(t *testing.T) {
+	history := []map[string]interface{}{
+		{"p_timestamp": "2024-05-01T10:00:01.000", "msg": "a"},
+		{"p_timestamp": "2024-05-01T10:00:02.000", "msg": "b"},
+		{"p_timestamp": "2024-05-01T10:00:03.000", "msg": "c"},
+	}
+	connect := func() (func() ([]string, error), func(), error) {
+		next := func() ([]string, error) {
+			t.Fatal("expected the live feed not to be read once the count is reached")
+			return nil, nil
+		}
+		return next, func() {}, nil
+	}
+
+	var out strings.Builder
+	follower := &tailFollower{
+		connect:  connect,
+		backfill: func(time.Time) ([]map[string]interface{}, error) { return history, nil },
+		out:      &out,
+		notices:  io.Discard,
+		count:    2,
+	}
+	if err := follower.run(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 2 {
+		t.Errorf("expected 2 events, got %d:\n%s", got, out.String())
+	}
+}
+
+func TestTailCountReturnsWhatWasCollectedWhenFeedEnds(t *testing.T) {
+	connect := func() (func() ([]string, error), func(), error) {
+		sent := false
+		next := func() ([]string, error) {
+			if sent {
+				return nil, errors.New("stream closed by server")
+			}
+			sent = true
+			return []string{`{"msg":"a","p_timestamp":"2024-05-01 10:00:01"}`}, nil
+		}
+		return next, func() {}, nil
+	}
+
+	var out strings.Builder
+	follower := &tailFollower{
+		connect:       connect,
+		out:           &out,
+		notices:       io.Discard,
+		maxReconnects: 0,
+		count:         10,
+	}
+	if err := follower.run(0); err == nil {
+		t.Error("expected the closed feed to be reported")
+	}
+	if got := strings.Count(out.String(), "\n"); got != 1 {
+		t.Errorf("expected the one received event to be printed, got %d", got)
+	}
+}
+
+func TestTailRunningTotals(t *testing.T) {
+	batches := [][]string{
+		{`{"level":"error","bytes":100,"p_timestamp":"2024-05-01 10:00:01"}`, `{"level":"error","bytes":250,"p_timestamp":"2024-05-01 10:00:02"}`},
+		{`{"level":"error","p_timestamp":"2024-05-01 10:00:03"}`, `{"level":"error","bytes":"50","p_timestamp":"2024-05-01 10:00:04"}`},
+	}
+	connect := func() (func() ([]string, error), func(), error) {
+		sent := 0
+		next := func() ([]string, error) {
+			if sent == len(batches) {
+				return nil, errors.New("stream closed by server")
+			}
+			sent++
+			return batches[sent-1], nil
+		}
+		return next, func() {}, nil
+	}
+
+	totals, err := parseRunningAggs([]string{"count", "sum:bytes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status strings.Builder
+	totals.status = &status
+
+	follower := &tailFollower{
+		connect: connect,
+		out:     io.Discard,
+		notices: io.Discard,
+		totals:  totals,
+	}
+	follower.run(0)
+
+	var final strings.Builder
+	totals.final(&final)
+	if final.String() != "Total: count=4  sum(bytes)=400\n" {
+		t.Errorf("unexpected final totals %q", final.String())
+	}
+	if !strings.Contains(status.String(), "count=2  sum(bytes)=350") {
+		t.Errorf("expected the status line to be updated after each event, got %q", status.String())
+	}
+}
+
+func TestParseRunningAggs(t *testing.T) {
+	for _, value := range []string{"sum", "count:bytes", "avg:latency"} {
+		if _, err := parseRunningAggs([]string{value}); err == nil {
+			t.Errorf("expected --running-agg %s to be rejected", value)
+		}
+	}
+}
+
+func TestTailStopsOnPermanentErrors(t *testing.T) {
+	for _, code := range []codes.Code{codes.Unauthenticated, codes.PermissionDenied, codes.NotFound} {
+		connects := 0
+		connect := func() (func() ([]string, error), func(), error) {
+			connects++
+			if connects > 1 {
+				return nil, nil, status.Error(code, "rejected")
+			}
+			next := func() ([]string, error) {
+				return nil, status.Error(codes.Unavailable, "connection reset")
+			}
+			return next, func() {}, nil
+		}
+
+		var sleeps int
+		follower := &tailFollower{
+			connect:       connect,
+			out:           io.Discard,
+			notices:       io.Discard,
+			maxReconnects: -1,
+			sleep:         func(time.Duration) { sleeps++ },
+		}
+		err := follower.run(0)
+		if status.Code(err) != code {
+			t.Errorf("%s: expected the tail to stop with the error, got %v", code, err)
+		}
+		if connects != 2 || sleeps != 1 {
+			t.Errorf("%s: expected a single reconnect attempt, got %d connects and %d waits", code, connects, sleeps)
+		}
+	}
+
+	if !permanentTailError(fmt.Errorf("connect: %w", internalHTTP.ErrOffline)) {
+		t.Error("expected offline mode to stop the tail")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	internalHTTP "pb/pkg/http"
 	"strings"
 	"sync"
@@ -33,6 +34,19 @@ import (
 type UserData struct {
 	ID     string `json:"id"`
 	Method string `json:"method"`
+	// LastActive and LastLogin are only sent by servers that track user
+	// activity
+	LastActive string `json:"last_active,omitempty"`
+	LastLogin  string `json:"last_login,omitempty"`
+	// RoleExpiry maps temporary roles to when the server revokes them, it
+	// is only sent by servers that support role expiry
+	RoleExpiry map[string]string `json:"role_expiry,omitempty"`
+}
+
+// userRoles holds the roles fetched for one user
+type userRoles struct {
+	data []string
+	err  error
 }
 
 type UserRoleData map[string][]RoleData
@@ -44,9 +58,29 @@ var (
 
 var addUser = &cobra.Command{
 	Use:     "add user-name",
-	Example: "  pb user add bob",
+	Example: "  pb user add bob\n  pb user add bob --role admin --role developer --rollback-on-error\n  pb user add analyst --readonly --create-missing-roles",
 	Short:   "Add a new user",
-	Args:    cobra.ExactArgs(1),
+	Long: `Add a new user, optionally with roles.
+
+The user is created first and the roles given with --role are assigned
+afterwards. If assigning the roles fails, the user is kept and pb explains
+how to finish or undo the change. Pass --rollback-on-error to remove the
+user again instead.
+
+For the common cases, assign a well known role with a shortcut flag instead
+of --role:
+
+  --readonly     role readonly, reader on every stream (stream *): can
+                 query every stream, but not ingest or change anything
+  --ingest-only  role ingest-only, ingestor on every stream (stream *):
+                 can send events to every stream, but not query them
+  --admin        role admin, the admin privilege: full access, including
+                 users, roles and server settings
+
+If the role does not exist, pb stops with an error unless
+--create-missing-roles is set, which creates it with the privileges above.
+An existing role with that name is assigned as it is.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		startTime := time.Now()
 		cmd.Annotations = make(map[string]string) // Initialize Annotations map
@@ -72,31 +106,87 @@ var addUser = &cobra.Command{
 		}
 
 		// fetch all the roles to be applied to this user
-		rolesToSet := cmd.Flag(roleFlag).Value.String()
-		rolesToSetArr := strings.Split(rolesToSet, ",")
+		rolesToSet, _ := cmd.Flags().GetStringSlice(roleFlag)
+		shortcuts := selectedRoleShortcuts(cmd.Flags())
+		for _, shortcut := range shortcuts {
+			rolesToSet = append(rolesToSet, shortcut.role)
+		}
+		var roles []string
+		for _, role := range rolesToSet {
+			if role = strings.TrimSpace(role); role != "" && !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
 
-		// fetch the role names on the server
-		var rolesOnServer []string
-		if err := fetchRoles(&client, &rolesOnServer); err != nil {
+		if len(roles) > 0 {
+			// fetch the role names on the server
+			var rolesOnServer []string
+			if err := fetchRoles(&client, &rolesOnServer); err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
+
+			createMissing, _ := cmd.Flags().GetBool(createMissingRolesFlag)
+			if err := ensureShortcutRoles(&client, shortcuts, rolesOnServer, createMissing, os.Stdout); err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
+			for _, shortcut := range shortcuts {
+				if !slices.Contains(rolesOnServer, shortcut.role) {
+					rolesOnServer = append(rolesOnServer, shortcut.role)
+				}
+			}
+
+			// validate if roles to be applied are actually present on the server
+			for _, role := range roles {
+				if !slices.Contains(rolesOnServer, role) {
+					fmt.Printf("role %s doesn't exist, please create a role using pb role add %s\n", role, role)
+					cmd.Annotations["error"] = fmt.Sprintf("role %s doesn't exist", role)
+					return nil
+				}
+			}
+		}
+
+		rollback, _ := cmd.Flags().GetBool(rollbackOnErrorFlag)
+		generated, err := provisionUser(&client, name, roles, rollback)
+		if generated != "" {
+			fmt.Printf("Added user: %s \nPassword is: %s\n", name, generated)
+		}
+		if err != nil {
 			cmd.Annotations["error"] = err.Error()
 			return err
 		}
-		rolesOnServerArr := strings.Join(rolesOnServer, " ")
-
-		// validate if roles to be applied are actually present on the server
-		for idx, role := range rolesToSetArr {
-			rolesToSetArr[idx] = strings.TrimSpace(role)
-			if !strings.Contains(rolesOnServerArr, rolesToSetArr[idx]) {
-				fmt.Printf("role %s doesn't exist, please create a role using pb role add %s\n", rolesToSetArr[idx], rolesToSetArr[idx])
-				cmd.Annotations["error"] = fmt.Sprintf("role %s doesn't exist", rolesToSetArr[idx])
-				return nil
-			}
+		if len(roles) > 0 {
+			fmt.Printf("Role(s) assigned: %s\n", strings.Join(roles, ","))
 		}
+		cmd.Annotations["error"] = "none"
+		return nil
+	},
+}
 
-		var putBody io.Reader
-		putBodyJSON, _ := json.Marshal(rolesToSetArr)
-		putBody = bytes.NewBuffer([]byte(putBodyJSON))
-		req, err := client.NewRequest("POST", "user/"+name, putBody)
+var AddUserCmd = func() *cobra.Command {
+	addUser.Flags().StringSliceP(roleFlag, roleFlagShort, nil, "specify the role(s) to be assigned to the user. Repeat the flag or use comma separated values for multiple roles. Example: --role admin,developer")
+	addUser.Flags().Bool(rollbackOnErrorFlag, false, "Remove the new user again if assigning its roles fails")
+	addRoleShortcutFlags(addUser.Flags())
+	return addUser
+}()
+
+var ResetUserPasswordCmd = &cobra.Command{
+	Use:     "reset-password user-name",
+	Example: "  pb user reset-password bob",
+	Short:   "Reset the password for a user",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		name := args[0]
+
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+		req, err := client.NewRequest("POST", "user/"+name+"/generate-new-password", nil)
 		if err != nil {
 			cmd.Annotations["error"] = err.Error()
 			return err
@@ -117,7 +207,7 @@ var addUser = &cobra.Command{
 		defer resp.Body.Close()
 
 		if resp.StatusCode == 200 {
-			fmt.Printf("Added user: %s \nPassword is: %s\nRole(s) assigned: %s\n", name, body, rolesToSet)
+			fmt.Printf("Password reset for user %s\nNew password is: %s\n", name, body)
 			cmd.Annotations["error"] = "none"
 		} else {
 			fmt.Printf("Request Failed\nStatus Code: %s\nResponse: %s\n", resp.Status, body)
@@ -128,17 +218,17 @@ var addUser = &cobra.Command{
 	},
 }
 
-var AddUserCmd = func() *cobra.Command {
-	addUser.Flags().StringP(roleFlag, roleFlagShort, "", "specify the role(s) to be assigned to the user. Use comma separated values for multiple roles. Example: --role admin,developer")
-	return addUser
-}()
-
 var RemoveUserCmd = &cobra.Command{
 	Use:     "remove user-name",
 	Aliases: []string{"rm"},
-	Example: "  pb user remove bob",
+	Example: "  pb user remove bob\n  pb user remove bob --yes",
 	Short:   "Delete a user",
-	Args:    cobra.ExactArgs(1),
+	Long: `Delete a user.
+
+pb asks you to type the user name before deleting the user. Pass --yes to
+skip the prompt. When stdin is not a terminal, pb cannot ask and stops with
+an error unless --yes is set.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		startTime := time.Now()
 		cmd.Annotations = make(map[string]string)
@@ -147,6 +237,17 @@ var RemoveUserCmd = &cobra.Command{
 		}()
 
 		name := args[0]
+		confirmed, err := confirmDestructive(cmd, fmt.Sprintf("Deleting user %s", name), name)
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted, the user was not deleted")
+			cmd.Annotations["error"] = "none"
+			return nil
+		}
+
 		client := internalHTTP.DefaultClient(&DefaultProfile)
 		req, err := client.NewRequest("DELETE", "user/"+name, nil)
 		if err != nil {
@@ -177,6 +278,10 @@ var SetUserRoleCmd = &cobra.Command{
 	Use:     "set-role user-name roles",
 	Short:   "Set roles for a user",
 	Example: "  pb user set-role bob admin,developer",
+	Long: `Set roles for a user.
+
+--expires-in and --expires-at are refused, as Parseable cannot revoke a role
+when its time is up. A role meant to be temporary is never granted for good.`,
 	PreRunE: func(_ *cobra.Command, args []string) error {
 		if len(args) < 2 {
 			return fmt.Errorf("requires at least 2 arguments")
@@ -190,6 +295,15 @@ var SetUserRoleCmd = &cobra.Command{
 			cmd.Annotations["executionTime"] = time.Since(startTime).String()
 		}()
 
+		expiry, err := roleExpiryFromFlags(cmd.Flags(), time.Now())
+		if err == nil && !expiry.IsZero() {
+			err = errRoleExpiryUnsupported
+		}
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
 		name := args[0]
 		client := internalHTTP.DefaultClient(&DefaultProfile)
 		users, err := fetchUsers(&client)
@@ -262,7 +376,7 @@ var SetUserRoleCmd = &cobra.Command{
 var ListUserCmd = &cobra.Command{
 	Use:     "list",
 	Short:   "List all users",
-	Example: "  pb user list",
+	Example: "  pb user list\n  pb user list --inactive-for=90d",
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		startTime := time.Now()
 		cmd.Annotations = make(map[string]string)
@@ -270,6 +384,16 @@ var ListUserCmd = &cobra.Command{
 			cmd.Annotations["executionTime"] = time.Since(startTime).String()
 		}()
 
+		var inactiveFor time.Duration
+		if value, _ := cmd.Flags().GetString(inactiveForFlag); value != "" {
+			var err error
+			inactiveFor, err = parseDaysOrDuration(inactiveForFlag, value)
+			if err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
+		}
+
 		client := internalHTTP.DefaultClient(&DefaultProfile)
 		users, err := fetchUsers(&client)
 		if err != nil {
@@ -277,10 +401,13 @@ var ListUserCmd = &cobra.Command{
 			return err
 		}
 
-		roleResponses := make([]struct {
-			data []string
-			err  error
-		}, len(users))
+		tracked := hasActivityData(users)
+		if inactiveFor > 0 && !tracked {
+			cmd.Annotations["error"] = errNoActivityData.Error()
+			return errNoActivityData
+		}
+
+		roleResponses := make([]userRoles, len(users))
 
 		wsg := sync.WaitGroup{}
 		for idx, user := range users {
@@ -302,6 +429,10 @@ var ListUserCmd = &cobra.Command{
 
 		wsg.Wait()
 
+		if inactiveFor > 0 {
+			users, roleResponses = filterInactiveUsers(users, roleResponses, time.Now().Add(-inactiveFor))
+		}
+
 		outputFormat, err := cmd.Flags().GetString("output")
 		if err != nil {
 			cmd.Annotations["error"] = err.Error()
@@ -315,6 +446,16 @@ var ListUserCmd = &cobra.Command{
 					"id":    user.ID,
 					"roles": roleResponses[idx].data,
 				}
+				if len(user.RoleExpiry) > 0 {
+					usersWithRoles[idx]["role_expiry"] = user.RoleExpiry
+				}
+				if tracked {
+					var lastActive interface{}
+					if last, ok := user.lastActive(); ok {
+						lastActive = last.Format(time.RFC3339)
+					}
+					usersWithRoles[idx]["last_active"] = lastActive
+				}
 			}
 			jsonOutput, err := json.MarshalIndent(usersWithRoles, "", "  ")
 			if err != nil {
@@ -342,13 +483,27 @@ var ListUserCmd = &cobra.Command{
 			return nil
 		}
 
+		if inactiveFor > 0 && len(users) == 0 {
+			value, _ := cmd.Flags().GetString(inactiveForFlag)
+			fmt.Printf("No users inactive for %s\n", value)
+			cmd.Annotations["error"] = "none"
+			return nil
+		}
+
 		fmt.Println()
 		for idx, user := range users {
 			roles := roleResponses[idx]
 			fmt.Print("• ")
-			fmt.Println(StandardStyleBold.Bold(true).Render(user.ID))
+			fmt.Print(StandardStyleBold.Bold(true).Render(user.ID))
+			if tracked {
+				fmt.Print(StandardStyleAlt.Render(" last active " + formatLastActive(user, tracked)))
+			}
+			fmt.Println()
 			if roles.err == nil {
 				for _, role := range roles.data {
+					if expiry, ok := user.roleExpiry(role); ok {
+						role += StandardStyleAlt.Render(" " + formatRoleExpiry(expiry, time.Now()))
+					}
 					fmt.Println(lipgloss.NewStyle().PaddingLeft(3).Render(role))
 				}
 			} else {
@@ -415,4 +570,7 @@ func fetchUserRoles(client *internalHTTP.HTTPClient, user string) (res UserRoleD
 func init() {
 	// Add the --output flag with shorthand -o, defaulting to empty for default layout
 	ListUserCmd.Flags().StringP("output", "o", "", "Output format: 'text' or 'json'")
+	ListUserCmd.Flags().String(inactiveForFlag, "", "Only list users not active within this period, e.g. 90d or 720h. Requires a server that reports user activity")
+	SetUserRoleCmd.Flags().String(expiresInFlag, "", "Grant the roles for this long, e.g. 4h or 2d. Refused, as the server cannot revoke roles on expiry")
+	SetUserRoleCmd.Flags().String(expiresAtFlag, "", "Grant the roles until this RFC3339 time. Refused, as the server cannot revoke roles on expiry")
 }
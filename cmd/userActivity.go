@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+var inactiveForFlag = "inactive-for"
+
+// errNoActivityData is returned when --inactive-for is used against a server
+// that does not report when users were last active
+var errNoActivityData = errors.New("the server does not report user activity, so inactive users cannot be identified. Upgrade the server or remove --" + inactiveForFlag)
+
+// lastActive returns when the user was last active, from whichever activity
+// field the server reports
+func (u UserData) lastActive() (time.Time, bool) {
+	for _, value := range []string{u.LastActive, u.LastLogin} {
+		if value == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// hasActivityData reports whether the server sent activity fields at all. A
+// server that tracks activity may still omit them for users who never logged
+// in, but if no user has them the server does not track activity
+func hasActivityData(users []UserData) bool {
+	for _, user := range users {
+		if _, ok := user.lastActive(); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterInactiveUsers keeps the users not active since cutoff, including
+// those who were never active. roles is filtered alongside users
+func filterInactiveUsers(users []UserData, roles []userRoles, cutoff time.Time) ([]UserData, []userRoles) {
+	var keptUsers []UserData
+	var keptRoles []userRoles
+	for idx, user := range users {
+		if last, ok := user.lastActive(); ok && !last.Before(cutoff) {
+			continue
+		}
+		keptUsers = append(keptUsers, user)
+		keptRoles = append(keptRoles, roles[idx])
+	}
+	return keptUsers, keptRoles
+}
+
+// formatLastActive renders the last activity of a user for text output
+func formatLastActive(user UserData, tracked bool) string {
+	if last, ok := user.lastActive(); ok {
+		return fmt.Sprintf("%s (%s)", last.Format(time.RFC3339), humanize.Time(last))
+	}
+	if tracked {
+		return "never"
+	}
+	return "unknown"
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterInactiveUsers(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	users := []UserData{
+		{ID: "recent", LastActive: now.Add(-24 * time.Hour).Format(time.RFC3339)},
+		{ID: "stale", LastActive: now.Add(-100 * 24 * time.Hour).Format(time.RFC3339)},
+		{ID: "login-only", LastLogin: now.Add(-200 * 24 * time.Hour).Format(time.RFC3339)},
+		{ID: "never"},
+	}
+	roles := []userRoles{{data: []string{"a"}}, {data: []string{"b"}}, {data: []string{"c"}}, {data: []string{"d"}}}
+
+	kept, keptRoles := filterInactiveUsers(users, roles, now.Add(-90*24*time.Hour))
+	var ids []string
+	for _, user := range kept {
+		ids = append(ids, user.ID)
+	}
+	if len(ids) != 3 || ids[0] != "stale" || ids[1] != "login-only" || ids[2] != "never" {
+		t.Errorf("unexpected inactive users %v", ids)
+	}
+	if keptRoles[0].data[0] != "b" || keptRoles[2].data[0] != "d" {
+		t.Errorf("expected roles to stay aligned with users, got %v", keptRoles)
+	}
+}
+
+func TestActivityDataDetection(t *testing.T) {
+	if hasActivityData([]UserData{{ID: "a"}, {ID: "b"}}) {
+		t.Error("expected users without activity fields to be reported as untracked")
+	}
+	if !hasActivityData([]UserData{{ID: "a"}, {ID: "b", LastLogin: "2024-05-01T10:00:00Z"}}) {
+		t.Error("expected a last_login field to count as activity data")
+	}
+}
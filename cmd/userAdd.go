@@ -0,0 +1,84 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	internalHTTP "pb/pkg/http"
+)
+
+var rollbackOnErrorFlag = "rollback-on-error"
+
+// provisionUser creates the user and then assigns roles to it. It returns
+// the password the server generated, also when assigning the roles
+// fails and the user is kept. With rollback, a user whose roles could not be
+// assigned is deleted again so that no half-provisioned user is left behind
+func provisionUser(client *internalHTTP.HTTPClient, name string, roles []string, rollback bool) (string, error) {
+	generated, err := createUser(client, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create user %s: %w", name, err)
+	}
+	if len(roles) == 0 {
+		return generated, nil
+	}
+
+	body, _ := json.Marshal(roles)
+	assignErr := sendRoleRequest(client, http.MethodPut, "user/"+name+"/role", body)
+	if assignErr == nil {
+		return generated, nil
+	}
+
+	if !rollback {
+		return generated, fmt.Errorf("user %s was created but assigning role(s) %s failed: %w. Assign them with pb user set-role %s %s, remove the user with pb user remove %s, or pass --%s to remove it automatically",
+			name, strings.Join(roles, ","), assignErr, name, strings.Join(roles, ","), name, rollbackOnErrorFlag)
+	}
+	if err := sendRoleRequest(client, http.MethodDelete, "user/"+name, nil); err != nil {
+		return generated, fmt.Errorf("assigning role(s) %s failed: %v, and removing user %s again also failed: %w. Remove it with pb user remove %s",
+			strings.Join(roles, ","), assignErr, name, err, name)
+	}
+	return "", fmt.Errorf("assigning role(s) %s failed: %w. User %s was removed again", strings.Join(roles, ","), assignErr, name)
+}
+
+// createUser creates a user without roles and returns the response body,
+// which holds the password the server generated for it
+func createUser(client *internalHTTP.HTTPClient, name string) (string, error) {
+	body, _ := json.Marshal([]string{})
+	req, err := client.NewRequest(http.MethodPost, "user/"+name, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return string(respBody), nil
+}
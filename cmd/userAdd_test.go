@@ -0,0 +1,100 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+// userServer records the user requests it gets and fails role assignment
+// when failRoles is set
+func userServer(t *testing.T, failRoles bool) (*httptest.Server, *[]string) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+		calls = append(calls, r.Method+" "+path)
+		switch {
+		case r.Method == http.MethodPost && path == "user/bob":
+			w.Write([]byte("generated-password"))
+		case r.Method == http.MethodPut && path == "user/bob/role":
+			if failRoles {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("role store unavailable"))
+			}
+		case r.Method == http.MethodDelete && path == "user/bob":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestProvisionUserAssignsRoles(t *testing.T) {
+	server, calls := userServer(t, false)
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	generated, err := provisionUser(&client, "bob", []string{"admin", "developer"}, false)
+	if err != nil {
+		t.Fatalf("expected provisioning to succeed, got %v", err)
+	}
+	if generated != "generated-password" {
+		t.Errorf("expected the generated password, got %q", generated)
+	}
+	if strings.Join(*calls, ",") != "POST user/bob,PUT user/bob/role" {
+		t.Errorf("expected the user to be created and then given roles, got %v", *calls)
+	}
+}
+
+func TestProvisionUserKeepsUserOnRoleFailure(t *testing.T) {
+	server, calls := userServer(t, true)
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	generated, err := provisionUser(&client, "bob", []string{"admin"}, false)
+	if err == nil || !strings.Contains(err.Error(), "was created") || !strings.Contains(err.Error(), "role store unavailable") {
+		t.Fatalf("expected the partial failure to be reported, got %v", err)
+	}
+	if generated != "generated-password" {
+		t.Errorf("expected the password of the kept user, got %q", generated)
+	}
+	for _, call := range *calls {
+		if strings.HasPrefix(call, http.MethodDelete) {
+			t.Errorf("expected the user to be kept, got %v", *calls)
+		}
+	}
+}
+
+func TestProvisionUserRollsBackOnRoleFailure(t *testing.T) {
+	server, calls := userServer(t, true)
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+
+	generated, err := provisionUser(&client, "bob", []string{"admin"}, true)
+	if err == nil || !strings.Contains(err.Error(), "removed again") {
+		t.Fatalf("expected the rollback to be reported, got %v", err)
+	}
+	if generated != "" {
+		t.Errorf("expected no password for a removed user, got %q", generated)
+	}
+	if strings.Join(*calls, ",") != "POST user/bob,PUT user/bob/role,DELETE user/bob" {
+		t.Errorf("expected the user to be removed again, got %v", *calls)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	importFileFlag   = "file"
+	importDryRunFlag = "dry-run"
+
+	// userNamePattern is the user name format checked before import: 3 to
+	// 64 characters, starting with a letter, then letters, digits, _ or -
+	userNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{2,63}$`)
+)
+
+// userImportRow is one user of an import file
+type userImportRow struct {
+	// Line is the line of the row in the file, for messages
+	Line  int
+	Name  string
+	Roles []string
+	// Err is why the row cannot be imported, nil when it can
+	Err error
+}
+
+var ImportUserCmd = &cobra.Command{
+	Use:     "import",
+	Example: "  pb user import --file users.csv --dry-run\n  pb user import --file users.csv\n  cat users.csv | pb user import --file -",
+	Short:   "Add users in bulk from a CSV file",
+	Long: `Add users in bulk from a CSV file.
+
+The file needs a header row with the columns username and roles. Separate
+several roles with semicolons, e.g. reader;ingestor. The server generates
+a password for each user, which is printed once the user is created.
+
+Every row is checked before anything is created: the user name format,
+that the user does not exist yet and is not listed twice, and that its
+roles exist. If any row fails, nothing is imported.
+
+Pass --dry-run to only run these checks and print what would be created,
+without changing anything on the server. It exits with an error if any
+row would fail, so CI can gate an onboarding batch on it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		path, _ := cmd.Flags().GetString(importFileFlag)
+		rows, err := readUserImportFile(path, cmd.InOrStdin())
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
+		client := internalHTTP.DefaultClient(&DefaultProfile)
+		users, err := fetchUsers(&client)
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		var roles []string
+		if err := fetchRoles(&client, &roles); err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		existing := make([]string, len(users))
+		for idx, user := range users {
+			existing[idx] = user.ID
+		}
+
+		failed := validateUserImport(rows, existing, roles)
+		dryRun, _ := cmd.Flags().GetBool(importDryRunFlag)
+		out := cmd.OutOrStdout()
+		printUserImportPlan(out, rows, dryRun)
+
+		if failed > 0 {
+			err := fmt.Errorf("%d of %d row(s) cannot be imported, nothing was changed", failed, len(rows))
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		if dryRun {
+			cmd.Annotations["error"] = "none"
+			return nil
+		}
+
+		rollback, _ := cmd.Flags().GetBool(rollbackOnErrorFlag)
+		fmt.Fprintln(out)
+		var errs []error
+		for _, row := range rows {
+			generated, err := provisionUser(&client, row.Name, row.Roles, rollback)
+			if generated != "" {
+				fmt.Fprintf(out, "Added user %s, password is: %s\n", row.Name, generated)
+			} else if err == nil {
+				fmt.Fprintf(out, "Added user %s\n", row.Name)
+			}
+			if err != nil {
+				fmt.Fprintf(out, "Error: %s\n", err)
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			err := fmt.Errorf("%d of %d user(s) could not be imported", len(errs), len(rows))
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		cmd.Annotations["error"] = "none"
+		return nil
+	},
+}
+
+func init() {
+	ImportUserCmd.Flags().String(importFileFlag, "", "CSV file with the users to add, - reads from stdin")
+	ImportUserCmd.Flags().Bool(importDryRunFlag, false, "Check every row and print what would be created, without changing anything")
+	ImportUserCmd.Flags().Bool(rollbackOnErrorFlag, false, "Remove a new user again if assigning its roles fails")
+	_ = ImportUserCmd.MarkFlagRequired(importFileFlag)
+}
+
+// readUserImportFile reads the rows of an import file, - reads stdin
+func readUserImportFile(path string, stdin io.Reader) ([]userImportRow, error) {
+	if path == "-" {
+		return parseUserImport(stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+	return parseUserImport(file)
+}
+
+// parseUserImport reads the CSV rows of an import file. Problems with a
+// single row are recorded in the row, problems with the file as a whole are
+// returned
+func parseUserImport(r io.Reader) ([]userImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the import file is empty, it needs a header row with username and roles")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read import file: %w", err)
+	}
+	columns := map[string]int{}
+	for idx, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = idx
+	}
+	for _, required := range []string{"username", "roles"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("the import file has no %s column, the header needs username and roles", required)
+		}
+	}
+	// the server always generates the password of a new user, so a password
+	// from the file could not be set
+	if _, ok := columns["password"]; ok {
+		return nil, errors.New("the import file has a password column, but the server generates the password of each user. Remove the column, pb prints the generated passwords")
+	}
+
+	var rows []userImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read import file: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if idx, ok := columns[name]; ok && idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+
+		row := userImportRow{Line: line, Name: field("username")}
+		for _, role := range strings.Split(field("roles"), ";") {
+			if role = strings.TrimSpace(role); role != "" && !slices.Contains(row.Roles, role) {
+				row.Roles = append(row.Roles, role)
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the import file has no users")
+	}
+	return rows, nil
+}
+
+// validateUserImport checks every row against the users and roles on the
+// server. It records the first problem of each row and returns the number of
+// failed rows
+func validateUserImport(rows []userImportRow, users, roles []string) int {
+	seen := map[string]int{}
+	failed := 0
+	for idx := range rows {
+		row := &rows[idx]
+		row.Err = validateUserImportRow(*row, users, roles)
+		if row.Err == nil {
+			if line, ok := seen[row.Name]; ok {
+				row.Err = fmt.Errorf("user %s is already listed on line %d", row.Name, line)
+			} else {
+				seen[row.Name] = row.Line
+			}
+		}
+		if row.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+func validateUserImportRow(row userImportRow, users, roles []string) error {
+	if err := validateUserName(row.Name); err != nil {
+		return err
+	}
+	if slices.Contains(users, row.Name) {
+		return fmt.Errorf("user %s already exists", row.Name)
+	}
+	for _, role := range row.Roles {
+		if !slices.Contains(roles, role) {
+			return fmt.Errorf("role %s does not exist, create it with pb role add %s", role, role)
+		}
+	}
+	return nil
+}
+
+// validateUserName checks the format of a user name before it is sent
+func validateUserName(name string) error {
+	if name == "" {
+		return errors.New("user name is empty")
+	}
+	if !userNamePattern.MatchString(name) {
+		return fmt.Errorf("user name %q must be 3 to 64 characters, start with a letter and contain only letters, digits, _ or -", name)
+	}
+	return nil
+}
+
+// printUserImportPlan lists what the import creates for each row and why
+// failed rows cannot be imported
+func printUserImportPlan(w io.Writer, rows []userImportRow, dryRun bool) {
+	if dryRun {
+		fmt.Fprintf(w, "Dry run, nothing is changed. Importing %d user(s) would:\n", len(rows))
+	} else {
+		fmt.Fprintf(w, "Importing %d user(s) will:\n", len(rows))
+	}
+
+	valid := 0
+	for _, row := range rows {
+		if row.Err != nil {
+			fmt.Fprintf(w, "  ✗ line %d: %s\n", row.Line, row.Err)
+			continue
+		}
+		valid++
+		roles := "no roles"
+		if len(row.Roles) > 0 {
+			roles = "role(s) " + strings.Join(row.Roles, ",")
+		}
+		fmt.Fprintf(w, "  • line %d: create user %s with %s\n", row.Line, row.Name, roles)
+	}
+	fmt.Fprintf(w, "%d row(s) can be imported, %d would fail\n", valid, len(rows)-valid)
+}
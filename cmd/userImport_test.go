@@ -0,0 +1,152 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+const importCSV = `username,roles
+alice,reader;ingestor
+bob,reader
+x,reader
+carol,auditors
+erin,reader
+alice,reader
+`
+
+func runUserImport(t *testing.T, server *roleServer, csv string, args ...string) (string, error) {
+	httpServer := httptest.NewServer(userCreatingServer(server))
+	t.Cleanup(httpServer.Close)
+
+	defer func(profile config.Profile) { DefaultProfile = profile }(DefaultProfile)
+	DefaultProfile = config.Profile{URL: httpServer.URL}
+	defer func() {
+		ImportUserCmd.Flags().Set(importDryRunFlag, "false")
+		ImportUserCmd.Flags().Lookup(importDryRunFlag).Changed = false
+		ImportUserCmd.SetArgs(nil)
+		ImportUserCmd.SetIn(nil)
+		ImportUserCmd.SetOut(nil)
+	}()
+
+	var out bytes.Buffer
+	ImportUserCmd.SetArgs(append([]string{"--file", "-"}, args...))
+	ImportUserCmd.SetIn(strings.NewReader(csv))
+	ImportUserCmd.SetOut(&out)
+	ImportUserCmd.SilenceUsage = true
+	ImportUserCmd.SilenceErrors = true
+	err := ImportUserCmd.Execute()
+	return out.String(), err
+}
+
+func importServer() *roleServer {
+	return &roleServer{
+		roles: map[string]json.RawMessage{
+			"reader":   json.RawMessage(`[{"privilege":"reader","resource":{"stream":"*"}}]`),
+			"ingestor": json.RawMessage(`[{"privilege":"ingestor","resource":{"stream":"*"}}]`),
+		},
+		userRoles: map[string][]string{"erin": {"reader"}},
+	}
+}
+
+func TestValidateUserImportFlagsFailingRows(t *testing.T) {
+	rows, err := parseUserImport(strings.NewReader(importCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := validateUserImport(rows, []string{"erin"}, []string{"reader", "ingestor"})
+	if failed != 4 {
+		t.Errorf("expected 4 failing rows, got %d", failed)
+	}
+
+	expected := map[string]string{
+		"alice": "",
+		"bob":   "",
+		"x":     "must be 3 to 64 characters",
+		"carol": "role auditors does not exist",
+		"erin":  "already exists",
+	}
+	for _, row := range rows[:5] {
+		want := expected[row.Name]
+		switch {
+		case want == "" && row.Err != nil:
+			t.Errorf("line %d: expected %s to be valid, got %v", row.Line, row.Name, row.Err)
+		case want != "" && (row.Err == nil || !strings.Contains(row.Err.Error(), want)):
+			t.Errorf("line %d: expected %q, got %v", row.Line, want, row.Err)
+		}
+	}
+	if last := rows[5]; last.Err == nil || !strings.Contains(last.Err.Error(), "already listed on line 2") {
+		t.Errorf("expected the repeated alice to be flagged, got %v", last.Err)
+	}
+	if strings.Join(rows[0].Roles, ",") != "reader,ingestor" {
+		t.Errorf("expected roles split on semicolons, got %v", rows[0].Roles)
+	}
+}
+
+func TestParseUserImportRejectsPasswords(t *testing.T) {
+	_, err := parseUserImport(strings.NewReader("username,roles,password\nbob,reader,Correct-Horse-7\n"))
+	if err == nil || !strings.Contains(err.Error(), "password column") {
+		t.Errorf("expected a password column to be rejected, got %v", err)
+	}
+}
+
+func TestUserImportDryRunChangesNothing(t *testing.T) {
+	server := importServer()
+	out, err := runUserImport(t, server, importCSV, "--dry-run")
+	if err == nil {
+		t.Fatal("expected the dry run to fail with invalid rows")
+	}
+	if len(server.userRoles) != 1 {
+		t.Errorf("expected no users to be created, got %v", server.userRoles)
+	}
+	if !strings.Contains(out, "line 2: create user alice with role(s) reader,ingestor") || !strings.Contains(out, "✗ line 5: role auditors does not exist") {
+		t.Errorf("expected the plan to list created and failing rows, got:\n%s", out)
+	}
+
+	valid := "username,roles\nalice,reader\nbob,\n"
+	if _, err := runUserImport(t, server, valid, "--dry-run"); err != nil {
+		t.Errorf("expected a dry run of valid rows to pass, got %v", err)
+	}
+	if len(server.userRoles) != 1 {
+		t.Errorf("expected no users to be created, got %v", server.userRoles)
+	}
+}
+
+func TestUserImportCreatesUsers(t *testing.T) {
+	server := importServer()
+	if _, err := runUserImport(t, server, importCSV); err == nil {
+		t.Error("expected an import with invalid rows to fail")
+	}
+	if len(server.userRoles) != 1 {
+		t.Fatalf("expected nothing to be imported when a row fails, got %v", server.userRoles)
+	}
+
+	if _, err := runUserImport(t, server, "username,roles\nalice,reader;ingestor\nbob,\n"); err != nil {
+		t.Fatalf("expected the import to succeed, got %v", err)
+	}
+	if strings.Join(server.userRoles["alice"], ",") != "reader,ingestor" {
+		t.Errorf("expected alice to get the roles from the file, got %v", server.userRoles["alice"])
+	}
+	if _, ok := server.userRoles["bob"]; !ok {
+		t.Error("expected bob to be created")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/pflag"
+)
+
+var (
+	expiresInFlag = "expires-in"
+	expiresAtFlag = "expires-at"
+)
+
+// errRoleExpiryUnsupported is returned for --expires-in and --expires-at. The
+// Parseable API cannot grant a role for a limited time and pb never revokes
+// roles itself, so a temporary role is refused rather than granted for good
+var errRoleExpiryUnsupported = fmt.Errorf("--%s and --%s need a server that revokes expired roles, which Parseable does not offer. Nothing was changed. Assign the role without an expiry and remove it with pb user set-role when it is no longer needed", expiresInFlag, expiresAtFlag)
+
+// roleExpiryFromFlags returns when the roles set by pb user set-role expire,
+// or the zero time when neither --expires-in nor --expires-at is set
+func roleExpiryFromFlags(flags *pflag.FlagSet, now time.Time) (time.Time, error) {
+	expiresIn, _ := flags.GetString(expiresInFlag)
+	expiresAt, _ := flags.GetString(expiresAtFlag)
+
+	switch {
+	case expiresIn != "" && expiresAt != "":
+		return time.Time{}, fmt.Errorf("use either --%s or --%s, not both", expiresInFlag, expiresAtFlag)
+	case expiresIn != "":
+		duration, err := parseDaysOrDuration(expiresInFlag, expiresIn)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(duration).UTC().Truncate(time.Second), nil
+	case expiresAt != "":
+		at, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid --%s %q, use an RFC3339 time such as 2024-05-01T18:00:00Z", expiresAtFlag, expiresAt)
+		}
+		if !at.After(now) {
+			return time.Time{}, fmt.Errorf("--%s %s is not in the future", expiresAtFlag, expiresAt)
+		}
+		return at.UTC(), nil
+	}
+	return time.Time{}, nil
+}
+
+// roleExpiry returns when the server revokes role for the user, for servers
+// that report role expiry
+func (u UserData) roleExpiry(role string) (time.Time, bool) {
+	value, ok := u.RoleExpiry[role]
+	if !ok {
+		return time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339, value)
+	return expiry, err == nil
+}
+
+// formatRoleExpiry renders the remaining time of a temporary role
+func formatRoleExpiry(expiry, now time.Time) string {
+	if !expiry.After(now) {
+		return "expired " + humanize.RelTime(expiry, now, "ago", "from now")
+	}
+	return fmt.Sprintf("expires %s (%s)", expiry.Format(time.RFC3339), humanize.RelTime(expiry, now, "ago", "from now"))
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+)
+
+// roleExpiryServer answers for user bob. The returned string holds the body
+// of the last role assignment, if one was made
+func roleExpiryServer(t *testing.T) (*httptest.Server, *string) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/user":
+			w.Write([]byte(`[{"id":"bob","method":"native"}]`))
+		case r.URL.Path == "/api/v1/role":
+			w.Write([]byte(`["admin","reader"]`))
+		case r.URL.Path == "/api/v1/user/bob/role" && r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &body
+}
+
+func runSetUserRole(t *testing.T, url string, args ...string) error {
+	defer func(profile config.Profile) { DefaultProfile = profile }(DefaultProfile)
+	DefaultProfile = config.Profile{URL: url}
+	defer func() {
+		for _, name := range []string{expiresInFlag, expiresAtFlag} {
+			SetUserRoleCmd.Flags().Set(name, "")
+			SetUserRoleCmd.Flags().Lookup(name).Changed = false
+		}
+		SetUserRoleCmd.SetArgs(nil)
+	}()
+
+	SetUserRoleCmd.SetArgs(args)
+	SetUserRoleCmd.SilenceUsage = true
+	return SetUserRoleCmd.Execute()
+}
+
+func TestSetRoleExpiryRefused(t *testing.T) {
+	server, body := roleExpiryServer(t)
+
+	for _, flag := range []string{"--expires-in=4h", "--expires-at=2099-01-01T00:00:00Z"} {
+		err := runSetUserRole(t, server.URL, "bob", "admin", flag)
+		if err != errRoleExpiryUnsupported {
+			t.Errorf("%s: expected the expiry to be refused, got %v", flag, err)
+		}
+		if *body != "" {
+			t.Fatalf("%s: expected no role to be granted, got %s", flag, *body)
+		}
+	}
+
+	// an invalid value is still reported as such
+	if err := runSetUserRole(t, server.URL, "bob", "admin", "--expires-in=soon"); err == nil || !strings.Contains(err.Error(), "invalid --expires-in") {
+		t.Errorf("expected an invalid duration to be reported, got %v", err)
+	}
+
+	if err := runSetUserRole(t, server.URL, "bob", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if *body != `["admin"]` {
+		t.Errorf("expected a permanent assignment, got %s", *body)
+	}
+}
+
+func TestRoleExpiryFromFlags(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	defer func() {
+		for _, name := range []string{expiresInFlag, expiresAtFlag} {
+			SetUserRoleCmd.Flags().Set(name, "")
+		}
+	}()
+	flags := SetUserRoleCmd.Flags()
+
+	flags.Set(expiresInFlag, "2d")
+	if expiry, err := roleExpiryFromFlags(flags, now); err != nil || !expiry.Equal(now.Add(48*time.Hour)) {
+		t.Errorf("expected 2d from now, got %v, %v", expiry, err)
+	}
+
+	flags.Set(expiresAtFlag, "2024-05-02T00:00:00Z")
+	if _, err := roleExpiryFromFlags(flags, now); err == nil {
+		t.Error("expected --expires-in and --expires-at together to be rejected")
+	}
+
+	flags.Set(expiresInFlag, "")
+	flags.Set(expiresAtFlag, "2024-04-30T00:00:00Z")
+	if _, err := roleExpiryFromFlags(flags, now); err == nil || !strings.Contains(err.Error(), "not in the future") {
+		t.Errorf("expected a past expiry to be rejected, got %v", err)
+	}
+
+	flags.Set(expiresAtFlag, "")
+	if _, err := parseDaysOrDuration(expiresInFlag, "0h"); err == nil {
+		t.Error("expected a zero duration to be rejected")
+	}
+}
+
+func TestFormatRoleExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := UserData{ID: "bob", RoleExpiry: map[string]string{"admin": "2024-05-01T15:00:00Z"}}
+
+	expiry, ok := user.roleExpiry("admin")
+	if !ok {
+		t.Fatal("expected the admin role to expire")
+	}
+	if got := formatRoleExpiry(expiry, now); got != "expires 2024-05-01T15:00:00Z (3 hours from now)" {
+		t.Errorf("unexpected remaining time %q", got)
+	}
+	if got := formatRoleExpiry(expiry, now.Add(4*time.Hour)); got != "expired 1 hour ago" {
+		t.Errorf("unexpected expired role %q", got)
+	}
+	if _, ok := user.roleExpiry("reader"); ok {
+		t.Error("expected a permanent role to have no expiry")
+	}
+}
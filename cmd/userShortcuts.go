@@ -0,0 +1,112 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+)
+
+var createMissingRolesFlag = "create-missing-roles"
+
+// roleShortcut is a pb user add flag that assigns a well known role, and the
+// privileges that role is created with by --create-missing-roles
+type roleShortcut struct {
+	flag        string
+	role        string
+	privileges  []RoleData
+	description string
+}
+
+// roleShortcuts cover the common cases without defining roles first. A
+// stream of * grants the privilege on every stream
+var roleShortcuts = []roleShortcut{
+	{
+		flag:        "readonly",
+		role:        "readonly",
+		privileges:  []RoleData{{Privilege: "reader", Resource: &RoleResource{Stream: "*"}}},
+		description: "query every stream, without ingesting or changing anything",
+	},
+	{
+		flag:        "ingest-only",
+		role:        "ingest-only",
+		privileges:  []RoleData{{Privilege: "ingestor", Resource: &RoleResource{Stream: "*"}}},
+		description: "send events to every stream, without querying or changing anything",
+	},
+	{
+		flag:        "admin",
+		role:        "admin",
+		privileges:  []RoleData{{Privilege: "admin"}},
+		description: "full access, including users, roles and server settings",
+	},
+}
+
+func addRoleShortcutFlags(flags *pflag.FlagSet) {
+	for _, shortcut := range roleShortcuts {
+		flags.Bool(shortcut.flag, false, fmt.Sprintf("Assign the %s role: %s", shortcut.role, shortcut.description))
+	}
+	flags.Bool(createMissingRolesFlag, false, "Create the role of --readonly, --ingest-only or --admin when it does not exist yet")
+}
+
+// selectedRoleShortcuts returns the shortcuts whose flags are set
+func selectedRoleShortcuts(flags *pflag.FlagSet) []roleShortcut {
+	var selected []roleShortcut
+	for _, shortcut := range roleShortcuts {
+		if set, _ := flags.GetBool(shortcut.flag); set {
+			selected = append(selected, shortcut)
+		}
+	}
+	return selected
+}
+
+// ensureShortcutRoles checks that the roles of the shortcuts exist on the
+// server. Missing roles are created with the shortcut privileges when create
+// is set, and rejected with guidance otherwise. An existing role is used as
+// it is, whatever its privileges
+func ensureShortcutRoles(client *internalHTTP.HTTPClient, shortcuts []roleShortcut, rolesOnServer []string, create bool, w io.Writer) error {
+	for _, shortcut := range shortcuts {
+		if slices.Contains(rolesOnServer, shortcut.role) {
+			continue
+		}
+		if !create {
+			return fmt.Errorf("role %s for --%s does not exist. Pass --%s to create it with %s, or create it yourself with pb role add %s",
+				shortcut.role, shortcut.flag, createMissingRolesFlag, describePrivileges(shortcut.privileges), shortcut.role)
+		}
+
+		body, _ := json.Marshal(shortcut.privileges)
+		if err := sendRoleRequest(client, http.MethodPut, "role/"+shortcut.role, body); err != nil {
+			return fmt.Errorf("failed to create role %s: %w", shortcut.role, err)
+		}
+		fmt.Fprintf(w, "Created role %s with %s\n", shortcut.role, describePrivileges(shortcut.privileges))
+	}
+	return nil
+}
+
+func describePrivileges(privileges []RoleData) string {
+	descriptions := make([]string, len(privileges))
+	for idx, privilege := range privileges {
+		descriptions[idx] = formatPrivilege(privilege)
+	}
+	return strings.Join(descriptions, " and ")
+}
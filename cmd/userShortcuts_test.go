@@ -0,0 +1,113 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+// userCreatingServer answers the requests that create users on top of the
+// role and user requests of server
+func userCreatingServer(server *roleServer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutPrefix(r.URL.Path, "/api/v1/user/")
+		if !ok || r.Method != http.MethodPost || strings.Contains(name, "/") {
+			server.ServeHTTP(w, r)
+			return
+		}
+		server.mu.Lock()
+		server.userRoles[name] = nil
+		server.mu.Unlock()
+		w.Write([]byte("generated-password"))
+	})
+}
+
+func runUserAdd(t *testing.T, server *roleServer, args ...string) error {
+	httpServer := httptest.NewServer(userCreatingServer(server))
+	t.Cleanup(httpServer.Close)
+
+	defer func(profile config.Profile) { DefaultProfile = profile }(DefaultProfile)
+	DefaultProfile = config.Profile{URL: httpServer.URL}
+	defer func() {
+		for _, shortcut := range roleShortcuts {
+			AddUserCmd.Flags().Set(shortcut.flag, "false")
+		}
+		AddUserCmd.Flags().Set(createMissingRolesFlag, "false")
+		AddUserCmd.SetArgs(nil)
+	}()
+
+	AddUserCmd.SetArgs(args)
+	AddUserCmd.SilenceUsage = true
+	return AddUserCmd.Execute()
+}
+
+func TestUserAddShortcutsCreateMissingRoles(t *testing.T) {
+	cases := map[string]string{
+		"--readonly":    `[{"privilege":"reader","resource":{"stream":"*"}}]`,
+		"--ingest-only": `[{"privilege":"ingestor","resource":{"stream":"*"}}]`,
+		"--admin":       `[{"privilege":"admin"}]`,
+	}
+	for flag, privileges := range cases {
+		t.Run(flag, func(t *testing.T) {
+			server := &roleServer{roles: map[string]json.RawMessage{}, userRoles: map[string][]string{}}
+			if err := runUserAdd(t, server, "bob", flag, "--create-missing-roles"); err != nil {
+				t.Fatalf("expected user add to succeed, got %v", err)
+			}
+
+			role := strings.TrimPrefix(flag, "--")
+			if strings.Join(server.userRoles["bob"], ",") != role {
+				t.Errorf("expected bob to get role %s, got %v", role, server.userRoles["bob"])
+			}
+			if string(server.roles[role]) != privileges {
+				t.Errorf("expected role %s to be created with %s, got %s", role, privileges, server.roles[role])
+			}
+		})
+	}
+}
+
+func TestUserAddShortcutUsesExistingRole(t *testing.T) {
+	existing := json.RawMessage(`[{"privilege":"reader","resource":{"stream":"web"}}]`)
+	server := &roleServer{roles: map[string]json.RawMessage{"readonly": existing}, userRoles: map[string][]string{}}
+	if err := runUserAdd(t, server, "bob", "--readonly"); err != nil {
+		t.Fatalf("expected user add to succeed, got %v", err)
+	}
+	if strings.Join(server.userRoles["bob"], ",") != "readonly" {
+		t.Errorf("expected bob to get the existing readonly role, got %v", server.userRoles["bob"])
+	}
+	if string(server.roles["readonly"]) != string(existing) {
+		t.Errorf("expected the existing role to be left alone, got %s", server.roles["readonly"])
+	}
+}
+
+func TestUserAddShortcutMissingRoleFails(t *testing.T) {
+	server := &roleServer{roles: map[string]json.RawMessage{}, userRoles: map[string][]string{}}
+	err := runUserAdd(t, server, "bob", "--ingest-only")
+	if err == nil || !strings.Contains(err.Error(), "--create-missing-roles") {
+		t.Fatalf("expected an error pointing to --create-missing-roles, got %v", err)
+	}
+	if _, ok := server.userRoles["bob"]; ok {
+		t.Error("expected no user to be created")
+	}
+	if len(server.roles) != 0 {
+		t.Errorf("expected no role to be created, got %v", server.roles)
+	}
+}
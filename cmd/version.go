@@ -25,12 +25,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// versionOutputFormat is the --output of pb version
+var versionOutputFormat string
+
 // VersionCmd is the command for printing version information
 var VersionCmd = &cobra.Command{
 	Use:     "version",
 	Short:   "Print version",
 	Long:    "Print version and commit information",
-	Example: "  pb version",
+	Example: "  pb version\n  pb version --components -o json",
 	Run: func(cmd *cobra.Command, _ []string) {
 		if cmd.Annotations == nil {
 			cmd.Annotations = make(map[string]string)
@@ -50,7 +53,7 @@ var VersionCmd = &cobra.Command{
 }
 
 func init() {
-	VersionCmd.Flags().StringVarP(&outputFormat, "output", "o", "text", "Output format (text|json)")
+	VersionCmd.Flags().StringVarP(&versionOutputFormat, "output", "o", "text", "Output format (text|json)")
 }
 
 // PrintVersion prints version information
@@ -62,23 +65,30 @@ func PrintVersion(version, commit string) error {
 		return fmt.Errorf("error in PreRun: %w", err)
 	}
 
-	about, err := analytics.FetchAbout(&client)
-	if err != nil {
-		return fmt.Errorf("error fetching server information: %w", err)
+	// in offline mode only the client version is known
+	var about analytics.About
+	if !internalHTTP.Offline {
+		var err error
+		about, err = analytics.FetchAbout(&client)
+		if err != nil {
+			return fmt.Errorf("error fetching server information: %w", err)
+		}
 	}
 
 	// Output as JSON if specified
-	if outputFormat == "json" {
+	if versionOutputFormat == "json" {
 		versionInfo := map[string]interface{}{
 			"client": map[string]string{
 				"version": version,
 				"commit":  commit,
 			},
-			"server": map[string]string{
+		}
+		if !internalHTTP.Offline {
+			versionInfo["server"] = map[string]string{
 				"url":     DefaultProfile.URL,
 				"version": about.Version,
 				"commit":  about.Commit,
-			},
+			}
 		}
 		jsonData, err := json.MarshalIndent(versionInfo, "", "  ")
 		if err != nil {
@@ -93,6 +103,11 @@ func PrintVersion(version, commit string) error {
 	fmt.Printf("- %s %s\n", StandardStyleBold.Render("version: "), version)
 	fmt.Printf("- %s %s\n\n", StandardStyleBold.Render("commit:  "), commit)
 
+	if internalHTTP.Offline {
+		fmt.Printf("Server version not checked in offline mode\n\n")
+		return nil
+	}
+
 	fmt.Printf("%s %s \n", StandardStyleAlt.Render("Connected to"), StandardStyleBold.Render(DefaultProfile.URL))
 	fmt.Printf("- %s %s\n", StandardStyleBold.Render("version: "), about.Version)
 	fmt.Printf("- %s %s\n\n", StandardStyleBold.Render("commit:  "), about.Commit)
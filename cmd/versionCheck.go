@@ -0,0 +1,209 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkFlag   = "check"
+	channelFlag = "channel"
+
+	// releasesURL lists pb releases, newest first
+	releasesURL = "https://api.github.com/repos/parseablehq/pb/releases"
+)
+
+// release is the part of a GitHub release used for update checks
+type release struct {
+	TagName    string `json:"tag_name"`
+	Prerelease bool   `json:"prerelease"`
+	Draft      bool   `json:"draft"`
+	URL        string `json:"html_url"`
+}
+
+// UpdateCheck is the result of pb version --check
+type UpdateCheck struct {
+	Channel         string `json:"channel"`
+	Current         string `json:"current"`
+	Latest          string `json:"latest,omitempty"`
+	URL             string `json:"url,omitempty"`
+	UpdateAvailable bool   `json:"update_available"`
+}
+
+// VersionChannelCmd shows or sets the release channel used by update checks
+var VersionChannelCmd = &cobra.Command{
+	Use:     "channel [stable|beta]",
+	Short:   "Show or set the release channel for update checks",
+	Long:    "\nShow or set the release channel used by pb version --check. The stable channel only reports final releases, the beta channel also reports pre-releases such as 1.2.0-beta.1. The choice is saved in the config file.",
+	Example: "  pb version channel\n  pb version channel beta",
+	Args:    cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Annotations == nil {
+			cmd.Annotations = make(map[string]string)
+		}
+		startTime := time.Now()
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		fileConfig, err := config.ReadConfigFromFile()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+
+		if len(args) == 0 {
+			fmt.Println(fileConfig.Channel())
+			return nil
+		}
+
+		channel := args[0]
+		if err := config.ValidateChannel(channel); err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		err = config.UpdateConfig(func(conf *config.Config) error {
+			conf.UpdateChannel = channel
+			return nil
+		})
+		if err != nil {
+			cmd.Annotations["error"] = err.Error()
+			return err
+		}
+		fmt.Printf("Update checks now use the %s channel\n", channel)
+		return nil
+	},
+}
+
+func init() {
+	VersionCmd.Flags().Bool(checkFlag, false, "Check whether a newer pb release is available on the release channel")
+	VersionCmd.Flags().String(channelFlag, "", "Release channel for --check (stable|beta), overrides the saved channel")
+	VersionCmd.AddCommand(VersionChannelCmd)
+}
+
+// RunVersionCheck handles --check for the version command. It is a no-op
+// when the flag is not set
+func RunVersionCheck(cmd *cobra.Command, version string) error {
+	if check, _ := cmd.Flags().GetBool(checkFlag); !check {
+		return nil
+	}
+
+	channel, _ := cmd.Flags().GetString(channelFlag)
+	if channel == "" {
+		fileConfig, _ := config.ReadConfigFromFile()
+		channel = fileConfig.Channel()
+	}
+	if err := config.ValidateChannel(channel); err != nil {
+		return err
+	}
+
+	if err := internalHTTP.CheckOnline(); err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+	releases, err := fetchReleases(&http.Client{Timeout: 10 * time.Second}, releasesURL)
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+	result, err := checkForUpdate(version, channel, releases)
+	if err != nil {
+		return err
+	}
+
+	if versionOutputFormat == "json" {
+		jsonData, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+
+	if result.UpdateAvailable {
+		fmt.Printf("%s pb %s is available on the %s channel, you have %s\n", StandardStyleBold.Render("Update:"), result.Latest, result.Channel, result.Current)
+		fmt.Printf("  %s\n", result.URL)
+		return nil
+	}
+	fmt.Printf("pb %s is up to date on the %s channel\n", result.Current, result.Channel)
+	return nil
+}
+
+func fetchReleases(client *http.Client, url string) ([]release, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("request failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var releases []release
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to decode releases: %w", err)
+	}
+	return releases, nil
+}
+
+// checkForUpdate compares the running version with the newest release in
+// channel. The stable channel skips pre-releases, the beta channel includes
+// them. Versions are ordered by semver precedence, so 1.2.0-beta.2 is newer
+// than 1.2.0-beta.1 and older than 1.2.0, and an older stable release is never
+// offered to someone on a newer pre-release
+func checkForUpdate(current, channel string, releases []release) (UpdateCheck, error) {
+	result := UpdateCheck{Channel: channel, Current: current}
+	running, err := semver.NewVersion(current)
+	if err != nil {
+		return result, fmt.Errorf("cannot check for updates, %q is not a release version", current)
+	}
+
+	var latest *semver.Version
+	for _, rel := range releases {
+		if rel.Draft {
+			continue
+		}
+		version, err := semver.NewVersion(rel.TagName)
+		if err != nil {
+			continue
+		}
+		if channel == config.ChannelStable && (rel.Prerelease || version.Prerelease() != "") {
+			continue
+		}
+		if latest == nil || version.GreaterThan(latest) {
+			latest = version
+			result.Latest = version.String()
+			result.URL = rel.URL
+		}
+	}
+
+	result.UpdateAvailable = latest != nil && latest.GreaterThan(running)
+	return result, nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+var testReleases = []release{
+	{TagName: "v1.3.0-rc.1", Prerelease: true},
+	{TagName: "v1.3.0-beta.10", Prerelease: true},
+	{TagName: "v1.3.0-beta.2", Prerelease: true},
+	{TagName: "v1.2.0"},
+	{TagName: "v1.4.0", Draft: true},
+	{TagName: "nightly", Prerelease: true},
+	{TagName: "v1.1.0"},
+}
+
+func TestCheckForUpdateStaysInChannel(t *testing.T) {
+	cases := []struct {
+		current, channel, latest string
+		update                   bool
+	}{
+		{"1.1.0", config.ChannelStable, "1.2.0", true},
+		{"1.2.0", config.ChannelStable, "1.2.0", false},
+		{"1.2.0", config.ChannelBeta, "1.3.0-rc.1", true},
+		// beta.10 sorts after beta.2 by semver, not by string
+		{"1.3.0-beta.2", config.ChannelBeta, "1.3.0-rc.1", true},
+		// a beta user on the stable channel is not offered an older release
+		{"1.3.0-beta.2", config.ChannelStable, "1.2.0", false},
+		{"v1.3.0-rc.1", config.ChannelBeta, "1.3.0-rc.1", false},
+	}
+
+	for _, c := range cases {
+		result, err := checkForUpdate(c.current, c.channel, testReleases)
+		if err != nil {
+			t.Fatalf("%s on %s: %v", c.current, c.channel, err)
+		}
+		if result.Latest != c.latest || result.UpdateAvailable != c.update {
+			t.Errorf("%s on %s: expected latest %s update %v, got %s %v", c.current, c.channel, c.latest, c.update, result.Latest, result.UpdateAvailable)
+		}
+	}
+}
+
+func TestCheckForUpdateOrdersPrereleases(t *testing.T) {
+	releases := []release{{TagName: "1.3.0-beta.2"}, {TagName: "1.3.0-beta.10"}, {TagName: "1.3.0-alpha.5"}}
+	result, err := checkForUpdate("1.3.0-beta.9", config.ChannelBeta, releases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Latest != "1.3.0-beta.10" || !result.UpdateAvailable {
+		t.Errorf("expected 1.3.0-beta.10 to be offered, got %+v", result)
+	}
+}
+
+func TestCheckForUpdateRejectsDevBuild(t *testing.T) {
+	if _, err := checkForUpdate("", config.ChannelStable, testReleases); err == nil {
+		t.Error("expected an unversioned build to be rejected")
+	}
+}
+
+func TestFetchReleases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`[{"tag_name":"v1.2.0","prerelease":false,"html_url":"https://example.com/v1.2.0"}]`))
+	}))
+	defer server.Close()
+
+	releases, err := fetchReleases(server.Client(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 1 || releases[0].TagName != "v1.2.0" || releases[0].URL != "https://example.com/v1.2.0" {
+		t.Errorf("unexpected releases %+v", releases)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"pb/pkg/analytics"
+	internalHTTP "pb/pkg/http"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// ComponentsFlag asks pb version for the client and server versions
+var ComponentsFlag = "components"
+
+func init() {
+	VersionCmd.Flags().Bool(ComponentsFlag, false, "Show the pb version together with the version of the server of the default profile")
+}
+
+// Compatibility verdicts reported by pb version --components
+const (
+	verdictCompatible   = "compatible"
+	verdictClientTooOld = "client-too-old"
+	verdictServerTooOld = "server-too-old"
+	verdictUnknown      = "unknown"
+)
+
+// compatibility lists the Parseable server releases supported by a range of
+// pb releases. minServer is the oldest supported server, maxServer the first
+// server release that is no longer supported, empty while there is none
+type compatibility struct {
+	client    string
+	minServer string
+	maxServer string
+}
+
+// compatibilityTable is checked from top to bottom, the first entry whose
+// client constraint matches applies. Only add an entry for a range that the
+// pb or Parseable release notes state. A pair without an entry is reported
+// as unknown rather than guessed. Neither project publishes such ranges yet
+// and the server does not advertise one in /about, so the table is empty
+// and every pair is reported as unknown
+var compatibilityTable []compatibility
+
+// ComponentVersion is the version of pb or of the server it talks to
+type ComponentVersion struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// ComponentsReport is the output of pb version --components
+type ComponentsReport struct {
+	Client           ComponentVersion  `json:"client"`
+	Server           *ComponentVersion `json:"server,omitempty"`
+	Compatibility    string            `json:"compatibility"`
+	SupportedServers string            `json:"supported_servers,omitempty"`
+	Note             string            `json:"note,omitempty"`
+}
+
+// compatibilityVerdict looks up the servers supported by the client release
+// and places server among them. It also returns the supported range, empty
+// when the client version is not in the table
+func compatibilityVerdict(client, server string) (string, string) {
+	clientVersion, err := semver.NewVersion(client)
+	if err != nil {
+		return verdictUnknown, ""
+	}
+	// pre-releases are checked as the release they lead up to
+	core, _ := semver.NewVersion(fmt.Sprintf("%d.%d.%d", clientVersion.Major(), clientVersion.Minor(), clientVersion.Patch()))
+
+	for _, entry := range compatibilityTable {
+		constraint, err := semver.NewConstraint(entry.client)
+		if err != nil || !constraint.Check(core) {
+			continue
+		}
+		supported := ">= " + entry.minServer
+		if entry.maxServer != "" {
+			supported += ", < " + entry.maxServer
+		}
+
+		serverVersion, err := semver.NewVersion(server)
+		if err != nil {
+			return verdictUnknown, supported
+		}
+		if serverVersion.LessThan(semver.MustParse(entry.minServer)) {
+			return verdictServerTooOld, supported
+		}
+		if entry.maxServer != "" && !serverVersion.LessThan(semver.MustParse(entry.maxServer)) {
+			return verdictClientTooOld, supported
+		}
+		return verdictCompatible, supported
+	}
+	return verdictUnknown, ""
+}
+
+// componentsReport builds the report for pb version --components. client is
+// nil when no profile is available, the report then only covers pb itself
+func componentsReport(client *internalHTTP.HTTPClient, version, commit string) ComponentsReport {
+	report := ComponentsReport{
+		Client:        ComponentVersion{Version: version, Commit: commit},
+		Compatibility: verdictUnknown,
+	}
+
+	switch {
+	case internalHTTP.Offline:
+		report.Note = "server version not checked in offline mode"
+	case client == nil:
+		report.Note = "no profile to reach a server, add one with pb profile add"
+	default:
+		about, err := analytics.FetchAbout(client)
+		if err != nil {
+			report.Note = fmt.Sprintf("server at %s is not reachable: %v", client.Profile.URL, err)
+			break
+		}
+		report.Server = &ComponentVersion{Version: about.Version, Commit: about.Commit, URL: client.Profile.URL}
+	}
+
+	if report.Server != nil {
+		report.Compatibility, report.SupportedServers = compatibilityVerdict(version, report.Server.Version)
+	} else {
+		_, report.SupportedServers = compatibilityVerdict(version, "")
+	}
+	if report.Compatibility == verdictUnknown && report.Note == "" {
+		if report.SupportedServers == "" {
+			report.Note = fmt.Sprintf("the compatibility table has no entry for pb %q", version)
+		} else {
+			report.Note = fmt.Sprintf("server version %q cannot be compared", report.Server.Version)
+		}
+	}
+	return report
+}
+
+// PrintComponents prints the pb and server versions with a compatibility
+// verdict. It returns an error when compatibilityTable marks the two as
+// incompatible, which cannot happen while the table is empty
+func PrintComponents(version, commit string) error {
+	var client *internalHTTP.HTTPClient
+	if err := PreRun(); err == nil {
+		httpClient := internalHTTP.DefaultClient(&DefaultProfile)
+		client = &httpClient
+	}
+	report := componentsReport(client, version, commit)
+
+	if versionOutputFormat == "json" {
+		// keep the version ranges readable, json escapes < and > by default
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("error generating JSON output: %w", err)
+		}
+	} else {
+		printComponents(report)
+	}
+
+	if report.Compatibility == verdictClientTooOld || report.Compatibility == verdictServerTooOld {
+		return fmt.Errorf("pb %s does not support Parseable %s: %s", version, report.Server.Version, report.Compatibility)
+	}
+	return nil
+}
+
+func printComponents(report ComponentsReport) {
+	fmt.Printf("\n%s \n", StandardStyleAlt.Render("pb client"))
+	fmt.Printf("- %s %s\n", StandardStyleBold.Render("version: "), report.Client.Version)
+	fmt.Printf("- %s %s\n\n", StandardStyleBold.Render("commit:  "), report.Client.Commit)
+
+	if report.Server != nil {
+		fmt.Printf("%s %s \n", StandardStyleAlt.Render("Parseable server"), StandardStyleBold.Render(report.Server.URL))
+		fmt.Printf("- %s %s\n", StandardStyleBold.Render("version: "), report.Server.Version)
+		fmt.Printf("- %s %s\n\n", StandardStyleBold.Render("commit:  "), report.Server.Commit)
+	}
+
+	fmt.Printf("%s %s\n", StandardStyleBold.Render("Compatibility:"), report.Compatibility)
+	if report.SupportedServers != "" {
+		fmt.Printf("- %s %s\n", StandardStyleBold.Render("supported servers:"), report.SupportedServers)
+	}
+	if report.Note != "" {
+		fmt.Printf("- %s %s\n", StandardStyleBold.Render("note:"), report.Note)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
+)
+
+func withCompatibilityTable(t *testing.T) {
+	previous := compatibilityTable
+	compatibilityTable = []compatibility{
+		{client: "< 1.0.0", minServer: "0.9.0", maxServer: "2.0.0"},
+		{client: ">= 1.0.0", minServer: "1.5.0"},
+	}
+	t.Cleanup(func() { compatibilityTable = previous })
+}
+
+func TestCompatibilityVerdicts(t *testing.T) {
+	withCompatibilityTable(t)
+
+	cases := []struct {
+		client, server, verdict, supported string
+	}{
+		{"v0.8.0", "v1.2.0", verdictCompatible, ">= 0.9.0, < 2.0.0"},
+		{"v0.8.0", "v0.8.5", verdictServerTooOld, ">= 0.9.0, < 2.0.0"},
+		{"v0.8.0", "v2.0.0", verdictClientTooOld, ">= 0.9.0, < 2.0.0"},
+		{"v1.1.0", "v2.3.1", verdictCompatible, ">= 1.5.0"},
+		{"v1.1.0", "v1.4.9", verdictServerTooOld, ">= 1.5.0"},
+		// a pre-release is checked as the release it leads up to
+		{"v1.0.0-beta.1", "v1.5.0", verdictCompatible, ">= 1.5.0"},
+		{"v1.1.0", "main", verdictUnknown, ">= 1.5.0"},
+		{"", "v1.5.0", verdictUnknown, ""},
+	}
+	for _, c := range cases {
+		verdict, supported := compatibilityVerdict(c.client, c.server)
+		if verdict != c.verdict || supported != c.supported {
+			t.Errorf("pb %q with server %q: expected %s (%s), got %s (%s)", c.client, c.server, c.verdict, c.supported, verdict, supported)
+		}
+	}
+}
+
+func TestUnlistedPairIsUnknown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"version":"v2.1.0","commit":"f00"}`))
+	}))
+	defer server.Close()
+
+	// the shipped table has no entry for this release
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	report := componentsReport(&client, "v0.5.0", "abc")
+	if report.Compatibility != verdictUnknown || report.SupportedServers != "" {
+		t.Errorf("expected an unknown verdict for an unlisted pair, got %q (%s)", report.Compatibility, report.SupportedServers)
+	}
+	if !strings.Contains(report.Note, "no entry for pb") {
+		t.Errorf("expected a note about the missing entry, got %q", report.Note)
+	}
+}
+
+func TestComponentsReportWithServer(t *testing.T) {
+	withCompatibilityTable(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"version":"v2.1.0","commit":"f00"}`))
+	}))
+	defer server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: server.URL})
+	report := componentsReport(&client, "v0.9.0", "abc")
+	if report.Server == nil || report.Server.Version != "v2.1.0" || report.Server.URL != server.URL {
+		t.Fatalf("expected the server version in the report, got %+v", report.Server)
+	}
+	if report.Compatibility != verdictClientTooOld || report.Note != "" {
+		t.Errorf("expected client-too-old without a note, got %q, %q", report.Compatibility, report.Note)
+	}
+}
+
+func TestComponentsReportWithoutServer(t *testing.T) {
+	withCompatibilityTable(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := internalHTTP.DefaultClient(&config.Profile{URL: url})
+	report := componentsReport(&client, "v1.2.0", "abc")
+	if report.Server != nil || report.Client.Version != "v1.2.0" {
+		t.Errorf("expected only the client in the report, got %+v", report)
+	}
+	if report.Compatibility != verdictUnknown || !strings.Contains(report.Note, "not reachable") {
+		t.Errorf("expected an unknown verdict with a note, got %q, %q", report.Compatibility, report.Note)
+	}
+	if report.SupportedServers != ">= 1.5.0" {
+		t.Errorf("expected the supported servers to still be listed, got %q", report.SupportedServers)
+	}
+}
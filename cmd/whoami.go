@@ -0,0 +1,168 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	internalHTTP "pb/pkg/http"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// WhoamiData describes the identity behind the active profile
+type WhoamiData struct {
+	Profile  string       `json:"profile"`
+	URL      string       `json:"url"`
+	Username string       `json:"username"`
+	Auth     string       `json:"auth"`
+	Roles    UserRoleData `json:"roles,omitempty"`
+	// RolesError explains why roles are missing, e.g. the user may not read them
+	RolesError string `json:"roles_error,omitempty"`
+}
+
+var WhoamiCmd = &cobra.Command{
+	Use:     "whoami",
+	Short:   "Show the user and roles of the active profile",
+	Example: "  pb user whoami\n  pb user whoami -o json",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		startTime := time.Now()
+		cmd.Annotations = make(map[string]string)
+		defer func() {
+			cmd.Annotations["executionTime"] = time.Since(startTime).String()
+		}()
+
+		data := WhoamiData{
+			Profile:  DefaultProfileName,
+			URL:      DefaultProfile.URL,
+			Username: DefaultProfile.Username,
+			Auth:     "basic",
+		}
+		if DefaultProfile.Token != "" {
+			data.Auth = "token"
+		}
+		if DefaultProfile.UsesOAuth() {
+			data.Auth = DefaultProfile.AuthMode
+		}
+		if DefaultProfile.SignsRequests() {
+			data.Auth += ", hmac signed"
+		}
+
+		if DefaultProfile.Username == "" {
+			data.RolesError = "the profile has no username to look up roles for"
+		} else {
+			client := internalHTTP.DefaultClient(&DefaultProfile)
+			roles, err := fetchOwnRoles(&client, DefaultProfile.Username)
+			if errors.Is(err, errCredentialsRejected) {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
+			if err != nil {
+				data.RolesError = err.Error()
+			}
+			data.Roles = roles
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		if output == "json" {
+			jsonOutput, err := json.MarshalIndent(data, "", "  ")
+			if err != nil {
+				cmd.Annotations["error"] = err.Error()
+				return err
+			}
+			fmt.Println(string(jsonOutput))
+			return nil
+		}
+
+		fmt.Println()
+		fmt.Printf("%s %s\n", StandardStyle.Render("Profile: "), StandardStyleAlt.Render(data.Profile))
+		fmt.Printf("%s %s\n", StandardStyle.Render("URL:     "), StandardStyleAlt.Render(data.URL))
+		fmt.Printf("%s %s\n", StandardStyle.Render("User:    "), StandardStyleAlt.Render(data.Username))
+		fmt.Printf("%s %s\n", StandardStyle.Render("Auth:    "), StandardStyleAlt.Render(data.Auth))
+		fmt.Println()
+
+		if data.RolesError != "" {
+			fmt.Printf("Roles unavailable: %s\n", data.RolesError)
+			return nil
+		}
+		if len(data.Roles) == 0 {
+			fmt.Println("No roles assigned")
+			return nil
+		}
+
+		names := make([]string, 0, len(data.Roles))
+		for name := range data.Roles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Print("• ")
+			fmt.Println(StandardStyleBold.Bold(true).Render(name))
+			for _, privilege := range data.Roles[name] {
+				fmt.Println(lipgloss.NewStyle().PaddingLeft(3).Render(privilege.Render()))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	WhoamiCmd.Flags().StringP("output", "o", "", "Output format: 'text' or 'json'")
+}
+
+var errCredentialsRejected = errors.New("the server rejected the credentials of the active profile")
+
+// fetchOwnRoles reads the roles of the authenticated user. Unlike
+// fetchUserRoles it tells rejected credentials apart from a user that is
+// not allowed to read its own roles
+func fetchOwnRoles(client *internalHTTP.HTTPClient, username string) (UserRoleData, error) {
+	req, err := client.NewRequest(http.MethodGet, fmt.Sprintf("user/%s/role", username), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var roles UserRoleData
+		err = json.Unmarshal(body, &roles)
+		return roles, err
+	case http.StatusUnauthorized:
+		return nil, errCredentialsRejected
+	case http.StatusForbidden:
+		return nil, errors.New("this user is not allowed to read its roles")
+	default:
+		return nil, fmt.Errorf("request failed with status %s", resp.Status)
+	}
+}
@@ -25,8 +25,10 @@ import (
 	pb "pb/cmd"
 	"pb/pkg/analytics"
 	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var wg sync.WaitGroup
@@ -40,6 +42,8 @@ var (
 var (
 	versionFlag      = "version"
 	versionFlagShort = "v"
+
+	strictPermissionsFlag = "strict-permissions"
 )
 
 func defaultInitialProfile() config.Profile {
@@ -64,7 +68,7 @@ var cli = &cobra.Command{
 		return errors.New("no command or flag supplied")
 	},
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -81,7 +85,7 @@ var profile = &cobra.Command{
 	Long:              "\nuse profile command to configure different Parseable instances. Each profile takes a URL and credentials.",
 	PersistentPreRunE: combinedPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -107,7 +111,7 @@ Examples:
 `,
 	PersistentPreRunE: combinedPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -124,7 +128,7 @@ var user = &cobra.Command{
 	Long:              "\nuser command is used to manage users.",
 	PersistentPreRunE: combinedPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -141,7 +145,7 @@ var role = &cobra.Command{
 	Long:              "\nrole command is used to manage roles.",
 	PersistentPreRunE: combinedPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -158,7 +162,7 @@ var stream = &cobra.Command{
 	Long:              "\nstream command is used to manage streams.",
 	PersistentPreRunE: combinedPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -175,7 +179,7 @@ var query = &cobra.Command{
 	Long:              "\nRun SQL query on a log stream. Default output format is json. Use -i flag to open interactive table view.",
 	PersistentPreRunE: combinedPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -190,9 +194,9 @@ var cluster = &cobra.Command{
 	Use:               "cluster",
 	Short:             "Cluster operations for Parseable.",
 	Long:              "\nCluster operations for Parseable cluster on Kubernetes.",
-	PersistentPreRunE: combinedPreRun,
+	PersistentPreRunE: clusterPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -207,9 +211,9 @@ var list = &cobra.Command{
 	Use:               "list",
 	Short:             "List parseable on kubernetes cluster",
 	Long:              "\nlist command is used to list Parseable oss installations.",
-	PersistentPreRunE: combinedPreRun,
+	PersistentPreRunE: clusterPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -224,9 +228,9 @@ var show = &cobra.Command{
 	Use:               "show",
 	Short:             "Show outputs values defined when installing Parseable on kubernetes cluster",
 	Long:              "\nshow command is used to get values in Parseable.",
-	PersistentPreRunE: combinedPreRun,
+	PersistentPreRunE: clusterPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -241,9 +245,9 @@ var uninstall = &cobra.Command{
 	Use:               "uninstall",
 	Short:             "Uninstall Parseable on kubernetes cluster",
 	Long:              "\nuninstall command is used to uninstall Parseable oss/enterprise on k8s cluster.",
-	PersistentPreRunE: combinedPreRun,
+	PersistentPreRunE: clusterPreRun,
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		if os.Getenv("PB_ANALYTICS") == "disable" {
+		if !analytics.Enabled() {
 			return
 		}
 		wg.Add(1)
@@ -254,28 +258,76 @@ var uninstall = &cobra.Command{
 	},
 }
 
+var alias = &cobra.Command{
+	Use:               "alias",
+	Short:             "Manage command aliases",
+	Long:              "\nalias command saves pb command lines under a name of your own, so pb <alias-name> runs them.",
+	PersistentPreRunE: combinedPreRun,
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if !analytics.Enabled() {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			analytics.PostRunAnalytics(cmd, "alias", args)
+		}()
+	},
+}
+
+// analyticsCmd neither creates an install ID nor reports usage itself, so
+// inspecting or purging analytics never adds to it
+var analyticsCmd = &cobra.Command{
+	Use:               "analytics",
+	Short:             "Inspect usage analytics",
+	Long:              "\nanalytics command shows what usage data pb collects and lets you turn it off.",
+	PersistentPreRun:  func(_ *cobra.Command, _ []string) {},
+	PersistentPostRun: func(_ *cobra.Command, _ []string) {},
+}
+
+// strictPermissionsRequested looks for --strict-permissions in args ahead of
+// the cobra parse, ignoring every other flag
+func strictPermissionsRequested(args []string) bool {
+	flags := pflag.NewFlagSet(strictPermissionsFlag, pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.Usage = func() {}
+	strict := flags.Bool(strictPermissionsFlag, false, "")
+	_ = flags.Parse(args)
+	return *strict
+}
+
 func main() {
 	profile.AddCommand(pb.AddProfileCmd)
 	profile.AddCommand(pb.RemoveProfileCmd)
 	profile.AddCommand(pb.ListProfileCmd)
 	profile.AddCommand(pb.DefaultProfileCmd)
+	profile.AddCommand(pb.MigrateProfileCmd)
+	profile.AddCommand(pb.EnvProfileCmd)
+	profile.AddCommand(pb.SetProfileCmd)
 
 	user.AddCommand(pb.AddUserCmd)
 	user.AddCommand(pb.RemoveUserCmd)
 	user.AddCommand(pb.ListUserCmd)
 	user.AddCommand(pb.SetUserRoleCmd)
+	user.AddCommand(pb.ResetUserPasswordCmd)
+	user.AddCommand(pb.WhoamiCmd)
+	user.AddCommand(pb.ImportUserCmd)
 
 	role.AddCommand(pb.AddRoleCmd)
 	role.AddCommand(pb.RemoveRoleCmd)
 	role.AddCommand(pb.ListRoleCmd)
+	role.AddCommand(pb.RenameRoleCmd)
+	role.AddCommand(pb.ApplyRoleCmd)
 
 	stream.AddCommand(pb.AddStreamCmd)
 	stream.AddCommand(pb.RemoveStreamCmd)
 	stream.AddCommand(pb.ListStreamCmd)
 	stream.AddCommand(pb.StatStreamCmd)
+	stream.AddCommand(pb.SetRetentionStreamCmd)
 
 	query.AddCommand(pb.QueryCmd)
 	query.AddCommand(pb.SavedQueryList)
+	query.AddCommand(pb.QueryCacheCmd)
 
 	schema.AddCommand(pb.GenerateSchemaCmd)
 	schema.AddCommand(pb.CreateSchemaCmd)
@@ -285,6 +337,12 @@ func main() {
 	cluster.AddCommand(pb.ShowValuesCmd)
 	cluster.AddCommand(pb.UninstallOssCmd)
 
+	analyticsCmd.AddCommand(pb.AnalyticsStatusCmd)
+
+	alias.AddCommand(pb.AddAliasCmd)
+	alias.AddCommand(pb.ListAliasCmd)
+	alias.AddCommand(pb.RemoveAliasCmd)
+
 	list.AddCommand(pb.ListOssCmd)
 
 	uninstall.AddCommand(pb.UninstallOssCmd)
@@ -297,57 +355,96 @@ func main() {
 	cli.AddCommand(user)
 	cli.AddCommand(role)
 	cli.AddCommand(pb.TailCmd)
+	cli.AddCommand(pb.IngestCmd)
+	cli.AddCommand(pb.ShellCmd)
 	cli.AddCommand(cluster)
+	cli.AddCommand(analyticsCmd)
+	cli.AddCommand(alias)
 
 	cli.AddCommand(pb.AutocompleteCmd)
 
 	// Set as command
-	pb.VersionCmd.Run = func(_ *cobra.Command, _ []string) {
+	pb.VersionCmd.Run = nil
+	pb.VersionCmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		cmd.SilenceUsage = true
+		if components, _ := cmd.Flags().GetBool(pb.ComponentsFlag); components {
+			return pb.PrintComponents(Version, Commit)
+		}
 		pb.PrintVersion(Version, Commit)
+		return pb.RunVersionCheck(cmd, Version)
 	}
 
 	cli.AddCommand(pb.VersionCmd)
 	// set as flag
 	cli.Flags().BoolP(versionFlag, versionFlagShort, false, "Print version")
+	cli.PersistentFlags().BoolVar(&internalHTTP.TraceEnabled, "trace", false, "Print DNS, connect, TLS, first byte and total timings for each request to stderr")
+	cli.PersistentFlags().StringVar(&internalHTTP.MinTLSVersion, "min-tls-version", "", "Lowest TLS version accepted from the server (1.0|1.1|1.2|1.3), overrides the profile setting. Defaults to "+internalHTTP.DefaultMinTLSVersion)
+
+	cli.PersistentFlags().IntVar(&internalHTTP.MaxRetries, "max-retries", internalHTTP.DefaultMaxRetries, "Retries of a request while the server is unavailable (502, 503 or 504), honoring its Retry-After header. 0 disables retries")
+	cli.PersistentFlags().BoolVar(&config.StrictPermissions, strictPermissionsFlag, false, "Refuse to read a config file that other users can read instead of warning")
+	cli.PersistentFlags().BoolVar(&internalHTTP.NoConditionalCache, "no-http-cache", false, "Do not keep GET responses for revalidation with ETag and Last-Modified, download them in full every time")
+	cli.PersistentFlags().DurationVar(&internalHTTP.SlowThreshold, "slow-threshold", 0, "Warn on stderr when a request takes longer than this duration, e.g. 2s. 0 turns the warnings off")
+	cli.PersistentFlags().BoolVar(&internalHTTP.Offline, "offline", false, "Make no network calls. Commands that need the server or a Kubernetes cluster fail at once, and analytics is not sent")
+	cli.PersistentFlags().BoolVarP(&pb.AssumeYes, pb.AssumeYesFlag, "y", false, "Answer yes to confirmation prompts, also --assume-yes. Without it, destructive commands fail when stdin is not a terminal")
+	cli.SetGlobalNormalizationFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		return pflag.NormalizedName(pb.NormalizeAssumeYes(name))
+	})
 
 	cli.CompletionOptions.HiddenDefaultCmd = true
 
-	// create a default profile if file does not exist
-	if previousConfig, err := config.ReadConfigFromFile(); os.IsNotExist(err) {
-		conf := config.Config{
-			Profiles:       map[string]config.Profile{"demo": defaultInitialProfile()},
-			DefaultProfile: "demo",
-		}
-		err = config.WriteConfigToFile(&conf)
-		if err != nil {
-			fmt.Printf("failed to write to file %v\n", err)
-			os.Exit(1)
+	// the config is read below, before cobra parses the command line
+	config.StrictPermissions = strictPermissionsRequested(os.Args[1:])
+
+	// create a default profile if file does not exist. The config is updated
+	// under the config lock, as other pb commands may be running
+	var flagDefaults, profileDefaults map[string]interface{}
+	var aliases map[string]string
+	err := config.UpdateConfig(func(conf *config.Config) error {
+		if conf.Profiles == nil {
+			conf.Profiles = make(map[string]config.Profile)
 		}
-	} else {
 		// Only update the "demo" profile without overwriting other profiles
-		demoProfile, exists := previousConfig.Profiles["demo"]
+		demoProfile, exists := conf.Profiles["demo"]
 		if exists {
 			// Update fields in the demo profile only
 			demoProfile.URL = "http://demo.parseable.com"
 			demoProfile.Username = "admin"
 			demoProfile.Password = "admin"
-			previousConfig.Profiles["demo"] = demoProfile
+			conf.Profiles["demo"] = demoProfile
 		} else {
 			// Add the "demo" profile if it doesn't exist
-			previousConfig.Profiles["demo"] = defaultInitialProfile()
-			previousConfig.DefaultProfile = "demo" // Optional: set as default if needed
+			conf.Profiles["demo"] = defaultInitialProfile()
+			conf.DefaultProfile = "demo" // Optional: set as default if needed
 		}
+		flagDefaults = conf.Defaults
+		profileDefaults = conf.Profiles[conf.DefaultProfile].Defaults
+		aliases = conf.Aliases
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("failed to update config file %v\n", err)
+		os.Exit(1)
+	}
 
-		// Write the updated configuration back to file
-		err = config.WriteConfigToFile(previousConfig)
-		if err != nil {
-			fmt.Printf("failed to write to existing file %v\n", err)
-			os.Exit(1)
-		}
+	if err := pb.ApplyFlagDefaults(cli, flagDefaults, profileDefaults, os.Getenv); err != nil {
+		fmt.Printf("failed to apply flag defaults from the environment or config file: %v\n", err)
+		os.Exit(1)
 	}
 
-	err := cli.Execute()
+	args, err := pb.ExpandAlias(cli, os.Args[1:], aliases)
 	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	cli.SetArgs(args)
+
+	err = cli.Execute()
+	internalHTTP.ReportSlowRequests(os.Stderr)
+	if err != nil {
+		var exitErr *pb.ExitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 	wg.Wait()
@@ -366,3 +463,12 @@ func combinedPreRun(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// clusterPreRun fails cluster commands in offline mode before they try to
+// reach the Kubernetes API, which does not go through pb's HTTP client
+func clusterPreRun(cmd *cobra.Command, args []string) error {
+	if err := internalHTTP.CheckOnline(); err != nil {
+		return err
+	}
+	return combinedPreRun(cmd, args)
+}
@@ -51,6 +51,28 @@ type Event struct {
 	ExecutionTimestamp string  `json:"execution_timestamp"`
 }
 
+// EventField describes one field of the usage event
+type EventField struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// EventFields documents every field PostRunAnalytics sends, in the order of
+// Event. Keep it in sync when Event changes
+var EventFields = []EventField{
+	{"cli_version", "commit of the Parseable server the active profile points to"},
+	{"ulid", "random ID of this install, not derived from any user or machine data"},
+	{"commit_hash", "commit of the Parseable server the active profile points to"},
+	{"os_name", "operating system, e.g. Linux"},
+	{"os_version", "operating system version or distribution"},
+	{"report_created_at", "time the event was created"},
+	{"command.name", "command group that ran, e.g. query"},
+	{"command.arguments", "positional arguments of the command followed by its name"},
+	{"command.flags", "name and value of every flag of the command"},
+	{"errors", "error of the command, if any"},
+	{"execution_timestamp", "how long the command took to run"},
+}
+
 // About struct
 type About struct {
 	Version         string    `json:"version"`
@@ -92,17 +114,84 @@ type Command struct {
 // Config struct for parsing YAML
 type Config struct {
 	ULID string `yaml:"ulid"`
+	// Disabled turns analytics off for this install, see Purge
+	Disabled bool `yaml:"disabled,omitempty"`
 }
 
-// CheckAndCreateULID checks for a ULID in the config file and creates it if absent.
-func CheckAndCreateULID(_ *cobra.Command, _ []string) error {
+// DisableEnv is the environment variable that turns analytics off when set
+// to "disable"
+const DisableEnv = "PB_ANALYTICS"
+
+// EventsURL is where usage events are sent
+const EventsURL = "https://analytics.parseable.io:80/pb"
+
+// ConfigPath returns the file that stores the install ULID
+func ConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Printf("could not find home directory: %v\n", err)
+		return "", fmt.Errorf("could not find home directory: %v", err)
+	}
+	return filepath.Join(homeDir, ".parseable", "config.yaml"), nil
+}
+
+// readConfig returns the analytics config, empty when the file does not exist
+func readConfig() (Config, error) {
+	var config Config
+	configPath, err := ConfigPath()
+	if err != nil {
+		return config, err
+	}
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, fmt.Errorf("could not read config file: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("could not parse config file: %v", err)
+	}
+	return config, nil
+}
+
+// Enabled reports whether usage events are sent, which is the case unless
+// the environment or the analytics config turns them off, or pb runs in
+// offline mode
+func Enabled() bool {
+	if os.Getenv(DisableEnv) == "disable" || internalHTTP.Offline {
+		return false
+	}
+	config, err := readConfig()
+	return err != nil || !config.Disabled
+}
+
+// Purge deletes the stored ULID and turns analytics off for this install
+func Purge() error {
+	configPath, err := ConfigPath()
+	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		return fmt.Errorf("could not create config directory: %v", err)
+	}
+	data, err := yaml.Marshal(&Config{Disabled: true})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, data, 0o644)
+}
+
+// CheckAndCreateULID checks for a ULID in the config file and creates it if
+// absent. Nothing is created while analytics is disabled
+func CheckAndCreateULID(_ *cobra.Command, _ []string) error {
+	if !Enabled() {
+		return nil
+	}
 
-	configPath := filepath.Join(homeDir, ".parseable", "config.yaml")
+	configPath, err := ConfigPath()
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
 
 	// Check if config path exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -214,11 +303,8 @@ func sendEvent(commandName string, arguments []string, errors *string, execution
 		return fmt.Errorf("failed to marshal event JSON: %v", err)
 	}
 
-	// Define the target URL for the HTTP request
-	url := "https://analytics.parseable.io:80/pb"
-
 	// Create the HTTP POST request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(eventJSON))
+	req, err := http.NewRequest("POST", EventsURL, bytes.NewBuffer(eventJSON))
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP request: %v", err)
 	}
@@ -325,13 +411,11 @@ func getWindowsVersion() string {
 }
 
 func ReadUULD() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	configPath, err := ConfigPath()
 	if err != nil {
-		return "", fmt.Errorf("could not find home directory: %v", err)
+		return "", err
 	}
 
-	configPath := filepath.Join(homeDir, ".parseable", "config.yaml")
-
 	// Check if config path exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return "", fmt.Errorf("config file does not exist, please run CheckAndCreateULID first")
@@ -395,5 +479,5 @@ func GetProfile() (config.Profile, error) {
 		return config.Profile{}, errors.New("no profile is configured to run this command. please create one using profile command")
 	}
 
-	return conf.Profiles[conf.DefaultProfile], nil
+	return conf.Profile(conf.DefaultProfile)
 }
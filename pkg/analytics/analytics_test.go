@@ -0,0 +1,49 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package analytics
+
+import (
+	"testing"
+)
+
+func TestPurgeDisablesAnalytics(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(DisableEnv, "")
+
+	if err := CheckAndCreateULID(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadUULD(); err != nil {
+		t.Fatalf("expected a ULID to be created, got %v", err)
+	}
+
+	if err := Purge(); err != nil {
+		t.Fatal(err)
+	}
+	if Enabled() {
+		t.Error("expected analytics to be disabled after purge")
+	}
+	if _, err := ReadUULD(); err == nil {
+		t.Error("expected the ULID to be deleted")
+	}
+
+	if err := CheckAndCreateULID(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadUULD(); err == nil {
+		t.Error("expected no new ULID while analytics is disabled")
+	}
+}
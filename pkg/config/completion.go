@@ -0,0 +1,100 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"encoding/json"
+	"os"
+	path "path/filepath"
+	"time"
+)
+
+var completionCacheFilename = "completion-cache.json"
+
+// CompletionCache keeps names fetched for shell completion, keyed by server
+// URL and then by kind such as users or roles, so that pressing tab
+// repeatedly does not query the server every time
+type CompletionCache struct {
+	Servers map[string]map[string]CompletionEntry `json:"servers"`
+}
+
+// CompletionEntry is one list of names and when it was fetched
+type CompletionEntry struct {
+	Names     []string  `json:"names"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// CompletionCachePath returns the path of the completion cache file
+func CompletionCachePath() (string, error) {
+	configPath, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(configPath), completionCacheFilename), nil
+}
+
+// ReadCompletionCache reads the completion cache. A missing or unreadable
+// file is returned as an empty cache, it is rebuilt on the next fetch
+func ReadCompletionCache() *CompletionCache {
+	cache := &CompletionCache{}
+	filePath, err := CompletionCachePath()
+	if err != nil {
+		return cache
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return &CompletionCache{}
+	}
+	return cache
+}
+
+// WriteCompletionCache writes the completion cache
+func WriteCompletionCache(cache *CompletionCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	filePath, err := CompletionCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(filePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, configFileMode)
+}
+
+// Get returns the cached names of kind for the server at url
+func (c *CompletionCache) Get(url, kind string) (CompletionEntry, bool) {
+	entry, ok := c.Servers[serverKey(url)][kind]
+	return entry, ok
+}
+
+// Set stores the names of kind for the server at url
+func (c *CompletionCache) Set(url, kind string, names []string, fetchedAt time.Time) {
+	key := serverKey(url)
+	if c.Servers == nil {
+		c.Servers = map[string]map[string]CompletionEntry{}
+	}
+	if c.Servers[key] == nil {
+		c.Servers[key] = map[string]CompletionEntry{}
+	}
+	c.Servers[key][kind] = CompletionEntry{Names: names, FetchedAt: fetchedAt}
+}
@@ -17,12 +17,16 @@
 package config
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net"
 	"net/url"
 	"os"
 	path "path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
 	toml "github.com/pelletier/go-toml/v2"
 )
@@ -30,6 +34,16 @@ import (
 var (
 	configFilename = "config.toml"
 	configAppName  = "parseable"
+
+	// configFileMode keeps the config, which holds credentials, private to the user
+	configFileMode os.FileMode = 0o600
+
+	// StrictPermissions refuses to read a config file that other users can
+	// read instead of only warning about it. It is set by the global
+	// --strict-permissions flag
+	StrictPermissions bool
+
+	permissionWarningShown bool
 )
 
 // Path returns user directory that can be used for the config file
@@ -45,6 +59,39 @@ func Path() (string, error) {
 type Config struct {
 	Profiles       map[string]Profile
 	DefaultProfile string
+	// UpdateChannel is the release channel pb version --check looks for
+	// updates in, stable when empty
+	UpdateChannel string `toml:",omitempty"`
+	// Defaults holds default values for command line flags, keyed by flag
+	// name. A nested table named after a command, e.g. "query run", holds
+	// defaults for that command only
+	Defaults map[string]interface{} `toml:",omitempty"`
+	// Aliases maps user defined command names to the pb command line they
+	// run, e.g. "prod-errors" to "query run --from 1h ..."
+	Aliases map[string]string `toml:",omitempty"`
+}
+
+const (
+	// ChannelStable only includes final releases
+	ChannelStable = "stable"
+	// ChannelBeta also includes pre-releases such as 1.2.0-beta.1
+	ChannelBeta = "beta"
+)
+
+// ValidateChannel checks that channel is a known release channel
+func ValidateChannel(channel string) error {
+	if channel != ChannelStable && channel != ChannelBeta {
+		return fmt.Errorf("unknown release channel %q, use %s or %s", channel, ChannelStable, ChannelBeta)
+	}
+	return nil
+}
+
+// Channel returns the configured release channel, defaulting to stable
+func (c *Config) Channel() string {
+	if c == nil || c.UpdateChannel == "" {
+		return ChannelStable
+	}
+	return c.UpdateChannel
 }
 
 // Profile is the struct that holds the profile configuration
@@ -52,6 +99,159 @@ type Profile struct {
 	URL      string `json:"url"`
 	Username string `json:"username"`
 	Password string `json:"password,omitempty"`
+	// Token is an API token, used instead of the username and password when set
+	Token string `json:"token,omitempty" toml:",omitempty"`
+	// MinTLSVersion is the lowest TLS version accepted from the server, e.g. "1.2"
+	MinTLSVersion string `json:"min_tls_version,omitempty" toml:",omitempty"`
+	// TLSCipherSuites restricts the cipher suites offered for TLS 1.2 and
+	// below, using the names from crypto/tls
+	TLSCipherSuites []string `json:"tls_cipher_suites,omitempty" toml:",omitempty"`
+	// Group tags the profile with an environment such as prod or staging, so
+	// commands can run against every profile in the group
+	Group string `json:"group,omitempty" toml:",omitempty"`
+	// AuthMode selects how requests are authenticated, basic or token auth
+	// from the fields above when empty, see AuthModeOAuthClientCredentials
+	AuthMode string `json:"auth_mode,omitempty" toml:",omitempty"`
+	// TokenURL, ClientID, ClientSecret and Scopes are the OAuth2 client
+	// credentials used to fetch bearer tokens in the oauth-client-credentials
+	// auth mode
+	TokenURL     string   `json:"token_url,omitempty" toml:",omitempty"`
+	ClientID     string   `json:"client_id,omitempty" toml:",omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty" toml:",omitempty"`
+	Scopes       []string `json:"scopes,omitempty" toml:",omitempty"`
+	// CacheToken keeps fetched OAuth2 tokens on disk so that they are reused
+	// across runs until they expire
+	CacheToken bool `json:"cache_token,omitempty" toml:",omitempty"`
+	// HMACKeyID and HMACSecret sign every request in the hmac auth mode, for
+	// gateways that require signed requests
+	HMACKeyID  string `json:"hmac_key_id,omitempty" toml:",omitempty"`
+	HMACSecret string `json:"hmac_secret,omitempty" toml:",omitempty"`
+	// URLs lists further endpoints of the same deployment, such as the other
+	// nodes behind a load balancer, that are tried when URL cannot be reached
+	URLs []string `json:"urls,omitempty" toml:",omitempty"`
+	// LBPolicy picks the order the endpoints are tried in, failover when empty
+	LBPolicy string `json:"lb_policy,omitempty" toml:",omitempty"`
+	// Defaults holds flag defaults that apply while this is the default
+	// profile, in the same form as Config.Defaults, which they take
+	// precedence over
+	Defaults map[string]interface{} `json:"defaults,omitempty" toml:",omitempty"`
+}
+
+const (
+	// LBPolicyFailover always starts with URL and moves on to the next
+	// endpoint only when one cannot be reached
+	LBPolicyFailover = "failover"
+	// LBPolicyRoundRobin spreads requests across all endpoints in turn, and
+	// still moves on to the next endpoint when one cannot be reached
+	LBPolicyRoundRobin = "round-robin"
+)
+
+// Endpoints returns URL followed by the further endpoints of the profile,
+// without duplicates
+func (p *Profile) Endpoints() []string {
+	endpoints := []string{p.URL}
+	seen := map[string]bool{strings.TrimSuffix(p.URL, "/"): true}
+	for _, endpoint := range p.URLs {
+		key := strings.TrimSuffix(endpoint, "/")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// ValidateEndpoints checks the further endpoints and the load balancing
+// policy of the profile
+func (p *Profile) ValidateEndpoints() error {
+	for _, endpoint := range p.URLs {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid endpoint URL %q", endpoint)
+		}
+	}
+	switch p.LBPolicy {
+	case "", LBPolicyFailover, LBPolicyRoundRobin:
+		return nil
+	}
+	return fmt.Errorf("unknown load balancing policy %q, use %s or %s", p.LBPolicy, LBPolicyFailover, LBPolicyRoundRobin)
+}
+
+const (
+	// AuthModeBasic uses the username and password, or the API token when set
+	AuthModeBasic = "basic"
+	// AuthModeOAuthClientCredentials fetches bearer tokens from TokenURL with
+	// the OAuth2 client credentials grant
+	AuthModeOAuthClientCredentials = "oauth-client-credentials"
+	// AuthModeHMAC signs every request with HMACKeyID and HMACSecret, on top
+	// of the username and password or API token
+	AuthModeHMAC = "hmac"
+)
+
+// ValidateAuthMode checks that mode is a known auth mode and that the profile
+// has the settings it needs
+func (p *Profile) ValidateAuthMode() error {
+	switch p.AuthMode {
+	case "", AuthModeBasic:
+		return nil
+	case AuthModeOAuthClientCredentials:
+		if p.TokenURL == "" || p.ClientID == "" || p.ClientSecret == "" {
+			return fmt.Errorf("auth mode %s needs a token URL, client ID and client secret", p.AuthMode)
+		}
+		if u, err := url.Parse(p.TokenURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid token URL %q", p.TokenURL)
+		}
+		return nil
+	case AuthModeHMAC:
+		if p.HMACKeyID == "" || p.HMACSecret == "" {
+			return fmt.Errorf("auth mode %s needs an HMAC key ID and secret", p.AuthMode)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown auth mode %q, use %s, %s or %s", p.AuthMode, AuthModeBasic, AuthModeOAuthClientCredentials, AuthModeHMAC)
+}
+
+// SignsRequests reports whether requests of the profile carry an HMAC signature
+func (p *Profile) SignsRequests() bool {
+	return p.AuthMode == AuthModeHMAC
+}
+
+// UsesOAuth reports whether the profile authenticates with OAuth2 tokens
+func (p *Profile) UsesOAuth() bool {
+	return p.AuthMode == AuthModeOAuthClientCredentials
+}
+
+var groupNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// ValidateGroupName checks that a profile group is a simple identifier
+func ValidateGroupName(group string) error {
+	if !groupNamePattern.MatchString(group) {
+		return fmt.Errorf("invalid group name %q: group names must start with a letter and contain only letters, digits, - and _, up to 64 characters", group)
+	}
+	return nil
+}
+
+// ProfilesInGroup returns the sorted names of the profiles tagged with group,
+// or of all profiles when group is empty
+func (c *Config) ProfilesInGroup(group string) []string {
+	var names []string
+	for name, profile := range c.Profiles {
+		if group == "" || profile.Group == group {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AuthHeader returns the Authorization header value for the profile, a bearer
+// token when one is set and basic auth otherwise. OAuth2 profiles need a
+// token fetched from the token URL, see AuthHeader in pkg/http
+func (p *Profile) AuthHeader() string {
+	if p.Token != "" {
+		return "Bearer " + p.Token
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(p.Username+":"+p.Password))
 }
 
 func (p *Profile) GrpcAddr(port string) string {
@@ -59,41 +259,78 @@ func (p *Profile) GrpcAddr(port string) string {
 	return net.JoinHostPort(urlv.Hostname(), port)
 }
 
-// WriteConfigToFile writes the configuration to the config file
+// WriteConfigToFile writes the configuration to the config file. A new file is
+// created readable only by the user, the mode of an existing file is kept. The
+// config lock is held while writing, use UpdateConfig to also cover reading
+// the config the new one is based on
 func WriteConfigToFile(config *Config) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return writeConfig(config)
+}
+
+// writeConfig replaces the config file through a temporary file in the same
+// directory, so other processes reading it never see a partly written config
+func writeConfig(config *Config) error {
 	tomlData, _ := toml.Marshal(config)
 	filePath, err := Path()
 	if err != nil {
 		return err
 	}
-	// Open or create the file for writing (it will truncate the file if it already exists
-	err = os.MkdirAll(path.Dir(filePath), os.ModePerm)
+	err = os.MkdirAll(path.Dir(filePath), 0o700)
 	if err != nil {
 		return err
 	}
 
-	file, err := os.Create(filePath)
+	mode := configFileMode
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	file, err := os.CreateTemp(path.Dir(filePath), "."+configFilename+".*.tmp")
 	if err != nil {
 		fmt.Println("Error creating the file:", err)
 		return err
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
+
 	// Write the data into the file
-	_, err = file.Write(tomlData)
-	if err != nil {
+	if _, err = file.Write(tomlData); err != nil {
+		file.Close()
 		fmt.Println("Error writing to the file:", err)
 		return err
 	}
-	return err
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filePath)
 }
 
-// ReadConfigFromFile reads the configuration from the config file
-func ReadConfigFromFile() (config *Config, err error) {
+// ReadConfigFromFile reads the configuration from the config file. Profiles
+// are returned as written, use Profile or Default to get one with its ${VAR}
+// references expanded. A config file that other users can read is reported,
+// see StrictPermissions
+func ReadConfigFromFile() (*Config, error) {
+	return readConfig()
+}
+
+// readConfig reads the configuration from the config file as written
+func readConfig() (config *Config, err error) {
 	filePath, err := Path()
 	if err != nil {
 		return &Config{}, err
 	}
 
+	if err = checkPermissions(filePath); err != nil && !os.IsNotExist(err) {
+		return &Config{}, err
+	}
+
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return &Config{}, err
@@ -115,10 +352,26 @@ func GetProfile() (Profile, error) {
 		return Profile{}, err
 	}
 
-	if conf.Profiles == nil || conf.DefaultProfile == "" {
+	return conf.Default()
+}
+
+// Default returns the default profile with its ${VAR} references expanded.
+// It fails rather than falling back to another profile when no default is
+// set, or when the default no longer exists
+func (c *Config) Default() (Profile, error) {
+	if len(c.Profiles) == 0 {
 		return Profile{}, errors.New("no profile is configured to run this command. please create one using profile command")
 	}
-
-	return conf.Profiles[conf.DefaultProfile], nil
-
+	if c.DefaultProfile == "" {
+		names := make([]string, 0, len(c.Profiles))
+		for name := range c.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("no default profile is set. Choose the profile to use with pb profile default, one of: %s", strings.Join(names, ", "))
+	}
+	if _, ok := c.Profiles[c.DefaultProfile]; !ok {
+		return Profile{}, fmt.Errorf("default profile %s does not exist. Choose another with pb profile default", c.DefaultProfile)
+	}
+	return c.Profile(c.DefaultProfile)
 }
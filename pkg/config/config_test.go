@@ -0,0 +1,212 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+func TestValidateGroupName(t *testing.T) {
+	for _, group := range []string{"prod", "staging-eu", "dev_2"} {
+		if err := ValidateGroupName(group); err != nil {
+			t.Errorf("expected %q to be valid, got %v", group, err)
+		}
+	}
+	for _, group := range []string{"", "2prod", "prod env", "prod,staging", strings.Repeat("a", 65)} {
+		if err := ValidateGroupName(group); err == nil {
+			t.Errorf("expected %q to be rejected", group)
+		}
+	}
+}
+
+func TestProfilesInGroup(t *testing.T) {
+	conf := Config{Profiles: map[string]Profile{
+		"prod-us": {Group: "prod"},
+		"prod-eu": {Group: "prod"},
+		"staging": {Group: "staging"},
+		"local":   {},
+	}}
+
+	if got := strings.Join(conf.ProfilesInGroup("prod"), ","); got != "prod-eu,prod-us" {
+		t.Errorf("expected sorted prod profiles, got %s", got)
+	}
+	if got := len(conf.ProfilesInGroup("")); got != 4 {
+		t.Errorf("expected every profile without a group filter, got %d", got)
+	}
+}
+
+func TestDefaultsRoundTrip(t *testing.T) {
+	data := []byte(`DefaultProfile = "local"
+
+[Defaults]
+output = "json"
+max-retries = 5
+
+[Defaults."query run"]
+no-color = true
+`)
+	var conf Config
+	if err := toml.Unmarshal(data, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Defaults["output"] != "json" || conf.Defaults["max-retries"] != int64(5) {
+		t.Errorf("unexpected top level defaults %v", conf.Defaults)
+	}
+	section, ok := conf.Defaults["query run"].(map[string]interface{})
+	if !ok || section["no-color"] != true {
+		t.Errorf("expected a command section, got %v", conf.Defaults["query run"])
+	}
+
+	// the config is rewritten on every run, so defaults must survive it
+	out, err := toml.Marshal(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Config
+	if err := toml.Unmarshal(out, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.Defaults["output"] != "json" {
+		t.Errorf("defaults lost when writing the config:\n%s", out)
+	}
+}
+
+func TestExpandProfileFromEnvironment(t *testing.T) {
+	env := map[string]string{"PB_HOST": "logs.example.com", "PB_PASSWORD": "s3cret"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	profile, err := expandProfile(Profile{
+		URL:      "https://${PB_HOST}:8000",
+		Username: "admin",
+		Password: "${PB_PASSWORD}",
+		URLs:     []string{"https://node2.${PB_HOST}"},
+	}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.URL != "https://logs.example.com:8000" || profile.Password != "s3cret" || profile.Username != "admin" {
+		t.Errorf("unexpected expansion: %+v", profile)
+	}
+	if profile.URLs[0] != "https://node2.logs.example.com" {
+		t.Errorf("expected string lists to be expanded, got %v", profile.URLs)
+	}
+}
+
+func TestExpandMissingVariable(t *testing.T) {
+	conf := &Config{Profiles: map[string]Profile{"prod": {Token: "${PB_TOKEN}"}}}
+	_, err := conf.profile("prod", func(string) (string, bool) { return "", false })
+	if err == nil {
+		t.Fatal("expected an unset variable to be an error")
+	}
+	for _, want := range []string{"prod", "Token", "PB_TOKEN"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got %v", want, err)
+		}
+	}
+}
+
+func TestExpandOnlySelectedProfile(t *testing.T) {
+	t.Setenv("PB_TEST_HOST", "logs.example.com")
+	conf := &Config{
+		DefaultProfile: "local",
+		Profiles: map[string]Profile{
+			"local": {URL: "https://${PB_TEST_HOST}"},
+			"prod":  {Token: "${PB_TEST_UNSET_TOKEN}"},
+		},
+	}
+
+	profile, err := conf.Default()
+	if err != nil {
+		t.Fatalf("expected an unset variable in another profile to be ignored, got %v", err)
+	}
+	if profile.URL != "https://logs.example.com" {
+		t.Errorf("expected the default profile to be expanded, got %q", profile.URL)
+	}
+	if conf.Profiles["local"].URL != "https://${PB_TEST_HOST}" {
+		t.Errorf("expected the config to keep the reference, got %q", conf.Profiles["local"].URL)
+	}
+	if _, err := conf.Profile("prod"); err == nil {
+		t.Error("expected the profile with the unset variable to fail when used")
+	}
+}
+
+func TestExpandEscape(t *testing.T) {
+	lookup := func(string) (string, bool) { return "value", true }
+	cases := map[string]string{
+		"pa$${word}":      "pa${word}",
+		"$${A}-${A}":      "${A}-value",
+		"plain $ dollars": "plain $ dollars",
+	}
+	for in, want := range cases {
+		got, err := expandEnv(in, lookup)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+		} else if got != want {
+			t.Errorf("%q: expected %q, got %q", in, want, got)
+		}
+	}
+
+	if _, err := expandEnv("${UNTERMINATED", lookup); err == nil {
+		t.Error("expected an unterminated reference to be rejected")
+	}
+}
+
+func TestUpdateConfigKeepsReferences(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	t.Setenv("PB_TEST_PASSWORD", "s3cret")
+
+	err := UpdateConfig(func(conf *Config) error {
+		conf.Profiles = map[string]Profile{"local": {URL: "http://localhost:8000", Password: "${PB_TEST_PASSWORD}"}}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateConfig(func(conf *Config) error {
+		conf.DefaultProfile = "local"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := readConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Profiles["local"].Password != "${PB_TEST_PASSWORD}" {
+		t.Errorf("expected the reference to be kept in the file, got %q", raw.Profiles["local"].Password)
+	}
+	conf, err := ReadConfigFromFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := conf.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Password != "s3cret" {
+		t.Errorf("expected the password to be expanded, got %q", profile.Password)
+	}
+}
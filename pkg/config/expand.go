@@ -0,0 +1,115 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// expandEnv replaces ${VAR} references in value with the value of the
+// environment variable VAR, as returned by lookup. $${ stands for a literal
+// ${ and is not expanded
+func expandEnv(value string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var expanded strings.Builder
+	for {
+		idx := strings.Index(value, "${")
+		if idx < 0 {
+			expanded.WriteString(value)
+			return expanded.String(), nil
+		}
+		if idx > 0 && value[idx-1] == '$' {
+			expanded.WriteString(value[:idx-1])
+			expanded.WriteString("${")
+			value = value[idx+2:]
+			continue
+		}
+		expanded.WriteString(value[:idx])
+
+		end := strings.IndexByte(value[idx:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q, write $${ for a literal ${", value[idx:])
+		}
+		name := value[idx+2 : idx+end]
+		if name == "" {
+			return "", fmt.Errorf("empty variable name in %q", value[idx:idx+end+1])
+		}
+		resolved, ok := lookup(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		expanded.WriteString(resolved)
+		value = value[idx+end+1:]
+	}
+}
+
+// expandProfile expands environment variable references in every string
+// field of profile, including string lists
+func expandProfile(profile Profile, lookup func(string) (string, bool)) (Profile, error) {
+	value := reflect.ValueOf(&profile).Elem()
+	for idx := 0; idx < value.NumField(); idx++ {
+		field := value.Field(idx)
+		name := value.Type().Field(idx).Name
+		switch {
+		case field.Kind() == reflect.String:
+			expanded, err := expandEnv(field.String(), lookup)
+			if err != nil {
+				return profile, fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetString(expanded)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+			if field.IsNil() {
+				continue
+			}
+			items := make([]string, field.Len())
+			for item := range items {
+				expanded, err := expandEnv(field.Index(item).String(), lookup)
+				if err != nil {
+					return profile, fmt.Errorf("%s: %w", name, err)
+				}
+				items[item] = expanded
+			}
+			field.Set(reflect.ValueOf(items))
+		}
+	}
+	return profile, nil
+}
+
+// Profile returns the named profile with its ${VAR} references expanded from
+// the environment. Only this profile is expanded, so an unset variable in
+// another profile does not get in the way
+func (c *Config) Profile(name string) (Profile, error) {
+	return c.profile(name, os.LookupEnv)
+}
+
+func (c *Config) profile(name string, lookup func(string) (string, bool)) (Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %s does not exist", name)
+	}
+	expanded, err := expandProfile(profile, lookup)
+	if err != nil {
+		return Profile{}, fmt.Errorf("profile %s: %w", name, err)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	path "path/filepath"
+	"time"
+)
+
+var (
+	// lockTimeout is how long pb waits for another pb process to finish
+	// updating the config file
+	lockTimeout = 5 * time.Second
+	// lockRetryInterval is the pause between attempts to take the lock
+	lockRetryInterval = 20 * time.Millisecond
+)
+
+// lockConfig takes an exclusive lock on a lock file next to the config file,
+// so that pb processes running at the same time update the config one after
+// the other. The lock is released by the returned function, or by the
+// operating system if the process dies
+func lockConfig() (func(), error) {
+	filePath, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(path.Dir(filePath), 0o700); err != nil {
+		return nil, err
+	}
+
+	lockPath := filePath + ".lock"
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, configFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", lockPath, err)
+		}
+		if locked {
+			return func() {
+				unlockFile(file)
+				file.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("another pb command is updating the config file %s, gave up after %s. Try again once it has finished", filePath, lockTimeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// UpdateConfig reads the config file, lets update change it and writes it
+// back, holding the config lock throughout so that no change made by another
+// pb process in the meantime is lost. A missing config file is read as an
+// empty config
+func UpdateConfig(update func(*Config) error) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	conf, err := readConfig()
+	if errors.Is(err, os.ErrNotExist) {
+		conf, err = &Config{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := update(conf); err != nil {
+		return err
+	}
+	return writeConfig(conf)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentConfigUpdates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for idx := 0; idx < writers; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			errs <- UpdateConfig(func(conf *Config) error {
+				if conf.Profiles == nil {
+					conf.Profiles = make(map[string]Profile)
+				}
+				conf.Profiles[fmt.Sprintf("profile-%d", idx)] = Profile{URL: fmt.Sprintf("http://node-%d:8000", idx)}
+				return nil
+			})
+		}(idx)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+	}
+
+	conf, err := ReadConfigFromFile()
+	if err != nil {
+		t.Fatalf("config is not valid after concurrent updates: %v", err)
+	}
+	if len(conf.Profiles) != writers {
+		t.Errorf("expected all %d profiles to be kept, got %d", writers, len(conf.Profiles))
+	}
+}
+
+func TestConfigLockTimeout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	defer func(timeout time.Duration) { lockTimeout = timeout }(lockTimeout)
+	lockTimeout = 100 * time.Millisecond
+
+	unlock, err := lockConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	err = WriteConfigToFile(&Config{})
+	if err == nil || !strings.Contains(err.Error(), "another pb command is updating the config file") {
+		t.Errorf("expected a clear error while the config is locked, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !windows
+
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkPermissions reports a config file that group or other users can read.
+// With StrictPermissions set the file is refused, otherwise a warning is
+// printed to stderr once per run
+func checkPermissions(filePath string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	mode := info.Mode().Perm()
+	if mode&0o077 == 0 {
+		return nil
+	}
+
+	if StrictPermissions {
+		return fmt.Errorf("config file %s has permissions %04o, which lets other users read your credentials. Run chmod 600 %s", filePath, mode, filePath)
+	}
+	if !permissionWarningShown {
+		permissionWarningShown = true
+		fmt.Fprintf(os.Stderr, "Warning: config file %s has permissions %04o and may be readable by other users. Run chmod 600 %s to restrict it\n", filePath, mode, filePath)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func useTempConfigDir(t *testing.T) string {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	filePath, err := Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestWriteConfigCreatesPrivateFile(t *testing.T) {
+	filePath := useTempConfigDir(t)
+
+	conf := Config{Profiles: map[string]Profile{"local": {URL: "http://localhost:8000"}}, DefaultProfile: "local"}
+	if err := WriteConfigToFile(&conf); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("expected new config file to have mode 0600, got %04o", mode)
+	}
+}
+
+func TestStrictPermissionsRefusesReadableConfig(t *testing.T) {
+	filePath := useTempConfigDir(t)
+
+	conf := Config{Profiles: map[string]Profile{"local": {URL: "http://localhost:8000"}}, DefaultProfile: "local"}
+	if err := WriteConfigToFile(&conf); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := os.Chmod(filePath, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	StrictPermissions = true
+	defer func() { StrictPermissions = false }()
+	if _, err := ReadConfigFromFile(); err == nil {
+		t.Error("expected a group and world readable config to be refused")
+	}
+
+	StrictPermissions = false
+	read, err := ReadConfigFromFile()
+	if err != nil {
+		t.Fatalf("expected a readable config to only warn, got %v", err)
+	}
+	if read.DefaultProfile != "local" {
+		t.Errorf("unexpected config read back: %+v", read)
+	}
+}
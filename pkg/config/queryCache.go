@@ -0,0 +1,46 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	path "path/filepath"
+)
+
+var (
+	queryCacheDirname = "query-cache"
+	httpCacheDirname  = "http-cache"
+)
+
+// QueryCacheDir returns the directory holding cached query results, next to
+// the config file
+func QueryCacheDir() (string, error) {
+	configPath, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(configPath), queryCacheDirname), nil
+}
+
+// HTTPCacheDir returns the directory holding responses kept for conditional
+// requests, next to the config file
+func HTTPCacheDir() (string, error) {
+	configPath, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(configPath), httpCacheDirname), nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"os"
+	path "path/filepath"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+var roleInheritanceFilename = "roles.toml"
+
+// RoleInheritanceFile records which roles a role inherited its privileges
+// from, keyed by server URL and then by role name. Parseable has no role
+// inheritance, so pb copies the privileges at creation time and keeps this
+// record locally to detect inheritance cycles
+type RoleInheritanceFile struct {
+	Servers map[string]map[string][]string
+}
+
+// RoleInheritancePath returns the path of the role inheritance file
+func RoleInheritancePath() (string, error) {
+	configPath, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(configPath), roleInheritanceFilename), nil
+}
+
+// ReadRoleInheritance reads the role inheritance file. A missing file is
+// returned as an empty one
+func ReadRoleInheritance() (*RoleInheritanceFile, error) {
+	file := &RoleInheritanceFile{}
+	filePath, err := RoleInheritancePath()
+	if err != nil {
+		return file, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	} else if err != nil {
+		return file, err
+	}
+	if err := toml.Unmarshal(data, file); err != nil {
+		return &RoleInheritanceFile{}, err
+	}
+	return file, nil
+}
+
+// WriteRoleInheritance writes the role inheritance file
+func WriteRoleInheritance(file *RoleInheritanceFile) error {
+	data, err := toml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	filePath, err := RoleInheritancePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(filePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, configFileMode)
+}
+
+// Roles returns the recorded inheritance of every role on the server at url
+func (f *RoleInheritanceFile) Roles(url string) map[string][]string {
+	return f.Servers[serverKey(url)]
+}
+
+// Set records the roles a role inherited from, removing the entry when there
+// are none
+func (f *RoleInheritanceFile) Set(url, role string, parents []string) {
+	if len(parents) == 0 {
+		f.Delete(url, role)
+		return
+	}
+	key := serverKey(url)
+	if f.Servers == nil {
+		f.Servers = map[string]map[string][]string{}
+	}
+	if f.Servers[key] == nil {
+		f.Servers[key] = map[string][]string{}
+	}
+	f.Servers[key][role] = parents
+}
+
+// Delete removes the record of a role
+func (f *RoleInheritanceFile) Delete(url, role string) {
+	key := serverKey(url)
+	delete(f.Servers[key], role)
+	if len(f.Servers[key]) == 0 {
+		delete(f.Servers, key)
+	}
+}
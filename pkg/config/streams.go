@@ -0,0 +1,152 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"os"
+	path "path/filepath"
+	"sort"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+var streamMetadataFilename = "streams.toml"
+
+// StreamMetadata is a description and tags kept by pb for a stream. The
+// server has no place to store them, so they live in a local file next to
+// the config file and are only visible to this user on this machine
+type StreamMetadata struct {
+	Description string            `json:"description,omitempty" toml:",omitempty"`
+	Tags        map[string]string `json:"tags,omitempty" toml:",omitempty"`
+}
+
+// IsEmpty reports whether there is nothing worth storing
+func (m StreamMetadata) IsEmpty() bool {
+	return m.Description == "" && len(m.Tags) == 0
+}
+
+// HasTags reports whether every key=value pair in tags is set on the stream
+func (m StreamMetadata) HasTags(tags map[string]string) bool {
+	for key, value := range tags {
+		if m.Tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// TagKeys returns the sorted tag keys of m
+func (m StreamMetadata) TagKeys() []string {
+	keys := make([]string, 0, len(m.Tags))
+	for key := range m.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// StreamMetadataFile holds stream metadata keyed by server URL and then by
+// stream name, so streams with the same name on different servers are kept
+// apart
+type StreamMetadataFile struct {
+	Servers map[string]map[string]StreamMetadata
+}
+
+// StreamMetadataPath returns the path of the stream metadata file
+func StreamMetadataPath() (string, error) {
+	configPath, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(configPath), streamMetadataFilename), nil
+}
+
+// ReadStreamMetadata reads the stream metadata file. A missing file is
+// returned as an empty one
+func ReadStreamMetadata() (*StreamMetadataFile, error) {
+	file := &StreamMetadataFile{}
+	filePath, err := StreamMetadataPath()
+	if err != nil {
+		return file, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	} else if err != nil {
+		return file, err
+	}
+	if err := toml.Unmarshal(data, file); err != nil {
+		return &StreamMetadataFile{}, err
+	}
+	return file, nil
+}
+
+// WriteStreamMetadata writes the stream metadata file, readable only by the
+// user like the config file
+func WriteStreamMetadata(file *StreamMetadataFile) error {
+	data, err := toml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	filePath, err := StreamMetadataPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(filePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, configFileMode)
+}
+
+// Get returns the metadata of a stream on the server at url
+func (f *StreamMetadataFile) Get(url, stream string) StreamMetadata {
+	return f.Servers[serverKey(url)][stream]
+}
+
+// Set stores the metadata of a stream, removing the entry when it is empty
+func (f *StreamMetadataFile) Set(url, stream string, meta StreamMetadata) {
+	key := serverKey(url)
+	if meta.IsEmpty() {
+		f.Delete(url, stream)
+		return
+	}
+	if f.Servers == nil {
+		f.Servers = map[string]map[string]StreamMetadata{}
+	}
+	if f.Servers[key] == nil {
+		f.Servers[key] = map[string]StreamMetadata{}
+	}
+	f.Servers[key][stream] = meta
+}
+
+// Delete removes the metadata of a stream
+func (f *StreamMetadataFile) Delete(url, stream string) {
+	key := serverKey(url)
+	delete(f.Servers[key], stream)
+	if len(f.Servers[key]) == 0 {
+		delete(f.Servers, key)
+	}
+}
+
+// serverKey normalizes a profile URL so trailing slashes do not split the
+// metadata of one server
+func serverKey(url string) string {
+	return strings.TrimRight(url, "/")
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import "testing"
+
+func TestStreamMetadataRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	file, err := ReadStreamMetadata()
+	if err != nil {
+		t.Fatalf("expected a missing file to read as empty, got %v", err)
+	}
+
+	meta := StreamMetadata{Description: "gateway logs", Tags: map[string]string{"env": "prod", "team": "platform"}}
+	file.Set("https://logs.example.com/", "gateway", meta)
+	file.Set("https://staging.example.com", "gateway", StreamMetadata{Tags: map[string]string{"env": "staging"}})
+	if err := WriteStreamMetadata(file); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = ReadStreamMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := file.Get("https://logs.example.com", "gateway")
+	if got.Description != "gateway logs" || got.Tags["team"] != "platform" {
+		t.Errorf("unexpected metadata after reload: %+v", got)
+	}
+	if file.Get("https://staging.example.com", "gateway").Tags["env"] != "staging" {
+		t.Error("expected streams with the same name on different servers to be kept apart")
+	}
+
+	file.Delete("https://logs.example.com", "gateway")
+	if !file.Get("https://logs.example.com", "gateway").IsEmpty() {
+		t.Error("expected metadata to be deleted")
+	}
+}
+
+func TestStreamMetadataHasTags(t *testing.T) {
+	meta := StreamMetadata{Tags: map[string]string{"env": "prod", "team": "platform"}}
+
+	if !meta.HasTags(nil) {
+		t.Error("expected no filter to match")
+	}
+	if !meta.HasTags(map[string]string{"env": "prod", "team": "platform"}) {
+		t.Error("expected all matching tags to match")
+	}
+	if meta.HasTags(map[string]string{"env": "prod", "team": "data"}) {
+		t.Error("expected a differing tag value not to match")
+	}
+	if (StreamMetadata{}).HasTags(map[string]string{"env": "prod"}) {
+		t.Error("expected an untagged stream not to match a filter")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"os"
+	path "path/filepath"
+	"time"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+var tokenCacheFilename = "tokens.toml"
+
+// CachedToken is an OAuth2 access token kept between runs
+type CachedToken struct {
+	AccessToken string
+	Expiry      time.Time
+}
+
+// TokenCache holds cached access tokens keyed by token URL, client ID and
+// scopes, so profiles sharing a client share its token
+type TokenCache struct {
+	Tokens map[string]CachedToken `toml:",omitempty"`
+}
+
+// TokenCachePath returns the path of the token cache, next to the config file
+func TokenCachePath() (string, error) {
+	configPath, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(configPath), tokenCacheFilename), nil
+}
+
+// ReadTokenCache reads the token cache. A missing file is returned as an empty
+// cache, and the file is checked for loose permissions like the config file
+func ReadTokenCache() (*TokenCache, error) {
+	cache := &TokenCache{}
+	filePath, err := TokenCachePath()
+	if err != nil {
+		return cache, err
+	}
+
+	if err := checkPermissions(filePath); errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	} else if err != nil {
+		return cache, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	} else if err != nil {
+		return cache, err
+	}
+	if err := toml.Unmarshal(data, cache); err != nil {
+		return &TokenCache{}, err
+	}
+	return cache, nil
+}
+
+// WriteTokenCache writes the token cache, readable only by the user like the
+// config file
+func WriteTokenCache(cache *TokenCache) error {
+	data, err := toml.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	filePath, err := TokenCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(filePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, configFileMode)
+}
@@ -34,6 +34,7 @@ import (
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage/driver"
 )
 
 type Helm struct {
@@ -277,6 +278,30 @@ func GetReleaseValues(releaseName, namespace string) (map[string]interface{}, er
 	return release.Config, nil
 }
 
+// GetRelease returns the chart version and user supplied values of a
+// release. found is false when no release of that name exists in namespace
+func GetRelease(releaseName, namespace string) (version string, values map[string]interface{}, found bool, err error) {
+	settings := cli.New()
+
+	// Initialize action configuration
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), log.Printf); err != nil {
+		return "", nil, false, err
+	}
+
+	rel, err := action.NewGet(actionConfig).Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return "", nil, false, nil
+	}
+	if err != nil {
+		return "", nil, false, err
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		version = rel.Chart.Metadata.Version
+	}
+	return version, rel.Config, true, nil
+}
+
 // DeleteRelease deletes a Helm release based on the specified chart name and namespace.
 func DeleteRelease(chartName, namespace string) error {
 	settings := cli.New()
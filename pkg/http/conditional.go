@@ -0,0 +1,261 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pb/pkg/config"
+)
+
+// NoConditionalCache turns off keeping GET responses that carry an ETag or
+// Last-Modified header for revalidation. It is set by the global
+// --no-http-cache flag
+var NoConditionalCache bool
+
+const (
+	// maxCachedResponse is the largest response body kept for revalidation
+	maxCachedResponse = 8 << 20
+
+	// maxCacheSize bounds the cache directory. Once it is exceeded, the
+	// responses used least recently are removed
+	maxCacheSize = 64 << 20
+)
+
+// cacheablePathPattern matches the requests worth revalidating, the stream
+// list and stream schemas, which are fetched often and rarely change
+var cacheablePathPattern = regexp.MustCompile(`/api/v1/logstream(/[^/]+/schema)?/?$`)
+
+// cachedResponse is a response kept on disk for conditional requests
+type cachedResponse struct {
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+}
+
+// conditionalTransport sends If-None-Match and If-Modified-Since with GET
+// requests it has a cached response for, and serves the cached body when the
+// server answers 304 Not Modified. The server still decides on every request
+// whether the data changed, so results are never stale
+type conditionalTransport struct {
+	base http.RoundTripper
+	dir  string
+
+	// identity is the user the requests are sent as, responses are only
+	// reused for the same user
+	identity string
+}
+
+func newConditionalTransport(base http.RoundTripper, profile *config.Profile) http.RoundTripper {
+	dir, err := config.HTTPCacheDir()
+	if err != nil {
+		return base
+	}
+	return &conditionalTransport{base: base, dir: dir, identity: cacheIdentity(profile)}
+}
+
+// cacheIdentity names the user of the profile. OAuth2 bearer tokens change
+// with every fetch, so the user is taken from the profile rather than from
+// the Authorization header. An API token is the only identity of its user
+// and is hashed, so it never ends up in a file name
+func cacheIdentity(profile *config.Profile) string {
+	switch {
+	case profile.UsesOAuth():
+		return "oauth\x00" + profile.TokenURL + "\x00" + profile.ClientID
+	case profile.SignsRequests():
+		return "hmac\x00" + profile.HMACKeyID
+	case profile.Token != "":
+		sum := sha256.Sum256([]byte(profile.Token))
+		return "token\x00" + hex.EncodeToString(sum[:])
+	}
+	return "basic\x00" + profile.Username
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// requests that already carry their own conditions are left alone
+	if req.Method != http.MethodGet || !cacheablePathPattern.MatchString(req.URL.Path) || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	path := t.path(req)
+	cached := readCachedResponse(path)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		// mark the response as recently used, so pruning keeps it
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		header := cached.Header.Clone()
+		header.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       resp.Request,
+		}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK || (resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") {
+		return resp, nil
+	}
+	if resp.ContentLength > maxCachedResponse {
+		return resp, nil
+	}
+
+	// read the body to keep it, a body over the limit is passed on uncached
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedResponse+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCachedResponse {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	writeCachedResponse(path, &cachedResponse{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Header:       resp.Header.Clone(),
+		Body:         body,
+	})
+	pruneCache(t.dir, maxCacheSize)
+	return resp, nil
+}
+
+// path returns the cache file of the request. The user is part of the key,
+// so a response is only reused for the user it was sent to
+func (t *conditionalTransport) path(req *http.Request) string {
+	hash := sha256.New()
+	for _, part := range []string{req.URL.String(), t.identity} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return filepath.Join(t.dir, hex.EncodeToString(hash.Sum(nil))+".json")
+}
+
+// readCachedResponse returns the cached response at path, or nil when there
+// is none or it cannot be read
+func readCachedResponse(path string) *cachedResponse {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil
+	}
+	return &cached
+}
+
+// writeCachedResponse stores a response for later revalidation. Failures are
+// ignored, the response is then simply downloaded again next time
+func writeCachedResponse(path string, cached *cachedResponse) {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".response-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	os.Rename(tmp.Name(), path)
+}
+
+// pruneCache removes the least recently used responses from dir until the
+// responses left take up at most limit bytes
+func pruneCache(dir string, limit int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var files []os.FileInfo
+	var total int64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, file := range files {
+		if total <= limit {
+			return
+		}
+		if os.Remove(filepath.Join(dir, file.Name())) == nil {
+			total -= file.Size()
+		}
+	}
+}
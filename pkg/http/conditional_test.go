@@ -0,0 +1,182 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+)
+
+func TestNotModifiedServesCachedBody(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	var conditions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`["app","web"]`))
+	}))
+	defer server.Close()
+
+	get := func(profile *config.Profile) (int, string) {
+		client := DefaultClient(profile)
+		req, err := client.NewRequest(http.MethodGet, "logstream", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	profile := &config.Profile{URL: server.URL, Username: "admin", Password: "admin"}
+	for i := 0; i < 2; i++ {
+		status, body := get(profile)
+		if status != http.StatusOK || body != `["app","web"]` {
+			t.Fatalf("request %d: expected the stream list, got %d %q", i+1, status, body)
+		}
+	}
+	if len(conditions) != 2 || conditions[0] != "" || conditions[1] != `"v1"` {
+		t.Errorf("expected the second request to be conditional, got %q", conditions)
+	}
+
+	// another user does not get the first user's cached response
+	get(&config.Profile{URL: server.URL, Username: "reader", Password: "reader"})
+	if conditions[2] != "" {
+		t.Errorf("expected no condition for another user, got %q", conditions[2])
+	}
+}
+
+func TestResponsesWithoutValidatorsAreNotCached(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	var conditional int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			conditional++
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := DefaultClient(&config.Profile{URL: server.URL})
+	for i := 0; i < 2; i++ {
+		req, _ := client.NewRequest(http.MethodGet, "about", nil)
+		resp, err := client.Client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if conditional != 0 {
+		t.Errorf("expected no conditional requests, got %d", conditional)
+	}
+}
+
+func TestOnlyListsAndSchemasAreCached(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	conditional := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			conditional[r.URL.Path]++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := DefaultClient(&config.Profile{URL: server.URL, Username: "admin"})
+	for _, path := range []string{"logstream/web/schema", "logstream/web/stats", "about"} {
+		for i := 0; i < 2; i++ {
+			req, _ := client.NewRequest(http.MethodGet, path, nil)
+			resp, err := client.Client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+	}
+	if conditional["/api/v1/logstream/web/schema"] != 1 || len(conditional) != 1 {
+		t.Errorf("expected only the schema to be revalidated, got %v", conditional)
+	}
+}
+
+func TestCacheIdentity(t *testing.T) {
+	if cacheIdentity(&config.Profile{Username: "admin", Password: "a"}) != cacheIdentity(&config.Profile{Username: "admin", Password: "b"}) {
+		t.Error("expected the identity of a basic auth profile to be its user")
+	}
+	if cacheIdentity(&config.Profile{Token: "one"}) == cacheIdentity(&config.Profile{Token: "two"}) {
+		t.Error("expected different API tokens to be different users")
+	}
+	if identity := cacheIdentity(&config.Profile{Token: "secret-token"}); strings.Contains(identity, "secret-token") {
+		t.Errorf("expected the API token to be hashed, got %q", identity)
+	}
+	oauth := &config.Profile{AuthMode: config.AuthModeOAuthClientCredentials, TokenURL: "https://idp/token", ClientID: "pb"}
+	if cacheIdentity(oauth) == cacheIdentity(&config.Profile{AuthMode: config.AuthModeOAuthClientCredentials, TokenURL: "https://idp/token", ClientID: "other"}) {
+		t.Error("expected different OAuth2 clients to be different users")
+	}
+}
+
+func TestPruneCacheRemovesLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for idx, name := range []string{"old.json", "middle.json", "new.json"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 100), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		used := now.Add(time.Duration(idx-3) * time.Hour)
+		os.Chtimes(path, used, used)
+	}
+
+	pruneCache(dir, 250)
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "middle.json,new.json" {
+		t.Errorf("expected the oldest response to be removed, got %v", names)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"pb/pkg/config"
+)
+
+// roundRobinNext holds the next starting endpoint of every round-robin
+// profile, keyed by its endpoint list, so that the rotation carries on
+// across the clients of one run
+var roundRobinNext sync.Map
+
+// endpointTransport sends requests of a profile with further endpoints to
+// the first one that can be reached, in the order of the profile's load
+// balancing policy. Only connection errors move on to the next endpoint, a
+// response from the server, even an error, is returned as is
+type endpointTransport struct {
+	base      http.RoundTripper
+	out       io.Writer
+	primary   *url.URL
+	endpoints []*url.URL
+	next      *atomic.Uint64
+}
+
+func newEndpointTransport(base http.RoundTripper, profile *config.Profile) http.RoundTripper {
+	var endpoints []*url.URL
+	for _, endpoint := range profile.Endpoints() {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			continue
+		}
+		endpoints = append(endpoints, u)
+	}
+	if len(endpoints) < 2 {
+		return base
+	}
+
+	t := &endpointTransport{base: base, out: RetryOutput, primary: endpoints[0], endpoints: endpoints}
+	if profile.LBPolicy == config.LBPolicyRoundRobin {
+		key := strings.Join(profile.Endpoints(), ",")
+		next, _ := roundRobinNext.LoadOrStore(key, new(atomic.Uint64))
+		t.next = next.(*atomic.Uint64)
+	}
+	return t
+}
+
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// requests outside the profile's base URL, such as token requests, and
+	// bodies that cannot be replayed go to the URL they were made for
+	if !t.ownsURL(req.URL) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return t.base.RoundTrip(req)
+	}
+
+	start := 0
+	if t.next != nil {
+		start = int((t.next.Add(1) - 1) % uint64(len(t.endpoints)))
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < len(t.endpoints); attempt++ {
+		endpoint := t.endpoints[(start+attempt)%len(t.endpoints)]
+		if attempt > 0 {
+			if req.Context().Err() != nil {
+				break
+			}
+			fmt.Fprintf(t.out, "Endpoint unreachable (%s), trying %s\n", lastErr, endpoint.Host)
+		}
+
+		attemptReq := req.Clone(req.Context())
+		attemptReq.URL = t.rewrite(req.URL, endpoint)
+		attemptReq.Host = ""
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+	}
+	if req.Context().Err() == nil {
+		return nil, fmt.Errorf("no endpoint of the profile could be reached, last error: %w", lastErr)
+	}
+	return nil, lastErr
+}
+
+// ownsURL reports whether u points below the profile's base URL
+func (t *endpointTransport) ownsURL(u *url.URL) bool {
+	return u.Scheme == t.primary.Scheme && u.Host == t.primary.Host &&
+		strings.HasPrefix(u.Path, strings.TrimSuffix(t.primary.Path, "/"))
+}
+
+// rewrite moves u from the profile's base URL onto endpoint, keeping the
+// API path and query
+func (t *endpointTransport) rewrite(u *url.URL, endpoint *url.URL) *url.URL {
+	rewritten := *u
+	rewritten.Scheme = endpoint.Scheme
+	rewritten.Host = endpoint.Host
+	rewritten.Path = strings.TrimSuffix(endpoint.Path, "/") + strings.TrimPrefix(u.Path, strings.TrimSuffix(t.primary.Path, "/"))
+	rewritten.RawPath = ""
+	return &rewritten
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+// deadEndpoint returns the URL of a server that has been shut down, so
+// connecting to it fails
+func deadEndpoint() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestFailoverToLiveEndpoint(t *testing.T) {
+	var bodies []string
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.URL.Path+" "+string(body))
+		w.Write([]byte("ok"))
+	}))
+	defer live.Close()
+
+	profile := &config.Profile{URL: deadEndpoint(), URLs: []string{live.URL}}
+	RetryOutput = io.Discard
+	defer func() { RetryOutput = os.Stderr }()
+	client := DefaultClient(profile)
+
+	req, err := client.NewRequest(http.MethodPost, "logstream/app", bytes.NewBufferString(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		t.Fatalf("expected the request to fail over, got %v", err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 1 || bodies[0] != `/api/v1/logstream/app {"a":1}` {
+		t.Errorf("expected the live endpoint to get the full request, got %q", bodies)
+	}
+}
+
+func TestAllEndpointsDown(t *testing.T) {
+	profile := &config.Profile{URL: deadEndpoint(), URLs: []string{deadEndpoint()}}
+	RetryOutput = io.Discard
+	defer func() { RetryOutput = os.Stderr }()
+	client := DefaultClient(profile)
+
+	req, err := client.NewRequest(http.MethodGet, "about", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Client.Do(req); err == nil || !strings.Contains(err.Error(), "no endpoint") {
+		t.Errorf("expected every endpoint to be reported unreachable, got %v", err)
+	}
+}
+
+func TestRoundRobinRotatesEndpoints(t *testing.T) {
+	var hits [2]int
+	servers := make([]*httptest.Server, 2)
+	for idx := range servers {
+		idx := idx
+		servers[idx] = httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			hits[idx]++
+		}))
+		defer servers[idx].Close()
+	}
+
+	profile := &config.Profile{URL: servers[0].URL, URLs: []string{servers[1].URL}, LBPolicy: config.LBPolicyRoundRobin}
+	for i := 0; i < 4; i++ {
+		client := DefaultClient(profile)
+		req, err := client.NewRequest(http.MethodGet, "about", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if hits[0] != 2 || hits[1] != 2 {
+		t.Errorf("expected requests to alternate between endpoints, got %v", hits)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pb/pkg/config"
+)
+
+// Headers of the pb HMAC signing scheme. The signature is the hex encoded
+// HMAC-SHA256, keyed with the profile secret, of these lines joined by "\n":
+//
+//	HTTP method in upper case
+//	URL path, escaped as sent
+//	query parameters sorted by name, as name=value joined by &
+//	value of X-PB-Timestamp, the Unix time in seconds
+//	value of X-PB-Content-SHA256, the hex SHA-256 of the request body
+const (
+	HMACKeyIDHeader     = "X-PB-Key-Id"
+	HMACTimestampHeader = "X-PB-Timestamp"
+	HMACContentHeader   = "X-PB-Content-SHA256"
+	HMACSignatureHeader = "X-PB-Signature"
+)
+
+// maxClockSkew is how far the local clock may drift from the Date header of
+// the gateway before timestamps are corrected to the gateway clock
+var maxClockSkew = 5 * time.Second
+
+// signingTransport signs each request with the HMAC key of a profile. It
+// learns the offset to the gateway clock from response Date headers, and
+// signs a rejected request again once when the offset changed
+type signingTransport struct {
+	base   http.RoundTripper
+	keyID  string
+	secret []byte
+	now    func() time.Time
+
+	mu   sync.Mutex
+	skew time.Duration
+}
+
+func newSigningTransport(base http.RoundTripper, profile *config.Profile) *signingTransport {
+	return &signingTransport{base: base, keyID: profile.HMACKeyID, secret: []byte(profile.HMACSecret), now: time.Now}
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	skew := t.clockSkew()
+	resp, err := t.base.RoundTrip(t.sign(req, body, t.now().Add(skew)))
+	if err != nil {
+		return nil, err
+	}
+
+	corrected := t.learnSkew(resp.Header.Get("Date"))
+	if resp.StatusCode != http.StatusUnauthorized || corrected == skew {
+		return resp, nil
+	}
+
+	// the gateway may have rejected a timestamp from a drifting clock
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return t.base.RoundTrip(t.sign(req, body, t.now().Add(corrected)))
+}
+
+// sign returns a copy of req carrying the signature headers for timestamp
+func (t *signingTransport) sign(req *http.Request, body []byte, timestamp time.Time) *http.Request {
+	signed := req.Clone(req.Context())
+	if body != nil {
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	contentHash := sha256.Sum256(body)
+	signed.Header.Set(HMACKeyIDHeader, t.keyID)
+	signed.Header.Set(HMACTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	signed.Header.Set(HMACContentHeader, hex.EncodeToString(contentHash[:]))
+	signed.Header.Set(HMACSignatureHeader, Signature(t.secret, signed))
+	return signed
+}
+
+// Signature computes the signature of a request that already carries the
+// timestamp and content hash headers
+func Signature(secret []byte, req *http.Request) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonicalRequest(req)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func canonicalRequest(req *http.Request) string {
+	return strings.Join([]string{
+		strings.ToUpper(req.Method),
+		req.URL.EscapedPath(),
+		sortedQuery(req.URL.Query()),
+		req.Header.Get(HMACTimestampHeader),
+		req.Header.Get(HMACContentHeader),
+	}, "\n")
+}
+
+// sortedQuery encodes query parameters sorted by name and then by value, so
+// the signature does not depend on parameter order
+func sortedQuery(values url.Values) string {
+	var pairs []string
+	for name, list := range values {
+		for _, value := range list {
+			pairs = append(pairs, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// readBody reads the request body so it can be hashed and sent more than
+// once. A request without a body hashes as empty
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
+
+func (t *signingTransport) clockSkew() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.skew
+}
+
+// learnSkew corrects the offset to the gateway clock from a Date header when
+// the corrected local clock still differs by more than maxClockSkew, and
+// returns the offset to use
+func (t *signingTransport) learnSkew(date string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return t.skew
+	}
+	offset := serverTime.Sub(t.now().Add(t.skew))
+	if offset > maxClockSkew || offset < -maxClockSkew {
+		t.skew += offset.Truncate(time.Second)
+	}
+	return t.skew
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+)
+
+func TestHMACSignatureOfKnownRequest(t *testing.T) {
+	var captured *http.Request
+	var capturedBody []byte
+	transport := &signingTransport{
+		base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			captured = req
+			capturedBody, _ = io.ReadAll(req.Body)
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: http.Header{}}, nil
+		}),
+		keyID:  "pb-ci",
+		secret: []byte("s3cret"),
+		now:    func() time.Time { return time.Unix(1700000000, 0) },
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://gw.example.com/api/v1/query?b=2&a=1", bytes.NewBufferString(`{"query":"select 1"}`))
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		HMACKeyIDHeader:     "pb-ci",
+		HMACTimestampHeader: "1700000000",
+		HMACContentHeader:   "69f1d4a18667949e6b56cf0082924bd79616bd6fa64a026124d8899698fb94a4",
+		HMACSignatureHeader: "9a60b83ef815d877b2d97594969dc370847f8de1b6a265e4b0f9bcc77572501e",
+	}
+	for header, value := range expected {
+		if got := captured.Header.Get(header); got != value {
+			t.Errorf("expected %s %q, got %q", header, value, got)
+		}
+	}
+	if string(capturedBody) != `{"query":"select 1"}` {
+		t.Errorf("expected the body to be sent unchanged, got %q", capturedBody)
+	}
+}
+
+func TestHMACCorrectsClockSkew(t *testing.T) {
+	serverClock := time.Now().Add(-10 * time.Minute)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Date", serverClock.UTC().Format(http.TimeFormat))
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HMACTimestampHeader), 10, 64)
+		if skew := time.Unix(timestamp, 0).Sub(serverClock); skew > time.Minute || skew < -time.Minute {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(HMACSignatureHeader) != Signature([]byte("s3cret"), r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	profile := &config.Profile{URL: server.URL, AuthMode: config.AuthModeHMAC, HMACKeyID: "pb-ci", HMACSecret: "s3cret"}
+	client := http.Client{Transport: newSigningTransport(http.DefaultTransport, profile)}
+
+	resp, err := client.Post(server.URL+"/api/v1/ingest", "application/json", bytes.NewBufferString(`[{"a":1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(&requests) != 2 {
+		t.Fatalf("expected a rejected request to be signed again with the gateway clock, got %s after %d requests", resp.Status, atomic.LoadInt32(&requests))
+	}
+
+	// later requests use the learned offset straight away
+	resp, err = client.Get(server.URL + "/api/v1/about")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(&requests) != 3 {
+		t.Errorf("expected the learned offset to be reused, got %s after %d requests", resp.Status, atomic.LoadInt32(&requests))
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -32,12 +32,47 @@ type HTTPClient struct {
 func DefaultClient(profile *config.Profile) HTTPClient {
 	return HTTPClient{
 		Client: http.Client{
-			Timeout: 60 * time.Second,
+			Timeout:   60 * time.Second,
+			Transport: Transport(profile),
 		},
 		Profile: profile,
 	}
 }
 
+// Transport returns the round tripper for requests with the profile, with the
+// TLS settings, tracing, request signing, endpoint failover, conditional
+// requests, retries and slow request warnings it needs
+func Transport(profile *config.Profile) http.RoundTripper {
+	if Offline {
+		return offlineTransport{}
+	}
+	var transport http.RoundTripper = sharedTransport(tlsConfig(profile))
+	if TraceEnabled {
+		transport = &tracingTransport{base: transport, out: TraceOutput}
+	}
+	// sign below the retries, so every retry carries a fresh timestamp
+	if profile.SignsRequests() {
+		transport = newSigningTransport(transport, profile)
+	}
+	// pick the endpoint below the retries, so every retry looks for a
+	// reachable endpoint again
+	transport = newEndpointTransport(transport, profile)
+	// check the server above the endpoints, an unexpected answer is not a
+	// reason to try the next endpoint
+	transport = &serverCheckTransport{base: transport}
+	if !NoConditionalCache {
+		transport = newConditionalTransport(transport, profile)
+	}
+	if MaxRetries > 0 {
+		transport = newRetryTransport(transport)
+	}
+	// time the request as the user sees it, including retries
+	if SlowThreshold > 0 {
+		transport = &slowTransport{base: transport, threshold: SlowThreshold, out: SlowOutput}
+	}
+	return transport
+}
+
 func (client *HTTPClient) baseAPIURL(path string) (x string) {
 	x, _ = url.JoinPath(client.Profile.URL, "api/v1/", path)
 	return
@@ -48,7 +83,11 @@ func (client *HTTPClient) NewRequest(method string, path string, body io.Reader)
 	if err != nil {
 		return
 	}
-	req.SetBasicAuth(client.Profile.Username, client.Profile.Password)
+	auth, err := AuthHeader(client.Profile)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", auth)
 	req.Header.Add("Content-Type", "application/json")
 	return
 }
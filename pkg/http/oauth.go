@@ -0,0 +1,192 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"pb/pkg/config"
+)
+
+var (
+	// tokenExpiryMargin refreshes a token shortly before it expires, so a
+	// request does not reach the server with a token that just ran out
+	tokenExpiryMargin = 30 * time.Second
+
+	// tokenRequestTimeout bounds a single request to the token endpoint
+	tokenRequestTimeout = 30 * time.Second
+
+	tokens = newTokenStore()
+)
+
+// tokenStore caches OAuth2 access tokens in memory for the lifetime of the
+// process, and on disk for profiles that set CacheToken
+type tokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]config.CachedToken
+	now    func() time.Time
+}
+
+func newTokenStore() *tokenStore {
+	return &tokenStore{tokens: make(map[string]config.CachedToken), now: time.Now}
+}
+
+// AuthHeader returns the Authorization header value for a request with the
+// profile. OAuth2 profiles get a bearer token, fetched from the token URL when
+// there is no cached token or it is about to expire
+func AuthHeader(profile *config.Profile) (string, error) {
+	if !profile.UsesOAuth() {
+		return profile.AuthHeader(), nil
+	}
+	token, err := tokens.token(profile)
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+// tokenKey identifies the token of a client, profiles using the same client
+// and scopes share it
+func tokenKey(profile *config.Profile) string {
+	return strings.Join([]string{profile.TokenURL, profile.ClientID, strings.Join(profile.Scopes, " ")}, "|")
+}
+
+func (s *tokenStore) token(profile *config.Profile) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := tokenKey(profile)
+	if token, ok := s.tokens[key]; ok && s.valid(token) {
+		return token.AccessToken, nil
+	}
+	if profile.CacheToken {
+		if cache, err := config.ReadTokenCache(); err == nil {
+			if token, ok := cache.Tokens[key]; ok && s.valid(token) {
+				s.tokens[key] = token
+				return token.AccessToken, nil
+			}
+		}
+	}
+
+	token, err := fetchToken(profile, s.now())
+	if err != nil {
+		return "", err
+	}
+	s.tokens[key] = token
+
+	// a token without a known expiry is only reused within this run
+	if profile.CacheToken && !token.Expiry.IsZero() {
+		if err := storeToken(key, token); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to cache access token: %s\n", err)
+		}
+	}
+	return token.AccessToken, nil
+}
+
+// valid reports whether a token can still be sent, leaving tokenExpiryMargin
+// before it expires. A token without an expiry stays valid
+func (s *tokenStore) valid(token config.CachedToken) bool {
+	if token.AccessToken == "" {
+		return false
+	}
+	return token.Expiry.IsZero() || s.now().Add(tokenExpiryMargin).Before(token.Expiry)
+}
+
+func storeToken(key string, token config.CachedToken) error {
+	cache, err := config.ReadTokenCache()
+	if err != nil {
+		return err
+	}
+	if cache.Tokens == nil {
+		cache.Tokens = make(map[string]config.CachedToken)
+	}
+	cache.Tokens[key] = token
+	return config.WriteTokenCache(cache)
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// fetchToken requests an access token with the client credentials grant. The
+// client authenticates with HTTP basic auth as recommended by RFC 6749
+func fetchToken(profile *config.Profile, now time.Time) (config.CachedToken, error) {
+	if err := CheckOnline(); err != nil {
+		return config.CachedToken{}, err
+	}
+	form := url.Values{"grant_type": []string{"client_credentials"}}
+	if len(profile.Scopes) > 0 {
+		form.Set("scope", strings.Join(profile.Scopes, " "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, profile.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return config.CachedToken{}, err
+	}
+	req.SetBasicAuth(url.QueryEscape(profile.ClientID), url.QueryEscape(profile.ClientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := http.Client{Timeout: tokenRequestTimeout, Transport: sharedTransport(tlsConfig(profile))}
+	resp, err := client.Do(req)
+	if err != nil {
+		return config.CachedToken{}, fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return config.CachedToken{}, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var data tokenResponse
+	decodeErr := json.Unmarshal(body, &data)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && data.Error != "" {
+			return config.CachedToken{}, fmt.Errorf("token endpoint rejected the client credentials: %s", strings.TrimSpace(data.Error+" "+data.ErrorDescription))
+		}
+		return config.CachedToken{}, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return config.CachedToken{}, fmt.Errorf("invalid token response: %w", decodeErr)
+	}
+	if data.AccessToken == "" {
+		return config.CachedToken{}, errors.New("token response has no access_token")
+	}
+	if data.TokenType != "" && !strings.EqualFold(data.TokenType, "bearer") {
+		return config.CachedToken{}, fmt.Errorf("unsupported token type %q, expected bearer", data.TokenType)
+	}
+
+	token := config.CachedToken{AccessToken: data.AccessToken}
+	if data.ExpiresIn > 0 {
+		token.Expiry = now.Add(time.Duration(data.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+)
+
+// newTokenServer returns a token endpoint that issues numbered tokens valid
+// for an hour and counts the requests it served
+func newTokenServer(t *testing.T, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "pb-ci" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"unknown client"}`)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+			t.Errorf("unexpected token request form %v", r.PostForm)
+		}
+		if scope := r.PostForm.Get("scope"); scope != "logs.read logs.write" {
+			t.Errorf("expected scopes to be space separated, got %q", scope)
+		}
+		n := atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+}
+
+func oauthProfile(tokenURL string) *config.Profile {
+	return &config.Profile{
+		URL:          "http://localhost:8000",
+		AuthMode:     config.AuthModeOAuthClientCredentials,
+		TokenURL:     tokenURL,
+		ClientID:     "pb-ci",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"logs.read", "logs.write"},
+	}
+}
+
+func TestOAuthTokenReusedAndRefreshed(t *testing.T) {
+	var requests int32
+	server := newTokenServer(t, &requests)
+	defer server.Close()
+
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := newTokenStore()
+	store.now = func() time.Time { return clock }
+	profile := oauthProfile(server.URL)
+
+	for i := 0; i < 3; i++ {
+		token, err := store.token(profile)
+		if err != nil {
+			t.Fatalf("failed to get token: %v", err)
+		}
+		if token != "token-1" {
+			t.Errorf("expected the first token to be reused, got %q", token)
+		}
+	}
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("expected a single token request, got %d", atomic.LoadInt32(&requests))
+	}
+
+	// inside the expiry margin the token is refreshed before it runs out
+	clock = clock.Add(time.Hour - tokenExpiryMargin)
+	token, err := store.token(profile)
+	if err != nil {
+		t.Fatalf("failed to refresh token: %v", err)
+	}
+	if token != "token-2" || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("expected a refreshed token, got %q after %d requests", token, atomic.LoadInt32(&requests))
+	}
+}
+
+func TestOAuthTokenCachedOnDisk(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	var requests int32
+	server := newTokenServer(t, &requests)
+	defer server.Close()
+
+	profile := oauthProfile(server.URL)
+	profile.CacheToken = true
+	if _, err := newTokenStore().token(profile); err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+
+	// a new process starts with an empty memory cache
+	token, err := newTokenStore().token(profile)
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+	if token != "token-1" || atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("expected the token to be read from disk, got %q after %d requests", token, atomic.LoadInt32(&requests))
+	}
+}
+
+func TestOAuthRejectedCredentials(t *testing.T) {
+	var requests int32
+	server := newTokenServer(t, &requests)
+	defer server.Close()
+
+	profile := oauthProfile(server.URL)
+	profile.ClientSecret = "wrong"
+	_, err := AuthHeader(profile)
+	if err == nil || !strings.Contains(err.Error(), "invalid_client unknown client") {
+		t.Errorf("expected the token endpoint error to be reported, got %v", err)
+	}
+}
+
+func TestAuthHeaderUsesBearerToken(t *testing.T) {
+	var requests int32
+	server := newTokenServer(t, &requests)
+	defer server.Close()
+
+	tokens = newTokenStore()
+	client := DefaultClient(oauthProfile(server.URL))
+	req, err := client.NewRequest(http.MethodGet, "about", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token-1" {
+		t.Errorf("expected bearer token header, got %q", got)
+	}
+}
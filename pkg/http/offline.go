@@ -0,0 +1,52 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// Offline turns off every network call. It is set by the global --offline
+	// flag or PB_OFFLINE
+	Offline bool
+
+	// ErrOffline is returned instead of making a network call in offline mode
+	ErrOffline = errors.New("offline mode is on and pb makes no network calls, unset --offline or PB_OFFLINE to reach the server")
+)
+
+// CheckOnline returns ErrOffline in offline mode, for network calls that do
+// not go through Transport
+func CheckOnline() error {
+	if Offline {
+		return ErrOffline
+	}
+	return nil
+}
+
+// offlineTransport fails every request at once instead of letting it wait for
+// a network that is not there
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, fmt.Errorf("%w (%s %s)", ErrOffline, req.Method, req.URL.Host)
+}
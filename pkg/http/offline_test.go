@@ -0,0 +1,57 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+func TestOfflineMakesNoRequests(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	Offline = true
+	defer func() { Offline = false }()
+
+	client := DefaultClient(&config.Profile{URL: server.URL})
+	req, err := client.NewRequest(http.MethodGet, "about", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Client.Do(req); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected the offline error, got %v", err)
+	}
+
+	// OAuth profiles fetch a token before the request is built
+	profile := &config.Profile{URL: server.URL, AuthMode: config.AuthModeOAuthClientCredentials, TokenURL: server.URL + "/token", ClientID: "pb"}
+	if _, err := AuthHeader(profile); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected the token request to be refused, got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("expected no request to reach the server, got %d", n)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxRetries is the number of times a request is retried when the
+// server is temporarily unavailable
+const DefaultMaxRetries = 3
+
+var (
+	// MaxRetries bounds the retries of a single request. It is set by the
+	// --max-retries flag, 0 disables retries
+	MaxRetries = DefaultMaxRetries
+
+	// RetryOutput is where retry notices are written
+	RetryOutput io.Writer = os.Stderr
+
+	// retryBaseDelay is the first delay of the exponential backoff used when
+	// the server does not send a Retry-After header
+	retryBaseDelay = time.Second
+
+	// maxRetryWait caps the total time spent waiting between attempts of a
+	// request. It stays well below the 60 second timeout of DefaultClient,
+	// so a long maintenance window fails with a clear error instead of the
+	// client deadline
+	maxRetryWait = 20 * time.Second
+)
+
+// retryableStatus lists the gateway and availability errors a restarting
+// server or load balancer returns while the request was not processed
+var retryableStatus = map[int]bool{
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// retryTransport wraps a RoundTripper and retries requests the server could
+// not handle. A Retry-After header sets the delay, otherwise the delay
+// doubles after every attempt. GET, HEAD and OPTIONS are retried on every
+// status in retryableStatus. Other methods may have been processed behind a
+// gateway error, so they are only retried on a 503 that carries Retry-After
+type retryTransport struct {
+	base       http.RoundTripper
+	out        io.Writer
+	maxRetries int
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+	return &retryTransport{base: base, out: RetryOutput, maxRetries: MaxRetries, sleep: sleepContext}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a body that cannot be replayed can only be sent once
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	backoff := retryBaseDelay
+	var waited time.Duration
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			attemptReq = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil || !retryableStatus[resp.StatusCode] {
+			return resp, err
+		}
+
+		delay, fromHeader := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !idempotentMethod(req.Method) && (resp.StatusCode != http.StatusServiceUnavailable || !fromHeader) {
+			return resp, nil
+		}
+		if !fromHeader {
+			delay = backoff
+			backoff *= 2
+		}
+		if attempt >= t.maxRetries || waited+delay > maxRetryWait || pastDeadline(req.Context(), delay) {
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				return resp, nil
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("server unavailable after %d attempts, it may be under maintenance or restarting. Try again later", attempt+1)
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		fmt.Fprintf(t.out, "Server unavailable (%s), retrying in %s (retry %d of %d)\n", resp.Status, delay, attempt+1, t.maxRetries)
+		if err := t.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		waited += delay
+	}
+}
+
+// idempotentMethod reports whether a request can be sent again without
+// changing anything on the server when it was processed the first time
+func idempotentMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// pastDeadline reports whether waiting d would run past the deadline of ctx
+func pastDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Now().Add(d).After(deadline)
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date. The second result is false when no usable delay was sent
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay.Round(time.Second), true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRetryTransport(out io.Writer, delays *[]time.Duration) *retryTransport {
+	return &retryTransport{
+		base:       http.DefaultTransport,
+		out:        out,
+		maxRetries: 3,
+		sleep: func(_ context.Context, d time.Duration) error {
+			*delays = append(*delays, d)
+			return nil
+		},
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var requests int
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if requests == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	var delays []time.Duration
+	client := http.Client{Transport: newTestRetryTransport(&out, &delays)}
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 after retrying, got %s", resp.Status)
+	}
+	if len(delays) != 1 || delays[0] != 7*time.Second {
+		t.Errorf("expected a single 7s delay from Retry-After, got %v", delays)
+	}
+	if len(bodies) != 2 || bodies[1] != `{"a":1}` {
+		t.Errorf("expected the request body to be resent, got %q", bodies)
+	}
+	if !strings.Contains(out.String(), "retrying in 7s") {
+		t.Errorf("expected a retry notice, got %q", out.String())
+	}
+}
+
+func TestRetryReportsMaintenanceWhenExhausted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	client := http.Client{Transport: newTestRetryTransport(io.Discard, &delays)}
+
+	_, err := client.Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "maintenance") {
+		t.Fatalf("expected a maintenance error, got %v", err)
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	if len(delays) != len(expected) {
+		t.Fatalf("expected backoff delays %v, got %v", expected, delays)
+	}
+	for idx := range expected {
+		if delays[idx] != expected[idx] {
+			t.Errorf("expected backoff delays %v, got %v", expected, delays)
+			break
+		}
+	}
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	delay, ok := retryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now)
+	if !ok || delay != 30*time.Second {
+		t.Errorf("expected 30s from an HTTP date, got %v (%v)", delay, ok)
+	}
+	if _, ok := retryAfter("soon", now); ok {
+		t.Error("expected an unparsable Retry-After to be ignored")
+	}
+}
+
+func TestRetryLeavesNonIdempotentRequestsAlone(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/gateway":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	client := http.Client{Transport: newTestRetryTransport(io.Discard, &delays)}
+
+	for _, path := range []string{"/gateway", "/unavailable"} {
+		requests = 0
+		resp, err := client.Post(server.URL+path, "application/json", strings.NewReader(`{"a":1}`))
+		if err != nil {
+			t.Fatalf("%s: expected the response to be returned, got %v", path, err)
+		}
+		resp.Body.Close()
+		if requests != 1 {
+			t.Errorf("%s: expected a POST without Retry-After to be sent once, got %d requests", path, requests)
+		}
+	}
+	if len(delays) != 0 {
+		t.Errorf("expected no retries, got delays %v", delays)
+	}
+}
+
+func TestRetryStopsWithinWaitBudget(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "15")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	client := http.Client{Transport: newTestRetryTransport(io.Discard, &delays)}
+
+	_, err := client.Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Fatalf("expected to give up once the waits pass %s, got %v", maxRetryWait, err)
+	}
+	if len(delays) != 1 {
+		t.Errorf("expected a single wait, got %v", delays)
+	}
+}
+
+func TestRetryDoesNotModifyRequest(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	transport := newTestRetryTransport(io.Discard, &delays)
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	header := req.Header.Clone()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if requests != 2 || req.Body != nil || len(req.Header) != len(header) {
+		t.Errorf("expected the retry to use a copy of the request, got %d requests and %+v", requests, req)
+	}
+}
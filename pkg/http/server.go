@@ -0,0 +1,77 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// apiPrefix is the path every Parseable API request is made under
+const apiPrefix = "/api/v1/"
+
+// NotParseableError is returned when the server a profile points at answers
+// API requests with something that is not a Parseable response, such as the
+// HTML page of an unrelated website
+type NotParseableError struct {
+	// URL is the server the profile points at
+	URL string
+	// ContentType is the content type the server answered with
+	ContentType string
+}
+
+func (e *NotParseableError) Error() string {
+	return fmt.Sprintf("the server at %s doesn't appear to be a Parseable instance, it answered with %s instead of an API response. "+
+		"Check the URL and port of the profile with pb profile list, Parseable listens on port 8000 by default", e.URL, e.ContentType)
+}
+
+// serverCheckTransport rejects HTML responses to API requests. Parseable
+// answers its API with JSON or plain text, an HTML page means the profile
+// points at a different server, or at the console behind a wrong path
+type serverCheckTransport struct {
+	base http.RoundTripper
+}
+
+func (t *serverCheckTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	idx := strings.Index(req.URL.Path, apiPrefix)
+	// 5xx pages usually come from a proxy in front of the server and are
+	// left to the retries and the caller's error handling
+	if idx < 0 || resp.StatusCode >= http.StatusInternalServerError {
+		return resp, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" {
+		return resp, nil
+	}
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	server := *req.URL
+	server.Path = req.URL.Path[:idx]
+	server.RawPath = ""
+	server.RawQuery = ""
+	server.User = nil
+	return nil, &NotParseableError{URL: server.String(), ContentType: mediaType}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pb/pkg/config"
+)
+
+func getAbout(t *testing.T, handler http.HandlerFunc) (*http.Response, error) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := DefaultClient(&config.Profile{URL: server.URL})
+	req, err := client.NewRequest(http.MethodGet, "about", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.Client.Do(req)
+}
+
+func TestHTMLResponseIsNotParseable(t *testing.T) {
+	_, err := getAbout(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<!DOCTYPE html><html><body>Welcome to nginx!</body></html>"))
+	})
+
+	var notParseable *NotParseableError
+	if !errors.As(err, &notParseable) {
+		t.Fatalf("expected a NotParseableError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "doesn't appear to be a Parseable instance") {
+		t.Errorf("expected a friendly error, got %v", err)
+	}
+	if strings.Contains(notParseable.URL, "/api/v1") || !strings.HasPrefix(notParseable.URL, "http://127.0.0.1") {
+		t.Errorf("expected the error to name the profile URL, got %q", notParseable.URL)
+	}
+}
+
+func TestJSONResponsePasses(t *testing.T) {
+	resp, err := getAbout(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"v1.0.0"}`))
+	})
+	if err != nil {
+		t.Fatalf("expected a JSON response to pass, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"version":"v1.0.0"}` {
+		t.Errorf("expected the body to be untouched, got %q", body)
+	}
+}
+
+func TestHTMLServerErrorPasses(t *testing.T) {
+	resp, err := getAbout(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>502 Bad Gateway</html>"))
+	})
+	if err != nil {
+		t.Fatalf("expected a proxy error page to reach the caller, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", resp.StatusCode)
+	}
+}
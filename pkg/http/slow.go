@@ -0,0 +1,116 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// SlowThreshold is the duration after which a request is reported as slow.
+// It is set by the --slow-threshold flag, 0 turns the warnings off
+var SlowThreshold time.Duration
+
+// SlowOutput is where slow request warnings are written
+var SlowOutput io.Writer = os.Stderr
+
+// slowRequests counts the slow requests of the running command for the
+// summary printed by ReportSlowRequests
+var slowRequests struct {
+	mu      sync.Mutex
+	count   int
+	slowest time.Duration
+}
+
+// slowTransport warns about requests that take longer than the threshold,
+// measured until the response body has been read or closed
+type slowTransport struct {
+	base      http.RoundTripper
+	threshold time.Duration
+	out       io.Writer
+}
+
+func (t *slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.observe(req, time.Since(start))
+		return resp, err
+	}
+	resp.Body = &slowBody{ReadCloser: resp.Body, done: func() { t.observe(req, time.Since(start)) }}
+	return resp, nil
+}
+
+func (t *slowTransport) observe(req *http.Request, took time.Duration) {
+	if took <= t.threshold {
+		return
+	}
+	slowRequests.mu.Lock()
+	slowRequests.count++
+	if took > slowRequests.slowest {
+		slowRequests.slowest = took
+	}
+	slowRequests.mu.Unlock()
+
+	fmt.Fprintf(t.out, "pb: %s %s took %s (slow)\n", req.Method, req.URL.Path, roundLatency(took))
+}
+
+// slowBody calls done once, when the body is fully read or closed
+type slowBody struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *slowBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// ReportSlowRequests prints a summary when more than one request of the
+// command was slow, and starts counting again for the next command
+func ReportSlowRequests(w io.Writer) {
+	slowRequests.mu.Lock()
+	defer slowRequests.mu.Unlock()
+
+	if slowRequests.count > 1 {
+		fmt.Fprintf(w, "pb: %d requests took longer than %s, the slowest took %s. The server may be struggling\n",
+			slowRequests.count, SlowThreshold, roundLatency(slowRequests.slowest))
+	}
+	slowRequests.count = 0
+	slowRequests.slowest = 0
+}
+
+// roundLatency keeps warnings short, e.g. 4.2s or 350ms
+func roundLatency(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowServer answers every request after the given delay
+func slowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(delay)
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func slowGet(t *testing.T, url string, threshold time.Duration, out io.Writer) {
+	client := http.Client{Transport: &slowTransport{base: http.DefaultTransport, threshold: threshold, out: out}}
+	resp, err := client.Get(url + "/api/v1/about")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+}
+
+func TestSlowRequestWarns(t *testing.T) {
+	defer ReportSlowRequests(io.Discard)
+	server := slowServer(t, 80*time.Millisecond)
+
+	var out bytes.Buffer
+	slowGet(t, server.URL, 20*time.Millisecond, &out)
+
+	if !strings.HasPrefix(out.String(), "pb: GET /api/v1/about took ") || !strings.HasSuffix(out.String(), " (slow)\n") {
+		t.Errorf("expected a slow request warning, got %q", out.String())
+	}
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("expected the request to be reported exactly once, got %q", out.String())
+	}
+}
+
+func TestFastRequestDoesNotWarn(t *testing.T) {
+	defer ReportSlowRequests(io.Discard)
+	server := slowServer(t, 0)
+
+	var out bytes.Buffer
+	slowGet(t, server.URL, time.Second, &out)
+
+	if out.Len() != 0 {
+		t.Errorf("expected no warning below the threshold, got %q", out.String())
+	}
+	var summary bytes.Buffer
+	ReportSlowRequests(&summary)
+	if summary.Len() != 0 {
+		t.Errorf("expected no summary without slow requests, got %q", summary.String())
+	}
+}
+
+func TestSlowRequestSummary(t *testing.T) {
+	server := slowServer(t, 50*time.Millisecond)
+
+	slowGet(t, server.URL, 10*time.Millisecond, io.Discard)
+	var summary bytes.Buffer
+	ReportSlowRequests(&summary)
+	if summary.Len() != 0 {
+		t.Errorf("expected no summary for a single slow request, got %q", summary.String())
+	}
+
+	slowGet(t, server.URL, 10*time.Millisecond, io.Discard)
+	slowGet(t, server.URL, 10*time.Millisecond, io.Discard)
+	ReportSlowRequests(&summary)
+	if !strings.HasPrefix(summary.String(), "pb: 2 requests took longer than") {
+		t.Errorf("expected a summary of the slow requests, got %q", summary.String())
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"pb/pkg/config"
+	"strings"
+	"sync"
+)
+
+// DefaultMinTLSVersion is used when neither the --min-tls-version flag nor
+// the profile sets a minimum
+const DefaultMinTLSVersion = "1.2"
+
+// MinTLSVersion overrides the profile minimum TLS version. It is set by the
+// --min-tls-version flag
+var MinTLSVersion string
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsVersionNames = []string{"1.0", "1.1", "1.2", "1.3"}
+
+// ParseTLSVersion converts a version string such as "1.2" to its crypto/tls
+// constant
+func ParseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(version), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q, valid values are %s", version, strings.Join(tlsVersionNames, ", "))
+	}
+	return v, nil
+}
+
+// ParseCipherSuites converts cipher suite names as listed by crypto/tls to
+// their IDs. Only suites without known security issues are accepted
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	var valid []string
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+		valid = append(valid, suite.Name)
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q, valid values are %s", name, strings.Join(valid, ", "))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ValidateTLSConfig checks the TLS settings of a profile together with the
+// --min-tls-version override
+func ValidateTLSConfig(profile *config.Profile) error {
+	if _, err := ParseTLSVersion(minTLSVersion(profile)); err != nil {
+		return err
+	}
+	_, err := ParseCipherSuites(profile.TLSCipherSuites)
+	return err
+}
+
+func minTLSVersion(profile *config.Profile) string {
+	if MinTLSVersion != "" {
+		return MinTLSVersion
+	}
+	if profile != nil && profile.MinTLSVersion != "" {
+		return profile.MinTLSVersion
+	}
+	return DefaultMinTLSVersion
+}
+
+// tlsConfig builds the client TLS configuration for a profile. Invalid
+// settings fall back to the defaults, they are reported by ValidateTLSConfig
+// before any request is made
+func tlsConfig(profile *config.Profile) *tls.Config {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if version, err := ParseTLSVersion(minTLSVersion(profile)); err == nil {
+		conf.MinVersion = version
+	}
+	if profile != nil && len(profile.TLSCipherSuites) > 0 {
+		if suites, err := ParseCipherSuites(profile.TLSCipherSuites); err == nil {
+			conf.CipherSuites = suites
+		}
+	}
+	return conf
+}
+
+func newTransport(conf *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = conf
+	return transport
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[string]*http.Transport)
+)
+
+// sharedTransport returns one transport per distinct TLS configuration so
+// that clients created for the same profile reuse open connections
+func sharedTransport(conf *tls.Config) *http.Transport {
+	key := fmt.Sprint(conf.MinVersion, conf.CipherSuites)
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if transport, ok := transports[key]; ok {
+		return transport
+	}
+	transport := newTransport(conf)
+	transports[key] = transport
+	return transport
+}
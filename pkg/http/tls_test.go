@@ -0,0 +1,79 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"pb/pkg/config"
+	"strings"
+	"testing"
+)
+
+func TestMinTLSVersionRefusesOlderServer(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	get := func(minVersion string) error {
+		conf := tlsConfig(&config.Profile{MinTLSVersion: minVersion})
+		conf.RootCAs = roots
+		client := http.Client{Transport: newTransport(conf)}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get("1.2"); err != nil {
+		t.Fatalf("expected handshake to succeed with minimum 1.2, got %v", err)
+	}
+	if err := get("1.3"); err == nil {
+		t.Fatal("expected handshake with a TLS 1.2 only server to be refused with minimum 1.3")
+	}
+}
+
+func TestMinTLSVersionDefaultsAndOverride(t *testing.T) {
+	if v := tlsConfig(&config.Profile{}).MinVersion; v != tls.VersionTLS12 {
+		t.Errorf("expected default minimum TLS 1.2, got %x", v)
+	}
+
+	MinTLSVersion = "1.3"
+	defer func() { MinTLSVersion = "" }()
+	if v := tlsConfig(&config.Profile{MinTLSVersion: "1.2"}).MinVersion; v != tls.VersionTLS13 {
+		t.Errorf("expected --min-tls-version to override the profile, got %x", v)
+	}
+}
+
+func TestParseTLSVersionRejectsUnknown(t *testing.T) {
+	_, err := ParseTLSVersion("2.0")
+	if err == nil {
+		t.Fatal("expected unknown version to be rejected")
+	}
+	if !strings.Contains(err.Error(), "1.0, 1.1, 1.2, 1.3") {
+		t.Errorf("expected valid values in error, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceEnabled turns on per request timing reports. It is set by the --trace flag
+var TraceEnabled bool
+
+// TraceOutput is where timing reports are written when tracing is enabled
+var TraceOutput io.Writer = os.Stderr
+
+// tracingTransport wraps a RoundTripper and reports the time spent in each
+// phase of a request once its response body has been consumed
+type tracingTransport struct {
+	base http.RoundTripper
+	out  io.Writer
+}
+
+type requestTimings struct {
+	start               time.Time
+	dnsStart, dnsDone   time.Time
+	connStart, connDone time.Time
+	tlsStart, tlsDone   time.Time
+	firstByte           time.Time
+	reusedConn          bool
+	method, url, status string
+	err                 error
+	reportOnce          sync.Once
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timings := &requestTimings{method: req.Method, url: req.URL.String()}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { timings.dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { timings.dnsDone = time.Now() },
+		ConnectStart:      func(_, _ string) { timings.connStart = time.Now() },
+		ConnectDone:       func(_, _ string, _ error) { timings.connDone = time.Now() },
+		TLSHandshakeStart: func() { timings.tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { timings.tlsDone = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			timings.reusedConn = info.Reused
+		},
+		GotFirstResponseByte: func() { timings.firstByte = time.Now() },
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	timings.start = time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		timings.err = err
+		timings.report(t.out, time.Now())
+		return resp, err
+	}
+
+	timings.status = resp.Status
+	resp.Body = &tracingBody{ReadCloser: resp.Body, timings: timings, out: t.out}
+	return resp, nil
+}
+
+// tracingBody reports the request timings when the body is fully read or closed
+type tracingBody struct {
+	io.ReadCloser
+	timings *requestTimings
+	out     io.Writer
+}
+
+func (b *tracingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.timings.report(b.out, time.Now())
+	}
+	return n, err
+}
+
+func (b *tracingBody) Close() error {
+	b.timings.report(b.out, time.Now())
+	return b.ReadCloser.Close()
+}
+
+func (r *requestTimings) report(out io.Writer, end time.Time) {
+	r.reportOnce.Do(func() {
+		var s strings.Builder
+		fmt.Fprintf(&s, "* %s %s\n", r.method, r.url)
+		fmt.Fprintf(&s, "*   %-15s %s\n", "dns lookup:", phase(r.dnsStart, r.dnsDone, r.reusedConn))
+		fmt.Fprintf(&s, "*   %-15s %s\n", "connect:", phase(r.connStart, r.connDone, r.reusedConn))
+		fmt.Fprintf(&s, "*   %-15s %s\n", "tls handshake:", phase(r.tlsStart, r.tlsDone, r.reusedConn))
+		fmt.Fprintf(&s, "*   %-15s %s\n", "first byte:", phase(r.start, r.firstByte, false))
+		fmt.Fprintf(&s, "*   %-15s %s\n", "total:", end.Sub(r.start).Round(time.Microsecond))
+		if r.err != nil {
+			fmt.Fprintf(&s, "*   %-15s %v\n", "error:", r.err)
+		} else {
+			fmt.Fprintf(&s, "*   %-15s %s\n", "status:", r.status)
+		}
+		fmt.Fprint(out, s.String())
+	})
+}
+
+// phase formats the duration between two trace events, or explains why the
+// phase did not happen
+func phase(start, end time.Time, reused bool) string {
+	if start.IsZero() || end.IsZero() {
+		if reused {
+			return "- (reused connection)"
+		}
+		return "-"
+	}
+	return end.Sub(start).Round(time.Microsecond).String()
+}
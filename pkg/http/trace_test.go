@@ -0,0 +1,69 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceReportsPhases(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	client := http.Client{
+		Transport: &tracingTransport{base: server.Client().Transport, out: &out},
+	}
+
+	resp, err := client.Get(server.URL + "/api/v1/about")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	report := out.String()
+	if !strings.Contains(report, "* GET "+server.URL+"/api/v1/about") {
+		t.Errorf("expected request line in report, got:\n%s", report)
+	}
+
+	for _, label := range []string{"dns lookup:", "connect:", "tls handshake:", "first byte:", "total:", "status:"} {
+		if !strings.Contains(report, label) {
+			t.Errorf("expected %q in report, got:\n%s", label, report)
+		}
+	}
+
+	// a fresh TLS connection must have measured connect and handshake times
+	for _, label := range []string{"connect:", "tls handshake:"} {
+		for _, line := range strings.Split(report, "\n") {
+			if strings.Contains(line, label) && strings.HasSuffix(line, "-") {
+				t.Errorf("expected %q to be measured, got %q", label, line)
+			}
+		}
+	}
+
+	if strings.Count(report, "* GET") != 1 {
+		t.Errorf("expected the request to be reported exactly once, got:\n%s", report)
+	}
+}
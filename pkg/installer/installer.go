@@ -22,7 +22,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"os/exec"
@@ -50,22 +49,26 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-func Installer(verbose bool) {
+// Installer runs the interactive installation and returns the entry of the
+// installed release. An existing release of the same name is upgraded or left
+// alone when it already matches, with force it is installed again. Failures
+// are returned rather than exiting so that pb shell keeps running
+func Installer(verbose, force bool) (common.InstallerEntry, error) {
 	printBanner()
-	waterFall(verbose)
+	return waterFall(verbose, force)
 }
 
 // waterFall orchestrates the installation process
-func waterFall(verbose bool) {
+func waterFall(verbose, force bool) (common.InstallerEntry, error) {
 	var chartValues []string
 	plan, err := promptUserPlanSelection()
 	if err != nil {
-		log.Fatalf("Failed to prompt for plan selection: %v", err)
+		return common.InstallerEntry{}, fmt.Errorf("failed to prompt for plan selection: %w", err)
 	}
 
 	_, err = common.PromptK8sContext()
 	if err != nil {
-		log.Fatalf("Failed to prompt for kubernetes context: %v", err)
+		return common.InstallerEntry{}, fmt.Errorf("failed to prompt for kubernetes context: %w", err)
 	}
 
 	if plan.Name == "Playground" {
@@ -75,17 +78,17 @@ func waterFall(verbose bool) {
 		// Prompt for namespace and credentials
 		pbInfo, err := promptNamespaceAndCredentials()
 		if err != nil {
-			log.Fatalf("Failed to prompt for namespace and credentials: %v", err)
+			return common.InstallerEntry{}, fmt.Errorf("failed to prompt for namespace and credentials: %w", err)
 		}
 
 		// Prompt for agent deployment
 		_, agentValues, err := promptAgentDeployment(chartValues, *pbInfo)
 		if err != nil {
-			log.Fatalf("Failed to prompt for agent deployment: %v", err)
+			return common.InstallerEntry{}, fmt.Errorf("failed to prompt for agent deployment: %w", err)
 		}
 
 		if err := applyParseableSecret(pbInfo, LocalStore, ObjectStoreConfig{}); err != nil {
-			log.Fatalf("Failed to apply secret object store configuration: %v", err)
+			return common.InstallerEntry{}, fmt.Errorf("failed to apply secret object store configuration: %w", err)
 		}
 
 		// Define the deployment configuration
@@ -100,22 +103,27 @@ func waterFall(verbose bool) {
 			Verbose:     verbose,
 		}
 
-		if err := deployRelease(config); err != nil {
-			log.Fatalf("Failed to deploy parseable, err: %v", err)
+		outcome, err := reconcileRelease(helmReleases{}, config, force)
+		if err != nil {
+			return common.InstallerEntry{}, fmt.Errorf("failed to deploy parseable: %w", err)
 		}
+		printOutcome(outcome, config)
 
-		if err := updateInstallerConfigMap(common.InstallerEntry{
+		entry := common.InstallerEntry{
 			Name:      pbInfo.Name,
 			Namespace: pbInfo.Namespace,
 			Version:   config.Version,
 			Status:    "success",
-		}); err != nil {
-			log.Fatalf("Failed to update parseable installer file, err: %v", err)
+		}
+		if err := updateInstallerConfigMap(entry); err != nil {
+			return common.InstallerEntry{}, fmt.Errorf("failed to update parseable installer file: %w", err)
 		}
 
-		printSuccessBanner(*pbInfo, config.Version, "parseable", "parseable")
+		if outcome != OutcomeUnchanged {
+			printSuccessBanner(*pbInfo, config.Version, "parseable", "parseable")
+		}
 
-		return
+		return entry, nil
 	}
 
 	// pb supports only distributed deployments
@@ -124,29 +132,29 @@ func waterFall(verbose bool) {
 	// Prompt for namespace and credentials
 	pbInfo, err := promptNamespaceAndCredentials()
 	if err != nil {
-		log.Fatalf("Failed to prompt for namespace and credentials: %v", err)
+		return common.InstallerEntry{}, fmt.Errorf("failed to prompt for namespace and credentials: %w", err)
 	}
 
 	// Prompt for agent deployment
 	_, agentValues, err := promptAgentDeployment(chartValues, *pbInfo)
 	if err != nil {
-		log.Fatalf("Failed to prompt for agent deployment: %v", err)
+		return common.InstallerEntry{}, fmt.Errorf("failed to prompt for agent deployment: %w", err)
 	}
 
 	// Prompt for store configuration
 	store, storeValues, err := promptStore(agentValues)
 	if err != nil {
-		log.Fatalf("Failed to prompt for store configuration: %v", err)
+		return common.InstallerEntry{}, fmt.Errorf("failed to prompt for store configuration: %w", err)
 	}
 
 	// Prompt for object store configuration and get the final chart values
 	objectStoreConfig, storeConfigs, err := promptStoreConfigs(store, storeValues, plan)
 	if err != nil {
-		log.Fatalf("Failed to prompt for object store configuration: %v", err)
+		return common.InstallerEntry{}, fmt.Errorf("failed to prompt for object store configuration: %w", err)
 	}
 
 	if err := applyParseableSecret(pbInfo, store, objectStoreConfig); err != nil {
-		log.Fatalf("Failed to apply secret object store configuration: %v", err)
+		return common.InstallerEntry{}, fmt.Errorf("failed to apply secret object store configuration: %w", err)
 	}
 
 	// Define the deployment configuration
@@ -161,23 +169,28 @@ func waterFall(verbose bool) {
 		Verbose:     verbose,
 	}
 
-	if err := deployRelease(config); err != nil {
-		log.Fatalf("Failed to deploy parseable, err: %v", err)
+	outcome, err := reconcileRelease(helmReleases{}, config, force)
+	if err != nil {
+		return common.InstallerEntry{}, fmt.Errorf("failed to deploy parseable: %w", err)
 	}
+	printOutcome(outcome, config)
 
-	if err := updateInstallerConfigMap(common.InstallerEntry{
+	entry := common.InstallerEntry{
 		Name:      pbInfo.Name,
 		Namespace: pbInfo.Namespace,
 		Version:   config.Version,
 		Status:    "success",
-	}); err != nil {
-		log.Fatalf("Failed to update parseable installer file, err: %v", err)
+	}
+	if err := updateInstallerConfigMap(entry); err != nil {
+		return common.InstallerEntry{}, fmt.Errorf("failed to update parseable installer file: %w", err)
 	}
 
-	ingestorURL, queryURL := getParseableSvcUrls(pbInfo.Name, pbInfo.Namespace)
-
-	printSuccessBanner(*pbInfo, config.Version, ingestorURL, queryURL)
+	if outcome != OutcomeUnchanged {
+		ingestorURL, queryURL := getParseableSvcUrls(pbInfo.Name, pbInfo.Namespace)
+		printSuccessBanner(*pbInfo, config.Version, ingestorURL, queryURL)
+	}
 
+	return entry, nil
 }
 
 // promptStorageClass fetches and prompts the user to select a Kubernetes storage class
@@ -529,7 +542,7 @@ func promptStoreConfigs(store ObjectStore, chartValues []string, plan Plan) (Obj
 
 		sc, err := promptStorageClass()
 		if err != nil {
-			log.Fatalf("Failed to prompt for storage class: %v", err)
+			return ObjectStoreConfig{}, nil, fmt.Errorf("failed to prompt for storage class: %w", err)
 		}
 		storeValues.StorageClass = sc
 		storeValues.ObjectStore = S3Store
@@ -547,7 +560,7 @@ func promptStoreConfigs(store ObjectStore, chartValues []string, plan Plan) (Obj
 	case BlobStore:
 		sc, err := promptStorageClass()
 		if err != nil {
-			log.Fatalf("Failed to prompt for storage class: %v", err)
+			return ObjectStoreConfig{}, nil, fmt.Errorf("failed to prompt for storage class: %w", err)
 		}
 		storeValues.BlobStore = Blob{
 			StorageAccountName: promptForInputWithDefault(common.Yellow+"  Enter Blob Storage Account Name: "+common.Reset, ""),
@@ -580,7 +593,7 @@ func promptStoreConfigs(store ObjectStore, chartValues []string, plan Plan) (Obj
 	case GcsStore:
 		sc, err := promptStorageClass()
 		if err != nil {
-			log.Fatalf("Failed to prompt for storage class: %v", err)
+			return ObjectStoreConfig{}, nil, fmt.Errorf("failed to prompt for storage class: %w", err)
 		}
 		storeValues.GCSStore = GCS{
 			Bucket:    promptForInputWithDefault(common.Yellow+"  Enter GCS Bucket: "+common.Reset, ""),
@@ -664,8 +677,18 @@ func applyManifest(manifest string) error {
 		return fmt.Errorf("failed to get GVR: %w", err)
 	}
 
-	// Apply the manifest using the dynamic client
-	_, err = dynamicClient.Resource(gvr).Namespace(namespace).Create(context.TODO(), &obj, metav1.CreateOptions{})
+	// Apply the manifest using the dynamic client, updating the object when
+	// an earlier install already created it
+	resource := dynamicClient.Resource(gvr).Namespace(namespace)
+	_, err = resource.Create(context.TODO(), &obj, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		var existing *unstructured.Unstructured
+		existing, err = resource.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+		if err == nil {
+			obj.SetResourceVersion(existing.GetResourceVersion())
+			_, err = resource.Update(context.TODO(), &obj, metav1.UpdateOptions{})
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to apply manifest: %w", err)
 	}
@@ -737,6 +760,20 @@ type HelmDeploymentConfig struct {
 
 // deployRelease handles the deployment of a Helm release using a configuration struct
 func deployRelease(config HelmDeploymentConfig) error {
+	msg := fmt.Sprintf(" Deploying parseable release name [%s] namespace [%s] ", config.ReleaseName, config.Namespace)
+	return runHelm(config, msg, func(app helm.Helm) error {
+		return helm.Apply(app, config.Verbose)
+	})
+}
+
+// upgradeRelease upgrades an existing Helm release to the configuration
+func upgradeRelease(config HelmDeploymentConfig) error {
+	msg := fmt.Sprintf(" Upgrading parseable release name [%s] namespace [%s] ", config.ReleaseName, config.Namespace)
+	return runHelm(config, msg, helm.Upgrade)
+}
+
+// runHelm runs a Helm operation for the configuration behind a spinner
+func runHelm(config HelmDeploymentConfig, msg string, operation func(helm.Helm) error) error {
 	// Helm application configuration
 	app := helm.Helm{
 		ReleaseName: config.ReleaseName,
@@ -749,7 +786,6 @@ func deployRelease(config HelmDeploymentConfig) error {
 	}
 
 	// Create a spinner
-	msg := fmt.Sprintf(" Deploying parseable release name [%s] namespace [%s] ", config.ReleaseName, config.Namespace)
 	spinner := common.CreateDeploymentSpinner(msg)
 
 	// Redirect standard output if not in verbose mode
@@ -769,7 +805,7 @@ func deployRelease(config HelmDeploymentConfig) error {
 
 	go func() {
 		defer wg.Done()
-		if err := helm.Apply(app, config.Verbose); err != nil {
+		if err := operation(app); err != nil {
 			errCh <- err
 		}
 	}()
@@ -971,7 +1007,7 @@ func updateInstallerConfigMap(entry common.InstallerEntry) error {
 			return fmt.Errorf("failed to parse existing ConfigMap data: %v", err)
 		}
 	}
-	entries = append(entries, entry)
+	entries = upsertInstallerEntry(entries, entry)
 
 	// Marshal the updated data back to YAML
 	updatedData, err := yamling.Marshal(entries)
@@ -0,0 +1,157 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"pb/pkg/common"
+	"pb/pkg/helm"
+
+	"helm.sh/helm/v3/pkg/strvals"
+)
+
+// Outcome reports what an install run did to the release
+type Outcome string
+
+const (
+	OutcomeInstalled   Outcome = "installed"
+	OutcomeUpgraded    Outcome = "upgraded"
+	OutcomeReinstalled Outcome = "reinstalled"
+	OutcomeUnchanged   Outcome = "unchanged"
+)
+
+// releaseClient is the part of Helm the installer needs to reconcile a
+// release, so the decision can be tested without a cluster
+type releaseClient interface {
+	// Get returns the chart version and user supplied values of a release,
+	// found is false when it does not exist
+	Get(name, namespace string) (version string, values map[string]interface{}, found bool, err error)
+	Install(config HelmDeploymentConfig) error
+	Upgrade(config HelmDeploymentConfig) error
+	Uninstall(name, namespace string) error
+}
+
+// reconcileRelease makes the release match config. A missing release is
+// installed, one with another chart version or other values is upgraded and
+// a matching one is left alone. With force an existing release is removed
+// and installed again
+func reconcileRelease(client releaseClient, config HelmDeploymentConfig, force bool) (Outcome, error) {
+	version, values, found, err := client.Get(config.ReleaseName, config.Namespace)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for an existing release: %w", err)
+	}
+
+	switch {
+	case !found:
+		return OutcomeInstalled, client.Install(config)
+	case force:
+		if err := client.Uninstall(config.ReleaseName, config.Namespace); err != nil {
+			return "", fmt.Errorf("failed to remove the existing release: %w", err)
+		}
+		return OutcomeReinstalled, client.Install(config)
+	}
+
+	matches, err := releaseValuesMatch(values, config.Values)
+	if err != nil {
+		return "", err
+	}
+	if version == config.Version && matches {
+		return OutcomeUnchanged, nil
+	}
+	return OutcomeUpgraded, client.Upgrade(config)
+}
+
+// releaseValuesMatch compares the values of a release with the key=value
+// settings of an install. Both sides go through JSON so numbers compare
+// equal whichever way they were decoded
+func releaseValuesMatch(current map[string]interface{}, settings []string) (bool, error) {
+	wanted := map[string]interface{}{}
+	for _, setting := range settings {
+		if err := strvals.ParseInto(setting, wanted); err != nil {
+			return false, fmt.Errorf("failed to parse chart value %q: %w", setting, err)
+		}
+	}
+	if current == nil {
+		current = map[string]interface{}{}
+	}
+
+	normalize := func(values map[string]interface{}) (interface{}, error) {
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		err = json.Unmarshal(encoded, &decoded)
+		return decoded, err
+	}
+	a, err := normalize(current)
+	if err != nil {
+		return false, err
+	}
+	b, err := normalize(wanted)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(a, b), nil
+}
+
+// upsertInstallerEntry records entry in the list of installations, replacing
+// the entry of the same name and namespace so re-runs do not add duplicates
+func upsertInstallerEntry(entries []common.InstallerEntry, entry common.InstallerEntry) []common.InstallerEntry {
+	for idx, existing := range entries {
+		if existing.Name == entry.Name && existing.Namespace == entry.Namespace {
+			entries[idx] = entry
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
+
+// helmReleases manages releases with the Helm SDK
+type helmReleases struct{}
+
+func (helmReleases) Get(name, namespace string) (string, map[string]interface{}, bool, error) {
+	return helm.GetRelease(name, namespace)
+}
+
+func (helmReleases) Install(config HelmDeploymentConfig) error {
+	return deployRelease(config)
+}
+
+func (helmReleases) Upgrade(config HelmDeploymentConfig) error {
+	return upgradeRelease(config)
+}
+
+func (helmReleases) Uninstall(name, namespace string) error {
+	return helm.DeleteRelease(name, namespace)
+}
+
+// printOutcome tells the user what the install run did
+func printOutcome(outcome Outcome, config HelmDeploymentConfig) {
+	switch outcome {
+	case OutcomeUnchanged:
+		fmt.Printf(common.Green+"Release %s in namespace %s already runs chart version %s with these settings, nothing changed"+common.Reset+"\n", config.ReleaseName, config.Namespace, config.Version)
+	case OutcomeUpgraded:
+		fmt.Printf(common.Green+"Upgraded release %s in namespace %s to chart version %s"+common.Reset+"\n", config.ReleaseName, config.Namespace, config.Version)
+	case OutcomeReinstalled:
+		fmt.Printf(common.Green+"Reinstalled release %s in namespace %s"+common.Reset+"\n", config.ReleaseName, config.Namespace)
+	default:
+		fmt.Printf(common.Green+"Installed release %s in namespace %s"+common.Reset+"\n", config.ReleaseName, config.Namespace)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package installer
+
+import (
+	"strings"
+	"testing"
+
+	"pb/pkg/common"
+)
+
+// fakeReleases is a release client over an in-memory release, recording the
+// operations it is asked to run
+type fakeReleases struct {
+	version string
+	values  map[string]interface{}
+	found   bool
+	calls   []string
+}
+
+func (f *fakeReleases) Get(_, _ string) (string, map[string]interface{}, bool, error) {
+	return f.version, f.values, f.found, nil
+}
+
+func (f *fakeReleases) Install(HelmDeploymentConfig) error {
+	f.calls = append(f.calls, "install")
+	return nil
+}
+
+func (f *fakeReleases) Upgrade(HelmDeploymentConfig) error {
+	f.calls = append(f.calls, "upgrade")
+	return nil
+}
+
+func (f *fakeReleases) Uninstall(_, _ string) error {
+	f.calls = append(f.calls, "uninstall")
+	return nil
+}
+
+func testDeploymentConfig() HelmDeploymentConfig {
+	return HelmDeploymentConfig{
+		ReleaseName: "parseable",
+		Namespace:   "pb",
+		Version:     "1.6.6",
+		Values:      []string{"parseable.store=local-store", "parseable.localModeSecret.enabled=true", "parseable.replicas=2"},
+	}
+}
+
+// installedValues are the values Helm stores for testDeploymentConfig, with
+// numbers decoded as float64
+func installedValues() map[string]interface{} {
+	return map[string]interface{}{
+		"parseable": map[string]interface{}{
+			"store":           "local-store",
+			"localModeSecret": map[string]interface{}{"enabled": true},
+			"replicas":        float64(2),
+		},
+	}
+}
+
+func TestReconcileInstallsNewRelease(t *testing.T) {
+	client := &fakeReleases{}
+	outcome, err := reconcileRelease(client, testDeploymentConfig(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != OutcomeInstalled || strings.Join(client.calls, ",") != "install" {
+		t.Errorf("expected a new install, got %s with %v", outcome, client.calls)
+	}
+}
+
+func TestReconcileLeavesMatchingReleaseAlone(t *testing.T) {
+	client := &fakeReleases{version: "1.6.6", values: installedValues(), found: true}
+	outcome, err := reconcileRelease(client, testDeploymentConfig(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != OutcomeUnchanged || len(client.calls) != 0 {
+		t.Errorf("expected the release to be left alone, got %s with %v", outcome, client.calls)
+	}
+}
+
+func TestReconcileUpgradesChangedRelease(t *testing.T) {
+	older := &fakeReleases{version: "1.5.0", values: installedValues(), found: true}
+	outcome, err := reconcileRelease(older, testDeploymentConfig(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != OutcomeUpgraded || strings.Join(older.calls, ",") != "upgrade" {
+		t.Errorf("expected another chart version to be upgraded, got %s with %v", outcome, older.calls)
+	}
+
+	values := installedValues()
+	values["parseable"].(map[string]interface{})["replicas"] = float64(1)
+	changed := &fakeReleases{version: "1.6.6", values: values, found: true}
+	if outcome, _ := reconcileRelease(changed, testDeploymentConfig(), false); outcome != OutcomeUpgraded {
+		t.Errorf("expected other values to be upgraded, got %s", outcome)
+	}
+}
+
+func TestReconcileForceReinstalls(t *testing.T) {
+	client := &fakeReleases{version: "1.6.6", values: installedValues(), found: true}
+	outcome, err := reconcileRelease(client, testDeploymentConfig(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != OutcomeReinstalled || strings.Join(client.calls, ",") != "uninstall,install" {
+		t.Errorf("expected the release to be removed and installed again, got %s with %v", outcome, client.calls)
+	}
+}
+
+func TestUpsertInstallerEntry(t *testing.T) {
+	entries := []common.InstallerEntry{
+		{Name: "parseable", Namespace: "pb", Version: "1.5.0"},
+		{Name: "parseable", Namespace: "other", Version: "1.5.0"},
+	}
+	entries = upsertInstallerEntry(entries, common.InstallerEntry{Name: "parseable", Namespace: "pb", Version: "1.6.6"})
+	if len(entries) != 2 || entries[0].Version != "1.6.6" || entries[1].Version != "1.5.0" {
+		t.Errorf("expected the entry to be replaced in place, got %+v", entries)
+	}
+
+	entries = upsertInstallerEntry(entries, common.InstallerEntry{Name: "logs", Namespace: "pb"})
+	if len(entries) != 3 {
+		t.Errorf("expected a new installation to be added, got %+v", entries)
+	}
+}
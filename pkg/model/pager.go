@@ -0,0 +1,180 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	// pageRows is the number of rows fetched from the server at a time
+	pageRows = 300
+
+	// maxCachedRows bounds the rows kept in memory. Once more pages have
+	// been loaded, the pages farthest from the one on screen are dropped and
+	// fetched again if the user scrolls back to them
+	maxCachedRows = 3000
+)
+
+// resultPager loads the result of a query one page of rows at a time, so
+// that large results are never held in memory at once
+type resultPager struct {
+	query     string
+	startTime string
+	endTime   string
+
+	// order is the ORDER BY added to each page. Without a sort order the
+	// server may return rows in a different order for every page, so pages
+	// of a query that does not sort its rows are ordered by p_timestamp
+	order string
+
+	// generation tells the responses of a re-run query apart from those of
+	// the run it replaced
+	generation int
+	rows       int
+	maxPages   int
+
+	// total is the number of rows in the result, -1 until it is known
+	total   int
+	fields  []string
+	pages   map[int][]map[string]interface{}
+	current int
+}
+
+func newResultPager(query, startTime, endTime string, generation int) *resultPager {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	order := ""
+	if !HasOrderBy(query) {
+		order = " order by p_timestamp"
+	}
+	return &resultPager{
+		query:      query,
+		order:      order,
+		startTime:  startTime,
+		endTime:    endTime,
+		generation: generation,
+		rows:       pageRows,
+		maxPages:   max(1, maxCachedRows/pageRows),
+		total:      -1,
+		pages:      map[int][]map[string]interface{}{},
+	}
+}
+
+// pageQuery wraps the query so the server returns only the rows of page
+func (p *resultPager) pageQuery(page int) string {
+	return fmt.Sprintf("select * from (%s) as page%s limit %d offset %d", p.query, p.order, p.rows, page*p.rows)
+}
+
+// countQuery wraps the query so the server returns its number of rows
+func (p *resultPager) countQuery() string {
+	return fmt.Sprintf("select count(*) as count from (%s) as result", p.query)
+}
+
+// cached returns the rows of page if they are in memory
+func (p *resultPager) cached(page int) ([]map[string]interface{}, bool) {
+	rows, ok := p.pages[page]
+	return rows, ok
+}
+
+// store keeps the rows of a fetched page. A short page marks the end of the
+// result, which gives the total before the count query returns
+func (p *resultPager) store(page int, data QueryData) {
+	if len(data.Fields) > 0 {
+		p.fields = data.Fields
+	}
+	p.pages[page] = data.Records
+	if len(data.Records) < p.rows && p.total < 0 {
+		p.total = page*p.rows + len(data.Records)
+	}
+}
+
+// show makes page the current page and drops the cached pages farthest from
+// it while more than maxPages are held
+func (p *resultPager) show(page int) {
+	p.current = page
+	for len(p.pages) > p.maxPages {
+		farthest := -1
+		for cachedPage := range p.pages {
+			if farthest < 0 || distance(cachedPage, page) > distance(farthest, page) {
+				farthest = cachedPage
+			}
+		}
+		delete(p.pages, farthest)
+	}
+}
+
+// lastPage returns the index of the last page, or -1 while the total is not
+// known
+func (p *resultPager) lastPage() int {
+	if p.total < 0 {
+		return -1
+	}
+	if p.total == 0 {
+		return 0
+	}
+	return (p.total - 1) / p.rows
+}
+
+func (p *resultPager) hasNext() bool {
+	if p.total >= 0 {
+		return p.current < p.lastPage()
+	}
+	return len(p.pages[p.current]) == p.rows
+}
+
+func (p *resultPager) hasPrev() bool {
+	return p.current > 0
+}
+
+// loadedRows returns the number of rows held in memory
+func (p *resultPager) loadedRows() int {
+	loaded := 0
+	for _, rows := range p.pages {
+		loaded += len(rows)
+	}
+	return loaded
+}
+
+// status describes the rows on screen for the status bar, e.g.
+// "rows 301-600 of 12,345 (900 loaded)"
+func (p *resultPager) status() string {
+	rows, ok := p.pages[p.current]
+	if !ok {
+		return ""
+	}
+
+	total := "?"
+	if p.total >= 0 {
+		total = humanize.Comma(int64(p.total))
+	}
+	if len(rows) == 0 {
+		return fmt.Sprintf("no rows of %s", total)
+	}
+	first := p.current*p.rows + 1
+	last := first + len(rows) - 1
+	return fmt.Sprintf("rows %s-%s of %s (%s loaded)", humanize.Comma(int64(first)), humanize.Comma(int64(last)), total, humanize.Comma(int64(p.loadedRows())))
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"pb/pkg/config"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func testPage(first, count int) QueryData {
+	records := make([]map[string]interface{}, count)
+	for idx := range records {
+		records[idx] = map[string]interface{}{"id": float64(first + idx)}
+	}
+	return QueryData{Fields: []string{"id"}, Records: records}
+}
+
+func TestPagerQueries(t *testing.T) {
+	pager := newResultPager("select * from backend where status = 500;", "", "", 1)
+
+	if got := pager.pageQuery(2); got != "select * from (select * from backend where status = 500) as page order by p_timestamp limit 300 offset 600" {
+		t.Errorf("unexpected page query %q", got)
+	}
+	if got := pager.countQuery(); !strings.HasPrefix(got, "select count(*) as count from (select * from backend") {
+		t.Errorf("unexpected count query %q", got)
+	}
+}
+
+func TestPagerKeepsQueryOrder(t *testing.T) {
+	sorted := newResultPager("select host, count(*) as hits from backend group by host ORDER BY hits desc", "", "", 1)
+	if got := sorted.pageQuery(0); got != "select * from (select host, count(*) as hits from backend group by host ORDER BY hits desc) as page limit 300 offset 0" {
+		t.Errorf("unexpected page query %q", got)
+	}
+
+	quoted := newResultPager("select * from backend where message = 'order by'", "", "", 1)
+	if got := quoted.pageQuery(0); !strings.Contains(got, "as page order by p_timestamp") {
+		t.Errorf("expected the page to be ordered by p_timestamp, got %q", got)
+	}
+}
+
+func TestPagerKeepsMemoryBounded(t *testing.T) {
+	pager := newResultPager("select * from backend", "", "", 1)
+	for page := 0; page < 15; page++ {
+		pager.store(page, testPage(page*pageRows, pageRows))
+		pager.show(page)
+	}
+
+	if pager.loadedRows() > maxCachedRows {
+		t.Errorf("expected at most %d rows in memory, got %d", maxCachedRows, pager.loadedRows())
+	}
+	if _, ok := pager.cached(14); !ok {
+		t.Error("expected the page on screen to stay in memory")
+	}
+	if _, ok := pager.cached(0); ok {
+		t.Error("expected the page farthest from the screen to be dropped")
+	}
+}
+
+func TestPagerFindsEndFromShortPage(t *testing.T) {
+	pager := newResultPager("select * from backend", "", "", 1)
+	pager.store(0, testPage(0, pageRows))
+	pager.show(0)
+	if !pager.hasNext() || pager.lastPage() != -1 {
+		t.Error("expected a full page with no count to have a next page")
+	}
+
+	pager.store(1, testPage(pageRows, 20))
+	pager.show(1)
+	if pager.hasNext() {
+		t.Error("expected a short page to end the result")
+	}
+	if pager.total != pageRows+20 || pager.lastPage() != 1 {
+		t.Errorf("expected total %d on 2 pages, got %d", pageRows+20, pager.total)
+	}
+	if got := pager.status(); got != "rows 301-320 of 320 (320 loaded)" {
+		t.Errorf("unexpected status %q", got)
+	}
+}
+
+func TestQueryModelPagesThroughResult(t *testing.T) {
+	m := NewQueryModel(config.Profile{URL: "http://localhost:8000"}, "select * from backend", time.Now().Add(-time.Hour), time.Now())
+	m.focused = 2
+	m.focusSelected()
+
+	updated, _ := m.Update(rowCount{generation: 1, total: 2*pageRows + 5, status: fetchOk})
+	m = updated.(QueryModel)
+	updated, _ = m.Update(pageData{generation: 1, page: 0, status: fetchOk, data: testPage(0, pageRows)})
+	m = updated.(QueryModel)
+	if m.table.TotalRows() != pageRows || m.pager.current != 0 {
+		t.Fatalf("expected the first page in the table, got %d rows on page %d", m.table.TotalRows(), m.pager.current)
+	}
+	if !strings.Contains(m.status.Info, "of 605") {
+		t.Errorf("expected the total in the status bar, got %q", m.status.Info)
+	}
+
+	// paging down from the last table page requests the next server page
+	m.table = m.table.PageLast()
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+	if cmd == nil {
+		t.Fatal("expected the next page to be fetched")
+	}
+
+	// a response from an earlier run is ignored
+	updated, _ = m.Update(pageData{generation: 0, page: 1, status: fetchOk, data: testPage(pageRows, pageRows)})
+	m = updated.(QueryModel)
+	if _, ok := m.pager.cached(1); ok {
+		t.Error("expected a stale page to be ignored")
+	}
+
+	updated, _ = m.Update(pageData{generation: 1, page: 1, status: fetchOk, data: testPage(pageRows, pageRows)})
+	m = updated.(QueryModel)
+	if m.pager.current != 1 || m.table.CurrentPage() != 1 {
+		t.Errorf("expected page 1 at its top, got page %d table page %d", m.pager.current, m.table.CurrentPage())
+	}
+}
@@ -24,9 +24,7 @@ import (
 	"net/http"
 	"os"
 	"pb/pkg/config"
-	"pb/pkg/iterator"
-	"strings"
-	"sync"
+	internalHTTP "pb/pkg/http"
 	"time"
 
 	"github.com/charmbracelet/bubbles/help"
@@ -95,11 +93,6 @@ var (
 		key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl r", "(re) run query")),
 	}
 
-	paginatorKeyBinds = []key.Binding{
-		key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl r", "Fetch Next Minute")),
-		key.NewBinding(key.WithKeys("ctrl+b"), key.WithHelp("ctrl b", "Fetch Prev Minute")),
-	}
-
 	QueryNavigationMap = []string{"query", "time", "table"}
 )
 
@@ -114,6 +107,23 @@ type FetchData struct {
 	data   []map[string]interface{}
 }
 
+// pageData is a page of rows fetched by the result pager. When showLast is
+// set the table opens at the end of the page, as the user scrolled up into it
+type pageData struct {
+	generation int
+	page       int
+	showLast   bool
+	status     FetchResult
+	data       QueryData
+}
+
+// rowCount is the total number of rows in the result of the pager's query
+type rowCount struct {
+	generation int
+	total      int
+	status     FetchResult
+}
+
 const (
 	fetchOk FetchResult = iota
 	fetchErr
@@ -125,17 +135,18 @@ const (
 )
 
 type QueryModel struct {
-	width         int
-	height        int
-	table         table.Model
-	query         textarea.Model
-	timeRange     TimeInputModel
-	profile       config.Profile
-	help          help.Model
-	status        StatusBar
-	queryIterator *iterator.QueryIterator[QueryData, FetchResult]
-	overlay       uint
-	focused       int
+	width     int
+	height    int
+	table     table.Model
+	query     textarea.Model
+	timeRange TimeInputModel
+	profile   config.Profile
+	help      help.Model
+	status    StatusBar
+	pager     *resultPager
+	loading   bool
+	overlay   uint
+	focused   int
 }
 
 func (m *QueryModel) focusSelected() {
@@ -154,45 +165,63 @@ func (m *QueryModel) currentFocus() string {
 	return QueryNavigationMap[m.focused]
 }
 
-func (m *QueryModel) initIterator() {
-	iter := createIteratorFromModel(m)
-	m.queryIterator = iter
+// runQuery starts paging through the result of the query in the text area
+// over the selected time range, replacing the result of any earlier run
+func (m *QueryModel) runQuery() tea.Cmd {
+	generation := 1
+	if m.pager != nil {
+		generation = m.pager.generation + 1
+	}
+	m.pager = newResultPager(m.query.Value(), m.timeRange.StartValueUtc(), m.timeRange.EndValueUtc(), generation)
+	m.loading = true
+	m.status.Error = ""
+	m.status.Info = "loading…"
+	return tea.Batch(fetchPage(m.profile, m.pager, 0, false), fetchRowCount(m.profile, m.pager))
 }
 
-func createIteratorFromModel(m *QueryModel) *iterator.QueryIterator[QueryData, FetchResult] {
-	startTime := m.timeRange.start.Time()
-	endTime := m.timeRange.end.Time()
-
-	startTime = startTime.Truncate(time.Minute)
-	endTime = endTime.Truncate(time.Minute).Add(time.Minute)
-
-	table := streamNameFromQuery(m.query.Value())
-	if table != "" {
-		iter := iterator.NewQueryIterator(
-			startTime, endTime,
-			false,
-			func(t1, t2 time.Time) (QueryData, FetchResult) {
-				client := &http.Client{
-					Timeout: time.Second * 50,
-				}
-				return fetchData(client, &m.profile, m.query.Value(), t1.UTC().Format(time.RFC3339), t2.UTC().Format(time.RFC3339))
-			},
-			func(_, _ time.Time) bool {
-				client := &http.Client{
-					Timeout: time.Second * 50,
-				}
-				res, err := fetchData(client, &m.profile, "select count(*) as count from "+table, m.timeRange.StartValueUtc(), m.timeRange.EndValueUtc())
-				if err == fetchErr {
-					return false
-				}
-				count := res.Records[0]["count"].(float64)
-				return count > 0
-			})
-		return &iter
+// showPage puts a page of the result in the table, fetching it first when it
+// is not in memory
+func (m *QueryModel) showPage(page int, showLast bool) tea.Cmd {
+	rows, ok := m.pager.cached(page)
+	if !ok {
+		m.loading = true
+		m.status.Info = "loading…"
+		return fetchPage(m.profile, m.pager, page, showLast)
+	}
+
+	m.loading = false
+	m.pager.show(page)
+	m.UpdateTable(FetchData{status: fetchOk, schema: m.pager.fields, data: rows})
+	if showLast {
+		m.table = m.table.PageLast()
+	} else {
+		m.table = m.table.PageFirst()
 	}
+	m.status.Info = m.pager.status()
 	return nil
 }
 
+// pageAtBoundary loads the neighboring page of the result when a paging key
+// would move past the rows in the table. It returns false when the key
+// should be handled by the table itself
+func (m *QueryModel) pageAtBoundary(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if m.pager == nil || m.loading || m.table.GetIsFilterInputFocused() {
+		return nil, false
+	}
+
+	switch {
+	case key.Matches(msg, tableHelpBinds.PageDown) && m.table.CurrentPage() >= m.table.MaxPages() && m.pager.hasNext():
+		return m.showPage(m.pager.current+1, false), true
+	case key.Matches(msg, tableHelpBinds.PageUp) && m.table.CurrentPage() <= 1 && m.pager.hasPrev():
+		return m.showPage(m.pager.current-1, true), true
+	case key.Matches(msg, tableHelpBinds.PageFirst) && m.pager.current != 0:
+		return m.showPage(0, false), true
+	case key.Matches(msg, tableHelpBinds.PageLast) && m.pager.lastPage() > m.pager.current:
+		return m.showPage(m.pager.lastPage(), true), true
+	}
+	return nil, false
+}
+
 func NewQueryModel(profile config.Profile, queryStr string, startTime, endTime time.Time) QueryModel {
 	w, h, _ := term.GetSize(int(os.Stdout.Fd()))
 
@@ -233,39 +262,23 @@ func NewQueryModel(profile config.Profile, queryStr string, startTime, endTime t
 	help.Styles.FullDesc = lipgloss.NewStyle().Foreground(FocusSecondary)
 
 	model := QueryModel{
-		width:         w,
-		height:        h,
-		table:         table,
-		query:         query,
-		timeRange:     inputs,
-		overlay:       overlayNone,
-		profile:       profile,
-		help:          help,
-		queryIterator: nil,
-		status:        NewStatusBar(profile.URL, w),
+		width:     w,
+		height:    h,
+		table:     table,
+		query:     query,
+		timeRange: inputs,
+		overlay:   overlayNone,
+		profile:   profile,
+		help:      help,
+		status:    NewStatusBar(profile.URL, w),
 	}
-	model.queryIterator = createIteratorFromModel(&model)
+	model.pager = newResultPager(queryStr, inputs.StartValueUtc(), inputs.EndValueUtc(), 1)
+	model.loading = true
 	return model
 }
 
 func (m QueryModel) Init() tea.Cmd {
-	return func() tea.Msg {
-		var ready sync.WaitGroup
-		ready.Add(1)
-		go func() {
-			m.initIterator()
-			for !m.queryIterator.Ready() {
-				time.Sleep(time.Millisecond * 100)
-			}
-			ready.Done()
-		}()
-		ready.Wait()
-		if m.queryIterator.Finished() {
-			return nil
-		}
-
-		return IteratorNext(m.queryIterator)()
-	}
+	return tea.Batch(fetchPage(m.profile, m.pager, 0, false), fetchRowCount(m.profile, m.pager))
 }
 
 func (m QueryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -291,6 +304,34 @@ func (m QueryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case pageData:
+		if msg.generation != m.pager.generation {
+			return m, nil
+		}
+		if msg.status != fetchOk {
+			m.loading = false
+			m.status.Error = "failed to query"
+			return m, nil
+		}
+		m.pager.store(msg.page, msg.data)
+		if len(msg.data.Records) == 0 && msg.page > 0 {
+			// scrolled past the end, stay on the last rows
+			delete(m.pager.pages, msg.page)
+			m.loading = false
+			m.status.Info = m.pager.status()
+			return m, nil
+		}
+		return m, m.showPage(msg.page, msg.showLast)
+
+	case rowCount:
+		if msg.generation == m.pager.generation && msg.status == fetchOk {
+			m.pager.total = msg.total
+			if !m.loading {
+				m.status.Info = m.pager.status()
+			}
+		}
+		return m, nil
+
 		// Is it a key press?
 	case tea.KeyMsg:
 		// special behavior on main page
@@ -322,21 +363,8 @@ func (m QueryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// common keybind
 		if msg.Type == tea.KeyCtrlR {
 			m.overlay = overlayNone
-			if m.queryIterator == nil {
-				return m, NewFetchTask(m.profile, m.query.Value(), m.timeRange.StartValueUtc(), m.timeRange.EndValueUtc())
-			}
-			if m.queryIterator.Ready() && !m.queryIterator.Finished() {
-				return m, IteratorNext(m.queryIterator)
-			}
-			return m, nil
-		}
-
-		if msg.Type == tea.KeyCtrlB {
-			m.overlay = overlayNone
-			if m.queryIterator.CanFetchPrev() {
-				return m, IteratorPrev(m.queryIterator)
-			}
-			return m, nil
+			m.focusSelected()
+			return m, m.runQuery()
 		}
 
 		switch msg.Type {
@@ -349,14 +377,15 @@ func (m QueryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				switch m.currentFocus() {
 				case "query":
 					m.query, cmd = m.query.Update(msg)
-					m.initIterator()
 				case "table":
+					if pageCmd, handled := m.pageAtBoundary(msg); handled {
+						return m, pageCmd
+					}
 					m.table, cmd = m.table.Update(msg)
 				}
 				cmds = append(cmds, cmd)
 			case overlayInputs:
 				m.timeRange, cmd = m.timeRange.Update(msg)
-				m.initIterator()
 				cmds = append(cmds, cmd)
 			}
 		}
@@ -398,28 +427,6 @@ func (m QueryModel) View() string {
 
 	mainViewRenderElements := []string{lipgloss.JoinHorizontal(lipgloss.Top, queryOuter.Render(m.query.View()), timeOuter.Render(time)), tableOuter.Render(m.table.View())}
 
-	if m.queryIterator != nil {
-		inactiveStyle := lipgloss.NewStyle().Foreground(StandardPrimary)
-		activeStyle := lipgloss.NewStyle().Foreground(FocusPrimary)
-		var line strings.Builder
-
-		if m.queryIterator.CanFetchPrev() {
-			line.WriteString(activeStyle.Render("<<"))
-		} else {
-			line.WriteString(inactiveStyle.Render("<<"))
-		}
-
-		fmt.Fprintf(&line, " %d of many ", m.table.TotalRows())
-
-		if m.queryIterator.Ready() && !m.queryIterator.Finished() {
-			line.WriteString(activeStyle.Render(">>"))
-		} else {
-			line.WriteString(inactiveStyle.Render(">>"))
-		}
-
-		mainViewRenderElements = append(mainViewRenderElements, line.String())
-	}
-
 	switch m.overlay {
 	case overlayNone:
 		mainView = lipgloss.JoinVertical(lipgloss.Left, mainViewRenderElements...)
@@ -438,11 +445,7 @@ func (m QueryModel) View() string {
 		helpKeys = m.timeRange.FullHelp()
 	}
 
-	if m.queryIterator != nil {
-		helpKeys = append(helpKeys, paginatorKeyBinds)
-	} else {
-		helpKeys = append(helpKeys, additionalKeyBinds)
-	}
+	helpKeys = append(helpKeys, additionalKeyBinds)
 
 	helpView = m.help.FullHelpView(helpKeys)
 
@@ -471,7 +474,8 @@ func NewFetchTask(profile config.Profile, query string, startTime string, endTim
 		}
 
 		client := &http.Client{
-			Timeout: time.Second * 50,
+			Timeout:   time.Second * 50,
+			Transport: internalHTTP.Transport(&profile),
 		}
 
 		data, status := fetchData(client, &profile, query, startTime, endTime)
@@ -486,43 +490,36 @@ func NewFetchTask(profile config.Profile, query string, startTime string, endTim
 	}
 }
 
-func IteratorNext(iter *iterator.QueryIterator[QueryData, FetchResult]) func() tea.Msg {
+// fetchPage fetches one page of the pager's query
+func fetchPage(profile config.Profile, pager *resultPager, page int, showLast bool) tea.Cmd {
+	query, startTime, endTime, generation := pager.pageQuery(page), pager.startTime, pager.endTime, pager.generation
 	return func() tea.Msg {
-		res := FetchData{
-			status: fetchErr,
-			schema: []string{},
-			data:   []map[string]interface{}{},
-		}
-
-		data, status := iter.Next()
-
-		if status == fetchOk {
-			res.data = data.Records
-			res.schema = data.Fields
-			res.status = fetchOk
+		client := &http.Client{
+			Timeout:   time.Second * 50,
+			Transport: internalHTTP.Transport(&profile),
 		}
-
-		return res
+		data, status := fetchData(client, &profile, query, startTime, endTime)
+		return pageData{generation: generation, page: page, showLast: showLast, status: status, data: data}
 	}
 }
 
-func IteratorPrev(iter *iterator.QueryIterator[QueryData, FetchResult]) func() tea.Msg {
+// fetchRowCount counts the rows in the result of the pager's query
+func fetchRowCount(profile config.Profile, pager *resultPager) tea.Cmd {
+	query, startTime, endTime, generation := pager.countQuery(), pager.startTime, pager.endTime, pager.generation
 	return func() tea.Msg {
-		res := FetchData{
-			status: fetchErr,
-			schema: []string{},
-			data:   []map[string]interface{}{},
+		client := &http.Client{
+			Timeout:   time.Second * 50,
+			Transport: internalHTTP.Transport(&profile),
 		}
-
-		data, status := iter.Prev()
-
-		if status == fetchOk {
-			res.data = data.Records
-			res.schema = data.Fields
-			res.status = fetchOk
+		msg := rowCount{generation: generation, status: fetchErr}
+		data, status := fetchData(client, &profile, query, startTime, endTime)
+		if status == fetchOk && len(data.Records) > 0 {
+			if count, ok := data.Records[0]["count"].(float64); ok {
+				msg.total = int(count)
+				msg.status = fetchOk
+			}
 		}
-
-		return res
+		return msg
 	}
 }
 
@@ -530,29 +527,36 @@ func fetchData(client *http.Client, profile *config.Profile, query string, start
 	data = QueryData{}
 	res = fetchErr
 
-	queryTemplate := `{
-    "query": "%s",
-    "startTime": "%s",
-    "endTime": "%s"
+	finalQuery, err := json.Marshal(map[string]string{
+		"query":     query,
+		"startTime": startTime,
+		"endTime":   endTime,
+	})
+	if err != nil {
+		return
 	}
-	`
-
-	finalQuery := fmt.Sprintf(queryTemplate, query, startTime, endTime)
 
 	endpoint := fmt.Sprintf("%s/%s", profile.URL, "api/v1/query?fields=true")
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer([]byte(finalQuery)))
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(finalQuery))
+	if err != nil {
+		return
+	}
+	auth, err := internalHTTP.AuthHeader(profile)
 	if err != nil {
 		return
 	}
-	req.SetBasicAuth(profile.Username, profile.Password)
+	req.Header.Set("Authorization", auth)
 	req.Header.Add("Content-Type", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
 
 	err = json.NewDecoder(resp.Body).Decode(&data)
-	defer resp.Body.Close()
 	if err != nil {
 		return
 	}
@@ -650,15 +654,3 @@ func countDigits(num int) int {
 	numDigits := int(math.Log10(math.Abs(float64(num)))) + 1
 	return numDigits
 }
-
-func streamNameFromQuery(query string) string {
-	stream := ""
-	tokens := strings.Split(query, " ")
-	for i, token := range tokens {
-		if token == "from" {
-			stream = tokens[i+1]
-			break
-		}
-	}
-	return stream
-}
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"pb/pkg/config"
+	internalHTTP "pb/pkg/http"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -211,9 +212,9 @@ func (m modelSavedQueries) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return commandResultMsg(fmt.Sprintf("Error: %s", err))
 				}
 
-				profile, profileExists := userConfig.Profiles[userConfig.DefaultProfile]
-				if !profileExists {
-					return commandResultMsg("Error: Profile not found")
+				profile, err := userConfig.Default()
+				if err != nil {
+					return commandResultMsg(fmt.Sprintf("Error: %s", err))
 				}
 
 				// Clean the query string
@@ -224,7 +225,7 @@ func (m modelSavedQueries) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				fmt.Printf("Executing command: pb query run %s\n", cleanedQuery)
 
 				// Prepare HTTP client
-				client := &http.Client{Timeout: 60 * time.Second}
+				client := &http.Client{Timeout: 60 * time.Second, Transport: internalHTTP.Transport(&profile)}
 
 				// Determine query time range
 				startTime := selectedQueryApply.StartTime()
@@ -290,12 +291,13 @@ func SavedQueriesMenu() *tea.Program {
 		fmt.Println("Error reading Default Profile")
 	}
 	var userProfile config.Profile
-	if profile, ok := userConfig.Profiles[userConfig.DefaultProfile]; ok {
+	if profile, err := userConfig.Default(); err == nil {
 		userProfile = profile
 	}
 
 	client := &http.Client{
-		Timeout: time.Second * 60,
+		Timeout:   time.Second * 60,
+		Transport: internalHTTP.Transport(&userProfile),
 	}
 	userSavedQueries := fetchFilters(client, &userProfile)
 
@@ -314,7 +316,12 @@ func fetchFilters(client *http.Client, profile *config.Profile) []list.Item {
 		return nil
 	}
 
-	req.SetBasicAuth(profile.Username, profile.Password)
+	auth, err := internalHTTP.AuthHeader(profile)
+	if err != nil {
+		fmt.Println("Error creating request:", err)
+		return nil
+	}
+	req.Header.Set("Authorization", auth)
 	req.Header.Add("Content-Type", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
@@ -382,7 +389,11 @@ func RunQuery(client *http.Client, profile *config.Profile, query string, startT
 	if err != nil {
 		return "", err
 	}
-	req.SetBasicAuth(profile.Username, profile.Password)
+	auth, err := internalHTTP.AuthHeader(profile)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", auth)
 	req.Header.Add("Content-Type", "application/json")
 
 	resp, err := client.Do(req)
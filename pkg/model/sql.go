@@ -0,0 +1,70 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+var sqlWordPattern = regexp.MustCompile(`[a-z_][a-z0-9_]*`)
+
+// SQLWords returns the lowercased words of a statement, leaving out string
+// literals, quoted identifiers and comments
+func SQLWords(sql string) []string {
+	var code strings.Builder
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := i + 1
+			for end < len(sql) && sql[end] != c {
+				end++
+			}
+			code.WriteByte(' ')
+			i = end
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return sqlWordPattern.FindAllString(strings.ToLower(code.String()), -1)
+			}
+			code.WriteByte(' ')
+			i += end
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return sqlWordPattern.FindAllString(strings.ToLower(code.String()), -1)
+			}
+			code.WriteByte(' ')
+			i += end + 3
+		default:
+			code.WriteByte(c)
+		}
+	}
+	return sqlWordPattern.FindAllString(strings.ToLower(code.String()), -1)
+}
+
+// HasOrderBy reports whether a statement sorts its rows, without which
+// pages are not guaranteed to line up
+func HasOrderBy(sql string) bool {
+	words := SQLWords(sql)
+	for idx := 0; idx+1 < len(words); idx++ {
+		if words[idx] == "order" && words[idx+1] == "by" {
+			return true
+		}
+	}
+	return false
+}
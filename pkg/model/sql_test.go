@@ -0,0 +1,33 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+import "testing"
+
+func TestHasOrderBy(t *testing.T) {
+	if !HasOrderBy("select * from backend ORDER BY p_timestamp") {
+		t.Error("expected ORDER BY to be found")
+	}
+	if HasOrderBy("select \"order\" from backend") {
+		t.Error("expected a quoted column named order not to count")
+	}
+	if HasOrderBy("select * from backend -- order by p_timestamp") {
+		t.Error("expected an ORDER BY in a comment not to count")
+	}
+	if HasOrderBy("select * from backend /* order by host */") {
+		t.Error("expected an ORDER BY in a block comment not to count")
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2024 Parseable, Inc
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package s3 uploads objects to Amazon S3 and S3-compatible storage such as
+// MinIO. It covers only what pb needs to stream exports, so it signs requests
+// itself instead of pulling in an SDK.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRegion is used when neither a flag nor the environment sets one
+const DefaultRegion = "us-east-1"
+
+// PartSize is the amount of data buffered before it is sent as one part of a
+// multipart upload. S3 requires every part but the last to be at least 5 MiB
+var PartSize = 8 << 20
+
+// Config holds the endpoint and credentials for uploads
+type Config struct {
+	// Endpoint is the base URL of the storage service, e.g.
+	// http://localhost:9000 for a local MinIO. When empty the AWS endpoint
+	// for Region is used
+	Endpoint     string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	Client *http.Client
+}
+
+// ConfigFromEnv reads the standard AWS environment variables:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION
+// (or AWS_DEFAULT_REGION) and AWS_ENDPOINT_URL
+func ConfigFromEnv() Config {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return Config{
+		Endpoint:     os.Getenv("AWS_ENDPOINT_URL"),
+		Region:       region,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Validate reports missing credentials before any data is read
+func (c Config) Validate() error {
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return errors.New("missing object storage credentials, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or pass them as flags")
+	}
+	if c.Endpoint != "" {
+		if _, err := url.Parse(c.Endpoint); err != nil {
+			return fmt.Errorf("invalid object storage endpoint %q: %w", c.Endpoint, err)
+		}
+	}
+	return nil
+}
+
+func (c Config) region() string {
+	if c.Region == "" {
+		return DefaultRegion
+	}
+	return c.Region
+}
+
+func (c Config) endpoint() string {
+	if c.Endpoint != "" {
+		return strings.TrimRight(c.Endpoint, "/")
+	}
+	return fmt.Sprintf("https://s3.%s.amazonaws.com", c.region())
+}
+
+func (c Config) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return &http.Client{Timeout: 5 * time.Minute}
+}
+
+// ParseURL splits an s3://bucket/key URL into its bucket and key
+func ParseURL(raw string) (bucket, key string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid object storage URL %q: %w", raw, err)
+	}
+	if u.Scheme != "s3" {
+		return "", "", fmt.Errorf("unsupported object storage URL %q, use s3://bucket/key", raw)
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" || strings.HasSuffix(key, "/") {
+		return "", "", fmt.Errorf("object storage URL %q must name a bucket and an object key, e.g. s3://bucket/exports/results.csv", raw)
+	}
+	return u.Host, key, nil
+}
+
+// Upload streams data to a single object. Data is buffered up to PartSize;
+// anything larger is sent as a multipart upload so memory use stays bounded
+// however large the export is. Nothing is visible in the bucket until Close
+// returns without error
+type Upload struct {
+	cfg         Config
+	bucket      string
+	key         string
+	contentType string
+
+	buf      bytes.Buffer
+	uploadID string
+	parts    []completedPart
+	err      error
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// NewUpload prepares an upload to bucket/key. No request is made until
+// enough data has been written or the upload is closed
+func NewUpload(cfg Config, bucket, key, contentType string) *Upload {
+	return &Upload{cfg: cfg, bucket: bucket, key: key, contentType: contentType}
+}
+
+func (u *Upload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	n, _ := u.buf.Write(p)
+	for u.buf.Len() >= PartSize {
+		if err := u.flushPart(u.buf.Next(PartSize)); err != nil {
+			u.err = err
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Close sends the remaining data and completes the upload. Small uploads
+// that never filled a part are sent with a single PUT
+func (u *Upload) Close() error {
+	if u.err != nil {
+		return u.err
+	}
+	if u.uploadID == "" {
+		resp, err := u.do(http.MethodPut, nil, u.buf.Bytes(), u.contentType)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if u.buf.Len() > 0 {
+		if err := u.flushPart(u.buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: u.parts})
+	if err != nil {
+		return err
+	}
+	resp, err := u.do(http.MethodPost, url.Values{"uploadId": {u.uploadID}}, body, "application/xml")
+	if err != nil {
+		u.Abort()
+		return err
+	}
+	defer resp.Body.Close()
+
+	// a complete request can fail after the 200 status has been sent, in
+	// which case the error is in the body
+	data, _ := io.ReadAll(resp.Body)
+	if bytes.Contains(data, []byte("<Error>")) {
+		u.Abort()
+		return fmt.Errorf("failed to complete upload to s3://%s/%s: %s", u.bucket, u.key, errorMessage(data))
+	}
+	return nil
+}
+
+// Abort discards a multipart upload so no partial object or orphaned parts
+// are left behind. It is a no-op for uploads that have not started one
+func (u *Upload) Abort() {
+	if u.uploadID == "" {
+		return
+	}
+	if resp, err := u.do(http.MethodDelete, url.Values{"uploadId": {u.uploadID}}, nil, ""); err == nil {
+		resp.Body.Close()
+	}
+	u.uploadID = ""
+}
+
+func (u *Upload) flushPart(data []byte) error {
+	if u.uploadID == "" {
+		if err := u.start(); err != nil {
+			return err
+		}
+	}
+
+	number := len(u.parts) + 1
+	resp, err := u.do(http.MethodPut, url.Values{
+		"partNumber": {strconv.Itoa(number)},
+		"uploadId":   {u.uploadID},
+	}, data, "")
+	if err != nil {
+		u.Abort()
+		return err
+	}
+	resp.Body.Close()
+	u.parts = append(u.parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	return nil
+}
+
+func (u *Upload) start() error {
+	resp, err := u.do(http.MethodPost, url.Values{"uploads": {""}}, nil, u.contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to start upload to s3://%s/%s: %w", u.bucket, u.key, err)
+	}
+	if result.UploadID == "" {
+		return fmt.Errorf("failed to start upload to s3://%s/%s: no upload id in response", u.bucket, u.key)
+	}
+	u.uploadID = result.UploadID
+	return nil
+}
+
+// do sends a signed request for the object and returns the response when the
+// status is 2xx. The bucket is addressed path style, which every
+// S3-compatible service supports
+func (u *Upload) do(method string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	target := fmt.Sprintf("%s/%s/%s", u.cfg.endpoint(), u.bucket, escapePath(u.key))
+	req, err := http.NewRequestWithContext(context.Background(), method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = canonicalQuery(query)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sign(req, u.cfg, body, time.Now())
+
+	resp, err := u.cfg.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("upload to s3://%s/%s failed: %w", u.bucket, u.key, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("upload to s3://%s/%s failed: %s: %s", u.bucket, u.key, resp.Status, errorMessage(data))
+	}
+	return resp, nil
+}
+
+// errorMessage extracts the code and message from an S3 error document,
+// falling back to the raw body
+func errorMessage(data []byte) string {
+	var doc struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil || doc.Code == "" {
+		return strings.TrimSpace(string(data))
+	}
+	return fmt.Sprintf("%s: %s", doc.Code, doc.Message)
+}